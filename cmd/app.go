@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"webstack-cli/internal/app"
+	"webstack-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var appCmd = &cobra.Command{
+	Use:   "app",
+	Short: "Install web applications",
+	Long:  `Install WordPress, Laravel or any composer project into an existing domain.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Use 'webstack app --help' for available commands")
+	},
+}
+
+var appInstallCmd = &cobra.Command{
+	Use:   "install [app] [domain]",
+	Short: "Install an application into a domain",
+	Long: `Download an application into the domain's htdocs, create its database and user,
+write wp-config.php/.env, set ownership to www-data and apply the matching vhost rules.
+
+Available applications: ` + strings.Join(app.Apps(), ", ") + `
+
+Usage:
+  sudo webstack app install wordpress example.com
+  sudo webstack app install laravel example.com --db-type postgresql
+  sudo webstack app install composer example.com --package symfony/skeleton`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+
+		dbType, _ := cmd.Flags().GetString("db-type")
+		dbName, _ := cmd.Flags().GetString("db-name")
+		dbUser, _ := cmd.Flags().GetString("db-user")
+		noDatabase, _ := cmd.Flags().GetBool("no-database")
+		pkg, _ := cmd.Flags().GetString("package")
+		force, _ := cmd.Flags().GetBool("force")
+		app.Install(args[0], args[1], app.Options{
+			DBType:     dbType,
+			DBName:     dbName,
+			DBUser:     dbUser,
+			NoDatabase: noDatabase,
+			Package:    pkg,
+			Force:      force,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(appCmd)
+	appCmd.AddCommand(appInstallCmd)
+
+	appInstallCmd.Flags().String("db-type", "mysql", "Database type: mysql or postgresql")
+	appInstallCmd.Flags().String("db-name", "", "Database name (default: derived from the domain)")
+	appInstallCmd.Flags().String("db-user", "", "Database user (default: derived from the domain)")
+	appInstallCmd.Flags().Bool("no-database", false, "Do not create a database and user")
+	appInstallCmd.Flags().String("package", "", "Composer package for the composer installer, e.g. symfony/skeleton")
+	appInstallCmd.Flags().Bool("force", false, "Install even if htdocs already contains files")
+}
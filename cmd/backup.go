@@ -3,8 +3,12 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"webstack-cli/internal/backup"
+	"webstack-cli/internal/bytesize"
+	"webstack-cli/internal/prompt"
+	"webstack-cli/internal/ui"
 
 	"github.com/spf13/cobra"
 )
@@ -27,37 +31,22 @@ Usage:
   webstack backup create --domain example.com           # Single domain
   webstack backup create --all --compress gzip          # With compression
   webstack backup create --mysql wordpress              # Single MySQL database
-  webstack backup create --postgresql crm               # Single PostgreSQL database`,
+  webstack backup create --postgresql crm               # Single PostgreSQL database
+  webstack backup create --postgresql crm --cluster 17/main  # ... of another PostgreSQL cluster
+  webstack backup create --all --encrypt age1...        # Encrypted for an age recipient
+  webstack backup create --all --encrypt ops@example.com # Encrypted for a GPG key in root's keyring`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
-		backupAll, _ := cmd.Flags().GetBool("all")
-		domain, _ := cmd.Flags().GetString("domain")
-		mysqlDB, _ := cmd.Flags().GetString("mysql")
-		postgresDB, _ := cmd.Flags().GetString("postgresql")
 		compression, _ := cmd.Flags().GetString("compress")
 		encryption, _ := cmd.Flags().GetString("encrypt")
 
-		// Determine backup type and scope
-		var backupType, scope string
-
-		if backupAll {
-			backupType = "full"
-			scope = "all"
-		} else if domain != "" {
-			backupType = "domain"
-			scope = domain
-		} else if mysqlDB != "" {
-			backupType = "database"
-			scope = "mysql:" + mysqlDB
-		} else if postgresDB != "" {
-			backupType = "database"
-			scope = "postgresql:" + postgresDB
-		} else {
-			fmt.Println("Please specify --all, --domain, --mysql, or --postgresql")
+		backupType, scope := backupScope(cmd)
+		if backupType == "" {
+			ui.Invalid("Please specify --all, --domain, --mysql, or --postgresql\n")
 			return
 		}
 
@@ -70,7 +59,7 @@ Usage:
 
 		backupID, size, compressedSize, err := backup.Create(opts)
 		if err != nil {
-			fmt.Printf("❌ Backup failed: %v\n", err)
+			ui.Fail("❌ Backup failed: %v\n", err)
 			return
 		}
 
@@ -80,7 +69,10 @@ Usage:
 		fmt.Printf("   Location: %s\n", backupPath)
 		fmt.Printf("   Type: %s (%s)\n", backupType, scope)
 		fmt.Printf("   Size: %s → %s (compressed)\n",
-			backup.FormatBytes(size), backup.FormatBytes(compressedSize))
+			bytesize.Format(size), bytesize.Format(compressedSize))
+		if encryption != "" && encryption != "none" {
+			fmt.Printf("   Encrypted: %s\n", encryption)
+		}
 		fmt.Printf("\n   Commands:\n")
 		fmt.Printf("   - List details: webstack backup list | grep %s\n", backupID[:8])
 		fmt.Printf("   - Restore: sudo webstack backup restore %s\n", backupID)
@@ -89,6 +81,70 @@ Usage:
 	},
 }
 
+var backupRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Create a backup and upload it to a remote target",
+	Long: `Create a backup, upload it off the server and delete the backups in the target
+older than --keep days. Without --domain, --mysql or --postgresql a full backup is made.
+Usage:
+  webstack backup run --target s3://bucket/webstack --keep 30        # aws CLI credentials
+  webstack backup run --target sftp://backup@nas.example.com/srv/backups
+  webstack backup run --target b2:webstack-backups --encrypt age1...  # rclone remote
+  webstack backup run --target s3://bucket/web --domain example.com --remove-local
+
+S3-compatible storage is reached with AWS_ENDPOINT_URL, SFTP with root's SSH key
+and rclone remotes with root's rclone.conf. Restore with:
+  webstack backup restore <backup-id> --target <target>`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("This command requires root privileges (use sudo)\n")
+			return
+		}
+
+		targetSpec, _ := cmd.Flags().GetString("target")
+		compression, _ := cmd.Flags().GetString("compress")
+		encryption, _ := cmd.Flags().GetString("encrypt")
+		keepDays, _ := cmd.Flags().GetInt("keep")
+		removeLocal, _ := cmd.Flags().GetBool("remove-local")
+
+		target, err := backup.ParseTarget(targetSpec)
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+
+		backupType, scope := backupScope(cmd)
+		if backupType == "" {
+			backupType, scope = "full", "all"
+		}
+
+		opts := backup.BackupOptions{
+			Type:        backupType,
+			Scope:       scope,
+			Compression: compression,
+			Encryption:  encryption,
+		}
+
+		backupID, err := backup.Run(opts, target, keepDays, removeLocal)
+		if err != nil {
+			ui.Fail("❌ Backup failed: %v\n", err)
+			if backupID != "" {
+				fmt.Printf("   The local copy is kept: sudo webstack backup list | grep %s\n", backupID)
+			}
+			return
+		}
+
+		fmt.Printf("✅ Backup uploaded successfully\n")
+		fmt.Printf("   ID: %s\n", backupID)
+		fmt.Printf("   Target: %s\n", target)
+		if keepDays > 0 {
+			fmt.Printf("   Retention: %d days\n", keepDays)
+		}
+		fmt.Printf("   Restore: sudo webstack backup restore %s --target %s\n", backupID, targetSpec)
+	},
+}
+
 var backupListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all backups",
@@ -97,20 +153,35 @@ Usage:
   webstack backup list                        # All backups
   webstack backup list --domain example.com   # Backups for domain
   webstack backup list --since 7d             # Last 7 days
-  webstack backup list --format json          # JSON output`,
+  webstack backup list --format json          # JSON output
+  webstack backup list --target s3://bucket/webstack   # Backups in a remote target`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
 		domain, _ := cmd.Flags().GetString("domain")
 		since, _ := cmd.Flags().GetString("since")
 		format, _ := cmd.Flags().GetString("format")
-
-		backups, err := backup.List(domain, since)
+		targetSpec, _ := cmd.Flags().GetString("target")
+
+		var backups []backup.Backup
+		var err error
+		location := "/var/backups/webstack/archives/"
+		if targetSpec != "" {
+			target, parseErr := backup.ParseTarget(targetSpec)
+			if parseErr != nil {
+				ui.Fail("❌ %v\n", parseErr)
+				return
+			}
+			backups, err = backup.ListRemote(target)
+			location = target.String()
+		} else {
+			backups, err = backup.List(domain, since)
+		}
 		if err != nil {
-			fmt.Printf("❌ Error listing backups: %v\n", err)
+			ui.Fail("❌ Error listing backups: %v\n", err)
 			return
 		}
 
@@ -138,16 +209,16 @@ Usage:
 				idShort,
 				b.Type,
 				b.Timestamp.Format("2006-01-02 15:04"),
-				backup.FormatBytes(b.SizeBytes),
-				backup.FormatBytes(b.CompressedSize),
+				bytesize.Format(b.SizeBytes),
+				bytesize.Format(b.CompressedSize),
 			)
 		}
 
 		fmt.Printf("\nTotal: %d backups | Total size: %s\n",
 			len(backups),
-			backup.FormatBytes(backup.GetTotalSize(backups)),
+			bytesize.Format(backup.GetTotalSize(backups)),
 		)
-		fmt.Printf("\nBackup location: /var/backups/webstack/archives/\n")
+		fmt.Printf("\nBackup location: %s\n", location)
 	},
 }
 
@@ -159,11 +230,17 @@ Usage:
   webstack backup restore abc123                  # Restore full backup
   webstack backup restore abc123 --domain example.com  # Restore single domain
   webstack backup restore abc123 --verify-only   # Check backup integrity
-  webstack backup restore abc123 --force          # Skip confirmation`,
+  webstack backup restore abc123 --force          # Skip confirmation
+  webstack backup restore abc123 --identity ~/age.key  # Decrypt with this age identity
+  webstack backup restore abc123 --target s3://bucket/webstack  # Download it first
+
+Encrypted backups are decrypted transparently: age archives with the identity
+from --identity (default: ` + backup.DefaultAgeIdentity + `), GPG archives with
+the secret key in root's keyring.`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -171,12 +248,27 @@ Usage:
 		domain, _ := cmd.Flags().GetString("domain")
 		verifyOnly, _ := cmd.Flags().GetBool("verify-only")
 		force, _ := cmd.Flags().GetBool("force")
+		identity, _ := cmd.Flags().GetString("identity")
+		targetSpec, _ := cmd.Flags().GetString("target")
+
+		if targetSpec != "" {
+			target, err := backup.ParseTarget(targetSpec)
+			if err != nil {
+				ui.Fail("❌ %v\n", err)
+				return
+			}
+			fmt.Printf("📥 Downloading backup %s from %s...\n", backupID, target)
+			if err := backup.Fetch(backupID, target); err != nil {
+				ui.Fail("❌ Download failed: %v\n", err)
+				return
+			}
+		}
 
 		if verifyOnly {
 			fmt.Printf("🔍 Verifying backup integrity: %s\n", backupID)
 			ok, err := backup.Verify(backupID)
 			if err != nil {
-				fmt.Printf("❌ Verification failed: %v\n", err)
+				ui.Fail("❌ Verification failed: %v\n", err)
 				return
 			}
 			if ok {
@@ -185,26 +277,26 @@ Usage:
 			return
 		}
 
+		if !prompt.Allow("restore backup " + backupID) {
+			return
+		}
 		if !force {
-			fmt.Printf("⚠️  This will restore from backup: %s\n", backupID)
+			ui.Warn("⚠️  This will restore from backup: %s\n", backupID)
 			if domain != "" {
 				fmt.Printf("   Domain: %s\n", domain)
 			} else {
 				fmt.Println("   Scope: Full system")
 			}
-			fmt.Print("Type 'yes' to confirm: ")
-			var confirm string
-			fmt.Scanln(&confirm)
-			if confirm != "yes" {
+			if !prompt.ConfirmTyped() {
 				fmt.Println("Restore cancelled")
 				return
 			}
 		}
 
 		fmt.Printf("📥 Starting restore from backup: %s\n", backupID)
-		itemsRestored, err := backup.Restore(backupID, domain)
+		itemsRestored, err := backup.Restore(backupID, domain, identity)
 		if err != nil {
-			fmt.Printf("❌ Restore failed: %v\n", err)
+			ui.Fail("❌ Restore failed: %v\n", err)
 			return
 		}
 
@@ -227,19 +319,19 @@ Usage:
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
 		backupID := args[0]
 		force, _ := cmd.Flags().GetBool("force")
 
+		if !prompt.Allow("delete backup " + backupID) {
+			return
+		}
 		if !force {
-			fmt.Printf("⚠️  Delete backup: %s\n", backupID)
-			fmt.Print("Type 'yes' to confirm: ")
-			var confirm string
-			fmt.Scanln(&confirm)
-			if confirm != "yes" {
+			ui.Warn("⚠️  Delete backup: %s\n", backupID)
+			if !prompt.ConfirmTyped() {
 				fmt.Println("Deletion cancelled")
 				return
 			}
@@ -247,7 +339,7 @@ Usage:
 
 		err := backup.Delete(backupID)
 		if err != nil {
-			fmt.Printf("❌ Delete failed: %v\n", err)
+			ui.Fail("❌ Delete failed: %v\n", err)
 			return
 		}
 
@@ -264,7 +356,7 @@ Usage:
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -273,14 +365,14 @@ Usage:
 		fmt.Printf("🔍 Verifying backup: %s\n", backupID)
 		ok, err := backup.Verify(backupID)
 		if err != nil {
-			fmt.Printf("❌ Verification failed: %v\n", err)
+			ui.Fail("❌ Verification failed: %v\n", err)
 			return
 		}
 
 		if ok {
 			fmt.Println("✅ Backup is valid and ready to restore")
 		} else {
-			fmt.Println("❌ Backup integrity check failed")
+			ui.Fail("❌ Backup integrity check failed\n")
 		}
 	},
 }
@@ -300,10 +392,12 @@ var backupScheduleEnableCmd = &cobra.Command{
 	Long: `Set up automatic daily backups.
 Usage:
   webstack backup schedule enable --time 02:00 --type full --keep 30
-  webstack backup schedule enable --time 03:00 --type full --compress gzip`,
+  webstack backup schedule enable --time 03:00 --type full --compress gzip
+  webstack backup schedule enable --encrypt age1...   # Encrypt every scheduled backup
+  webstack backup schedule enable --target s3://bucket/webstack --keep 30   # Upload and prune off-box`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -311,6 +405,8 @@ Usage:
 		backupType, _ := cmd.Flags().GetString("type")
 		keepDays, _ := cmd.Flags().GetInt("keep")
 		compression, _ := cmd.Flags().GetString("compress")
+		encryption, _ := cmd.Flags().GetString("encrypt")
+		target, _ := cmd.Flags().GetString("target")
 
 		if backupTime == "" {
 			backupTime = "02:00"
@@ -326,10 +422,13 @@ Usage:
 		fmt.Printf("   Time: %s UTC daily\n", backupTime)
 		fmt.Printf("   Type: %s\n", backupType)
 		fmt.Printf("   Retention: %d days\n", keepDays)
+		if target != "" {
+			fmt.Printf("   Target: %s\n", target)
+		}
 
-		err := backup.EnableSchedule(backupTime, backupType, keepDays, compression)
+		err := backup.EnableSchedule(backupTime, backupType, keepDays, compression, encryption, target)
 		if err != nil {
-			fmt.Printf("❌ Failed to enable schedule: %v\n", err)
+			ui.Fail("❌ Failed to enable schedule: %v\n", err)
 			return
 		}
 
@@ -344,13 +443,13 @@ var backupScheduleDisableCmd = &cobra.Command{
 	Short: "Disable automatic backups",
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
 		err := backup.DisableSchedule()
 		if err != nil {
-			fmt.Printf("❌ Failed to disable schedule: %v\n", err)
+			ui.Fail("❌ Failed to disable schedule: %v\n", err)
 			return
 		}
 
@@ -363,18 +462,18 @@ var backupScheduleStatusCmd = &cobra.Command{
 	Short: "Show backup schedule status",
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
 		enabled, nextRun, err := backup.GetScheduleStatus()
 		if err != nil {
-			fmt.Printf("❌ Error getting schedule status: %v\n", err)
+			ui.Fail("❌ Error getting schedule status: %v\n", err)
 			return
 		}
 
 		if !enabled {
-			fmt.Println("❌ Automatic backups are disabled")
+			ui.Fail("❌ Automatic backups are disabled\n")
 			fmt.Println("   Enable with: webstack backup schedule enable")
 			return
 		}
@@ -393,13 +492,13 @@ Usage:
   webstack backup status   # Show storage info`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
 		info, err := backup.GetStorageStatus()
 		if err != nil {
-			fmt.Printf("❌ Error getting storage status: %v\n", err)
+			ui.Fail("❌ Error getting storage status: %v\n", err)
 			return
 		}
 
@@ -414,7 +513,7 @@ Usage:
 		fmt.Printf("Space Used: %.1f%%\n", percentUsed)
 
 		if percentUsed > 90 {
-			fmt.Println("⚠️  Warning: Storage usage is high!")
+			ui.Warn("⚠️  Warning: Storage usage is high!\n")
 		}
 
 		if info.ScheduleEnabled {
@@ -434,7 +533,7 @@ Usage:
 	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -446,7 +545,7 @@ Usage:
 
 		err := backup.Export(backupID, destination)
 		if err != nil {
-			fmt.Printf("❌ Export failed: %v\n", err)
+			ui.Fail("❌ Export failed: %v\n", err)
 			return
 		}
 
@@ -463,7 +562,7 @@ Usage:
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -473,7 +572,7 @@ Usage:
 
 		backupID, err := backup.Import(source)
 		if err != nil {
-			fmt.Printf("❌ Import failed: %v\n", err)
+			ui.Fail("❌ Import failed: %v\n", err)
 			return
 		}
 
@@ -487,6 +586,7 @@ func init() {
 	rootCmd.AddCommand(backupCmd)
 
 	backupCmd.AddCommand(backupCreateCmd)
+	backupCmd.AddCommand(backupRunCmd)
 	backupCmd.AddCommand(backupListCmd)
 	backupCmd.AddCommand(backupRestoreCmd)
 	backupCmd.AddCommand(backupDeleteCmd)
@@ -506,18 +606,35 @@ func init() {
 	backupCreateCmd.Flags().StringP("domain", "d", "", "Domain name to backup")
 	backupCreateCmd.Flags().String("mysql", "", "MySQL database name")
 	backupCreateCmd.Flags().String("postgresql", "", "PostgreSQL database name")
+	backupCreateCmd.Flags().String("cluster", "", "PostgreSQL cluster of --postgresql, e.g. 17/main (default: the one on port 5432)")
 	backupCreateCmd.Flags().StringP("compress", "c", "gzip", "Compression: gzip, bzip2, xz, none")
-	backupCreateCmd.Flags().StringP("encrypt", "e", "none", "Encryption: none, aes-256")
+	backupCreateCmd.Flags().StringP("encrypt", "e", "none", "Encrypt for age recipients (age1...) or GPG recipients, comma-separated")
+
+	// Run flags
+	backupRunCmd.Flags().StringP("target", "t", "", "Remote target: s3://bucket/path, sftp://user@host/path or rclone remote:path")
+	backupRunCmd.Flags().BoolP("all", "a", false, "Backup entire system (default)")
+	backupRunCmd.Flags().StringP("domain", "d", "", "Domain name to backup")
+	backupRunCmd.Flags().String("mysql", "", "MySQL database name")
+	backupRunCmd.Flags().String("postgresql", "", "PostgreSQL database name")
+	backupRunCmd.Flags().String("cluster", "", "PostgreSQL cluster of --postgresql, e.g. 17/main (default: the one on port 5432)")
+	backupRunCmd.Flags().StringP("compress", "c", "gzip", "Compression: gzip, bzip2, xz, none")
+	backupRunCmd.Flags().StringP("encrypt", "e", "none", "Encrypt for age recipients (age1...) or GPG recipients, comma-separated")
+	backupRunCmd.Flags().IntP("keep", "k", 0, "Delete backups in the target older than N days (0 keeps all)")
+	backupRunCmd.Flags().Bool("remove-local", false, "Delete the local copy after the upload")
+	backupRunCmd.MarkFlagRequired("target")
 
 	// List flags
 	backupListCmd.Flags().StringP("domain", "d", "", "Filter by domain")
 	backupListCmd.Flags().StringP("since", "s", "", "Filter by time (e.g., 7d, 30d, 1y)")
 	backupListCmd.Flags().StringP("format", "f", "table", "Output format: table, json")
+	backupListCmd.Flags().String("target", "", "List the backups in a remote target")
 
 	// Restore flags
 	backupRestoreCmd.Flags().StringP("domain", "d", "", "Restore specific domain only")
 	backupRestoreCmd.Flags().BoolP("verify-only", "v", false, "Verify backup without restoring")
 	backupRestoreCmd.Flags().BoolP("force", "f", false, "Skip confirmation")
+	backupRestoreCmd.Flags().String("target", "", "Download the backup from a remote target first")
+	backupRestoreCmd.Flags().String("identity", "", "age identity file for encrypted backups (default: "+backup.DefaultAgeIdentity+")")
 
 	// Delete flags
 	backupDeleteCmd.Flags().BoolP("force", "f", false, "Skip confirmation")
@@ -527,4 +644,30 @@ func init() {
 	backupScheduleEnableCmd.Flags().StringP("type", "T", "full", "Backup type: full, incremental")
 	backupScheduleEnableCmd.Flags().IntP("keep", "k", 30, "Keep backups for N days")
 	backupScheduleEnableCmd.Flags().StringP("compress", "c", "gzip", "Compression: gzip, bzip2, xz, none")
+	backupScheduleEnableCmd.Flags().StringP("encrypt", "e", "none", "Encrypt for age recipients (age1...) or GPG recipients, comma-separated")
+	backupScheduleEnableCmd.Flags().String("target", "", "Upload each backup to a remote target and prune it after --keep days")
+}
+
+// backupScope returns the backup type and scope selected by --all, --domain,
+// --mysql or --postgresql (and --cluster), or empty strings when none is given
+func backupScope(cmd *cobra.Command) (string, string) {
+	backupAll, _ := cmd.Flags().GetBool("all")
+	domain, _ := cmd.Flags().GetString("domain")
+	mysqlDB, _ := cmd.Flags().GetString("mysql")
+	postgresDB, _ := cmd.Flags().GetString("postgresql")
+	cluster, _ := cmd.Flags().GetString("cluster")
+
+	switch {
+	case backupAll:
+		return "full", "all"
+	case domain != "":
+		return "domain", domain
+	case mysqlDB != "":
+		return "database", "mysql:" + mysqlDB
+	case postgresDB != "" && cluster != "":
+		return "database", "postgresql@" + strings.Replace(cluster, "/", "-", 1) + ":" + postgresDB
+	case postgresDB != "":
+		return "database", "postgresql:" + postgresDB
+	}
+	return "", ""
 }
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"webstack-cli/internal/domain"
+
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the Nginx response cache of domains",
+	Long: `Cache the responses of PHP-FPM (FastCGI cache), Apache and upstream apps (proxy
+cache) in Nginx. The cache zones live in /etc/nginx/includes/cache.conf; requests
+with session or login cookies and authenticated requests always skip the cache.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		domain.CacheStatus()
+	},
+}
+
+var cacheEnableCmd = &cobra.Command{
+	Use:   "enable [domain]",
+	Short: "Enable the response cache of a domain",
+	Long: `Enable the response cache of a domain, or change its TTL. Examples:
+  webstack cache enable example.com --ttl 10m
+  webstack cache enable shop.example.com --ttl 1h --bypass-cookie cart_id`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ttl, _ := cmd.Flags().GetString("ttl")
+		cookies, _ := cmd.Flags().GetStringSlice("bypass-cookie")
+		domain.EnableCache(args[0], domain.CacheOptions{
+			TTL:           ttl,
+			BypassCookies: cookies,
+		})
+	},
+}
+
+var cacheDisableCmd = &cobra.Command{
+	Use:   "disable [domain]",
+	Short: "Disable the response cache of a domain and purge it",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		domain.DisableCache(args[0])
+	},
+}
+
+var cachePurgeCmd = &cobra.Command{
+	Use:   "purge [domain] [path]",
+	Short: "Remove cached responses of a domain",
+	Long: `Remove all cached responses of a domain, or those of one path. Examples:
+  webstack cache purge example.com
+  webstack cache purge example.com /blog/hello-world
+  webstack cache purge example.com '/blog/*'`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := ""
+		if len(args) == 2 {
+			path = args[1]
+		}
+		domain.PurgeCache(args[0], path)
+	},
+}
+
+var cacheStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the cache zones and cached domains",
+	Run: func(cmd *cobra.Command, args []string) {
+		domain.CacheStatus()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheEnableCmd)
+	cacheCmd.AddCommand(cacheDisableCmd)
+	cacheCmd.AddCommand(cachePurgeCmd)
+	cacheCmd.AddCommand(cacheStatusCmd)
+
+	cacheEnableCmd.Flags().String("ttl", "", "How long responses are cached, e.g. 30s, 10m, 1h (default 10m)")
+	cacheEnableCmd.Flags().StringSlice("bypass-cookie", nil, "Cookie name that skips the cache, besides the session and login cookies of common apps (repeatable)")
+}
@@ -0,0 +1,296 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"webstack-cli/internal/cluster"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/remote"
+	"webstack-cli/internal/store"
+	"webstack-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var clusterCmd = &cobra.Command{
+	Use:   "cluster",
+	Short: "Keep secondary web servers in sync for failover",
+	Long: `Mirror this server (the primary) to secondary servers registered with 'webstack remote add':
+domains.json and ssl.json, the Nginx and Apache vhosts, certificates, customized templates and
+the domain folders in /var/www (without logs) are copied with rsync over SSH, then the web
+servers on the secondary are validated and reloaded.
+
+Files changed on a secondary since the last sync are reported as conflicts and nothing is
+copied until they are reviewed with --dry-run and overwritten with --force.
+Usage:
+  sudo webstack remote add 203.0.113.11 --name standby
+  sudo webstack cluster join standby
+  sudo webstack cluster sync --dry-run
+  sudo webstack cluster sync`,
+}
+
+var clusterJoinCmd = &cobra.Command{
+	Use:   "join [server]",
+	Short: "Add a remote server as secondary of this one",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		s, ok := clusterServer(args[0])
+		if !ok {
+			return
+		}
+
+		if _, err := exec.LookPath("rsync"); err != nil {
+			ui.Fail("❌ rsync is not installed on this server (apt install rsync)\n")
+			return
+		}
+		fmt.Printf("🔍 Connecting to %s...\n", s.Target())
+		version, err := remote.Check(s)
+		if err != nil {
+			ui.Fail("❌ Could not run webstack on %s: %v\n", s.Name, err)
+			return
+		}
+		if output, err := remote.Exec(s, "command -v rsync", nil); err != nil {
+			ui.Fail("❌ rsync is not installed on %s (apt install rsync): %s\n", s.Name, strings.TrimSpace(string(output)))
+			return
+		}
+		fmt.Printf("✅ webstack %s and rsync found on %s\n", version, s.Name)
+
+		joined := true
+		err = cluster.Update(func(cfg *cluster.Config) error {
+			if _, exists := cfg.Find(s.Name); exists {
+				joined = false
+				return nil
+			}
+			cfg.Nodes = append(cfg.Nodes, cluster.Node{Server: s.Name, Joined: time.Now()})
+			return nil
+		})
+		if err != nil {
+			ui.Fail("❌ Could not save the cluster: %v\n", err)
+			return
+		}
+		if !joined {
+			fmt.Printf("ℹ️  %s is already a secondary\n", s.Name)
+			return
+		}
+		fmt.Printf("✅ %s joined as secondary\n", s.Name)
+		fmt.Println("💡 Review the first sync with: sudo webstack cluster sync --dry-run")
+	},
+}
+
+var clusterLeaveCmd = &cobra.Command{
+	Use:   "leave [server]",
+	Short: "Stop syncing a secondary (its files are kept)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		found := false
+		err := cluster.Update(func(cfg *cluster.Config) error {
+			for i := range cfg.Nodes {
+				if cfg.Nodes[i].Server == args[0] {
+					cfg.Nodes = append(cfg.Nodes[:i], cfg.Nodes[i+1:]...)
+					found = true
+					return nil
+				}
+			}
+			return fmt.Errorf("%s is not a secondary", args[0])
+		})
+		if !found {
+			ui.Fail("❌ %s is not a secondary\n", args[0])
+			return
+		}
+		if err != nil {
+			ui.Fail("❌ Could not save the cluster: %v\n", err)
+			return
+		}
+		fmt.Printf("✅ %s left the cluster\n", args[0])
+	},
+}
+
+var clusterStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the secondaries and the local changes not synced yet",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := cluster.Load()
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		if len(cfg.Nodes) == 0 {
+			fmt.Println("ℹ️  No secondaries (add one with: sudo webstack cluster join <server>)")
+			return
+		}
+		local, err := cluster.Checksums()
+		if err != nil {
+			ui.Fail("❌ Could not read the local configuration: %v\n", err)
+			return
+		}
+
+		fmt.Printf("%-20s %-20s %s\n", "SECONDARY", "LAST SYNC", "PENDING")
+		for _, node := range cfg.Nodes {
+			lastSync := "never"
+			if !node.LastSync.IsZero() {
+				lastSync = node.LastSync.Format("2006-01-02 15:04")
+			}
+			pending := 0
+			for path, sum := range local {
+				if node.Synced[path] != sum {
+					pending++
+				}
+			}
+			for path := range node.Synced {
+				if _, ok := local[path]; !ok {
+					pending++
+				}
+			}
+			fmt.Printf("%-20s %-20s %d file(s)\n", node.Server, lastSync, pending)
+		}
+	},
+}
+
+var clusterSyncCmd = &cobra.Command{
+	Use:   "sync [server]",
+	Short: "Copy the domains, vhosts, certificates and domain folders to the secondaries",
+	Long: `Copy the configuration and content of this server to every secondary, or to one.
+With --dry-run the differences are listed (state documents and vhosts as diffs) and
+nothing is copied.
+Examples:
+  sudo webstack cluster sync --dry-run
+  sudo webstack cluster sync standby
+  sudo webstack cluster sync --no-content       # configuration only
+  sudo webstack cluster sync standby --force    # overwrite changes made on the secondary`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		force, _ := cmd.Flags().GetBool("force")
+		noContent, _ := cmd.Flags().GetBool("no-content")
+
+		cfg, err := cluster.Load()
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		nodes := cfg.Nodes
+		if len(args) == 1 {
+			node, ok := cfg.Find(args[0])
+			if !ok {
+				ui.Fail("❌ %s is not a secondary (see 'webstack cluster status')\n", args[0])
+				return
+			}
+			nodes = []cluster.Node{*node}
+		}
+		if len(nodes) == 0 {
+			fmt.Println("ℹ️  No secondaries (add one with: sudo webstack cluster join <server>)")
+			return
+		}
+
+		for _, node := range nodes {
+			s, ok := clusterServer(node.Server)
+			if !ok {
+				continue
+			}
+			err := cluster.Sync(node, s, cluster.SyncOptions{Force: force, NoContent: noContent})
+			switch {
+			case err != nil:
+				ui.Fail("❌ %s: %v\n", node.Server, err)
+			case dryrun.Enabled():
+				fmt.Printf("🔎 %s: nothing was copied (dry run)\n", node.Server)
+			default:
+				fmt.Printf("✅ %s in sync\n", node.Server)
+			}
+		}
+	},
+}
+
+// The commands below are run by the primary on the secondaries over SSH
+
+var clusterChecksumsCmd = &cobra.Command{
+	Use:    "checksums",
+	Short:  "Print the checksums of the synced files as JSON",
+	Hidden: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		sums, err := cluster.Checksums()
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		data, _ := json.Marshal(sums)
+		fmt.Println(string(data))
+	},
+}
+
+var clusterDumpCmd = &cobra.Command{
+	Use:    "dump [document]",
+	Short:  "Print a synced state document",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		data, _, err := store.Dump(args[0])
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		os.Stdout.Write(data)
+	},
+}
+
+var clusterReceiveCmd = &cobra.Command{
+	Use:    "receive [document]",
+	Short:  "Store a state document read from stdin",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		if err := cluster.Receive(args[0], data); err != nil {
+			ui.Fail("❌ Could not store %s: %v\n", args[0], err)
+		}
+	},
+}
+
+// clusterServer returns a registered remote server, printing why not
+func clusterServer(name string) (remote.Server, bool) {
+	r, err := remote.Load()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return remote.Server{}, false
+	}
+	servers, err := r.Find([]string{name})
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return remote.Server{}, false
+	}
+	return servers[0], true
+}
+
+func init() {
+	rootCmd.AddCommand(clusterCmd)
+	clusterCmd.AddCommand(clusterJoinCmd)
+	clusterCmd.AddCommand(clusterLeaveCmd)
+	clusterCmd.AddCommand(clusterStatusCmd)
+	clusterCmd.AddCommand(clusterSyncCmd)
+	clusterCmd.AddCommand(clusterChecksumsCmd)
+	clusterCmd.AddCommand(clusterDumpCmd)
+	clusterCmd.AddCommand(clusterReceiveCmd)
+
+	clusterSyncCmd.Flags().Bool("force", false, "Overwrite files changed on the secondary since the last sync")
+	clusterSyncCmd.Flags().Bool("no-content", false, "Only copy the configuration, not the domain folders")
+}
@@ -2,131 +2,350 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
 	"webstack-cli/internal/config"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/firewall"
+	"webstack-cli/internal/monitor"
+	"webstack-cli/internal/selfupdate"
+	"webstack-cli/internal/ssl"
+	"webstack-cli/internal/ui"
 
 	"github.com/spf13/cobra"
 )
 
+// configKey is a setting that can be managed with 'webstack config'
+type configKey struct {
+	name        string
+	description string
+	secret      bool // Masked by get and list
+	// parse validates a value given on the command line and returns what
+	// is stored in config.json
+	parse func(value string) (interface{}, error)
+	// applied is printed after a successful set, e.g. how to apply it
+	applied string
+}
+
+var phpVersions = []string{"5.6", "7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3", "8.4"}
+
+// configKeys are the settings known to webstack
+var configKeys = []configKey{
+	{
+		name:        config.DefaultBackendKey,
+		description: "Backend of new domains when --backend is omitted: nginx, apache, static or caddy",
+		parse:       oneOf("nginx", "apache", "static", "caddy"),
+	},
+	{
+		name:        config.DefaultPHPKey,
+		description: "PHP version of new domains when --php is omitted",
+		parse: func(value string) (interface{}, error) {
+			if _, err := oneOf(phpVersions...)(value); err != nil {
+				return nil, err
+			}
+			if exec.Command("systemctl", "is-enabled", fmt.Sprintf("php%s-fpm", value)).Run() != nil {
+				ui.Warn("⚠️  PHP %s is not installed, use 'webstack install php %s' before adding domains\n", value, value)
+			}
+			return value, nil
+		},
+	},
+	{
+		name:        config.WebRootKey,
+		description: "Folder new domains are created in (default /var/www)",
+		parse:       parseWebRoot,
+		applied:     "New domains are created there; existing domains keep their folder",
+	},
+	{
+		name:        ssl.DefaultEmailKey,
+		description: "Let's Encrypt email when 'ssl enable' gets no --email",
+		parse: func(value string) (interface{}, error) {
+			if !strings.Contains(value, "@") || strings.ContainsAny(value, " \t") {
+				return nil, fmt.Errorf("expected an email address")
+			}
+			return value, nil
+		},
+	},
+	{
+		name:        "ssl_provider",
+		description: "Default SSL provider: letsencrypt or custom",
+		parse:       oneOf("letsencrypt", "custom"),
+	},
+	{
+		name:        "acme_client",
+		description: "ACME client for new certificates: builtin or certbot",
+		parse:       oneOf("builtin", "certbot"),
+	},
+	{
+		name:        "acme_directory",
+		description: "ACME directory: production, staging or an https:// URL",
+		parse: func(value string) (interface{}, error) {
+			if value != "production" && value != "staging" && !strings.HasPrefix(value, "https://") {
+				return nil, fmt.Errorf("valid values: production, staging or an https:// directory URL")
+			}
+			return value, nil
+		},
+	},
+	{
+		name:        firewall.BackendKey,
+		description: "Firewall backend: auto, " + strings.Join(firewall.Backends, ", "),
+		parse: func(value string) (interface{}, error) {
+			if value == "auto" {
+				return value, nil
+			}
+			fw := firewall.New(value)
+			if fw == nil {
+				return nil, fmt.Errorf("valid backends: auto, %s", strings.Join(firewall.Backends, ", "))
+			}
+			if !fw.Available() {
+				ui.Warn("⚠️  %s is not installed on this server\n", value)
+			}
+			return value, nil
+		},
+		applied: "Run 'webstack firewall rebuild' to apply the registered ports with it",
+	},
+	{
+		name:        selfupdate.ChannelKey,
+		description: "Release channel of 'webstack self-update': stable or edge (pre-releases)",
+		parse:       oneOf(selfupdate.Stable, selfupdate.Edge),
+	},
+	{
+		name:        "harden_webroot",
+		description: "Deny .git, .env and backup files in generated vhosts",
+		parse:       parseBool,
+		applied:     "Run 'webstack domain rebuild-configs' to apply it to existing domains",
+	},
+	{
+		name:        domain.HTTP3Key,
+		description: "Serve SSL domains over HTTP/3 (QUIC) with Nginx",
+		parse: func(value string) (interface{}, error) {
+			enabled, err := parseBool(value)
+			if err != nil || !enabled.(bool) {
+				return enabled, err
+			}
+			if supported, reason := domain.NginxHTTP3Support(); !supported {
+				return nil, fmt.Errorf("HTTP/3 is not available: %s", reason)
+			}
+			return enabled, nil
+		},
+		applied: "Run 'webstack domain rebuild-configs' to apply it to existing domains",
+	},
+	{
+		name:        domain.CompressionKey,
+		description: "gzip/Brotli compression (managed with 'webstack server compression')",
+		parse:       parseBool,
+		applied:     "Run 'webstack server compression enable' or 'disable' to apply it",
+	},
+	{
+		name:        "no_emoji",
+		description: "Plain-text output without emoji",
+		parse:       parseBool,
+	},
+	{
+		name:        "no_color",
+		description: "Output without colors",
+		parse:       parseBool,
+	},
+	{
+		name:        monitor.IntervalKey,
+		description: "Seconds between two rounds of monitor checks",
+		parse:       intRange(10, 86400),
+	},
+	{
+		name:        monitor.DiskThresholdKey,
+		description: "Used disk space in percent that raises disk.full",
+		parse:       intRange(1, 100),
+	},
+	{
+		name:        monitor.CertDaysKey,
+		description: "Days before certificate expiry that raise ssl.expiring",
+		parse:       intRange(1, 90),
+	},
+	{
+		name:        "mysql_root_password",
+		description: "MySQL root password used by webstack (set by the installer)",
+		secret:      true,
+		parse:       parseString,
+	},
+	{
+		name:        "mariadb_root_password",
+		description: "MariaDB root password used by webstack (set by the installer)",
+		secret:      true,
+		parse:       parseString,
+	},
+	{
+		name:        "postgresql_root_password",
+		description: "PostgreSQL postgres user password used by webstack (set by the installer)",
+		secret:      true,
+		parse:       parseString,
+	},
+	{
+		name:        "mongodb_admin_password",
+		description: "MongoDB admin user password used by webstack (set by the installer)",
+		secret:      true,
+		parse:       parseString,
+	},
+}
+
+// configAliases are alternative names of known keys
+var configAliases = map[string]string{
+	"default_php":     config.DefaultPHPKey,
+	"default_backend": config.DefaultBackendKey,
+	"default_webroot": config.WebRootKey,
+	"php_version":     config.DefaultPHPKey, // Older name, never used for new domains
+}
+
+// resolveConfigKey returns the key an alias stands for
+func resolveConfigKey(name string) string {
+	if key, ok := configAliases[name]; ok {
+		return key
+	}
+	return name
+}
+
 var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Manage WebStack configuration",
-	Long:  `Manage WebStack configuration settings like default PHP version and SSL provider.`,
+	Long: `Manage WebStack configuration settings like default PHP version and SSL provider.
+Settings are stored in /etc/webstack/config.json; run 'webstack config list'
+to see the known keys and their values.`,
 }
 
 var configSetCmd = &cobra.Command{
-	Use:   "set [key] [value]",
-	Short: "Set a configuration value",
-	Long: `Set a configuration value. Examples:
-  webstack config set php_version 8.3
-  webstack config set ssl_provider letsencrypt`,
+	Use:         "set [key] [value]",
+	Short:       "Set a configuration value",
+	Annotations: map[string]string{secretArgs: "1"}, // e.g. mysql_root_password
+	Long: `Set a configuration value. default_php, default_backend and default_webroot are
+aliases of defaults.php, defaults.backend and defaults.webroot. Examples:
+  webstack config set default_php 8.3
+  webstack config set defaults.backend apache
+  webstack config set default_webroot /srv/www
+  webstack config set ssl_provider letsencrypt
+  webstack config set no_emoji true
+  webstack config set harden_webroot false
+  webstack config set acme_client certbot
+  webstack config set firewall_backend nftables`,
 	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
-		key := args[0]
-		value := args[1]
+		key, value := resolveConfigKey(args[0]), args[1]
 
-		cfg, err := config.Load()
-		if err != nil {
-			fmt.Printf("Error loading config: %v\n", err)
+		known, ok := lookupConfigKey(key)
+		if !ok {
+			ui.Invalid("Unknown configuration key: %s\n", key)
+			fmt.Println("Run 'webstack config list' to see the known keys")
 			return
 		}
 
-		switch key {
-		case "php_version":
-			validVersions := []string{"5.6", "7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3", "8.4"}
-			valid := false
-			for _, v := range validVersions {
-				if v == value {
-					valid = true
-					break
-				}
-			}
-			if !valid {
-				fmt.Printf("Invalid PHP version: %s\n", value)
-				fmt.Printf("Valid versions: %v\n", validVersions)
-				return
-			}
-
-			// Check if PHP version is installed
-			phpFpmService := fmt.Sprintf("php%s-fpm", value)
-			checkCmd := exec.Command("systemctl", "is-enabled", phpFpmService)
-			err := checkCmd.Run()
-			if err != nil {
-				fmt.Printf("PHP %s is not installed\n", value)
-				fmt.Println("Use 'webstack install php [version]' to install it first")
-				return
-			}
-
-			cfg.SetDefault("php_version", value)
-			fmt.Printf("Default PHP version set to %s\n", value)
-
-		case "ssl_provider":
-			if value != "letsencrypt" && value != "custom" {
-				fmt.Printf("Invalid SSL provider: %s\n", value)
-				fmt.Println("Valid providers: letsencrypt, custom")
-				return
-			}
-			cfg.SetDefault("ssl_provider", value)
-			fmt.Printf("Default SSL provider set to %s\n", value)
-
-		default:
-			fmt.Printf("Unknown configuration key: %s\n", key)
+		parsed, err := known.parse(value)
+		if err != nil {
+			ui.Invalid("Invalid value for %s: %s\n", key, value)
+			fmt.Printf("%v\n", err)
 			return
 		}
 
-		if err := cfg.Save(); err != nil {
-			fmt.Printf("Error saving config: %v\n", err)
+		err = config.Update(func(cfg *config.Config) error {
+			cfg.SetDefault(key, parsed)
+			return nil
+		})
+		if err != nil {
+			ui.Fail("Error saving config: %v\n", err)
 			return
 		}
+
+		fmt.Printf("%s set to %s\n", key, displayConfigValue(known, parsed))
+		if known.applied != "" {
+			fmt.Println(known.applied)
+		}
 	},
 }
 
 var configGetCmd = &cobra.Command{
 	Use:   "get [key]",
 	Short: "Get a configuration value",
-	Long: `Get a configuration value. Examples:
-  webstack config get php_version
-  webstack config get ssl_provider`,
+	Long: `Get a configuration value. Secrets are masked unless --reveal is given. Examples:
+  webstack config get default_php
+  webstack config get ssl_provider
+  webstack config get mysql_root_password --reveal`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		key := args[0]
+		reveal, _ := cmd.Flags().GetBool("reveal")
 
 		cfg, err := config.Load()
 		if err != nil {
-			fmt.Printf("Error loading config: %v\n", err)
+			ui.Fail("Error loading config: %v\n", err)
 			return
 		}
 
 		value := cfg.GetDefault(key, nil)
+		if value == nil && resolveConfigKey(key) != key {
+			key = resolveConfigKey(key)
+			value = cfg.GetDefault(key, nil)
+		}
 		if value == nil {
-			fmt.Printf("Configuration key '%s' not found\n", key)
+			ui.Fail("Configuration key '%s' not found\n", key)
 			return
 		}
 
-		fmt.Printf("%s = %v\n", key, value)
+		known, _ := lookupConfigKey(key)
+		if reveal {
+			known.secret = false
+		}
+		fmt.Printf("%s = %s\n", key, displayConfigValue(known, value))
 	},
 }
 
-var configShowCmd = &cobra.Command{
-	Use:   "show",
-	Short: "Show all configuration values",
-	Long:  `Display all current configuration values.`,
+var configListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"show"},
+	Short:   "List all configuration values",
+	Long:    `Display the known configuration keys with their values, and the servers recorded by the installers. Secrets are masked.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg, err := config.Load()
 		if err != nil {
-			fmt.Printf("Error loading config: %v\n", err)
+			ui.Fail("Error loading config: %v\n", err)
 			return
 		}
 
 		fmt.Println("WebStack Configuration")
 		fmt.Println("======================")
 		fmt.Printf("Version: %s\n", cfg.Version)
-		fmt.Println("\nDefaults:")
-		for key, value := range cfg.Defaults {
-			fmt.Printf("  %s = %v\n", key, value)
+
+		fmt.Println("\nSettings:")
+		for _, key := range configKeys {
+			value := "(not set)"
+			if v := cfg.GetDefault(key.name, nil); v != nil {
+				value = displayConfigValue(key, v)
+			}
+			fmt.Printf("  %-22s %-20s %s\n", key.name, value, key.description)
+		}
+
+		// Values written by older versions or by hand
+		var unknown []string
+		for key := range cfg.Defaults {
+			if _, ok := lookupConfigKey(key); !ok {
+				unknown = append(unknown, key)
+			}
 		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			fmt.Println("\nOther values:")
+			for _, key := range unknown {
+				known, _ := lookupConfigKey(key)
+				fmt.Printf("  %-22s %s\n", key, displayConfigValue(known, cfg.Defaults[key]))
+			}
+		}
+
 		fmt.Println("\nServers:")
-		for name, srv := range cfg.Servers {
+		var servers []string
+		for name := range cfg.Servers {
+			servers = append(servers, name)
+		}
+		sort.Strings(servers)
+		for _, name := range servers {
+			srv := cfg.Servers[name]
 			status := "Not installed"
 			if srv.Installed {
 				status = "Installed"
@@ -136,9 +355,113 @@ var configShowCmd = &cobra.Command{
 	},
 }
 
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset [key]",
+	Short: "Remove a configuration value",
+	Long: `Remove a configuration value so the built-in default applies again. Example:
+  webstack config unset acme_directory`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key := args[0]
+
+		found := false
+		err := config.Update(func(cfg *config.Config) error {
+			if cfg.GetDefault(key, nil) == nil {
+				key = resolveConfigKey(key)
+			}
+			if cfg.GetDefault(key, nil) == nil {
+				return nil
+			}
+			found = true
+			cfg.UnsetDefault(key)
+			return nil
+		})
+		if err != nil {
+			ui.Fail("Error saving config: %v\n", err)
+			return
+		}
+		if !found {
+			ui.Fail("Configuration key '%s' not found\n", key)
+			return
+		}
+		fmt.Printf("%s unset\n", key)
+	},
+}
+
+func lookupConfigKey(name string) (configKey, bool) {
+	for _, key := range configKeys {
+		if key.name == name {
+			return key, true
+		}
+	}
+	// Unknown keys holding credentials are masked all the same
+	lower := strings.ToLower(name)
+	secret := strings.Contains(lower, "password") || strings.Contains(lower, "secret") || strings.Contains(lower, "token")
+	return configKey{name: name, secret: secret}, false
+}
+
+// displayConfigValue formats a value for output, masking secrets
+func displayConfigValue(key configKey, value interface{}) string {
+	s := fmt.Sprintf("%v", value)
+	if !key.secret || s == "" {
+		return s
+	}
+	return "********"
+}
+
+// parseWebRoot accepts an absolute folder usable in a vhost
+func parseWebRoot(value string) (interface{}, error) {
+	root, err := domain.CleanFolder(value)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		fmt.Printf("ℹ️  %s does not exist yet; it is created with the first domain\n", root)
+	}
+	return root, nil
+}
+
+func parseBool(value string) (interface{}, error) {
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("valid values: true, false")
+	}
+	return enabled, nil
+}
+
+func parseString(value string) (interface{}, error) {
+	return value, nil
+}
+
+// intRange accepts a whole number between min and max
+func intRange(min, max int) func(string) (interface{}, error) {
+	return func(value string) (interface{}, error) {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < min || n > max {
+			return nil, fmt.Errorf("expected a number from %d to %d", min, max)
+		}
+		return n, nil
+	}
+}
+
+// oneOf accepts one of a fixed set of values
+func oneOf(valid ...string) func(string) (interface{}, error) {
+	return func(value string) (interface{}, error) {
+		for _, v := range valid {
+			if v == value {
+				return value, nil
+			}
+		}
+		return nil, fmt.Errorf("valid values: %s", strings.Join(valid, ", "))
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(configCmd)
 	configCmd.AddCommand(configSetCmd)
 	configCmd.AddCommand(configGetCmd)
-	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configUnsetCmd)
+
+	configGetCmd.Flags().Bool("reveal", false, "Print secrets in clear text")
 }
@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	"webstack-cli/internal/cron"
+	"webstack-cli/internal/prompt"
+	"webstack-cli/internal/ui"
 
 	"github.com/spf13/cobra"
 )
@@ -62,7 +64,7 @@ Command examples:
 	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -72,7 +74,7 @@ Command examples:
 
 		jobID, err := cron.AddJob(schedule, command, description)
 		if err != nil {
-			fmt.Printf("❌ Failed to add cron job: %v\n", err)
+			ui.Fail("❌ Failed to add cron job: %v\n", err)
 			return
 		}
 
@@ -106,14 +108,14 @@ Shows:
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
 		webstackOnly, _ := cmd.Flags().GetBool("webstack-only")
 		jobs, err := cron.ListJobs(webstackOnly)
 		if err != nil {
-			fmt.Printf("❌ Failed to list cron jobs: %v\n", err)
+			ui.Fail("❌ Failed to list cron jobs: %v\n", err)
 			return
 		}
 
@@ -177,7 +179,7 @@ You can update:
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -186,7 +188,7 @@ You can update:
 
 		job, err := cron.GetJob(jobID)
 		if err != nil {
-			fmt.Printf("❌ Cron job not found: %v\n", err)
+			ui.Fail("❌ Cron job not found: %v\n", err)
 			return
 		}
 
@@ -215,7 +217,7 @@ You can update:
 		}
 
 		if err := cron.UpdateJob(jobID, newSchedule, newCommand, newDescription); err != nil {
-			fmt.Printf("❌ Failed to update cron job: %v\n", err)
+			ui.Fail("❌ Failed to update cron job: %v\n", err)
 			return
 		}
 
@@ -236,7 +238,7 @@ The job is immediately removed from the cron schedule.
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -245,27 +247,27 @@ The job is immediately removed from the cron schedule.
 
 		job, err := cron.GetJob(jobID)
 		if err != nil {
-			fmt.Printf("❌ Cron job not found\n")
+			ui.Fail("❌ Cron job not found\n")
 			return
 		}
 
 		force, _ := cmd.Flags().GetBool("force")
 
+		if !prompt.Allow(fmt.Sprintf("delete cron job %d", jobID)) {
+			return
+		}
 		if !force {
-			fmt.Printf("⚠️  This will delete cron job: %d\n", jobID)
+			ui.Warn("⚠️  This will delete cron job: %d\n", jobID)
 			fmt.Printf("   Schedule: %s\n", job.Schedule)
 			fmt.Printf("   Command: %s\n", job.Command)
-			fmt.Print("Type 'yes' to confirm: ")
-			var confirm string
-			fmt.Scanln(&confirm)
-			if confirm != "yes" {
+			if !prompt.ConfirmTyped() {
 				fmt.Println("Delete cancelled")
 				return
 			}
 		}
 
 		if err := cron.DeleteJob(jobID); err != nil {
-			fmt.Printf("❌ Failed to delete cron job: %v\n", err)
+			ui.Fail("❌ Failed to delete cron job: %v\n", err)
 			return
 		}
 
@@ -284,7 +286,7 @@ Useful for testing or running a job outside its normal schedule.
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -293,7 +295,7 @@ Useful for testing or running a job outside its normal schedule.
 
 		job, err := cron.GetJob(jobID)
 		if err != nil {
-			fmt.Printf("❌ Cron job not found\n")
+			ui.Fail("❌ Cron job not found\n")
 			return
 		}
 
@@ -303,7 +305,7 @@ Useful for testing or running a job outside its normal schedule.
 
 		exitCode, err := cron.RunJob(jobID)
 		if err != nil {
-			fmt.Printf("❌ Failed to run cron job: %v\n", err)
+			ui.Fail("❌ Failed to run cron job: %v\n", err)
 			return
 		}
 
@@ -323,7 +325,7 @@ The job will resume its normal schedule.
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -331,7 +333,7 @@ The job will resume its normal schedule.
 		fmt.Sscanf(args[0], "%d", &jobID)
 
 		if err := cron.EnableJob(jobID); err != nil {
-			fmt.Printf("❌ Failed to enable cron job: %v\n", err)
+			ui.Fail("❌ Failed to enable cron job: %v\n", err)
 			return
 		}
 
@@ -350,7 +352,7 @@ The job remains in the list but won't execute. Re-enable with 'enable' command.
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -358,7 +360,7 @@ The job remains in the list but won't execute. Re-enable with 'enable' command.
 		fmt.Sscanf(args[0], "%d", &jobID)
 
 		if err := cron.DisableJob(jobID); err != nil {
-			fmt.Printf("❌ Failed to disable cron job: %v\n", err)
+			ui.Fail("❌ Failed to disable cron job: %v\n", err)
 			return
 		}
 
@@ -381,13 +383,13 @@ Shows:
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
 		status, err := cron.GetStatus()
 		if err != nil {
-			fmt.Printf("❌ Failed to get cron status: %v\n", err)
+			ui.Fail("❌ Failed to get cron status: %v\n", err)
 			return
 		}
 
@@ -420,7 +422,7 @@ Shows when jobs ran and their exit status.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -429,7 +431,7 @@ Shows when jobs ran and their exit status.
 
 		logs, err := cron.GetLogs(lines, pattern)
 		if err != nil {
-			fmt.Printf("❌ Failed to get cron logs: %v\n", err)
+			ui.Fail("❌ Failed to get cron logs: %v\n", err)
 			return
 		}
 
@@ -1,12 +1,24 @@
 package cmd
 
 import (
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"webstack-cli/internal/bytesize"
 	"webstack-cli/internal/config"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/mysql"
+	"webstack-cli/internal/postgres"
+	"webstack-cli/internal/prompt"
+	"webstack-cli/internal/ui"
 
 	"github.com/spf13/cobra"
 )
@@ -30,24 +42,30 @@ var dbUserCmd = &cobra.Command{
 }
 
 var dbUserCreateCmd = &cobra.Command{
-	Use:   "create [database] [username] [password] [host]",
-	Short: "Create a new database user",
+	Use:         "create [database] [username] [password] [host]",
+	Short:       "Create a new database user",
+	Annotations: map[string]string{secretArgs: "2"},
 	Long: `Create a new database user with specified privileges and settings.
 Usage:
   webstack db user create mysql appuser apppass localhost
   webstack db user create mysql appuser apppass 192.168.1.% --privileges SELECT,INSERT --max-connections 10
   webstack db user create mysql appuser apppass 192.168.1.% --database mydb --require-ssl
-  webstack db user create postgresql appuser apppass localhost`,
+  webstack db user create postgresql appuser apppass localhost
+A password of - is read from stdin, keeping it out of the process list and shell history:
+  echo "$PASSWORD" | webstack db user create mysql appuser - localhost`,
 	Args: cobra.ExactArgs(4),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
 		dbType := strings.ToLower(args[0])
 		username := args[1]
 		password := args[2]
+		if password == "-" {
+			password = prompt.Line()
+		}
 		host := args[3]
 
 		privileges, _ := cmd.Flags().GetString("privileges")
@@ -61,7 +79,7 @@ Usage:
 		case "postgresql":
 			createPostgresqlUser(username, password, host)
 		default:
-			fmt.Printf("Unknown database type: %s\n", dbType)
+			ui.Invalid("Unknown database type: %s\n", dbType)
 			fmt.Println("Supported: mysql, mariadb, postgresql")
 		}
 	},
@@ -84,7 +102,7 @@ Usage:
 	Args: cobra.ExactArgs(3),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -98,7 +116,7 @@ Usage:
 		case "postgresql":
 			deletePostgresqlUser(username)
 		default:
-			fmt.Printf("Unknown database type: %s\n", dbType)
+			ui.Invalid("Unknown database type: %s\n", dbType)
 			fmt.Println("Supported: mysql, mariadb, postgresql")
 		}
 	},
@@ -115,8 +133,8 @@ Usage:
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
-			os.Exit(1)
+			ui.Fail("This command requires root privileges (use sudo)\n")
+			ui.Exit(ui.ExitFailure)
 		}
 
 		if len(args) == 0 {
@@ -137,29 +155,34 @@ Usage:
 		case "postgresql":
 			listPostgresqlUsers()
 		default:
-			fmt.Printf("Unknown database type: %s\n", dbType)
+			ui.Invalid("Unknown database type: %s\n", dbType)
 			fmt.Println("Supported: mysql, mariadb, postgresql")
 		}
 	},
 }
 
 var dbUserPasswordCmd = &cobra.Command{
-	Use:   "password [database] [username] [newpassword]",
-	Short: "Change database user password",
+	Use:         "password [database] [username] [newpassword]",
+	Short:       "Change database user password",
+	Annotations: map[string]string{secretArgs: "2"},
 	Long: `Change password for a database user.
 Usage:
   webstack db user password mysql appuser newpass123
-  webstack db user password postgresql appuser newpass123`,
+  webstack db user password postgresql appuser newpass123
+  echo "$PASSWORD" | webstack db user password mysql appuser -   (read from stdin)`,
 	Args: cobra.ExactArgs(3),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
 		dbType := strings.ToLower(args[0])
 		username := args[1]
 		password := args[2]
+		if password == "-" {
+			password = prompt.Line()
+		}
 
 		switch dbType {
 		case "mysql", "mariadb":
@@ -167,7 +190,7 @@ Usage:
 		case "postgresql":
 			changePostgresqlPassword(username, password)
 		default:
-			fmt.Printf("Unknown database type: %s\n", dbType)
+			ui.Invalid("Unknown database type: %s\n", dbType)
 			fmt.Println("Supported: mysql, mariadb, postgresql")
 		}
 	},
@@ -184,7 +207,7 @@ Usage:
 	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -202,7 +225,7 @@ Usage:
 		case "postgresql":
 			fmt.Println("PostgreSQL user updates coming soon")
 		default:
-			fmt.Printf("Unknown database type: %s\n", dbType)
+			ui.Invalid("Unknown database type: %s\n", dbType)
 			fmt.Println("Supported: mysql, mariadb, postgresql")
 		}
 	},
@@ -218,7 +241,7 @@ Usage:
 	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -231,7 +254,7 @@ Usage:
 		case "postgresql":
 			showPostgresqlUserInfo(username)
 		default:
-			fmt.Printf("Unknown database type: %s\n", dbType)
+			ui.Invalid("Unknown database type: %s\n", dbType)
 			fmt.Println("Supported: mysql, mariadb, postgresql")
 		}
 	},
@@ -250,15 +273,20 @@ var dbDatabaseCmd = &cobra.Command{
 var dbDatabaseCreateCmd = &cobra.Command{
 	Use:   "create [database-type] [database-name]",
 	Short: "Create a new database",
-	Long: `Create a new database in MySQL/MariaDB or PostgreSQL.
+	Long: `Create a new database in MySQL/MariaDB or PostgreSQL, or a SQLite database for a domain.
+SQLite databases are files in the domain folder (<home>/databases/<name>.sqlite, outside htdocs),
+owned by www-data; the SQLite extension of the domain's PHP version is installed when missing,
+and backups include them.
 Usage:
   webstack db database create mysql myapp
   webstack db database create mysql myapp --charset utf8mb4 --collation utf8mb4_unicode_ci
-  webstack db database create postgresql myapp --owner postgres`,
+  webstack db database create postgresql myapp --owner postgres
+  webstack db database create sqlite example.com
+  webstack db database create sqlite example.com --name cache`,
 	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -267,15 +295,18 @@ Usage:
 		charset, _ := cmd.Flags().GetString("charset")
 		collation, _ := cmd.Flags().GetString("collation")
 		owner, _ := cmd.Flags().GetString("owner")
+		name, _ := cmd.Flags().GetString("name")
 
 		switch dbType {
 		case "mysql", "mariadb":
 			createMySQLDatabase(dbName, charset, collation)
 		case "postgresql":
 			createPostgresqlDatabase(dbName, owner)
+		case "sqlite":
+			domain.CreateSQLite(dbName, name)
 		default:
-			fmt.Printf("Unknown database type: %s\n", dbType)
-			fmt.Println("Supported: mysql, mariadb, postgresql")
+			ui.Invalid("Unknown database type: %s\n", dbType)
+			fmt.Println("Supported: mysql, mariadb, postgresql, sqlite")
 		}
 	},
 }
@@ -283,44 +314,50 @@ Usage:
 var dbDatabaseDeleteCmd = &cobra.Command{
 	Use:   "delete [database-type] [database-name]",
 	Short: "Delete a database",
-	Long: `Delete a database from MySQL/MariaDB or PostgreSQL (requires confirmation).
+	Long: `Delete a database from MySQL/MariaDB or PostgreSQL, or a SQLite database of a domain (requires confirmation).
 Usage:
   webstack db database delete mysql myapp
-  webstack db database delete postgresql myapp --force`,
+  webstack db database delete postgresql myapp --force
+  webstack db database delete sqlite example.com --name cache`,
 	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
 		dbType := strings.ToLower(args[0])
 		dbName := args[1]
 		force, _ := cmd.Flags().GetBool("force")
+		name, _ := cmd.Flags().GetString("name")
 
 		switch dbType {
 		case "mysql", "mariadb":
 			deleteMySQLDatabase(dbName, force)
 		case "postgresql":
 			deletePostgresqlDatabase(dbName, force)
+		case "sqlite":
+			domain.DeleteSQLite(dbName, name, force)
 		default:
-			fmt.Printf("Unknown database type: %s\n", dbType)
-			fmt.Println("Supported: mysql, mariadb, postgresql")
+			ui.Invalid("Unknown database type: %s\n", dbType)
+			fmt.Println("Supported: mysql, mariadb, postgresql, sqlite")
 		}
 	},
 }
 
 var dbDatabaseListCmd = &cobra.Command{
-	Use:   "list [database-type]",
+	Use:   "list [database-type] [domain]",
 	Short: "List all databases",
 	Long: `List all databases with size and other information.
+SQLite databases are listed for all domains, or for the domain given.
 Usage:
   webstack db database list mysql
-  webstack db database list postgresql`,
-	Args: cobra.ExactArgs(1),
+  webstack db database list postgresql
+  webstack db database list sqlite example.com`,
+	Args: cobra.RangeArgs(1, 2),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -331,9 +368,15 @@ Usage:
 			listMySQLDatabases()
 		case "postgresql":
 			listPostgresqlDatabases()
+		case "sqlite":
+			domainName := ""
+			if len(args) > 1 {
+				domainName = args[1]
+			}
+			domain.ListSQLite(domainName)
 		default:
-			fmt.Printf("Unknown database type: %s\n", dbType)
-			fmt.Println("Supported: mysql, mariadb, postgresql")
+			ui.Invalid("Unknown database type: %s\n", dbType)
+			fmt.Println("Supported: mysql, mariadb, postgresql, sqlite")
 		}
 	},
 }
@@ -344,24 +387,111 @@ var dbDatabaseInfoCmd = &cobra.Command{
 	Long: `Display detailed information about a database including size, tables, and charset.
 Usage:
   webstack db database info mysql myapp
-  webstack db database info postgresql myapp`,
+  webstack db database info postgresql myapp
+  webstack db database info sqlite example.com --name cache`,
 	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
 		dbType := strings.ToLower(args[0])
 		dbName := args[1]
+		name, _ := cmd.Flags().GetString("name")
 
 		switch dbType {
 		case "mysql", "mariadb":
 			showMySQLDatabaseInfo(dbName)
 		case "postgresql":
 			showPostgresqlDatabaseInfo(dbName)
+		case "sqlite":
+			domain.SQLiteInfo(dbName, name)
+		default:
+			ui.Invalid("Unknown database type: %s\n", dbType)
+			fmt.Println("Supported: mysql, mariadb, postgresql, sqlite")
+		}
+	},
+}
+
+var dbShellCmd = &cobra.Command{
+	Use:   "shell [database-type] [database-name]",
+	Short: "Open an interactive database client as the administrator",
+	Long: `Open mysql or psql logged in as root (postgres), with the password the installer
+saved in the config (mysql_root_password, mariadb_root_password, postgresql_root_password).
+Without a saved password the client connects through the local socket as root (postgres).
+Usage:
+  webstack db shell mysql
+  webstack db shell mysql wordpress
+  webstack db shell postgresql crm
+  webstack db shell postgresql crm --cluster 17/main`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("This command requires root privileges (use sudo)\n")
+			return
+		}
+
+		dbType := strings.ToLower(args[0])
+		dbName := ""
+		if len(args) > 1 {
+			dbName = args[1]
+		}
+		cluster, _ := cmd.Flags().GetString("cluster")
+
+		switch dbType {
+		case "mysql", "mariadb":
+			openMySQLShell(dbType, dbName)
+		case "postgresql":
+			openPostgresqlShell(cluster, dbName)
+		default:
+			ui.Invalid("Unknown database type: %s\n", dbType)
+			fmt.Println("Supported: mysql, mariadb, postgresql")
+		}
+	},
+}
+
+var dbExecCmd = &cobra.Command{
+	Use:   "exec [database-type] [database-name]",
+	Short: "Run a SQL query or import a SQL script",
+	Long: `Run a query (--query) or a SQL script (--file) as root (postgres), with the password
+the installer saved in the config. Scripts ending in .gz are decompressed on the fly and
+the import progress is shown. --transaction runs everything in one transaction that is
+rolled back on the first error (MySQL/MariaDB commit implicitly on CREATE, ALTER and DROP).
+Usage:
+  webstack db exec mysql mydb --query "SELECT COUNT(*) FROM users"
+  webstack db exec mysql mydb --file schema.sql
+  webstack db exec mariadb mydb --file dump.sql.gz --transaction
+  webstack db exec postgresql crm --file crm.sql --cluster 17/main
+  webstack db exec mysql mydb --file - < schema.sql
+  webstack db exec mysql mydb --file -          (type the statements, end with Ctrl-D)`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("This command requires root privileges (use sudo)\n")
+			return
+		}
+
+		dbType := strings.ToLower(args[0])
+		dbName := ""
+		if len(args) > 1 {
+			dbName = args[1]
+		}
+		file, _ := cmd.Flags().GetString("file")
+		query, _ := cmd.Flags().GetString("query")
+		transaction, _ := cmd.Flags().GetBool("transaction")
+		cluster, _ := cmd.Flags().GetString("cluster")
+
+		if (file == "") == (query == "") {
+			ui.Fail("❌ Give either --file or --query\n")
+			return
+		}
+
+		switch dbType {
+		case "mysql", "mariadb", "postgresql":
+			execSQL(dbType, dbName, file, query, transaction, cluster)
 		default:
-			fmt.Printf("Unknown database type: %s\n", dbType)
+			ui.Invalid("Unknown database type: %s\n", dbType)
 			fmt.Println("Supported: mysql, mariadb, postgresql")
 		}
 	},
@@ -371,10 +501,13 @@ func init_dbDatabaseCreateCmd() {
 	dbDatabaseCreateCmd.Flags().StringP("charset", "c", "utf8mb4", "Character set for MySQL/MariaDB (default: utf8mb4)")
 	dbDatabaseCreateCmd.Flags().StringP("collation", "l", "utf8mb4_unicode_ci", "Collation for MySQL/MariaDB (default: utf8mb4_unicode_ci)")
 	dbDatabaseCreateCmd.Flags().StringP("owner", "o", "postgres", "Owner for PostgreSQL (default: postgres)")
+	dbDatabaseCreateCmd.Flags().String("name", domain.DefaultSQLiteName, "Name of the SQLite database of the domain")
 }
 
 func init_dbDatabaseDeleteCmd() {
 	dbDatabaseDeleteCmd.Flags().BoolP("force", "f", false, "Skip confirmation prompt")
+	dbDatabaseDeleteCmd.Flags().String("name", domain.DefaultSQLiteName, "Name of the SQLite database of the domain")
+	dbDatabaseInfoCmd.Flags().String("name", domain.DefaultSQLiteName, "Name of the SQLite database of the domain")
 }
 
 func init_dbUserUpdateCmd() {
@@ -388,6 +521,12 @@ func init_dbUserUpdateCmd() {
 func createMySQLUserWithOptions(username, password, host, privileges, database string, maxConnections int, requireSSL bool) {
 	fmt.Printf("👤 Creating MySQL user '%s'@'%s'...\n", username, host)
 
+	privStr, err := mysql.Privileges(privileges)
+	if err != nil {
+		ui.Invalid("Invalid privileges: %v\n", err)
+		return
+	}
+
 	// Load config to get admin password from defaults
 	cfg, err := config.Load()
 	var adminPass string
@@ -412,11 +551,10 @@ func createMySQLUserWithOptions(username, password, host, privileges, database s
 	}
 
 	// Create user
-	createCmd := fmt.Sprintf("CREATE USER IF NOT EXISTS '%s'@'%s' IDENTIFIED BY '%s';", username, host, password)
+	createCmd := fmt.Sprintf("CREATE USER IF NOT EXISTS %s IDENTIFIED BY %s;", mysql.Account(username, host), mysql.Quote(password))
 
-	mysqlCmd := exec.Command("mysql", "-u", "root", "-p"+adminPass, "-e", createCmd)
-	if err := mysqlCmd.Run(); err != nil {
-		fmt.Printf("Error creating user: %v\n", err)
+	if _, err := mysql.Exec("root", adminPass, createCmd); err != nil {
+		ui.Fail("Error creating user: %v\n", err)
 		fmt.Println("   Try manually: mysql -u root -p")
 		return
 	}
@@ -426,26 +564,20 @@ func createMySQLUserWithOptions(username, password, host, privileges, database s
 	if database == "*" {
 		dbSpec = "*.*"
 	} else {
-		dbSpec = database + ".*"
-	}
-
-	privStr := privileges
-	if privileges == "ALL" {
-		privStr = "ALL PRIVILEGES"
+		dbSpec = mysql.QuoteIdent(database) + ".*"
 	}
 
 	// Grant privileges
-	grantCmd := fmt.Sprintf("GRANT %s ON %s TO '%s'@'%s' WITH GRANT OPTION;", privStr, dbSpec, username, host)
+	grantCmd := fmt.Sprintf("GRANT %s ON %s TO %s WITH GRANT OPTION;", privStr, dbSpec, mysql.Account(username, host))
 
-	mysqlCmd = exec.Command("mysql", "-u", "root", "-p"+adminPass, "-e", grantCmd)
-	if err := mysqlCmd.Run(); err != nil {
-		fmt.Printf("Error granting privileges: %v\n", err)
+	if _, err := mysql.Exec("root", adminPass, grantCmd); err != nil {
+		ui.Fail("Error granting privileges: %v\n", err)
 		return
 	}
 
 	// Set resource limits if specified
 	if maxConnections > 0 || requireSSL {
-		alterCmd := fmt.Sprintf("ALTER USER '%s'@'%s'", username, host)
+		alterCmd := fmt.Sprintf("ALTER USER %s", mysql.Account(username, host))
 
 		if requireSSL {
 			alterCmd += " REQUIRE SSL"
@@ -460,16 +592,13 @@ func createMySQLUserWithOptions(username, password, host, privileges, database s
 
 		alterCmd += ";"
 
-		mysqlCmd = exec.Command("mysql", "-u", "root", "-p"+adminPass, "-e", alterCmd)
-		if err := mysqlCmd.Run(); err != nil {
-			fmt.Printf("Warning: Could not set user limits: %v\n", err)
+		if _, err := mysql.Exec("root", adminPass, alterCmd); err != nil {
+			ui.Warn("Warning: Could not set user limits: %v\n", err)
 		}
 	}
 
 	// Flush privileges
-	flushCmd := "FLUSH PRIVILEGES;"
-	mysqlCmd = exec.Command("mysql", "-u", "root", "-p"+adminPass, "-e", flushCmd)
-	mysqlCmd.Run()
+	mysql.Exec("root", adminPass, "FLUSH PRIVILEGES;")
 
 	fmt.Printf("User '%s'@'%s' created successfully\n", username, host)
 	if privileges != "ALL" {
@@ -506,11 +635,10 @@ func deleteMySQLUser(username, host string) {
 		fmt.Scanln(&adminPass)
 	}
 
-	deleteCmd := fmt.Sprintf("DROP USER IF EXISTS '%s'@'%s'; FLUSH PRIVILEGES;", username, host)
+	deleteCmd := fmt.Sprintf("DROP USER IF EXISTS %s; FLUSH PRIVILEGES;", mysql.Account(username, host))
 
-	mysqlCmd := exec.Command("mysql", "-u", "root", "-p"+adminPass, "-e", deleteCmd)
-	if err := mysqlCmd.Run(); err != nil {
-		fmt.Printf("Error deleting user: %v\n", err)
+	if _, err := mysql.Exec("root", adminPass, deleteCmd); err != nil {
+		ui.Fail("Error deleting user: %v\n", err)
 		return
 	}
 
@@ -544,10 +672,9 @@ func listMySQLUsers() {
 }
 
 func executeMySQLQuery(query, user, password string) {
-	mysqlCmd := exec.Command("mysql", "-u", user, "-p"+password, "-e", query)
-	output, err := mysqlCmd.CombinedOutput()
+	output, err := mysql.Table(user, password, query)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		ui.Fail("Error: %v\n", err)
 		return
 	}
 	fmt.Print(string(output))
@@ -576,25 +703,24 @@ func changeMySQLPassword(username, password string) {
 	}
 
 	// Get current host for the user
-	getHostCmd := fmt.Sprintf("SELECT Host FROM mysql.user WHERE User='%s' LIMIT 1;", username)
-	output, err := exec.Command("mysql", "-u", "root", "-p"+adminPass, "-sNe", getHostCmd).Output()
+	getHostCmd := fmt.Sprintf("SELECT Host FROM mysql.user WHERE User=%s LIMIT 1;", mysql.Quote(username))
+	output, err := mysql.Query("root", adminPass, getHostCmd)
 	if err != nil {
-		fmt.Printf("User not found: %s\n", username)
+		ui.Fail("User not found: %s\n", username)
 		return
 	}
 
 	host := strings.TrimSpace(string(output))
 	if host == "" {
-		fmt.Printf("User '%s' not found\n", username)
+		ui.Fail("User '%s' not found\n", username)
 		return
 	}
 
 	// Update password
-	updateCmd := fmt.Sprintf("ALTER USER '%s'@'%s' IDENTIFIED BY '%s'; FLUSH PRIVILEGES;", username, host, password)
+	updateCmd := fmt.Sprintf("ALTER USER %s IDENTIFIED BY %s; FLUSH PRIVILEGES;", mysql.Account(username, host), mysql.Quote(password))
 
-	mysqlCmd := exec.Command("mysql", "-u", "root", "-p"+adminPass, "-e", updateCmd)
-	if err := mysqlCmd.Run(); err != nil {
-		fmt.Printf("Error changing password: %v\n", err)
+	if _, err := mysql.Exec("root", adminPass, updateCmd); err != nil {
+		ui.Fail("Error changing password: %v\n", err)
 		return
 	}
 
@@ -605,18 +731,18 @@ func changeMySQLPassword(username, password string) {
 func createPostgresqlUser(username, password, host string) {
 	fmt.Printf("Creating PostgreSQL user '%s'...\n", username)
 
-	createCmd := fmt.Sprintf("CREATE USER %s WITH PASSWORD '%s' CREATEDB;", username, password)
+	createCmd := fmt.Sprintf("CREATE USER %s WITH PASSWORD %s CREATEDB;", postgres.QuoteIdent(username), postgres.Quote(password))
 
-	psqlCmd := exec.Command("sudo", "-u", "postgres", "psql", "-c", createCmd)
-	if err := psqlCmd.Run(); err != nil {
-		fmt.Printf("Error creating user: %v\n", err)
+	psqlCmd := postgresqlSQL(createCmd)
+	if err := dryrun.Run(psqlCmd); err != nil {
+		ui.Fail("Error creating user: %v\n", err)
 		return
 	}
 
 	// Grant privileges
-	grantCmd := fmt.Sprintf("GRANT ALL PRIVILEGES ON ALL TABLES IN SCHEMA public TO %s;", username)
-	psqlCmd = exec.Command("sudo", "-u", "postgres", "psql", "-c", grantCmd)
-	psqlCmd.Run() // Ignore error if schema doesn't exist yet
+	grantCmd := fmt.Sprintf("GRANT ALL PRIVILEGES ON ALL TABLES IN SCHEMA public TO %s;", postgres.QuoteIdent(username))
+	psqlCmd = postgresqlSQL(grantCmd)
+	dryrun.Run(psqlCmd) // Ignore error if schema doesn't exist yet
 
 	fmt.Printf("PostgreSQL user '%s' created successfully\n", username)
 	fmt.Printf("   Connect with: psql -U %s -h <server> -d postgres\n", username)
@@ -626,11 +752,11 @@ func deletePostgresqlUser(username string) {
 	fmt.Printf("Deleting PostgreSQL user '%s'...\n", username)
 
 	// Drop owned objects first
-	dropCmd := fmt.Sprintf("DROP OWNED BY %s CASCADE; DROP USER IF EXISTS %s;", username, username)
+	dropCmd := fmt.Sprintf("DROP OWNED BY %[1]s CASCADE; DROP USER IF EXISTS %[1]s;", postgres.QuoteIdent(username))
 
-	psqlCmd := exec.Command("sudo", "-u", "postgres", "psql", "-c", dropCmd)
-	if err := psqlCmd.Run(); err != nil {
-		fmt.Printf("Error deleting user: %v\n", err)
+	psqlCmd := postgresqlSQL(dropCmd)
+	if err := dryrun.Run(psqlCmd); err != nil {
+		ui.Fail("Error deleting user: %v\n", err)
 		return
 	}
 
@@ -645,7 +771,7 @@ func listPostgresqlUsers() {
 
 	psqlCmd := exec.Command("sudo", "-u", "postgres", "psql", "-c", listCmd)
 	if err := psqlCmd.Run(); err != nil {
-		fmt.Printf("Error listing users: %v\n", err)
+		ui.Fail("Error listing users: %v\n", err)
 		return
 	}
 }
@@ -653,11 +779,11 @@ func listPostgresqlUsers() {
 func changePostgresqlPassword(username, password string) {
 	fmt.Printf("Changing password for user '%s'...\n", username)
 
-	updateCmd := fmt.Sprintf("ALTER USER %s WITH PASSWORD '%s';", username, password)
+	updateCmd := fmt.Sprintf("ALTER USER %s WITH PASSWORD %s;", postgres.QuoteIdent(username), postgres.Quote(password))
 
-	psqlCmd := exec.Command("sudo", "-u", "postgres", "psql", "-c", updateCmd)
-	if err := psqlCmd.Run(); err != nil {
-		fmt.Printf("Error changing password: %v\n", err)
+	psqlCmd := postgresqlSQL(updateCmd)
+	if err := dryrun.Run(psqlCmd); err != nil {
+		ui.Fail("Error changing password: %v\n", err)
 		return
 	}
 
@@ -667,6 +793,15 @@ func changePostgresqlPassword(username, password string) {
 func updateMySQLUser(username string, privileges string, maxConnections int, requireSSL, noSSL bool) {
 	fmt.Printf("Updating MySQL user '%s'...\n", username)
 
+	var privStr string
+	if privileges != "" {
+		var err error
+		if privStr, err = mysql.Privileges(privileges); err != nil {
+			ui.Invalid("Invalid privileges: %v\n", err)
+			return
+		}
+	}
+
 	// Load config to get admin password from defaults
 	cfg, err := config.Load()
 	var adminPass string
@@ -685,21 +820,21 @@ func updateMySQLUser(username string, privileges string, maxConnections int, req
 	}
 
 	if requireSSL && noSSL {
-		fmt.Println("Cannot use both --require-ssl and --no-ssl")
+		ui.Invalid("Cannot use both --require-ssl and --no-ssl\n")
 		return
 	}
 
 	// Get user hosts
-	hostCmd := fmt.Sprintf("SELECT DISTINCT Host FROM mysql.user WHERE User='%s';", username)
-	output, err := exec.Command("mysql", "-u", "root", "-p"+adminPass, "-sNe", hostCmd).Output()
+	hostCmd := fmt.Sprintf("SELECT DISTINCT Host FROM mysql.user WHERE User=%s;", mysql.Quote(username))
+	output, err := mysql.Query("root", adminPass, hostCmd)
 	if err != nil {
-		fmt.Printf("User not found: %s\n", username)
+		ui.Fail("User not found: %s\n", username)
 		return
 	}
 
 	hosts := strings.Split(strings.TrimSpace(string(output)), "\n")
 	if len(hosts) == 0 || hosts[0] == "" {
-		fmt.Printf("User '%s' not found\n", username)
+		ui.Fail("User '%s' not found\n", username)
 		return
 	}
 
@@ -713,19 +848,12 @@ func updateMySQLUser(username string, privileges string, maxConnections int, req
 				continue
 			}
 
-			privStr := privileges
-			if privileges == "ALL" {
-				privStr = "ALL PRIVILEGES"
-			}
-
-			revokeCmd := fmt.Sprintf("REVOKE ALL PRIVILEGES ON *.* FROM '%s'@'%s';", username, host)
-			mysqlCmd := exec.Command("mysql", "-u", "root", "-p"+adminPass, "-e", revokeCmd)
-			mysqlCmd.Run() // Ignore errors
+			revokeCmd := fmt.Sprintf("REVOKE ALL PRIVILEGES ON *.* FROM %s;", mysql.Account(username, host))
+			mysql.Exec("root", adminPass, revokeCmd) // Ignore errors
 
-			grantCmd := fmt.Sprintf("GRANT %s ON *.* TO '%s'@'%s' WITH GRANT OPTION;", privStr, username, host)
-			mysqlCmd = exec.Command("mysql", "-u", "root", "-p"+adminPass, "-e", grantCmd)
-			if err := mysqlCmd.Run(); err != nil {
-				fmt.Printf("Could not update privileges for %s@%s: %v\n", username, host, err)
+			grantCmd := fmt.Sprintf("GRANT %s ON *.* TO %s WITH GRANT OPTION;", privStr, mysql.Account(username, host))
+			if _, err := mysql.Exec("root", adminPass, grantCmd); err != nil {
+				ui.Fail("Could not update privileges for %s@%s: %v\n", username, host, err)
 				continue
 			}
 
@@ -742,7 +870,7 @@ func updateMySQLUser(username string, privileges string, maxConnections int, req
 				continue
 			}
 
-			alterCmd := fmt.Sprintf("ALTER USER '%s'@'%s'", username, host)
+			alterCmd := fmt.Sprintf("ALTER USER %s", mysql.Account(username, host))
 
 			if requireSSL {
 				alterCmd += " REQUIRE SSL"
@@ -763,9 +891,8 @@ func updateMySQLUser(username string, privileges string, maxConnections int, req
 
 			alterCmd += ";"
 
-			mysqlCmd := exec.Command("mysql", "-u", "root", "-p"+adminPass, "-e", alterCmd)
-			if err := mysqlCmd.Run(); err != nil {
-				fmt.Printf("Warning: Could not update settings for %s@%s: %v\n", username, host, err)
+			if _, err := mysql.Exec("root", adminPass, alterCmd); err != nil {
+				ui.Warn("Warning: Could not update settings for %s@%s: %v\n", username, host, err)
 				continue
 			}
 
@@ -787,9 +914,7 @@ func updateMySQLUser(username string, privileges string, maxConnections int, req
 	}
 
 	// Flush privileges
-	flushCmd := "FLUSH PRIVILEGES;"
-	mysqlCmd := exec.Command("mysql", "-u", "root", "-p"+adminPass, "-e", flushCmd)
-	mysqlCmd.Run()
+	mysql.Exec("root", adminPass, "FLUSH PRIVILEGES;")
 
 	if !updated {
 		fmt.Println("No changes specified. Use --privileges, --max-connections, --require-ssl, or --no-ssl")
@@ -820,10 +945,10 @@ func showMySQLUserInfo(username string) {
 	}
 
 	// Get user hosts and info
-	hostsCmd := fmt.Sprintf("SELECT Host FROM mysql.user WHERE User='%s';", username)
-	output, err := exec.Command("mysql", "-u", "root", "-p"+adminPass, "-sNe", hostsCmd).Output()
+	hostsCmd := fmt.Sprintf("SELECT Host FROM mysql.user WHERE User=%s;", mysql.Quote(username))
+	output, err := mysql.Query("root", adminPass, hostsCmd)
 	if err != nil {
-		fmt.Printf("User not found: %s\n", username)
+		ui.Fail("User not found: %s\n", username)
 		return
 	}
 
@@ -839,8 +964,8 @@ func showMySQLUserInfo(username string) {
 		fmt.Printf("\nHost: %s\n", host)
 
 		// Get grants
-		grantsCmd := fmt.Sprintf("SHOW GRANTS FOR '%s'@'%s';", username, host)
-		grantsOutput, _ := exec.Command("mysql", "-u", "root", "-p"+adminPass, "-sNe", grantsCmd).Output()
+		grantsCmd := fmt.Sprintf("SHOW GRANTS FOR %s;", mysql.Account(username, host))
+		grantsOutput, _ := mysql.Query("root", adminPass, grantsCmd)
 		if grantsOutput != nil {
 			for _, line := range strings.Split(string(grantsOutput), "\n") {
 				line = strings.TrimSpace(line)
@@ -875,7 +1000,7 @@ func showPostgresqlUserInfo(username string) {
 	}
 
 	if !found {
-		fmt.Printf("User '%s' not found\n", username)
+		ui.Fail("User '%s' not found\n", username)
 	}
 }
 
@@ -899,11 +1024,10 @@ func createMySQLDatabase(dbName, charset, collation string) {
 		fmt.Scanln(&adminPass)
 	}
 
-	createCmd := fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s` CHARACTER SET %s COLLATE %s;", dbName, charset, collation)
+	createCmd := fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s CHARACTER SET %s COLLATE %s;", mysql.QuoteIdent(dbName), mysql.QuoteIdent(charset), mysql.QuoteIdent(collation))
 
-	mysqlCmd := exec.Command("mysql", "-u", "root", "-p"+adminPass, "-e", createCmd)
-	if err := mysqlCmd.Run(); err != nil {
-		fmt.Printf("Error creating database: %v\n", err)
+	if _, err := mysql.Exec("root", adminPass, createCmd); err != nil {
+		ui.Fail("Error creating database: %v\n", err)
 		return
 	}
 
@@ -912,12 +1036,12 @@ func createMySQLDatabase(dbName, charset, collation string) {
 }
 
 func deleteMySQLDatabase(dbName string, force bool) {
+	if !prompt.Allow(fmt.Sprintf("delete database %s", dbName)) {
+		return
+	}
 	if !force {
 		fmt.Printf("Are you sure you want to delete database '%s'? This cannot be undone!\n", dbName)
-		fmt.Print("Type 'yes' to confirm: ")
-		var confirm string
-		fmt.Scanln(&confirm)
-		if confirm != "yes" {
+		if !prompt.ConfirmTyped() {
 			fmt.Println("Deletion cancelled")
 			return
 		}
@@ -941,11 +1065,10 @@ func deleteMySQLDatabase(dbName string, force bool) {
 		fmt.Scanln(&adminPass)
 	}
 
-	deleteCmd := fmt.Sprintf("DROP DATABASE IF EXISTS `%s`;", dbName)
+	deleteCmd := fmt.Sprintf("DROP DATABASE IF EXISTS %s;", mysql.QuoteIdent(dbName))
 
-	mysqlCmd := exec.Command("mysql", "-u", "root", "-p"+adminPass, "-e", deleteCmd)
-	if err := mysqlCmd.Run(); err != nil {
-		fmt.Printf("Error deleting database: %v\n", err)
+	if _, err := mysql.Exec("root", adminPass, deleteCmd); err != nil {
+		ui.Fail("Error deleting database: %v\n", err)
 		return
 	}
 
@@ -982,10 +1105,9 @@ func listMySQLDatabases() {
 	GROUP BY SCHEMA_NAME, DEFAULT_CHARACTER_SET_NAME, DEFAULT_COLLATION_NAME
 	ORDER BY SCHEMA_NAME;`
 
-	mysqlCmd := exec.Command("mysql", "-u", "root", "-p"+adminPass, "-e", query)
-	output, err := mysqlCmd.CombinedOutput()
+	output, err := mysql.Table("root", adminPass, query)
 	if err != nil {
-		fmt.Printf("Error listing databases: %v\n", err)
+		ui.Fail("Error listing databases: %v\n", err)
 		return
 	}
 	fmt.Print(string(output))
@@ -1012,25 +1134,23 @@ func showMySQLDatabaseInfo(dbName string) {
 	}
 
 	// Database exists?
-	checkCmd := fmt.Sprintf("SELECT SCHEMA_NAME FROM INFORMATION_SCHEMA.SCHEMATA WHERE SCHEMA_NAME = '%s';", dbName)
-	mysqlCmd := exec.Command("mysql", "-u", "root", "-p"+adminPass, "-sNe", checkCmd)
-	if err := mysqlCmd.Run(); err != nil {
-		fmt.Printf("Database '%s' not found\n", dbName)
+	checkCmd := fmt.Sprintf("SELECT SCHEMA_NAME FROM INFORMATION_SCHEMA.SCHEMATA WHERE SCHEMA_NAME = %s;", mysql.Quote(dbName))
+	if _, err := mysql.Query("root", adminPass, checkCmd); err != nil {
+		ui.Fail("Database '%s' not found\n", dbName)
 		return
 	}
 
 	// Get database info
 	infoCmd := fmt.Sprintf(`
 	SELECT 
-		'Database:' as 'Info', '%s' as 'Value' UNION
-	SELECT 'Charset:', DEFAULT_CHARACTER_SET_NAME FROM INFORMATION_SCHEMA.SCHEMATA WHERE SCHEMA_NAME='%s' UNION
-	SELECT 'Collation:', DEFAULT_COLLATION_NAME FROM INFORMATION_SCHEMA.SCHEMATA WHERE SCHEMA_NAME='%s' UNION
-	SELECT 'Tables:', CAST(COUNT(*) as CHAR) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA='%s' UNION
-	SELECT 'Size (MB):', ROUND(SUM(DATA_LENGTH + INDEX_LENGTH) / 1024 / 1024, 2) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA='%s';
-	`, dbName, dbName, dbName, dbName, dbName)
-
-	mysqlCmd = exec.Command("mysql", "-u", "root", "-p"+adminPass, "-e", infoCmd)
-	output, _ := mysqlCmd.CombinedOutput()
+		'Database:' as 'Info', %[1]s as 'Value' UNION
+	SELECT 'Charset:', DEFAULT_CHARACTER_SET_NAME FROM INFORMATION_SCHEMA.SCHEMATA WHERE SCHEMA_NAME=%[1]s UNION
+	SELECT 'Collation:', DEFAULT_COLLATION_NAME FROM INFORMATION_SCHEMA.SCHEMATA WHERE SCHEMA_NAME=%[1]s UNION
+	SELECT 'Tables:', CAST(COUNT(*) as CHAR) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA=%[1]s UNION
+	SELECT 'Size (MB):', ROUND(SUM(DATA_LENGTH + INDEX_LENGTH) / 1024 / 1024, 2) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA=%[1]s;
+	`, mysql.Quote(dbName))
+
+	output, _ := mysql.Table("root", adminPass, infoCmd)
 	fmt.Print(string(output))
 }
 
@@ -1038,11 +1158,11 @@ func showMySQLDatabaseInfo(dbName string) {
 func createPostgresqlDatabase(dbName, owner string) {
 	fmt.Printf("Creating PostgreSQL database '%s'...\n", dbName)
 
-	createCmd := fmt.Sprintf("CREATE DATABASE \"%s\" OWNER %s;", dbName, owner)
+	createCmd := fmt.Sprintf("CREATE DATABASE %s OWNER %s;", postgres.QuoteIdent(dbName), postgres.QuoteIdent(owner))
 
-	psqlCmd := exec.Command("sudo", "-u", "postgres", "psql", "-c", createCmd)
-	if err := psqlCmd.Run(); err != nil {
-		fmt.Printf("Error creating database: %v\n", err)
+	psqlCmd := postgresqlSQL(createCmd)
+	if err := dryrun.Run(psqlCmd); err != nil {
+		ui.Fail("Error creating database: %v\n", err)
 		return
 	}
 
@@ -1051,12 +1171,12 @@ func createPostgresqlDatabase(dbName, owner string) {
 }
 
 func deletePostgresqlDatabase(dbName string, force bool) {
+	if !prompt.Allow(fmt.Sprintf("delete database %s", dbName)) {
+		return
+	}
 	if !force {
 		fmt.Printf("Are you sure you want to delete database '%s'? This cannot be undone!\n", dbName)
-		fmt.Print("Type 'yes' to confirm: ")
-		var confirm string
-		fmt.Scanln(&confirm)
-		if confirm != "yes" {
+		if !prompt.ConfirmTyped() {
 			fmt.Println("Deletion cancelled")
 			return
 		}
@@ -1068,17 +1188,17 @@ func deletePostgresqlDatabase(dbName string, force bool) {
 	terminateCmd := fmt.Sprintf(`
 	SELECT pg_terminate_backend(pg_stat_activity.pid)
 	FROM pg_stat_activity
-	WHERE pg_stat_activity.datname = '%s' AND pid <> pg_backend_pid();
-	`, dbName)
+	WHERE pg_stat_activity.datname = %s AND pid <> pg_backend_pid();
+	`, postgres.Quote(dbName))
 
-	psqlCmd := exec.Command("sudo", "-u", "postgres", "psql", "-c", terminateCmd)
-	psqlCmd.Run() // Ignore errors
+	psqlCmd := postgresqlSQL(terminateCmd)
+	dryrun.Run(psqlCmd) // Ignore errors
 
 	// Drop database
-	dropCmd := fmt.Sprintf("DROP DATABASE IF EXISTS \"%s\";", dbName)
-	psqlCmd = exec.Command("sudo", "-u", "postgres", "psql", "-c", dropCmd)
-	if err := psqlCmd.Run(); err != nil {
-		fmt.Printf("Error deleting database: %v\n", err)
+	dropCmd := fmt.Sprintf("DROP DATABASE IF EXISTS %s;", postgres.QuoteIdent(dbName))
+	psqlCmd = postgresqlSQL(dropCmd)
+	if err := dryrun.Run(psqlCmd); err != nil {
+		ui.Fail("Error deleting database: %v\n", err)
 		return
 	}
 
@@ -1094,7 +1214,7 @@ func listPostgresqlDatabases() {
 	psqlCmd := exec.Command("sudo", "-u", "postgres", "psql", "-c", query)
 	output, err := psqlCmd.CombinedOutput()
 	if err != nil {
-		fmt.Printf("Error listing databases: %v\n", err)
+		ui.Fail("Error listing databases: %v\n", err)
 		return
 	}
 	fmt.Print(string(output))
@@ -1106,21 +1226,242 @@ func showPostgresqlDatabaseInfo(dbName string) {
 
 	// Connect to specific database and get info
 	query := fmt.Sprintf(`
-	SELECT 'Database:' as Key, datname as Value FROM pg_database WHERE datname = '%s' UNION
-	SELECT 'Owner:', pg_get_userbyid(datdba) FROM pg_database WHERE datname = '%s' UNION
+	SELECT 'Database:' as Key, datname as Value FROM pg_database WHERE datname = %[1]s UNION
+	SELECT 'Owner:', pg_get_userbyid(datdba) FROM pg_database WHERE datname = %[1]s UNION
 	SELECT 'Tables:', CAST(COUNT(*) as TEXT) FROM information_schema.tables WHERE table_schema = 'public' AND table_type = 'BASE TABLE' UNION
-	SELECT 'Connections:', CAST(COUNT(*) as TEXT) FROM pg_stat_activity WHERE datname = '%s';
-	`, dbName, dbName, dbName)
+	SELECT 'Connections:', CAST(COUNT(*) as TEXT) FROM pg_stat_activity WHERE datname = %[1]s;
+	`, postgres.Quote(dbName))
 
-	psqlCmd := exec.Command("sudo", "-u", "postgres", "psql", "-c", query)
+	psqlCmd := postgresqlSQL(query)
 	output, err := psqlCmd.CombinedOutput()
 	if err != nil {
-		fmt.Printf("Error retrieving database info: %v\n", err)
+		ui.Fail("Error retrieving database info: %v\n", err)
 		return
 	}
 	fmt.Print(string(output))
 }
 
+// postgresqlSQL returns psql running statements as the postgres user. They
+// go through stdin, so the passwords in them don't show up in the process
+// list.
+func postgresqlSQL(statements string) *exec.Cmd {
+	psqlCmd := exec.Command("sudo", "-u", "postgres", "psql", "-X", "-q", "-v", "ON_ERROR_STOP=1")
+	psqlCmd.Stdin = strings.NewReader(statements)
+	return psqlCmd
+}
+
+// openMySQLShell runs the mysql client as root
+func openMySQLShell(dbType, dbName string) {
+	shell, cleanup, err := mysqlClient(dbType, dbName)
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	defer cleanup()
+	runShell(shell)
+}
+
+// openPostgresqlShell runs psql as the postgres user on a cluster
+func openPostgresqlShell(clusterRef, dbName string) {
+	shell, err := postgresqlClient(clusterRef, dbName)
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	runShell(shell)
+}
+
+// mysqlClient returns the mysql client logged in as root with the saved
+// root password (see mysql.Command for the cleanup function)
+func mysqlClient(dbType, dbName string, args ...string) (*exec.Cmd, func(), error) {
+	if dbName != "" {
+		args = append(args, dbName)
+	}
+	return mysql.Command("mysql", "root", mysql.RootPassword(dbType), args...)
+}
+
+// postgresqlClient returns psql logged in as the postgres user on a
+// cluster, with the saved password over TCP or else through the socket
+// (peer authentication)
+func postgresqlClient(clusterRef, dbName string, args ...string) (*exec.Cmd, error) {
+	cluster, err := postgres.Find(clusterRef)
+	if err != nil {
+		return nil, err
+	}
+	if dbName == "" {
+		dbName = "postgres"
+	}
+	args = append(args, dbName)
+
+	if password := savedPassword("postgresql_root_password"); password != "" {
+		client := exec.Command("psql", append([]string{"-U", "postgres", "-h", "localhost", "-p", strconv.Itoa(cluster.Port)}, args...)...)
+		client.Env = append(os.Environ(), "PGPASSWORD="+password)
+		return client, nil
+	}
+	return cluster.Command("psql", args...), nil
+}
+
+// execSQL runs a query or a SQL script (optionally gzipped) in a database,
+// in one transaction when asked. Both go through the client's stdin, so
+// neither shows up in the process list.
+func execSQL(dbType, dbName, file, query string, transaction bool, clusterRef string) {
+	source := "query"
+	if file == "-" {
+		source = "stdin"
+	} else if file != "" {
+		source = file
+	}
+	target := dbType
+	if dbName != "" {
+		target = dbType + " database " + dbName
+	}
+	if dryrun.Enabled() {
+		fmt.Printf("🔎 [dry-run] would run %s in %s\n", source, target)
+		return
+	}
+
+	var input io.Reader = strings.NewReader(query)
+	var progress *importProgress
+	if file == "-" {
+		// The client reads stdin itself, so it can also be typed in
+		input = os.Stdin
+	} else if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			ui.Fail("❌ Could not open %s: %v\n", file, err)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			ui.Fail("❌ Could not read %s: %v\n", file, err)
+			return
+		}
+
+		progress = &importProgress{r: f, name: filepath.Base(file), total: info.Size()}
+		input = progress
+		if strings.HasSuffix(file, ".gz") {
+			gz, err := gzip.NewReader(progress)
+			if err != nil {
+				ui.Fail("❌ %s is not a gzip file: %v\n", file, err)
+				return
+			}
+			defer gz.Close()
+			input = gz
+		}
+	}
+
+	var client *exec.Cmd
+	if dbType == "postgresql" {
+		args := []string{"-v", "ON_ERROR_STOP=1", "-f", "-"}
+		if transaction {
+			args = append(args, "--single-transaction")
+		}
+		var err error
+		if client, err = postgresqlClient(clusterRef, dbName, args...); err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+	} else {
+		var args []string
+		if file == "" {
+			// Tables like the interactive client, not tab-separated
+			args = append(args, "--table")
+		}
+		// mysql stops at the first error, so COMMIT is never reached and
+		// the transaction is rolled back when the connection closes
+		if transaction {
+			input = io.MultiReader(strings.NewReader("START TRANSACTION;\n"), input, strings.NewReader("\nCOMMIT;\n"))
+		}
+		var cleanup func()
+		var err error
+		if client, cleanup, err = mysqlClient(dbType, dbName, args...); err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		defer cleanup()
+	}
+
+	if progress != nil {
+		fmt.Printf("📥 Importing %s into %s...\n", file, target)
+	}
+	start := time.Now()
+	client.Stdin = input
+	// Query results go to the terminal as they are, not through the filter
+	// that colors lines (a result starting with "Error" isn't an error)
+	client.Stdout, client.Stderr = ui.Terminal()
+	err := client.Run()
+	if progress != nil {
+		progress.show()
+		fmt.Fprintln(os.Stderr)
+	}
+	if err != nil {
+		if transaction {
+			ui.Fail("❌ Running %s in %s failed, the transaction was rolled back: %v\n", source, target, err)
+		} else {
+			ui.Fail("❌ Running %s in %s failed: %v\n", source, target, err)
+		}
+		return
+	}
+	if progress != nil {
+		fmt.Printf("✅ Imported %s (%s) in %s\n", file, bytesize.Format(progress.total), time.Since(start).Round(time.Second))
+	}
+}
+
+// importProgress shows how much of a script was read, on one line of the
+// terminal, at most twice a second
+type importProgress struct {
+	r     io.Reader
+	name  string
+	total int64
+	read  int64
+	shown time.Time
+}
+
+func (p *importProgress) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if time.Since(p.shown) >= 500*time.Millisecond {
+		p.show()
+	}
+	return n, err
+}
+
+func (p *importProgress) show() {
+	p.shown = time.Now()
+	percent := int64(100)
+	if p.total > 0 {
+		percent = p.read * 100 / p.total
+	}
+	fmt.Fprintf(os.Stderr, "\r📥 %s: %3d%% (%s of %s)", p.name, percent, bytesize.Format(p.read), bytesize.Format(p.total))
+}
+
+// savedPassword returns the first password saved in the config under keys
+func savedPassword(keys ...string) string {
+	cfg, err := config.Load()
+	if err != nil {
+		return ""
+	}
+	for _, key := range keys {
+		if pass, ok := cfg.GetDefault(key, "").(string); ok && pass != "" {
+			return pass
+		}
+	}
+	return ""
+}
+
+// runShell runs an interactive client on the terminal
+func runShell(shell *exec.Cmd) {
+	// The clients only show their prompt and line editing on a terminal
+	shell.Stdin = os.Stdin
+	shell.Stdout, shell.Stderr = ui.Terminal()
+	if err := shell.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			ui.Fail("❌ Could not start %s: %v\n", filepath.Base(shell.Path), err)
+		}
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(dbCmd)
 
@@ -1140,6 +1481,15 @@ func init() {
 	dbDatabaseCmd.AddCommand(dbDatabaseListCmd)
 	dbDatabaseCmd.AddCommand(dbDatabaseInfoCmd)
 
+	// Clients
+	dbCmd.AddCommand(dbShellCmd)
+	dbCmd.AddCommand(dbExecCmd)
+	dbShellCmd.Flags().String("cluster", "", "PostgreSQL cluster, e.g. 17/main (default: the one on port 5432)")
+	dbExecCmd.Flags().String("cluster", "", "PostgreSQL cluster, e.g. 17/main (default: the one on port 5432)")
+	dbExecCmd.Flags().StringP("file", "f", "", "SQL script to run (.sql or .sql.gz, - for stdin)")
+	dbExecCmd.Flags().String("query", "", "SQL query to run")
+	dbExecCmd.Flags().BoolP("transaction", "t", false, "Run in one transaction, rolled back on the first error")
+
 	// Initialize flags
 	init_dbUserCreateCmd()
 	init_dbUserUpdateCmd()
@@ -7,7 +7,11 @@ import (
 	"strings"
 	"text/template"
 
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/firewall"
+	"webstack-cli/internal/pkg"
 	"webstack-cli/internal/templates"
+	"webstack-cli/internal/ui"
 
 	"github.com/spf13/cobra"
 )
@@ -32,7 +36,7 @@ Usage:
   sudo webstack dns install --mode slave --master-ip 192.168.1.10 --cluster-name datacenter-1`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -53,7 +57,7 @@ Usage:
   sudo webstack dns uninstall`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -82,7 +86,7 @@ Usage:
   sudo webstack dns config --zone example.com --type master`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 
@@ -119,12 +123,12 @@ var dnsRestartCmd = &cobra.Command{
 	Short: "Restart Bind9 DNS service",
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 		fmt.Println("Restarting Bind9 DNS service...")
 		if err := exec.Command("systemctl", "restart", "bind9").Run(); err != nil {
-			fmt.Printf("Failed to restart Bind9: %v\n", err)
+			ui.Fail("Failed to restart Bind9: %v\n", err)
 			return
 		}
 		fmt.Println("Bind9 restarted successfully")
@@ -136,12 +140,12 @@ var dnsReloadCmd = &cobra.Command{
 	Short: "Reload Bind9 configuration",
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 		fmt.Println("Reloading Bind9 configuration...")
 		if err := exec.Command("systemctl", "reload", "bind9").Run(); err != nil {
-			fmt.Printf("Failed to reload Bind9: %v\n", err)
+			ui.Fail("Failed to reload Bind9: %v\n", err)
 			return
 		}
 		fmt.Println("Bind9 configuration reloaded")
@@ -154,7 +158,7 @@ var dnsCheckCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("Checking Bind9 configuration...")
 		if err := exec.Command("named-checkconf").Run(); err != nil {
-			fmt.Println("Configuration is invalid")
+			ui.Fail("Configuration is invalid\n")
 			return
 		}
 		fmt.Println("Configuration is valid")
@@ -185,7 +189,7 @@ var dnsQueryCmd = &cobra.Command{
 	Long:  "Test DNS query: webstack dns query example.com",
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) == 0 {
-			fmt.Println("Please specify a domain to query")
+			ui.Invalid("Please specify a domain to query\n")
 			fmt.Println("   Usage: webstack dns query example.com")
 			return
 		}
@@ -198,7 +202,7 @@ var dnsBackupCmd = &cobra.Command{
 	Short: "Backup DNS configuration and zones",
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 		backupDNS()
@@ -211,11 +215,11 @@ var dnsRestoreCmd = &cobra.Command{
 	Long:  "Restore DNS configuration: sudo webstack dns restore /path/to/backup.tar.gz",
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 		if len(args) == 0 {
-			fmt.Println("Please specify backup file path")
+			ui.Invalid("Please specify backup file path\n")
 			return
 		}
 		restoreDNS(args[0])
@@ -227,7 +231,7 @@ var dnsDNSSECCmd = &cobra.Command{
 	Short: "Manage DNSSEC settings",
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 		enable, _ := cmd.Flags().GetBool("enable")
@@ -260,7 +264,7 @@ var dnsQuerylogCmd = &cobra.Command{
 	Short: "Enable/disable query logging",
 	Run: func(cmd *cobra.Command, args []string) {
 		if os.Geteuid() != 0 {
-			fmt.Println("This command requires root privileges (use sudo)")
+			ui.Fail("This command requires root privileges (use sudo)\n")
 			return
 		}
 		enable, _ := cmd.Flags().GetBool("enable")
@@ -332,7 +336,7 @@ func installDNS(mode, masterIP, serverIP, clusterName string) {
 
 	// Validate master-slave setup
 	if mode == "slave" && masterIP == "" {
-		fmt.Println("Slave mode requires --master-ip flag")
+		ui.Invalid("Slave mode requires --master-ip flag\n")
 		return
 	}
 
@@ -340,7 +344,7 @@ func installDNS(mode, masterIP, serverIP, clusterName string) {
 	if serverIP == "" {
 		serverIP = detectServerIP()
 		if serverIP == "" {
-			fmt.Println("Could not detect server IP. Please specify with --server-ip")
+			ui.Fail("Could not detect server IP. Please specify with --server-ip\n")
 			return
 		}
 		fmt.Printf("✓ Auto-detected server IP: %s\n", serverIP)
@@ -348,13 +352,13 @@ func installDNS(mode, masterIP, serverIP, clusterName string) {
 
 	// Step 1: Update packages and install Bind9
 	fmt.Println("Installing Bind9...")
-	if err := exec.Command("apt", "update").Run(); err != nil {
-		fmt.Printf("Failed to update package list: %v\n", err)
+	if err := pkg.Update(); err != nil {
+		ui.Fail("Failed to update package list: %v\n", err)
 		return
 	}
 
-	if err := exec.Command("apt", "install", "-y", "bind9", "bind9-utils", "bind9-doc").Run(); err != nil {
-		fmt.Printf("Failed to install Bind9: %v\n", err)
+	if err := pkg.Install("bind9", "bind9-utils", "bind9-doc"); err != nil {
+		ui.Fail("Failed to install Bind9: %v\n", err)
 		return
 	}
 	fmt.Println("✓ Bind9 installed")
@@ -382,7 +386,7 @@ func installDNS(mode, masterIP, serverIP, clusterName string) {
 	// Step 3: Deploy named.conf configuration
 	fmt.Println("Generating Bind9 configuration...")
 	if !deployNamedConf(serverIP, mode, masterIP, clusterName) {
-		fmt.Println("Failed to deploy Bind9 configuration")
+		ui.Fail("Failed to deploy Bind9 configuration\n")
 		return
 	}
 	fmt.Println("✓ Configuration deployed")
@@ -390,7 +394,7 @@ func installDNS(mode, masterIP, serverIP, clusterName string) {
 	// Step 4: Test configuration
 	fmt.Println("Testing Bind9 configuration...")
 	if err := exec.Command("named-checkconf").Run(); err != nil {
-		fmt.Println("Bind9 configuration test failed")
+		ui.Fail("Bind9 configuration test failed\n")
 		fmt.Println("   Run 'sudo named-checkconf' for details")
 		return
 	}
@@ -400,28 +404,17 @@ func installDNS(mode, masterIP, serverIP, clusterName string) {
 	fmt.Println("Starting Bind9 service...")
 	exec.Command("systemctl", "enable", "bind9").Run()
 	if err := exec.Command("systemctl", "restart", "bind9").Run(); err != nil {
-		fmt.Printf("Failed to start Bind9: %v\n", err)
+		ui.Fail("Failed to start Bind9: %v\n", err)
 		return
 	}
 	fmt.Println("Bind9 service started")
 
 	// Step 6: Configure firewall
 	fmt.Println("Configuring firewall...")
-	dnsPorts := []int{53} // DNS uses both TCP and UDP on port 53
-
-	for _, port := range dnsPorts {
-		portStr := fmt.Sprintf("%d", port)
-		// Add both TCP and UDP rules for IPv4
-		exec.Command("iptables", "-A", "INPUT", "-p", "tcp", "--dport", portStr, "-j", "ACCEPT").Run()
-		exec.Command("iptables", "-A", "INPUT", "-p", "udp", "--dport", portStr, "-j", "ACCEPT").Run()
-		// Add both TCP and UDP rules for IPv6
-		exec.Command("ip6tables", "-A", "INPUT", "-p", "tcp", "--dport", portStr, "-j", "ACCEPT").Run()
-		exec.Command("ip6tables", "-A", "INPUT", "-p", "udp", "--dport", portStr, "-j", "ACCEPT").Run()
+	// DNS uses both TCP and UDP on port 53
+	if err := firewall.Open("dns", "both", 53); err != nil {
+		ui.Warn("⚠️  Warning: Could not open port 53: %v\n", err)
 	}
-
-	// Persist rules across reboots
-	exec.Command("bash", "-c", "iptables-save > /etc/iptables/rules.v4 2>/dev/null || true").Run()
-	exec.Command("bash", "-c", "ip6tables-save > /etc/iptables/rules.v6 2>/dev/null || true").Run()
 	fmt.Println("✓ Firewall configured (DNS port 53 TCP/UDP opened)")
 
 	// Success message
@@ -453,7 +446,7 @@ func uninstallDNS() {
 
 	// Remove package
 	fmt.Println("Removing Bind9 package...")
-	exec.Command("apt", "purge", "-y", "bind9", "bind9-utils", "bind9-doc").Run()
+	pkg.Purge("bind9", "bind9-utils", "bind9-doc")
 
 	// Clean up directories
 	fmt.Println("Cleaning up...")
@@ -461,16 +454,9 @@ func uninstallDNS() {
 
 	// Remove firewall rules
 	fmt.Println("Removing firewall rules...")
-	// Remove both TCP and UDP rules for DNS port 53
-	exec.Command("iptables", "-D", "INPUT", "-p", "tcp", "--dport", "53", "-j", "ACCEPT").Run()
-	exec.Command("iptables", "-D", "INPUT", "-p", "udp", "--dport", "53", "-j", "ACCEPT").Run()
-	// Remove IPv6 rules
-	exec.Command("ip6tables", "-D", "INPUT", "-p", "tcp", "--dport", "53", "-j", "ACCEPT").Run()
-	exec.Command("ip6tables", "-D", "INPUT", "-p", "udp", "--dport", "53", "-j", "ACCEPT").Run()
-
-	// Save updated rules
-	exec.Command("bash", "-c", "iptables-save > /etc/iptables/rules.v4 2>/dev/null || true").Run()
-	exec.Command("bash", "-c", "ip6tables-save > /etc/iptables/rules.v6 2>/dev/null || true").Run()
+	if _, err := firewall.Close("dns", "both", false, 53); err != nil {
+		ui.Warn("⚠️  Warning: Could not close port 53: %v\n", err)
+	}
 
 	fmt.Println("Bind9 DNS Server uninstalled successfully (firewall port 53 closed)")
 }
@@ -527,14 +513,14 @@ func deployNamedConf(serverIP, mode, masterIP, clusterName string) bool {
 	// Get named.conf template
 	templateContent, err := templates.GetDNSTemplate("named.conf")
 	if err != nil {
-		fmt.Printf("Could not read DNS template: %v\n", err)
+		ui.Fail("Could not read DNS template: %v\n", err)
 		return false
 	}
 
 	// Parse and execute template
 	tmpl, err := template.New("dns").Parse(string(templateContent))
 	if err != nil {
-		fmt.Printf("Could not parse DNS template: %v\n", err)
+		ui.Fail("Could not parse DNS template: %v\n", err)
 		return false
 	}
 
@@ -546,14 +532,14 @@ func deployNamedConf(serverIP, mode, masterIP, clusterName string) bool {
 		"ClusterName": clusterName,
 	})
 	if err != nil {
-		fmt.Printf("Could not execute DNS template: %v\n", err)
+		ui.Fail("Could not execute DNS template: %v\n", err)
 		return false
 	}
 
 	// Write to named.conf
 	configPath := "/etc/bind/named.conf"
 	if err := os.WriteFile(configPath, []byte(buf.String()), 0644); err != nil {
-		fmt.Printf("Failed to write DNS config: %v\n", err)
+		ui.Fail("Failed to write DNS config: %v\n", err)
 		return false
 	}
 
@@ -569,7 +555,7 @@ func configureDNSSlave(slaveIP string, add bool) {
 	// Read current config
 	data, err := os.ReadFile("/etc/bind/named.conf.local")
 	if err != nil {
-		fmt.Printf("Could not read DNS config: %v\n", err)
+		ui.Fail("Could not read DNS config: %v\n", err)
 		return
 	}
 
@@ -590,13 +576,13 @@ func configureDNSSlave(slaveIP string, add bool) {
 
 	// Write back config
 	if err := os.WriteFile("/etc/bind/named.conf.local", []byte(content), 0644); err != nil {
-		fmt.Printf("Failed to update config: %v\n", err)
+		ui.Fail("Failed to update config: %v\n", err)
 		return
 	}
 
 	// Test and reload
 	if err := exec.Command("named-checkconf").Run(); err != nil {
-		fmt.Println("Configuration invalid, reverting...")
+		ui.Fail("Configuration invalid, reverting...\n")
 		return
 	}
 
@@ -605,6 +591,7 @@ func configureDNSSlave(slaveIP string, add bool) {
 }
 
 func configureZone(zoneName, zoneType string) {
+	zoneName = domain.Normalize(zoneName)
 	fmt.Printf("Configuring zone: %s (type: %s)\n", zoneName, zoneType)
 
 	// Read current config
@@ -616,9 +603,9 @@ func configureZone(zoneName, zoneType string) {
 
 	content := string(data)
 
-	// Check if zone already exists
-	if strings.Contains(content, fmt.Sprintf(`zone "%s"`, zoneName)) {
-		fmt.Printf("Zone %s already configured\n", zoneName)
+	// Check if zone already exists (zone names are case-insensitive)
+	if strings.Contains(strings.ToLower(content), fmt.Sprintf(`zone "%s"`, zoneName)) {
+		ui.Fail("Zone %s already configured\n", zoneName)
 		return
 	}
 
@@ -641,7 +628,7 @@ func configureZone(zoneName, zoneType string) {
 
 	// Write back config
 	if err := os.WriteFile("/etc/bind/named.conf.local", []byte(content), 0644); err != nil {
-		fmt.Printf("Failed to write zone config: %v\n", err)
+		ui.Fail("Failed to write zone config: %v\n", err)
 		return
 	}
 
@@ -689,7 +676,7 @@ func detectServerIP() string {
 func listDNSZones() {
 	data, err := os.ReadFile("/etc/bind/named.conf.local")
 	if err != nil {
-		fmt.Println("Could not read zone configuration")
+		ui.Fail("Could not read zone configuration\n")
 		return
 	}
 
@@ -722,7 +709,7 @@ func testDNSQuery(domain string) {
 	fmt.Printf("Testing DNS query for: %s\n", domain)
 	output, err := exec.Command("dig", "@127.0.0.1", domain, "+short").Output()
 	if err != nil {
-		fmt.Printf("Query failed: %v\n", err)
+		ui.Fail("Query failed: %v\n", err)
 		return
 	}
 
@@ -741,7 +728,7 @@ func backupDNS() {
 
 	cmd := fmt.Sprintf("tar -czf %s /etc/bind /var/lib/bind 2>/dev/null", backupName)
 	if err := exec.Command("bash", "-c", cmd).Run(); err != nil {
-		fmt.Printf("Backup failed: %v\n", err)
+		ui.Fail("Backup failed: %v\n", err)
 		return
 	}
 
@@ -752,7 +739,7 @@ func restoreDNS(backupPath string) {
 	fmt.Printf("Restoring DNS from: %s\n", backupPath)
 
 	if _, err := os.Stat(backupPath); os.IsNotExist(err) {
-		fmt.Println("Backup file not found")
+		ui.Fail("Backup file not found\n")
 		return
 	}
 
@@ -761,7 +748,7 @@ func restoreDNS(backupPath string) {
 
 	cmd := fmt.Sprintf("tar -xzf %s -C / 2>/dev/null", backupPath)
 	if err := exec.Command("bash", "-c", cmd).Run(); err != nil {
-		fmt.Printf("Restore failed: %v\n", err)
+		ui.Fail("Restore failed: %v\n", err)
 		fmt.Println("Attempting to restart Bind9...")
 		exec.Command("systemctl", "start", "bind9").Run()
 		return
@@ -773,7 +760,7 @@ func restoreDNS(backupPath string) {
 
 	fmt.Println("Starting Bind9...")
 	if err := exec.Command("systemctl", "start", "bind9").Run(); err != nil {
-		fmt.Printf("Failed to start Bind9: %v\n", err)
+		ui.Fail("Failed to start Bind9: %v\n", err)
 		return
 	}
 
@@ -785,7 +772,7 @@ func manageDNSSEC(enable bool) {
 
 	data, err := os.ReadFile("/etc/bind/named.conf")
 	if err != nil {
-		fmt.Println("Could not read named.conf")
+		ui.Fail("Could not read named.conf\n")
 		return
 	}
 
@@ -804,7 +791,7 @@ func manageDNSSEC(enable bool) {
 	}
 
 	if err := os.WriteFile("/etc/bind/named.conf", []byte(content), 0644); err != nil {
-		fmt.Println("Failed to update configuration")
+		ui.Fail("Failed to update configuration\n")
 		return
 	}
 
@@ -841,7 +828,7 @@ func manageQueryLog(enable bool) {
 
 	data, err := os.ReadFile("/etc/bind/named.conf")
 	if err != nil {
-		fmt.Println("Could not read named.conf")
+		ui.Fail("Could not read named.conf\n")
 		return
 	}
 
@@ -860,7 +847,7 @@ func manageQueryLog(enable bool) {
 	}
 
 	if err := os.WriteFile("/etc/bind/named.conf", []byte(content), 0644); err != nil {
-		fmt.Println("Failed to update configuration")
+		ui.Fail("Failed to update configuration\n")
 		return
 	}
 
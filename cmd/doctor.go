@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"os"
+
+	"webstack-cli/internal/doctor"
+	"webstack-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose common server problems",
+	Long: `Run a health check of the web stack and suggest a fix for every problem found:
+vhost files without a domain (and domains without a vhost), broken sites-enabled
+symlinks, missing PHP-FPM sockets, expired certificates, stopped services,
+port conflicts and domains that do not resolve to this server.
+
+Usage:
+  sudo webstack doctor          # report only
+  sudo webstack doctor --fix    # also repair what can be fixed safely`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fix, _ := cmd.Flags().GetBool("fix")
+		if fix && os.Geteuid() != 0 {
+			ui.Fail("❌ --fix requires root privileges (use sudo)\n")
+			return
+		}
+		doctor.Run(doctor.Options{Fix: fix})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().Bool("fix", false, "Repair problems that have a safe automatic fix")
+}
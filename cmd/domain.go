@@ -1,7 +1,17 @@
 package cmd
 
 import (
+	"fmt"
+	"strings"
+
+	"webstack-cli/internal/backup"
 	"webstack-cli/internal/domain"
+	"webstack-cli/internal/installer"
+	"webstack-cli/internal/manifest"
+	"webstack-cli/internal/prompt"
+	"webstack-cli/internal/templates"
+	"webstack-cli/internal/ui"
+	"webstack-cli/internal/worker"
 
 	"github.com/spf13/cobra"
 )
@@ -19,7 +29,101 @@ var domainAddCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		backend, _ := cmd.Flags().GetString("backend")
 		phpVersion, _ := cmd.Flags().GetString("php")
-		domain.Add(args[0], backend, phpVersion)
+		docRoot, _ := cmd.Flags().GetString("docroot")
+		root, _ := cmd.Flags().GetString("root")
+		preset, _ := cmd.Flags().GetString("preset")
+		http3, _ := cmd.Flags().GetString("http3")
+		upstream, _ := cmd.Flags().GetString("upstream")
+		domain.Add(args[0], backend, phpVersion, domain.AddOptions{
+			DocRoot:  docRoot,
+			Root:     root,
+			Preset:   preset,
+			HTTP3:    http3,
+			Upstream: upstream,
+		})
+	},
+}
+
+var domainImportCmd = &cobra.Command{
+	Use:   "import [vhost files...]",
+	Short: "Adopt domains served by existing Nginx and Apache vhosts",
+	Long: `Bring a server that wasn't set up by webstack under management: read existing vhosts,
+extract the server names, document root, PHP-FPM socket (PHP version), proxy target and
+SSL certificate paths, show each domain for review and add the accepted ones to domains.json.
+The existing vhost files are left in place until you switch to webstack's templates with
+'webstack domain rebuild-configs'.
+Usage:
+  webstack domain import --scan
+  webstack domain import /etc/nginx/sites-available/shop.conf
+  webstack domain import --scan --yes`,
+	Run: func(cmd *cobra.Command, args []string) {
+		scan, _ := cmd.Flags().GetBool("scan")
+		yes, _ := cmd.Flags().GetBool("yes")
+		domain.Import(domain.ImportOptions{Files: args, Scan: scan, Yes: yes})
+	},
+}
+
+var domainApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Create, update and delete domains to match a manifest file",
+	Long: `Manage many domains declaratively from a YAML (or JSON) manifest kept in git. The
+manifest lists each domain with its backend, PHP version, SSL and aliases; apply compares
+it with the configured domains, shows the plan and carries it out once confirmed. Domains
+missing from the manifest are only deleted with --prune (their files are kept).
+
+  email: admin@example.com
+  domains:
+    - name: example.com
+      backend: nginx
+      php: "8.3"
+      ssl: letsencrypt
+      aliases: [www.example.com]
+    - name: app.example.com
+      backend: proxy
+      upstream: http://127.0.0.1:3000
+      ssl: selfsigned
+
+Usage:
+  webstack domain apply -f domains.yaml --plan
+  webstack domain apply -f domains.yaml
+  webstack domain apply -f domains.yaml --prune --yes`,
+	Run: func(cmd *cobra.Command, args []string) {
+		file, _ := cmd.Flags().GetString("file")
+		planOnly, _ := cmd.Flags().GetBool("plan")
+		yes, _ := cmd.Flags().GetBool("yes")
+		prune, _ := cmd.Flags().GetBool("prune")
+
+		m, err := manifest.Load(file)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		plan, err := manifest.NewPlan(m, prune)
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+
+		plan.Print()
+		if plan.Empty() {
+			fmt.Println("✅ Domains already match the manifest")
+			return
+		}
+		if planOnly {
+			return
+		}
+		if !plan.Confirm(yes) {
+			fmt.Println("Cancelled")
+			return
+		}
+
+		failed := plan.Apply()
+		fmt.Println()
+		if len(failed) > 0 {
+			ui.Fail("❌ Applied %d of %d change(s), failed: %s\n", len(plan.Changes)-len(failed), len(plan.Changes), strings.Join(failed, ", "))
+			return
+		}
+		fmt.Printf("✅ Applied %d change(s)\n", len(plan.Changes))
 	},
 }
 
@@ -30,7 +134,33 @@ var domainEditCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		backend, _ := cmd.Flags().GetString("backend")
 		phpVersion, _ := cmd.Flags().GetString("php")
-		domain.Edit(args[0], backend, phpVersion)
+		docRoot, _ := cmd.Flags().GetString("docroot")
+		root, _ := cmd.Flags().GetString("root")
+		hardening, _ := cmd.Flags().GetString("hardening")
+		http3, _ := cmd.Flags().GetString("http3")
+		forceHTTPS, _ := cmd.Flags().GetString("force-https")
+		canonical, _ := cmd.Flags().GetString("canonical")
+		upstream, _ := cmd.Flags().GetString("upstream")
+
+		// Offer to install a missing PHP version instead of failing the switch
+		if _, err := oneOf(phpVersions...)(phpVersion); err == nil && !domain.PHPInstalled(phpVersion) {
+			ui.Warn("⚠️  PHP %s is not installed\n", phpVersion)
+			if !prompt.Confirm(fmt.Sprintf("Install PHP %s now?", phpVersion)) {
+				ui.Fail("❌ Install it with 'webstack install php %s', then run the edit again\n", phpVersion)
+				return
+			}
+			installer.InstallPHP(phpVersion)
+		}
+
+		domain.Edit(args[0], backend, phpVersion, domain.EditOptions{
+			DocRoot:    docRoot,
+			Root:       root,
+			Hardening:  hardening,
+			HTTP3:      http3,
+			ForceHTTPS: forceHTTPS,
+			Canonical:  canonical,
+			Upstream:   upstream,
+		})
 	},
 }
 
@@ -39,7 +169,12 @@ var domainDeleteCmd = &cobra.Command{
 	Short: "Delete a domain",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		existed := domain.DomainExists(args[0])
 		domain.Delete(args[0])
+		// Workers of a deleted domain would restart forever
+		if existed && !domain.DomainExists(args[0]) {
+			worker.RemoveDomain(args[0])
+		}
 	},
 }
 
@@ -54,9 +189,395 @@ var domainListCmd = &cobra.Command{
 var domainRebuildCmd = &cobra.Command{
 	Use:   "rebuild-configs",
 	Short: "Rebuild configuration files for all domains",
-	Long:  `Regenerate Nginx and Apache configuration files for all domains from templates. Useful after updating templates or fixing configuration issues.`,
+	Long: `Regenerate Nginx and Apache configuration files for all domains from templates. Useful after updating templates or fixing configuration issues.
+
+Vhosts are rendered in parallel and compared with the live files: only domains whose output
+changed are written and validated, and only the web servers whose configs changed are
+reloaded. A summary lists the changed and failed domains.
+Usage:
+  webstack domain rebuild-configs
+  webstack domain rebuild-configs --diff
+  webstack domain rebuild-configs --force --workers 4`,
+	Run: func(cmd *cobra.Command, args []string) {
+		workers, _ := cmd.Flags().GetInt("workers")
+		force, _ := cmd.Flags().GetBool("force")
+		diff, _ := cmd.Flags().GetBool("diff")
+
+		warnConfigDrift()
+		domain.RebuildAll(domain.RebuildOptions{Workers: workers, Force: force, Diff: diff})
+	},
+}
+
+var domainBackupCmd = &cobra.Command{
+	Use:   "backup [domain]",
+	Short: "Export a domain to a single archive",
+	Long: `Export a domain into a single tarball containing its files (htdocs, logs, configs),
+the domain entry, web server configuration, SSL certificates and databases.
+Databases are auto-detected from wp-config.php and .env files; add others with --database.
+Usage:
+  webstack domain backup example.com
+  webstack domain backup example.com --output /root/example.com.tar.gz
+  webstack domain backup example.com --database mysql:shop --database postgresql:crm
+  webstack domain backup example.com --database postgresql@17-main:crm  # another PostgreSQL cluster`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+		databases, _ := cmd.Flags().GetStringSlice("database")
+		noDatabases, _ := cmd.Flags().GetBool("no-databases")
+
+		fmt.Printf("📦 Exporting domain: %s\n", args[0])
+		archive, err := backup.ExportDomain(args[0], backup.DomainExportOptions{
+			Output:      output,
+			Databases:   databases,
+			NoDatabases: noDatabases,
+		})
+		if err != nil {
+			ui.Fail("❌ Domain export failed: %v\n", err)
+			return
+		}
+
+		fmt.Printf("✅ Domain exported: %s\n", archive)
+		fmt.Printf("   Restore with: sudo webstack domain restore %s\n", archive)
+	},
+}
+
+var domainRestoreCmd = &cobra.Command{
+	Use:   "restore [file]",
+	Short: "Restore a domain from an exported archive",
+	Long: `Re-create a domain from an archive created by 'webstack domain backup'.
+Files, SSL certificates, databases and the domain entry are restored and the web server
+configuration is regenerated for this server.
+Usage:
+  webstack domain restore /root/example.com.tar.gz
+  webstack domain restore /root/example.com.tar.gz --force --skip-databases`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		force, _ := cmd.Flags().GetBool("force")
+		skipDatabases, _ := cmd.Flags().GetBool("skip-databases")
+
+		name, err := backup.ImportDomain(args[0], backup.DomainRestoreOptions{
+			Force:         force,
+			SkipDatabases: skipDatabases,
+		})
+		if err != nil {
+			ui.Fail("❌ Domain restore failed: %v\n", err)
+			return
+		}
+
+		fmt.Printf("✅ Domain %s restored successfully\n", name)
+	},
+}
+
+var domainRewriteCmd = &cobra.Command{
+	Use:   "rewrite",
+	Short: "Manage per-domain HTTP redirects",
+	Long: `Manage redirects stored with the domain and rendered into its Nginx and Apache configuration,
+so common SEO redirects don't require hand-editing vhosts.
+Usage:
+  webstack domain rewrite add example.com --from /old-page --to /new-page --code 301
+  webstack domain rewrite list example.com
+  webstack domain rewrite remove example.com --from /old-page`,
+}
+
+var domainRewriteAddCmd = &cobra.Command{
+	Use:   "add [domain]",
+	Short: "Add or replace a redirect",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+		code, _ := cmd.Flags().GetInt("code")
+		domain.AddRewrite(args[0], from, to, code)
+	},
+}
+
+var domainRewriteRemoveCmd = &cobra.Command{
+	Use:   "remove [domain]",
+	Short: "Remove a redirect",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		from, _ := cmd.Flags().GetString("from")
+		domain.RemoveRewrite(args[0], from)
+	},
+}
+
+var domainRewriteListCmd = &cobra.Command{
+	Use:   "list [domain]",
+	Short: "List redirects of a domain",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		domain.ListRewrites(args[0])
+	},
+}
+
+var domainRouteCmd = &cobra.Command{
+	Use:   "route",
+	Short: "Serve sub-paths with another PHP version or document root",
+	Long: `Serve a sub-path of a domain with another PHP version, another document root, or
+both, e.g. a blog on PHP 8.1 under /blog or a legacy application on PHP 7.4 from its
+own folder under /legacy. Routes are stored with the domain and rendered into its
+Nginx location blocks and Apache Alias/ProxyPassMatch directives.
+
+Routes on another PHP version use the shared pool of that version, which must be
+installed and running; php.ini overrides of the domain only apply to its own version.
+Usage:
+  webstack domain route add example.com --path /blog --php 8.1
+  webstack domain route add example.com --path /legacy --php 7.4 --root /srv/legacy
+  webstack domain route list example.com
+  webstack domain route remove example.com --path /legacy`,
+}
+
+var domainRouteAddCmd = &cobra.Command{
+	Use:   "add [domain]",
+	Short: "Add or replace the route of a path",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path, _ := cmd.Flags().GetString("path")
+		php, _ := cmd.Flags().GetString("php")
+		root, _ := cmd.Flags().GetString("root")
+		domain.AddRoute(args[0], domain.Route{Path: path, PHPVersion: php, Root: root})
+	},
+}
+
+var domainRouteRemoveCmd = &cobra.Command{
+	Use:   "remove [domain]",
+	Short: "Remove the route of a path",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path, _ := cmd.Flags().GetString("path")
+		domain.RemoveRoute(args[0], path)
+	},
+}
+
+var domainRouteListCmd = &cobra.Command{
+	Use:   "list [domain]",
+	Short: "List routes of a domain",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		domain.ListRoutes(args[0])
+	},
+}
+
+var domainHeadersCmd = &cobra.Command{
+	Use:   "headers [domain]",
+	Short: "Manage HSTS and security headers of a domain",
+	Long: `Manage the security headers sent by the domain's vhosts. The settings are stored in
+domains.json and rendered into the Nginx and Apache configuration, so they survive
+'webstack domain rebuild-configs'. Without flags the current headers are shown.
+  webstack domain headers example.com
+  webstack domain headers example.com --hsts preload --x-frame-options DENY
+  webstack domain headers example.com --csp "default-src 'self'; img-src 'self' data:"
+  webstack domain headers example.com --csp off
+  webstack domain headers example.com --reset`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		hsts, _ := cmd.Flags().GetString("hsts")
+		csp, _ := cmd.Flags().GetString("csp")
+		xfo, _ := cmd.Flags().GetString("x-frame-options")
+		referrer, _ := cmd.Flags().GetString("referrer-policy")
+		permissions, _ := cmd.Flags().GetString("permissions-policy")
+		reset, _ := cmd.Flags().GetBool("reset")
+		domain.ManageHeaders(args[0], domain.HeadersOptions{
+			HSTS:              hsts,
+			CSP:               csp,
+			XFrameOptions:     xfo,
+			ReferrerPolicy:    referrer,
+			PermissionsPolicy: permissions,
+			Reset:             reset,
+		})
+	},
+}
+
+var domainProtectCmd = &cobra.Command{
+	Use:   "protect [domain]",
+	Short: "Protect a domain or path with HTTP basic auth",
+	Long: `Put a domain, or a path of it, behind HTTP basic auth, e.g. a staging site or an
+admin area. Users are kept in an htpasswd file per path under /etc/webstack/htpasswd
+and the vhosts get auth_basic (Nginx) or AuthType Basic (Apache). The password is
+generated and printed unless --password is given; adding an existing user changes
+its password. Let's Encrypt challenges stay reachable. Without --user or --remove
+the protected paths are shown.
+  webstack domain protect staging.example.com --user admin
+  webstack domain protect example.com --path /admin --user admin --password 'S3cret!'
+  webstack domain protect example.com --path /admin --user admin --remove
+  webstack domain protect example.com --path /admin --remove
+  webstack domain protect example.com`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path, _ := cmd.Flags().GetString("path")
+		user, _ := cmd.Flags().GetString("user")
+		password, _ := cmd.Flags().GetString("password")
+		remove, _ := cmd.Flags().GetBool("remove")
+		domain.Protect(args[0], domain.ProtectOptions{
+			Path:     path,
+			User:     user,
+			Password: password,
+			Remove:   remove,
+		})
+	},
+}
+
+var domainAccessCmd = &cobra.Command{
+	Use:   "access [domain] [allow|deny|remove|reset] [address|network|all]",
+	Short: "Manage IP allow/deny rules of a domain",
+	Long: `Allow or deny clients by IP address or CIDR network. The rules are stored with the
+domain and rendered as allow/deny directives (Nginx) and Require ip blocks (Apache).
+They are checked in order and the first match wins; clients matching no rule are
+allowed, and rules for single networks are kept before a final "all" rule. Let's
+Encrypt challenges and error pages stay reachable. Without an action the rules are shown.
+  webstack domain access example.com allow 203.0.113.0/24
+  webstack domain access example.com allow 2001:db8::/32
+  webstack domain access example.com deny all
+  webstack domain access example.com remove 203.0.113.0/24
+  webstack domain access example.com reset
+  webstack domain access example.com`,
+	Args: cobra.RangeArgs(1, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		action, source := "", ""
+		if len(args) > 1 {
+			action = args[1]
+		}
+		if len(args) > 2 {
+			source = args[2]
+		}
+		domain.ManageAccess(args[0], action, source)
+	},
+}
+
+var domainRateLimitCmd = &cobra.Command{
+	Use:   "ratelimit [domain]",
+	Short: "Limit requests and connections per client IP",
+	Long: `Limit the requests per second and the concurrent connections of each client IP.
+Nginx keeps a limit_req and a limit_conn zone per domain in
+/etc/nginx/includes/ratelimit.conf and the vhost refers to them; requests over the
+rate plus the burst are answered with 429 Too Many Requests. The burst defaults to
+twice the rate. Without flags the current limits are shown.
+
+On Apache-only servers the limits are stored but not applied: Apache has no
+request rate limiting of its own (mod_ratelimit limits bandwidth), so they take
+effect once Nginx serves the domain.
+  webstack domain ratelimit example.com --rps 10 --burst 20
+  webstack domain ratelimit example.com --conn 20
+  webstack domain ratelimit example.com --conn 0
+  webstack domain ratelimit example.com --disable
+  webstack domain ratelimit example.com`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := domain.RateLimitOptions{}
+		opts.RPS, _ = cmd.Flags().GetInt("rps")
+		opts.Burst, _ = cmd.Flags().GetInt("burst")
+		opts.Conn, _ = cmd.Flags().GetInt("conn")
+		if cmd.Flags().Changed("conn") && opts.Conn == 0 {
+			opts.Conn = -1
+		}
+		opts.Disable, _ = cmd.Flags().GetBool("disable")
+		domain.ManageRateLimit(args[0], opts)
+	},
+}
+
+var domainConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage custom vhost snippets",
+	Long: `Manage custom Nginx/Apache rules kept in /var/www/<domain>/configs. Generated vhosts include
+configs/nginx*.conf (Nginx server block), configs/apache*.conf (Apache VirtualHost) and
+configs/caddy*.conf (Caddy site block), so redirects, headers and location blocks survive
+'webstack domain rebuild-configs'.
+Usage:
+  webstack domain config edit example.com
+  webstack domain config edit example.com --server apache
+  webstack domain config edit example.com --name headers`,
+}
+
+var domainConfigEditCmd = &cobra.Command{
+	Use:   "edit [domain]",
+	Short: "Edit a snippet in $EDITOR, validate and reload",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		server, _ := cmd.Flags().GetString("server")
+		name, _ := cmd.Flags().GetString("name")
+		domain.EditSnippet(args[0], server, name)
+	},
+}
+
+var domainVarCmd = &cobra.Command{
+	Use:   "var",
+	Short: "Manage custom template variables of a domain",
+	Long: `Store key/value variables with the domain and pass them to its vhost and preset templates
+as .Vars, so customized templates (see 'webstack template export') can be parameterized
+per domain, e.g. an upstream port or a feature toggle, without code changes.
+In a template: {{ index .Vars "APP_PORT" }} or {{ if .Vars.MAINTENANCE }}...{{ end }}
+Usage:
+  webstack domain var set example.com APP_PORT 3001
+  webstack domain var list example.com
+  webstack domain var unset example.com APP_PORT`,
+}
+
+var domainVarSetCmd = &cobra.Command{
+	Use:   "set [domain] [name] [value]",
+	Short: "Set a template variable and regenerate the configuration",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		domain.SetVar(args[0], args[1], args[2])
+	},
+}
+
+var domainVarUnsetCmd = &cobra.Command{
+	Use:   "unset [domain] [name]",
+	Short: "Remove a template variable",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		domain.UnsetVar(args[0], args[1])
+	},
+}
+
+var domainVarListCmd = &cobra.Command{
+	Use:   "list [domain]",
+	Short: "List template variables of a domain",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		domain.ListVars(args[0])
+	},
+}
+
+var domainPHPSettingsCmd = &cobra.Command{
+	Use:   "php-settings [domain]",
+	Short: "Show or change php.ini overrides of a domain",
+	Long: `Override php.ini settings for a single domain. Domains with overrides get a dedicated PHP-FPM
+pool (php_admin_value entries); the overrides are stored in domains.json and kept by rebuild-configs.
+Usage:
+  webstack domain php-settings example.com
+  webstack domain php-settings example.com --memory-limit 512M --upload-max 64M --post-max 64M
+  webstack domain php-settings example.com --set max_input_vars=5000 --unset memory_limit
+  webstack domain php-settings example.com --reset`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		domain.RebuildConfigs()
+		opts := domain.PHPSettingsOptions{Set: map[string]string{}}
+		flagDirectives := []struct{ flag, directive string }{
+			{"memory-limit", "memory_limit"},
+			{"upload-max", "upload_max_filesize"},
+			{"post-max", "post_max_size"},
+			{"max-execution-time", "max_execution_time"},
+			{"max-input-vars", "max_input_vars"},
+		}
+		for _, f := range flagDirectives {
+			if value, _ := cmd.Flags().GetString(f.flag); value != "" {
+				opts.Set[f.directive] = value
+			}
+		}
+
+		set, _ := cmd.Flags().GetStringSlice("set")
+		for _, pair := range set {
+			key, value, found := strings.Cut(pair, "=")
+			if !found {
+				ui.Invalid("Invalid --set value: %s (expected directive=value)\n", pair)
+				return
+			}
+			opts.Set[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+		opts.Unset, _ = cmd.Flags().GetStringSlice("unset")
+		opts.Reset, _ = cmd.Flags().GetBool("reset")
+
+		domain.PHPSettings(args[0], opts)
 	},
 }
 
@@ -64,14 +585,130 @@ func init() {
 	rootCmd.AddCommand(domainCmd)
 	domainCmd.AddCommand(domainAddCmd)
 	domainCmd.AddCommand(domainEditCmd)
+	domainCmd.AddCommand(domainImportCmd)
+	domainCmd.AddCommand(domainApplyCmd)
 	domainCmd.AddCommand(domainDeleteCmd)
 	domainCmd.AddCommand(domainListCmd)
 	domainCmd.AddCommand(domainRebuildCmd)
+	domainCmd.AddCommand(domainBackupCmd)
+	domainCmd.AddCommand(domainRestoreCmd)
+	domainCmd.AddCommand(domainRewriteCmd)
+	domainRewriteCmd.AddCommand(domainRewriteAddCmd)
+	domainRewriteCmd.AddCommand(domainRewriteRemoveCmd)
+	domainRewriteCmd.AddCommand(domainRewriteListCmd)
+	domainCmd.AddCommand(domainRouteCmd)
+	domainRouteCmd.AddCommand(domainRouteAddCmd)
+	domainRouteCmd.AddCommand(domainRouteRemoveCmd)
+	domainRouteCmd.AddCommand(domainRouteListCmd)
+	domainCmd.AddCommand(domainHeadersCmd)
+	domainCmd.AddCommand(domainProtectCmd)
+	domainCmd.AddCommand(domainAccessCmd)
+	domainCmd.AddCommand(domainRateLimitCmd)
+	domainCmd.AddCommand(domainConfigCmd)
+	domainCmd.AddCommand(domainPHPSettingsCmd)
+	domainCmd.AddCommand(domainVarCmd)
+	domainVarCmd.AddCommand(domainVarSetCmd)
+	domainVarCmd.AddCommand(domainVarUnsetCmd)
+	domainVarCmd.AddCommand(domainVarListCmd)
+	domainConfigCmd.AddCommand(domainConfigEditCmd)
 
 	// Flags for domain add/edit
-	domainAddCmd.Flags().StringP("backend", "b", "", "Backend type: nginx or apache (default: nginx)")
-	domainAddCmd.Flags().StringP("php", "p", "", "PHP version (5.6-8.4)")
+	domainAddCmd.Flags().StringP("backend", "b", "", "Backend type: nginx, apache, static, proxy or caddy (default: defaults.backend, else nginx)")
+	domainAddCmd.Flags().StringP("php", "p", "", "PHP version (5.6-8.4, default: defaults.php, else 8.2)")
+	domainAddCmd.Flags().StringP("docroot", "d", "", "Web root subfolder relative to htdocs, e.g. public for Laravel/Symfony")
+	domainAddCmd.Flags().String("root", "", "Serve an absolute folder instead of htdocs, e.g. /home/app/site/public")
+	domainAddCmd.Flags().String("preset", "", "Framework preset: "+strings.Join(templates.ListPresets(), ", "))
+	domainAddCmd.Flags().String("http3", "", "HTTP/3 (QUIC) once SSL is enabled: on, off or default (follow http3)")
+	domainAddCmd.Flags().String("upstream", "", "App URL or upstream name for the proxy backend, e.g. http://127.0.0.1:3000 or api")
 
-	domainEditCmd.Flags().StringP("backend", "b", "", "Backend type: nginx or apache")
+	domainEditCmd.Flags().StringP("backend", "b", "", "Backend type: nginx, apache, static, proxy or caddy")
 	domainEditCmd.Flags().StringP("php", "p", "", "PHP version (5.6-8.4)")
+	domainEditCmd.Flags().StringP("docroot", "d", "", "Web root subfolder relative to htdocs (use . for htdocs itself)")
+	domainEditCmd.Flags().String("root", "", "Serve an existing absolute folder instead of htdocs (--docroot goes back to htdocs)")
+	domainEditCmd.Flags().String("hardening", "", "Deny rules for .git, .env, composer.lock, backups and node_modules: on, off or default (follow harden_webroot)")
+	domainEditCmd.Flags().String("http3", "", "HTTP/3 (QUIC) for the SSL vhost: on, off or default (follow http3)")
+	domainEditCmd.Flags().String("force-https", "", "Redirect HTTP to HTTPS once SSL is enabled: on, off or default (on)")
+	domainEditCmd.Flags().String("canonical", "", "Canonical host, the other name redirects to it: www, apex or none")
+	domainEditCmd.Flags().String("upstream", "", "App URL or upstream name for the proxy backend, e.g. http://127.0.0.1:3000 or api")
+
+	// Flags for domain delete
+	domainDeleteCmd.Flags().Bool("force", false, "Delete the domain folder without asking, also when WEBSTACK_FORBID_DESTRUCTIVE is set")
+
+	// Flags for domain import
+	domainImportCmd.Flags().Bool("scan", false, "Scan /etc/nginx/sites-enabled and /etc/apache2/sites-enabled")
+	domainImportCmd.Flags().BoolP("yes", "y", false, "Import every importable domain without asking")
+
+	// Flags for domain apply
+	domainApplyCmd.Flags().StringP("file", "f", "", "Manifest file (YAML or JSON)")
+	domainApplyCmd.MarkFlagRequired("file")
+	domainApplyCmd.Flags().Bool("plan", false, "Only show the changes")
+	domainApplyCmd.Flags().BoolP("yes", "y", false, "Apply without asking")
+	domainApplyCmd.Flags().Bool("prune", false, "Delete domains missing from the manifest")
+	domainApplyCmd.Flags().Bool("force", false, "Allow --prune to delete domains when WEBSTACK_FORBID_DESTRUCTIVE is set")
+
+	// Flags for domain rebuild-configs
+	domainRebuildCmd.Flags().Int("workers", 0, "Domains rendered in parallel (default: number of CPUs)")
+	domainRebuildCmd.Flags().Bool("force", false, "Rewrite every vhost, even when unchanged")
+	domainRebuildCmd.Flags().Bool("diff", false, "Show the changes to each vhost before writing it")
+
+	// Flags for domain backup/restore
+	domainBackupCmd.Flags().StringP("output", "o", "", "Archive path (default: /var/backups/webstack/domains/<domain>-<timestamp>.tar.gz)")
+	domainBackupCmd.Flags().StringSlice("database", []string{}, "Additional database to include, e.g. mysql:shop (repeatable)")
+	domainBackupCmd.Flags().Bool("no-databases", false, "Do not include databases")
+
+	domainRestoreCmd.Flags().BoolP("force", "f", false, "Overwrite an existing domain with the same name")
+	domainRestoreCmd.Flags().Bool("skip-databases", false, "Do not import databases")
+
+	// Flags for domain rewrite
+	domainRewriteAddCmd.Flags().String("from", "", "Source path, e.g. /old-page")
+	domainRewriteAddCmd.Flags().String("to", "", "Target path or URL, e.g. /new-page")
+	domainRewriteAddCmd.Flags().Int("code", 301, "Redirect status code: 301, 302, 307 or 308")
+	domainRewriteAddCmd.MarkFlagRequired("from")
+	domainRewriteAddCmd.MarkFlagRequired("to")
+
+	domainRewriteRemoveCmd.Flags().String("from", "", "Source path of the redirect to remove")
+	domainRewriteRemoveCmd.MarkFlagRequired("from")
+
+	// Flags for domain route
+	domainRouteAddCmd.Flags().String("path", "", "URL path, e.g. /blog")
+	domainRouteAddCmd.Flags().String("php", "", "PHP version for the path (default: the domain's)")
+	domainRouteAddCmd.Flags().String("root", "", "Folder served for the path (default: the matching folder of the document root)")
+	domainRouteAddCmd.MarkFlagRequired("path")
+
+	domainRouteRemoveCmd.Flags().String("path", "", "URL path of the route to remove")
+	domainRouteRemoveCmd.MarkFlagRequired("path")
+
+	// Flags for domain protect
+	domainProtectCmd.Flags().String("path", "/", "Path to protect, / for the whole site")
+	domainProtectCmd.Flags().String("user", "", "User to add, or to remove with --remove")
+	domainProtectCmd.Flags().String("password", "", "Password of the user (generated when omitted)")
+	domainProtectCmd.Flags().Bool("remove", false, "Remove the user, or the protection of the path without --user")
+
+	// Flags for domain ratelimit
+	domainRateLimitCmd.Flags().Int("rps", 0, "Requests per second per client IP")
+	domainRateLimitCmd.Flags().Int("burst", 0, "Requests over the rate served before 429 (default: twice --rps)")
+	domainRateLimitCmd.Flags().Int("conn", 0, "Concurrent connections per client IP (0 removes the limit)")
+	domainRateLimitCmd.Flags().Bool("disable", false, "Remove the limits")
+
+	// Flags for domain headers
+	domainHeadersCmd.Flags().String("hsts", "", "Strict-Transport-Security: on, off, subdomains, preload, default or a max-age=... value")
+	domainHeadersCmd.Flags().String("csp", "", "Content-Security-Policy value, off or default")
+	domainHeadersCmd.Flags().String("x-frame-options", "", "X-Frame-Options: DENY, SAMEORIGIN, off or default")
+	domainHeadersCmd.Flags().String("referrer-policy", "", "Referrer-Policy, e.g. strict-origin-when-cross-origin, off or default")
+	domainHeadersCmd.Flags().String("permissions-policy", "", "Permissions-Policy value, off or default")
+	domainHeadersCmd.Flags().Bool("reset", false, "Drop all overrides before applying the other flags")
+
+	// Flags for domain config
+	domainConfigEditCmd.Flags().String("server", "nginx", "Web server of the snippet: nginx, apache or caddy")
+	domainConfigEditCmd.Flags().String("name", "", "Snippet name, e.g. headers for configs/nginx-headers.conf (default: configs/nginx.conf)")
+
+	// Flags for domain php-settings
+	domainPHPSettingsCmd.Flags().String("memory-limit", "", "memory_limit, e.g. 512M")
+	domainPHPSettingsCmd.Flags().String("upload-max", "", "upload_max_filesize, e.g. 64M")
+	domainPHPSettingsCmd.Flags().String("post-max", "", "post_max_size, e.g. 64M")
+	domainPHPSettingsCmd.Flags().String("max-execution-time", "", "max_execution_time in seconds")
+	domainPHPSettingsCmd.Flags().String("max-input-vars", "", "max_input_vars")
+	domainPHPSettingsCmd.Flags().StringSlice("set", []string{}, "Any php.ini directive as directive=value (repeatable)")
+	domainPHPSettingsCmd.Flags().StringSlice("unset", []string{}, "Directive to drop back to the default (repeatable)")
+	domainPHPSettingsCmd.Flags().Bool("reset", false, "Remove all overrides and use the shared pool again")
 }
@@ -4,15 +4,22 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 
+	"webstack-cli/internal/firewall"
+	"webstack-cli/internal/prompt"
+	"webstack-cli/internal/ui"
+
 	"github.com/spf13/cobra"
 )
 
 var firewallCmd = &cobra.Command{
 	Use:   "firewall",
 	Short: "Firewall rules management",
-	Long:  `Manage firewall rules, view open ports, and control access to services.`,
+	Long: `Manage firewall rules, view open ports, and control access to services.
+Rules are applied with iptables, nftables, ufw or firewalld, detected at runtime; pick one
+with 'webstack config set firewall_backend <auto|iptables|nftables|ufw|firewalld>'.`,
 }
 
 var firewallStatusCmd = &cobra.Command{
@@ -27,30 +34,53 @@ var firewallStatusCmd = &cobra.Command{
 var firewallOpenPortCmd = &cobra.Command{
 	Use:   "open [port] [protocol]",
 	Short: "Open a port in the firewall",
-	Long:  `Open a specific port. Protocol can be 'tcp', 'udp', or 'both' (default: both).`,
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Open a specific port. Protocol can be 'tcp', 'udp', or 'both' (default: both).
+The port is recorded in /etc/webstack/firewall.json with the label "manual"
+(or --component), so it is re-opened by 'webstack firewall rebuild'.`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		port := args[0]
 		protocol := "both"
 		if len(args) > 1 {
 			protocol = args[1]
 		}
-		openFirewallPort(port, protocol)
+		component, _ := cmd.Flags().GetString("component")
+		openFirewallPort(args[0], protocol, component)
 	},
 }
 
 var firewallClosePortCmd = &cobra.Command{
 	Use:   "close [port] [protocol]",
 	Short: "Close a port in the firewall",
-	Long:  `Close a specific port. Protocol can be 'tcp', 'udp', or 'both' (default: both).`,
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Close a specific port. Protocol can be 'tcp', 'udp', or 'both' (default: both).
+A port still needed by an installed component (e.g. 80/443 for Nginx) is kept
+open unless --force is given.`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		port := args[0]
 		protocol := "both"
 		if len(args) > 1 {
 			protocol = args[1]
 		}
-		closeFirewallPort(port, protocol)
+		component, _ := cmd.Flags().GetString("component")
+		force, _ := cmd.Flags().GetBool("force")
+		closeFirewallPort(args[0], protocol, component, force)
+	},
+}
+
+var firewallListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the ports opened by webstack",
+	Long:  `List the ports recorded in /etc/webstack/firewall.json, the components that need them and whether the rules are present in the live firewall (iptables, nftables, ufw or firewalld).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		listFirewallRules()
+	},
+}
+
+var firewallRebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "Re-apply the registered firewall rules",
+	Long:  `Re-add the core rules (loopback, established connections, SSH) and every port recorded in /etc/webstack/firewall.json that is missing from the live firewall, e.g. after 'firewall flush' or a reboot.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		rebuildFirewallRules()
 	},
 }
 
@@ -88,7 +118,7 @@ var firewallFlushCmd = &cobra.Command{
 	Short: "Flush all custom firewall rules",
 	Long:  `Remove all custom firewall rules (keeps SSH and established connections).`,
 	Run: func(cmd *cobra.Command, args []string) {
-		confirmed := confirmAction("Are you sure you want to flush all firewall rules?")
+		confirmed := prompt.Destructive("flush the firewall rules", "Are you sure you want to flush all firewall rules?")
 		if confirmed {
 			flushFirewallRules()
 		} else {
@@ -102,7 +132,7 @@ var firewallRestoreCmd = &cobra.Command{
 	Short: "Restore default firewall configuration",
 	Long:  `Reset firewall to default WebStack configuration.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		confirmed := confirmAction("Are you sure you want to restore default firewall rules?")
+		confirmed := prompt.Destructive("replace the firewall rules with the defaults", "Are you sure you want to restore default firewall rules?")
 		if confirmed {
 			restoreDefaultFirewall()
 		} else {
@@ -145,201 +175,202 @@ func firewallStatus() {
 	fmt.Println("\n🔥 WebStack Firewall Status")
 	fmt.Println("═══════════════════════════════════════════")
 
-	// Show IPv4 rules
-	fmt.Println("\n📋 IPv4 Rules (iptables):")
-	fmt.Println("───────────────────────────────────────────")
-	output, err := exec.Command("iptables", "-L", "-n", "-v").Output()
-	if err != nil {
-		fmt.Printf("❌ Error reading IPv4 rules: %v\n", err)
-	} else {
-		fmt.Print(string(output))
+	fw := firewall.Current()
+	if !fw.Available() {
+		ui.Fail("❌ No firewall tool found (backend: %s). Install iptables, nftables, ufw or firewalld\n", fw.Name())
+		return
 	}
+	fmt.Printf("Backend: %s\n", fw.Name())
 
-	// Show IPv6 rules
-	fmt.Println("\n📋 IPv6 Rules (ip6tables):")
+	// Show the live rules
+	fmt.Printf("\n📋 Rules (%s):\n", fw.Name())
 	fmt.Println("───────────────────────────────────────────")
-	output6, err := exec.Command("ip6tables", "-L", "-n", "-v").Output()
+	ruleset, err := fw.Ruleset()
 	if err != nil {
-		fmt.Printf("❌ Error reading IPv6 rules: %v\n", err)
-	} else {
-		fmt.Print(string(output6))
+		ui.Fail("❌ Error reading rules: %v\n", err)
 	}
+	fmt.Print(ruleset)
 
 	// Show blocked IPs
-	fmt.Println("\n🚫 Blocked IP Addresses (ipset):")
+	fmt.Println("\n🚫 Blocked IP Addresses:")
 	fmt.Println("───────────────────────────────────────────")
-	ipsetOutput, err := exec.Command("ipset", "list", "banned_ips").Output()
-	if err != nil {
-		fmt.Println("No blocked IPs or ipset not available")
-	} else {
-		fmt.Print(string(ipsetOutput))
-	}
-}
+	printBlockedIPs(fw)
 
-func openFirewallPort(port, protocol string) {
-	fmt.Printf("🔓 Opening port %s (%s)...\n", port, protocol)
+	// Show the ports opened by webstack components
+	fmt.Println("\n📌 Registered Ports (/etc/webstack/firewall.json):")
+	fmt.Println("───────────────────────────────────────────")
+	listFirewallRules()
+}
 
-	protocols := []string{}
-	if protocol == "both" || protocol == "tcp" {
-		protocols = append(protocols, "tcp")
+func openFirewallPort(port, protocol, component string) {
+	portNum, err := strconv.Atoi(port)
+	if err != nil || portNum < 1 || portNum > 65535 {
+		ui.Invalid("Invalid port: %s\n", port)
+		return
 	}
-	if protocol == "both" || protocol == "udp" {
-		protocols = append(protocols, "udp")
+
+	fmt.Printf("🔓 Opening port %d (%s)...\n", portNum, protocol)
+	if err := firewall.Open(component, protocol, portNum); err != nil {
+		ui.Fail("❌ Error opening port: %v\n", err)
+		return
 	}
+	fmt.Printf("✅ Port %d (%s) opened and persisted\n", portNum, protocol)
+}
 
-	for _, proto := range protocols {
-		// IPv4
-		cmd := exec.Command("iptables", "-A", "INPUT", "-p", proto, "--dport", port, "-j", "ACCEPT")
-		if err := cmd.Run(); err != nil {
-			fmt.Printf("⚠️  IPv4 rule may already exist or error occurred: %v\n", err)
-		}
+func closeFirewallPort(port, protocol, component string, force bool) {
+	portNum, err := strconv.Atoi(port)
+	if err != nil || portNum < 1 || portNum > 65535 {
+		ui.Invalid("Invalid port: %s\n", port)
+		return
+	}
 
-		// IPv6
-		cmd6 := exec.Command("ip6tables", "-A", "INPUT", "-p", proto, "--dport", port, "-j", "ACCEPT")
-		if err := cmd6.Run(); err != nil {
-			fmt.Printf("⚠️  IPv6 rule may already exist or error occurred: %v\n", err)
+	fmt.Printf("🔒 Closing port %d (%s)...\n", portNum, protocol)
+	kept, err := firewall.Close(component, protocol, force, portNum)
+	if err != nil {
+		ui.Fail("❌ Error closing port: %v\n", err)
+		return
+	}
+	if len(kept) > 0 {
+		for _, r := range kept {
+			ui.Warn("⚠️  Port %d/%s kept open, still needed by: %s\n", r.Port, r.Protocol, strings.Join(r.Components, ", "))
 		}
+		fmt.Println("   Use --force to close it anyway")
+		return
 	}
-
-	// Persist rules
-	persistFirewallRules()
-	fmt.Printf("✅ Port %s (%s) opened and persisted\n", port, protocol)
+	fmt.Printf("✅ Port %d (%s) closed and persisted\n", portNum, protocol)
 }
 
-func closeFirewallPort(port, protocol string) {
-	fmt.Printf("🔒 Closing port %s (%s)...\n", port, protocol)
-
-	protocols := []string{}
-	if protocol == "both" || protocol == "tcp" {
-		protocols = append(protocols, "tcp")
+func listFirewallRules() {
+	rules, err := firewall.Rules()
+	if err != nil {
+		ui.Fail("❌ Error reading firewall registry: %v\n", err)
+		return
 	}
-	if protocol == "both" || protocol == "udp" {
-		protocols = append(protocols, "udp")
+	if len(rules) == 0 {
+		fmt.Println("No ports registered (open one with 'webstack firewall open <port>')")
+		return
 	}
 
-	for _, proto := range protocols {
-		// IPv4
-		cmd := exec.Command("iptables", "-D", "INPUT", "-p", proto, "--dport", port, "-j", "ACCEPT")
-		if err := cmd.Run(); err != nil {
-			fmt.Printf("⚠️  IPv4 rule may not exist: %v\n", err)
+	fmt.Println("Registered Firewall Rules:")
+	fmt.Println("─────────────────────────────────────────────────────────────────")
+	fmt.Printf("%-8s %-6s %-10s %s\n", "PORT", "PROTO", "STATE", "COMPONENTS")
+	fmt.Println("─────────────────────────────────────────────────────────────────")
+	missing := 0
+	for _, r := range rules {
+		if !r.Active {
+			missing++
 		}
+		fmt.Printf("%-8d %-6s %-10s %s\n", r.Port, r.Protocol, ruleState(r.Active), strings.Join(r.Components, ", "))
+	}
+	if missing > 0 {
+		ui.Warn("\n⚠️  %d rule(s) missing from %s, run 'sudo webstack firewall rebuild'\n", missing, firewall.Current().Name())
+	}
+}
 
-		// IPv6
-		cmd6 := exec.Command("ip6tables", "-D", "INPUT", "-p", proto, "--dport", port, "-j", "ACCEPT")
-		if err := cmd6.Run(); err != nil {
-			fmt.Printf("⚠️  IPv6 rule may not exist: %v\n", err)
-		}
+func ruleState(active bool) string {
+	if active {
+		return "active"
 	}
+	return "missing"
+}
 
-	// Persist rules
-	persistFirewallRules()
-	fmt.Printf("✅ Port %s (%s) closed and persisted\n", port, protocol)
+func rebuildFirewallRules() {
+	fmt.Println("🔄 Rebuilding firewall rules from /etc/webstack/firewall.json...")
+	added, err := firewall.Rebuild()
+	if err != nil {
+		ui.Fail("❌ Error rebuilding firewall rules: %v\n", err)
+		return
+	}
+	if added == 0 {
+		fmt.Println("✅ All registered rules are already active")
+		return
+	}
+	fmt.Printf("✅ %d rule(s) re-applied and persisted\n", added)
 }
 
 func blockIP(ip string) {
 	fmt.Printf("🚫 Blocking IP %s...\n", ip)
 
-	// Create ipset if not exists
-	exec.Command("ipset", "create", "banned_ips", "hash:ip", "forcreate").Run()
-
-	// Add IP to ipset
-	cmd := exec.Command("ipset", "add", "banned_ips", ip)
-	if err := cmd.Run(); err != nil {
-		fmt.Printf("❌ Error adding IP to blocklist: %v\n", err)
+	fw := firewall.Current()
+	if err := fw.Block(ip); err != nil {
+		ui.Fail("❌ Error adding IP to blocklist: %v\n", err)
 		return
 	}
 
-	// Add iptables rule to block the IP
-	exec.Command("iptables", "-A", "INPUT", "-m", "set", "--match-set", "banned_ips", "src", "-j", "DROP").Run()
-	exec.Command("ip6tables", "-A", "INPUT", "-m", "set", "--match-set", "banned_ips", "src", "-j", "DROP").Run()
-
 	// Persist
-	persistFirewallRules()
+	fw.Persist()
 	fmt.Printf("✅ IP %s blocked and persisted\n", ip)
 }
 
 func unblockIP(ip string) {
 	fmt.Printf("✅ Unblocking IP %s...\n", ip)
 
-	// Remove from ipset
-	cmd := exec.Command("ipset", "del", "banned_ips", ip)
-	if err := cmd.Run(); err != nil {
-		fmt.Printf("❌ Error removing IP from blocklist: %v\n", err)
+	fw := firewall.Current()
+	if err := fw.Unblock(ip); err != nil {
+		ui.Fail("❌ Error removing IP from blocklist: %v\n", err)
 		return
 	}
 
 	// Persist
-	persistFirewallRules()
+	fw.Persist()
 	fmt.Printf("✅ IP %s unblocked and persisted\n", ip)
 }
 
 func listBlockedIPs() {
 	fmt.Println("\n🚫 Blocked IP Addresses")
 	fmt.Println("═══════════════════════════════════════════")
+	printBlockedIPs(firewall.Current())
+}
 
-	output, err := exec.Command("ipset", "list", "banned_ips").Output()
-	if err != nil {
-		fmt.Println("No blocked IPs found or ipset not available")
+func printBlockedIPs(fw firewall.Firewall) {
+	ips, err := fw.Blocked()
+	if err != nil || len(ips) == 0 {
+		fmt.Println("No blocked IPs found")
 		return
 	}
-
-	fmt.Print(string(output))
+	for _, ip := range ips {
+		fmt.Println(ip)
+	}
 }
 
 func flushFirewallRules() {
 	fmt.Println("🧹 Flushing firewall rules...")
 
 	// Keep SSH and localhost, remove everything else
-	exec.Command("iptables", "-F", "INPUT").Run()
-	exec.Command("ip6tables", "-F", "INPUT").Run()
-
-	// Re-add core security rules
-	exec.Command("iptables", "-A", "INPUT", "-i", "lo", "-j", "ACCEPT").Run()
-	exec.Command("iptables", "-A", "INPUT", "-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "ACCEPT").Run()
-	exec.Command("iptables", "-A", "INPUT", "-p", "tcp", "--dport", "22", "-j", "ACCEPT").Run()
-
-	exec.Command("ip6tables", "-A", "INPUT", "-i", "lo", "-j", "ACCEPT").Run()
-	exec.Command("ip6tables", "-A", "INPUT", "-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "ACCEPT").Run()
-	exec.Command("ip6tables", "-A", "INPUT", "-p", "tcp", "--dport", "22", "-j", "ACCEPT").Run()
+	fw := firewall.Current()
+	if err := fw.Flush(); err != nil {
+		ui.Fail("❌ Error flushing rules: %v\n", err)
+		return
+	}
 
-	persistFirewallRules()
+	fw.Persist()
 	fmt.Println("✅ Firewall rules flushed (SSH and established connections preserved)")
+	fmt.Println("   Registered ports are kept in /etc/webstack/firewall.json; re-open them with 'sudo webstack firewall rebuild'")
 }
 
 func restoreDefaultFirewall() {
 	fmt.Println("🔄 Restoring default firewall configuration...")
 
-	// Flush all
-	exec.Command("iptables", "-F").Run()
-	exec.Command("ip6tables", "-F").Run()
-
-	// Set default policies
-	exec.Command("iptables", "-P", "INPUT", "DROP").Run()
-	exec.Command("iptables", "-P", "FORWARD", "DROP").Run()
-	exec.Command("iptables", "-P", "OUTPUT", "ACCEPT").Run()
-
-	exec.Command("ip6tables", "-P", "INPUT", "DROP").Run()
-	exec.Command("ip6tables", "-P", "FORWARD", "DROP").Run()
-	exec.Command("ip6tables", "-P", "OUTPUT", "ACCEPT").Run()
-
-	// Core security rules
-	for _, ipVersion := range []string{"iptables", "ip6tables"} {
-		ipt := ipVersion
-		// Allow localhost
-		exec.Command(ipt, "-A", "INPUT", "-i", "lo", "-j", "ACCEPT").Run()
-		// Allow established connections
-		exec.Command(ipt, "-A", "INPUT", "-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "ACCEPT").Run()
-		// Allow SSH
-		exec.Command(ipt, "-A", "INPUT", "-p", "tcp", "--dport", "22", "-j", "ACCEPT").Run()
+	// Drop incoming traffic by default, keeping localhost, established
+	// connections and SSH
+	fw := firewall.Current()
+	if err := fw.Reset(); err != nil {
+		ui.Fail("❌ Error restoring defaults: %v\n", err)
+		return
 	}
 
-	persistFirewallRules()
+	fw.Persist()
 	fmt.Println("✅ Firewall restored to default configuration")
+	fmt.Println("   Re-open the ports of installed components with 'sudo webstack firewall rebuild'")
 }
 
 func saveFirewallRules() {
 	fmt.Println("💾 Saving firewall rules...")
 
+	if !requireIptablesBackend() {
+		return
+	}
+
 	backupFile := "/etc/webstack/firewall-backup.tar.gz"
 
 	// Create backup directory if needed
@@ -353,7 +384,7 @@ func saveFirewallRules() {
 			"ip6tables-save > /etc/webstack/iptables-v6.backup")
 
 	if err := cmd.Run(); err != nil {
-		fmt.Printf("❌ Error saving rules: %v\n", err)
+		ui.Fail("❌ Error saving rules: %v\n", err)
 		return
 	}
 
@@ -363,16 +394,20 @@ func saveFirewallRules() {
 func loadFirewallRules(filePath string) {
 	fmt.Printf("📂 Loading firewall rules from %s...\n", filePath)
 
+	if !requireIptablesBackend() {
+		return
+	}
+
 	// Check if file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		fmt.Printf("❌ File not found: %s\n", filePath)
+		ui.Fail("❌ File not found: %s\n", filePath)
 		return
 	}
 
 	// Load IPv4 rules
 	cmd := exec.Command("iptables-restore", filePath)
 	if err := cmd.Run(); err != nil {
-		fmt.Printf("⚠️  Error loading IPv4 rules: %v\n", err)
+		ui.Warn("⚠️  Error loading IPv4 rules: %v\n", err)
 	}
 
 	// Try IPv6
@@ -380,65 +415,55 @@ func loadFirewallRules(filePath string) {
 	if _, err := os.Stat(ipv6File); err == nil {
 		cmd6 := exec.Command("ip6tables-restore", ipv6File)
 		if err := cmd6.Run(); err != nil {
-			fmt.Printf("⚠️  Error loading IPv6 rules: %v\n", err)
+			ui.Warn("⚠️  Error loading IPv6 rules: %v\n", err)
 		}
 	}
 
-	persistFirewallRules()
+	firewall.Persist()
 	fmt.Println("✅ Firewall rules loaded and persisted")
 }
 
+// requireIptablesBackend reports whether save/load can run: they work on
+// iptables-save files, nftables and ufw keep their own rule files
+func requireIptablesBackend() bool {
+	switch name := firewall.Current().Name(); name {
+	case "iptables":
+		return true
+	case "nftables":
+		ui.Warn("⚠️  save/load work with iptables rules; with nftables use 'nft list ruleset > file' and 'nft -f file'\n")
+	default:
+		ui.Warn("⚠️  save/load work with iptables rules; %s keeps its own rules in /etc/%s\n", name, name)
+	}
+	return false
+}
+
 func firewallStats() {
 	fmt.Println("\n📊 Firewall Statistics")
 	fmt.Println("═══════════════════════════════════════════")
 
-	fmt.Println("\n📈 IPv4 Statistics:")
+	fw := firewall.Current()
+	fmt.Printf("\n📈 Rule Counters (%s):\n", fw.Name())
 	fmt.Println("───────────────────────────────────────────")
-	output, err := exec.Command("iptables", "-L", "-n", "-v").Output()
+	ruleset, err := fw.Ruleset()
 	if err != nil {
-		fmt.Printf("❌ Error: %v\n", err)
+		ui.Fail("❌ Error: %v\n", err)
 	} else {
-		fmt.Print(string(output))
-	}
-
-	fmt.Println("\n📈 IPv6 Statistics:")
-	fmt.Println("───────────────────────────────────────────")
-	output6, err := exec.Command("ip6tables", "-L", "-n", "-v").Output()
-	if err != nil {
-		fmt.Printf("❌ Error: %v\n", err)
-	} else {
-		fmt.Print(string(output6))
+		fmt.Print(ruleset)
 	}
 
 	// Show ipset stats
-	fmt.Println("\n📈 ipset Statistics:")
-	fmt.Println("───────────────────────────────────────────")
-	ipsetOutput, err := exec.Command("ipset", "list").Output()
-	if err != nil {
-		fmt.Println("No ipsets available")
-	} else {
-		fmt.Print(string(ipsetOutput))
+	if fw.Name() == "iptables" {
+		fmt.Println("\n📈 ipset Statistics:")
+		fmt.Println("───────────────────────────────────────────")
+		ipsetOutput, err := exec.Command("ipset", "list").Output()
+		if err != nil {
+			fmt.Println("No ipsets available")
+		} else {
+			fmt.Print(string(ipsetOutput))
+		}
 	}
 }
 
-func persistFirewallRules() {
-	// Save IPv4 rules
-	exec.Command("bash", "-c", "iptables-save > /etc/iptables/rules.v4 2>/dev/null || true").Run()
-
-	// Save IPv6 rules
-	exec.Command("bash", "-c", "ip6tables-save > /etc/iptables/rules.v6 2>/dev/null || true").Run()
-
-	// Also save ipset rules
-	exec.Command("bash", "-c", "ipset save > /etc/iptables/ipset.rules 2>/dev/null || true").Run()
-}
-
-func confirmAction(message string) bool {
-	fmt.Print(message + " (yes/no): ")
-	var response string
-	fmt.Scanln(&response)
-	return strings.ToLower(response) == "yes" || strings.ToLower(response) == "y"
-}
-
 func init() {
 	rootCmd.AddCommand(firewallCmd)
 
@@ -453,4 +478,13 @@ func init() {
 	firewallCmd.AddCommand(firewallSaveCmd)
 	firewallCmd.AddCommand(firewallLoadCmd)
 	firewallCmd.AddCommand(firewallStatsCmd)
+	firewallCmd.AddCommand(firewallListCmd)
+	firewallCmd.AddCommand(firewallRebuildCmd)
+
+	// Flags for firewall open/close
+	firewallOpenPortCmd.Flags().String("component", firewall.Manual, "Component label recorded for the port")
+	firewallClosePortCmd.Flags().String("component", firewall.Manual, "Component whose claim on the port is removed")
+	firewallClosePortCmd.Flags().Bool("force", false, "Close the port even if other components need it")
+	firewallFlushCmd.Flags().Bool("force", false, "Flush without asking, also when WEBSTACK_FORBID_DESTRUCTIVE is set")
+	firewallRestoreCmd.Flags().Bool("force", false, "Restore without asking, also when WEBSTACK_FORBID_DESTRUCTIVE is set")
 }
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"os"
+
+	"webstack-cli/internal/ftp"
+	"webstack-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var ftpCmd = &cobra.Command{
+	Use:   "ftp",
+	Short: "Manage FTP/SFTP accounts of domains",
+	Long: `Manage FTP and SFTP accounts locked into a domain's /var/www/<domain> directory.
+Install the server first with 'webstack install ftp'.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var ftpUserCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Add, remove and list FTP/SFTP accounts",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var ftpUserAddCmd = &cobra.Command{
+	Use:   "add [domain] [user]",
+	Short: "Create an FTP/SFTP account for a domain",
+	Long: `Create a system user without a login shell whose home is /var/www/<domain>.
+It can use FTP with explicit TLS on port 21 or SFTP on the SSH port, and shares
+write access to htdocs with PHP-FPM through the www-data group. Examples:
+  webstack ftp user add example.com deploy
+  webstack ftp user add example.com deploy --password 'S3cret!'`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		password, _ := cmd.Flags().GetString("password")
+		ftp.AddUser(args[0], args[1], password)
+	},
+}
+
+var ftpUserDeleteCmd = &cobra.Command{
+	Use:   "delete [user]",
+	Short: "Remove an FTP/SFTP account",
+	Long: `Remove an FTP/SFTP account and end its open sessions. The domain files are kept.
+Example:
+  webstack ftp user delete deploy`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		ftp.DeleteUser(args[0])
+	},
+}
+
+var ftpUserPasswdCmd = &cobra.Command{
+	Use:   "passwd [user]",
+	Short: "Change the password of an FTP/SFTP account",
+	Long: `Set a new password, generated and printed unless --password is given. Examples:
+  webstack ftp user passwd deploy
+  webstack ftp user passwd deploy --password 'S3cret!'`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		password, _ := cmd.Flags().GetString("password")
+		ftp.SetPassword(args[0], password)
+	},
+}
+
+var ftpUserListCmd = &cobra.Command{
+	Use:   "list [domain]",
+	Short: "List FTP/SFTP accounts, optionally of one domain",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		domainName := ""
+		if len(args) == 1 {
+			domainName = args[0]
+		}
+		ftp.ListUsers(domainName)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(ftpCmd)
+	ftpCmd.AddCommand(ftpUserCmd)
+	ftpUserCmd.AddCommand(ftpUserAddCmd)
+	ftpUserCmd.AddCommand(ftpUserDeleteCmd)
+	ftpUserCmd.AddCommand(ftpUserPasswdCmd)
+	ftpUserCmd.AddCommand(ftpUserListCmd)
+
+	ftpUserAddCmd.Flags().String("password", "", "Account password (generated and printed when omitted)")
+	ftpUserPasswdCmd.Flags().String("password", "", "New password (generated and printed when omitted)")
+}
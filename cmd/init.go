@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"os"
+
+	"webstack-cli/internal/setup"
+	"webstack-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Guided first-run setup of a new server",
+	Long: `Walk a new server through its first-run setup in one guided flow:
+hostname, timezone, web server/database/PHP stack, admin email for Let's Encrypt,
+daily backups and the security baseline (firewall core rules, fail2ban, webroot
+hardening). The answers are saved to /etc/webstack/config.json and the stack can
+be installed right away. Nothing is changed until the summary is confirmed.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("This command requires root privileges (use sudo)\n")
+			return
+		}
+		setup.Run()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+}
@@ -1,7 +1,12 @@
 package cmd
 
 import (
+	"fmt"
+
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/ftp"
 	"webstack-cli/internal/installer"
+	"webstack-cli/internal/ui"
 
 	"github.com/spf13/cobra"
 )
@@ -9,14 +14,52 @@ import (
 var installCmd = &cobra.Command{
 	Use:   "install",
 	Short: "Install web stack components",
-	Long:  `Install and configure web servers, databases, and PHP-FPM versions.`,
+	Long: `Install and configure web servers, databases, and PHP-FPM versions.
+Use --resume to continue an interrupted 'install all' without answering every prompt again.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		resume, _ := cmd.Flags().GetBool("resume")
+		if resume {
+			installer.ResumeInstallAll()
+			return
+		}
+		if installer.HasInterruptedInstall() {
+			fmt.Println("ℹ️  A previous 'install all' was interrupted. Continue it with: sudo webstack install --resume")
+		}
+		cmd.Help()
+	},
 }
 
 var installAllCmd = &cobra.Command{
 	Use:   "all",
 	Short: "Install complete web stack with interactive prompts",
-	Long:  `Install Nginx, Apache, and interactively choose database and PHP options.`,
+	Long: `Install Nginx, Apache, and interactively choose database and PHP options.
+With --profile the components, versions and passwords come from a YAML file and
+nothing is asked, for cloud-init and image builds.
+Examples:
+  sudo webstack install all
+  sudo webstack install all --profile stack.yaml
+  sudo DB_ROOT_PASSWORD=secret webstack install all --profile stack.yaml`,
 	Run: func(cmd *cobra.Command, args []string) {
+		resume, _ := cmd.Flags().GetBool("resume")
+		profile, _ := cmd.Flags().GetString("profile")
+		installer.PurgeData, _ = cmd.Flags().GetBool("purge-data")
+		if profile != "" {
+			if resume {
+				ui.Fail("❌ --profile and --resume cannot be combined\n")
+				return
+			}
+			p, err := installer.LoadStackProfile(profile)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			installer.InstallStack(p)
+			return
+		}
+		if resume {
+			installer.ResumeInstallAll()
+			return
+		}
 		installer.InstallAll()
 	},
 }
@@ -55,6 +98,7 @@ var installMysqlCmd = &cobra.Command{
 	Long:  `Install MySQL database server. Optionally specify version (e.g., 5.7, 8.0, 8.1). Default: latest available.`,
 	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		installer.PurgeData, _ = cmd.Flags().GetBool("purge-data")
 		version := ""
 		if len(args) > 0 {
 			version = args[0]
@@ -69,6 +113,7 @@ var installMariadbCmd = &cobra.Command{
 	Long:  `Install MariaDB database server. Optionally specify version (e.g., 10.5, 10.6, 11.0). Default: latest available.`,
 	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		installer.PurgeData, _ = cmd.Flags().GetBool("purge-data")
 		version := ""
 		if len(args) > 0 {
 			version = args[0]
@@ -80,9 +125,17 @@ var installMariadbCmd = &cobra.Command{
 var installPostgresqlCmd = &cobra.Command{
 	Use:   "postgresql [version]",
 	Short: "Install PostgreSQL database server with optional version",
-	Long:  `Install PostgreSQL database server. Optionally specify version (e.g., 12, 13, 14, 15, 16). Default: latest available.`,
-	Args:  cobra.MaximumNArgs(1),
+	Long: `Install PostgreSQL database server. Optionally specify a major version (e.g., 15, 16, 17),
+installed from the PostgreSQL project's repository (PGDG) on Debian and Ubuntu, or a dnf
+module stream on RHEL. Default: the distribution's version.
+When PostgreSQL is already installed, another major version can be added next to the
+existing clusters; it gets its own cluster on the next free port (5433, ...).
+Examples:
+  sudo webstack install postgresql
+  sudo webstack install postgresql 17`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		installer.PurgeData, _ = cmd.Flags().GetBool("purge-data")
 		version := ""
 		if len(args) > 0 {
 			version = args[0]
@@ -91,12 +144,99 @@ var installPostgresqlCmd = &cobra.Command{
 	},
 }
 
+var installMongodbCmd = &cobra.Command{
+	Use:   "mongodb [version]",
+	Short: "Install MongoDB database server with optional version",
+	Long: `Install MongoDB from MongoDB's repository. Optionally specify the release series (6.0, 7.0, 8.0). Default: 8.0.
+An admin user with the root role is created and authentication is enabled; the password is
+saved in /etc/webstack/mongodb-admin-credentials.txt and the mongodb_admin_password config key.
+Examples:
+  sudo webstack install mongodb
+  sudo webstack install mongodb 7.0`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.PurgeData, _ = cmd.Flags().GetBool("purge-data")
+		version := ""
+		if len(args) > 0 {
+			version = args[0]
+		}
+		installer.InstallMongoDBVersion(version)
+	},
+}
+
 var installPhpCmd = &cobra.Command{
 	Use:   "php [version]",
 	Short: "Install PHP-FPM version (5.6-8.4)",
-	Args:  cobra.ExactArgs(1),
+	Long: `Install a PHP-FPM version. Packages come from ppa:ondrej/php on Ubuntu and
+packages.sury.org on Debian, which carry every supported version. With
+--no-external-repo the distribution's own PHP packages are used instead, which
+only offers the version the release ships (e.g. 8.2 on Debian 12, 8.3 on Ubuntu 24.04).
+Without a version the defaults.php setting is installed (8.2 when unset).`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		installer.InstallPHP(args[0])
+		noExternalRepo, _ := cmd.Flags().GetBool("no-external-repo")
+		version := config.FallbackPHPVersion
+		if len(args) == 1 {
+			version = args[0]
+		} else if cfg, err := config.Load(); err == nil {
+			version = cfg.DefaultPHPVersion()
+		}
+		installer.InstallPHPWithOptions(version, installer.PHPOptions{NoExternalRepo: noExternalRepo})
+	},
+}
+
+var installRedisCmd = &cobra.Command{
+	Use:   "redis",
+	Short: "Install Redis as an object cache for PHP",
+	Long: `Install Redis with a memory limit and an LRU eviction policy. PHP connects through
+the unix socket /run/redis/redis-server.sock; Redis also keeps listening on
+127.0.0.1:6379. Run it again with --memory to change the limit. Examples:
+  webstack install redis
+  webstack install redis --memory 512`,
+	Run: func(cmd *cobra.Command, args []string) {
+		memory, _ := cmd.Flags().GetInt("memory")
+		installer.InstallRedis(installer.ObjectCacheOptions{MemoryMB: memory})
+	},
+}
+
+var installCaddyCmd = &cobra.Command{
+	Use:   "caddy",
+	Short: "Install Caddy as a web server backend behind Nginx",
+	Long: `Install Caddy listening on 127.0.0.1:8082 for domains added with --backend caddy.
+Nginx keeps terminating SSL and proxies those domains to Caddy, which serves the files
+and PHP. Examples:
+  webstack install caddy
+  webstack domain add example.com --backend caddy`,
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.InstallCaddy()
+	},
+}
+
+var installMemcachedCmd = &cobra.Command{
+	Use:   "memcached",
+	Short: "Install Memcached as an object cache for PHP",
+	Long: `Install Memcached with a memory limit. It listens only on the unix socket
+/run/memcached/memcached.sock. Run it again with --memory to change the limit. Examples:
+  webstack install memcached
+  webstack install memcached --memory 128`,
+	Run: func(cmd *cobra.Command, args []string) {
+		memory, _ := cmd.Flags().GetInt("memory")
+		installer.InstallMemcached(installer.ObjectCacheOptions{MemoryMB: memory})
+	},
+}
+
+var installFtpCmd = &cobra.Command{
+	Use:   "ftp",
+	Short: "Install an FTP/SFTP server for domain accounts",
+	Long: `Install vsftpd with explicit TLS (FTPES) and enable SFTP in OpenSSH for accounts
+created with 'webstack ftp user add'. Accounts are chrooted to their domain and
+cannot log in with a shell. Port 21 and the passive ports are opened in the firewall.
+Examples:
+  webstack install ftp
+  webstack install ftp --passive-ports 50000-50099`,
+	Run: func(cmd *cobra.Command, args []string) {
+		passivePorts, _ := cmd.Flags().GetString("passive-ports")
+		installer.InstallFTP(ftp.InstallOptions{PassivePorts: passivePorts})
 	},
 }
 
@@ -117,6 +257,31 @@ func init() {
 	installCmd.AddCommand(installMysqlCmd)
 	installCmd.AddCommand(installMariadbCmd)
 	installCmd.AddCommand(installPostgresqlCmd)
+	installCmd.AddCommand(installMongodbCmd)
 	installCmd.AddCommand(installPhpCmd)
+	installCmd.AddCommand(installRedisCmd)
+	installCmd.AddCommand(installCaddyCmd)
+	installCmd.AddCommand(installMemcachedCmd)
+	installCmd.AddCommand(installFtpCmd)
 	installCmd.AddCommand(installMailCmd)
+
+	// Resume an interrupted 'install all'
+	installCmd.Flags().Bool("resume", false, "Resume an interrupted 'install all', skipping completed components")
+	installAllCmd.Flags().Bool("resume", false, "Resume an interrupted installation, skipping completed components")
+	installAllCmd.Flags().String("profile", "", "Install unattended from a profile file (YAML) declaring components, versions and passwords")
+
+	// Object cache memory limits
+	installRedisCmd.Flags().Int("memory", 0, "Memory limit in MB (default 256)")
+	installMemcachedCmd.Flags().Int("memory", 0, "Memory limit in MB (default 64)")
+
+	// FTP passive data ports
+	installFtpCmd.Flags().String("passive-ports", "", "Passive data port range (default "+ftp.DefaultPassivePorts+")")
+
+	// PHP package source
+	installPhpCmd.Flags().Bool("no-external-repo", false, "Use the distribution's PHP packages instead of ppa:ondrej/php or packages.sury.org")
+
+	// Existing database data is only deleted when confirmed or with --purge-data
+	for _, c := range []*cobra.Command{installAllCmd, installMysqlCmd, installMariadbCmd, installPostgresqlCmd, installMongodbCmd} {
+		c.Flags().Bool("purge-data", false, "Delete existing database data directories without asking (no dump is taken)")
+	}
 }
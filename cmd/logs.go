@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "View and rotate domain logs",
+	Long:  `Follow the access, error and PHP logs of a domain and rotate them. Logs are written to /var/www/<domain>/logs.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Use 'webstack logs --help' for available commands")
+	},
+}
+
+var logsTailCmd = &cobra.Command{
+	Use:   "tail [domain]",
+	Short: "Follow the logs of a domain",
+	Long: `Print the last lines of a domain's logs and follow them.
+Without a flag, the access and error logs are shown.
+
+Usage:
+  webstack logs tail example.com              # Access and error logs
+  webstack logs tail example.com --error      # Nginx (and Apache) error log
+  webstack logs tail example.com --php -n 200 # PHP-FPM errors`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var kinds []string
+		for _, kind := range []string{domain.LogAccess, domain.LogError, domain.LogPHP} {
+			if enabled, _ := cmd.Flags().GetBool(kind); enabled {
+				kinds = append(kinds, kind)
+			}
+		}
+		lines, _ := cmd.Flags().GetInt("lines")
+		noFollow, _ := cmd.Flags().GetBool("no-follow")
+		domain.TailLogs(args[0], kinds, lines, !noFollow)
+	},
+}
+
+var logsRotateCmd = &cobra.Command{
+	Use:   "rotate [domain]",
+	Short: "Rotate domain logs now",
+	Long: `Force a rotation of the logs of one domain, or of all domains.
+Logs are also rotated daily by the logrotate configuration deployed for each
+domain (/etc/logrotate.d/webstack-<domain>, 14 compressed files kept).`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+
+		domainName := ""
+		if len(args) == 1 {
+			domainName = args[0]
+		}
+		domain.RotateLogs(domainName)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+	logsCmd.AddCommand(logsTailCmd)
+	logsCmd.AddCommand(logsRotateCmd)
+
+	// Flags for logs tail
+	logsTailCmd.Flags().Bool("access", false, "Show the access log")
+	logsTailCmd.Flags().Bool("error", false, "Show the error log")
+	logsTailCmd.Flags().Bool("php", false, "Show the PHP-FPM error log")
+	logsTailCmd.Flags().IntP("lines", "n", 50, "Number of lines to show")
+	logsTailCmd.Flags().Bool("no-follow", false, "Print the lines and exit instead of following")
+}
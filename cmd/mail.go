@@ -1,7 +1,11 @@
 package cmd
 
 import (
+	"strings"
+	"webstack-cli/internal/app"
 	"webstack-cli/internal/installer"
+	"webstack-cli/internal/ssl"
+	"webstack-cli/internal/ui"
 
 	"github.com/spf13/cobra"
 )
@@ -19,10 +23,11 @@ var mailAddCmd = &cobra.Command{
 }
 
 var mailAccountCmd = &cobra.Command{
-	Use:   "account <email> <password>",
-	Short: "Add a mail account",
-	Long:  `Add a new mail account with format: webstack mail add account user@domain.tld password`,
-	Args:  cobra.ExactArgs(2),
+	Use:         "account <email> <password>",
+	Short:       "Add a mail account",
+	Long:        `Add a new mail account with format: webstack mail add account user@domain.tld password`,
+	Args:        cobra.ExactArgs(2),
+	Annotations: map[string]string{secretArgs: "1"},
 	Run: func(cmd *cobra.Command, args []string) {
 		installer.AddMailAccount(args[0], args[1])
 	},
@@ -38,6 +43,343 @@ var mailDomainCmd = &cobra.Command{
 	},
 }
 
+var mailPasswordCmd = &cobra.Command{
+	Use:         "password <email> [password]",
+	Short:       "Change the password of a mail account",
+	Annotations: map[string]string{secretArgs: "1"},
+	Long: `Change the password of a mail account. Without a password a random one is generated and
+printed. Passwords are stored as SHA512-CRYPT hashes in /etc/dovecot/users.
+  webstack mail password user@domain.tld
+  webstack mail password user@domain.tld NewSecret123`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		password := ""
+		if len(args) > 1 {
+			password = args[1]
+		}
+		installer.SetMailPassword(args[0], password)
+	},
+}
+
+var mailQuotaCmd = &cobra.Command{
+	Use:   "quota",
+	Short: "Manage mailbox quotas",
+	Long: `Manage mailbox quotas, enforced by the Dovecot quota plugin: mail over the quota is
+rejected on delivery. A domain's quota is the default for its accounts; an account's own
+quota takes precedence. Without a quota an account is unlimited.
+  webstack mail quota set user@domain.tld 2G
+  webstack mail quota set domain.tld 5G
+  webstack mail quota set user@domain.tld none
+  webstack mail quota report`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.MailQuotaReport()
+	},
+}
+
+var mailQuotaSetCmd = &cobra.Command{
+	Use:   "set <email|domain> <size>",
+	Short: "Set the quota of an account or the default quota of a domain",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.SetMailQuota(args[0], args[1])
+	},
+}
+
+var mailQuotaReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Show mailbox usage and quotas per account and domain",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.MailQuotaReport()
+	},
+}
+
+var mailAliasCmd = &cobra.Command{
+	Use:   "alias",
+	Short: "Manage mail aliases, forwarders and catch-alls",
+	Long: `Forward mail for an address to one or more local or external addresses, or catch the
+mail of every address of a domain without a mailbox with @domain. Aliases are kept in
+/etc/postfix/virtual.
+  webstack mail alias add info@domain.tld user@domain.tld
+  webstack mail alias add sales@domain.tld anna@domain.tld bob@example.org
+  webstack mail alias add @domain.tld admin@domain.tld
+  webstack mail alias delete info@domain.tld
+  webstack mail alias list domain.tld`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.ListMailAliases("")
+	},
+}
+
+var mailAliasAddCmd = &cobra.Command{
+	Use:   "add <alias|@domain> <destination>...",
+	Short: "Forward an address or a catch-all to one or more addresses",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.AddMailAlias(args[0], args[1:])
+	},
+}
+
+var mailAliasDeleteCmd = &cobra.Command{
+	Use:   "delete <alias|@domain> [destination]",
+	Short: "Delete an alias, or only one of its destinations",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		destination := ""
+		if len(args) > 1 {
+			destination = args[1]
+		}
+		installer.DeleteMailAlias(args[0], destination)
+	},
+}
+
+var mailAliasListCmd = &cobra.Command{
+	Use:   "list [domain]",
+	Short: "List aliases, optionally of one domain",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		domain := ""
+		if len(args) > 0 {
+			domain = args[0]
+		}
+		installer.ListMailAliases(domain)
+	},
+}
+
+var mailDKIMCmd = &cobra.Command{
+	Use:   "dkim",
+	Short: "Manage DKIM signing of outgoing mail",
+	Long: `Show the DKIM signing status. Outgoing mail of every mail domain is signed by OpenDKIM
+with the key created by 'webstack mail add domain' (selector default), wired into Postfix
+as milter for SMTP and sendmail submissions.
+  webstack mail dkim setup
+  webstack mail dkim verify domain.tld
+  webstack mail dkim verify domain.tld --to check@mail-tester.example`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.DKIMStatus()
+	},
+}
+
+var mailDKIMSetupCmd = &cobra.Command{
+	Use:   "setup",
+	Short: "Install OpenDKIM and sign the mail of all mail domains",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.SetupDKIM()
+	},
+}
+
+var mailDKIMVerifyCmd = &cobra.Command{
+	Use:   "verify <domain>",
+	Short: "Check the DKIM DNS record and that sent mail is signed",
+	Long: `Check that the default._domainkey record of a domain matches its key, then send a test
+message through Postfix and check that it carries a DKIM-Signature header. The message goes
+to a mailbox of the domain, or with --to to any address (e.g. a mail tester) whose headers
+you check yourself.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		to, _ := cmd.Flags().GetString("to")
+		installer.VerifyDKIM(args[0], to)
+	},
+}
+
+var mailSSLCmd = &cobra.Command{
+	Use:   "ssl <mail-hostname>",
+	Short: "Obtain a certificate for SMTP and IMAP",
+	Long: `Obtain a certificate for the mail host name and configure Postfix (smtpd_tls_cert_file)
+and Dovecot (ssl_cert/ssl_key) to use it. An existing certificate of the name is reused;
+otherwise one is issued by Let's Encrypt over HTTP-01, which only needs an A record of the
+name pointing to this server, or by the local CA for .local/.test names. Renewals reload
+Postfix and Dovecot.
+
+  sudo webstack mail ssl mail.example.com
+  sudo webstack mail ssl mail.example.com --email admin@example.com`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		email, _ := cmd.Flags().GetString("email")
+		certPath, keyPath, err := ssl.MailCertificate(args[0], email)
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		installer.ConfigureMailTLS(args[0], certPath, keyPath)
+	},
+}
+
+var mailWebmailCmd = &cobra.Command{
+	Use:   "webmail",
+	Short: "Webmail client management",
+	Long:  `Deploy a webmail client (Roundcube or SnappyMail) for the mail accounts.`,
+}
+
+var mailWebmailInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install a webmail client on its own domain",
+	Long: `Deploy a webmail client wired to the local IMAP (Dovecot) and SMTP (Postfix) servers.
+The domain is added when missing, the client gets its database (Roundcube) and the
+matching vhost rules, and SSL is enabled with Let's Encrypt, or the local CA for
+.local/.test names. Users log in with their mail address and password.
+
+Clients: ` + strings.Join(app.WebmailClients, ", ") + ` (default: roundcube)
+
+  sudo webstack mail webmail install --domain webmail.example.com
+  sudo webstack mail webmail install --domain webmail.example.com --client snappymail
+  sudo webstack mail webmail install --domain webmail.example.com --db-type postgresql --no-ssl`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		domainName, _ := cmd.Flags().GetString("domain")
+		client, _ := cmd.Flags().GetString("client")
+		backend, _ := cmd.Flags().GetString("backend")
+		phpVersion, _ := cmd.Flags().GetString("php")
+		dbType, _ := cmd.Flags().GetString("db-type")
+		email, _ := cmd.Flags().GetString("email")
+		noSSL, _ := cmd.Flags().GetBool("no-ssl")
+		force, _ := cmd.Flags().GetBool("force")
+		app.InstallWebmail(domainName, app.WebmailOptions{
+			Client:     client,
+			Backend:    backend,
+			PHPVersion: phpVersion,
+			DBType:     dbType,
+			Email:      email,
+			NoSSL:      noSSL,
+			Force:      force,
+		})
+	},
+}
+
+var mailMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Copy mailboxes from another IMAP server",
+	Long: `Copy the mail and folders of accounts on another IMAP server into local mail accounts
+with imapsync, which is installed when missing. The local accounts must exist; imapsync
+logs in to them with a temporary Dovecot master login, so their passwords are not needed.
+Running a migration again only copies new messages.
+
+A CSV file migrates many mailboxes, one per line: source,user,password,dest[,security]
+(security: ssl, starttls or none; a header line and # comments are skipped).
+
+  sudo webstack mail migrate --source imap.old.com --user a@example.com --dest a@example.com
+  sudo webstack mail migrate --source imap.old.com:143 --security starttls --user a --password secret --dest a@example.com
+  sudo webstack mail migrate --csv mailboxes.csv`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		csvPath, _ := cmd.Flags().GetString("csv")
+		source, _ := cmd.Flags().GetString("source")
+		security, _ := cmd.Flags().GetString("security")
+		user, _ := cmd.Flags().GetString("user")
+		password, _ := cmd.Flags().GetString("password")
+		dest, _ := cmd.Flags().GetString("dest")
+		if csvPath != "" {
+			if source != "" || user != "" || dest != "" {
+				ui.Invalid("Invalid options: --csv replaces --source, --user and --dest\n")
+				return
+			}
+			installer.MigrateMailboxesCSV(csvPath)
+			return
+		}
+		if source == "" || user == "" || dest == "" {
+			ui.Invalid("Invalid options: --source, --user and --dest are required (or --csv)\n")
+			return
+		}
+		installer.MigrateMailbox(installer.MailMigration{
+			Source:   source,
+			Security: security,
+			User:     user,
+			Password: password,
+			Dest:     dest,
+		})
+	},
+}
+
+var mailQueueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Show and manage the Postfix mail queue",
+	Long: `Show the messages waiting in the Postfix queue with their age, sender, recipients and
+why delivery is delayed, and retry, hold, release or delete them.
+
+  webstack mail queue                   # same as 'mail queue list'
+  webstack mail queue list --queue deferred
+  sudo webstack mail queue flush
+  sudo webstack mail queue hold 4BF3C1A2B9
+  sudo webstack mail queue delete 4BF3C1A2B9
+  sudo webstack mail queue delete --all --queue deferred`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.ListMailQueue("")
+	},
+}
+
+var mailQueueListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the queued messages",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		queue, _ := cmd.Flags().GetString("queue")
+		installer.ListMailQueue(queue)
+	},
+}
+
+var mailQueueFlushCmd = &cobra.Command{
+	Use:   "flush [id...]",
+	Short: "Retry delivery of the queued messages now",
+	Long:  `Retry delivery of all deferred messages, or only of the given queue IDs: sudo webstack mail queue flush`,
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.FlushMailQueue(args)
+	},
+}
+
+var mailQueueDeleteCmd = &cobra.Command{
+	Use:   "delete [id...]",
+	Short: "Delete queued messages",
+	Long: `Delete queued messages by queue ID, or with --all every message (of one queue with --queue).
+
+  sudo webstack mail queue delete 4BF3C1A2B9
+  sudo webstack mail queue delete --all --queue deferred`,
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		queue, _ := cmd.Flags().GetString("queue")
+		if all && len(args) > 0 {
+			ui.Invalid("Invalid arguments: give queue IDs or --all, not both\n")
+			return
+		}
+		installer.DeleteQueuedMail(args, all, queue)
+	},
+}
+
+var mailQueueHoldCmd = &cobra.Command{
+	Use:   "hold <id>...",
+	Short: "Hold queued messages until they are released",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.HoldQueuedMail(args, false)
+	},
+}
+
+var mailQueueReleaseCmd = &cobra.Command{
+	Use:   "release <id>...",
+	Short: "Release held messages for delivery",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.HoldQueuedMail(args, true)
+	},
+}
+
+var mailVerifyCmd = &cobra.Command{
+	Use:   "verify <domain>",
+	Short: "Check the live mail DNS records of a domain",
+	Long: `Query the live DNS of a mail domain and check its MX, SPF, DKIM and DMARC records
+against the ones generated in /etc/postfix/dns-records, and the reverse DNS (PTR) of the
+server address. Run it before sending mail from a new domain.
+
+  webstack mail verify mydomain.tld`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.VerifyMailDNS(args[0])
+	},
+}
+
 var mailListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List mail accounts and domains",
@@ -151,6 +493,15 @@ func init() {
 
 	// Add subcommands
 	mailCmd.AddCommand(mailAddCmd)
+	mailCmd.AddCommand(mailPasswordCmd)
+	mailCmd.AddCommand(mailQuotaCmd)
+	mailCmd.AddCommand(mailAliasCmd)
+	mailCmd.AddCommand(mailDKIMCmd)
+	mailCmd.AddCommand(mailSSLCmd)
+	mailCmd.AddCommand(mailWebmailCmd)
+	mailCmd.AddCommand(mailMigrateCmd)
+	mailCmd.AddCommand(mailQueueCmd)
+	mailCmd.AddCommand(mailVerifyCmd)
 	mailCmd.AddCommand(mailListCmd)
 	mailCmd.AddCommand(mailDeleteCmd)
 	mailCmd.AddCommand(mailShowDNSCmd)
@@ -161,6 +512,51 @@ func init() {
 	mailAddCmd.AddCommand(mailAccountCmd)
 	mailAddCmd.AddCommand(mailDomainCmd)
 
+	// Mail quota subcommands
+	mailQuotaCmd.AddCommand(mailQuotaSetCmd)
+	mailQuotaCmd.AddCommand(mailQuotaReportCmd)
+
+	// Mail alias subcommands
+	mailAliasCmd.AddCommand(mailAliasAddCmd)
+	mailAliasCmd.AddCommand(mailAliasDeleteCmd)
+	mailAliasCmd.AddCommand(mailAliasListCmd)
+
+	// Mail DKIM subcommands
+	mailDKIMCmd.AddCommand(mailDKIMSetupCmd)
+	mailDKIMCmd.AddCommand(mailDKIMVerifyCmd)
+	mailDKIMVerifyCmd.Flags().String("to", "", "Send the test message to this address instead of a mailbox of the domain")
+
+	mailSSLCmd.Flags().String("email", "", "Email address for Let's Encrypt (default: defaults.ssl_email)")
+
+	// Mail webmail subcommands
+	mailWebmailCmd.AddCommand(mailWebmailInstallCmd)
+	mailWebmailInstallCmd.Flags().String("domain", "", "Domain serving the webmail, e.g. webmail.example.com")
+	mailWebmailInstallCmd.Flags().String("client", "roundcube", "Webmail client: roundcube or snappymail")
+	mailWebmailInstallCmd.Flags().String("backend", "", "Backend of a new domain: nginx or apache (default: defaults.backend)")
+	mailWebmailInstallCmd.Flags().String("php", "", "PHP version of a new domain (default: defaults.php)")
+	mailWebmailInstallCmd.Flags().String("db-type", "mysql", "Roundcube database type: mysql or postgresql")
+	mailWebmailInstallCmd.Flags().String("email", "", "Email address for Let's Encrypt (default: defaults.ssl_email)")
+	mailWebmailInstallCmd.Flags().Bool("no-ssl", false, "Do not enable SSL on the domain")
+	mailWebmailInstallCmd.Flags().Bool("force", false, "Install even if htdocs already contains files")
+	mailWebmailInstallCmd.MarkFlagRequired("domain")
+
+	mailMigrateCmd.Flags().String("source", "", "IMAP server of the old mailbox, optionally host:port")
+	mailMigrateCmd.Flags().String("security", "ssl", "Connection to the old server: ssl (port 993), starttls or none (port 143)")
+	mailMigrateCmd.Flags().String("user", "", "Login on the old server")
+	mailMigrateCmd.Flags().String("password", "", "Password on the old server (prompted when empty)")
+	mailMigrateCmd.Flags().String("dest", "", "Local mail account receiving the mail")
+	mailMigrateCmd.Flags().String("csv", "", "CSV file listing mailboxes: source,user,password,dest[,security]")
+
+	// Mail queue subcommands
+	mailQueueCmd.AddCommand(mailQueueListCmd)
+	mailQueueCmd.AddCommand(mailQueueFlushCmd)
+	mailQueueCmd.AddCommand(mailQueueDeleteCmd)
+	mailQueueCmd.AddCommand(mailQueueHoldCmd)
+	mailQueueCmd.AddCommand(mailQueueReleaseCmd)
+	mailQueueListCmd.Flags().String("queue", "", "Only list one queue: active, deferred, hold or incoming")
+	mailQueueDeleteCmd.Flags().Bool("all", false, "Delete every queued message")
+	mailQueueDeleteCmd.Flags().String("queue", "", "With --all, only delete the messages of this queue")
+
 	// Mail list subcommands
 	mailListCmd.AddCommand(mailListAccountsCmd)
 	mailListCmd.AddCommand(mailListDomainsCmd)
@@ -168,6 +564,7 @@ func init() {
 	// Mail delete subcommands
 	mailDeleteCmd.AddCommand(mailDeleteAccountCmd)
 	mailDeleteCmd.AddCommand(mailDeleteDomainCmd)
+	mailDeleteCmd.PersistentFlags().Bool("force", false, "Delete without asking, also when WEBSTACK_FORBID_DESTRUCTIVE is set")
 
 	// Mail DNS subcommands
 	mailDNSCmd.AddCommand(mailDNSShowCmd)
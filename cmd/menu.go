@@ -2,8 +2,10 @@ package cmd
 
 import (
     "fmt"
+    "strings"
     "github.com/spf13/cobra"
     "webstack-cli/internal/installer"
+    "webstack-cli/internal/ui"
 )
 
 var menuCmd = &cobra.Command{
@@ -17,24 +19,29 @@ var menuCmd = &cobra.Command{
         fmt.Printf("%-12s %-12s %-8s\n", "Component", "Installed", "Running")
         fmt.Println("---------------------------------------------")
 
-        green := "\033[32m"
-        red := "\033[31m"
-        reset := "\033[0m"
-
         // Display main components
         for name, s := range statuses {
             inst := "no"
             if s.DpkgInstalled {
                 inst = "yes"
             }
-            running := fmt.Sprintf("%s%s%s", red, "stopped", reset)
+            running := ui.Red("stopped")
             if s.ServiceRunning {
-                running = fmt.Sprintf("%s%s%s", green, "running", reset)
+                running = ui.Green("running")
+            } else if !s.HasService {
+                running = "-"
             }
 
             fmt.Printf("%-12s %-12s %-8s\n", name, inst, running)
         }
 
+        // Installed components whose requirements are missing
+        for name, s := range statuses {
+            if len(s.MissingDeps) > 0 {
+                ui.Warn("⚠️  %s needs %s\n", name, strings.Join(s.MissingDeps, ", "))
+            }
+        }
+
         // Display PHP versions if any are installed
         hasPhp := false
         for _, s := range phpVersions {
@@ -53,9 +60,9 @@ var menuCmd = &cobra.Command{
                 if s.DpkgInstalled {
                     inst = "yes"
                 }
-                running := fmt.Sprintf("%s%s%s", red, "stopped", reset)
+                running := ui.Red("stopped")
                 if s.ServiceRunning {
-                    running = fmt.Sprintf("%s%s%s", green, "running", reset)
+                    running = ui.Green("running")
                 }
 
                 fmt.Printf("%-12s %-12s %-8s\n", name, inst, running)
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"webstack-cli/internal/monitor"
+	"webstack-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var monitorCmd = &cobra.Command{
+	Use:   "monitor",
+	Short: "Check the server periodically and alert on problems",
+	Long: `The monitor is a systemd service (webstack-monitor) running this binary. Every
+monitor.interval seconds it requests each domain from the local web server and checks
+that enabled services are running, disk usage, certificate expiry and PHP-FPM pool
+saturation. Checks that start failing or recover are sent to the channels set up with
+'webstack notify'.
+
+Thresholds are read from 'webstack config' each round:
+  monitor.interval        seconds between rounds (default 60)
+  monitor.disk_threshold  used disk space in percent (default 90)
+  monitor.cert_days       days before certificate expiry (default 14)
+
+Examples:
+  sudo webstack monitor enable
+  sudo webstack config set monitor.disk_threshold 85
+  sudo webstack monitor status
+  sudo webstack monitor run --once`,
+}
+
+var monitorEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Install and start the monitor service",
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		if err := monitor.Enable(); err != nil {
+			ui.Fail("❌ Could not enable the monitor: %v\n", err)
+			return
+		}
+		fmt.Println("✅ Monitor enabled (webstack-monitor.service)")
+		fmt.Println("💡 Alerts go to the channels from 'webstack notify list'")
+	},
+}
+
+var monitorDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Stop and remove the monitor service",
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		if err := monitor.Disable(); err != nil {
+			ui.Fail("❌ Could not disable the monitor: %v\n", err)
+			return
+		}
+		fmt.Println("✅ Monitor disabled")
+	},
+}
+
+var monitorStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the results of the last checks",
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		if enabled, active := monitor.Enabled(); enabled {
+			fmt.Printf("🩺 Monitor service: %s\n", active)
+		} else {
+			fmt.Println("🩺 Monitor service: not enabled (sudo webstack monitor enable)")
+		}
+
+		state, err := monitor.Status()
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		if state.CheckedAt.IsZero() {
+			fmt.Println("ℹ️  No checks recorded yet")
+			return
+		}
+		fmt.Printf("Last checked %s\n\n", state.CheckedAt.Format("2006-01-02 15:04:05"))
+		printMonitorResults(state.Sorted(), true)
+	},
+}
+
+var monitorRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run the checks in the foreground",
+	Long: `Run the checks every monitor.interval seconds until stopped. This is what the
+monitor service runs; use --once to run a single round and print the results.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		once, _ := cmd.Flags().GetBool("once")
+		if !once {
+			monitor.Run()
+			return
+		}
+		printMonitorResults(monitor.RunOnce(), false)
+	},
+}
+
+func printMonitorResults(results []monitor.Result, since bool) {
+	if len(results) == 0 {
+		fmt.Println("ℹ️  Nothing to check")
+		return
+	}
+	failing := 0
+	for _, r := range results {
+		icon := "✅"
+		if !r.OK {
+			icon = "❌"
+			failing++
+		}
+		line := fmt.Sprintf("%s %-8s %-30s %s", icon, r.Check, r.Target, r.Message)
+		if since && !r.OK {
+			line += fmt.Sprintf(" (since %s)", r.Since.Format(time.RFC822))
+		}
+		if r.OK {
+			fmt.Println(line)
+		} else {
+			ui.Fail("%s\n", line)
+		}
+	}
+	fmt.Printf("\n%d checks, %d failing\n", len(results), failing)
+}
+
+func init() {
+	rootCmd.AddCommand(monitorCmd)
+	monitorCmd.AddCommand(monitorEnableCmd)
+	monitorCmd.AddCommand(monitorDisableCmd)
+	monitorCmd.AddCommand(monitorStatusCmd)
+	monitorCmd.AddCommand(monitorRunCmd)
+
+	monitorRunCmd.Flags().Bool("once", false, "Run one round of checks and exit")
+}
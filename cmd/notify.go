@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"webstack-cli/internal/notify"
+	"webstack-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Send lifecycle events to Slack, Discord, webhooks or email",
+	Long: `Manage the notification channels in /etc/webstack/notify.json. Channels receive
+certificate renewals and failures, stopped services, backups and domain changes;
+see 'webstack notify events'.`,
+}
+
+var notifyAddCmd = &cobra.Command{
+	Use:   "add [name]",
+	Short: "Add or replace a notification channel",
+	Long: `Add a channel, or replace the channel with the same name.
+Examples:
+  sudo webstack notify add ops --type slack --url https://hooks.slack.com/services/...
+  sudo webstack notify add alerts --type discord --url https://discord.com/api/webhooks/... --events ssl.failed,service.down
+  sudo webstack notify add panel --type webhook --url https://panel.example.com/hook --secret s3cret
+  sudo webstack notify add admins --type email --to admin@example.com,ops@example.com`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		chType, _ := cmd.Flags().GetString("type")
+		chURL, _ := cmd.Flags().GetString("url")
+		secret, _ := cmd.Flags().GetString("secret")
+		to, _ := cmd.Flags().GetStringSlice("to")
+		events, _ := cmd.Flags().GetStringSlice("events")
+
+		ch := notify.Channel{Name: args[0], Type: chType, URL: chURL, Secret: secret, To: to, Events: events}
+		if err := ch.Validate(); err != nil {
+			ui.Invalid("Invalid channel: %v\n", err)
+			return
+		}
+
+		replaced := false
+		err := notify.Update(func(cfg *notify.Config) error {
+			for i := range cfg.Channels {
+				if cfg.Channels[i].Name == ch.Name {
+					cfg.Channels[i] = ch
+					replaced = true
+					return nil
+				}
+			}
+			cfg.Channels = append(cfg.Channels, ch)
+			return nil
+		})
+		if err != nil {
+			ui.Fail("❌ Could not save the channel: %v\n", err)
+			return
+		}
+		if replaced {
+			fmt.Printf("✅ Channel %s updated\n", ch.Name)
+		} else {
+			fmt.Printf("✅ Channel %s added\n", ch.Name)
+		}
+		fmt.Printf("💡 Send a test with: sudo webstack notify test %s\n", ch.Name)
+	},
+}
+
+var notifyRemoveCmd = &cobra.Command{
+	Use:   "remove [name]",
+	Short: "Remove a notification channel",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		found := false
+		err := notify.Update(func(cfg *notify.Config) error {
+			for i := range cfg.Channels {
+				if cfg.Channels[i].Name == args[0] {
+					cfg.Channels = append(cfg.Channels[:i], cfg.Channels[i+1:]...)
+					found = true
+					return nil
+				}
+			}
+			return fmt.Errorf("channel %s not found", args[0])
+		})
+		if !found {
+			ui.Fail("❌ Channel %s not found\n", args[0])
+			return
+		}
+		if err != nil {
+			ui.Fail("❌ Could not remove the channel: %v\n", err)
+			return
+		}
+		fmt.Printf("✅ Channel %s removed\n", args[0])
+	},
+}
+
+var notifyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the notification channels",
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		cfg, err := notify.Load()
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		if len(cfg.Channels) == 0 {
+			fmt.Println("ℹ️  No notification channels configured")
+			fmt.Println("   Add one with: sudo webstack notify add <name> --type slack --url ...")
+			return
+		}
+		fmt.Printf("%-14s %-8s %-40s %s\n", "NAME", "TYPE", "DESTINATION", "EVENTS")
+		for _, ch := range cfg.Channels {
+			destination := strings.Join(ch.To, ", ")
+			if ch.Type != "email" {
+				destination = maskURL(ch.URL)
+			}
+			events := strings.Join(ch.Events, ",")
+			if events == "" {
+				events = "all"
+			}
+			fmt.Printf("%-14s %-8s %-40s %s\n", ch.Name, ch.Type, destination, events)
+		}
+		if cfg.SMTP.Host != "" {
+			fmt.Printf("\n📧 SMTP: %s:%d (from %s)\n", cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.From)
+		}
+	},
+}
+
+var notifyTestCmd = &cobra.Command{
+	Use:   "test [name]",
+	Short: "Send a test notification to one channel or all of them",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		cfg, err := notify.Load()
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		sent := 0
+		for _, ch := range cfg.Channels {
+			if len(args) == 1 && ch.Name != args[0] {
+				continue
+			}
+			sent++
+			if err := notify.Test(cfg, ch); err != nil {
+				ui.Fail("❌ %s: %v\n", ch.Name, err)
+				continue
+			}
+			fmt.Printf("✅ %s: test notification sent\n", ch.Name)
+		}
+		if sent == 0 {
+			ui.Fail("❌ No matching notification channel\n")
+		}
+	},
+}
+
+var notifyEventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "List the events channels can subscribe to",
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, e := range notify.Events {
+			fmt.Printf("%-18s %s\n", e.Name, e.Description)
+		}
+	},
+}
+
+var notifySMTPCmd = &cobra.Command{
+	Use:   "smtp",
+	Short: "Set the mail server used by email channels",
+	Long: `Set the SMTP server email channels send through. Without one, mail is handed to the
+local sendmail (Postfix from 'webstack install mail').
+Examples:
+  sudo webstack notify smtp --host smtp.example.com --port 587 --user alerts@example.com --password ... --from alerts@example.com
+  sudo webstack notify smtp --clear`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		clear, _ := cmd.Flags().GetBool("clear")
+		host, _ := cmd.Flags().GetString("host")
+		port, _ := cmd.Flags().GetInt("port")
+		user, _ := cmd.Flags().GetString("user")
+		password, _ := cmd.Flags().GetString("password")
+		from, _ := cmd.Flags().GetString("from")
+		if !clear && host == "" {
+			ui.Invalid("Invalid options: give --host, or --clear to use the local sendmail\n")
+			return
+		}
+
+		err := notify.Update(func(cfg *notify.Config) error {
+			if clear {
+				cfg.SMTP = notify.SMTP{}
+				return nil
+			}
+			cfg.SMTP = notify.SMTP{Host: host, Port: port, Username: user, Password: password, From: from}
+			return nil
+		})
+		if err != nil {
+			ui.Fail("❌ Could not save the SMTP settings: %v\n", err)
+			return
+		}
+		if clear {
+			fmt.Println("✅ Email channels now use the local sendmail")
+			return
+		}
+		fmt.Printf("✅ Email channels now send through %s\n", host)
+	},
+}
+
+// maskURL hides the path of a webhook URL, which usually holds its secret
+func maskURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "***"
+	}
+	return u.Scheme + "://" + u.Host + "/***"
+}
+
+func init() {
+	rootCmd.AddCommand(notifyCmd)
+	notifyCmd.AddCommand(notifyAddCmd)
+	notifyCmd.AddCommand(notifyRemoveCmd)
+	notifyCmd.AddCommand(notifyListCmd)
+	notifyCmd.AddCommand(notifyTestCmd)
+	notifyCmd.AddCommand(notifyEventsCmd)
+	notifyCmd.AddCommand(notifySMTPCmd)
+
+	notifyAddCmd.Flags().String("type", "", "Channel type: slack, discord, webhook or email")
+	notifyAddCmd.Flags().String("url", "", "Webhook URL (slack, discord, webhook)")
+	notifyAddCmd.Flags().String("secret", "", "Sign generic webhook payloads with HMAC-SHA256 (X-Webstack-Signature header)")
+	notifyAddCmd.Flags().StringSlice("to", []string{}, "Email recipients (email)")
+	notifyAddCmd.Flags().StringSlice("events", []string{}, "Events to send (default: all, see 'webstack notify events')")
+
+	notifySMTPCmd.Flags().String("host", "", "SMTP server")
+	notifySMTPCmd.Flags().Int("port", 587, "SMTP port (STARTTLS is used when offered)")
+	notifySMTPCmd.Flags().String("user", "", "SMTP username")
+	notifySMTPCmd.Flags().String("password", "", "SMTP password")
+	notifySMTPCmd.Flags().String("from", "", "Sender address (default webstack@<hostname>)")
+	notifySMTPCmd.Flags().Bool("clear", false, "Remove the SMTP server and use the local sendmail")
+}
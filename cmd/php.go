@@ -0,0 +1,320 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/phpfpm"
+	"webstack-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var phpCmd = &cobra.Command{
+	Use:   "php",
+	Short: "Inspect and tune PHP-FPM",
+	Long:  `Inspect and tune the PHP-FPM pools of the installed PHP versions.`,
+}
+
+var phpStatusCmd = &cobra.Command{
+	Use:   "status [version]",
+	Short: "Show PHP-FPM workers, listen queue and slow requests per pool",
+	Long: `Show the workers, listen queue and recent slow requests of every PHP-FPM pool of a
+PHP version, or of all installed versions. The status is read from each pool's
+pm.status_path over its socket, and slow requests (over 5 seconds) from its slowlog.
+
+Pools written by older versions have no status page or slowlog; update the shared
+pool with 'webstack install php <version>' (keep the installation) and the domain
+pools with 'webstack domain rebuild-configs'.
+
+Examples:
+  sudo webstack php status
+  sudo webstack php status 8.3 --slow 20`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		slow, _ := cmd.Flags().GetInt("slow")
+
+		versions := phpfpm.Versions()
+		if len(args) == 1 {
+			if _, err := oneOf(phpVersions...)(args[0]); err != nil {
+				ui.Invalid("Invalid PHP version %s: %v\n", args[0], err)
+				return
+			}
+			versions = []string{args[0]}
+		}
+		if len(versions) == 0 {
+			ui.Fail("❌ No PHP-FPM installation found (use 'webstack install php <version>')\n")
+			return
+		}
+
+		for _, version := range versions {
+			pools, err := phpfpm.Pools(version)
+			if err != nil {
+				ui.Fail("❌ %v\n", err)
+				continue
+			}
+			for _, pool := range pools {
+				printPoolStatus(pool, slow)
+			}
+		}
+	},
+}
+
+var phpTuneCmd = &cobra.Command{
+	Use:   "tune [version]",
+	Short: "Set the process manager of a PHP-FPM pool",
+	Long: `Set pm and the worker limits of the shared webstack pool of a PHP version, or of
+the pool of a domain with its own PHP settings (--domain). The pool is validated with
+php-fpm -t and PHP-FPM is reloaded. Without options the current values are shown.
+
+--auto computes pm.max_children from the memory available to PHP-FPM (80% of the
+available memory plus what the running workers use, shared by all pools) and the
+average size of the pool's running workers; explicit options override the result.
+The values are kept when the installer or 'domain rebuild-configs' rewrite the pool.
+
+Examples:
+  sudo webstack php tune 8.3
+  sudo webstack php tune 8.3 --auto
+  sudo webstack php tune 8.3 --pm dynamic --max-children 20 --max-requests 1000
+  sudo webstack php tune 8.3 --domain example.com --pm ondemand --max-children 10`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		version := args[0]
+		if _, err := oneOf(phpVersions...)(version); err != nil {
+			ui.Invalid("Invalid PHP version %s: %v\n", version, err)
+			return
+		}
+		domainName, _ := cmd.Flags().GetString("domain")
+		auto, _ := cmd.Flags().GetBool("auto")
+		pm, _ := cmd.Flags().GetString("pm")
+
+		poolName := "webstack"
+		if domainName != "" {
+			poolName = domainName
+		}
+		pools, err := phpfpm.Pools(version)
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		var pool *phpfpm.Pool
+		for i := range pools {
+			if pools[i].Name == poolName {
+				pool = &pools[i]
+			}
+		}
+		if pool == nil {
+			if domainName != "" {
+				ui.Fail("❌ %s has no PHP %s pool of its own; it uses the shared pool (give it one with 'webstack domain php-settings')\n", domainName, version)
+			} else {
+				ui.Fail("❌ No webstack pool found for PHP %s (use 'webstack install php %s')\n", version, version)
+			}
+			return
+		}
+
+		current, err := pool.Tuning()
+		if err != nil {
+			ui.Fail("❌ Could not read %s: %v\n", pool.File, err)
+			return
+		}
+
+		limits := map[string]string{
+			"max-children":  "pm.max_children",
+			"start-servers": "pm.start_servers",
+			"min-spare":     "pm.min_spare_servers",
+			"max-spare":     "pm.max_spare_servers",
+			"max-requests":  "pm.max_requests",
+		}
+		changed := auto || cmd.Flags().Changed("pm")
+		for flag := range limits {
+			changed = changed || cmd.Flags().Changed(flag)
+		}
+		if !changed {
+			printTuning(*pool, current)
+			return
+		}
+
+		if pm == "" {
+			pm = current["pm"]
+		}
+		if pm == "" {
+			pm = "dynamic"
+		}
+		if _, err := oneOf(phpfpm.Managers...)(pm); err != nil {
+			ui.Invalid("Invalid process manager %s: %v\n", pm, err)
+			return
+		}
+
+		values := map[string]string{}
+		switch {
+		case auto:
+			var report phpfpm.AutoReport
+			values, report, err = pool.Auto(pm)
+			if err != nil {
+				ui.Fail("❌ %v\n", err)
+				return
+			}
+			size := "default, no running worker to measure"
+			if report.Samples > 0 {
+				size = fmt.Sprintf("average of %d running workers", report.Samples)
+			}
+			fmt.Printf("🧮 %s of %s memory available to PHP-FPM, %d pools: %s for this pool\n",
+				formatMB(report.MemAvailable), formatMB(report.MemTotal), report.Pools, formatMB(report.Budget))
+			fmt.Printf("   Worker size %s (%s) → pm.max_children = %s\n", formatMB(report.WorkerSize), size, values["pm.max_children"])
+		case cmd.Flags().Changed("pm"):
+			// Derive the spare servers the new process manager needs
+			maxChildren, _ := strconv.Atoi(current["pm.max_children"])
+			if cmd.Flags().Changed("max-children") {
+				maxChildren, _ = cmd.Flags().GetInt("max-children")
+			}
+			if maxChildren < 1 {
+				maxChildren = 20
+			}
+			values = phpfpm.Values(pm, maxChildren)
+		}
+
+		for flag, key := range limits {
+			if !cmd.Flags().Changed(flag) {
+				continue
+			}
+			n, _ := cmd.Flags().GetInt(flag)
+			if n < 1 {
+				ui.Invalid("Invalid --%s: expected a positive number\n", flag)
+				return
+			}
+			values[key] = strconv.Itoa(n)
+		}
+
+		// Check the result the way php-fpm will
+		merged := map[string]string{}
+		for key, value := range current {
+			merged[key] = value
+		}
+		for key, value := range values {
+			merged[key] = value
+		}
+		if err := checkTuning(merged); err != nil {
+			ui.Invalid("Invalid pool settings: %v\n", err)
+			return
+		}
+
+		if err := pool.Tune(values); err != nil {
+			ui.Fail("❌ Could not tune pool %s: %v\n", pool.Name, err)
+			return
+		}
+		fmt.Printf("✅ PHP %s pool %s tuned\n", version, pool.Name)
+		updated, err := pool.Tuning()
+		if err == nil && !dryrun.Enabled() {
+			printTuning(*pool, updated)
+		}
+	},
+}
+
+func printTuning(pool phpfpm.Pool, values map[string]string) {
+	fmt.Printf("🐘 PHP %s pool %s (%s)\n", pool.Version, pool.Name, pool.File)
+	for _, key := range phpfpm.TuningKeys {
+		if value, ok := values[key]; ok {
+			fmt.Printf("   %-24s %s\n", key, value)
+		}
+	}
+}
+
+// checkTuning applies the limits php-fpm checks on start
+func checkTuning(values map[string]string) error {
+	n := func(key string) int {
+		v, _ := strconv.Atoi(values[key])
+		return v
+	}
+	maxChildren := n("pm.max_children")
+	if maxChildren < 1 {
+		return fmt.Errorf("pm.max_children must be at least 1")
+	}
+	if values["pm"] != "dynamic" {
+		return nil
+	}
+	start, minSpare, maxSpare := n("pm.start_servers"), n("pm.min_spare_servers"), n("pm.max_spare_servers")
+	if minSpare < 1 || maxSpare < 1 {
+		return fmt.Errorf("pm = dynamic needs pm.min_spare_servers and pm.max_spare_servers")
+	}
+	if maxSpare > maxChildren {
+		return fmt.Errorf("pm.max_spare_servers (%d) must not be greater than pm.max_children (%d)", maxSpare, maxChildren)
+	}
+	if minSpare > maxSpare {
+		return fmt.Errorf("pm.min_spare_servers (%d) must not be greater than pm.max_spare_servers (%d)", minSpare, maxSpare)
+	}
+	if start != 0 && (start < minSpare || start > maxSpare) {
+		return fmt.Errorf("pm.start_servers (%d) must be between pm.min_spare_servers (%d) and pm.max_spare_servers (%d)", start, minSpare, maxSpare)
+	}
+	return nil
+}
+
+func formatMB(bytes uint64) string {
+	return fmt.Sprintf("%d MB", bytes>>20)
+}
+
+func printPoolStatus(pool phpfpm.Pool, slow int) {
+	fmt.Printf("🐘 PHP %s pool %s (%s)\n", pool.Version, pool.Name, pool.Listen)
+
+	if pool.StatusPath == "" {
+		fmt.Println("   ⚠️  No status page (pm.status_path is not set)")
+	} else if status, err := pool.Status(); err != nil {
+		fmt.Printf("   ❌ Could not read the status page: %v\n", err)
+	} else {
+		fmt.Printf("   Workers:      %d active, %d idle, %d total (%s, max active %d)\n",
+			status.ActiveProcesses, status.IdleProcesses, status.TotalProcesses, status.ProcessManager, status.MaxActiveProcesses)
+		fmt.Printf("   Listen queue: %d waiting (max %d, backlog %d)\n",
+			status.ListenQueue, status.MaxListenQueue, status.ListenQueueLen)
+		fmt.Printf("   Requests:     %d accepted, %d slow\n", status.AcceptedConn, status.SlowRequests)
+		if status.MaxChildrenReached > 0 {
+			fmt.Printf("   ⚠️  pm.max_children reached %d times since start; requests waited for a worker\n", status.MaxChildrenReached)
+		}
+	}
+
+	if pool.Slowlog == "" {
+		fmt.Println("   ⚠️  Slow requests are not logged (slowlog is not set)")
+		fmt.Println()
+		return
+	}
+	entries, err := pool.SlowRequests(slow)
+	if err != nil {
+		fmt.Printf("   ❌ Could not read %s: %v\n", pool.Slowlog, err)
+	} else if len(entries) == 0 {
+		fmt.Println("   No slow requests logged")
+	} else {
+		fmt.Printf("   Recent slow requests (%s):\n", pool.Slowlog)
+		for _, e := range entries {
+			fmt.Printf("     %s  %s\n", e.Time, e.Script)
+			if e.Frame != "" {
+				fmt.Printf("       at %s\n", e.Frame)
+			}
+		}
+	}
+	fmt.Println()
+}
+
+func init() {
+	rootCmd.AddCommand(phpCmd)
+	phpCmd.AddCommand(phpStatusCmd)
+	phpCmd.AddCommand(phpTuneCmd)
+
+	phpStatusCmd.Flags().Int("slow", 5, "Number of recent slow requests to show per pool")
+
+	phpTuneCmd.Flags().String("domain", "", "Tune the pool of a domain with its own PHP settings")
+	phpTuneCmd.Flags().Bool("auto", false, "Compute pm.max_children from the available memory and worker size")
+	phpTuneCmd.Flags().String("pm", "", "Process manager: dynamic, static or ondemand")
+	phpTuneCmd.Flags().Int("max-children", 0, "pm.max_children")
+	phpTuneCmd.Flags().Int("start-servers", 0, "pm.start_servers (dynamic)")
+	phpTuneCmd.Flags().Int("min-spare", 0, "pm.min_spare_servers (dynamic)")
+	phpTuneCmd.Flags().Int("max-spare", 0, "pm.max_spare_servers (dynamic)")
+	phpTuneCmd.Flags().Int("max-requests", 0, "pm.max_requests: respawn workers after this many requests")
+}
@@ -0,0 +1,348 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"webstack-cli/internal/remote"
+	"webstack-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var remoteCmd = &cobra.Command{
+	Use:   "remote",
+	Short: "Manage remote servers and run commands on them over SSH",
+	Long: `Register remote servers in /etc/webstack/remotes.json and run any webstack command on
+them with --host. The command runs the webstack binary installed on each server over SSH
+(key authentication), on several servers in parallel, and the results are summarized.
+Usage:
+  sudo webstack remote add 203.0.113.10 --name web1
+  sudo webstack remote add web2.example.com --user deploy --sudo --key /root/.ssh/id_ed25519
+  sudo webstack domain list --host web1
+  sudo webstack doctor --host web1,web2
+  sudo webstack ssl renew --host all`,
+}
+
+var remoteAddCmd = &cobra.Command{
+	Use:   "add [host]",
+	Short: "Register a remote server",
+	Long: `Register a remote server, or replace the server with the same name. The SSH connection
+and the webstack binary on the server are checked unless --no-check is given.
+Examples:
+  sudo webstack remote add 203.0.113.10 --name web1
+  sudo webstack remote add web2.example.com --port 2222 --key /root/.ssh/id_ed25519
+  sudo webstack remote add web3.example.com --user deploy --sudo --binary /usr/local/bin/webstack`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		s := remote.Server{Host: args[0]}
+		s.Name, _ = cmd.Flags().GetString("name")
+		s.User, _ = cmd.Flags().GetString("user")
+		s.Port, _ = cmd.Flags().GetInt("port")
+		s.Key, _ = cmd.Flags().GetString("key")
+		s.Sudo, _ = cmd.Flags().GetBool("sudo")
+		s.Binary, _ = cmd.Flags().GetString("binary")
+		noCheck, _ := cmd.Flags().GetBool("no-check")
+		if s.Name == "" {
+			s.Name = s.Host
+		}
+		if s.User == "root" {
+			s.User = ""
+		}
+		if s.Port == 22 {
+			s.Port = 0
+		}
+		if err := s.Validate(); err != nil {
+			ui.Invalid("Invalid server: %v\n", err)
+			return
+		}
+
+		if !noCheck {
+			fmt.Printf("🔍 Connecting to %s...\n", s.Target())
+			version, err := remote.Check(s)
+			if err != nil {
+				ui.Fail("❌ Could not run webstack on %s: %v\n", s.Target(), err)
+				fmt.Println("   Check the SSH key (ssh-copy-id) and that webstack is installed, or use --no-check")
+				return
+			}
+			fmt.Printf("✅ webstack %s found on %s\n", version, s.Target())
+		}
+
+		replaced := false
+		err := remote.Update(func(r *remote.Registry) error {
+			for i := range r.Servers {
+				if r.Servers[i].Name == s.Name {
+					r.Servers[i] = s
+					replaced = true
+					return nil
+				}
+			}
+			r.Servers = append(r.Servers, s)
+			return nil
+		})
+		if err != nil {
+			ui.Fail("❌ Could not save the server: %v\n", err)
+			return
+		}
+		if replaced {
+			fmt.Printf("✅ Server %s updated\n", s.Name)
+		} else {
+			fmt.Printf("✅ Server %s added\n", s.Name)
+		}
+		fmt.Printf("💡 Run commands on it with: sudo webstack <command> --host %s\n", s.Name)
+	},
+}
+
+var remoteRemoveCmd = &cobra.Command{
+	Use:   "remove [name]",
+	Short: "Unregister a remote server",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		found := false
+		err := remote.Update(func(r *remote.Registry) error {
+			for i := range r.Servers {
+				if r.Servers[i].Name == args[0] {
+					r.Servers = append(r.Servers[:i], r.Servers[i+1:]...)
+					found = true
+					return nil
+				}
+			}
+			return fmt.Errorf("server %s not found", args[0])
+		})
+		if !found {
+			ui.Fail("❌ Server %s not found\n", args[0])
+			return
+		}
+		if err != nil {
+			ui.Fail("❌ Could not remove the server: %v\n", err)
+			return
+		}
+		fmt.Printf("✅ Server %s removed\n", args[0])
+	},
+}
+
+var remoteListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the remote servers",
+	Run: func(cmd *cobra.Command, args []string) {
+		r, err := remote.Load()
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		if len(r.Servers) == 0 {
+			fmt.Println("ℹ️  No remote servers registered")
+			fmt.Println("   Add one with: sudo webstack remote add <host> --name <name>")
+			return
+		}
+		fmt.Printf("%-20s %-36s %-6s %s\n", "NAME", "TARGET", "SUDO", "BINARY")
+		for _, s := range r.Servers {
+			sudo := "no"
+			if s.Sudo {
+				sudo = "yes"
+			}
+			binary := s.Binary
+			if binary == "" {
+				binary = "webstack"
+			}
+			fmt.Printf("%-20s %-36s %-6s %s\n", s.Name, s.Target(), sudo, binary)
+		}
+	},
+}
+
+var remoteTestCmd = &cobra.Command{
+	Use:   "test [name]",
+	Short: "Check the SSH connection and webstack version of one server or all of them",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		r, err := remote.Load()
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		names := []string{"all"}
+		if len(args) == 1 {
+			names = args
+		}
+		servers, err := r.Find(names)
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		results := remote.Run(servers, []string{"version", "--no-emoji"})
+		for _, result := range results {
+			output := strings.TrimSpace(string(result.Output))
+			if result.Err != nil || result.ExitCode != 0 {
+				if result.Err != nil {
+					output = result.Err.Error() + ": " + output
+				}
+				ui.Fail("❌ %s (%s): %s\n", result.Server.Name, result.Server.Target(), strings.TrimSpace(output))
+				continue
+			}
+			version := strings.TrimPrefix(strings.SplitN(output, "\n", 2)[0], "WebStack CLI ")
+			fmt.Printf("✅ %s (%s): webstack %s\n", result.Server.Name, result.Server.Target(), version)
+		}
+	},
+}
+
+// remoteArgs extracts the --host flag from the command line. It returns the
+// selected servers, the remaining arguments to forward and whether --host
+// was given.
+func remoteArgs(args []string) ([]string, []string, bool) {
+	var hosts, rest []string
+	given := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			rest = append(rest, args[i:]...)
+			return hosts, rest, given
+		case arg == "--host":
+			given = true
+			if i+1 < len(args) {
+				hosts = append(hosts, strings.Split(args[i+1], ",")...)
+				i++
+			}
+		case strings.HasPrefix(arg, "--host="):
+			given = true
+			hosts = append(hosts, strings.Split(strings.TrimPrefix(arg, "--host="), ",")...)
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return hosts, rest, given
+}
+
+// runRemote forwards a command to the selected servers. A single server is
+// connected to the terminal so prompts work; several servers run in
+// parallel and their output is shown per server, followed by a summary.
+// It returns the worst exit code of the servers.
+func runRemote(names, args []string) int {
+	flags := rootCmd.PersistentFlags()
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	flags.Parse(args)
+	initOutput()
+
+	var selected []string
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			selected = append(selected, name)
+		}
+	}
+	if len(selected) == 0 {
+		ui.Invalid("Invalid --host: give server names separated by commas, or all\n")
+		return ui.ExitValidation
+	}
+	if command := flags.Args(); len(command) == 0 || command[0] == "remote" {
+		ui.Invalid("Invalid --host: give a command to run, remote commands only run locally\n")
+		return ui.ExitValidation
+	}
+
+	r, err := remote.Load()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return ui.ExitFailure
+	}
+	servers, err := r.Find(selected)
+	if err != nil {
+		ui.Invalid("Invalid --host: %v\n", err)
+		return ui.ExitValidation
+	}
+
+	if len(servers) == 1 {
+		s := servers[0]
+		stat, _ := os.Stdin.Stat()
+		interactive := stat != nil && stat.Mode()&os.ModeCharDevice != 0
+		fmt.Printf("🌐 %s (%s): webstack %s\n", s.Name, s.Target(), strings.Join(args, " "))
+		code, err := remote.Stream(s, args, interactive)
+		if err != nil {
+			ui.Fail("❌ %s: %v\n", s.Name, err)
+		}
+		return remoteExitCode([]int{code})
+	}
+
+	fmt.Printf("🌐 Running 'webstack %s' on %d servers...\n", strings.Join(args, " "), len(servers))
+	results := remote.Run(servers, args)
+	var codes []int
+	for _, result := range results {
+		fmt.Printf("\n==> %s (%s)\n", result.Server.Name, result.Server.Target())
+		for _, line := range strings.Split(strings.TrimRight(string(result.Output), "\n"), "\n") {
+			if line != "" {
+				fmt.Printf("  %s\n", line)
+			}
+		}
+		codes = append(codes, result.ExitCode)
+	}
+
+	fmt.Println()
+	fmt.Printf("%-20s %-36s %s\n", "SERVER", "TARGET", "RESULT")
+	failed := 0
+	for _, result := range results {
+		status := "ok"
+		switch {
+		case result.Err != nil:
+			status = result.Err.Error()
+			failed++
+		case result.ExitCode == ui.ExitPartial:
+			status = "warnings"
+		case result.ExitCode == ui.ExitValidation:
+			status = "invalid arguments"
+			failed++
+		case result.ExitCode != ui.ExitOK:
+			status = fmt.Sprintf("failed (exit %d)", result.ExitCode)
+			failed++
+		}
+		fmt.Printf("%-20s %-36s %s\n", result.Server.Name, result.Server.Target(), status)
+	}
+	fmt.Println()
+	if failed > 0 {
+		ui.Fail("❌ Failed on %d of %d servers\n", failed, len(servers))
+	} else {
+		fmt.Printf("✅ Completed on %d servers\n", len(servers))
+	}
+	return remoteExitCode(codes)
+}
+
+// remoteExitCode combines the exit codes of the servers like the local exit
+// codes: invalid arguments, then failures, then warnings
+func remoteExitCode(codes []int) int {
+	worst := ui.ExitOK
+	for _, code := range codes {
+		switch {
+		case code == ui.ExitValidation:
+			return ui.ExitValidation
+		case code == ui.ExitPartial:
+			if worst == ui.ExitOK {
+				worst = ui.ExitPartial
+			}
+		case code != ui.ExitOK:
+			worst = ui.ExitFailure
+		}
+	}
+	return worst
+}
+
+func init() {
+	rootCmd.AddCommand(remoteCmd)
+	remoteCmd.AddCommand(remoteAddCmd)
+	remoteCmd.AddCommand(remoteRemoveCmd)
+	remoteCmd.AddCommand(remoteListCmd)
+	remoteCmd.AddCommand(remoteTestCmd)
+
+	remoteAddCmd.Flags().String("name", "", "Name used with --host (default: the host)")
+	remoteAddCmd.Flags().String("user", "root", "SSH user")
+	remoteAddCmd.Flags().Int("port", 22, "SSH port")
+	remoteAddCmd.Flags().String("key", "", "SSH private key (default: the SSH agent and ~/.ssh)")
+	remoteAddCmd.Flags().Bool("sudo", false, "Run webstack with sudo (passwordless) when the user isn't root")
+	remoteAddCmd.Flags().String("binary", "", "Path of webstack on the server (default: webstack)")
+	remoteAddCmd.Flags().Bool("no-check", false, "Don't check the SSH connection")
+}
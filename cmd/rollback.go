@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/rollback"
+	"webstack-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+// snapshotCommands are the commands that change domains, certificates, the
+// configuration or other state; a rollback snapshot is taken before each
+var snapshotCommands = map[string]bool{
+	"backup import":                true,
+	"backup restore":               true,
+	"cache disable":                true,
+	"cache enable":                 true,
+	"config set":                   true,
+	"config unset":                 true,
+	"cron add":                     true,
+	"cron delete":                  true,
+	"cron disable":                 true,
+	"cron edit":                    true,
+	"cron enable":                  true,
+	"domain access":                true,
+	"domain add":                   true,
+	"domain apply":                 true,
+	"domain config edit":           true,
+	"domain delete":                true,
+	"domain edit":                  true,
+	"domain headers":               true,
+	"domain import":                true,
+	"domain php-settings":          true,
+	"domain protect":               true,
+	"domain ratelimit":             true,
+	"domain rebuild-configs":       true,
+	"domain restore":               true,
+	"domain rewrite add":           true,
+	"domain rewrite remove":        true,
+	"domain route add":             true,
+	"domain route remove":          true,
+	"ftp user add":                 true,
+	"ftp user delete":              true,
+	"notify add":                   true,
+	"notify remove":                true,
+	"notify smtp":                  true,
+	"php tune":                     true,
+	"server compression disable":   true,
+	"server compression enable":    true,
+	"ssl disable":                  true,
+	"ssl enable":                   true,
+	"ssl import":                   true,
+	"state export":                 true,
+	"state migrate":                true,
+	"system remote-access disable": true,
+	"system remote-access enable":  true,
+	"tools install":                true,
+	"tools uninstall":              true,
+	"worker add":                   true,
+	"worker remove":                true,
+	"worker scale":                 true,
+}
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Undo the last command that changed domains, SSL or configuration",
+	Long: `Commands that change domains, certificates, the configuration or other webstack state
+first snapshot the state files and the Nginx, Apache, PHP-FPM and logrotate configuration
+into /var/lib/webstack/rollback/<timestamp>. The last 20 snapshots are kept.
+Website files, databases and certificates are not part of a snapshot.
+Examples:
+  webstack rollback list
+  sudo webstack rollback last
+  sudo webstack rollback restore 20250101-120000`,
+}
+
+var rollbackLastCmd = &cobra.Command{
+	Use:   "last",
+	Short: "Restore the snapshot taken before the last changing command",
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		snap, err := rollback.Last()
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		restoreSnapshot(snap)
+	},
+}
+
+var rollbackRestoreCmd = &cobra.Command{
+	Use:   "restore [id]",
+	Short: "Restore an older snapshot (see 'webstack rollback list')",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		snap, err := rollback.Get(args[0])
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		restoreSnapshot(snap)
+	},
+}
+
+var rollbackListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the rollback snapshots, newest first",
+	Run: func(cmd *cobra.Command, args []string) {
+		snaps, err := rollback.List()
+		if err != nil {
+			ui.Fail("❌ Could not read %s: %v\n", rollback.Dir, err)
+			return
+		}
+		if len(snaps) == 0 {
+			fmt.Println("ℹ️  No rollback snapshots yet")
+			return
+		}
+		fmt.Printf("%-20s %-20s %-10s %s\n", "ID", "TAKEN", "RESTORED", "BEFORE")
+		for _, snap := range snaps {
+			restored := "-"
+			if !snap.Restored.IsZero() {
+				restored = snap.Restored.Format("01-02 15:04")
+			}
+			fmt.Printf("%-20s %-20s %-10s %s\n", snap.ID, snap.Created.Format("2006-01-02 15:04:05"), restored, snap.Command)
+		}
+	},
+}
+
+func restoreSnapshot(snap *rollback.Snapshot) {
+	fmt.Printf("⏪ Rolling back to snapshot %s, taken before: %s\n", snap.ID, snap.Command)
+	if !snap.Restored.IsZero() {
+		fmt.Printf("ℹ️  This snapshot was already restored on %s\n", snap.Restored.Format("2006-01-02 15:04:05"))
+	}
+	// Snapshot the current state first so the rollback can be undone
+	if !dryrun.Enabled() {
+		if _, err := rollback.Take("rollback to " + snap.ID); err != nil {
+			ui.Fail("❌ Could not snapshot the current state: %v\n", err)
+			return
+		}
+	}
+	if err := snap.Restore(); err != nil {
+		ui.Fail("❌ Rollback incomplete: %v\n", err)
+		return
+	}
+	fmt.Printf("✅ Rolled back to the state before '%s'\n", snap.Command)
+	fmt.Println("💡 Undo the rollback with: sudo webstack rollback last")
+}
+
+// takeSnapshot snapshots the state before a command in snapshotCommands
+func takeSnapshot(cmd *cobra.Command, args []string) {
+	name := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	if !snapshotCommands[name] || dryrun.Enabled() || os.Geteuid() != 0 {
+		return
+	}
+	description := strings.TrimSpace(name + " " + strings.Join(args, " "))
+	if _, err := rollback.Take(description); err != nil {
+		ui.Warn("⚠️  Warning: Could not take a rollback snapshot: %v\n", err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+	rollbackCmd.AddCommand(rollbackLastCmd)
+	rollbackCmd.AddCommand(rollbackRestoreCmd)
+	rollbackCmd.AddCommand(rollbackListCmd)
+}
@@ -2,7 +2,19 @@ package cmd
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/logging"
+	"webstack-cli/internal/pkg"
+	"webstack-cli/internal/prompt"
+	"webstack-cli/internal/ui"
 
 	"github.com/spf13/cobra"
 )
@@ -17,7 +29,19 @@ Features:
 - Install MariaDB/MySQL and PostgreSQL
 - Install PHP-FPM versions 5.6 to 8.4
 - Domain management with SSL support
-- Let's Encrypt SSL certificate management`,
+- Let's Encrypt SSL certificate management
+
+Exit codes:
+  0  Success
+  1  Completed with warnings (partial success)
+  2  Failure
+  3  Validation error (invalid arguments, flags or values)
+Use --strict to turn warnings into failures (exit code 2).`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		initPrompt(cmd)
+		// Snapshot the state before commands that change it, see rollback.go
+		takeSnapshot(cmd, args)
+	},
 	// Disable completion command
 	CompletionOptions: cobra.CompletionOptions{
 		DisableDefaultCmd: true,
@@ -25,12 +49,202 @@ Features:
 }
 
 func Execute() {
+	// Commands given --host run on remote servers instead, see remote.go
+	if hosts, args, ok := remoteArgs(os.Args[1:]); ok {
+		ui.Exit(runRemote(hosts, args))
+	}
+
+	start := time.Now()
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		ui.MarkValidationError()
+	}
+	ui.Flush()
+	code := ui.ExitCode()
+	slog.Info("command finished", "exit_code", code, "duration", time.Since(start).Round(time.Millisecond).String())
+	logging.Close()
+	ui.Exit(code)
+}
+
+// initOutput configures the output mode once flags have been parsed.
+// Plain-ASCII output is used when --no-emoji is given, when no_emoji is
+// set in the config, or when the terminal does not look UTF-8 capable.
+// An explicit --no-emoji=false always wins over the config and detection.
+// Colors are used on terminals unless --no-color or NO_COLOR is set.
+// Logging starts with the output, so every printed line is logged.
+func initOutput() {
+	flags := rootCmd.PersistentFlags()
+	opts := ui.Options{Plain: ui.DetectPlain(), Color: true}
+
+	if cfg, err := config.Load(); err == nil {
+		if cfg.GetBool("no_emoji") {
+			opts.Plain = true
+		}
+		if cfg.GetBool("no_color") {
+			opts.Color = false
+		}
+	}
+
+	if flags.Changed("no-emoji") {
+		opts.Plain, _ = flags.GetBool("no-emoji")
+	}
+	if noColor, _ := flags.GetBool("no-color"); noColor {
+		opts.Color = false
+	}
+
+	strict, _ := flags.GetBool("strict")
+	ui.SetStrict(strict)
+
+	opts.Quiet, _ = flags.GetBool("quiet")
+	opts.Log = logOutput
+	ui.Start(opts)
+
+	verbose, _ := flags.GetBool("verbose")
+	logFormat, _ := flags.GetString("log-format")
+	logFile, _ := flags.GetString("log-file")
+	if opts.Quiet && verbose {
+		ui.Invalid("❌ Invalid flags: --quiet and --verbose cannot be combined\n")
+		ui.Finish()
+	}
+	if err := logging.Setup(logging.Options{Verbose: verbose, Format: logFormat, File: logFile}); err != nil {
+		ui.Fail("❌ %v\n", err)
+		ui.Finish()
+	}
+	slog.Info("command started", "args", strings.Join(maskArgs(os.Args[1:]), " "), "user", os.Getenv("SUDO_USER"))
+}
+
+// logOutput logs a printed line. Lines without a marker (📦, ✅, ⚠️ ...),
+// such as the output of apt, are only kept in verbose logs.
+func logOutput(level ui.Level, line string) {
+	switch level {
+	case ui.LevelError, ui.LevelValidation:
+		logging.Output(slog.LevelError, line)
+	case ui.LevelWarning:
+		logging.Output(slog.LevelWarn, line)
+	case ui.LevelSuccess:
+		logging.Output(slog.LevelInfo, line)
+	default:
+		if r, _ := utf8.DecodeRuneInString(line); r >= utf8.RuneSelf {
+			logging.Output(slog.LevelInfo, line)
+		} else {
+			logging.Output(slog.LevelDebug, line)
+		}
+	}
+}
+
+// secretArgs is the annotation of commands taking passwords as arguments:
+// the positions of those arguments (from 0), comma-separated
+const secretArgs = "webstack_secret_args"
+
+// maskArgs hides the values of password and token flags, and the password
+// arguments of the command (see secretArgs), for the log
+func maskArgs(args []string) []string {
+	secret := func(flag string) bool {
+		flag = strings.ToLower(flag)
+		return strings.HasPrefix(flag, "-") && (strings.Contains(flag, "pass") || strings.Contains(flag, "token") || strings.Contains(flag, "secret"))
+	}
+	masked := make([]string, len(args))
+	for i, arg := range args {
+		masked[i] = arg
+		if name, _, found := strings.Cut(arg, "="); found && secret(name) {
+			masked[i] = name + "=****"
+		} else if i > 0 && secret(args[i-1]) && !strings.Contains(args[i-1], "=") && !strings.HasPrefix(arg, "-") {
+			masked[i] = "****"
+		}
+	}
+	maskSecretArgs(args, masked)
+	return masked
+}
+
+// maskSecretArgs masks the arguments of the command at the positions its
+// secretArgs annotation lists
+func maskSecretArgs(args, masked []string) {
+	cmd, _, err := rootCmd.Find(args)
+	if err != nil || cmd.Annotations[secretArgs] == "" {
+		return
+	}
+	secrets := map[int]bool{}
+	for _, position := range strings.Split(cmd.Annotations[secretArgs], ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(position)); err == nil {
+			secrets[n] = true
+		}
+	}
+
+	// The first words are the names of the command and its parents
+	skip := 0
+	for c := cmd; c.HasParent(); c = c.Parent() {
+		skip++
+	}
+	position, flagsDone := 0, false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !flagsDone && arg == "--" {
+			flagsDone = true
+			continue
+		}
+		if !flagsDone && strings.HasPrefix(arg, "-") && len(arg) > 1 {
+			if !strings.Contains(arg, "=") && flagTakesValue(cmd, arg) {
+				i++
+			}
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		if secrets[position] {
+			masked[i] = "****"
+		}
+		position++
+	}
+}
+
+// flagTakesValue reports whether a flag (--name or -n) is followed by its
+// value as the next argument
+func flagTakesValue(cmd *cobra.Command, arg string) bool {
+	if strings.HasPrefix(arg, "--") {
+		flag := cmd.Flags().Lookup(arg[2:])
+		return flag != nil && flag.NoOptDefVal == ""
+	}
+	// A shorthand's value may be attached: -pSELECT
+	flag := cmd.Flags().ShorthandLookup(arg[1:2])
+	return flag != nil && flag.NoOptDefVal == "" && len(arg) == 2
+}
+
+// initDryRun enables dry-run mode when --dry-run is given
+func initDryRun() {
+	if dryRun, _ := rootCmd.PersistentFlags().GetBool("dry-run"); dryRun {
+		dryrun.Enable()
+		fmt.Println("🔎 Dry-run mode: commands, file writes and service actions are printed, not executed")
+	}
+}
+
+// initPackages makes apt and dpkg run without waiting for their locks when
+// --assume-apt-ready is given
+func initPackages() {
+	pkg.AssumeReady, _ = rootCmd.PersistentFlags().GetBool("assume-apt-ready")
+}
+
+// initPrompt lets destructive actions run without asking, and under
+// WEBSTACK_FORBID_DESTRUCTIVE, when the command was given --force
+func initPrompt(cmd *cobra.Command) {
+	if flag := cmd.Flags().Lookup("force"); flag != nil && flag.Changed {
+		prompt.Force, _ = cmd.Flags().GetBool("force")
 	}
 }
 
 func init() {
+	cobra.OnInitialize(initOutput, initDryRun, initPackages)
+
 	rootCmd.Flags().BoolP("version", "v", false, "Show version information")
+	rootCmd.PersistentFlags().Bool("no-emoji", false, "Use plain ASCII output instead of emoji (for logs, serial consoles and CI)")
+	rootCmd.PersistentFlags().Bool("no-color", false, "Disable colored output")
+	rootCmd.PersistentFlags().Bool("strict", false, "Treat warnings as failures (exit code 2 instead of 1)")
+	rootCmd.PersistentFlags().StringSlice("host", nil, "Run the command on registered remote servers over SSH: names separated by commas, or all (see 'webstack remote')")
+	rootCmd.PersistentFlags().Bool("dry-run", false, "Print the commands, file writes and service actions that would be executed without performing them")
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "Only print warnings, errors and prompts")
+	rootCmd.PersistentFlags().Bool("verbose", false, "Print debug details (commands run, files written, service actions) to stderr")
+	rootCmd.PersistentFlags().String("log-file", "", "Also write the log to this file (the log is always kept in "+logging.DefaultFile+" when writable)")
+	rootCmd.PersistentFlags().String("log-format", logging.Text, "Format of the log files: text or json")
+	rootCmd.PersistentFlags().Bool("assume-apt-ready", false, "Run apt and dpkg without waiting for other package managers (e.g. unattended-upgrades) to release their locks")
 }
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMaskArgs(t *testing.T) {
+	tests := []struct {
+		args string
+		want string
+	}{
+		{"db user create mysql appuser S3cret localhost", "db user create mysql appuser **** localhost"},
+		{"db user create mysql appuser S3cret 192.168.1.% --privileges SELECT,INSERT", "db user create mysql appuser **** 192.168.1.% --privileges SELECT,INSERT"},
+		{"db user create --privileges SELECT mysql appuser S3cret localhost", "db user create --privileges SELECT mysql appuser **** localhost"},
+		{"db user create -p SELECT -s mysql appuser S3cret localhost", "db user create -p SELECT -s mysql appuser **** localhost"},
+		{"db user password mysql appuser S3cret", "db user password mysql appuser ****"},
+		{"mail add account user@example.com S3cret", "mail add account user@example.com ****"},
+		{"mail password user@example.com S3cret", "mail password user@example.com ****"},
+		{"mail password user@example.com", "mail password user@example.com"},
+		{"system remote-access enable mysql root S3cret", "system remote-access enable mysql root ****"},
+		{"system remote-access enable postgresql postgres S3cret --cluster 16/main", "system remote-access enable postgresql postgres **** --cluster 16/main"},
+		{"--quiet system remote-access enable mysql root S3cret", "--quiet system remote-access enable mysql root ****"},
+		{"config set mysql_root_password S3cret", "config set mysql_root_password ****"},
+		{"db user create mysql appuser -- -S3cret localhost", "db user create mysql appuser -- **** localhost"},
+		{"backup create --password S3cret --domain example.com", "backup create --password **** --domain example.com"},
+		{"install mysql", "install mysql"},
+	}
+	for _, tt := range tests {
+		got := strings.Join(maskArgs(strings.Fields(tt.args)), " ")
+		if got != tt.want {
+			t.Errorf("maskArgs(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
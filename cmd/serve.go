@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"webstack-cli/internal/api"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the domain, SSL, database and installer operations as a JSON API",
+	Long: `Run an HTTP server exposing webstack as an authenticated JSON API, so web panels and
+remote automation can manage the server without SSH. Every request needs the header
+"Authorization: Bearer <token>"; pass the token with --token or, to keep it out of the
+process list, in WEBSTACK_API_TOKEN.
+
+Changes run the matching webstack command and return its output and exit code, so they
+behave exactly like the CLI. Listen on localhost (the default) behind a reverse proxy,
+or give --tls-cert and --tls-key when listening on a public address.
+
+Examples:
+  sudo WEBSTACK_API_TOKEN=$(openssl rand -hex 32) webstack serve
+  sudo webstack serve --listen 0.0.0.0:8443 --token ... --tls-cert cert.pem --tls-key key.pem
+  curl -H "Authorization: Bearer $TOKEN" http://127.0.0.1:8088/v1/domains`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+
+		listen, _ := cmd.Flags().GetString("listen")
+		token, _ := cmd.Flags().GetString("token")
+		tlsCert, _ := cmd.Flags().GetString("tls-cert")
+		tlsKey, _ := cmd.Flags().GetString("tls-key")
+
+		if token == "" {
+			token = os.Getenv("WEBSTACK_API_TOKEN")
+		}
+		if len(token) < 16 {
+			ui.Invalid("Invalid token: give at least 16 characters with --token or WEBSTACK_API_TOKEN\n")
+			return
+		}
+		if (tlsCert == "") != (tlsKey == "") {
+			ui.Invalid("Invalid options: --tls-cert and --tls-key must be given together\n")
+			return
+		}
+		host, _, err := net.SplitHostPort(listen)
+		if err != nil {
+			ui.Invalid("Invalid listen address %s: %v\n", listen, err)
+			return
+		}
+		if ip := net.ParseIP(host); tlsCert == "" && (ip == nil || !ip.IsLoopback()) && host != "localhost" {
+			ui.Warn("⚠️  Warning: %s is not a loopback address and TLS is off; the token is sent in clear text\n", listen)
+		}
+
+		executable, err := os.Executable()
+		if err != nil {
+			ui.Fail("❌ Could not find the webstack binary: %v\n", err)
+			return
+		}
+		server := &api.Server{Token: token, Executable: executable, DryRun: dryrun.Enabled()}
+		httpServer := &http.Server{
+			Addr:              listen,
+			Handler:           server.Handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		// Let running operations finish on Ctrl+C or systemctl stop
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-stop
+			fmt.Println("⏹️  Shutting down...")
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			defer cancel()
+			httpServer.Shutdown(ctx)
+		}()
+
+		scheme := "http"
+		if tlsCert != "" {
+			scheme = "https"
+		}
+		fmt.Printf("🌐 Serving the webstack API on %s://%s/v1/\n", scheme, listen)
+		if tlsCert != "" {
+			err = httpServer.ListenAndServeTLS(tlsCert, tlsKey)
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			ui.Fail("❌ API server failed: %v\n", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().String("listen", "127.0.0.1:8088", "Address to listen on")
+	serveCmd.Flags().String("token", "", "Bearer token clients must send (default: $WEBSTACK_API_TOKEN)")
+	serveCmd.Flags().String("tls-cert", "", "TLS certificate file, to serve HTTPS")
+	serveCmd.Flags().String("tls-key", "", "TLS private key file")
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"webstack-cli/internal/domain"
+
+	"github.com/spf13/cobra"
+)
+
+var serverCmd = &cobra.Command{
+	Use:   "server",
+	Short: "Web server tuning",
+	Long:  `Server-wide settings shared by the Nginx and Apache configurations of all domains.`,
+}
+
+var serverCompressionCmd = &cobra.Command{
+	Use:   "compression",
+	Short: "Manage gzip and Brotli compression",
+	Long: `Manage the compression of responses. Nginx and Apache load their settings from
+a shared include (/etc/nginx/includes/compression.conf, /etc/apache2/includes/compression.conf).
+Domains can opt out with --domain.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		domain.CompressionStatus()
+	},
+}
+
+var serverCompressionEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Enable compression server-wide or for a domain",
+	Long: `Enable gzip compression and, where the distribution packages the Nginx module
+or Apache ships mod_brotli, Brotli. Examples:
+  webstack server compression enable
+  webstack server compression enable --no-brotli
+  webstack server compression enable --domain example.com    # lift the domain's opt-out`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		domainName, _ := cmd.Flags().GetString("domain")
+		noBrotli, _ := cmd.Flags().GetBool("no-brotli")
+		domain.EnableCompression(domain.CompressionOptions{
+			Domain:   domainName,
+			NoBrotli: noBrotli,
+		})
+	},
+}
+
+var serverCompressionDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Disable compression server-wide or for a domain",
+	Long: `Disable compression. Examples:
+  webstack server compression disable
+  webstack server compression disable --domain example.com   # opt the domain out`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		domainName, _ := cmd.Flags().GetString("domain")
+		domain.DisableCompression(domain.CompressionOptions{
+			Domain: domainName,
+		})
+	},
+}
+
+var serverCompressionStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the compression settings",
+	Run: func(cmd *cobra.Command, args []string) {
+		domain.CompressionStatus()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serverCmd)
+	serverCmd.AddCommand(serverCompressionCmd)
+	serverCompressionCmd.AddCommand(serverCompressionEnableCmd)
+	serverCompressionCmd.AddCommand(serverCompressionDisableCmd)
+	serverCompressionCmd.AddCommand(serverCompressionStatusCmd)
+
+	serverCompressionEnableCmd.Flags().String("domain", "", "Lift the compression opt-out of a domain")
+	serverCompressionEnableCmd.Flags().Bool("no-brotli", false, "Do not install the Nginx Brotli module")
+	serverCompressionDisableCmd.Flags().String("domain", "", "Opt a domain out of compression")
+}
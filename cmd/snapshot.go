@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"webstack-cli/internal/notify"
+	"webstack-cli/internal/snapshot"
+	"webstack-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Snapshot the server configuration and detect manual edits (drift)",
+	Long: `Capture the server configuration into /var/lib/webstack/snapshots/<timestamp>: the webstack
+state in /etc/webstack, the Nginx and Apache vhosts, PHP-FPM pools, Postfix, Dovecot and
+OpenDKIM configuration, the firewall rules files and the rules loaded in the kernel.
+'snapshot diff' compares the current configuration with the last snapshot, showing edits
+made by hand that 'domain rebuild-configs' would overwrite. The last 30 snapshots are kept.
+Examples:
+  sudo webstack snapshot create --note "before PHP upgrade"
+  sudo webstack snapshot diff
+  sudo webstack snapshot diff 20250101-030000 --files
+  sudo webstack snapshot schedule enable --time 03:30`,
+}
+
+var snapshotCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Snapshot the current configuration",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		note, _ := cmd.Flags().GetString("note")
+		scheduled, _ := cmd.Flags().GetBool("scheduled")
+
+		if scheduled {
+			snap, changes, err := snapshot.CreateScheduled()
+			if err != nil {
+				ui.Fail("❌ Snapshot failed: %v\n", err)
+				return
+			}
+			if snap == nil {
+				fmt.Println("✅ No configuration changes since the last snapshot")
+				return
+			}
+			if len(changes) > 0 {
+				ui.Warn("⚠️  %d configuration change(s) since the last snapshot:\n", len(changes))
+				message := ""
+				for _, c := range changes {
+					fmt.Printf("   %-8s %s\n", c.Kind, c.Path)
+					message += fmt.Sprintf("%s %s\n", c.Kind, c.Path)
+				}
+				notify.Send(notify.ConfigDrift, "", fmt.Sprintf("%d configuration change(s) since the last snapshot", len(changes)),
+					message+"Review with: webstack snapshot diff "+snap.ID)
+			}
+			fmt.Printf("✅ Snapshot %s created (%d files)\n", snap.ID, len(snap.Files))
+			return
+		}
+
+		snap, err := snapshot.Create(note, false)
+		if err != nil {
+			ui.Fail("❌ Snapshot failed: %v\n", err)
+			return
+		}
+		fmt.Printf("✅ Snapshot %s created (%d files)\n", snap.ID, len(snap.Files))
+		fmt.Println("💡 Show changes made since with: sudo webstack snapshot diff")
+	},
+}
+
+var snapshotDiffCmd = &cobra.Command{
+	Use:   "diff [id]",
+	Short: "Compare the current configuration with the last snapshot, or the given one",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		filesOnly, _ := cmd.Flags().GetBool("files")
+
+		var snap *snapshot.Snapshot
+		var err error
+		if len(args) == 1 {
+			snap, err = snapshot.Get(args[0])
+		} else {
+			snap, err = snapshot.Last()
+		}
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+
+		changes, err := snap.Diff()
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		fmt.Printf("🔍 Comparing with snapshot %s (%s)\n", snap.ID, snap.Created.Format("2006-01-02 15:04:05"))
+		if len(changes) == 0 {
+			fmt.Println("✅ No changes: the configuration matches the snapshot")
+			return
+		}
+
+		if filesOnly {
+			for _, c := range changes {
+				fmt.Printf("   %-8s %s\n", c.Kind, c.Path)
+			}
+		} else {
+			snap.PrintDiff(changes)
+		}
+
+		fmt.Println()
+		ui.Warn("⚠️  %d file(s) changed since the snapshot\n", len(changes))
+		if generated := snapshot.Generated(changes); len(generated) > 0 {
+			fmt.Printf("   %d of them are rewritten by 'webstack domain rebuild-configs'; move manual edits to\n", len(generated))
+			fmt.Println("   'webstack domain config edit' or the templates to keep them")
+		}
+	},
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the configuration snapshots, newest first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		snaps, err := snapshot.List()
+		if err != nil {
+			ui.Fail("❌ Could not read %s: %v\n", snapshot.Dir, err)
+			return
+		}
+		if len(snaps) == 0 {
+			fmt.Println("ℹ️  No snapshots yet (create one with 'sudo webstack snapshot create')")
+			return
+		}
+		fmt.Printf("%-20s %-20s %6s  %s\n", "ID", "CREATED", "FILES", "NOTE")
+		for _, snap := range snaps {
+			note := snap.Note
+			if snap.Scheduled && note == "" {
+				note = "scheduled"
+			} else if snap.Scheduled {
+				note = "scheduled: " + note
+			}
+			fmt.Printf("%-20s %-20s %6d  %s\n", snap.ID, snap.Created.Format("2006-01-02 15:04:05"), len(snap.Files), note)
+		}
+	},
+}
+
+var snapshotScheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Take a daily snapshot and notify configuration drift",
+	Long: `A systemd timer (webstack-snapshot.timer) runs 'webstack snapshot create --scheduled' every
+day. It only takes a snapshot when the configuration changed since the last one, and then
+sends the changed files to the notification channels subscribed to config.drift.
+Examples:
+  sudo webstack snapshot schedule enable --time 03:30
+  webstack snapshot schedule status
+  sudo webstack snapshot schedule disable`,
+}
+
+var snapshotScheduleEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Enable daily snapshots",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		at, _ := cmd.Flags().GetString("time")
+		if err := snapshot.EnableSchedule(at); err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		fmt.Printf("✅ Daily configuration snapshots enabled at %s\n", at)
+		fmt.Println("   Get drift alerts with: sudo webstack notify add <name> ... --events config.drift")
+	},
+}
+
+var snapshotScheduleDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Disable daily snapshots",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		if err := snapshot.DisableSchedule(); err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		fmt.Println("✅ Daily configuration snapshots disabled")
+	},
+}
+
+var snapshotScheduleStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether daily snapshots are enabled",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		at := snapshot.ScheduleTime()
+		if at == "" {
+			fmt.Println("ℹ️  Daily snapshots are disabled")
+			fmt.Println("   Enable with: sudo webstack snapshot schedule enable")
+			return
+		}
+		fmt.Printf("✅ Daily snapshots are enabled (%s)\n", at)
+		if last, err := snapshot.Last(); err == nil {
+			fmt.Printf("   Last snapshot: %s\n", last.ID)
+		}
+	},
+}
+
+// warnConfigDrift lists the generated files changed since the last snapshot
+// before 'domain rebuild-configs' overwrites them
+func warnConfigDrift() {
+	snap, err := snapshot.Last()
+	if err != nil {
+		return
+	}
+	changes, err := snap.Diff()
+	if err != nil {
+		return
+	}
+	generated := snapshot.Generated(changes)
+	if len(generated) == 0 {
+		return
+	}
+	ui.Warn("⚠️  %d generated file(s) changed since snapshot %s and will be rewritten:\n", len(generated), snap.ID)
+	for _, c := range generated {
+		fmt.Printf("   %-8s %s\n", c.Kind, c.Path)
+	}
+	fmt.Println("   The current files are kept in a rollback snapshot: sudo webstack rollback last")
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.AddCommand(snapshotCreateCmd)
+	snapshotCmd.AddCommand(snapshotDiffCmd)
+	snapshotCmd.AddCommand(snapshotListCmd)
+	snapshotCmd.AddCommand(snapshotScheduleCmd)
+
+	snapshotScheduleCmd.AddCommand(snapshotScheduleEnableCmd)
+	snapshotScheduleCmd.AddCommand(snapshotScheduleDisableCmd)
+	snapshotScheduleCmd.AddCommand(snapshotScheduleStatusCmd)
+
+	snapshotCreateCmd.Flags().String("note", "", "Describe the snapshot")
+	snapshotCreateCmd.Flags().Bool("scheduled", false, "Only snapshot and notify when the configuration changed (used by the timer)")
+	snapshotDiffCmd.Flags().Bool("files", false, "Only list the changed files")
+	snapshotScheduleEnableCmd.Flags().String("time", "03:30", "Time of the daily snapshot (HH:MM)")
+}
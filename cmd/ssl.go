@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"webstack-cli/internal/ssl"
+	"webstack-cli/internal/ui"
 
 	"github.com/spf13/cobra"
 )
@@ -15,12 +16,44 @@ var sslCmd = &cobra.Command{
 var sslEnableCmd = &cobra.Command{
 	Use:   "enable [domain]",
 	Short: "Enable SSL certificate for a domain",
-	Long:  `Enable SSL certificate for a domain. Use --type to specify certificate type: selfsigned or letsencrypt.`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Enable SSL certificate for a domain. Use --type to specify certificate type: selfsigned or letsencrypt.
+
+Let's Encrypt certificates are requested with the built-in ACME client and the HTTP-01
+challenge (through the shared challenge web root, so web servers keep running) by default. Use the DNS-01
+challenge for wildcard certificates or servers without public port 80:
+  webstack ssl enable example.com --wildcard --dns-provider bind
+  webstack ssl enable example.com --challenge dns --dns-provider cloudflare
+  webstack ssl enable example.com --san www.example.com --san shop.example.com`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		email, _ := cmd.Flags().GetString("email")
 		certType, _ := cmd.Flags().GetString("type")
-		ssl.EnableWithType(args[0], email, certType)
+		challenge, _ := cmd.Flags().GetString("challenge")
+		wildcard, _ := cmd.Flags().GetBool("wildcard")
+		altNames, _ := cmd.Flags().GetStringSlice("san")
+		dnsProvider, _ := cmd.Flags().GetString("dns-provider")
+		acmeClient, _ := cmd.Flags().GetString("acme-client")
+
+		ssl.EnableWithOptions(args[0], email, certType, ssl.LetsEncryptOptions{
+			Challenge:   challenge,
+			Wildcard:    wildcard,
+			AltNames:    altNames,
+			DNSProvider: dnsProvider,
+			Client:      acmeClient,
+		})
+	},
+}
+
+var sslDNSHookCmd = &cobra.Command{
+	Use:    "dns-hook [auth|cleanup]",
+	Short:  "certbot DNS-01 hook for the local bind9 zones",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ssl.DNSHook(args[0]); err != nil {
+			ui.Fail("❌ DNS hook failed: %v\n", err)
+			ui.Exit(ui.ExitFailure)
+		}
 	},
 }
 
@@ -38,8 +71,11 @@ var sslRenewCmd = &cobra.Command{
 	Short: "Renew SSL certificate for a domain",
 	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		ifDue, _ := cmd.Flags().GetBool("if-due")
 		if len(args) == 0 {
 			ssl.RenewAll()
+		} else if ifDue {
+			ssl.RenewIfDue(args[0])
 		} else {
 			ssl.Renew(args[0])
 		}
@@ -73,6 +109,79 @@ var sslAutorenewCmd = &cobra.Command{
 	},
 }
 
+var sslImportCmd = &cobra.Command{
+	Use:   "import [domain]",
+	Short: "Import a certificate from a commercial CA",
+	Long: `Import a certificate issued outside webstack, e.g. bought from a commercial CA. The key
+must match the certificate, and the certificate must be valid and cover the domain. The files
+are copied to /etc/webstack/ssl/<domain>/ and the HTTPS vhost is generated. Imported
+certificates are not renewed automatically; import the renewed certificate the same way.
+  webstack ssl import example.com --cert example.com.crt --key example.com.key --chain ca-bundle.crt`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		certFile, _ := cmd.Flags().GetString("cert")
+		keyFile, _ := cmd.Flags().GetString("key")
+		chainFile, _ := cmd.Flags().GetString("chain")
+		ssl.Import(args[0], ssl.ImportOptions{
+			Cert:  certFile,
+			Key:   keyFile,
+			Chain: chainFile,
+		})
+	},
+}
+
+var sslTrustCACmd = &cobra.Command{
+	Use:   "trust-ca",
+	Short: "Show the local CA that signs self-signed certificates and how to trust it",
+	Long: `Show the WebStack local CA, which signs the certificates of 'ssl enable --type selfsigned'.
+Trusting its root once in the operating system or browser removes the security warning for
+every local domain (e.g. *.test). The CA is created on first use in /etc/ssl/webstack/ca.
+  webstack ssl trust-ca`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ssl.TrustCA()
+	},
+}
+
+var sslAccountCmd = &cobra.Command{
+	Use:   "account",
+	Short: "Manage the Let's Encrypt accounts of the built-in ACME client",
+	Long: `Manage the Let's Encrypt accounts of the built-in ACME client. Accounts are kept per
+email address in /etc/webstack/acme/accounts and registered with the first certificate.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ssl.ListAccounts()
+	},
+}
+
+var sslAccountListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List ACME accounts and their certificates",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ssl.ListAccounts()
+	},
+}
+
+var sslAccountRegisterCmd = &cobra.Command{
+	Use:   "register [email]",
+	Short: "Register an ACME account or show its registration",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ssl.RegisterAccount(args[0])
+	},
+}
+
+var sslAccountDeactivateCmd = &cobra.Command{
+	Use:   "deactivate [email]",
+	Short: "Deactivate an ACME account and remove its key",
+	Long: `Deactivate an ACME account with Let's Encrypt and remove its key. Certificates issued
+with it stay valid; their renewal registers a new account for the same email.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ssl.DeactivateAccount(args[0])
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(sslCmd)
 	sslCmd.AddCommand(sslEnableCmd)
@@ -80,8 +189,28 @@ func init() {
 	sslCmd.AddCommand(sslRenewCmd)
 	sslCmd.AddCommand(sslStatusCmd)
 	sslCmd.AddCommand(sslAutorenewCmd)
+	sslCmd.AddCommand(sslDNSHookCmd)
+	sslCmd.AddCommand(sslImportCmd)
+	sslCmd.AddCommand(sslTrustCACmd)
+	sslCmd.AddCommand(sslAccountCmd)
+	sslAccountCmd.AddCommand(sslAccountListCmd)
+	sslAccountCmd.AddCommand(sslAccountRegisterCmd)
+	sslAccountCmd.AddCommand(sslAccountDeactivateCmd)
 
 	// Flags for SSL enable
-	sslEnableCmd.Flags().StringP("email", "e", "", "Email address for Let's Encrypt registration")
+	sslEnableCmd.Flags().StringP("email", "e", "", "Email address for Let's Encrypt registration (default: defaults.ssl_email)")
 	sslEnableCmd.Flags().StringP("type", "t", "", "Certificate type: selfsigned or letsencrypt (default: auto-detect)")
+	sslEnableCmd.Flags().StringP("challenge", "c", "", "Let's Encrypt challenge: http or dns (default: http, dns for wildcards)")
+	sslEnableCmd.Flags().BoolP("wildcard", "w", false, "Also issue a wildcard certificate (*.domain) via DNS-01")
+	sslEnableCmd.Flags().StringSlice("san", []string{}, "Additional subject alternative names (repeatable)")
+	sslEnableCmd.Flags().String("dns-provider", "", "DNS-01 provider: bind, cloudflare, route53 (default: bind if the zone is local)")
+	sslEnableCmd.Flags().String("acme-client", "", "ACME client: builtin or certbot (default: builtin, see 'webstack config set acme_client')")
+
+	sslImportCmd.Flags().String("cert", "", "PEM certificate file, optionally with the chain appended")
+	sslImportCmd.Flags().String("key", "", "PEM private key file")
+	sslImportCmd.Flags().String("chain", "", "PEM file with the intermediate certificates")
+	sslImportCmd.MarkFlagRequired("cert")
+	sslImportCmd.MarkFlagRequired("key")
+
+	sslRenewCmd.Flags().Bool("if-due", false, "Only renew if the certificate expires within 30 days")
 }
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"webstack-cli/internal/store"
+	"webstack-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Manage where webstack keeps its state (JSON files or SQLite)",
+	Long: `Show and change how domains.json, ssl.json and config.json are stored.
+
+By default they are JSON files in /etc/webstack. 'webstack state migrate' moves them
+into the SQLite database /etc/webstack/webstack.db; 'webstack state export' writes
+them out as JSON files again.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		showStateStatus()
+	},
+}
+
+var stateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the storage backend and the state documents",
+	Run: func(cmd *cobra.Command, args []string) {
+		showStateStatus()
+	},
+}
+
+var stateMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Move the state from JSON files into SQLite",
+	Long: `Create /etc/webstack/webstack.db and import domains.json, ssl.json and config.json.
+The JSON files are renamed to <file>.migrated so nothing writes to a stale copy.
+Documents created later are imported automatically the first time they are read.
+Example:
+  sudo webstack state migrate`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		imported, err := store.Migrate()
+		if err != nil {
+			ui.Fail("❌ Migration failed: %v\n", err)
+			return
+		}
+		for _, name := range imported {
+			fmt.Printf("✅ Imported %s\n", name)
+		}
+		fmt.Printf("✅ State is now stored in %s\n", store.DatabasePath)
+		fmt.Println("💡 Switch back any time with: sudo webstack state export --disable")
+	},
+}
+
+var stateExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write the state stored in SQLite out as JSON files",
+	Long: `Write every state document from /etc/webstack/webstack.db as a JSON file, e.g. to
+inspect or edit it, or to go back to JSON files with --disable (the database is kept
+next to them with a timestamp suffix). Examples:
+  sudo webstack state export
+  sudo webstack state export --dir /root/state
+  sudo webstack state export --disable`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		dir, _ := cmd.Flags().GetString("dir")
+		disable, _ := cmd.Flags().GetBool("disable")
+		if dir != "" && disable {
+			ui.Invalid("Invalid options: --disable writes the files to /etc/webstack, drop --dir\n")
+			return
+		}
+		if dir == "" && !disable {
+			dir = filepath.Join("/var/lib/webstack/state-export", time.Now().Format("20060102-150405"))
+		}
+		if dir != "" {
+			if err := os.MkdirAll(dir, 0700); err != nil {
+				ui.Fail("❌ Could not create %s: %v\n", dir, err)
+				return
+			}
+		}
+
+		written, err := store.Export(dir, disable)
+		for _, path := range written {
+			fmt.Printf("✅ Wrote %s\n", path)
+		}
+		if err != nil {
+			ui.Fail("❌ Export failed: %v\n", err)
+			return
+		}
+		if disable {
+			fmt.Println("✅ State is stored in JSON files again")
+		}
+	},
+}
+
+func showStateStatus() {
+	fmt.Printf("💾 Backend: %s\n", store.Backend())
+	if store.Backend() == "sqlite" {
+		fmt.Printf("   Database: %s\n", store.DatabasePath)
+	}
+	docs, err := store.Documents()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	if len(docs) == 0 {
+		fmt.Println("ℹ️  No state documents yet")
+		return
+	}
+	fmt.Printf("\n%-16s %-7s %-10s %s\n", "DOCUMENT", "SCHEMA", "SIZE", "UPDATED")
+	for _, doc := range docs {
+		updated := doc.UpdatedAt
+		if updated == "" {
+			updated = "-"
+		}
+		fmt.Printf("%-16s %-7d %-10d %s\n", doc.Name, doc.Schema, doc.Size, updated)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(stateCmd)
+	stateCmd.AddCommand(stateStatusCmd)
+	stateCmd.AddCommand(stateMigrateCmd)
+	stateCmd.AddCommand(stateExportCmd)
+
+	stateExportCmd.Flags().String("dir", "", "Directory to write the JSON files to (default /var/lib/webstack/state-export/<timestamp>)")
+	stateExportCmd.Flags().Bool("disable", false, "Write the files back to /etc/webstack and stop using the database")
+}
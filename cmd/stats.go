@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"webstack-cli/internal/domain"
+
+	"github.com/spf13/cobra"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats [domain]",
+	Short: "Show traffic statistics of a domain",
+	Long: `Report hits, bandwidth, unique IPs, status codes and the top URLs and IPs of
+a domain, parsed from its access logs in /var/www/<domain>/logs (rotated and
+compressed logs included).
+
+Usage:
+  webstack stats example.com                 # Last 24 hours
+  webstack stats example.com --since 7d      # Last 7 days (m, h, d, w or all)
+  webstack stats example.com --top 25
+  webstack stats example.com --json          # Machine-readable output for dashboards`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		since, _ := cmd.Flags().GetString("since")
+		top, _ := cmd.Flags().GetInt("top")
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+
+		domain.ShowStats(args[0], domain.StatsOptions{
+			Window: since,
+			Top:    top,
+			JSON:   jsonOutput,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	statsCmd.Flags().String("since", "24h", "Time window: e.g. 30m, 24h, 7d, 4w or all")
+	statsCmd.Flags().Int("top", 10, "Number of top URLs and IPs to show")
+	statsCmd.Flags().Bool("json", false, "Output as JSON")
+}
@@ -7,6 +7,16 @@ import (
 	"os/exec"
 	"strings"
 
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/firewall"
+	"webstack-cli/internal/installer"
+	"webstack-cli/internal/mysql"
+	"webstack-cli/internal/osinfo"
+	"webstack-cli/internal/pkg"
+	"webstack-cli/internal/postgres"
+	"webstack-cli/internal/service"
+	"webstack-cli/internal/ui"
+
 	"github.com/spf13/cobra"
 )
 
@@ -43,20 +53,23 @@ var statusCmd = &cobra.Command{
 var remoteAccessCmd = &cobra.Command{
 	Use:   "remote-access",
 	Short: "Configure remote database access",
-	Long:  `Enable or disable remote connections to MySQL/MariaDB/PostgreSQL.`,
+	Long:  `Enable or disable remote connections to MySQL/MariaDB/PostgreSQL/MongoDB.`,
 }
 
 var remoteAccessEnableCmd = &cobra.Command{
-	Use:   "enable [database] [user] [password]",
-	Short: "Enable remote access for a database",
-	Long: `Enable remote connections for MySQL, MariaDB, or PostgreSQL.
+	Use:         "enable [database] [user] [password]",
+	Short:       "Enable remote access for a database",
+	Annotations: map[string]string{secretArgs: "2"},
+	Long: `Enable remote connections for MySQL, MariaDB, PostgreSQL, or MongoDB.
 Usage: 
   webstack system remote-access enable mysql (interactive prompts)
   webstack system remote-access enable mysql root rootpass (with args)
-  webstack system remote-access enable mysql appuser apppass`,
+  webstack system remote-access enable mysql appuser apppass
+  webstack system remote-access enable postgresql postgres pass --cluster 16/main`,
 	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		dbType := strings.ToLower(args[0])
+		pgCluster, _ = cmd.Flags().GetString("cluster")
 		var user, password string
 		if len(args) >= 3 {
 			user = args[1]
@@ -71,13 +84,14 @@ Usage:
 var remoteAccessDisableCmd = &cobra.Command{
 	Use:   "disable [database] [user]",
 	Short: "Disable remote access for a database",
-	Long: `Disable remote connections for MySQL, MariaDB, or PostgreSQL.
+	Long: `Disable remote connections for MySQL, MariaDB, PostgreSQL, or MongoDB.
 Usage:
   webstack system remote-access disable mysql (interactive prompts)
   webstack system remote-access disable mysql root (with user)`,
 	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		dbType := strings.ToLower(args[0])
+		pgCluster, _ = cmd.Flags().GetString("cluster")
 		var user string
 		if len(args) >= 2 {
 			user = args[1]
@@ -91,10 +105,11 @@ Usage:
 var remoteAccessStatusCmd = &cobra.Command{
 	Use:   "status [database]",
 	Short: "Check remote access status for a database",
-	Long:  `Check if remote connections are enabled for MySQL, MariaDB, or PostgreSQL. Usage: webstack system remote-access status mysql`,
+	Long:  `Check if remote connections are enabled for MySQL, MariaDB, PostgreSQL, or MongoDB. Usage: webstack system remote-access status mysql`,
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		dbType := strings.ToLower(args[0])
+		pgCluster, _ = cmd.Flags().GetString("cluster")
 		checkRemoteAccessStatus(dbType)
 	},
 }
@@ -108,9 +123,9 @@ func reloadConfigurations(cmd *cobra.Command, args []string) {
 
 	// Reload Nginx
 	if isServiceActive("nginx") {
-		if err := runSystemCommand("systemctl", "reload", "nginx"); err != nil {
+		if err := service.Reload("nginx"); err != nil {
 			if !quiet {
-				fmt.Printf("❌ Failed to reload Nginx: %v\n", err)
+				ui.Fail("❌ Failed to reload Nginx: %v\n", err)
 			}
 		} else if !quiet {
 			fmt.Println("✅ Nginx configuration reloaded")
@@ -118,10 +133,10 @@ func reloadConfigurations(cmd *cobra.Command, args []string) {
 	}
 
 	// Reload Apache
-	if isServiceActive("apache2") {
-		if err := runSystemCommand("systemctl", "reload", "apache2"); err != nil {
+	if apache := osinfo.Current().Service("apache2"); isServiceActive(apache) {
+		if err := service.Reload(apache); err != nil {
 			if !quiet {
-				fmt.Printf("❌ Failed to reload Apache: %v\n", err)
+				ui.Fail("❌ Failed to reload Apache: %v\n", err)
 			}
 		} else if !quiet {
 			fmt.Println("✅ Apache configuration reloaded")
@@ -129,16 +144,19 @@ func reloadConfigurations(cmd *cobra.Command, args []string) {
 	}
 
 	// Reload PHP-FPM services
-	phpServices := []string{"php5.6-fpm", "php7.0-fpm", "php7.1-fpm", "php7.2-fpm", "php7.3-fpm", "php7.4-fpm", "php8.0-fpm", "php8.1-fpm", "php8.2-fpm", "php8.3-fpm", "php8.4-fpm"}
+	var phpServices []string
+	for _, version := range []string{"5.6", "7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3", "8.4"} {
+		phpServices = append(phpServices, config.GetPHPServiceName(version))
+	}
 
-	for _, service := range phpServices {
-		if isServiceActive(service) {
-			if err := runSystemCommand("systemctl", "reload", service); err != nil {
+	for _, phpService := range phpServices {
+		if isServiceActive(phpService) {
+			if err := service.Reload(phpService); err != nil {
 				if !quiet {
-					fmt.Printf("❌ Failed to reload %s: %v\n", service, err)
+					ui.Fail("❌ Failed to reload %s: %v\n", phpService, err)
 				}
 			} else if !quiet {
-				fmt.Printf("✅ %s configuration reloaded\n", service)
+				fmt.Printf("✅ %s configuration reloaded\n", phpService)
 			}
 		}
 	}
@@ -161,7 +179,7 @@ func validateConfigurations(cmd *cobra.Command, args []string) {
 	if isServiceInstalled("nginx") {
 		if err := runSystemCommand("nginx", "-t"); err != nil {
 			if !quiet {
-				fmt.Printf("❌ Nginx configuration validation failed: %v\n", err)
+				ui.Fail("❌ Nginx configuration validation failed: %v\n", err)
 			}
 			errors++
 		} else if !quiet {
@@ -170,10 +188,10 @@ func validateConfigurations(cmd *cobra.Command, args []string) {
 	}
 
 	// Validate Apache configuration
-	if isServiceInstalled("apache2") {
-		if err := runSystemCommand("apache2ctl", "configtest"); err != nil {
+	if isServiceInstalled(osinfo.Current().Service("apache2")) {
+		if err := runSystemCommand(osinfo.Current().ApacheCtl, "configtest"); err != nil {
 			if !quiet {
-				fmt.Printf("❌ Apache configuration validation failed: %v\n", err)
+				ui.Fail("❌ Apache configuration validation failed: %v\n", err)
 			}
 			errors++
 		} else if !quiet {
@@ -191,12 +209,12 @@ func validateConfigurations(cmd *cobra.Command, args []string) {
 		if errors == 0 {
 			fmt.Println("🎉 All configurations are valid")
 		} else {
-			fmt.Printf("⚠️  Found %d configuration errors\n", errors)
+			ui.Warn("⚠️  Found %d configuration errors\n", errors)
 		}
 	}
 
 	if errors > 0 {
-		os.Exit(1)
+		ui.Exit(ui.ExitFailure)
 	}
 }
 
@@ -239,7 +257,10 @@ func showSystemStatus(cmd *cobra.Command, args []string) {
 	fmt.Println()
 
 	// Check services
-	services := []string{"nginx", "apache2", "mysql", "mariadb", "postgresql"}
+	var services []string
+	for _, unit := range []string{"nginx", "apache2", "mysql", "mariadb", "postgresql", "mongod"} {
+		services = append(services, osinfo.Current().Service(unit))
+	}
 
 	fmt.Println("🔧 Services:")
 	for _, service := range services {
@@ -254,7 +275,10 @@ func showSystemStatus(cmd *cobra.Command, args []string) {
 
 	// Check PHP-FPM versions
 	fmt.Println("\n🐘 PHP-FPM Services:")
-	phpServices := []string{"php5.6-fpm", "php7.0-fpm", "php7.1-fpm", "php7.2-fpm", "php7.3-fpm", "php7.4-fpm", "php8.0-fpm", "php8.1-fpm", "php8.2-fpm", "php8.3-fpm", "php8.4-fpm"}
+	var phpServices []string
+	for _, version := range []string{"5.6", "7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3", "8.4"} {
+		phpServices = append(phpServices, config.GetPHPServiceName(version))
+	}
 
 	phpCount := 0
 	for _, service := range phpServices {
@@ -269,9 +293,66 @@ func showSystemStatus(cmd *cobra.Command, args []string) {
 		fmt.Println("  ⚠️  No PHP-FPM services running")
 	}
 
+	// Check object caches
+	printedCache := false
+	for _, cache := range installer.GetObjectCacheStatus() {
+		if !cache.Installed {
+			continue
+		}
+		if !printedCache {
+			fmt.Println("\n🗄️  Object Cache:")
+			printedCache = true
+		}
+		state := "Running"
+		icon := "✅"
+		if !cache.Running {
+			state = "Stopped"
+			icon = "❌"
+		}
+		details := []string{}
+		if cache.Memory != "" {
+			details = append(details, "memory "+cache.Memory)
+		}
+		if cache.Socket != "" {
+			details = append(details, "socket "+cache.Socket)
+		} else if cache.Running {
+			details = append(details, "no socket")
+		}
+		fmt.Printf("  %s %s: %s", icon, cache.Name, state)
+		if len(details) > 0 {
+			fmt.Printf(" (%s)", strings.Join(details, ", "))
+		}
+		fmt.Println()
+	}
+
+	// Summarize the mail queue so stuck mail shows up without postqueue
+	if queue := installer.GetMailQueueStatus(); queue.Installed {
+		fmt.Println("\n📮 Mail Queue:")
+		switch {
+		case queue.Deferred > 0:
+			fmt.Printf("  ⚠️  %d deferred message(s), oldest %s old", queue.Deferred, installer.FormatAge(queue.OldestAge))
+			if queue.OldestReason != "" {
+				fmt.Printf(": %s", queue.OldestReason)
+			}
+			fmt.Println()
+			fmt.Println("     See 'webstack mail queue list --queue deferred'")
+		case queue.Total > 0:
+			fmt.Printf("  ✅ %d message(s) being delivered\n", queue.Total-queue.Hold)
+		default:
+			fmt.Println("  ✅ Empty")
+		}
+		if queue.Hold > 0 {
+			fmt.Printf("  ℹ️  %d message(s) on hold\n", queue.Hold)
+		}
+	}
+
 	// Check disk space
 	fmt.Println("\n💾 Disk Usage:")
-	runSystemCommand("df", "-h", "/var/www", "/var/log", "/etc")
+	webRoot := config.FallbackWebRoot
+	if cfg, err := config.Load(); err == nil {
+		webRoot = cfg.WebRoot()
+	}
+	runSystemCommand("df", "-h", webRoot, "/var/log", "/etc")
 
 	// Check domains
 	// TODO: Show domain count and status
@@ -307,9 +388,11 @@ func enableRemoteAccessWithArgs(dbType, user, password string) {
 		enableMySQLRemoteAccessWithArgs(user, password) // Same as MySQL
 	case "postgresql":
 		enablePostgreSQLRemoteAccessWithArgs(user, password)
+	case "mongodb":
+		enableMongoDBRemoteAccessWithArgs(user, password)
 	default:
-		fmt.Printf("❌ Unknown database type: %s\n", dbType)
-		fmt.Println("Supported: mysql, mariadb, postgresql")
+		ui.Invalid("❌ Unknown database type: %s\n", dbType)
+		fmt.Println("Supported: mysql, mariadb, postgresql, mongodb")
 	}
 }
 
@@ -323,9 +406,11 @@ func disableRemoteAccessWithArgs(dbType, user string) {
 		disableMySQLRemoteAccessWithArgs(user)
 	case "postgresql":
 		disablePostgreSQLRemoteAccessWithArgs(user)
+	case "mongodb":
+		disableMongoDBRemoteAccessWithArgs(user)
 	default:
-		fmt.Printf("❌ Unknown database type: %s\n", dbType)
-		fmt.Println("Supported: mysql, mariadb, postgresql")
+		ui.Invalid("❌ Unknown database type: %s\n", dbType)
+		fmt.Println("Supported: mysql, mariadb, postgresql, mongodb")
 	}
 }
 
@@ -340,9 +425,11 @@ func enableRemoteAccess(dbType string) {
 		enableMariaDBRemoteAccess()
 	case "postgresql":
 		enablePostgreSQLRemoteAccess()
+	case "mongodb":
+		enableMongoDBRemoteAccess()
 	default:
-		fmt.Printf("❌ Unknown database type: %s\n", dbType)
-		fmt.Println("Supported: mysql, mariadb, postgresql")
+		ui.Invalid("❌ Unknown database type: %s\n", dbType)
+		fmt.Println("Supported: mysql, mariadb, postgresql, mongodb")
 	}
 }
 
@@ -356,9 +443,11 @@ func disableRemoteAccess(dbType string) {
 		disableMariaDBRemoteAccess()
 	case "postgresql":
 		disablePostgreSQLRemoteAccess()
+	case "mongodb":
+		disableMongoDBRemoteAccess()
 	default:
-		fmt.Printf("❌ Unknown database type: %s\n", dbType)
-		fmt.Println("Supported: mysql, mariadb, postgresql")
+		ui.Invalid("❌ Unknown database type: %s\n", dbType)
+		fmt.Println("Supported: mysql, mariadb, postgresql, mongodb")
 	}
 }
 
@@ -368,9 +457,11 @@ func checkRemoteAccessStatus(dbType string) {
 		checkMySQLRemoteAccessStatus(dbType)
 	case "postgresql":
 		checkPostgreSQLRemoteAccessStatus()
+	case "mongodb":
+		checkMongoDBRemoteAccessStatus()
 	default:
-		fmt.Printf("❌ Unknown database type: %s\n", dbType)
-		fmt.Println("Supported: mysql, mariadb, postgresql")
+		ui.Invalid("❌ Unknown database type: %s\n", dbType)
+		fmt.Println("Supported: mysql, mariadb, postgresql, mongodb")
 	}
 }
 
@@ -397,7 +488,7 @@ func enableMySQLRemoteAccess() {
 	case "1":
 		bindAddress = "0.0.0.0"
 		hostPattern = "%"
-		fmt.Println("⚠️  WARNING: Allowing connections from ANY IP is less secure!")
+		ui.Warn("⚠️  WARNING: Allowing connections from ANY IP is less secure!\n")
 	case "2":
 		fmt.Print("Enter IP address: ")
 		fmt.Scanln(&bindAddress)
@@ -416,7 +507,7 @@ func enableMySQLRemoteAccess() {
 	// Update config file
 	data, err := ioutil.ReadFile(configFile)
 	if err != nil {
-		fmt.Printf("❌ Error reading config: %v\n", err)
+		ui.Fail("❌ Error reading config: %v\n", err)
 		return
 	}
 
@@ -438,7 +529,7 @@ func enableMySQLRemoteAccess() {
 	}
 
 	if err := ioutil.WriteFile(configFile, []byte(content), 0644); err != nil {
-		fmt.Printf("❌ Error writing config: %v\n", err)
+		ui.Fail("❌ Error writing config: %v\n", err)
 		return
 	}
 
@@ -448,7 +539,7 @@ func enableMySQLRemoteAccess() {
 	}
 
 	if err := exec.Command("systemctl", "restart", service).Run(); err != nil {
-		fmt.Printf("❌ Error restarting %s: %v\n", service, err)
+		ui.Fail("❌ Error restarting %s: %v\n", service, err)
 		return
 	}
 
@@ -482,12 +573,11 @@ func enableMySQLRemoteAccess() {
 
 	// Update database user privileges
 	fmt.Printf("✓ Granting privileges to %s@%s...\n", dbUser, hostPattern)
-	grantCmd := fmt.Sprintf("GRANT ALL PRIVILEGES ON *.* TO '%s'@'%s' IDENTIFIED BY '%s' WITH GRANT OPTION; FLUSH PRIVILEGES;",
-		dbUser, hostPattern, userPassword)
+	grantCmd := fmt.Sprintf("GRANT ALL PRIVILEGES ON *.* TO %s IDENTIFIED BY %s WITH GRANT OPTION; FLUSH PRIVILEGES;",
+		mysql.Account(dbUser, hostPattern), mysql.Quote(userPassword))
 
-	mysqlCmd := exec.Command("mysql", "-u", adminUser, "-p"+adminPassword, "-e", grantCmd)
-	if err := mysqlCmd.Run(); err != nil {
-		fmt.Printf("Error granting privileges: %v\n", err)
+	if _, err := mysql.Exec(adminUser, adminPassword, grantCmd); err != nil {
+		ui.Fail("Error granting privileges: %v\n", err)
 		fmt.Println("   You may need to run manually:")
 		fmt.Printf("   mysql -u %s -p -e \"GRANT ALL PRIVILEGES ON *.* TO '%s'@'%s' WITH GRANT OPTION; FLUSH PRIVILEGES;\"\n", adminUser, dbUser, hostPattern)
 		return
@@ -495,11 +585,9 @@ func enableMySQLRemoteAccess() {
 
 	// Open firewall port 3306 for MySQL/MariaDB
 	fmt.Println("Opening firewall port 3306 for MySQL/MariaDB...")
-	exec.Command("iptables", "-A", "INPUT", "-p", "tcp", "--dport", "3306", "-j", "ACCEPT").Run()
-	exec.Command("ip6tables", "-A", "INPUT", "-p", "tcp", "--dport", "3306", "-j", "ACCEPT").Run()
-	// Persist rules
-	exec.Command("bash", "-c", "iptables-save > /etc/iptables/rules.v4 2>/dev/null || true").Run()
-	exec.Command("bash", "-c", "ip6tables-save > /etc/iptables/rules.v6 2>/dev/null || true").Run()
+	if err := firewall.Open("mysql", "tcp", 3306); err != nil {
+		ui.Warn("⚠️  Warning: Could not open port 3306: %v\n", err)
+	}
 
 	fmt.Printf("Remote access enabled for %s\n", service)
 	fmt.Printf("   Listening on: %s:3306\n", bindAddress)
@@ -515,7 +603,7 @@ func disableMySQLRemoteAccess() {
 
 	data, err := ioutil.ReadFile(configFile)
 	if err != nil {
-		fmt.Printf("Error reading config: %v\n", err)
+		ui.Fail("Error reading config: %v\n", err)
 		return
 	}
 
@@ -532,7 +620,7 @@ func disableMySQLRemoteAccess() {
 	}
 
 	if err := ioutil.WriteFile(configFile, []byte(content), 0644); err != nil {
-		fmt.Printf("Error writing config: %v\n", err)
+		ui.Fail("Error writing config: %v\n", err)
 		return
 	}
 
@@ -542,7 +630,7 @@ func disableMySQLRemoteAccess() {
 	}
 
 	if err := exec.Command("systemctl", "restart", service).Run(); err != nil {
-		fmt.Printf("Error restarting %s: %v\n", service, err)
+		ui.Fail("Error restarting %s: %v\n", service, err)
 		return
 	}
 
@@ -571,22 +659,19 @@ func disableMySQLRemoteAccess() {
 
 	// Revoke remote privileges and keep only localhost
 	fmt.Printf("✓ Revoking remote access privileges for %s...\n", dbUser)
-	revokeCmd := fmt.Sprintf("DELETE FROM mysql.user WHERE User='%s' AND Host NOT IN ('localhost', '127.0.0.1', '::1'); FLUSH PRIVILEGES;", dbUser)
+	revokeCmd := fmt.Sprintf("DELETE FROM mysql.user WHERE User=%s AND Host NOT IN ('localhost', '127.0.0.1', '::1'); FLUSH PRIVILEGES;", mysql.Quote(dbUser))
 
-	mysqlCmd := exec.Command("mysql", "-u", adminUser, "-p"+adminPassword, "-e", revokeCmd)
-	if err := mysqlCmd.Run(); err != nil {
-		fmt.Printf("⚠️  Warning: Could not revoke remote privileges: %v\n", err)
+	if _, err := mysql.Exec(adminUser, adminPassword, revokeCmd); err != nil {
+		ui.Warn("⚠️  Warning: Could not revoke remote privileges: %v\n", err)
 		fmt.Println("   You may need to run manually:")
 		fmt.Printf("   mysql -u %s -p -e \"DELETE FROM mysql.user WHERE User='%s' AND Host NOT IN ('localhost', '127.0.0.1', '::1'); FLUSH PRIVILEGES;\"\n", adminUser, dbUser)
 	}
 
 	// Close firewall port 3306 for MySQL/MariaDB
 	fmt.Println("🔒 Closing firewall port 3306...")
-	exec.Command("iptables", "-D", "INPUT", "-p", "tcp", "--dport", "3306", "-j", "ACCEPT").Run()
-	exec.Command("ip6tables", "-D", "INPUT", "-p", "tcp", "--dport", "3306", "-j", "ACCEPT").Run()
-	// Persist rules
-	exec.Command("bash", "-c", "iptables-save > /etc/iptables/rules.v4 2>/dev/null || true").Run()
-	exec.Command("bash", "-c", "ip6tables-save > /etc/iptables/rules.v6 2>/dev/null || true").Run()
+	if _, err := firewall.Close("mysql", "tcp", false, 3306); err != nil {
+		ui.Warn("⚠️  Warning: Could not close port 3306: %v\n", err)
+	}
 
 	fmt.Printf("✅ Remote access disabled for %s (localhost only)\n", service)
 }
@@ -605,7 +690,7 @@ func enableMySQLRemoteAccessWithArgs(user, password string) {
 	// Update config file
 	data, err := ioutil.ReadFile(configFile)
 	if err != nil {
-		fmt.Printf("❌ Error reading config: %v\n", err)
+		ui.Fail("❌ Error reading config: %v\n", err)
 		return
 	}
 
@@ -624,7 +709,7 @@ func enableMySQLRemoteAccessWithArgs(user, password string) {
 	}
 
 	if err := ioutil.WriteFile(configFile, []byte(content), 0644); err != nil {
-		fmt.Printf("❌ Error writing config: %v\n", err)
+		ui.Fail("❌ Error writing config: %v\n", err)
 		return
 	}
 
@@ -634,7 +719,7 @@ func enableMySQLRemoteAccessWithArgs(user, password string) {
 	}
 
 	if err := exec.Command("systemctl", "restart", service).Run(); err != nil {
-		fmt.Printf("❌ Error restarting %s: %v\n", service, err)
+		ui.Fail("❌ Error restarting %s: %v\n", service, err)
 		return
 	}
 
@@ -642,15 +727,13 @@ func enableMySQLRemoteAccessWithArgs(user, password string) {
 
 	// Grant privileges using provided credentials
 	fmt.Printf("✓ Granting privileges to %s@%s...\n", user, hostPattern)
-	grantCmd := fmt.Sprintf("GRANT ALL PRIVILEGES ON *.* TO '%s'@'%s' IDENTIFIED BY '%s' WITH GRANT OPTION; FLUSH PRIVILEGES;",
-		user, hostPattern, password)
+	grantCmd := fmt.Sprintf("GRANT ALL PRIVILEGES ON *.* TO %s IDENTIFIED BY %s WITH GRANT OPTION; FLUSH PRIVILEGES;",
+		mysql.Account(user, hostPattern), mysql.Quote(password))
 
-	mysqlCmd := exec.Command("mysql", "-u", "root", "-p"+password, "-e", grantCmd)
-	if err := mysqlCmd.Run(); err != nil {
+	if _, err := mysql.Exec("root", password, grantCmd); err != nil {
 		// Try with the provided user as admin
-		mysqlCmd = exec.Command("mysql", "-u", user, "-p"+password, "-e", grantCmd)
-		if err := mysqlCmd.Run(); err != nil {
-			fmt.Printf("❌ Error granting privileges: %v\n", err)
+		if _, err := mysql.Exec(user, password, grantCmd); err != nil {
+			ui.Fail("❌ Error granting privileges: %v\n", err)
 			fmt.Println("   You may need to run manually:")
 			fmt.Printf("   mysql -u root -p -e \"GRANT ALL PRIVILEGES ON *.* TO '%s'@'%s' WITH GRANT OPTION; FLUSH PRIVILEGES;\"\n", user, hostPattern)
 			return
@@ -671,7 +754,7 @@ func disableMySQLRemoteAccessWithArgs(user string) {
 
 	data, err := ioutil.ReadFile(configFile)
 	if err != nil {
-		fmt.Printf("❌ Error reading config: %v\n", err)
+		ui.Fail("❌ Error reading config: %v\n", err)
 		return
 	}
 
@@ -688,7 +771,7 @@ func disableMySQLRemoteAccessWithArgs(user string) {
 	}
 
 	if err := ioutil.WriteFile(configFile, []byte(content), 0644); err != nil {
-		fmt.Printf("❌ Error writing config: %v\n", err)
+		ui.Fail("❌ Error writing config: %v\n", err)
 		return
 	}
 
@@ -698,7 +781,7 @@ func disableMySQLRemoteAccessWithArgs(user string) {
 	}
 
 	if err := exec.Command("systemctl", "restart", service).Run(); err != nil {
-		fmt.Printf("❌ Error restarting %s: %v\n", service, err)
+		ui.Fail("❌ Error restarting %s: %v\n", service, err)
 		return
 	}
 
@@ -715,7 +798,7 @@ func checkMySQLRemoteAccessStatus(dbType string) {
 
 	data, err := ioutil.ReadFile(configFile)
 	if err != nil {
-		fmt.Printf("❌ Error reading config: %v\n", err)
+		ui.Fail("❌ Error reading config: %v\n", err)
 		return
 	}
 
@@ -737,15 +820,27 @@ func disableMariaDBRemoteAccess() {
 	disableMySQLRemoteAccess()
 }
 
+// pgCluster is the PostgreSQL cluster given with --cluster
+var pgCluster string
+
+// remoteAccessPostgreSQLCluster returns the cluster of --cluster, by default
+// the one on port 5432
+func remoteAccessPostgreSQLCluster() (postgres.Cluster, bool) {
+	cluster, err := postgres.Find(pgCluster)
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return cluster, false
+	}
+	return cluster, true
+}
+
 // PostgreSQL remote access functions
 func enablePostgreSQLRemoteAccess() {
-	matches, _ := exec.Command("bash", "-c", "ls /etc/postgresql/*/main/postgresql.conf 2>/dev/null | head -1").Output()
-	if len(matches) == 0 {
-		fmt.Println("❌ PostgreSQL configuration file not found")
+	cluster, ok := remoteAccessPostgreSQLCluster()
+	if !ok {
 		return
 	}
-
-	configFile := strings.TrimSpace(string(matches))
+	configFile := cluster.ConfigFile()
 
 	// Prompt user for IP/network
 	fmt.Println("\n📋 PostgreSQL Remote Access Configuration")
@@ -763,7 +858,7 @@ func enablePostgreSQLRemoteAccess() {
 	switch input {
 	case "1":
 		cidrAddress = "0.0.0.0/0"
-		fmt.Println("⚠️  WARNING: Allowing connections from ANY IP is less secure!")
+		ui.Warn("⚠️  WARNING: Allowing connections from ANY IP is less secure!\n")
 	case "2":
 		fmt.Print("Enter IP address (will use /32 for single host): ")
 		fmt.Scanln(&input)
@@ -779,7 +874,7 @@ func enablePostgreSQLRemoteAccess() {
 
 	data, err := ioutil.ReadFile(configFile)
 	if err != nil {
-		fmt.Printf("❌ Error reading config: %v\n", err)
+		ui.Fail("❌ Error reading config: %v\n", err)
 		return
 	}
 
@@ -791,11 +886,11 @@ func enablePostgreSQLRemoteAccess() {
 	}
 
 	if err := ioutil.WriteFile(configFile, []byte(content), 0644); err != nil {
-		fmt.Printf("❌ Error writing config: %v\n", err)
+		ui.Fail("❌ Error writing config: %v\n", err)
 		return
 	}
 
-	pgHbaFile := strings.ReplaceAll(configFile, "postgresql.conf", "pg_hba.conf")
+	pgHbaFile := cluster.HbaFile()
 	pgHbaData, _ := ioutil.ReadFile(pgHbaFile)
 	pgHbaContent := string(pgHbaData)
 
@@ -814,8 +909,8 @@ func enablePostgreSQLRemoteAccess() {
 	ioutil.WriteFile(pgHbaFile, []byte(pgHbaContent), 0644)
 	fmt.Println("✓ Updated pg_hba.conf to allow remote connections")
 
-	if err := exec.Command("systemctl", "restart", "postgresql").Run(); err != nil {
-		fmt.Printf("❌ Error restarting PostgreSQL: %v\n", err)
+	if err := exec.Command("systemctl", "restart", cluster.Unit()).Run(); err != nil {
+		ui.Fail("❌ Error restarting PostgreSQL: %v\n", err)
 		return
 	}
 
@@ -833,38 +928,34 @@ func enablePostgreSQLRemoteAccess() {
 	fmt.Scanln(&password)
 
 	fmt.Printf("✓ Setting password for %s user...\n", dbUser)
-	altersqlCmd := fmt.Sprintf("ALTER USER %s WITH PASSWORD '%s';", dbUser, password)
-	psqlCmd := exec.Command("sudo", "-u", "postgres", "psql", "-c", altersqlCmd)
+	altersqlCmd := fmt.Sprintf("ALTER USER %s WITH PASSWORD %s;", postgres.QuoteIdent(dbUser), postgres.Quote(password))
+	psqlCmd := cluster.SQL(altersqlCmd)
 	if err := psqlCmd.Run(); err != nil {
-		fmt.Printf("⚠️  Warning: Could not set password: %v\n", err)
+		ui.Warn("⚠️  Warning: Could not set password: %v\n", err)
 		fmt.Println("   You may need to run manually:")
 		fmt.Printf("   sudo -u postgres psql -c \"ALTER USER %s WITH PASSWORD 'your_password';\"\n", dbUser)
 	}
 
-	// Open firewall port 5432 for PostgreSQL
-	fmt.Println("🔥 Opening firewall port 5432 for PostgreSQL...")
-	exec.Command("iptables", "-A", "INPUT", "-p", "tcp", "--dport", "5432", "-j", "ACCEPT").Run()
-	exec.Command("ip6tables", "-A", "INPUT", "-p", "tcp", "--dport", "5432", "-j", "ACCEPT").Run()
-	// Persist rules
-	exec.Command("bash", "-c", "iptables-save > /etc/iptables/rules.v4 2>/dev/null || true").Run()
-	exec.Command("bash", "-c", "ip6tables-save > /etc/iptables/rules.v6 2>/dev/null || true").Run()
+	// Open the firewall port of the cluster
+	fmt.Printf("🔥 Opening firewall port %d for PostgreSQL...\n", cluster.Port)
+	if err := firewall.Open("postgresql", "tcp", cluster.Port); err != nil {
+		ui.Warn("⚠️  Warning: Could not open port %d: %v\n", cluster.Port, err)
+	}
 
-	fmt.Println("✅ Remote access enabled for PostgreSQL")
-	fmt.Printf("   Listening on: 0.0.0.0:5432 (from %s)\n", cidrAddress)
-	fmt.Printf("   User '%s' can connect from: psql -U %s -h <server-ip> -d postgres\n", dbUser, dbUser)
+	fmt.Printf("✅ Remote access enabled for PostgreSQL %s\n", cluster.ID())
+	fmt.Printf("   Listening on: 0.0.0.0:%d (from %s)\n", cluster.Port, cidrAddress)
+	fmt.Printf("   User '%s' can connect from: psql -U %s -h <server-ip> -p %d -d postgres\n", dbUser, dbUser, cluster.Port)
 }
 
 func disablePostgreSQLRemoteAccess() {
-	matches, _ := exec.Command("bash", "-c", "ls /etc/postgresql/*/main/postgresql.conf 2>/dev/null | head -1").Output()
-	if len(matches) == 0 {
-		fmt.Println("❌ PostgreSQL configuration file not found")
+	cluster, ok := remoteAccessPostgreSQLCluster()
+	if !ok {
 		return
 	}
-
-	configFile := strings.TrimSpace(string(matches))
+	configFile := cluster.ConfigFile()
 	data, err := ioutil.ReadFile(configFile)
 	if err != nil {
-		fmt.Printf("❌ Error reading config: %v\n", err)
+		ui.Fail("❌ Error reading config: %v\n", err)
 		return
 	}
 
@@ -874,12 +965,12 @@ func disablePostgreSQLRemoteAccess() {
 	}
 
 	if err := ioutil.WriteFile(configFile, []byte(content), 0644); err != nil {
-		fmt.Printf("❌ Error writing config: %v\n", err)
+		ui.Fail("❌ Error writing config: %v\n", err)
 		return
 	}
 
-	if err := exec.Command("systemctl", "restart", "postgresql").Run(); err != nil {
-		fmt.Printf("❌ Error restarting PostgreSQL: %v\n", err)
+	if err := exec.Command("systemctl", "restart", cluster.Unit()).Run(); err != nil {
+		ui.Fail("❌ Error restarting PostgreSQL: %v\n", err)
 		return
 	}
 
@@ -901,39 +992,35 @@ func disablePostgreSQLRemoteAccess() {
 		var password string
 		fmt.Scanln(&password)
 
-		resetCmd := fmt.Sprintf("ALTER USER %s WITH PASSWORD '%s';", dbUser, password)
-		psqlCmd := exec.Command("sudo", "-u", "postgres", "psql", "-c", resetCmd)
+		resetCmd := fmt.Sprintf("ALTER USER %s WITH PASSWORD %s;", postgres.QuoteIdent(dbUser), postgres.Quote(password))
+		psqlCmd := cluster.SQL(resetCmd)
 		if err := psqlCmd.Run(); err != nil {
-			fmt.Printf("⚠️  Warning: Could not reset password: %v\n", err)
+			ui.Warn("⚠️  Warning: Could not reset password: %v\n", err)
 		}
 	}
 
-	// Close firewall port 5432 for PostgreSQL
-	fmt.Println("🔒 Closing firewall port 5432...")
-	exec.Command("iptables", "-D", "INPUT", "-p", "tcp", "--dport", "5432", "-j", "ACCEPT").Run()
-	exec.Command("ip6tables", "-D", "INPUT", "-p", "tcp", "--dport", "5432", "-j", "ACCEPT").Run()
-	// Persist rules
-	exec.Command("bash", "-c", "iptables-save > /etc/iptables/rules.v4 2>/dev/null || true").Run()
-	exec.Command("bash", "-c", "ip6tables-save > /etc/iptables/rules.v6 2>/dev/null || true").Run()
+	// Close the firewall port of the cluster
+	fmt.Printf("🔒 Closing firewall port %d...\n", cluster.Port)
+	if _, err := firewall.Close("postgresql", "tcp", false, cluster.Port); err != nil {
+		ui.Warn("⚠️  Warning: Could not close port %d: %v\n", cluster.Port, err)
+	}
 
-	fmt.Printf("✅ Remote access disabled for PostgreSQL (localhost only)\n")
+	fmt.Printf("✅ Remote access disabled for PostgreSQL %s (localhost only)\n", cluster.ID())
 	fmt.Printf("   User '%s' - remote connections revoked\n", dbUser)
 }
 
 // PostgreSQL functions with direct arguments (non-interactive)
 func enablePostgreSQLRemoteAccessWithArgs(user, password string) {
-	matches, _ := exec.Command("bash", "-c", "ls /etc/postgresql/*/main/postgresql.conf 2>/dev/null | head -1").Output()
-	if len(matches) == 0 {
-		fmt.Println("❌ PostgreSQL configuration file not found")
+	cluster, ok := remoteAccessPostgreSQLCluster()
+	if !ok {
 		return
 	}
-
-	configFile := strings.TrimSpace(string(matches))
+	configFile := cluster.ConfigFile()
 	cidrAddress := "0.0.0.0/0"
 
 	data, err := ioutil.ReadFile(configFile)
 	if err != nil {
-		fmt.Printf("❌ Error reading config: %v\n", err)
+		ui.Fail("❌ Error reading config: %v\n", err)
 		return
 	}
 
@@ -945,11 +1032,11 @@ func enablePostgreSQLRemoteAccessWithArgs(user, password string) {
 	}
 
 	if err := ioutil.WriteFile(configFile, []byte(content), 0644); err != nil {
-		fmt.Printf("❌ Error writing config: %v\n", err)
+		ui.Fail("❌ Error writing config: %v\n", err)
 		return
 	}
 
-	pgHbaFile := strings.ReplaceAll(configFile, "postgresql.conf", "pg_hba.conf")
+	pgHbaFile := cluster.HbaFile()
 	pgHbaData, _ := ioutil.ReadFile(pgHbaFile)
 	pgHbaContent := string(pgHbaData)
 
@@ -968,36 +1055,34 @@ func enablePostgreSQLRemoteAccessWithArgs(user, password string) {
 	ioutil.WriteFile(pgHbaFile, []byte(pgHbaContent), 0644)
 	fmt.Println("✓ Updated pg_hba.conf to allow remote connections")
 
-	if err := exec.Command("systemctl", "restart", "postgresql").Run(); err != nil {
-		fmt.Printf("❌ Error restarting PostgreSQL: %v\n", err)
+	if err := exec.Command("systemctl", "restart", cluster.Unit()).Run(); err != nil {
+		ui.Fail("❌ Error restarting PostgreSQL: %v\n", err)
 		return
 	}
 
 	fmt.Printf("✓ Setting password for %s user...\n", user)
-	altersqlCmd := fmt.Sprintf("ALTER USER %s WITH PASSWORD '%s';", user, password)
-	psqlCmd := exec.Command("sudo", "-u", "postgres", "psql", "-c", altersqlCmd)
+	altersqlCmd := fmt.Sprintf("ALTER USER %s WITH PASSWORD %s;", postgres.QuoteIdent(user), postgres.Quote(password))
+	psqlCmd := cluster.SQL(altersqlCmd)
 	if err := psqlCmd.Run(); err != nil {
-		fmt.Printf("⚠️  Warning: Could not set password: %v\n", err)
+		ui.Warn("⚠️  Warning: Could not set password: %v\n", err)
 		fmt.Println("   You may need to run manually:")
 		fmt.Printf("   sudo -u postgres psql -c \"ALTER USER %s WITH PASSWORD 'your_password';\"\n", user)
 	}
 
-	fmt.Println("✅ Remote access enabled for PostgreSQL")
-	fmt.Printf("   Listening on: 0.0.0.0:5432 (from %s)\n", cidrAddress)
-	fmt.Printf("   User '%s' can connect from: psql -U %s -h <server-ip> -d postgres\n", user, user)
+	fmt.Printf("✅ Remote access enabled for PostgreSQL %s\n", cluster.ID())
+	fmt.Printf("   Listening on: 0.0.0.0:%d (from %s)\n", cluster.Port, cidrAddress)
+	fmt.Printf("   User '%s' can connect from: psql -U %s -h <server-ip> -p %d -d postgres\n", user, user, cluster.Port)
 }
 
 func disablePostgreSQLRemoteAccessWithArgs(user string) {
-	matches, _ := exec.Command("bash", "-c", "ls /etc/postgresql/*/main/postgresql.conf 2>/dev/null | head -1").Output()
-	if len(matches) == 0 {
-		fmt.Println("❌ PostgreSQL configuration file not found")
+	cluster, ok := remoteAccessPostgreSQLCluster()
+	if !ok {
 		return
 	}
-
-	configFile := strings.TrimSpace(string(matches))
+	configFile := cluster.ConfigFile()
 	data, err := ioutil.ReadFile(configFile)
 	if err != nil {
-		fmt.Printf("❌ Error reading config: %v\n", err)
+		ui.Fail("❌ Error reading config: %v\n", err)
 		return
 	}
 
@@ -1007,39 +1092,184 @@ func disablePostgreSQLRemoteAccessWithArgs(user string) {
 	}
 
 	if err := ioutil.WriteFile(configFile, []byte(content), 0644); err != nil {
-		fmt.Printf("❌ Error writing config: %v\n", err)
+		ui.Fail("❌ Error writing config: %v\n", err)
 		return
 	}
 
-	if err := exec.Command("systemctl", "restart", "postgresql").Run(); err != nil {
-		fmt.Printf("❌ Error restarting PostgreSQL: %v\n", err)
+	if err := exec.Command("systemctl", "restart", cluster.Unit()).Run(); err != nil {
+		ui.Fail("❌ Error restarting PostgreSQL: %v\n", err)
 		return
 	}
 
-	fmt.Printf("✅ Remote access disabled for PostgreSQL (localhost only)\n")
+	fmt.Printf("✅ Remote access disabled for PostgreSQL %s (localhost only)\n", cluster.ID())
 	fmt.Printf("   User '%s' - remote connections revoked\n", user)
 }
 
 func checkPostgreSQLRemoteAccessStatus() {
-	matches, _ := exec.Command("bash", "-c", "ls /etc/postgresql/*/main/postgresql.conf 2>/dev/null | head -1").Output()
-	if len(matches) == 0 {
-		fmt.Println("❌ PostgreSQL configuration file not found")
+	cluster, ok := remoteAccessPostgreSQLCluster()
+	if !ok {
 		return
 	}
-
-	configFile := strings.TrimSpace(string(matches))
+	configFile := cluster.ConfigFile()
 	data, err := ioutil.ReadFile(configFile)
 	if err != nil {
-		fmt.Printf("❌ Error reading config: %v\n", err)
+		ui.Fail("❌ Error reading config: %v\n", err)
 		return
 	}
 
 	content := string(data)
 	if strings.Contains(content, "listen_addresses = '*'") {
-		fmt.Println("🔓 Remote access is ENABLED for PostgreSQL")
+		fmt.Printf("🔓 Remote access is ENABLED for PostgreSQL %s (port %d)\n", cluster.ID(), cluster.Port)
 		fmt.Println("   Any client can connect if they have valid credentials")
 	} else {
-		fmt.Println("🔒 Remote access is DISABLED for PostgreSQL")
+		fmt.Printf("🔒 Remote access is DISABLED for PostgreSQL %s (port %d)\n", cluster.ID(), cluster.Port)
+		fmt.Println("   Only localhost connections are allowed")
+	}
+}
+
+// MongoDB remote access functions
+func enableMongoDBRemoteAccess() {
+	if _, err := os.Stat(installer.MongoDBConfigFile); err != nil {
+		ui.Fail("❌ MongoDB configuration file not found\n")
+		return
+	}
+
+	// Prompt user for IP/network
+	fmt.Println("\n📋 MongoDB Remote Access Configuration")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Println("Allow connections from:")
+	fmt.Println("  1. Any IP (0.0.0.0/0) - LESS SECURE")
+	fmt.Println("  2. Specific IP address")
+	fmt.Println("  3. Specific subnet (e.g., 192.168.1.0/24)")
+	fmt.Print("\nEnter choice (1-3) or custom address: ")
+
+	var input string
+	fmt.Scanln(&input)
+
+	var cidrAddress string
+	switch input {
+	case "1":
+		cidrAddress = "0.0.0.0/0"
+		ui.Warn("⚠️  WARNING: Allowing connections from ANY IP is less secure!\n")
+	case "2":
+		fmt.Print("Enter IP address (will use /32 for single host): ")
+		fmt.Scanln(&input)
+		cidrAddress = input + "/32"
+	case "3":
+		fmt.Print("Enter subnet (e.g., 192.168.1.0/24): ")
+		fmt.Scanln(&cidrAddress)
+	default:
+		cidrAddress = input
+	}
+
+	fmt.Printf("\n✓ Allowing connections from: %s\n", cidrAddress)
+
+	fmt.Print("\n👤 Enter MongoDB user to grant remote access (default: admin): ")
+	var dbUser string
+	fmt.Scanln(&dbUser)
+	if dbUser == "" {
+		dbUser = "admin"
+	}
+
+	fmt.Printf("🔐 Enter password for user '%s': ", dbUser)
+	var password string
+	fmt.Scanln(&password)
+
+	setMongoDBRemoteAccess(dbUser, password, cidrAddress)
+}
+
+func disableMongoDBRemoteAccess() {
+	fmt.Print("\n👤 Enter MongoDB user to revoke remote access (default: admin): ")
+	var dbUser string
+	fmt.Scanln(&dbUser)
+	if dbUser == "" {
+		dbUser = "admin"
+	}
+	disableMongoDBRemoteAccessWithArgs(dbUser)
+}
+
+// MongoDB functions with direct arguments (non-interactive)
+func enableMongoDBRemoteAccessWithArgs(user, password string) {
+	if _, err := os.Stat(installer.MongoDBConfigFile); err != nil {
+		ui.Fail("❌ MongoDB configuration file not found\n")
+		return
+	}
+	setMongoDBRemoteAccess(user, password, "0.0.0.0/0")
+}
+
+func disableMongoDBRemoteAccessWithArgs(user string) {
+	if _, err := os.Stat(installer.MongoDBConfigFile); err != nil {
+		ui.Fail("❌ MongoDB configuration file not found\n")
+		return
+	}
+
+	if err := installer.SetMongoDBBindIP("127.0.0.1"); err != nil {
+		ui.Fail("❌ Error updating MongoDB: %v\n", err)
+		return
+	}
+
+	fmt.Printf("🔒 Closing firewall port %d...\n", installer.MongoDBPort)
+	if _, err := firewall.Close("mongodb", "tcp", false, installer.MongoDBPort); err != nil {
+		ui.Warn("⚠️  Warning: Could not close port %d: %v\n", installer.MongoDBPort, err)
+	}
+
+	fmt.Printf("✅ Remote access disabled for MongoDB (localhost only)\n")
+	fmt.Printf("   User '%s' - remote connections revoked\n", user)
+}
+
+// setMongoDBRemoteAccess makes mongod listen on all addresses and sets the
+// password of a user, who may only connect from cidrAddress and localhost
+func setMongoDBRemoteAccess(user, password, cidrAddress string) {
+	if password == "" {
+		ui.Fail("❌ A password is required for remote MongoDB users\n")
+		return
+	}
+
+	var clientSources []string
+	if cidrAddress != "0.0.0.0/0" {
+		clientSources = []string{cidrAddress, "127.0.0.1", "::1"}
+	}
+	fmt.Printf("✓ Setting password for %s user...\n", user)
+	if err := installer.MongoDBEval(installer.MongoDBUserScript(user, password, clientSources)); err != nil {
+		ui.Fail("❌ Could not update MongoDB user %s: %v\n", user, err)
+		return
+	}
+
+	if err := installer.SetMongoDBBindIP("0.0.0.0"); err != nil {
+		ui.Fail("❌ Error updating MongoDB: %v\n", err)
+		return
+	}
+	fmt.Printf("✓ Updated %s to listen on all addresses\n", installer.MongoDBConfigFile)
+
+	fmt.Printf("🔥 Opening firewall port %d for MongoDB...\n", installer.MongoDBPort)
+	if err := firewall.Open("mongodb", "tcp", installer.MongoDBPort); err != nil {
+		ui.Warn("⚠️  Warning: Could not open port %d: %v\n", installer.MongoDBPort, err)
+	}
+
+	fmt.Println("✅ Remote access enabled for MongoDB")
+	fmt.Printf("   Listening on: 0.0.0.0:%d (from %s)\n", installer.MongoDBPort, cidrAddress)
+	fmt.Printf("   User '%s' can connect from: mongosh \"mongodb://%s@<server-ip>:%d/admin\"\n", user, user, installer.MongoDBPort)
+}
+
+func checkMongoDBRemoteAccessStatus() {
+	if _, err := os.Stat(installer.MongoDBConfigFile); err != nil {
+		ui.Fail("❌ MongoDB configuration file not found\n")
+		return
+	}
+
+	bindIP := installer.MongoDBBindIP()
+	local := true
+	for _, address := range strings.Split(bindIP, ",") {
+		address = strings.TrimSpace(address)
+		if address != "" && address != "127.0.0.1" && address != "::1" && address != "localhost" {
+			local = false
+		}
+	}
+	if !local {
+		fmt.Println("🔓 Remote access is ENABLED for MongoDB")
+		fmt.Printf("   Listening on: %s\n", bindIP)
+	} else {
+		fmt.Println("🔒 Remote access is DISABLED for MongoDB")
 		fmt.Println("   Only localhost connections are allowed")
 	}
 }
@@ -1049,12 +1279,11 @@ func setupCoreSecurity() {
 
 	// Remove UFW if installed (conflicts with iptables)
 	fmt.Println("   Checking for UFW conflicts...")
-	ufwOutput, err := exec.Command("dpkg", "-l").Output()
-	if err == nil && strings.Contains(string(ufwOutput), "ufw") {
+	if pkg.Installed("ufw") {
 		fmt.Println("   ⚠️  UFW detected, removing to avoid conflicts with iptables...")
 		exec.Command("bash", "-c", "systemctl disable ufw 2>/dev/null || true").Run()
 		exec.Command("bash", "-c", "systemctl stop ufw 2>/dev/null || true").Run()
-		exec.Command("apt", "remove", "-y", "ufw").Run()
+		pkg.Purge("ufw")
 		fmt.Println("   ✓ UFW removed")
 	}
 
@@ -1068,13 +1297,12 @@ func setupCoreSecurity() {
 
 	// Update package list
 	fmt.Println("   Updating package list...")
-	exec.Command("apt", "update").Run()
+	pkg.Update()
 
 	// Install core security packages
 	fmt.Println("   Installing security packages...")
-	args := append([]string{"install", "-y", "--no-install-recommends"}, coreSecurityPkgs...)
-	if err := exec.Command("apt", args...).Run(); err != nil {
-		fmt.Printf("⚠️  Warning installing security packages: %v\n", err)
+	if err := pkg.InstallMinimal(coreSecurityPkgs...); err != nil {
+		ui.Warn("⚠️  Warning installing security packages: %v\n", err)
 		// Don't return - these might already be installed
 	}
 
@@ -1111,9 +1339,7 @@ func init() {
 	remoteAccessCmd.AddCommand(remoteAccessEnableCmd)
 	remoteAccessCmd.AddCommand(remoteAccessDisableCmd)
 	remoteAccessCmd.AddCommand(remoteAccessStatusCmd)
-
-	// Add quiet flag to system commands
-	reloadCmd.Flags().Bool("quiet", false, "Suppress output")
-	validateCmd.Flags().Bool("quiet", false, "Suppress output")
-	cleanupCmd.Flags().Bool("quiet", false, "Suppress output")
+	for _, c := range []*cobra.Command{remoteAccessEnableCmd, remoteAccessDisableCmd, remoteAccessStatusCmd} {
+		c.Flags().String("cluster", "", "PostgreSQL cluster, e.g. 16/main (default: the one on port 5432)")
+	}
 }
@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/templates"
+	"webstack-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var templateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "Develop, test and customize templates",
+	Long: `Tools for template authors: render vhost templates with sample variables and check them without touching live configurations.
+
+Templates are embedded in the binary. A copy in /etc/webstack/templates (same path, e.g.
+nginx/domain.conf or php-fpm/pool.conf) takes precedence over the embedded template, so
+'domain add' and 'domain rebuild-configs' use the customized variant. 'template export'
+records the version of the embedded template it was copied from; 'template list' marks
+overrides as outdated when a newer webstack ships a changed template.
+Examples:
+  sudo webstack template export nginx/domain-ssl.conf
+  webstack template list --customized
+  sudo webstack template validate
+  sudo webstack template reset nginx/domain-ssl.conf`,
+}
+
+var templateTestCmd = &cobra.Command{
+	Use:   "test [template]",
+	Short: "Render a template into a sandbox and syntax-check it",
+	Long: `Render a vhost template with sample variables into a sandbox directory and run
+the web server's syntax check (nginx -t / apache2ctl -t) against a minimal main
+configuration that only includes it. Live configurations are never touched.
+
+The template is a file on disk or an embedded template (domain.conf,
+nginx/proxy-ssl.conf, apache/domain.conf). Variables default to sample values
+pointing into the sandbox; keys in the --vars JSON object override them.
+
+Usage:
+  webstack template test domain-ssl.conf
+  webstack template test ./my-domain.conf --vars vars.json
+  webstack template test ./my-domain.conf --vars vars.json --watch
+  webstack template test apache/domain.conf --vars vars.json`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		vars, _ := cmd.Flags().GetString("vars")
+		server, _ := cmd.Flags().GetString("server")
+		sandbox, _ := cmd.Flags().GetString("sandbox")
+		watch, _ := cmd.Flags().GetBool("watch")
+
+		domain.TestTemplate(domain.TemplateTestOptions{
+			Template: args[0],
+			Server:   server,
+			VarsFile: vars,
+			Sandbox:  sandbox,
+			Watch:    watch,
+		})
+	},
+}
+
+var templateListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the templates, their version and whether they are customized",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		customized, _ := cmd.Flags().GetBool("customized")
+
+		list := templates.List()
+		if customized {
+			list = templates.Overrides()
+			if len(list) == 0 {
+				fmt.Printf("ℹ️  No customized templates in %s\n", templates.OverrideDir)
+				fmt.Println("   Customize one with: sudo webstack template export <template>")
+				return
+			}
+		}
+
+		outdated := 0
+		fmt.Printf("%-34s %-12s %-12s %s\n", "TEMPLATE", "VERSION", "STATUS", "EXPORTED FROM")
+		for _, info := range list {
+			version := info.Version
+			if version == "" {
+				version = "-"
+			}
+			fmt.Printf("%-34s %-12s %-12s %s\n", info.Path, version, info.Status, info.BaseVersion)
+			if info.Status == templates.StatusOutdated {
+				outdated++
+			}
+		}
+		if outdated > 0 {
+			fmt.Println()
+			ui.Warn("⚠️  %d customized template(s) were exported from an older built-in version\n", outdated)
+			fmt.Println("   Compare with: diff <(webstack template export <template> --stdout) /etc/webstack/templates/<template>")
+		}
+	},
+}
+
+var templateExportCmd = &cobra.Command{
+	Use:   "export [template|directory]",
+	Short: "Copy embedded templates to /etc/webstack/templates for customizing",
+	Long: `Copy an embedded template, a directory of templates (nginx, presets/wordpress) or all
+of them to /etc/webstack/templates, where they take precedence over the embedded ones.
+Existing customized templates are kept unless --force is given.
+Examples:
+  sudo webstack template export nginx/domain-ssl.conf
+  sudo webstack template export php-fpm
+  webstack template export nginx/domain.conf --stdout`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		force, _ := cmd.Flags().GetBool("force")
+		stdout, _ := cmd.Flags().GetBool("stdout")
+
+		name := ""
+		if len(args) == 1 {
+			name = args[0]
+		}
+		if stdout {
+			data, err := templates.FS.ReadFile(path.Clean(strings.Trim(name, "/")))
+			if err != nil {
+				ui.Fail("❌ No embedded template %s\n", name)
+				return
+			}
+			os.Stdout.Write(data)
+			return
+		}
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+
+		written, err := templates.Export(name, force)
+		for _, file := range written {
+			fmt.Printf("✅ Exported %s\n", file)
+		}
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		if len(written) > 0 {
+			fmt.Println("💡 Edit the files, then check and apply them:")
+			fmt.Println("   sudo webstack template validate && sudo webstack domain rebuild-configs")
+		}
+	},
+}
+
+var templateValidateCmd = &cobra.Command{
+	Use:   "validate [template]",
+	Short: "Check customized templates before they are used",
+	Long: `Parse the customized templates in /etc/webstack/templates, or the given one, and render
+vhost templates with sample variables into a sandbox for the web server's syntax check
+(see 'webstack template test'). Live configurations are never touched.
+Examples:
+  sudo webstack template validate
+  sudo webstack template validate nginx/domain-ssl.conf`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		overrides := templates.Overrides()
+		if len(args) == 1 {
+			name := path.Clean(strings.Trim(args[0], "/"))
+			var selected []templates.Info
+			for _, info := range overrides {
+				if info.Path == name || strings.HasPrefix(info.Path, name+"/") {
+					selected = append(selected, info)
+				}
+			}
+			overrides = selected
+		}
+		if len(overrides) == 0 {
+			fmt.Printf("ℹ️  No customized templates to validate in %s\n", templates.OverrideDir)
+			return
+		}
+
+		failed := 0
+		for _, info := range overrides {
+			fmt.Printf("🔍 %s\n", info.Override)
+			if err := templates.Parse(info.Path); err != nil {
+				ui.Fail("❌ %v\n", err)
+				failed++
+				continue
+			}
+			if !templates.IsVhost(info.Path) {
+				fmt.Println("✅ Template syntax is valid")
+				continue
+			}
+			if !domain.CheckTemplate(info.Override, strings.SplitN(info.Path, "/", 2)[0]) {
+				failed++
+			}
+			if info.Status == templates.StatusOutdated {
+				ui.Warn("⚠️  The built-in template changed since this one was exported\n")
+			}
+		}
+
+		fmt.Println()
+		if failed > 0 {
+			ui.Fail("❌ %d of %d customized template(s) failed validation\n", failed, len(overrides))
+			return
+		}
+		fmt.Printf("✅ %d customized template(s) are valid\n", len(overrides))
+	},
+}
+
+var templateResetCmd = &cobra.Command{
+	Use:   "reset [template|directory]",
+	Short: "Remove customized templates so the embedded ones are used again",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		all, _ := cmd.Flags().GetBool("all")
+		if len(args) == 0 && !all {
+			ui.Fail("❌ Give a template or directory, or --all to reset every customized template\n")
+			return
+		}
+
+		name := ""
+		if len(args) == 1 {
+			name = args[0]
+		}
+		removed, err := templates.Reset(name)
+		for _, file := range removed {
+			fmt.Printf("✅ Removed %s\n", file)
+		}
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		fmt.Println("💡 Apply the embedded templates with: sudo webstack domain rebuild-configs")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(templateCmd)
+	templateCmd.AddCommand(templateTestCmd)
+	templateCmd.AddCommand(templateListCmd)
+	templateCmd.AddCommand(templateExportCmd)
+	templateCmd.AddCommand(templateValidateCmd)
+	templateCmd.AddCommand(templateResetCmd)
+
+	// Flags for template test
+	templateTestCmd.Flags().String("vars", "", "JSON file with template variables")
+	templateTestCmd.Flags().String("server", "", "Web server of the template: nginx or apache (detected from the name)")
+	templateTestCmd.Flags().String("sandbox", domain.DefaultSandbox, "Directory the template is rendered into")
+	templateTestCmd.Flags().Bool("watch", false, "Re-run the test whenever the template or variables file changes")
+
+	templateListCmd.Flags().Bool("customized", false, "Only list customized templates")
+	templateExportCmd.Flags().Bool("force", false, "Overwrite customized templates")
+	templateExportCmd.Flags().Bool("stdout", false, "Print the embedded template instead of exporting it")
+	templateResetCmd.Flags().Bool("all", false, "Reset every customized template")
+}
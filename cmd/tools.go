@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"webstack-cli/internal/installer"
+	"webstack-cli/internal/tools"
+	"webstack-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var toolsCmd = &cobra.Command{
+	Use:   "tools",
+	Short: "Manage web admin tools and the developer toolchain",
+	Long: `Install, upgrade and remove web admin tools (phpMyAdmin) and command-line tools
+(composer, wp-cli, node).
+
+A web tool is served under a path on every domain and the server IP (/phpmyadmin
+by default) or on a dedicated host, and can be restricted to IP addresses and put
+behind basic auth. A command-line tool is installed into /usr/local/bin at a pinned
+release, checked against its published checksum, and only changes with 'upgrade'.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		tools.Status()
+	},
+}
+
+var toolsInstallCmd = &cobra.Command{
+	Use:   "install [tool]",
+	Short: "Install a tool, or change how an installed one is served",
+	Long: `Download a web tool and wire it into Nginx (or Apache on Apache-only servers),
+or install a command-line tool at a pinned release. Running it again for an
+installed web tool changes the path, host and access rules without downloading it
+again; for a command-line tool --version switches to another release. Examples:
+  webstack tools install composer
+  webstack tools install wp-cli --version 2.11.0
+  webstack tools install node --version 22
+  webstack tools install phpmyadmin
+  webstack tools install phpmyadmin --path /pma --allow-ip 203.0.113.10
+  webstack tools install phpmyadmin --domain db.example.com --auth-user admin
+  webstack tools install phpmyadmin --version 5.2.1 --php-version 8.2`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if missing := installer.MissingDependencies(args[0]); len(missing) > 0 {
+			ui.Fail("❌ %s needs components that are not installed: %s\n", args[0], strings.Join(missing, ", "))
+			fmt.Println("   Install the missing components first (see 'webstack install --help')")
+			return
+		}
+		version, _ := cmd.Flags().GetString("version")
+		path, _ := cmd.Flags().GetString("path")
+		host, _ := cmd.Flags().GetString("domain")
+		phpVersion, _ := cmd.Flags().GetString("php-version")
+		allowIPs, _ := cmd.Flags().GetStringSlice("allow-ip")
+		authUser, _ := cmd.Flags().GetString("auth-user")
+		authPassword, _ := cmd.Flags().GetString("auth-password")
+		tools.Install(args[0], tools.InstallOptions{
+			Version:      version,
+			Path:         path,
+			Host:         host,
+			PHPVersion:   phpVersion,
+			AllowIPs:     allowIPs,
+			AuthUser:     authUser,
+			AuthPassword: authPassword,
+		})
+	},
+}
+
+var toolsUpgradeCmd = &cobra.Command{
+	Use:   "upgrade [tool]",
+	Short: "Upgrade a tool to the latest or a given release",
+	Long: `Download a new release next to the installed one, carry its configuration over
+and swap it in. The installed release stays in place if anything fails. Examples:
+  webstack tools upgrade phpmyadmin
+  webstack tools upgrade composer
+  webstack tools upgrade node --version 24
+  webstack tools upgrade phpmyadmin --version 5.2.2`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		version, _ := cmd.Flags().GetString("version")
+		tools.Upgrade(args[0], version)
+	},
+}
+
+var toolsUninstallCmd = &cobra.Command{
+	Use:   "uninstall [tool]",
+	Short: "Remove a tool (and the web server configuration of a web tool)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		tools.Uninstall(args[0])
+	},
+}
+
+var toolsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the available tools and verify the installed ones",
+	Long: `List every tool with its installed release. Command-line tools are run to check
+that they still report the pinned release.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		tools.List()
+	},
+}
+
+var toolsStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the installed tools, their URLs and access rules",
+	Run: func(cmd *cobra.Command, args []string) {
+		tools.Status()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(toolsCmd)
+	toolsCmd.AddCommand(toolsInstallCmd)
+	toolsCmd.AddCommand(toolsUpgradeCmd)
+	toolsCmd.AddCommand(toolsUninstallCmd)
+	toolsCmd.AddCommand(toolsListCmd)
+	toolsCmd.AddCommand(toolsStatusCmd)
+
+	toolsInstallCmd.Flags().String("version", "", "Release to install (default latest; for node the latest LTS, or a major version such as 22)")
+	toolsInstallCmd.Flags().String("path", "", "URL path on every domain and the server IP (default /<tool>)")
+	toolsInstallCmd.Flags().String("domain", "", "Serve the tool on a dedicated host instead of a path, e.g. db.example.com")
+	toolsInstallCmd.Flags().String("php-version", "", "PHP-FPM version to run it with (default the newest installed)")
+	toolsInstallCmd.Flags().StringSlice("allow-ip", nil, "Only allow this IP address or CIDR range (repeatable)")
+	toolsInstallCmd.Flags().String("auth-user", "", "Require basic auth with this user in front of the tool")
+	toolsInstallCmd.Flags().String("auth-password", "", "Basic auth password (generated and printed when omitted)")
+	toolsUpgradeCmd.Flags().String("version", "", "Release to upgrade to (default latest)")
+}
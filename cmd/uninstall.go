@@ -17,6 +17,7 @@ var uninstallAllCmd = &cobra.Command{
 	Short: "Uninstall complete web stack with confirmation",
 	Long:  `Uninstall Nginx, Apache, databases, and PHP versions with user confirmation.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		installer.PurgeData, _ = cmd.Flags().GetBool("purge-data")
 		installer.UninstallAll()
 	},
 }
@@ -41,6 +42,7 @@ var uninstallMysqlCmd = &cobra.Command{
 	Use:   "mysql",
 	Short: "Uninstall MySQL database server",
 	Run: func(cmd *cobra.Command, args []string) {
+		installer.PurgeData, _ = cmd.Flags().GetBool("purge-data")
 		installer.UninstallMySQL()
 	},
 }
@@ -49,6 +51,7 @@ var uninstallMariadbCmd = &cobra.Command{
 	Use:   "mariadb",
 	Short: "Uninstall MariaDB database server",
 	Run: func(cmd *cobra.Command, args []string) {
+		installer.PurgeData, _ = cmd.Flags().GetBool("purge-data")
 		installer.UninstallMariaDB()
 	},
 }
@@ -57,10 +60,20 @@ var uninstallPostgresqlCmd = &cobra.Command{
 	Use:   "postgresql",
 	Short: "Uninstall PostgreSQL database server",
 	Run: func(cmd *cobra.Command, args []string) {
+		installer.PurgeData, _ = cmd.Flags().GetBool("purge-data")
 		installer.UninstallPostgreSQL()
 	},
 }
 
+var uninstallMongodbCmd = &cobra.Command{
+	Use:   "mongodb",
+	Short: "Uninstall MongoDB",
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.PurgeData, _ = cmd.Flags().GetBool("purge-data")
+		installer.UninstallMongoDB()
+	},
+}
+
 var uninstallPhpCmd = &cobra.Command{
 	Use:   "php [version]",
 	Short: "Uninstall PHP-FPM version (5.6-8.4)",
@@ -70,6 +83,39 @@ var uninstallPhpCmd = &cobra.Command{
 	},
 }
 
+var uninstallCaddyCmd = &cobra.Command{
+	Use:   "caddy",
+	Short: "Uninstall Caddy",
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.UninstallCaddy()
+	},
+}
+
+var uninstallRedisCmd = &cobra.Command{
+	Use:   "redis",
+	Short: "Uninstall Redis",
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.UninstallRedis()
+	},
+}
+
+var uninstallMemcachedCmd = &cobra.Command{
+	Use:   "memcached",
+	Short: "Uninstall Memcached",
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.UninstallMemcached()
+	},
+}
+
+var uninstallFtpCmd = &cobra.Command{
+	Use:   "ftp",
+	Short: "Uninstall the FTP/SFTP server and its accounts",
+	Long:  `Remove vsftpd, the SFTP settings, every FTP account and the FTP firewall rules. Domain files are kept.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.UninstallFTP()
+	},
+}
+
 var uninstallMailCmd = &cobra.Command{
 	Use:   "mail",
 	Short: "Uninstall complete mail server stack",
@@ -87,6 +133,15 @@ func init() {
 	uninstallCmd.AddCommand(uninstallMysqlCmd)
 	uninstallCmd.AddCommand(uninstallMariadbCmd)
 	uninstallCmd.AddCommand(uninstallPostgresqlCmd)
+	uninstallCmd.AddCommand(uninstallMongodbCmd)
 	uninstallCmd.AddCommand(uninstallPhpCmd)
+	uninstallCmd.AddCommand(uninstallRedisCmd)
+	uninstallCmd.AddCommand(uninstallCaddyCmd)
+	uninstallCmd.AddCommand(uninstallMemcachedCmd)
+	uninstallCmd.AddCommand(uninstallFtpCmd)
 	uninstallCmd.AddCommand(uninstallMailCmd)
+	uninstallCmd.PersistentFlags().Bool("force", false, "Uninstall without asking, also when WEBSTACK_FORBID_DESTRUCTIVE is set")
+	for _, c := range []*cobra.Command{uninstallAllCmd, uninstallMysqlCmd, uninstallMariadbCmd, uninstallPostgresqlCmd, uninstallMongodbCmd} {
+		c.Flags().Bool("purge-data", false, "Delete the database data directories without asking (no dump is taken)")
+	}
 }
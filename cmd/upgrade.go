@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"webstack-cli/internal/installer"
+	"webstack-cli/internal/prompt"
+	"webstack-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade [component]",
+	Short: "Check for and install component upgrades, or pin component versions",
+	Long: `Without a component, list the installed components (nginx, apache, mysql, php8.3 ...)
+with their installed version, the version an upgrade would install and their pins.
+
+With a component, show the changelog since the installed version and upgrade its
+packages after confirmation. The configuration is snapshotted before and after the
+upgrade (see 'webstack snapshot'), and files the packages changed are listed. The
+service is only restarted once its configuration passes nginx -t, apachectl configtest
+or php-fpm -t; on Debian and Ubuntu the packages don't restart it before that.
+
+--pin holds a component at its installed version (apt-mark hold, or dnf versionlock)
+and records the pin in config.json, so it is skipped by upgrades and unattended-upgrades
+until --unpin.
+
+--major upgrades a PostgreSQL cluster (--cluster, default: the one on port 5432) to a
+newer major version with pg_upgradecluster, after saving a dump of it. The old cluster
+is kept, stopped, on another port until you drop it (Debian and Ubuntu).
+Examples:
+  sudo webstack upgrade
+  sudo webstack upgrade nginx --check
+  sudo webstack upgrade php8.3 --yes
+  sudo webstack upgrade mariadb --to 1:10.11.6-0+deb12u1
+  sudo webstack upgrade mysql --pin
+  sudo webstack upgrade postgresql --major 17 --cluster 16/main`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		check, _ := cmd.Flags().GetBool("check")
+		yes, _ := cmd.Flags().GetBool("yes")
+		pin, _ := cmd.Flags().GetBool("pin")
+		unpin, _ := cmd.Flags().GetBool("unpin")
+		to, _ := cmd.Flags().GetString("to")
+		noRefresh, _ := cmd.Flags().GetBool("no-refresh")
+		lines, _ := cmd.Flags().GetInt("changelog-lines")
+		major, _ := cmd.Flags().GetString("major")
+		cluster, _ := cmd.Flags().GetString("cluster")
+
+		if len(args) == 0 {
+			if pin || unpin || to != "" {
+				ui.Fail("❌ --pin, --unpin and --to need a component\n")
+				return
+			}
+			if !noRefresh {
+				if err := installer.RefreshPackageLists(); err != nil {
+					ui.Warn("⚠️  Warning: Could not refresh the package lists: %v\n", err)
+				}
+			}
+			listUpgrades()
+			return
+		}
+		if major != "" {
+			upgradePostgreSQLMajor(args[0], cluster, major, yes)
+			return
+		}
+		if pin && unpin {
+			ui.Fail("❌ --pin and --unpin cannot be combined\n")
+			return
+		}
+
+		if !noRefresh && !pin && !unpin {
+			if err := installer.RefreshPackageLists(); err != nil {
+				ui.Warn("⚠️  Warning: Could not refresh the package lists: %v\n", err)
+			}
+		}
+		u, err := installer.FindComponentUpgrade(args[0])
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+
+		switch {
+		case unpin:
+			if u.Pinned == "" {
+				fmt.Printf("ℹ️  %s is not pinned\n", u.Name)
+				return
+			}
+			if err := installer.UnpinComponent(u); err != nil {
+				ui.Fail("❌ %v\n", err)
+				return
+			}
+			fmt.Printf("✅ %s unpinned, upgrades install new versions again\n", u.Name)
+			return
+		case pin && to == "":
+			if err := installer.PinComponent(u); err != nil {
+				ui.Fail("❌ %v\n", err)
+				return
+			}
+			fmt.Printf("📌 %s pinned to %s\n", u.Name, u.Installed)
+			return
+		}
+
+		fmt.Printf("📦 %s\n", u.Name)
+		fmt.Printf("   Installed: %s\n", u.Installed)
+		fmt.Printf("   Candidate: %s\n", orNone(u.Candidate))
+		fmt.Printf("   Packages:  %s\n", strings.Join(u.Packages, " "))
+		if u.Pinned != "" {
+			fmt.Printf("📌 %s is pinned to %s, unpin it with 'sudo webstack upgrade %s --unpin'\n", u.Name, u.Pinned, u.Component)
+			return
+		}
+		if to == "" && !u.Available() {
+			fmt.Printf("✅ %s is up to date\n", u.Name)
+			return
+		}
+		if to == "" {
+			fmt.Println()
+			installer.PrintChangelog(u, lines)
+		}
+		if check {
+			return
+		}
+
+		if !yes {
+			target := u.Candidate
+			if to != "" {
+				target = to
+			}
+			fmt.Println()
+			if !prompt.Confirm(fmt.Sprintf("Upgrade %s from %s to %s?", u.Name, u.Installed, target)) {
+				fmt.Println("Upgrade cancelled")
+				return
+			}
+		}
+		fmt.Println()
+		if err := installer.UpgradeComponent(u, installer.UpgradeOptions{Version: to}); err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		if pin {
+			u, err = installer.FindComponentUpgrade(u.Component)
+			if err == nil {
+				err = installer.PinComponent(u)
+			}
+			if err != nil {
+				ui.Fail("❌ %v\n", err)
+				return
+			}
+			fmt.Printf("📌 %s pinned to %s\n", u.Name, u.Installed)
+		}
+	},
+}
+
+// upgradePostgreSQLMajor upgrades a PostgreSQL cluster to a major version
+func upgradePostgreSQLMajor(component, cluster, major string, yes bool) {
+	if component != "postgresql" {
+		ui.Fail("❌ --major is only supported for postgresql\n")
+		return
+	}
+	if !yes {
+		name := cluster
+		if name == "" {
+			name = "on port 5432"
+		}
+		if !prompt.Confirm(fmt.Sprintf("Upgrade the PostgreSQL cluster %s to PostgreSQL %s? Its databases are unavailable during the upgrade", name, major)) {
+			fmt.Println("Upgrade cancelled")
+			return
+		}
+	}
+	if err := installer.UpgradePostgreSQLCluster(cluster, major); err != nil {
+		ui.Fail("❌ %v\n", err)
+	}
+}
+
+// listUpgrades prints the installed components and their upgrades
+func listUpgrades() {
+	upgrades := installer.ComponentUpgrades()
+	if len(upgrades) == 0 {
+		fmt.Println("ℹ️  No components installed")
+		return
+	}
+	available := 0
+	fmt.Printf("%-14s %-32s %-32s %s\n", "COMPONENT", "INSTALLED", "CANDIDATE", "STATUS")
+	for _, u := range upgrades {
+		status := "up to date"
+		switch {
+		case u.Pinned != "":
+			status = "pinned"
+		case u.Available():
+			status = "upgrade available"
+			available++
+		case u.Candidate == "":
+			status = "not in any repository"
+		}
+		fmt.Printf("%-14s %-32s %-32s %s\n", u.Component, u.Installed, orNone(u.Candidate), status)
+	}
+	fmt.Println()
+	if available == 0 {
+		fmt.Println("✅ All components are up to date")
+		return
+	}
+	fmt.Printf("💡 %d upgrade(s) available, review and install one with: sudo webstack upgrade <component>\n", available)
+}
+
+func orNone(version string) string {
+	if version == "" {
+		return "(none)"
+	}
+	return version
+}
+
+func init() {
+	rootCmd.AddCommand(upgradeCmd)
+	upgradeCmd.Flags().Bool("check", false, "Show the versions and changelog without upgrading")
+	upgradeCmd.Flags().BoolP("yes", "y", false, "Upgrade without asking")
+	upgradeCmd.Flags().Bool("pin", false, "Pin the component at its installed version (after the upgrade with --to)")
+	upgradeCmd.Flags().Bool("unpin", false, "Release the pin of the component")
+	upgradeCmd.Flags().String("to", "", "Package version to install instead of the newest (apt-cache madison / dnf list --showduplicates)")
+	upgradeCmd.Flags().Bool("no-refresh", false, "Don't update the package lists first")
+	upgradeCmd.Flags().Int("changelog-lines", 40, "Changelog lines to show")
+	upgradeCmd.Flags().String("major", "", "PostgreSQL major version to upgrade a cluster to")
+	upgradeCmd.Flags().String("cluster", "", "PostgreSQL cluster to upgrade with --major, e.g. 16/main (default: the one on port 5432)")
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"os"
+
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var upstreamCmd = &cobra.Command{
+	Use:   "upstream",
+	Short: "Manage load-balanced upstream pools for proxy domains",
+	Long: `Group app servers into named upstreams that proxy domains balance requests across.
+Nginx gets an upstream block per pool in /etc/nginx/includes/upstreams.conf with the
+balancing method, passive health checks (a server is skipped for --fail-timeout seconds
+after --max-fails failed attempts) and keepalive connections. Proxy domains refer to a
+pool by name with --upstream; changing a pool only rewrites the include.
+Usage:
+  sudo webstack upstream create api --server 10.0.0.2:8080 --server 10.0.0.3:8080
+  sudo webstack domain add api.example.com --backend proxy --upstream api
+  sudo webstack upstream edit api --method least-conn
+  sudo webstack upstream list`,
+}
+
+var upstreamCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create an upstream pool",
+	Long: `Create an upstream pool. New pools balance round-robin, skip a server for 30s after
+3 failed attempts and keep 32 idle connections per worker open.
+Examples:
+  sudo webstack upstream create api --server 10.0.0.2:8080 --server 10.0.0.3:8080
+  sudo webstack upstream create app --server 127.0.0.1:3001 --server 127.0.0.1:3002 --method least-conn
+  sudo webstack upstream create web --server 10.0.0.2:80 --backup 10.0.0.9:80 --max-fails 5 --fail-timeout 10`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		domain.CreatePool(args[0], poolOptions(cmd))
+	},
+}
+
+var upstreamEditCmd = &cobra.Command{
+	Use:   "edit [name]",
+	Short: "Change the servers or settings of an upstream pool",
+	Long: `Change an upstream pool; --server and --backup replace the current lists. Without
+flags the pool is shown.
+Examples:
+  sudo webstack upstream edit api --server 10.0.0.2:8080 --server 10.0.0.4:8080
+  sudo webstack upstream edit api --backup none
+  sudo webstack upstream edit api --max-fails 0      # no health check
+  sudo webstack upstream edit api --keepalive 0      # no keepalive
+  sudo webstack upstream edit api`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		domain.EditPool(args[0], poolOptions(cmd))
+	},
+}
+
+var upstreamDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete an upstream pool no domain uses",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		domain.DeletePool(args[0])
+	},
+}
+
+var upstreamListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the upstream pools and the domains using them",
+	Run: func(cmd *cobra.Command, args []string) {
+		domain.ListPools()
+	},
+}
+
+// poolOptions reads the flags of 'upstream create/edit'; an explicit 0
+// turns the health check or keepalive off
+func poolOptions(cmd *cobra.Command) domain.PoolOptions {
+	opts := domain.PoolOptions{}
+	opts.Servers, _ = cmd.Flags().GetStringArray("server")
+	opts.Backup, _ = cmd.Flags().GetStringArray("backup")
+	opts.Method, _ = cmd.Flags().GetString("method")
+	opts.MaxFails, _ = cmd.Flags().GetInt("max-fails")
+	if cmd.Flags().Changed("max-fails") && opts.MaxFails == 0 {
+		opts.MaxFails = -1
+	}
+	opts.FailTimeout, _ = cmd.Flags().GetInt("fail-timeout")
+	if cmd.Flags().Changed("fail-timeout") && opts.FailTimeout == 0 {
+		opts.FailTimeout = -1
+	}
+	opts.Keepalive, _ = cmd.Flags().GetInt("keepalive")
+	if cmd.Flags().Changed("keepalive") && opts.Keepalive == 0 {
+		opts.Keepalive = -1
+	}
+	return opts
+}
+
+func init() {
+	rootCmd.AddCommand(upstreamCmd)
+	upstreamCmd.AddCommand(upstreamCreateCmd)
+	upstreamCmd.AddCommand(upstreamEditCmd)
+	upstreamCmd.AddCommand(upstreamDeleteCmd)
+	upstreamCmd.AddCommand(upstreamListCmd)
+
+	for _, c := range []*cobra.Command{upstreamCreateCmd, upstreamEditCmd} {
+		c.Flags().StringArray("server", nil, "App server as host:port (repeat for several)")
+		c.Flags().StringArray("backup", nil, "Backup server used when every server is down (repeat for several; none removes them)")
+		c.Flags().String("method", "", "Balancing method: round-robin, least-conn, ip-hash or random (default round-robin)")
+		c.Flags().Int("max-fails", 0, "Failed attempts before a server is skipped; 0 disables the health check (default 3)")
+		c.Flags().Int("fail-timeout", 0, "Seconds a failed server is skipped for (default 30)")
+		c.Flags().Int("keepalive", 0, "Idle connections kept open to the servers per worker; 0 disables keepalive (default 32)")
+	}
+}
@@ -1,13 +1,15 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"os"
 	"runtime"
 
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/prompt"
+	"webstack-cli/internal/selfupdate"
+	"webstack-cli/internal/ui"
+
 	"github.com/spf13/cobra"
 )
 
@@ -17,22 +19,34 @@ var (
 	GitCommit = "unknown"
 )
 
-type GitHubRelease struct {
-	TagName string `json:"tag_name"`
-	Name    string `json:"name"`
-	Body    string `json:"body"`
-}
-
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Show version information",
-	Run:   showVersion,
+	Long: `Show the version of this binary. --check also asks GitHub for the newest release of
+the update channel (update_channel: stable or edge) and reports whether it is newer.
+Examples:
+  webstack version
+  webstack version --check`,
+	Run: showVersion,
 }
 
-var updateCmd = &cobra.Command{
-	Use:   "update",
-	Short: "Update WebStack CLI to the latest version",
-	Run:   updateCLI,
+var selfUpdateCmd = &cobra.Command{
+	Use:     "self-update",
+	Aliases: []string{"update"},
+	Short:   "Update the webstack binary to the newest release",
+	Long: `Download the newest release of the update channel from GitHub and replace this binary
+with it. The download is verified against the checksums.txt of the release, and the
+checksums against their signature when this binary was built with the release signing
+key. The new binary is renamed over the old one, which is kept as <binary>.previous.
+
+The channel is the update_channel setting: stable follows the latest release, edge also
+takes pre-releases ('webstack config set update_channel edge').
+Examples:
+  sudo webstack self-update
+  sudo webstack self-update --check
+  sudo webstack self-update --channel edge --yes`,
+	Args: cobra.NoArgs,
+	Run:  selfUpdate,
 }
 
 func showVersion(cmd *cobra.Command, args []string) {
@@ -41,135 +55,83 @@ func showVersion(cmd *cobra.Command, args []string) {
 	fmt.Printf("Git Commit: %s\n", GitCommit)
 	fmt.Printf("Go Version: %s\n", runtime.Version())
 	fmt.Printf("Platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
-}
 
-func updateCLI(cmd *cobra.Command, args []string) {
-	fmt.Println("🔍 Checking for updates...")
-
-	// Get latest release from GitHub
-	resp, err := http.Get("https://api.github.com/repos/script-php/webstack/releases/latest")
-	if err != nil {
-		fmt.Printf("❌ Failed to check for updates: %v\n", err)
-		return
+	if check, _ := cmd.Flags().GetBool("check"); check {
+		fmt.Println()
+		checkRelease(updateChannel(""))
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode != 200 {
-		fmt.Printf("❌ Failed to fetch release information (HTTP %d)\n", resp.StatusCode)
-		return
+// updateChannel returns the channel given with --channel, else the
+// update_channel setting
+func updateChannel(flag string) string {
+	if flag != "" {
+		return flag
+	}
+	if cfg, err := config.Load(); err == nil {
+		if channel, ok := cfg.GetDefault(selfupdate.ChannelKey, "").(string); ok && channel != "" {
+			return channel
+		}
 	}
+	return selfupdate.Stable
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+// checkRelease reports whether the channel has a release newer than this
+// binary and returns it, or nil
+func checkRelease(channel string) *selfupdate.Release {
+	release, err := selfupdate.Latest(channel)
 	if err != nil {
-		fmt.Printf("❌ Failed to read response: %v\n", err)
-		return
+		ui.Fail("❌ Could not check for updates: %v\n", err)
+		return nil
 	}
-
-	var release GitHubRelease
-	if err := json.Unmarshal(body, &release); err != nil {
-		fmt.Printf("❌ Failed to parse release information: %v\n", err)
-		return
+	if !selfupdate.Newer(Version, release.Tag) {
+		fmt.Printf("✅ %s is the newest %s release\n", Version, channel)
+		return nil
 	}
-
-	if release.TagName == Version {
-		fmt.Printf("✅ You're already running the latest version (%s)\n", Version)
-		return
+	fmt.Printf("🆕 %s is available on the %s channel (running %s)\n", release.Tag, channel, Version)
+	if release.URL != "" {
+		fmt.Printf("   Release notes: %s\n", release.URL)
 	}
+	return release
+}
 
-	fmt.Printf("🆕 New version available: %s (current: %s)\n", release.TagName, Version)
-	fmt.Printf("📝 Release notes: %s\n", release.Name)
-
-	if !askConfirmation("Do you want to update now?") {
-		fmt.Println("Update cancelled.")
+func selfUpdate(cmd *cobra.Command, args []string) {
+	check, _ := cmd.Flags().GetBool("check")
+	yes, _ := cmd.Flags().GetBool("yes")
+	channel, _ := cmd.Flags().GetString("channel")
+	channel = updateChannel(channel)
+	if channel != selfupdate.Stable && channel != selfupdate.Edge {
+		ui.Invalid("❌ Invalid channel %s (use stable or edge)\n", channel)
 		return
 	}
 
-	// Download and install update
-	if err := downloadAndInstall(release.TagName); err != nil {
-		fmt.Printf("❌ Update failed: %v\n", err)
+	fmt.Printf("🔍 Checking the %s channel for updates...\n", channel)
+	release := checkRelease(channel)
+	if release == nil || check {
 		return
 	}
 
-	fmt.Printf("✅ Successfully updated to version %s\n", release.TagName)
-	fmt.Println("Please restart your terminal or run 'webstack version' to verify the update.")
-}
-
-func downloadAndInstall(version string) error {
-	platform := fmt.Sprintf("%s-%s", runtime.GOOS, runtime.GOARCH)
-	if runtime.GOOS == "windows" {
-		platform += ".exe"
-	}
-
-	downloadURL := fmt.Sprintf("https://github.com/script-php/webstack/releases/download/%s/webstack-%s", version, platform)
-
-	fmt.Printf("📥 Downloading %s...\n", downloadURL)
-
-	// Download the new binary
-	resp, err := http.Get(downloadURL)
+	path, err := os.Executable()
 	if err != nil {
-		return fmt.Errorf("failed to download: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("download failed with HTTP %d", resp.StatusCode)
-	}
-
-	// Read the binary data
-	data, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read downloaded data: %v", err)
-	}
-
-	// Get current executable path
-	execPath, err := os.Executable()
-	if err != nil {
-		return fmt.Errorf("failed to get current executable path: %v", err)
-	}
-
-	// Create backup
-	backupPath := execPath + ".backup"
-	if err := copyFile(execPath, backupPath); err != nil {
-		return fmt.Errorf("failed to create backup: %v", err)
-	}
-
-	// Write new binary
-	tmpPath := execPath + ".new"
-	if err := ioutil.WriteFile(tmpPath, data, 0755); err != nil {
-		return fmt.Errorf("failed to write new binary: %v", err)
+		ui.Fail("❌ Could not find the running binary: %v\n", err)
+		return
 	}
-
-	// Replace current binary
-	if err := os.Rename(tmpPath, execPath); err != nil {
-		// Restore backup on failure
-		os.Rename(backupPath, execPath)
-		return fmt.Errorf("failed to replace binary: %v", err)
+	if !yes && !prompt.Confirm(fmt.Sprintf("Replace %s with %s?", path, release.Tag)) {
+		fmt.Println("Update cancelled.")
+		return
 	}
-
-	// Remove backup
-	os.Remove(backupPath)
-
-	return nil
-}
-
-func copyFile(src, dst string) error {
-	data, err := ioutil.ReadFile(src)
-	if err != nil {
-		return err
+	if err := selfupdate.Install(release, path); err != nil {
+		ui.Fail("❌ Update failed: %v\n", err)
+		return
 	}
-	return ioutil.WriteFile(dst, data, 0755)
-}
-
-func askConfirmation(question string) bool {
-	fmt.Printf("%s (y/N): ", question)
-
-	var response string
-	fmt.Scanln(&response)
-
-	return response == "y" || response == "Y" || response == "yes" || response == "Yes"
+	fmt.Printf("✅ Updated to %s (the previous binary is kept as %s.previous)\n", release.Tag, path)
 }
 
 func init() {
 	rootCmd.AddCommand(versionCmd)
-	rootCmd.AddCommand(updateCmd)
+	rootCmd.AddCommand(selfUpdateCmd)
+	versionCmd.Flags().Bool("check", false, "Report whether a newer release exists")
+	selfUpdateCmd.Flags().Bool("check", false, "Only report whether a newer release exists")
+	selfUpdateCmd.Flags().BoolP("yes", "y", false, "Update without asking")
+	selfUpdateCmd.Flags().String("channel", "", "Release channel for this run: stable or edge (default: update_channel setting)")
 }
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"webstack-cli/internal/ui"
+	"webstack-cli/internal/worker"
+
+	"github.com/spf13/cobra"
+)
+
+var workerCmd = &cobra.Command{
+	Use:   "worker",
+	Short: "Manage long-running processes of domains (queue workers)",
+	Long: `Run queue workers and other long-running processes of a domain under systemd.
+Each worker is a template unit webstack-worker-<domain>-<name>@.service with one
+instance per replica, run as www-data in the domain's htdocs and restarted when it
+exits. Output goes to /var/www/<domain>/logs/worker-<name>.log.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var workerAddCmd = &cobra.Command{
+	Use:   "add [domain]",
+	Short: "Add a worker to a domain and start it",
+	Long: `Add a worker to a domain and start its replicas. A command starting with "php"
+runs the PHP CLI of the domain's PHP version. Examples:
+  webstack worker add example.com --name queue --cmd "php artisan queue:work" --replicas 2
+  webstack worker add example.com --name messenger --cmd "php bin/console messenger:consume async --time-limit=3600"
+  webstack worker add example.com --name ws --cmd "node server.js" --restart on-failure`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		name, _ := cmd.Flags().GetString("name")
+		command, _ := cmd.Flags().GetString("cmd")
+		replicas, _ := cmd.Flags().GetInt("replicas")
+		restart, _ := cmd.Flags().GetString("restart")
+		worker.Add(args[0], worker.Options{
+			Name:     name,
+			Command:  command,
+			Replicas: replicas,
+			Restart:  restart,
+		})
+	},
+}
+
+var workerRemoveCmd = &cobra.Command{
+	Use:   "remove [domain] [name]",
+	Short: "Stop a worker and remove its unit",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		worker.Remove(args[0], args[1])
+	},
+}
+
+var workerScaleCmd = &cobra.Command{
+	Use:   "scale [domain] [name] [replicas]",
+	Short: "Change the number of replicas of a worker",
+	Long: `Start or stop replicas of a worker. Example:
+  webstack worker scale example.com queue 4`,
+	Args: cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		var replicas int
+		if _, err := fmt.Sscanf(args[2], "%d", &replicas); err != nil {
+			ui.Invalid("Invalid replica count: %s\n", args[2])
+			return
+		}
+		worker.Scale(args[0], args[1], replicas)
+	},
+}
+
+var workerRestartCmd = &cobra.Command{
+	Use:   "restart [domain] [name]",
+	Short: "Restart a worker, or every worker of a domain",
+	Long: `Restart the replicas of a worker, e.g. after deploying new code. Without a name
+every worker of the domain is restarted. Examples:
+  webstack worker restart example.com
+  webstack worker restart example.com queue`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Geteuid() != 0 {
+			ui.Fail("❌ This command requires root privileges (use sudo)\n")
+			return
+		}
+		name := ""
+		if len(args) == 2 {
+			name = args[1]
+		}
+		worker.Restart(args[0], name)
+	},
+}
+
+var workerListCmd = &cobra.Command{
+	Use:   "list [domain]",
+	Short: "List workers, optionally of one domain",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		domainName := ""
+		if len(args) == 1 {
+			domainName = args[0]
+		}
+		worker.List(domainName)
+	},
+}
+
+var workerStatusCmd = &cobra.Command{
+	Use:   "status [domain]",
+	Short: "Show the state, PID and restart count of every replica",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		domainName := ""
+		if len(args) == 1 {
+			domainName = args[0]
+		}
+		worker.Status(domainName)
+	},
+}
+
+var workerLogsCmd = &cobra.Command{
+	Use:   "logs [domain] [name]",
+	Short: "Show the output of a worker",
+	Long: `Show the last lines of a worker's log. Examples:
+  webstack worker logs example.com queue
+  webstack worker logs example.com queue -f`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		lines, _ := cmd.Flags().GetInt("lines")
+		follow, _ := cmd.Flags().GetBool("follow")
+		worker.TailLogs(args[0], args[1], lines, follow)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(workerCmd)
+	workerCmd.AddCommand(workerAddCmd)
+	workerCmd.AddCommand(workerRemoveCmd)
+	workerCmd.AddCommand(workerScaleCmd)
+	workerCmd.AddCommand(workerRestartCmd)
+	workerCmd.AddCommand(workerListCmd)
+	workerCmd.AddCommand(workerStatusCmd)
+	workerCmd.AddCommand(workerLogsCmd)
+
+	workerAddCmd.Flags().String("name", "", "Worker name, e.g. queue (required)")
+	workerAddCmd.Flags().String("cmd", "", "Command to run in htdocs, e.g. \"php artisan queue:work\" (required)")
+	workerAddCmd.Flags().Int("replicas", 1, "Number of instances to run")
+	workerAddCmd.Flags().String("restart", "always", "Restart policy: always or on-failure")
+	workerAddCmd.MarkFlagRequired("name")
+	workerAddCmd.MarkFlagRequired("cmd")
+
+	workerLogsCmd.Flags().IntP("lines", "n", 50, "Number of lines to show")
+	workerLogsCmd.Flags().BoolP("follow", "f", false, "Keep following the log")
+}
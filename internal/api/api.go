@@ -0,0 +1,483 @@
+package api
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/prompt"
+	"webstack-cli/internal/ssl"
+)
+
+// maxBody limits the size of request bodies
+const maxBody = 1 << 20
+
+// components are the names accepted by /v1/components/{name}, as for
+// 'webstack install' and 'webstack uninstall'
+var components = map[string]bool{
+	"nginx": true, "apache": true, "mysql": true, "mariadb": true, "postgresql": true,
+	"php": true, "redis": true, "memcached": true, "ftp": true, "mail": true,
+}
+
+// Server serves the JSON API. Reads come straight from the internal
+// packages; changes run the webstack binary itself with the matching
+// command, so they behave exactly like the CLI (validation, locking,
+// rollback snapshots) and never drift from it.
+type Server struct {
+	Token      string // Bearer token every request must carry
+	Executable string // webstack binary the changes are run with
+	DryRun     bool   // Pass --dry-run to every command
+
+	mu sync.Mutex // One changing operation at a time
+}
+
+// Result is the response to a changing operation
+type Result struct {
+	OK       bool   `json:"ok"`
+	ExitCode int    `json:"exit_code"` // As documented for the CLI: 0 ok, 1 warnings, 2 failure, 3 invalid input
+	Output   string `json:"output"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Handler returns the routes of the API behind the token check
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /v1/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+	})
+
+	mux.HandleFunc("GET /v1/domains", s.listDomains)
+	mux.HandleFunc("GET /v1/domains/{name}", s.getDomain)
+	mux.HandleFunc("POST /v1/domains", s.addDomain)
+	mux.HandleFunc("PATCH /v1/domains/{name}", s.editDomain)
+	mux.HandleFunc("DELETE /v1/domains/{name}", s.deleteDomain)
+
+	mux.HandleFunc("GET /v1/ssl", s.listCertificates)
+	mux.HandleFunc("POST /v1/ssl/{domain}", s.enableSSL)
+	mux.HandleFunc("POST /v1/ssl/{domain}/renew", s.renewSSL)
+	mux.HandleFunc("DELETE /v1/ssl/{domain}", s.disableSSL)
+
+	mux.HandleFunc("GET /v1/db/{engine}/databases", s.listDatabases)
+	mux.HandleFunc("POST /v1/db/{engine}/databases", s.createDatabase)
+	mux.HandleFunc("DELETE /v1/db/{engine}/databases/{name}", s.deleteDatabase)
+	mux.HandleFunc("GET /v1/db/{engine}/users", s.listDatabaseUsers)
+	mux.HandleFunc("POST /v1/db/{engine}/users", s.createDatabaseUser)
+	mux.HandleFunc("DELETE /v1/db/{engine}/users/{username}", s.deleteDatabaseUser)
+
+	mux.HandleFunc("POST /v1/components/{name}", s.installComponent)
+	mux.HandleFunc("DELETE /v1/components/{name}", s.uninstallComponent)
+
+	return s.authenticate(mux)
+}
+
+// authenticate rejects requests without the bearer token and logs the rest
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if s.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+			fmt.Printf("%s %s %s -> 401\n", r.RemoteAddr, r.Method, r.URL.Path)
+			writeJSON(w, http.StatusUnauthorized, errorResponse{"missing or invalid token"})
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		fmt.Printf("%s %s %s -> %d (%s)\n", r.RemoteAddr, r.Method, r.URL.Path, rec.status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (s *Server) listDomains(w http.ResponseWriter, r *http.Request) {
+	domains, err := domain.All()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{err.Error()})
+		return
+	}
+	if domains == nil {
+		domains = []domain.Domain{}
+	}
+	writeJSON(w, http.StatusOK, domains)
+}
+
+func (s *Server) getDomain(w http.ResponseWriter, r *http.Request) {
+	d, err := domain.GetDomain(r.PathValue("name"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+type addDomainRequest struct {
+	Name     string `json:"name"`
+	Backend  string `json:"backend"`
+	PHP      string `json:"php"`
+	DocRoot  string `json:"docroot"`
+	Root     string `json:"root"`
+	Preset   string `json:"preset"`
+	HTTP3    string `json:"http3"`
+	Upstream string `json:"upstream"`
+}
+
+func (s *Server) addDomain(w http.ResponseWriter, r *http.Request) {
+	var req addDomainRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Name == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"name is required"})
+		return
+	}
+	s.run(w, command{
+		path:  []string{"domain", "add"},
+		flags: flags{"backend": req.Backend, "php": req.PHP, "docroot": req.DocRoot, "root": req.Root, "preset": req.Preset, "http3": req.HTTP3, "upstream": req.Upstream},
+		args:  []string{req.Name},
+	})
+}
+
+type editDomainRequest struct {
+	Backend    string `json:"backend"`
+	PHP        string `json:"php"`
+	DocRoot    string `json:"docroot"`
+	Root       string `json:"root"`
+	Hardening  string `json:"hardening"`
+	HTTP3      string `json:"http3"`
+	ForceHTTPS string `json:"force_https"`
+	Canonical  string `json:"canonical"`
+	Upstream   string `json:"upstream"`
+}
+
+func (s *Server) editDomain(w http.ResponseWriter, r *http.Request) {
+	var req editDomainRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	s.run(w, command{
+		path: []string{"domain", "edit"},
+		flags: flags{"backend": req.Backend, "php": req.PHP, "docroot": req.DocRoot, "root": req.Root, "hardening": req.Hardening,
+			"http3": req.HTTP3, "force-https": req.ForceHTTPS, "canonical": req.Canonical, "upstream": req.Upstream},
+		args: []string{r.PathValue("name")},
+	})
+}
+
+// deleteDomain keeps the domain folder unless ?delete_files=true is given
+func (s *Server) deleteDomain(w http.ResponseWriter, r *http.Request) {
+	answer := "n"
+	if r.URL.Query().Get("delete_files") == "true" {
+		answer = "y"
+	}
+	s.run(w, command{
+		path:  []string{"domain", "delete"},
+		args:  []string{r.PathValue("name")},
+		input: answer + "\n",
+	})
+}
+
+func (s *Server) listCertificates(w http.ResponseWriter, r *http.Request) {
+	certs, err := ssl.Certificates()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{err.Error()})
+		return
+	}
+	if certs == nil {
+		certs = []ssl.SSLCertificate{}
+	}
+	writeJSON(w, http.StatusOK, certs)
+}
+
+type enableSSLRequest struct {
+	Email       string   `json:"email"`
+	Type        string   `json:"type"`
+	Challenge   string   `json:"challenge"`
+	Wildcard    bool     `json:"wildcard"`
+	AltNames    []string `json:"san"`
+	DNSProvider string   `json:"dns_provider"`
+	ACMEClient  string   `json:"acme_client"`
+}
+
+func (s *Server) enableSSL(w http.ResponseWriter, r *http.Request) {
+	var req enableSSLRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	f := flags{"email": req.Email, "type": req.Type, "challenge": req.Challenge, "dns-provider": req.DNSProvider, "acme-client": req.ACMEClient}
+	if req.Wildcard {
+		f["wildcard"] = "true"
+	}
+	if len(req.AltNames) > 0 {
+		f["san"] = strings.Join(req.AltNames, ",")
+	}
+	s.run(w, command{path: []string{"ssl", "enable"}, flags: f, args: []string{r.PathValue("domain")}})
+}
+
+func (s *Server) renewSSL(w http.ResponseWriter, r *http.Request) {
+	f := flags{}
+	if r.URL.Query().Get("if_due") == "true" {
+		f["if-due"] = "true"
+	}
+	s.run(w, command{path: []string{"ssl", "renew"}, flags: f, args: []string{r.PathValue("domain")}})
+}
+
+func (s *Server) disableSSL(w http.ResponseWriter, r *http.Request) {
+	s.run(w, command{path: []string{"ssl", "disable"}, args: []string{r.PathValue("domain")}})
+}
+
+func (s *Server) listDatabases(w http.ResponseWriter, r *http.Request) {
+	s.run(w, command{path: []string{"db", "database", "list"}, args: []string{r.PathValue("engine")}, read: true})
+}
+
+type createDatabaseRequest struct {
+	Name      string `json:"name"`
+	Charset   string `json:"charset"`
+	Collation string `json:"collation"`
+	Owner     string `json:"owner"`
+}
+
+func (s *Server) createDatabase(w http.ResponseWriter, r *http.Request) {
+	var req createDatabaseRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Name == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"name is required"})
+		return
+	}
+	s.run(w, command{
+		path:  []string{"db", "database", "create"},
+		flags: flags{"charset": req.Charset, "collation": req.Collation, "owner": req.Owner},
+		args:  []string{r.PathValue("engine"), req.Name},
+	})
+}
+
+func (s *Server) deleteDatabase(w http.ResponseWriter, r *http.Request) {
+	s.run(w, command{
+		path:  []string{"db", "database", "delete"},
+		flags: flags{"force": "true"},
+		args:  []string{r.PathValue("engine"), r.PathValue("name")},
+	})
+}
+
+func (s *Server) listDatabaseUsers(w http.ResponseWriter, r *http.Request) {
+	s.run(w, command{path: []string{"db", "user", "list"}, args: []string{r.PathValue("engine")}, read: true})
+}
+
+type createUserRequest struct {
+	Username       string `json:"username"`
+	Password       string `json:"password"`
+	Host           string `json:"host"`
+	Privileges     string `json:"privileges"`
+	Database       string `json:"database"`
+	MaxConnections int    `json:"max_connections"`
+	RequireSSL     bool   `json:"require_ssl"`
+}
+
+func (s *Server) createDatabaseUser(w http.ResponseWriter, r *http.Request) {
+	var req createUserRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Username == "" || req.Password == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"username and password are required"})
+		return
+	}
+	if strings.ContainsAny(req.Password, "\r\n") {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"the password can't contain line breaks"})
+		return
+	}
+	if req.Host == "" {
+		req.Host = "localhost"
+	}
+	f := flags{"privileges": req.Privileges, "database": req.Database}
+	if req.MaxConnections > 0 {
+		f["max-connections"] = strconv.Itoa(req.MaxConnections)
+	}
+	if req.RequireSSL {
+		f["require-ssl"] = "true"
+	}
+	// The password goes on stdin (-), not in the arguments of the process
+	s.run(w, command{
+		path:  []string{"db", "user", "create"},
+		flags: f,
+		args:  []string{r.PathValue("engine"), req.Username, "-", req.Host},
+		input: req.Password + "\n",
+	})
+}
+
+func (s *Server) deleteDatabaseUser(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Query().Get("host")
+	if host == "" {
+		host = "localhost"
+	}
+	s.run(w, command{
+		path: []string{"db", "user", "delete"},
+		args: []string{r.PathValue("engine"), r.PathValue("username"), host},
+	})
+}
+
+type installRequest struct {
+	Version      string `json:"version"`
+	Memory       int    `json:"memory"`
+	PassivePorts string `json:"passive_ports"`
+	// Reinstall replaces an existing installation; otherwise it is kept
+	Reinstall bool `json:"reinstall"`
+}
+
+func (s *Server) installComponent(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !components[name] {
+		writeJSON(w, http.StatusNotFound, errorResponse{"unknown component " + name})
+		return
+	}
+	var req installRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	f := flags{"passive-ports": req.PassivePorts}
+	if req.Memory > 0 {
+		f["memory"] = strconv.Itoa(req.Memory)
+	}
+	var args []string
+	if req.Version != "" {
+		args = append(args, req.Version)
+	}
+	answer := "k"
+	if req.Reinstall {
+		answer = "r"
+	}
+	s.run(w, command{path: []string{"install", name}, flags: f, args: args, input: answer + "\n"})
+}
+
+func (s *Server) uninstallComponent(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !components[name] {
+		writeJSON(w, http.StatusNotFound, errorResponse{"unknown component " + name})
+		return
+	}
+	var args []string
+	if version := r.URL.Query().Get("version"); version != "" {
+		args = append(args, version)
+	}
+	s.run(w, command{path: []string{"uninstall", name}, args: args, input: "y\n"})
+}
+
+// childEnv returns the environment of the server without the variables
+// answering or refusing prompts, so the request alone decides the answers
+// (delete_files=false keeps the files also under WEBSTACK_ASSUME_YES)
+func childEnv() []string {
+	var env []string
+	for _, v := range os.Environ() {
+		name, _, _ := strings.Cut(v, "=")
+		if name != prompt.AssumeYesEnv && name != prompt.ForbidDestructiveEnv {
+			env = append(env, v)
+		}
+	}
+	return env
+}
+
+// flags maps flag names to values; empty values are left out
+type flags map[string]string
+
+// command is a webstack invocation
+type command struct {
+	path  []string
+	flags flags
+	args  []string
+	input string // Answers to the command's prompts
+	read  bool   // Does not change anything, may run alongside others
+}
+
+// run executes a command and responds with its Result
+func (s *Server) run(w http.ResponseWriter, c command) {
+	args := append([]string{}, c.path...)
+	args = append(args, "--no-emoji", "--no-color")
+	if s.DryRun {
+		args = append(args, "--dry-run")
+	}
+	for name, value := range c.flags {
+		if value != "" {
+			args = append(args, "--"+name+"="+value)
+		}
+	}
+	// Positional values can never be taken for flags
+	args = append(args, "--")
+	args = append(args, c.args...)
+
+	if !c.read {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+
+	cmd := exec.Command(s.Executable, args...)
+	cmd.Env = childEnv()
+	cmd.Stdin = strings.NewReader(c.input)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	result := Result{}
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	default:
+		writeJSON(w, http.StatusInternalServerError, errorResponse{fmt.Sprintf("could not run webstack: %v", err)})
+		return
+	}
+	result.OK = result.ExitCode <= 1
+	result.Output = output.String()
+
+	status := http.StatusOK
+	switch result.ExitCode {
+	case 0, 1:
+	case 3:
+		status = http.StatusBadRequest
+	default:
+		status = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, status, result)
+}
+
+// decode reads a JSON request body into v, rejecting unknown fields so a
+// misspelled option is not silently ignored. An empty body is allowed.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && err != io.EOF {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
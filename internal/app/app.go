@@ -0,0 +1,266 @@
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/mysql"
+	"webstack-cli/internal/postgres"
+	"webstack-cli/internal/random"
+	"webstack-cli/internal/ui"
+)
+
+// Options controls an application install
+type Options struct {
+	DBType     string // "mysql" (default) or "postgresql"
+	DBName     string // Defaults to a name derived from the domain
+	DBUser     string // Defaults to the database name
+	NoDatabase bool   // Do not create a database and user
+	Package    string // Composer package for the generic composer installer
+	Force      bool   // Install even if htdocs already contains files
+}
+
+// Database holds the credentials written into the application config
+type Database struct {
+	Type     string
+	Host     string
+	Port     string
+	Name     string
+	User     string
+	Password string
+}
+
+// appInstaller installs an application into the htdocs directory of a domain
+// and returns the framework preset and web root subfolder to use for it
+type appInstaller func(d *domain.Domain, htdocs string, db *Database, opts Options) (preset, docRoot string, err error)
+
+var installers = map[string]appInstaller{
+	"wordpress":  installWordPress,
+	"laravel":    installLaravel,
+	"composer":   installComposerProject,
+	"roundcube":  installRoundcube,
+	"snappymail": installSnappyMail,
+}
+
+// fileBasedApps keep their data in files and get no database
+var fileBasedApps = map[string]bool{
+	"snappymail": true,
+}
+
+// redisSocket is the socket 'webstack install redis' sets up; it only exists
+// while Redis is running
+const redisSocket = "/run/redis/redis-server.sock"
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// Apps returns the names of the available application installers
+func Apps() []string {
+	var apps []string
+	for name := range installers {
+		apps = append(apps, name)
+	}
+	sort.Strings(apps)
+	return apps
+}
+
+// Install downloads an application into an existing domain, creates its
+// database and user, writes the application config, sets ownership and
+// regenerates the vhost with the application's rules. It reports whether
+// the application was installed.
+func Install(appName, domainName string, opts Options) bool {
+	install, ok := installers[appName]
+	if !ok {
+		ui.Invalid("Unknown application: %s. Available: %s\n", appName, strings.Join(Apps(), ", "))
+		return false
+	}
+
+	d, err := domain.GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found. Create it first with: sudo webstack domain add %s\n", domainName, domainName)
+		return false
+	}
+	if d.PHPVersion == "" {
+		ui.Fail("❌ %s is a %s domain; applications need the nginx or apache backend\n", d.Name, d.Backend)
+		return false
+	}
+
+	if d.CustomRoot() {
+		ui.Fail("❌ %s serves %s (--root); applications install into htdocs. Go back with 'webstack domain edit %s --docroot .'\n", d.Name, d.DocumentRoot, d.Name)
+		return false
+	}
+
+	htdocs := filepath.Join(d.HomeDir(), "htdocs")
+	if !opts.Force && !isEmptyWebroot(htdocs) {
+		ui.Fail("❌ %s already contains files. Use --force to install anyway\n", htdocs)
+		return false
+	}
+
+	fmt.Printf("📦 Installing %s for %s\n", appName, d.Name)
+
+	var db *Database
+	if !opts.NoDatabase && !fileBasedApps[appName] {
+		db, err = newDatabase(d.Name, opts)
+		if err != nil {
+			ui.Invalid("Invalid database settings: %v\n", err)
+			return false
+		}
+		if err := createDatabase(db); err != nil {
+			ui.Fail("❌ Could not create database: %v\n", err)
+			return false
+		}
+	}
+
+	preset, docRoot, err := install(d, htdocs, db, opts)
+	if err != nil {
+		ui.Fail("❌ %s installation failed: %v\n", appName, err)
+		return false
+	}
+
+	// The web server runs PHP as www-data
+	fmt.Println("🔐 Setting ownership to www-data...")
+	if err := dryrun.Run(exec.Command("chown", "-R", "www-data:www-data", htdocs)); err != nil {
+		ui.Warn("⚠️  Warning: Could not set ownership: %v\n", err)
+	}
+
+	// Apply the application's vhost rules
+	d.Preset = preset
+	d.DocRoot = docRoot
+	d.DocumentRoot = filepath.Join(htdocs, docRoot)
+	if err := domain.Reconfigure(*d); err != nil {
+		ui.Warn("⚠️  Warning: Could not apply %s vhost rules: %v\n", appName, err)
+	}
+
+	fmt.Printf("✅ %s installed for %s\n", appName, d.Name)
+	fmt.Printf("   Document Root: %s\n", d.DocumentRoot)
+	if db != nil {
+		fmt.Printf("   Database: %s (%s)\n", db.Name, db.Type)
+		fmt.Printf("   Database User: %s\n", db.User)
+		fmt.Printf("   Database Password: %s\n", db.Password)
+	}
+	return true
+}
+
+// redisAvailable reports whether Redis is running with its unix socket, so
+// installed applications can use it as their object cache
+func redisAvailable() bool {
+	_, err := os.Stat(redisSocket)
+	return err == nil
+}
+
+// isEmptyWebroot reports whether htdocs only contains the placeholder
+// index.php created by 'webstack domain add'
+func isEmptyWebroot(htdocs string) bool {
+	empty := true
+	filepath.Walk(htdocs, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		if info.Name() == "index.php" {
+			if data, err := ioutil.ReadFile(path); err == nil && strings.Contains(string(data), "phpinfo();") {
+				return nil
+			}
+		}
+		empty = false
+		return filepath.SkipDir
+	})
+	return empty
+}
+
+// newDatabase derives the database name, user and a random password
+func newDatabase(domainName string, opts Options) (*Database, error) {
+	db := &Database{Type: opts.DBType, Host: "127.0.0.1", Name: opts.DBName, User: opts.DBUser}
+	if db.Type == "" {
+		db.Type = "mysql"
+	}
+
+	switch db.Type {
+	case "mysql":
+		db.Port = "3306"
+	case "postgresql":
+		db.Port = "5432"
+	default:
+		return nil, fmt.Errorf("database type must be mysql or postgresql: %s", db.Type)
+	}
+
+	if db.Name == "" {
+		db.Name = identifierFromDomain(domainName, 64)
+	}
+	if db.User == "" {
+		db.User = identifierFromDomain(domainName, 32)
+	}
+	for _, name := range []string{db.Name, db.User} {
+		if !identifierPattern.MatchString(name) {
+			return nil, fmt.Errorf("%s may only contain letters, digits and underscores", name)
+		}
+	}
+
+	password, err := random.String(24)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate password: %v", err)
+	}
+	db.Password = password
+	return db, nil
+}
+
+// identifierFromDomain turns example.com into example_com
+func identifierFromDomain(domainName string, maxLen int) string {
+	name := regexp.MustCompile(`[^a-z0-9_]`).ReplaceAllString(strings.ToLower(domainName), "_")
+	if len(name) > maxLen {
+		name = name[:maxLen]
+	}
+	return name
+}
+
+// createDatabase creates the database and a user with full privileges on it
+func createDatabase(db *Database) error {
+	fmt.Printf("🗄️  Creating %s database %s and user %s...\n", db.Type, db.Name, db.User)
+
+	if db.Type == "postgresql" {
+		statements := []string{
+			fmt.Sprintf("CREATE USER %s WITH PASSWORD %s;", postgres.QuoteIdent(db.User), postgres.Quote(db.Password)),
+			fmt.Sprintf("CREATE DATABASE %s OWNER %s;", postgres.QuoteIdent(db.Name), postgres.QuoteIdent(db.User)),
+		}
+		for _, statement := range statements {
+			// On stdin, so the password doesn't show up in the process list
+			cmd := exec.Command("sudo", "-u", "postgres", "psql", "-v", "ON_ERROR_STOP=1")
+			cmd.Stdin = strings.NewReader(statement)
+			if output, err := dryrun.CombinedOutput(cmd); err != nil {
+				return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+			}
+		}
+		return nil
+	}
+
+	name, password := mysql.QuoteIdent(db.Name), mysql.Quote(db.Password)
+	sql := fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;\n"+
+		"CREATE USER IF NOT EXISTS %s IDENTIFIED BY %s;\n"+
+		"CREATE USER IF NOT EXISTS %s IDENTIFIED BY %s;\n"+
+		"GRANT ALL PRIVILEGES ON %s.* TO %s;\n"+
+		"GRANT ALL PRIVILEGES ON %s.* TO %s;\n"+
+		"FLUSH PRIVILEGES;",
+		name, mysql.Account(db.User, "localhost"), password, mysql.Account(db.User, "127.0.0.1"), password,
+		name, mysql.Account(db.User, "localhost"), name, mysql.Account(db.User, "127.0.0.1"))
+
+	if output, err := mysql.Exec("root", mysql.RootPassword("mysql"), sql); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// download fetches url to path with curl, falling back to wget
+func download(url, path string) error {
+	fmt.Printf("📥 Downloading %s...\n", url)
+	if err := dryrun.Run(exec.Command("curl", "-fsSL", "-o", path, url)); err != nil {
+		if err := dryrun.Run(exec.Command("wget", "-q", "-O", path, url)); err != nil {
+			return fmt.Errorf("could not download %s: %v", url, err)
+		}
+	}
+	return nil
+}
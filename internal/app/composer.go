@@ -0,0 +1,231 @@
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/pkg"
+	"webstack-cli/internal/ui"
+)
+
+// installLaravel creates a new Laravel project in htdocs and points its
+// .env at the database
+func installLaravel(d *domain.Domain, htdocs string, db *Database, opts Options) (string, string, error) {
+	if err := createProject(d, htdocs, "laravel/laravel"); err != nil {
+		return "", "", err
+	}
+
+	values := map[string]string{"APP_URL": appURL(d)}
+	if db != nil {
+		for key, value := range laravelDatabaseEnv(db) {
+			values[key] = value
+		}
+	}
+	if redisAvailable() {
+		fmt.Println("🗄️  Using Redis for the cache and sessions")
+		for key, value := range laravelRedisEnv(d) {
+			values[key] = value
+		}
+	}
+	if err := updateEnvFile(htdocs, values); err != nil {
+		return "", "", err
+	}
+
+	// storage and bootstrap/cache must be writable by PHP-FPM
+	for _, dir := range []string{"storage", "bootstrap/cache"} {
+		if err := dryrun.Run(exec.Command("chmod", "-R", "ug+rwX", filepath.Join(htdocs, dir))); err != nil {
+			ui.Warn("⚠️  Warning: Could not make %s writable: %v\n", dir, err)
+		}
+	}
+
+	if db != nil {
+		fmt.Println("🗄️  Running migrations...")
+		if err := runArtisan(d, htdocs, "migrate", "--force"); err != nil {
+			ui.Warn("⚠️  Warning: Migrations failed, run 'php artisan migrate' manually: %v\n", err)
+		}
+	}
+
+	return "laravel", "public", nil
+}
+
+// installComposerProject creates any composer project in htdocs, filling in
+// the database settings when it ships a .env file
+func installComposerProject(d *domain.Domain, htdocs string, db *Database, opts Options) (string, string, error) {
+	if opts.Package == "" {
+		return "", "", fmt.Errorf("--package is required, e.g. --package symfony/skeleton")
+	}
+	if err := createProject(d, htdocs, opts.Package); err != nil {
+		return "", "", err
+	}
+
+	if db != nil {
+		envPath := filepath.Join(htdocs, ".env")
+		data, _ := ioutil.ReadFile(envPath)
+		values := laravelDatabaseEnv(db)
+		if regexp.MustCompile(`(?m)^#?\s*DATABASE_URL=`).Match(data) {
+			values = map[string]string{"DATABASE_URL": databaseURL(db)}
+		}
+		if _, err := os.Stat(envPath); err == nil || dryrun.Enabled() {
+			if err := updateEnvFile(htdocs, values); err != nil {
+				return "", "", err
+			}
+		} else {
+			fmt.Println("ℹ️  Project has no .env file, configure the database credentials manually")
+		}
+	}
+
+	// Detect the framework to pick matching vhost rules
+	preset := ""
+	if fileExists(filepath.Join(htdocs, "artisan")) {
+		preset = "laravel"
+	} else if fileExists(filepath.Join(htdocs, "symfony.lock")) || fileExists(filepath.Join(htdocs, "bin", "console")) {
+		preset = "symfony"
+	}
+
+	docRoot := ""
+	if info, err := os.Stat(filepath.Join(htdocs, "public")); err == nil && info.IsDir() {
+		docRoot = "public"
+	}
+
+	return preset, docRoot, nil
+}
+
+// createProject runs composer create-project into htdocs with the domain's
+// PHP version
+func createProject(d *domain.Domain, htdocs, project string) error {
+	composer, err := exec.LookPath("composer")
+	if err != nil {
+		fmt.Println("📦 Installing composer...")
+		if err := pkg.Install("composer"); err != nil {
+			return fmt.Errorf("could not install composer: %v", err)
+		}
+		composer = "/usr/bin/composer"
+	}
+
+	// composer needs an empty target directory; only the placeholder
+	// index.php is there at this point
+	if err := dryrun.RemoveAll(htdocs); err != nil {
+		return fmt.Errorf("could not clear %s: %v", htdocs, err)
+	}
+
+	fmt.Printf("📦 Creating %s project...\n", project)
+	cmd := exec.Command(phpBinary(d), composer, "create-project", "--no-interaction", "--prefer-dist", project, htdocs)
+	cmd.Env = append(os.Environ(), "COMPOSER_ALLOW_SUPERUSER=1")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := dryrun.Run(cmd); err != nil {
+		return fmt.Errorf("composer create-project %s failed: %v", project, err)
+	}
+	return nil
+}
+
+func runArtisan(d *domain.Domain, htdocs string, args ...string) error {
+	cmd := exec.Command(phpBinary(d), append([]string{"artisan"}, args...)...)
+	cmd.Dir = htdocs
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return dryrun.Run(cmd)
+}
+
+// phpBinary returns the CLI binary matching the domain's PHP-FPM version
+func phpBinary(d *domain.Domain) string {
+	if d.PHPVersion != "" {
+		if path, err := exec.LookPath("php" + d.PHPVersion); err == nil {
+			return path
+		}
+	}
+	return "php"
+}
+
+func appURL(d *domain.Domain) string {
+	if d.SSLEnabled {
+		return "https://" + d.Name
+	}
+	return "http://" + d.Name
+}
+
+func laravelDatabaseEnv(db *Database) map[string]string {
+	connection := "mysql"
+	if db.Type == "postgresql" {
+		connection = "pgsql"
+	}
+	return map[string]string{
+		"DB_CONNECTION": connection,
+		"DB_HOST":       db.Host,
+		"DB_PORT":       db.Port,
+		"DB_DATABASE":   db.Name,
+		"DB_USERNAME":   db.User,
+		"DB_PASSWORD":   db.Password,
+	}
+}
+
+// laravelRedisEnv connects Laravel to the Redis socket, with a key prefix per
+// domain so sites sharing the server do not read each other's cache
+func laravelRedisEnv(d *domain.Domain) map[string]string {
+	return map[string]string{
+		"REDIS_CLIENT":   "phpredis",
+		"REDIS_HOST":     redisSocket,
+		"REDIS_PORT":     "0",
+		"REDIS_PREFIX":   identifierFromDomain(d.Name, 32) + "_",
+		"CACHE_STORE":    "redis",
+		"SESSION_DRIVER": "redis",
+	}
+}
+
+// databaseURL builds the Doctrine style DATABASE_URL used by Symfony
+func databaseURL(db *Database) string {
+	if db.Type == "postgresql" {
+		return fmt.Sprintf("\"postgresql://%s:%s@%s:%s/%s?charset=utf8\"", db.User, db.Password, db.Host, db.Port, db.Name)
+	}
+	return fmt.Sprintf("\"mysql://%s:%s@%s:%s/%s?charset=utf8mb4\"", db.User, db.Password, db.Host, db.Port, db.Name)
+}
+
+// updateEnvFile sets keys in htdocs/.env, replacing existing (or commented
+// out) assignments and appending the rest
+func updateEnvFile(htdocs string, values map[string]string) error {
+	envPath := filepath.Join(htdocs, ".env")
+	data, err := ioutil.ReadFile(envPath)
+	if err != nil && !dryrun.Enabled() {
+		if data, err = ioutil.ReadFile(filepath.Join(htdocs, ".env.example")); err != nil {
+			return fmt.Errorf("could not read .env: %v", err)
+		}
+	}
+
+	content := string(data)
+	for key, value := range values {
+		line := key + "=" + value
+		pattern := regexp.MustCompile(`(?m)^#?\s*` + regexp.QuoteMeta(key) + `=.*$`)
+		if pattern.MatchString(content) {
+			replaced := false
+			content = pattern.ReplaceAllStringFunc(content, func(string) string {
+				if replaced {
+					return ""
+				}
+				replaced = true
+				return line
+			})
+			continue
+		}
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += line + "\n"
+	}
+
+	if err := dryrun.WriteFile(envPath, []byte(content), 0640); err != nil {
+		return fmt.Errorf("could not write .env: %v", err)
+	}
+	fmt.Printf("✅ Updated %s\n", envPath)
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,244 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/random"
+	"webstack-cli/internal/ssl"
+	"webstack-cli/internal/ui"
+)
+
+const roundcubeVersion = "1.6.9"
+
+var roundcubeDownloadURL = fmt.Sprintf("https://github.com/roundcube/roundcubemail/releases/download/%s/roundcubemail-%s-complete.tar.gz", roundcubeVersion, roundcubeVersion)
+
+const snappymailDownloadURL = "https://snappymail.eu/repository/latest.tar.gz"
+
+// snappymailDataDir is the SnappyMail data folder relative to htdocs; the
+// snappymail preset denies web access to it
+const snappymailDataDir = "data/_data_/_default_"
+
+// WebmailClients are the webmail applications 'webstack mail webmail install' deploys
+var WebmailClients = []string{"roundcube", "snappymail"}
+
+// WebmailOptions controls a webmail install
+type WebmailOptions struct {
+	Client     string // "roundcube" (default) or "snappymail"
+	Backend    string // Backend of a new domain (default: defaults.backend)
+	PHPVersion string // PHP version of a new domain (default: defaults.php)
+	DBType     string // Roundcube database: "mysql" (default) or "postgresql"
+	Email      string // Let's Encrypt account email (default: defaults.ssl_email)
+	NoSSL      bool   // Do not enable SSL on the domain
+	Force      bool   // Install even if htdocs already contains files
+}
+
+// installRoundcube downloads the Roundcube release into htdocs, writes its
+// config for the local Dovecot and Postfix and creates its tables
+func installRoundcube(d *domain.Domain, htdocs string, db *Database, opts Options) (string, string, error) {
+	if db == nil {
+		return "", "", fmt.Errorf("Roundcube requires a database")
+	}
+
+	archive := filepath.Join(os.TempDir(), "roundcubemail-"+roundcubeVersion+".tar.gz")
+	if err := download(roundcubeDownloadURL, archive); err != nil {
+		return "", "", err
+	}
+	defer dryrun.Remove(archive)
+
+	fmt.Println("📂 Extracting Roundcube...")
+	if err := dryrun.Run(exec.Command("tar", "-xzf", archive, "-C", htdocs, "--strip-components=1")); err != nil {
+		return "", "", fmt.Errorf("could not extract Roundcube: %v", err)
+	}
+	// The web installer is only needed without a written config
+	dryrun.RemoveAll(filepath.Join(htdocs, "installer"))
+
+	desKey, err := random.String(24)
+	if err != nil {
+		return "", "", fmt.Errorf("could not generate the encryption key: %v", err)
+	}
+	configPath := filepath.Join(htdocs, "config", "config.inc.php")
+	if err := dryrun.WriteFile(configPath, []byte(roundcubeConfig(db, desKey)), 0640); err != nil {
+		return "", "", fmt.Errorf("could not write config.inc.php: %v", err)
+	}
+	fmt.Printf("✅ Wrote %s\n", configPath)
+
+	fmt.Println("🗄️  Creating the Roundcube tables...")
+	initdb := exec.Command(phpBinary(d), filepath.Join(htdocs, "bin", "initdb.sh"), "--dir="+filepath.Join(htdocs, "SQL"))
+	if output, err := dryrun.CombinedOutput(initdb); err != nil {
+		return "", "", fmt.Errorf("could not create the Roundcube tables: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return "roundcube", "", nil
+}
+
+// roundcubeConfig returns config.inc.php for the local mail server. Dovecot
+// accepts logins from localhost without TLS; Postfix requires STARTTLS on
+// the submission port, whose certificate is for the mail host name.
+func roundcubeConfig(db *Database, desKey string) string {
+	scheme := "mysql"
+	if db.Type == "postgresql" {
+		scheme = "pgsql"
+	}
+	return fmt.Sprintf(`<?php
+// Written by WebStack CLI; config/defaults.inc.php lists all options
+$config = [];
+$config['db_dsnw'] = '%s://%s:%s@%s/%s';
+
+$config['imap_host'] = 'localhost:143';
+$config['smtp_host'] = 'tls://localhost:587';
+$config['smtp_user'] = '%%u';
+$config['smtp_pass'] = '%%p';
+$config['smtp_conn_options'] = [
+    'ssl' => ['verify_peer' => false, 'verify_peer_name' => false],
+];
+
+$config['des_key'] = '%s';
+$config['product_name'] = 'Webmail';
+$config['skin'] = 'elastic';
+$config['plugins'] = ['archive', 'zipdownload'];
+$config['enable_installer'] = false;
+`, scheme, db.User, db.Password, db.Host, db.Name, desKey)
+}
+
+// installSnappyMail downloads the latest SnappyMail release into htdocs and
+// points its default domain to the local Dovecot and Postfix
+func installSnappyMail(d *domain.Domain, htdocs string, db *Database, opts Options) (string, string, error) {
+	archive := filepath.Join(os.TempDir(), "snappymail-latest.tar.gz")
+	if err := download(snappymailDownloadURL, archive); err != nil {
+		return "", "", err
+	}
+	defer dryrun.Remove(archive)
+
+	fmt.Println("📂 Extracting SnappyMail...")
+	if err := dryrun.Run(exec.Command("tar", "-xzf", archive, "-C", htdocs)); err != nil {
+		return "", "", fmt.Errorf("could not extract SnappyMail: %v", err)
+	}
+
+	domainsDir := filepath.Join(htdocs, snappymailDataDir, "domains")
+	if err := dryrun.MkdirAll(domainsDir, 0755); err != nil {
+		return "", "", fmt.Errorf("could not create %s: %v", domainsDir, err)
+	}
+	// default.json applies to every mail domain without its own file
+	configPath := filepath.Join(domainsDir, "default.json")
+	if err := dryrun.WriteFile(configPath, []byte(snappymailDomainConfig), 0640); err != nil {
+		return "", "", fmt.Errorf("could not write %s: %v", configPath, err)
+	}
+	fmt.Printf("✅ Wrote %s\n", configPath)
+
+	return "snappymail", "", nil
+}
+
+// snappymailDomainConfig connects to Dovecot on localhost and to the
+// submission port with STARTTLS (type 2)
+const snappymailDomainConfig = `{
+    "IMAP": {
+        "host": "localhost",
+        "port": 143,
+        "type": 0,
+        "timeout": 300,
+        "shortLogin": false,
+        "ssl": {
+            "verify_peer": false,
+            "verify_peer_name": false,
+            "allow_self_signed": true
+        }
+    },
+    "SMTP": {
+        "host": "localhost",
+        "port": 587,
+        "type": 2,
+        "timeout": 60,
+        "shortLogin": false,
+        "useAuth": true,
+        "setSender": false,
+        "usePhpMail": false,
+        "ssl": {
+            "verify_peer": false,
+            "verify_peer_name": false,
+            "allow_self_signed": true
+        }
+    },
+    "Sieve": {
+        "enabled": false,
+        "host": "localhost",
+        "port": 4190,
+        "type": 0
+    },
+    "whiteList": ""
+}
+`
+
+// InstallWebmail deploys a webmail client on its own domain: it adds the
+// domain when missing, installs the client wired to the local IMAP and SMTP
+// servers and enables SSL
+func InstallWebmail(domainName string, opts WebmailOptions) {
+	domainName = domain.Normalize(domainName)
+	if opts.Client == "" {
+		opts.Client = "roundcube"
+	}
+	if !containsString(WebmailClients, opts.Client) {
+		ui.Invalid("Invalid webmail client: %s. Use %s\n", opts.Client, strings.Join(WebmailClients, " or "))
+		return
+	}
+	if os.Geteuid() != 0 {
+		ui.Fail("❌ This command requires root privileges (use sudo)\n")
+		return
+	}
+
+	if _, err := os.Stat("/etc/dovecot/conf.d"); err != nil {
+		ui.Warn("⚠️  Dovecot is not installed; logins work once the mail server is installed ('webstack install mail')\n")
+	}
+
+	if !domain.DomainExists(domainName) {
+		backend := opts.Backend
+		if backend == "" {
+			backend = domain.DefaultBackend()
+		}
+		phpVersion := opts.PHPVersion
+		if phpVersion == "" {
+			phpVersion = domain.DefaultPHPVersion()
+		}
+		domain.Add(domainName, backend, phpVersion, domain.AddOptions{})
+		if !domain.DomainExists(domainName) {
+			return
+		}
+	}
+
+	if !Install(opts.Client, domainName, Options{DBType: opts.DBType, Force: opts.Force}) {
+		return
+	}
+
+	scheme := "http"
+	if !opts.NoSSL {
+		certType := "letsencrypt"
+		if strings.HasSuffix(domainName, ".local") || strings.HasSuffix(domainName, ".test") || domainName == "localhost" {
+			certType = "selfsigned"
+		}
+		ssl.EnableWithType(domainName, opts.Email, certType)
+		if d, err := domain.GetDomain(domainName); err == nil && d.SSLEnabled {
+			scheme = "https"
+		}
+	}
+
+	fmt.Printf("✅ Webmail ready: %s://%s/\n", scheme, domainName)
+	fmt.Println("   Log in with a mail account (user@domain) and its password")
+	fmt.Println("   IMAP: localhost:143, SMTP: localhost:587 (STARTTLS)")
+	if opts.Client == "snappymail" {
+		fmt.Printf("   Admin panel: %s://%s/?admin (password in %s after the first visit)\n",
+			scheme, domainName, filepath.Join(domain.HomeDir(domainName), "htdocs", snappymailDataDir, "admin_password.txt"))
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/random"
+)
+
+const wordpressDownloadURL = "https://wordpress.org/latest.tar.gz"
+
+var wordpressSaltKeys = []string{
+	"AUTH_KEY", "SECURE_AUTH_KEY", "LOGGED_IN_KEY", "NONCE_KEY",
+	"AUTH_SALT", "SECURE_AUTH_SALT", "LOGGED_IN_SALT", "NONCE_SALT",
+}
+
+// installWordPress downloads the latest WordPress release into htdocs and
+// writes wp-config.php with the database credentials and fresh salts
+func installWordPress(d *domain.Domain, htdocs string, db *Database, opts Options) (string, string, error) {
+	if db != nil && db.Type != "mysql" {
+		return "", "", fmt.Errorf("WordPress requires a MySQL/MariaDB database")
+	}
+
+	archive := filepath.Join(os.TempDir(), "wordpress-latest.tar.gz")
+	if err := download(wordpressDownloadURL, archive); err != nil {
+		return "", "", err
+	}
+	defer dryrun.Remove(archive)
+
+	// The placeholder index.php is replaced by the one from the archive
+	fmt.Println("📂 Extracting WordPress...")
+	if err := dryrun.Run(exec.Command("tar", "-xzf", archive, "-C", htdocs, "--strip-components=1")); err != nil {
+		return "", "", fmt.Errorf("could not extract WordPress: %v", err)
+	}
+
+	if db == nil {
+		fmt.Println("ℹ️  No database created, finish the setup in the browser")
+		return "wordpress", "", nil
+	}
+
+	content, err := wordpressConfig(db, objectCacheConfig(d))
+	if err != nil {
+		return "", "", err
+	}
+	configPath := filepath.Join(htdocs, "wp-config.php")
+	if err := dryrun.WriteFile(configPath, []byte(content), 0640); err != nil {
+		return "", "", fmt.Errorf("could not write wp-config.php: %v", err)
+	}
+	fmt.Printf("✅ Wrote %s\n", configPath)
+
+	return "wordpress", "", nil
+}
+
+// objectCacheConfig returns the wp-config.php settings of the Redis Object
+// Cache plugin when Redis is installed, with a key prefix per domain so sites
+// sharing the server do not read each other's cache
+func objectCacheConfig(d *domain.Domain) string {
+	if !redisAvailable() {
+		return ""
+	}
+	return fmt.Sprintf(`define('WP_REDIS_SCHEME', 'unix');
+define('WP_REDIS_PATH', '%s');
+define('WP_REDIS_PREFIX', '%s:');
+define('WP_CACHE_KEY_SALT', '%s:');
+
+`, redisSocket, d.Name, d.Name)
+}
+
+// wordpressConfig renders wp-config.php
+func wordpressConfig(db *Database, cache string) (string, error) {
+	var salts strings.Builder
+	for _, key := range wordpressSaltKeys {
+		salt, err := random.String(64)
+		if err != nil {
+			return "", fmt.Errorf("could not generate salts: %v", err)
+		}
+		fmt.Fprintf(&salts, "define('%s', '%s');\n", key, salt)
+	}
+
+	return fmt.Sprintf(`<?php
+// Generated by WebStack CLI
+
+define('DB_NAME', '%s');
+define('DB_USER', '%s');
+define('DB_PASSWORD', '%s');
+define('DB_HOST', 'localhost');
+define('DB_CHARSET', 'utf8mb4');
+define('DB_COLLATE', '');
+
+%s
+%s$table_prefix = 'wp_';
+
+define('WP_DEBUG', false);
+define('FS_METHOD', 'direct');
+
+if ( ! defined( 'ABSPATH' ) ) {
+	define( 'ABSPATH', __DIR__ . '/' );
+}
+
+require_once ABSPATH . 'wp-settings.php';
+`, db.Name, db.User, db.Password, salts.String(), cache), nil
+}
@@ -6,7 +6,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"webstack-cli/internal/store"
+	"webstack-cli/internal/ui"
 )
 
 // createTarGz creates a tar.gz archive from a directory
@@ -33,7 +37,15 @@ func createTarGz(sourcePath, targetPath string) error {
 			return err
 		}
 
-		header, err := tar.FileInfoHeader(info, "")
+		// Store symlinks as links instead of following them
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
 		if err != nil {
 			return err
 		}
@@ -43,7 +55,7 @@ func createTarGz(sourcePath, targetPath string) error {
 			return err
 		}
 
-		if !info.IsDir() {
+		if info.Mode().IsRegular() {
 			file, err := os.Open(path)
 			if err != nil {
 				return err
@@ -73,6 +85,13 @@ func extractTarGz(archivePath, targetDir string) error {
 	}
 	defer gzipReader.Close()
 
+	// Entries and symlinks must stay inside targetDir, archives may be
+	// crafted (domain restore takes any file)
+	root, err := filepath.EvalSymlinks(targetDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", targetDir, err)
+	}
+
 	tarReader := tar.NewReader(gzipReader)
 
 	for {
@@ -84,7 +103,31 @@ func extractTarGz(archivePath, targetDir string) error {
 			return fmt.Errorf("error reading tar: %w", err)
 		}
 
-		path := filepath.Join(targetDir, header.Name)
+		path := filepath.Join(root, header.Name)
+		if !resolvesInside(root, path) {
+			return fmt.Errorf("archive entry %s is outside the target directory", header.Name)
+		}
+		if header.Typeflag == tar.TypeSymlink {
+			// Relative targets only, cleaned so the OS resolves them like
+			// the check does
+			target := filepath.Clean(header.Linkname)
+			if filepath.IsAbs(target) {
+				return fmt.Errorf("archive entry %s links to an absolute path: %s", header.Name, header.Linkname)
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return fmt.Errorf("failed to create parent directory: %w", err)
+			}
+			parent, err := filepath.EvalSymlinks(filepath.Dir(path))
+			if err != nil || !resolvesInside(root, filepath.Join(parent, target)) {
+				return fmt.Errorf("archive entry %s links outside the target directory: %s", header.Name, header.Linkname)
+			}
+			os.Remove(path)
+			if err := os.Symlink(target, path); err != nil {
+				return fmt.Errorf("failed to create symlink: %w", err)
+			}
+			continue
+		}
+
 		if header.FileInfo().IsDir() {
 			if err := os.MkdirAll(path, header.FileInfo().Mode()); err != nil {
 				return fmt.Errorf("failed to create directory: %w", err)
@@ -110,6 +153,29 @@ func extractTarGz(archivePath, targetDir string) error {
 	return nil
 }
 
+// resolvesInside reports whether path is inside dir once the symlinks of
+// its existing part are resolved. dir must be resolved already.
+func resolvesInside(dir, path string) bool {
+	existing, rest := path, ""
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return false
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, filepath.Join(resolved, rest))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
 // backupDirectory backs up a directory structure
 func backupDirectory(sourceDir, destDir, name string) (int64, error) {
 	archivePath := filepath.Join(destDir, name+".tar.gz")
@@ -155,17 +221,17 @@ func backupMetadata(backupPath string) error {
 	metadataDir := filepath.Join(backupPath, "metadata")
 	os.MkdirAll(metadataDir, 0755)
 
-	// Backup domains.json
-	if _, err := os.Stat(domainsFile); err == nil {
-		if _, err := backupFile(domainsFile, metadataDir); err != nil {
-			return fmt.Errorf("failed to backup domains.json: %w", err)
+	// domains.json and ssl.json, also when they live in webstack.db
+	for _, name := range []string{"domains.json", "ssl.json"} {
+		data, found, err := store.Dump(name)
+		if err != nil {
+			return fmt.Errorf("failed to backup %s: %w", name, err)
 		}
-	}
-
-	// Backup ssl.json
-	if _, err := os.Stat(sslFile); err == nil {
-		if _, err := backupFile(sslFile, metadataDir); err != nil {
-			return fmt.Errorf("failed to backup ssl.json: %w", err)
+		if !found {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(metadataDir, name), data, 0644); err != nil {
+			return fmt.Errorf("failed to backup %s: %w", name, err)
 		}
 	}
 
@@ -188,6 +254,17 @@ func restoreMetadata(backupPath string) error {
 	for _, file := range files {
 		srcFile := filepath.Join(metadataDir, file)
 		if _, err := os.Stat(srcFile); err == nil {
+			if store.Managed(file) {
+				data, err := os.ReadFile(srcFile)
+				if err == nil {
+					err = store.Restore(file, data)
+				}
+				if err != nil {
+					return fmt.Errorf("failed to restore %s: %w", file, err)
+				}
+				continue
+			}
+
 			dstFile := filepath.Join("/etc/webstack", file)
 			os.MkdirAll("/etc/webstack", 0755)
 
@@ -220,16 +297,25 @@ func backupFull(backupPath string, opts BackupOptions) (int64, int64, error) {
 	}
 
 	for _, domain := range domains {
-		domainPath := filepath.Join("/var/www", domain)
+		domainPath := domainHome(domain)
 		domainBackupPath := filepath.Join(domainsBackupDir, domain)
 		os.MkdirAll(domainBackupPath, 0755)
 
 		size, err := backupDirectory(domainPath, domainBackupPath, "htdocs")
 		if err != nil {
-			fmt.Printf("⚠️  Warning: Could not backup domain %s: %v\n", domain, err)
+			ui.Warn("⚠️  Warning: Could not backup domain %s: %v\n", domain, err)
 			continue
 		}
 		totalSize += size
+
+		if root := customRoot(domain); root != "" {
+			size, err := backupDirectory(root, domainBackupPath, "root")
+			if err != nil {
+				ui.Warn("⚠️  Warning: Could not backup document root of %s: %v\n", domain, err)
+				continue
+			}
+			totalSize += size
+		}
 	}
 
 	// Backup databases
@@ -239,6 +325,9 @@ func backupFull(backupPath string, opts BackupOptions) (int64, int64, error) {
 	mysqlSize, _ := backupMySQLDatabases(databasesBackupDir)
 	postgresSize, _ := backupPostgreSQLDatabases(databasesBackupDir)
 	totalSize += mysqlSize + postgresSize
+	for _, domain := range domains {
+		totalSize += backupSQLiteDatabases(domain, databasesBackupDir)
+	}
 
 	// Backup web server configs
 	configsBackupDir := filepath.Join(backupPath, "configs")
@@ -253,6 +342,7 @@ func backupFull(backupPath string, opts BackupOptions) (int64, int64, error) {
 
 	backupDirectory("/etc/ssl/webstack", sslBackupDir, "selfsigned")
 	backupDirectory("/etc/letsencrypt", sslBackupDir, "letsencrypt")
+	backupDirectory("/etc/webstack/ssl", sslBackupDir, "imported")
 
 	// Backup firewall rules
 	fwBackupDir := filepath.Join(backupPath, "firewall")
@@ -260,6 +350,7 @@ func backupFull(backupPath string, opts BackupOptions) (int64, int64, error) {
 
 	backupFile("/etc/iptables/rules.v4", fwBackupDir)
 	backupFile("/etc/iptables/rules.v6", fwBackupDir)
+	backupFile("/etc/nftables.conf", fwBackupDir)
 
 	// Calculate compressed size
 	filepath.Walk(backupPath, func(path string, info os.FileInfo, err error) error {
@@ -287,12 +378,20 @@ func backupDomain(backupPath string, opts BackupOptions) (int64, int64, error) {
 	os.MkdirAll(domainBackupDir, 0755)
 
 	// Backup domain files
-	domainPath := filepath.Join("/var/www", domain)
+	domainPath := domainHome(domain)
 	size, err := backupDirectory(domainPath, domainBackupDir, "files")
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to backup domain files: %w", err)
 	}
 	totalSize += size
+	if root := customRoot(domain); root != "" {
+		size, err := backupDirectory(root, domainBackupDir, "root")
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to backup document root: %w", err)
+		}
+		totalSize += size
+	}
+	totalSize += backupSQLiteDatabases(domain, filepath.Join(backupPath, "databases"))
 
 	// Backup web server configs
 	configDir := filepath.Join(backupPath, "configs")
@@ -320,6 +419,12 @@ func backupDomain(backupPath string, opts BackupOptions) (int64, int64, error) {
 		totalSize += size
 	}
 
+	importedPath := filepath.Join("/etc/webstack/ssl", domain)
+	if _, err := os.Stat(importedPath); err == nil {
+		size, _ := backupDirectory(importedPath, sslDir, "imported-"+domain)
+		totalSize += size
+	}
+
 	selfsignedCert := filepath.Join("/etc/ssl/webstack", domain+".crt")
 	if _, err := os.Stat(selfsignedCert); err == nil {
 		backupFile(selfsignedCert, sslDir)
@@ -328,7 +433,7 @@ func backupDomain(backupPath string, opts BackupOptions) (int64, int64, error) {
 
 	// Backup metadata
 	if err := backupMetadata(backupPath); err != nil {
-		fmt.Printf("⚠️  Warning: Could not backup metadata: %v\n", err)
+		ui.Warn("⚠️  Warning: Could not backup metadata: %v\n", err)
 	}
 
 	// Calculate compressed size
@@ -362,18 +467,23 @@ func backupDatabase(backupPath string, opts BackupOptions) (int64, int64, error)
 	dbType := parts[0]
 	dbName := parts[1]
 
-	databasesDir := filepath.Join(backupPath, "databases", dbType)
-	os.MkdirAll(databasesDir, 0755)
-
-	switch dbType {
-	case "mysql":
+	switch {
+	case dbType == "mysql":
+		databasesDir := filepath.Join(backupPath, "databases", dbType)
+		os.MkdirAll(databasesDir, 0755)
 		size, err := dumpMySQLDatabase(dbName, databasesDir)
 		if err != nil {
 			return 0, 0, fmt.Errorf("failed to backup MySQL database: %w", err)
 		}
 		totalSize = size
-	case "postgresql":
-		size, err := dumpPostgreSQLDatabase(dbName, databasesDir)
+	case isPostgresDBType(dbType):
+		cluster, err := postgresCluster(dbType)
+		if err != nil {
+			return 0, 0, err
+		}
+		databasesDir := filepath.Join(backupPath, "databases", postgresDBType(cluster))
+		os.MkdirAll(databasesDir, 0755)
+		size, err := dumpPostgreSQLDatabase(cluster, dbName, databasesDir)
 		if err != nil {
 			return 0, 0, fmt.Errorf("failed to backup PostgreSQL database: %w", err)
 		}
@@ -384,7 +494,7 @@ func backupDatabase(backupPath string, opts BackupOptions) (int64, int64, error)
 
 	// Backup metadata
 	if err := backupMetadata(backupPath); err != nil {
-		fmt.Printf("⚠️  Warning: Could not backup metadata: %v\n", err)
+		ui.Warn("⚠️  Warning: Could not backup metadata: %v\n", err)
 	}
 
 	// Calculate compressed size
@@ -433,21 +543,39 @@ func restoreDomains(backupPath, domain string) (int, error) {
 			sourcePath = filepath.Join(domainsDir, domainName, "files.tar.gz")
 		}
 
-		destPath := filepath.Join("/var/www", domainName)
+		destPath := domainHome(domainName)
 		os.MkdirAll(destPath, 0755)
 
 		if err := extractTarGz(sourcePath, destPath); err != nil {
-			fmt.Printf("⚠️  Could not restore domain %s: %v\n", domainName, err)
+			ui.Warn("⚠️  Could not restore domain %s: %v\n", domainName, err)
 			continue
 		}
+		// Document root given with --root, archived next to the domain folder
+		rootPath := filepath.Join(domainsDir, domainName, "root.tar.gz")
+		if _, err := os.Stat(rootPath); err != nil {
+			rootPath = ""
+		}
+		if root := customRoot(domainName); root != "" && rootPath != "" {
+			os.MkdirAll(root, 0755)
+			if err := extractTarGz(rootPath, root); err != nil {
+				ui.Warn("⚠️  Could not restore document root of %s: %v\n", domainName, err)
+			}
+		}
+		restoreSQLiteDatabases(domainName, filepath.Join(backupPath, "databases", "sqlite", domainName))
 
 		// Restore web server configs
 		configsDir := filepath.Join(backupPath, "configs")
 		nginxConfig := filepath.Join(configsDir, domainName+".conf")
 		if _, err := os.Stat(nginxConfig); err == nil {
-			destConfig := filepath.Join("/etc/nginx/sites-available", domainName+".conf")
-			copyFile(nginxConfig, destConfig)
-			os.Symlink(destConfig, filepath.Join("/etc/nginx/sites-enabled", domainName+".conf"))
+			if _, err := os.Stat("/etc/nginx"); err == nil {
+				destConfig := filepath.Join("/etc/nginx/sites-available", domainName+".conf")
+				copyFile(nginxConfig, destConfig)
+				os.Symlink(destConfig, filepath.Join("/etc/nginx/sites-enabled", domainName+".conf"))
+			} else if data, err := os.ReadFile(nginxConfig); err == nil && strings.Contains(string(data), "<VirtualHost") {
+				// Apache-only server: restore the Apache vhost and leave /etc/nginx alone
+				copyFile(nginxConfig, filepath.Join("/etc/apache2/sites-available", domainName+".conf"))
+				exec.Command("a2ensite", domainName).Run()
+			}
 		}
 
 		restored++
@@ -492,17 +620,24 @@ func restoreDatabases(backupPath string) (int, error) {
 
 			sqlPath := filepath.Join(dbDir, dbFile.Name())
 
-			switch dbType {
-			case "mysql":
+			switch {
+			case dbType == "mysql":
 				if err := restoreMySQLDatabase(dbName, sqlPath); err != nil {
-					fmt.Printf("⚠️  Could not restore MySQL database %s: %v\n", dbName, err)
+					ui.Warn("⚠️  Could not restore MySQL database %s: %v\n", dbName, err)
 					continue
 				}
-			case "postgresql":
-				if err := restorePostgreSQLDatabase(dbName, sqlPath); err != nil {
-					fmt.Printf("⚠️  Could not restore PostgreSQL database %s: %v\n", dbName, err)
+			case isPostgresDBType(dbType):
+				cluster, err := postgresCluster(dbType)
+				if err != nil {
+					ui.Warn("⚠️  Could not restore PostgreSQL database %s: %v\n", dbName, err)
 					continue
 				}
+				if err := restorePostgreSQLDatabase(cluster, dbName, sqlPath); err != nil {
+					ui.Warn("⚠️  Could not restore PostgreSQL database %s: %v\n", dbName, err)
+					continue
+				}
+			default:
+				continue
 			}
 
 			restored++
@@ -0,0 +1,81 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeArchive writes a tar.gz holding the given headers, with content for
+// regular files
+func writeArchive(t *testing.T, headers []tar.Header) string {
+	path := filepath.Join(t.TempDir(), "archive.tar.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, h := range headers {
+		h := h
+		if h.Typeflag == tar.TypeReg {
+			h.Size = int64(len("content"))
+		}
+		if err := tw.WriteHeader(&h); err != nil {
+			t.Fatal(err)
+		}
+		if h.Typeflag == tar.TypeReg {
+			tw.Write([]byte("content"))
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return path
+}
+
+func TestExtractTarGz(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []tar.Header
+		wantErr bool
+	}{
+		{"files", []tar.Header{
+			{Name: "htdocs/", Typeflag: tar.TypeDir, Mode: 0755},
+			{Name: "htdocs/index.php", Typeflag: tar.TypeReg, Mode: 0644},
+			{Name: "htdocs/current", Typeflag: tar.TypeSymlink, Linkname: "index.php"},
+			{Name: "vendor/bin/tool", Typeflag: tar.TypeSymlink, Linkname: "../../htdocs/index.php"},
+		}, false},
+		{"parent entry", []tar.Header{
+			{Name: "../escaped", Typeflag: tar.TypeReg, Mode: 0644},
+		}, true},
+		{"absolute symlink", []tar.Header{
+			{Name: "etc", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+		}, true},
+		{"symlink to parent", []tar.Header{
+			{Name: "up", Typeflag: tar.TypeSymlink, Linkname: ".."},
+		}, true},
+		{"write through symlink", []tar.Header{
+			{Name: "here", Typeflag: tar.TypeSymlink, Linkname: "."},
+			{Name: "here/x", Typeflag: tar.TypeSymlink, Linkname: "here/../.."},
+			{Name: "here/x/escaped", Typeflag: tar.TypeReg, Mode: 0644},
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := t.TempDir()
+			target := filepath.Join(parent, "target")
+			os.Mkdir(target, 0755)
+
+			err := extractTarGz(writeArchive(t, tt.headers), target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("extractTarGz() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, err := os.Lstat(filepath.Join(parent, "escaped")); err == nil {
+				t.Errorf("a file was written outside the target directory")
+			}
+		})
+	}
+}
@@ -10,6 +10,12 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"webstack-cli/internal/bytesize"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/notify"
+	"webstack-cli/internal/ui"
 )
 
 // Backup represents a backup entry
@@ -21,7 +27,8 @@ type Backup struct {
 	SizeBytes         int64               `json:"size_bytes"`
 	CompressedSize    int64               `json:"compressed_size"`
 	Compression       string              `json:"compression"`
-	Encryption        string              `json:"encryption"`
+	Encryption        string              `json:"encryption"`           // "none", "age" or "gpg"
+	Recipients        []string            `json:"recipients,omitempty"` // age recipients or GPG keys the archive is encrypted to
 	Checksum          string              `json:"checksum"`
 	Verified          bool                `json:"verified"`
 	DomainsIncluded   []string            `json:"domains_included,omitempty"`
@@ -33,7 +40,7 @@ type BackupOptions struct {
 	Type        string
 	Scope       string
 	Compression string
-	Encryption  string
+	Encryption  string // "none", age recipients (age1...) or GPG recipients, comma-separated
 }
 
 // StorageStatus represents backup storage information
@@ -60,10 +67,32 @@ func init() {
 	os.MkdirAll(backupArchiveDir, 0755)
 }
 
-// Create creates a new backup
+// Create creates a new backup and reports the outcome to the notification
+// channels
 func Create(opts BackupOptions) (string, int64, int64, error) {
+	backupID, totalSize, compressedSize, err := create(opts)
+	scope := opts.Type
+	if opts.Scope != "" {
+		scope += " " + opts.Scope
+	}
+	if err != nil {
+		notify.Send(notify.BackupFailed, "", "Backup failed ("+scope+")", err.Error())
+	} else {
+		notify.Send(notify.BackupCompleted, "", "Backup "+backupID+" completed ("+scope+")",
+			fmt.Sprintf("%s, %s compressed", bytesize.Format(totalSize), bytesize.Format(compressedSize)))
+	}
+	return backupID, totalSize, compressedSize, err
+}
+
+func create(opts BackupOptions) (string, int64, int64, error) {
 	fmt.Printf("🔄 Preparing backup: type=%s, scope=%s\n", opts.Type, opts.Scope)
 
+	// Check the recipients before spending time on the backup
+	encryption, recipients, err := ParseEncryption(opts.Encryption)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
 	// Generate backup ID
 	backupID := generateBackupID()
 	stagingPath := filepath.Join(os.TempDir(), "webstack-backup-"+backupID)
@@ -76,13 +105,13 @@ func Create(opts BackupOptions) (string, int64, int64, error) {
 		Type:        opts.Type,
 		Scope:       opts.Scope,
 		Compression: opts.Compression,
-		Encryption:  opts.Encryption,
+		Encryption:  encryption,
+		Recipients:  recipients,
 		Checksum:    "",
 		Verified:    false,
 	}
 
 	var totalSize int64
-	var err error
 
 	// Backup metadata always
 	if err := backupMetadata(stagingPath); err != nil {
@@ -117,6 +146,17 @@ func Create(opts BackupOptions) (string, int64, int64, error) {
 		return "", 0, 0, fmt.Errorf("failed to compress backup: %w", err)
 	}
 
+	// Encrypt before the archive is counted and checksummed, so verify
+	// works without the key
+	if encryption != encryptionNone {
+		fmt.Printf("🔐 Encrypting backup with %s...\n", encryption)
+		archiveFile, err = encryptArchive(archiveFile, encryption, recipients)
+		if err != nil {
+			os.Remove(filepath.Join(backupArchiveDir, backupID+".tar.gz"))
+			return "", 0, 0, fmt.Errorf("failed to encrypt backup: %w", err)
+		}
+	}
+
 	// Get archive size
 	archiveInfo, err := os.Stat(archiveFile)
 	if err != nil {
@@ -148,7 +188,7 @@ func Create(opts BackupOptions) (string, int64, int64, error) {
 	}
 
 	fmt.Printf("✓ Backup completed: %s → %s (compressed)\n",
-		FormatBytes(totalSize), FormatBytes(compressedSize))
+		bytesize.Format(totalSize), bytesize.Format(compressedSize))
 	return backupID, totalSize, compressedSize, nil
 }
 
@@ -216,12 +256,18 @@ func List(domain, since string) ([]Backup, error) {
 	return backups, nil
 }
 
-// Restore restores from a backup
-func Restore(backupID, domain string) (int, error) {
-	archiveFile := filepath.Join(backupArchiveDir, backupID+".tar.gz")
+// Restore restores from a backup. Encrypted backups are decrypted with the
+// age identity file (DefaultAgeIdentity when empty) or root's GPG keyring.
+func Restore(backupID, domain, identity string) (int, error) {
+	archiveFile := archivePath(backupID)
 	if _, err := os.Stat(archiveFile); os.IsNotExist(err) {
 		return 0, fmt.Errorf("backup not found: %s", backupID)
 	}
+	// Files, databases and configurations are restored from the extracted
+	// archive, which a dry run doesn't extract
+	if dryrun.Enabled() {
+		return 0, fmt.Errorf("restoring a backup can't be previewed with --dry-run")
+	}
 
 	// Verify backup first
 	if ok, err := Verify(backupID); !ok || err != nil {
@@ -233,6 +279,19 @@ func Restore(backupID, domain string) (int, error) {
 	os.MkdirAll(stagingDir, 0755)
 	defer os.RemoveAll(stagingDir)
 
+	if archiveEncryption(archiveFile) != encryptionNone {
+		fmt.Printf("🔐 Decrypting backup...\n")
+		decryptDir, err := ioutil.TempDir("", "webstack-decrypt-")
+		if err != nil {
+			return 0, err
+		}
+		defer os.RemoveAll(decryptDir)
+
+		if archiveFile, err = decryptArchive(archiveFile, identity, decryptDir); err != nil {
+			return 0, err
+		}
+	}
+
 	fmt.Printf("📥 Extracting backup from archive...\n")
 
 	// Extract archive to staging
@@ -256,7 +315,7 @@ func Restore(backupID, domain string) (int, error) {
 
 	// Restore metadata
 	if err := restoreMetadata(stagingDir); err != nil {
-		fmt.Printf("⚠️  Warning: Could not restore metadata: %v\n", err)
+		ui.Warn("⚠️  Warning: Could not restore metadata: %v\n", err)
 	} else {
 		itemsRestored++
 	}
@@ -266,7 +325,7 @@ func Restore(backupID, domain string) (int, error) {
 		fmt.Println("📂 Restoring domains...")
 		count, err := restoreDomains(stagingDir, domain)
 		if err != nil {
-			fmt.Printf("⚠️  Warning: Could not fully restore domains: %v\n", err)
+			ui.Warn("⚠️  Warning: Could not fully restore domains: %v\n", err)
 		}
 		itemsRestored += count
 	}
@@ -276,7 +335,7 @@ func Restore(backupID, domain string) (int, error) {
 		fmt.Println("🗄️  Restoring databases...")
 		count, err := restoreDatabases(stagingDir)
 		if err != nil {
-			fmt.Printf("⚠️  Warning: Could not fully restore databases: %v\n", err)
+			ui.Warn("⚠️  Warning: Could not fully restore databases: %v\n", err)
 		}
 		itemsRestored += count
 	}
@@ -290,14 +349,17 @@ func Restore(backupID, domain string) (int, error) {
 
 // Delete deletes a backup
 func Delete(backupID string) error {
-	archiveFile := filepath.Join(backupArchiveDir, backupID+".tar.gz")
+	archiveFile := archivePath(backupID)
 	metadataFile := filepath.Join(backupMetadataDir, backupID+".json")
+	if _, err := os.Stat(archiveFile); os.IsNotExist(err) {
+		return fmt.Errorf("backup not found: %s", backupID)
+	}
 
-	if err := os.Remove(archiveFile); err != nil {
+	if err := dryrun.Remove(archiveFile); err != nil {
 		return fmt.Errorf("failed to delete backup archive: %w", err)
 	}
 
-	if err := os.Remove(metadataFile); err != nil {
+	if err := dryrun.Remove(metadataFile); err != nil {
 		return fmt.Errorf("failed to delete backup metadata: %w", err)
 	}
 
@@ -317,7 +379,7 @@ func Verify(backupID string) (bool, error) {
 		return false, fmt.Errorf("failed to parse metadata: %w", err)
 	}
 
-	archiveFile := filepath.Join(backupArchiveDir, backupID+".tar.gz")
+	archiveFile := archivePath(backupID)
 	checksum, err := calculateFileChecksum(archiveFile)
 	if err != nil {
 		return false, fmt.Errorf("failed to calculate checksum: %w", err)
@@ -379,7 +441,7 @@ func GetStorageStatus() (*StorageStatus, error) {
 
 // Export exports a backup to a file
 func Export(backupID, destination string) error {
-	archiveFile := filepath.Join(backupArchiveDir, backupID+".tar.gz")
+	archiveFile := archivePath(backupID)
 	if _, err := os.Stat(archiveFile); os.IsNotExist(err) {
 		return fmt.Errorf("backup not found: %s", backupID)
 	}
@@ -407,8 +469,13 @@ func Import(source string) (string, error) {
 		return "", fmt.Errorf("source file not found: %s", source)
 	}
 
+	// Encrypted archives keep their extension so restore knows to decrypt them
 	backupID := generateBackupID()
-	archiveFile := filepath.Join(backupArchiveDir, backupID+".tar.gz")
+	extension := ".tar.gz"
+	if method := archiveEncryption(source); method != encryptionNone {
+		extension += "." + method
+	}
+	archiveFile := filepath.Join(backupArchiveDir, backupID+extension)
 
 	// Copy the archive file
 	sourceFile, err := os.Open(source)
@@ -448,21 +515,7 @@ func GetTotalSize(backups []Backup) int64 {
 
 // GetBackupPath returns the path where a backup is stored
 func GetBackupPath(backupID string) string {
-	return filepath.Join(backupArchiveDir, backupID+".tar.gz")
-}
-
-// FormatBytes formats bytes to human-readable size
-func FormatBytes(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-	return fmt.Sprintf("%.2f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+	return archivePath(backupID)
 }
 
 // Helper functions
@@ -535,18 +588,11 @@ func saveBackupMetadata(backup Backup) error {
 }
 
 func getDomainsList() ([]string, error) {
-	data, err := ioutil.ReadFile(domainsFile)
+	domains, err := domain.All()
 	if err != nil {
 		return nil, err
 	}
 
-	var domains []struct {
-		Name string `json:"name"`
-	}
-	if err := json.Unmarshal(data, &domains); err != nil {
-		return nil, err
-	}
-
 	var names []string
 	for _, d := range domains {
 		names = append(names, d.Name)
@@ -556,7 +602,14 @@ func getDomainsList() ([]string, error) {
 
 func getIncludedDatabases() map[string][]string {
 	databases := make(map[string][]string)
-	// TODO: Implement actual database listing
+	// TODO: List the MySQL and PostgreSQL databases
+	if domains, err := domain.All(); err == nil {
+		for _, d := range domains {
+			for _, name := range d.SQLite {
+				databases["sqlite"] = append(databases["sqlite"], d.Name+"/"+name)
+			}
+		}
+	}
 	return databases
 }
 
@@ -6,6 +6,9 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/postgres"
+	"webstack-cli/internal/ui"
 )
 
 // dumpMySQLDatabase creates a SQL dump of a MySQL database
@@ -38,11 +41,12 @@ func dumpMySQLDatabase(dbName, outputDir string) (int64, error) {
 	return info.Size(), nil
 }
 
-// dumpPostgreSQLDatabase creates a SQL dump of a PostgreSQL database
-func dumpPostgreSQLDatabase(dbName, outputDir string) (int64, error) {
+// dumpPostgreSQLDatabase creates a SQL dump of a database of a PostgreSQL
+// cluster
+func dumpPostgreSQLDatabase(cluster postgres.Cluster, dbName, outputDir string) (int64, error) {
 	outputFile := filepath.Join(outputDir, dbName+".sql")
 
-	cmd := exec.Command("sudo", "-u", "postgres", "pg_dump", dbName)
+	cmd := cluster.Command("pg_dump", dbName)
 
 	output, err := os.Create(outputFile)
 	if err != nil {
@@ -89,10 +93,11 @@ func restoreMySQLDatabase(dbName, sqlFile string) error {
 	return nil
 }
 
-// restorePostgreSQLDatabase restores a PostgreSQL database from SQL dump
-func restorePostgreSQLDatabase(dbName, sqlFile string) error {
+// restorePostgreSQLDatabase restores a database of a PostgreSQL cluster from
+// SQL dump
+func restorePostgreSQLDatabase(cluster postgres.Cluster, dbName, sqlFile string) error {
 	// Create database if not exists
-	createCmd := exec.Command("sudo", "-u", "postgres", "createdb", "-i", dbName)
+	createCmd := cluster.Command("createdb", dbName)
 	createCmd.Run() // Ignore error if database exists
 
 	// Read SQL file and execute
@@ -101,7 +106,7 @@ func restorePostgreSQLDatabase(dbName, sqlFile string) error {
 		return fmt.Errorf("failed to read SQL file: %w", err)
 	}
 
-	restoreCmd := exec.Command("sudo", "-u", "postgres", "psql", dbName)
+	restoreCmd := cluster.Command("psql", dbName)
 	restoreCmd.Stdin = strings.NewReader(string(sqlData))
 
 	if err := restoreCmd.Run(); err != nil {
@@ -111,6 +116,48 @@ func restorePostgreSQLDatabase(dbName, sqlFile string) error {
 	return nil
 }
 
+// backupSQLiteDatabases snapshots the SQLite databases of a domain into
+// <outputDir>/sqlite/<domain>. The files are in the domain folder too, but a
+// copy taken while the site writes to them may be torn.
+func backupSQLiteDatabases(domainName, outputDir string) int64 {
+	d, err := domain.GetDomain(domainName)
+	if err != nil {
+		return 0
+	}
+
+	var total int64
+	for _, name := range d.SQLite {
+		dest := filepath.Join(outputDir, "sqlite", d.Name, name+".sqlite")
+		if err := domain.SnapshotSQLite(*d, name, dest); err != nil {
+			ui.Warn("⚠️  Warning: Could not back up SQLite database %s of %s: %v\n", name, d.Name, err)
+			continue
+		}
+		if info, err := os.Stat(dest); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// restoreSQLiteDatabases puts the snapshots of a domain's SQLite databases
+// back over the files restored from its folder
+func restoreSQLiteDatabases(domainName, snapshotDir string) {
+	d, err := domain.GetDomain(domainName)
+	if err != nil {
+		return
+	}
+
+	for _, name := range d.SQLite {
+		snapshot := filepath.Join(snapshotDir, name+".sqlite")
+		if _, err := os.Stat(snapshot); err != nil {
+			continue
+		}
+		if err := domain.RestoreSQLite(*d, name, snapshot); err != nil {
+			ui.Warn("⚠️  Could not restore SQLite database %s of %s: %v\n", name, d.Name, err)
+		}
+	}
+}
+
 // backupMySQLDatabases backs up all MySQL databases
 func backupMySQLDatabases(outputDir string) (int64, error) {
 	// Create MySQL subdirectory
@@ -135,7 +182,7 @@ func backupMySQLDatabases(outputDir string) (int64, error) {
 
 		size, err := dumpMySQLDatabase(dbName, mysqlDir)
 		if err != nil {
-			fmt.Printf("⚠️  Could not backup MySQL database %s: %v\n", dbName, err)
+			ui.Warn("⚠️  Could not backup MySQL database %s: %v\n", dbName, err)
 			continue
 		}
 		totalSize += size
@@ -144,33 +191,42 @@ func backupMySQLDatabases(outputDir string) (int64, error) {
 	return totalSize, nil
 }
 
-// backupPostgreSQLDatabases backs up all PostgreSQL databases
+// backupPostgreSQLDatabases backs up all databases of all running
+// PostgreSQL clusters, each cluster into its own subdirectory
 func backupPostgreSQLDatabases(outputDir string) (int64, error) {
-	// Create PostgreSQL subdirectory
-	postgresDir := filepath.Join(outputDir, "postgresql")
-	os.MkdirAll(postgresDir, 0755)
-
-	// Get list of databases
-	listCmd := exec.Command("sudo", "-u", "postgres", "psql", "-lqt")
-	output, err := listCmd.Output()
+	clusters, err := postgres.Clusters()
 	if err != nil {
-		return 0, fmt.Errorf("failed to list databases: %w", err)
+		return 0, err
 	}
 
 	var totalSize int64
-	lines := strings.Split(string(output), "\n")
+	for _, cluster := range clusters {
+		if !cluster.Online() {
+			ui.Warn("⚠️  Skipping PostgreSQL cluster %s (%s)\n", cluster.ID(), cluster.Status)
+			continue
+		}
 
-	for _, line := range lines {
-		parts := strings.Split(line, "|")
-		if len(parts) > 0 {
+		// Create PostgreSQL subdirectory
+		postgresDir := filepath.Join(outputDir, postgresDBType(cluster))
+		os.MkdirAll(postgresDir, 0755)
+
+		// Get list of databases
+		output, err := cluster.Command("psql", "-lqt").Output()
+		if err != nil {
+			ui.Warn("⚠️  Could not list the databases of PostgreSQL cluster %s: %v\n", cluster.ID(), err)
+			continue
+		}
+
+		for _, line := range strings.Split(string(output), "\n") {
+			parts := strings.Split(line, "|")
 			dbName := strings.TrimSpace(parts[0])
 			if dbName == "" || strings.HasPrefix(dbName, "template") || strings.HasPrefix(dbName, "postgres") {
 				continue
 			}
 
-			size, err := dumpPostgreSQLDatabase(dbName, postgresDir)
+			size, err := dumpPostgreSQLDatabase(cluster, dbName, postgresDir)
 			if err != nil {
-				fmt.Printf("⚠️  Could not backup PostgreSQL database %s: %v\n", dbName, err)
+				ui.Warn("⚠️  Could not backup PostgreSQL database %s of cluster %s: %v\n", dbName, cluster.ID(), err)
 				continue
 			}
 			totalSize += size
@@ -180,6 +236,27 @@ func backupPostgreSQLDatabases(outputDir string) (int64, error) {
 	return totalSize, nil
 }
 
+// postgresDBType returns the database type under which the dumps of a
+// cluster are stored: "postgresql" for the cluster on port 5432, and
+// "postgresql@16-main" for the others
+func postgresDBType(cluster postgres.Cluster) string {
+	if cluster.Port == postgres.DefaultPort {
+		return "postgresql"
+	}
+	return "postgresql@" + cluster.Key()
+}
+
+// postgresCluster returns the cluster of a database type written by
+// postgresDBType
+func postgresCluster(dbType string) (postgres.Cluster, error) {
+	return postgres.Find(strings.TrimPrefix(strings.TrimPrefix(dbType, "postgresql"), "@"))
+}
+
+// isPostgresDBType reports whether a database type is one of PostgreSQL
+func isPostgresDBType(dbType string) bool {
+	return dbType == "postgresql" || strings.HasPrefix(dbType, "postgresql@")
+}
+
 // listMySQLDatabases returns list of MySQL databases
 func listMySQLDatabases() ([]string, error) {
 	listCmd := exec.Command("mysql", "-u", "root", "-se", "SHOW DATABASES;")
@@ -203,7 +280,11 @@ func listMySQLDatabases() ([]string, error) {
 
 // listPostgreSQLDatabases returns list of PostgreSQL databases
 func listPostgreSQLDatabases() ([]string, error) {
-	listCmd := exec.Command("sudo", "-u", "postgres", "psql", "-lqt")
+	cluster, err := postgres.Find("")
+	if err != nil {
+		return nil, err
+	}
+	listCmd := cluster.Command("psql", "-lqt")
 	output, err := listCmd.Output()
 	if err != nil {
 		return nil, err
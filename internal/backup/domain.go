@@ -0,0 +1,572 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/postgres"
+	"webstack-cli/internal/store"
+	"webstack-cli/internal/ui"
+)
+
+// DomainManifest describes the contents of a domain export archive
+type DomainManifest struct {
+	Version   string          `json:"version"`
+	CreatedAt time.Time       `json:"created_at"`
+	Hostname  string          `json:"hostname"`
+	Domain    domain.Domain   `json:"domain"`
+	SSL       json.RawMessage `json:"ssl,omitempty"`
+	Databases []string        `json:"databases,omitempty"` // "mysql:name" or "postgresql:name"
+}
+
+// DomainExportOptions controls what ExportDomain includes
+type DomainExportOptions struct {
+	Output      string   // Archive path (default: /var/backups/webstack/domains/<domain>-<timestamp>.tar.gz)
+	Databases   []string // Databases to include in addition to auto-detected ones
+	NoDatabases bool     // Skip database dumps entirely
+}
+
+// DomainRestoreOptions controls how ImportDomain re-creates a domain
+type DomainRestoreOptions struct {
+	Force         bool // Overwrite an existing domain with the same name
+	SkipDatabases bool // Do not import database dumps
+}
+
+const domainManifestVersion = "1"
+const domainExportDir = backupDir + "/domains"
+
+var (
+	wpDBNamePattern  = regexp.MustCompile(`define\(\s*['"]DB_NAME['"]\s*,\s*['"]([^'"]+)['"]`)
+	envDBNamePattern = regexp.MustCompile(`(?m)^DB_DATABASE\s*=\s*"?([^"\s]+)"?`)
+	envDBConnPattern = regexp.MustCompile(`(?m)^DB_CONNECTION\s*=\s*"?([^"\s]+)"?`)
+)
+
+// ExportDomain writes a single tarball containing the domain files, the
+// domain entry, web server configs, SSL certificates and databases.
+// It returns the path of the created archive.
+func ExportDomain(name string, opts DomainExportOptions) (string, error) {
+	d, err := domain.GetDomain(name)
+	if err != nil {
+		return "", err
+	}
+	name = d.Name
+
+	stagingPath := filepath.Join(os.TempDir(), fmt.Sprintf("webstack-domain-%s-%d", name, time.Now().Unix()))
+	if err := os.MkdirAll(stagingPath, 0755); err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(stagingPath)
+
+	hostname, _ := os.Hostname()
+	manifest := DomainManifest{
+		Version:   domainManifestVersion,
+		CreatedAt: time.Now(),
+		Hostname:  hostname,
+		Domain:    *d,
+	}
+
+	// Domain files (htdocs, logs, configs, error)
+	fmt.Printf("📦 Archiving files: %s\n", d.HomeDir())
+	if _, err := backupDirectory(d.HomeDir(), stagingPath, "files"); err != nil {
+		return "", fmt.Errorf("failed to archive domain files: %w", err)
+	}
+	if len(d.Protect) > 0 {
+		if _, err := backupDirectory(domain.AuthDir(name), stagingPath, "htpasswd"); err != nil {
+			ui.Warn("⚠️  Warning: Could not archive basic auth users: %v\n", err)
+		}
+	}
+	if d.CustomRoot() {
+		fmt.Printf("📦 Archiving document root: %s\n", d.DocumentRoot)
+		if _, err := backupDirectory(d.DocumentRoot, stagingPath, "root"); err != nil {
+			return "", fmt.Errorf("failed to archive document root: %w", err)
+		}
+	}
+
+	// Web server configs (kept for reference, configs are regenerated on restore)
+	configsDir := filepath.Join(stagingPath, "configs")
+	os.MkdirAll(configsDir, 0755)
+	for server, path := range map[string]string{
+		"nginx":  filepath.Join("/etc/nginx/sites-available", name+".conf"),
+		"apache": filepath.Join("/etc/apache2/sites-available", name+".conf"),
+	} {
+		if _, err := os.Stat(path); err == nil {
+			copyFile(path, filepath.Join(configsDir, server+".conf"))
+		}
+	}
+
+	// SSL certificates
+	if d.SSLEnabled {
+		fmt.Println("🔒 Archiving SSL certificates...")
+		if err := exportDomainSSL(name, filepath.Join(stagingPath, "ssl")); err != nil {
+			ui.Warn("⚠️  Warning: Could not archive SSL certificates: %v\n", err)
+		}
+		manifest.SSL = loadSSLEntry(name)
+	}
+
+	// Databases
+	if !opts.NoDatabases {
+		if len(d.SQLite) > 0 {
+			fmt.Printf("🗄️  Copying SQLite databases: %s\n", strings.Join(d.SQLite, ", "))
+			backupSQLiteDatabases(name, filepath.Join(stagingPath, "databases"))
+		}
+
+		databases := detectDomainDatabases(*d)
+		for _, db := range opts.Databases {
+			if !containsString(databases, db) {
+				databases = append(databases, db)
+			}
+		}
+
+		for _, db := range databases {
+			dbType, dbName, err := parseDatabaseRef(db)
+			if err != nil {
+				return "", err
+			}
+
+			var cluster postgres.Cluster
+			if dbType != "mysql" {
+				if cluster, err = postgresCluster(dbType); err != nil {
+					return "", fmt.Errorf("failed to dump database %s: %w", db, err)
+				}
+				dbType = postgresDBType(cluster)
+			}
+
+			fmt.Printf("🗄️  Dumping %s database: %s\n", dbType, dbName)
+			dbDir := filepath.Join(stagingPath, "databases", dbType)
+			os.MkdirAll(dbDir, 0755)
+
+			if dbType == "mysql" {
+				_, err = dumpMySQLDatabase(dbName, dbDir)
+			} else {
+				_, err = dumpPostgreSQLDatabase(cluster, dbName, dbDir)
+			}
+			if err != nil {
+				return "", fmt.Errorf("failed to dump database %s: %w", db, err)
+			}
+			manifest.Databases = append(manifest.Databases, dbType+":"+dbName)
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(stagingPath, "manifest.json"), data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	output := opts.Output
+	if output == "" {
+		os.MkdirAll(domainExportDir, 0755)
+		output = filepath.Join(domainExportDir, fmt.Sprintf("%s-%s.tar.gz", name, time.Now().Format("20060102-150405")))
+	}
+
+	if err := createTarGz(stagingPath, output); err != nil {
+		return "", fmt.Errorf("failed to create archive: %w", err)
+	}
+	os.Chmod(output, 0600)
+
+	return output, nil
+}
+
+// ImportDomain re-creates a domain from an archive created by ExportDomain
+// and returns the restored domain name
+func ImportDomain(file string, opts DomainRestoreOptions) (string, error) {
+	if _, err := os.Stat(file); err != nil {
+		return "", fmt.Errorf("archive not found: %s", file)
+	}
+	// The domain is re-created from the extracted archive, which a dry run
+	// doesn't extract
+	if dryrun.Enabled() {
+		return "", fmt.Errorf("restoring a domain can't be previewed with --dry-run")
+	}
+
+	stagingPath := filepath.Join(os.TempDir(), fmt.Sprintf("webstack-domain-restore-%d", time.Now().Unix()))
+	if err := os.MkdirAll(stagingPath, 0755); err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(stagingPath)
+
+	fmt.Printf("📥 Extracting %s...\n", file)
+	if err := extractTarGz(file, stagingPath); err != nil {
+		return "", fmt.Errorf("failed to extract archive: %w", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(stagingPath, "manifest.json"))
+	if err != nil {
+		return "", fmt.Errorf("not a domain archive (manifest.json missing)")
+	}
+
+	var manifest DomainManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return "", fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	if manifest.Domain.Name == "" {
+		return "", fmt.Errorf("manifest does not contain a domain entry")
+	}
+	// The name becomes paths under the web root, checked like 'domain add'
+	name := domain.Normalize(manifest.Domain.Name)
+	if !domain.ValidName(name) {
+		return "", fmt.Errorf("invalid domain name in manifest: %q", manifest.Domain.Name)
+	}
+	manifest.Domain.Name = name
+
+	if domain.DomainExists(name) && !opts.Force {
+		return "", fmt.Errorf("domain %s already exists (use --force to overwrite)", name)
+	}
+
+	fmt.Printf("🔄 Restoring domain %s (exported from %s on %s)\n",
+		name, manifest.Hostname, manifest.CreatedAt.Format("2006-01-02 15:04"))
+
+	// Domain files go to the web root of this server, which may differ from
+	// the one the domain was exported from
+	webRoot := config.FallbackWebRoot
+	if cfg, err := config.Load(); err == nil {
+		webRoot = cfg.WebRoot()
+	}
+	baseDir := filepath.Join(webRoot, name)
+	customRoot := manifest.Domain.CustomRoot()
+	manifest.Domain.Home = baseDir
+	if !customRoot {
+		manifest.Domain.DocumentRoot = filepath.Join(baseDir, "htdocs", manifest.Domain.DocRoot)
+	}
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", baseDir, err)
+	}
+	if err := extractTarGz(filepath.Join(stagingPath, "files.tar.gz"), baseDir); err != nil {
+		return "", fmt.Errorf("failed to restore domain files: %w", err)
+	}
+	exec.Command("chown", "-R", "www-data:www-data", baseDir).Run()
+	fmt.Printf("✓ Files restored to %s\n", baseDir)
+
+	// A document root given with --root keeps its path and owner
+	root := filepath.Join(stagingPath, "root.tar.gz")
+	if _, err := os.Stat(root); customRoot && err == nil {
+		if err := os.MkdirAll(manifest.Domain.DocumentRoot, 0755); err != nil {
+			return "", fmt.Errorf("failed to create %s: %w", manifest.Domain.DocumentRoot, err)
+		}
+		if err := extractTarGz(root, manifest.Domain.DocumentRoot); err != nil {
+			return "", fmt.Errorf("failed to restore document root: %w", err)
+		}
+		fmt.Printf("✓ Document root restored to %s\n", manifest.Domain.DocumentRoot)
+	}
+
+	// Basic auth users, without which the protected paths would be closed
+	if htpasswd := filepath.Join(stagingPath, "htpasswd.tar.gz"); len(manifest.Domain.Protect) > 0 {
+		os.MkdirAll(domain.AuthDir(name), 0750)
+		if err := extractTarGz(htpasswd, domain.AuthDir(name)); err != nil {
+			ui.Warn("⚠️  Warning: Could not restore basic auth users, protection removed: %v\n", err)
+			manifest.Domain.Protect = nil
+		} else {
+			exec.Command("chgrp", "-R", "www-data", domain.AuthDir(name)).Run()
+		}
+	}
+
+	// SSL certificates
+	if manifest.Domain.SSLEnabled {
+		if err := importDomainSSL(name, filepath.Join(stagingPath, "ssl")); err != nil {
+			ui.Warn("⚠️  Warning: Could not restore SSL certificates, SSL disabled: %v\n", err)
+			manifest.Domain.SSLEnabled = false
+			manifest.Domain.SSLCertPath = ""
+			manifest.Domain.SSLKeyPath = ""
+		} else {
+			if len(manifest.SSL) > 0 {
+				saveSSLEntry(name, manifest.SSL)
+			}
+			fmt.Println("✓ SSL certificates restored")
+		}
+	}
+
+	// Domain entry and web server configuration (regenerated for this server)
+	if err := domain.UpdateDomain(manifest.Domain); err != nil {
+		return "", fmt.Errorf("failed to save domain entry: %w", err)
+	}
+	if err := domain.GenerateConfig(manifest.Domain); err != nil {
+		return "", fmt.Errorf("failed to generate configuration: %w", err)
+	}
+
+	// Databases
+	if !opts.SkipDatabases {
+		restoreSQLiteDatabases(name, filepath.Join(stagingPath, "databases", "sqlite", name))
+		for _, db := range manifest.Databases {
+			dbType, dbName, err := parseDatabaseRef(db)
+			if err != nil {
+				continue
+			}
+
+			sqlPath := filepath.Join(stagingPath, "databases", dbType, dbName+".sql")
+			fmt.Printf("🗄️  Importing %s database: %s\n", dbType, dbName)
+			if dbType == "mysql" {
+				err = restoreMySQLDatabase(dbName, sqlPath)
+			} else {
+				var cluster postgres.Cluster
+				if cluster, err = postgresCluster(dbType); err == nil {
+					err = restorePostgreSQLDatabase(cluster, dbName, sqlPath)
+				}
+			}
+			if err != nil {
+				ui.Warn("⚠️  Could not import database %s: %v\n", db, err)
+			}
+		}
+	}
+
+	domain.ReloadWebServers()
+
+	return name, nil
+}
+
+// domainHome returns the folder of a domain's files, for code where
+// "domain" names a variable
+func domainHome(name string) string {
+	return domain.HomeDir(name)
+}
+
+// customRoot returns the document root of a domain given with --root, which
+// is archived next to its folder, or "" when it lives in htdocs
+func customRoot(name string) string {
+	d, err := domain.GetDomain(name)
+	if err != nil || !d.CustomRoot() {
+		return ""
+	}
+	return d.DocumentRoot
+}
+
+// detectDomainDatabases looks for database names in common application
+// config files (WordPress wp-config.php, Laravel/Symfony .env)
+func detectDomainDatabases(d domain.Domain) []string {
+	var databases []string
+
+	dirs := []string{d.DocumentRoot, filepath.Dir(d.DocumentRoot), filepath.Join(d.HomeDir(), "htdocs")}
+
+	for _, dir := range dirs {
+		if data, err := ioutil.ReadFile(filepath.Join(dir, "wp-config.php")); err == nil {
+			if m := wpDBNamePattern.FindSubmatch(data); m != nil {
+				databases = appendUnique(databases, "mysql:"+string(m[1]))
+			}
+		}
+
+		if data, err := ioutil.ReadFile(filepath.Join(dir, ".env")); err == nil {
+			m := envDBNamePattern.FindSubmatch(data)
+			if m == nil {
+				continue
+			}
+			connection := ""
+			if c := envDBConnPattern.FindSubmatch(data); c != nil {
+				connection = string(c[1])
+			}
+			if strings.HasPrefix(connection, "sqlite") {
+				continue
+			}
+			dbType := "mysql"
+			if strings.HasPrefix(connection, "pgsql") {
+				dbType = "postgresql"
+			}
+			databases = appendUnique(databases, dbType+":"+string(m[1]))
+		}
+	}
+
+	return databases
+}
+
+// parseDatabaseRef splits "mysql:name" / "postgresql:name" references; a
+// PostgreSQL cluster other than the one on port 5432 is named as in
+// "postgresql@16-main:name"
+func parseDatabaseRef(ref string) (string, string, error) {
+	parts := strings.SplitN(ref, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid database reference %q (use mysql:name or postgresql:name)", ref)
+	}
+
+	dbType := strings.ToLower(parts[0])
+	cluster := ""
+	if at := strings.Index(dbType, "@"); at > 0 {
+		dbType, cluster = dbType[:at], "@"+strings.Replace(dbType[at+1:], "/", "-", 1)
+	}
+	switch dbType {
+	case "mysql", "mariadb":
+		if cluster != "" {
+			return "", "", fmt.Errorf("invalid database reference %q (clusters are PostgreSQL only)", ref)
+		}
+		dbType = "mysql"
+	case "postgresql", "postgres", "pgsql":
+		dbType = "postgresql" + cluster
+	default:
+		return "", "", fmt.Errorf("unknown database type in %q", ref)
+	}
+
+	return dbType, parts[1], nil
+}
+
+// exportDomainSSL copies the certificate files of a domain into destDir.
+// Let's Encrypt symlinks are resolved so the archive holds real files.
+func exportDomainSSL(name, destDir string) error {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+
+	liveDir := filepath.Join("/etc/letsencrypt/live", name)
+	if _, err := os.Stat(liveDir); err == nil {
+		leDir := filepath.Join(destDir, "letsencrypt")
+		os.MkdirAll(leDir, 0700)
+		for _, f := range []string{"cert.pem", "chain.pem", "fullchain.pem", "privkey.pem"} {
+			if err := copyFile(filepath.Join(liveDir, f), filepath.Join(leDir, f)); err != nil {
+				return fmt.Errorf("failed to copy %s: %w", f, err)
+			}
+		}
+		renewal := filepath.Join("/etc/letsencrypt/renewal", name+".conf")
+		if _, err := os.Stat(renewal); err == nil {
+			copyFile(renewal, filepath.Join(leDir, "renewal.conf"))
+		}
+		return nil
+	}
+
+	importedDir := filepath.Join("/etc/webstack/ssl", name)
+	if _, err := os.Stat(importedDir); err == nil {
+		dir := filepath.Join(destDir, "imported")
+		os.MkdirAll(dir, 0700)
+		for _, f := range []string{"fullchain.pem", "privkey.pem"} {
+			if err := copyFile(filepath.Join(importedDir, f), filepath.Join(dir, f)); err != nil {
+				return fmt.Errorf("failed to copy %s: %w", f, err)
+			}
+		}
+		return nil
+	}
+
+	cert := filepath.Join("/etc/ssl/webstack", name+".crt")
+	if _, err := os.Stat(cert); err == nil {
+		if err := copyFile(cert, filepath.Join(destDir, name+".crt")); err != nil {
+			return err
+		}
+		return copyFile(filepath.Join("/etc/ssl/webstack", name+".key"), filepath.Join(destDir, name+".key"))
+	}
+
+	return fmt.Errorf("no certificate files found for %s", name)
+}
+
+// importDomainSSL puts archived certificate files back in place.
+// Let's Encrypt certificates are restored in certbot's archive/live
+// layout so renewals keep working on the new server.
+func importDomainSSL(name, srcDir string) error {
+	leDir := filepath.Join(srcDir, "letsencrypt")
+	if _, err := os.Stat(leDir); err == nil {
+		archiveDir := filepath.Join("/etc/letsencrypt/archive", name)
+		liveDir := filepath.Join("/etc/letsencrypt/live", name)
+		os.MkdirAll(archiveDir, 0700)
+		os.MkdirAll(liveDir, 0755)
+
+		for _, f := range []string{"cert", "chain", "fullchain", "privkey"} {
+			archived := filepath.Join(archiveDir, f+"1.pem")
+			if err := copyFile(filepath.Join(leDir, f+".pem"), archived); err != nil {
+				return fmt.Errorf("failed to restore %s.pem: %w", f, err)
+			}
+			link := filepath.Join(liveDir, f+".pem")
+			os.Remove(link)
+			if err := os.Symlink(filepath.Join("../../archive", name, f+"1.pem"), link); err != nil {
+				return err
+			}
+		}
+		os.Chmod(filepath.Join(archiveDir, "privkey1.pem"), 0600)
+
+		if _, err := os.Stat(filepath.Join(leDir, "renewal.conf")); err == nil {
+			copyFile(filepath.Join(leDir, "renewal.conf"), filepath.Join("/etc/letsencrypt/renewal", name+".conf"))
+		}
+		return nil
+	}
+
+	importedDir := filepath.Join(srcDir, "imported")
+	if _, err := os.Stat(importedDir); err == nil {
+		destDir := filepath.Join("/etc/webstack/ssl", name)
+		os.MkdirAll(destDir, 0700)
+		for _, f := range []string{"fullchain.pem", "privkey.pem"} {
+			if err := copyFile(filepath.Join(importedDir, f), filepath.Join(destDir, f)); err != nil {
+				return fmt.Errorf("failed to restore %s: %w", f, err)
+			}
+		}
+		os.Chmod(filepath.Join(destDir, "privkey.pem"), 0600)
+		return nil
+	}
+
+	cert := filepath.Join(srcDir, name+".crt")
+	if _, err := os.Stat(cert); err == nil {
+		os.MkdirAll("/etc/ssl/webstack", 0755)
+		if err := copyFile(cert, filepath.Join("/etc/ssl/webstack", name+".crt")); err != nil {
+			return err
+		}
+		keyPath := filepath.Join("/etc/ssl/webstack", name+".key")
+		if err := copyFile(filepath.Join(srcDir, name+".key"), keyPath); err != nil {
+			return err
+		}
+		os.Chmod(keyPath, 0600)
+		return nil
+	}
+
+	return fmt.Errorf("archive contains no certificate files")
+}
+
+// sslStore reads and writes ssl.json with the locking and schema of the ssl
+// package, keeping the entries raw so fields this package does not know
+// survive a restore
+var sslStore = store.New(sslFile, 1)
+
+// loadSSLEntry returns the raw ssl.json entry for a domain
+func loadSSLEntry(name string) json.RawMessage {
+	var entries []json.RawMessage
+	if err := sslStore.Load(&entries); err != nil {
+		return nil
+	}
+
+	for _, entry := range entries {
+		var cert struct {
+			Domain string `json:"domain"`
+		}
+		if json.Unmarshal(entry, &cert) == nil && cert.Domain == name {
+			return entry
+		}
+	}
+	return nil
+}
+
+// saveSSLEntry adds or replaces the ssl.json entry for a domain
+func saveSSLEntry(name string, entry json.RawMessage) error {
+	var entries []json.RawMessage
+	return sslStore.Update(&entries, func() error {
+		for i, existing := range entries {
+			var cert struct {
+				Domain string `json:"domain"`
+			}
+			if json.Unmarshal(existing, &cert) == nil && cert.Domain == name {
+				entries[i] = entry
+				return nil
+			}
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+}
+
+func appendUnique(list []string, value string) []string {
+	if containsString(list, value) {
+		return list
+	}
+	return append(list, value)
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
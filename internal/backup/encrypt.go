@@ -0,0 +1,225 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// Encryption methods recorded in the backup metadata
+const (
+	encryptionNone = "none"
+	encryptionAge  = "age"
+	encryptionGPG  = "gpg"
+)
+
+// DefaultAgeIdentity is the age identity used to decrypt backups on restore
+// when no --identity is given
+const DefaultAgeIdentity = "/etc/webstack/backup/age.key"
+
+// Archive file extension per encryption method, in lookup order
+var archiveExtensions = []struct {
+	method string
+	ext    string
+}{
+	{encryptionNone, ".tar.gz"},
+	{encryptionAge, ".tar.gz.age"},
+	{encryptionGPG, ".tar.gz.gpg"},
+}
+
+// ParseEncryption splits an --encrypt value into the encryption method and
+// its recipients. Comma-separated age recipients (age1...) select age; any
+// other value is a GPG key ID, fingerprint or email, optionally prefixed with
+// "gpg:". "none" or an empty value disables encryption.
+func ParseEncryption(value string) (string, []string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == encryptionNone {
+		return encryptionNone, nil, nil
+	}
+
+	var recipients []string
+	for _, r := range strings.Split(value, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			recipients = append(recipients, r)
+		}
+	}
+	if len(recipients) == 0 {
+		return "", nil, fmt.Errorf("no recipients given in %q", value)
+	}
+
+	if strings.HasPrefix(recipients[0], "age1") {
+		for _, r := range recipients {
+			if _, err := age.ParseX25519Recipient(r); err != nil {
+				return "", nil, fmt.Errorf("invalid age recipient %q: %v", r, err)
+			}
+		}
+		return encryptionAge, recipients, nil
+	}
+
+	for i, r := range recipients {
+		recipients[i] = strings.TrimPrefix(r, "gpg:")
+		if recipients[i] == "" {
+			return "", nil, fmt.Errorf("gpg: needs a key ID, fingerprint or email")
+		}
+		if strings.HasPrefix(recipients[i], "age1") {
+			return "", nil, fmt.Errorf("cannot mix age and GPG recipients")
+		}
+	}
+	if _, err := exec.LookPath("gpg"); err != nil {
+		return "", nil, fmt.Errorf("gpg is not installed (apt install gnupg)")
+	}
+	for _, r := range recipients {
+		if err := exec.Command("gpg", "--batch", "--list-keys", r).Run(); err != nil {
+			return "", nil, fmt.Errorf("no GPG public key for %q in root's keyring (import it with: gpg --import key.asc)", r)
+		}
+	}
+	return encryptionGPG, recipients, nil
+}
+
+// archivePath returns the stored archive of a backup, whichever encryption it uses
+func archivePath(backupID string) string {
+	for _, a := range archiveExtensions {
+		path := filepath.Join(backupArchiveDir, backupID+a.ext)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(backupArchiveDir, backupID+".tar.gz")
+}
+
+// archiveEncryption returns the encryption method of an archive from its extension
+func archiveEncryption(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".age"):
+		return encryptionAge
+	case strings.HasSuffix(path, ".gpg"):
+		return encryptionGPG
+	}
+	return encryptionNone
+}
+
+// encryptArchive encrypts an archive for the recipients, removes the
+// plaintext and returns the path of the encrypted archive
+func encryptArchive(path, method string, recipients []string) (string, error) {
+	if method == encryptionNone {
+		return path, nil
+	}
+
+	output := path + "." + method
+	var err error
+	if method == encryptionAge {
+		err = encryptAge(path, output, recipients)
+	} else {
+		args := []string{"--batch", "--yes", "--trust-model", "always", "--output", output, "--encrypt"}
+		for _, r := range recipients {
+			args = append(args, "--recipient", r)
+		}
+		if out, gpgErr := exec.Command("gpg", append(args, path)...).CombinedOutput(); gpgErr != nil {
+			err = fmt.Errorf("gpg: %v: %s", gpgErr, strings.TrimSpace(string(out)))
+		}
+	}
+	if err != nil {
+		os.Remove(output)
+		return "", err
+	}
+
+	if err := os.Remove(path); err != nil {
+		return "", fmt.Errorf("failed to remove unencrypted archive: %w", err)
+	}
+	return output, nil
+}
+
+func encryptAge(input, output string, recipients []string) error {
+	var parsed []age.Recipient
+	for _, r := range recipients {
+		recipient, err := age.ParseX25519Recipient(r)
+		if err != nil {
+			return err
+		}
+		parsed = append(parsed, recipient)
+	}
+
+	in, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	w, err := age.Encrypt(out, parsed...)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, in); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// decryptArchive decrypts an encrypted archive into dir and returns the path
+// of the plain tar.gz. Unencrypted archives are returned as they are. age
+// archives need the identity file, GPG archives the secret key in root's keyring.
+func decryptArchive(path, identity, dir string) (string, error) {
+	method := archiveEncryption(path)
+	if method == encryptionNone {
+		return path, nil
+	}
+
+	output := filepath.Join(dir, "archive.tar.gz")
+	if method == encryptionGPG {
+		out, err := exec.Command("gpg", "--batch", "--yes", "--output", output, "--decrypt", path).CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("gpg could not decrypt the backup (is the secret key in root's keyring?): %s", strings.TrimSpace(string(out)))
+		}
+		return output, nil
+	}
+
+	if identity == "" {
+		identity = DefaultAgeIdentity
+	}
+	keyFile, err := os.Open(identity)
+	if err != nil {
+		return "", fmt.Errorf("backup is encrypted with age but the identity %s cannot be read (use --identity): %w", identity, err)
+	}
+	defer keyFile.Close()
+
+	identities, err := age.ParseIdentities(keyFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse age identity %s: %w", identity, err)
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	r, err := age.Decrypt(in, identities...)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt backup with %s: %w", identity, err)
+	}
+
+	out, err := os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, r); err != nil {
+		return "", fmt.Errorf("failed to decrypt backup: %w", err)
+	}
+	return output, out.Close()
+}
@@ -0,0 +1,17 @@
+package backup
+
+import "testing"
+
+func TestParseEncryptionWithoutRecipients(t *testing.T) {
+	for _, value := range []string{",", " , ", ",,", "gpg:"} {
+		if _, _, err := ParseEncryption(value); err == nil {
+			t.Errorf("ParseEncryption(%q) gave no error", value)
+		}
+	}
+	for _, value := range []string{"", "none", " none "} {
+		method, recipients, err := ParseEncryption(value)
+		if err != nil || method != encryptionNone || recipients != nil {
+			t.Errorf("ParseEncryption(%q) = %q, %v, %v, want no encryption", value, method, recipients, err)
+		}
+	}
+}
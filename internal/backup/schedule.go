@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 	"webstack-cli/internal/cron"
+	"webstack-cli/internal/ui"
 )
 
 // BackupSchedule represents a backup schedule configuration
@@ -18,18 +19,37 @@ type BackupSchedule struct {
 	Type          string // "full", "incremental"
 	RetentionDays int
 	Compression   string
+	Encryption    string // --encrypt value passed to each backup
+	Target        string // --target each backup is uploaded to, pruned after RetentionDays
 }
 
 const systemdServiceFile = "/etc/systemd/system/webstack-backup.service"
 const systemdTimerFile = "/etc/systemd/system/webstack-backup.timer"
 const scheduleConfigFile = "/etc/webstack/backup-schedule.conf"
 
-// EnableSchedule enables automatic backups with systemd timer
-func EnableSchedule(time, backupType string, retentionDays int, compression string) error {
+// EnableSchedule enables automatic backups with systemd timer. With a target
+// each backup is uploaded with 'backup run', which prunes the target too.
+func EnableSchedule(time, backupType string, retentionDays int, compression, encryption, target string) error {
 	if compression == "" {
 		compression = "gzip"
 	}
 
+	// Fail now rather than on every scheduled run
+	method, _, err := ParseEncryption(encryption)
+	if err != nil {
+		return err
+	}
+	backupArgs := "create --all --compress " + compression
+	if target != "" {
+		if _, err := ParseTarget(target); err != nil {
+			return err
+		}
+		backupArgs = fmt.Sprintf("run --all --compress %s --target %s --keep %d", compression, target, retentionDays)
+	}
+	if method != encryptionNone {
+		backupArgs += " --encrypt " + encryption
+	}
+
 	// Create service file
 	serviceContent := fmt.Sprintf(`[Unit]
 Description=WebStack Automatic Backup
@@ -37,14 +57,14 @@ After=network.target
 
 [Service]
 Type=oneshot
-ExecStart=/usr/local/bin/webstack backup create --all --compress %s
+ExecStart=/usr/local/bin/webstack backup %s
 StandardOutput=journal
 StandardError=journal
 SyslogIdentifier=webstack-backup
 
 [Install]
 WantedBy=multi-user.target
-`, compression)
+`, backupArgs)
 
 	if err := ioutil.WriteFile(systemdServiceFile, []byte(serviceContent), 0644); err != nil {
 		return fmt.Errorf("failed to create service file: %w", err)
@@ -92,6 +112,8 @@ WantedBy=timers.target
 		Type:          backupType,
 		RetentionDays: retentionDays,
 		Compression:   compression,
+		Encryption:    encryption,
+		Target:        target,
 	}
 
 	if err := saveScheduleConfig(schedule); err != nil {
@@ -100,7 +122,7 @@ WantedBy=timers.target
 
 	// Setup cleanup cron job
 	if err := setupCleanupCron(retentionDays); err != nil {
-		fmt.Printf("⚠️  Warning: Could not setup cleanup cron: %v\n", err)
+		ui.Warn("⚠️  Warning: Could not setup cleanup cron: %v\n", err)
 	}
 
 	return nil
@@ -241,7 +263,9 @@ time=%s
 type=%s
 retention_days=%d
 compression=%s
-`, schedule.Enabled, schedule.Frequency, schedule.Time, schedule.Type, schedule.RetentionDays, schedule.Compression)
+encryption=%s
+target=%s
+`, schedule.Enabled, schedule.Frequency, schedule.Time, schedule.Type, schedule.RetentionDays, schedule.Compression, schedule.Encryption, schedule.Target)
 
 	return ioutil.WriteFile(scheduleConfigFile, []byte(content), 0644)
 }
@@ -283,6 +307,10 @@ func loadScheduleConfig() (*BackupSchedule, error) {
 			fmt.Sscanf(value, "%d", &schedule.RetentionDays)
 		case "compression":
 			schedule.Compression = value
+		case "encryption":
+			schedule.Encryption = value
+		case "target":
+			schedule.Target = value
 		}
 	}
 
@@ -315,7 +343,7 @@ func CleanupOldBackups(retentionDays int) (int, error) {
 			backupID := strings.TrimSuffix(entry.Name(), ".json")
 
 			if err := Delete(backupID); err != nil {
-				fmt.Printf("⚠️  Could not delete old backup %s: %v\n", backupID, err)
+				ui.Warn("⚠️  Could not delete old backup %s: %v\n", backupID, err)
 				continue
 			}
 
@@ -0,0 +1,430 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"webstack-cli/internal/notify"
+	"webstack-cli/internal/ui"
+)
+
+// Target is an off-box location backups are uploaded to and restored from.
+// Each backup is stored as its archive plus <id>.json metadata, uploaded
+// after the archive so a listed backup is always complete.
+type Target interface {
+	Upload(localPath, name string) error
+	Download(name, localPath string) error
+	List() ([]string, error) // File names in the target
+	Delete(name string) error
+	String() string
+}
+
+var rcloneRemotePattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]+:`)
+
+// ParseTarget returns the target of a --target value: s3://bucket/path (aws
+// CLI), sftp://user@host[:port]/path (ssh key of root) or an rclone remote
+// (name:path)
+func ParseTarget(spec string) (Target, error) {
+	spec = strings.TrimSpace(spec)
+	switch {
+	case strings.HasPrefix(spec, "s3://"):
+		bucket := strings.TrimPrefix(strings.TrimRight(spec, "/"), "s3://")
+		if bucket == "" {
+			return nil, fmt.Errorf("invalid target %q: missing bucket", spec)
+		}
+		if _, err := exec.LookPath("aws"); err != nil {
+			return nil, fmt.Errorf("the aws CLI is not installed (apt install awscli)")
+		}
+		return &s3Target{url: "s3://" + bucket}, nil
+
+	case strings.HasPrefix(spec, "sftp://"):
+		u, err := url.Parse(spec)
+		if err != nil || u.Hostname() == "" {
+			return nil, fmt.Errorf("invalid target %q: use sftp://user@host[:port]/path", spec)
+		}
+		if _, err := exec.LookPath("sftp"); err != nil {
+			return nil, fmt.Errorf("sftp is not installed (apt install openssh-client)")
+		}
+		dir := strings.TrimRight(u.Path, "/")
+		// sftp://host/~/backups is relative to the login directory
+		dir = strings.TrimPrefix(dir, "/~/")
+		if dir == "" || dir == "/~" {
+			dir = "."
+		}
+		host := u.Hostname()
+		if u.User != nil {
+			host = u.User.Username() + "@" + host
+		}
+		return &sftpTarget{host: host, port: u.Port(), dir: dir}, nil
+
+	case rcloneRemotePattern.MatchString(spec):
+		if _, err := exec.LookPath("rclone"); err != nil {
+			return nil, fmt.Errorf("rclone is not installed (apt install rclone, then 'rclone config')")
+		}
+		return &rcloneTarget{remote: strings.TrimRight(spec, "/")}, nil
+	}
+	return nil, fmt.Errorf("invalid target %q: use s3://bucket/path, sftp://user@host/path or an rclone remote (name:path)", spec)
+}
+
+// s3Target stores backups in an S3 bucket with the aws CLI, which reads the
+// credentials and AWS_ENDPOINT_URL (S3-compatible storage) from its usual places
+type s3Target struct {
+	url string
+}
+
+func (t *s3Target) String() string { return t.url }
+
+func (t *s3Target) Upload(localPath, name string) error {
+	return runTargetCommand("aws", "s3", "cp", "--only-show-errors", localPath, t.url+"/"+name)
+}
+
+func (t *s3Target) Download(name, localPath string) error {
+	return runTargetCommand("aws", "s3", "cp", "--only-show-errors", t.url+"/"+name, localPath)
+}
+
+func (t *s3Target) List() ([]string, error) {
+	output, err := exec.Command("aws", "s3", "ls", t.url+"/").CombinedOutput()
+	if err != nil {
+		// An empty prefix is not an error for the caller
+		if strings.TrimSpace(string(output)) == "" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("aws s3 ls: %s", strings.TrimSpace(string(output)))
+	}
+	var names []string
+	for _, line := range strings.Split(string(output), "\n") {
+		// 2025-01-31 02:00:12    1048576 backup-1738288812.tar.gz
+		fields := strings.Fields(line)
+		if len(fields) == 4 && fields[0] != "PRE" {
+			names = append(names, fields[3])
+		}
+	}
+	return names, nil
+}
+
+func (t *s3Target) Delete(name string) error {
+	return runTargetCommand("aws", "s3", "rm", "--only-show-errors", t.url+"/"+name)
+}
+
+// sftpTarget stores backups on an SSH server with sftp batch mode, so root
+// needs a key the server accepts
+type sftpTarget struct {
+	host string // [user@]host
+	port string
+	dir  string
+}
+
+func (t *sftpTarget) String() string {
+	if t.port != "" {
+		return fmt.Sprintf("sftp://%s:%s/%s", t.host, t.port, strings.TrimPrefix(t.dir, "/"))
+	}
+	return fmt.Sprintf("sftp://%s/%s", t.host, strings.TrimPrefix(t.dir, "/"))
+}
+
+// batch runs sftp commands; a leading "-" lets a command fail
+func (t *sftpTarget) batch(commands ...string) (string, error) {
+	args := []string{"-b", "-", "-o", "BatchMode=yes"}
+	if t.port != "" {
+		args = append(args, "-P", t.port)
+	}
+	cmd := exec.Command("sftp", append(args, t.host)...)
+	cmd.Stdin = strings.NewReader(strings.Join(commands, "\n") + "\n")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("sftp %s: %s", t.host, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+func (t *sftpTarget) Upload(localPath, name string) error {
+	_, err := t.batch(fmt.Sprintf("-mkdir %q", t.dir), fmt.Sprintf("put %q %q", localPath, path.Join(t.dir, name)))
+	return err
+}
+
+func (t *sftpTarget) Download(name, localPath string) error {
+	_, err := t.batch(fmt.Sprintf("get %q %q", path.Join(t.dir, name), localPath))
+	return err
+}
+
+func (t *sftpTarget) List() ([]string, error) {
+	output, err := t.batch(fmt.Sprintf("-ls -1 %q", t.dir))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "sftp>") || strings.Contains(line, "not found") {
+			continue
+		}
+		names = append(names, path.Base(line))
+	}
+	return names, nil
+}
+
+func (t *sftpTarget) Delete(name string) error {
+	_, err := t.batch(fmt.Sprintf("rm %q", path.Join(t.dir, name)))
+	return err
+}
+
+// rcloneTarget stores backups on any rclone remote configured in root's
+// rclone.conf (Backblaze B2, Google Drive, WebDAV, ...)
+type rcloneTarget struct {
+	remote string
+}
+
+func (t *rcloneTarget) String() string { return t.remote }
+
+func (t *rcloneTarget) join(name string) string {
+	if strings.HasSuffix(t.remote, ":") {
+		return t.remote + name
+	}
+	return t.remote + "/" + name
+}
+
+func (t *rcloneTarget) Upload(localPath, name string) error {
+	return runTargetCommand("rclone", "copyto", localPath, t.join(name))
+}
+
+func (t *rcloneTarget) Download(name, localPath string) error {
+	return runTargetCommand("rclone", "copyto", t.join(name), localPath)
+}
+
+func (t *rcloneTarget) List() ([]string, error) {
+	output, err := exec.Command("rclone", "lsf", "--files-only", t.remote).CombinedOutput()
+	if err != nil {
+		if strings.Contains(string(output), "directory not found") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("rclone lsf: %s", strings.TrimSpace(string(output)))
+	}
+	var names []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			names = append(names, line)
+		}
+	}
+	return names, nil
+}
+
+func (t *rcloneTarget) Delete(name string) error {
+	return runTargetCommand("rclone", "deletefile", t.join(name))
+}
+
+func runTargetCommand(name string, args ...string) error {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %v: %s", name, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// remoteBackupID returns the backup ID of a file in a target, e.g.
+// backup-1738288812 for backup-1738288812.tar.gz.age
+func remoteBackupID(name string) string {
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+	if !strings.HasPrefix(name, "backup-") {
+		return ""
+	}
+	return name
+}
+
+// backupTime returns the creation time encoded in a backup ID
+func backupTime(backupID string) (time.Time, bool) {
+	seconds, err := strconv.ParseInt(strings.TrimPrefix(backupID, "backup-"), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
+// Upload copies a local backup to a target, archive first and metadata last
+func Upload(backupID string, target Target) error {
+	archiveFile := archivePath(backupID)
+	if _, err := os.Stat(archiveFile); err != nil {
+		return fmt.Errorf("backup not found: %s", backupID)
+	}
+	metadataFile := filepath.Join(backupMetadataDir, backupID+".json")
+	if _, err := os.Stat(metadataFile); err != nil {
+		return fmt.Errorf("backup metadata not found: %s", backupID)
+	}
+
+	if err := target.Upload(archiveFile, filepath.Base(archiveFile)); err != nil {
+		return fmt.Errorf("failed to upload archive: %w", err)
+	}
+	if err := target.Upload(metadataFile, backupID+".json"); err != nil {
+		return fmt.Errorf("failed to upload metadata: %w", err)
+	}
+	return nil
+}
+
+// Run creates a backup, uploads it to the target and deletes the backups in
+// the target older than retentionDays (0 keeps all). With removeLocal the
+// local copy is deleted once uploaded.
+func Run(opts BackupOptions, target Target, retentionDays int, removeLocal bool) (string, error) {
+	backupID, _, _, err := Create(opts)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Printf("📤 Uploading backup to %s...\n", target)
+	if err := Upload(backupID, target); err != nil {
+		notify.Send(notify.BackupFailed, "", "Backup "+backupID+" upload failed ("+target.String()+")", err.Error())
+		return backupID, err
+	}
+	fmt.Printf("✓ Uploaded: %s\n", backupID)
+
+	if removeLocal {
+		if err := Delete(backupID); err != nil {
+			ui.Warn("⚠️  Warning: Could not delete the local copy: %v\n", err)
+		}
+	}
+
+	if _, err := PruneRemote(target, retentionDays); err != nil {
+		ui.Warn("⚠️  Warning: Could not prune old backups in %s: %v\n", target, err)
+	}
+	return backupID, nil
+}
+
+// Fetch downloads a backup from a target into the local backup store, unless
+// it is there already, so it can be verified and restored
+func Fetch(backupID string, target Target) error {
+	metadataFile := filepath.Join(backupMetadataDir, backupID+".json")
+	if _, err := os.Stat(archivePath(backupID)); err == nil {
+		if _, err := os.Stat(metadataFile); err == nil {
+			return nil
+		}
+	}
+
+	names, err := target.List()
+	if err != nil {
+		return err
+	}
+	archiveName := ""
+	for _, name := range names {
+		if remoteBackupID(name) == backupID && name != backupID+".json" {
+			archiveName = name
+		}
+	}
+	if archiveName == "" || !containsName(names, backupID+".json") {
+		return fmt.Errorf("backup %s not found in %s", backupID, target)
+	}
+
+	archiveFile := filepath.Join(backupArchiveDir, archiveName)
+	if err := target.Download(archiveName, archiveFile); err != nil {
+		os.Remove(archiveFile)
+		return fmt.Errorf("failed to download archive: %w", err)
+	}
+	if err := target.Download(backupID+".json", metadataFile); err != nil {
+		os.Remove(archiveFile)
+		os.Remove(metadataFile)
+		return fmt.Errorf("failed to download metadata: %w", err)
+	}
+	return nil
+}
+
+// ListRemote returns the backups stored in a target, oldest first
+func ListRemote(target Target) ([]Backup, error) {
+	names, err := target.List()
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := ioutil.TempDir("", "webstack-remote-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var backups []Backup
+	for _, name := range names {
+		backupID := remoteBackupID(name)
+		if backupID == "" || name != backupID+".json" {
+			continue
+		}
+		localFile := filepath.Join(tmpDir, name)
+		if err := target.Download(name, localFile); err != nil {
+			ui.Warn("⚠️  Could not read %s: %v\n", name, err)
+			continue
+		}
+		data, err := ioutil.ReadFile(localFile)
+		if err != nil {
+			continue
+		}
+		var b Backup
+		if err := json.Unmarshal(data, &b); err != nil {
+			continue
+		}
+		backups = append(backups, b)
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Timestamp.Before(backups[j].Timestamp) })
+	return backups, nil
+}
+
+// PruneRemote deletes the backups in a target older than retentionDays,
+// including archives left without metadata by an interrupted upload
+func PruneRemote(target Target, retentionDays int) (int, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	names, err := target.List()
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	expired := map[string][]string{}
+	for _, name := range names {
+		backupID := remoteBackupID(name)
+		created, ok := backupTime(backupID)
+		if !ok || !created.Before(cutoff) {
+			continue
+		}
+		// Metadata goes first so a failed prune leaves an unlisted archive,
+		// deleted on the next run, rather than a listed backup without one
+		if strings.HasSuffix(name, ".json") {
+			expired[backupID] = append([]string{name}, expired[backupID]...)
+		} else {
+			expired[backupID] = append(expired[backupID], name)
+		}
+	}
+
+	deleted := 0
+	for backupID, files := range expired {
+		failed := false
+		for _, name := range files {
+			if err := target.Delete(name); err != nil {
+				ui.Warn("⚠️  Could not delete %s from %s: %v\n", name, target, err)
+				failed = true
+				break
+			}
+		}
+		if !failed {
+			deleted++
+			fmt.Printf("✓ Deleted old backup from %s: %s\n", target, backupID)
+		}
+	}
+	return deleted, nil
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,19 @@
+// Package bytesize formats sizes of files, backups and mailboxes for
+// people to read.
+package bytesize
+
+import "fmt"
+
+// Format returns a size in bytes with a binary unit, e.g. 1.50 MB
+func Format(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.2f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
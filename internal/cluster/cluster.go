@@ -0,0 +1,426 @@
+package cluster
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/remote"
+	"webstack-cli/internal/store"
+)
+
+var clusterStore = store.New("/etc/webstack/cluster.json", 1)
+
+// StateDocuments are copied with the store, so the secondary may keep its
+// state in JSON files or SQLite
+var StateDocuments = []string{"domains.json", "ssl.json", "upstreams.json"}
+
+// ConfigPaths are the vhosts, certificates and files they include, mirrored
+// to the secondary
+var ConfigPaths = []string{
+	"/etc/nginx/sites-available",
+	"/etc/nginx/sites-enabled",
+	"/etc/nginx/includes",
+	"/etc/apache2/sites-available",
+	"/etc/apache2/sites-enabled",
+	"/etc/apache2/includes",
+	"/etc/letsencrypt",
+	"/etc/webstack/ssl",
+	"/etc/webstack/acme",
+	"/etc/webstack/htpasswd",
+	"/etc/webstack/templates",
+	"/etc/webstack/error",
+}
+
+// ContentRoot holds the domain folders, copied without their logs
+const ContentRoot = "/var/www"
+
+// statePrefix marks state documents among the checksums
+const statePrefix = "state:"
+
+// Config is the content of cluster.json on the primary
+type Config struct {
+	Nodes []Node `json:"nodes"`
+}
+
+// Node is a secondary server kept in sync for failover
+type Node struct {
+	Server   string            `json:"server"` // Name in remotes.json
+	Joined   time.Time         `json:"joined"`
+	LastSync time.Time         `json:"last_sync,omitempty"`
+	Synced   map[string]string `json:"synced,omitempty"` // Checksums written by the last sync
+}
+
+// SyncOptions controls a sync
+type SyncOptions struct {
+	Force     bool // Overwrite changes made on the secondary since the last sync
+	NoContent bool // Only copy the configuration, not the domain folders
+}
+
+// Change is a difference between the primary and a secondary
+type Change struct {
+	Path     string
+	Action   string // add, update or delete on the secondary
+	Conflict string // Why the secondary's copy shouldn't be overwritten
+}
+
+// Load reads cluster.json
+func Load() (*Config, error) {
+	var cfg Config
+	if err := clusterStore.Load(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Update changes cluster.json under its lock
+func Update(fn func(*Config) error) error {
+	var cfg Config
+	return clusterStore.Update(&cfg, func() error {
+		return fn(&cfg)
+	})
+}
+
+// Find returns the node of a server
+func (c *Config) Find(server string) (*Node, bool) {
+	for i := range c.Nodes {
+		if c.Nodes[i].Server == server {
+			return &c.Nodes[i], true
+		}
+	}
+	return nil, false
+}
+
+// Checksums returns the sha256 of the state documents and of every file
+// under ConfigPaths. Symlinks (sites-enabled) are recorded by their target.
+func Checksums() (map[string]string, error) {
+	sums := map[string]string{}
+	for _, name := range StateDocuments {
+		data, found, err := store.Dump(name)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			sums[statePrefix+name] = checksum(data)
+		}
+	}
+
+	for _, root := range ConfigPaths {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			switch {
+			case info.Mode()&os.ModeSymlink != 0:
+				target, err := os.Readlink(path)
+				if err != nil {
+					return err
+				}
+				sums[path] = checksum([]byte("link:" + target))
+			case info.Mode().IsRegular():
+				data, err := ioutil.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				sums[path] = checksum(data)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sums, nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Receive stores a state document sent by the primary
+func Receive(name string, data []byte) error {
+	if !knownDocument(name) {
+		return fmt.Errorf("unknown state document %s", name)
+	}
+	return store.Restore(name, data)
+}
+
+func knownDocument(name string) bool {
+	for _, doc := range StateDocuments {
+		if doc == name {
+			return true
+		}
+	}
+	return false
+}
+
+// localRoot reports whether a path is under one of the ConfigPaths that
+// exist on this server
+func localRoot(path string) bool {
+	for _, root := range ConfigPaths {
+		if path == root || strings.HasPrefix(path, root+"/") {
+			_, err := os.Stat(root)
+			return err == nil
+		}
+	}
+	return false
+}
+
+// remoteChecksums asks the secondary for its checksums
+func remoteChecksums(s remote.Server) (map[string]string, error) {
+	output, err := remote.Webstack(s, []string{"cluster", "checksums"}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	sums := map[string]string{}
+	if err := json.Unmarshal(output, &sums); err != nil {
+		return nil, fmt.Errorf("unexpected answer from %s (is webstack up to date there?): %v", s.Name, err)
+	}
+	return sums, nil
+}
+
+// Compare lists the differences between the primary and a secondary. A
+// change conflicts when the secondary's copy was modified since the last
+// sync, or was never written by a sync.
+func Compare(local, secondary map[string]string, node Node) []Change {
+	var changes []Change
+	paths := map[string]bool{}
+	for path := range local {
+		paths[path] = true
+	}
+	for path := range secondary {
+		paths[path] = true
+	}
+
+	for path := range paths {
+		ours, theirs := local[path], secondary[path]
+		if ours == theirs {
+			continue
+		}
+		c := Change{Path: path, Action: "update"}
+		switch {
+		case theirs == "":
+			c.Action = "add"
+		case ours == "":
+			c.Action = "delete"
+		}
+		if theirs != "" {
+			synced, ok := node.Synced[path]
+			switch {
+			case !ok && node.LastSync.IsZero():
+				c.Conflict = "not written by a sync (first sync)"
+			case !ok:
+				c.Conflict = "created on the secondary since the last sync"
+			case synced != theirs:
+				c.Conflict = "changed on the secondary since the last sync"
+			}
+		}
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// Sync mirrors the domains, vhosts, certificates and domain folders to a
+// secondary. It stops before copying anything when there are conflicts,
+// unless forced, and only shows the differences in dry-run mode.
+func Sync(node Node, s remote.Server, opts SyncOptions) error {
+	fmt.Printf("🔍 Comparing with %s (%s)...\n", s.Name, s.Target())
+	local, err := Checksums()
+	if err != nil {
+		return fmt.Errorf("could not read the local configuration: %v", err)
+	}
+	secondary, err := remoteChecksums(s)
+	if err != nil {
+		return err
+	}
+	// Folders the primary doesn't have, e.g. Apache's on an Nginx-only
+	// primary, are left alone on the secondary
+	for path := range secondary {
+		if !strings.HasPrefix(path, statePrefix) && !localRoot(path) {
+			delete(secondary, path)
+		}
+	}
+
+	changes := Compare(local, secondary, node)
+	conflicts := 0
+	for _, c := range changes {
+		symbol := map[string]string{"add": "+", "update": "~", "delete": "-"}[c.Action]
+		fmt.Printf("  %s %s\n", symbol, displayPath(c.Path))
+		if c.Conflict != "" {
+			conflicts++
+			fmt.Printf("      ⚠️  conflict: %s\n", c.Conflict)
+		}
+	}
+	if len(changes) == 0 {
+		fmt.Println("  Configuration already in sync")
+	}
+
+	if dryrun.Enabled() {
+		printDiffs(s, changes)
+		if !opts.NoContent {
+			fmt.Printf("\nDomain folders (%s):\n", ContentRoot)
+			if err := rsync(s, ContentRoot, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if conflicts > 0 && !opts.Force {
+		return fmt.Errorf("%d conflict(s): review them with --dry-run, then use --force to overwrite the secondary", conflicts)
+	}
+
+	for _, path := range ConfigPaths {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if err := rsync(s, path, false); err != nil {
+			return err
+		}
+	}
+	for _, name := range StateDocuments {
+		data, found, err := store.Dump(name)
+		if err != nil || !found {
+			continue
+		}
+		if output, err := remote.Webstack(s, []string{"cluster", "receive", name}, bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("could not copy %s: %v: %s", name, err, strings.TrimSpace(string(output)))
+		}
+	}
+	if !opts.NoContent {
+		fmt.Printf("📦 Copying domain folders to %s...\n", s.Name)
+		if err := rsync(s, ContentRoot, false); err != nil {
+			return err
+		}
+	}
+
+	// The copied vhosts are validated before the web servers on the
+	// secondary load them
+	output, err := remote.Exec(s, reloadScript, nil)
+	if err != nil {
+		return fmt.Errorf("configuration copied, but the web servers on %s could not be reloaded: %s", s.Name, strings.TrimSpace(string(output)))
+	}
+
+	return Update(func(cfg *Config) error {
+		n, ok := cfg.Find(node.Server)
+		if !ok {
+			return fmt.Errorf("%s left the cluster during the sync", node.Server)
+		}
+		n.LastSync = time.Now()
+		n.Synced = local
+		return nil
+	})
+}
+
+// reloadScript reloads the web servers installed on the secondary after
+// checking their configuration
+const reloadScript = `set -e
+if command -v nginx >/dev/null 2>&1; then nginx -t -q && systemctl reload nginx; fi
+if command -v apache2ctl >/dev/null 2>&1; then apache2ctl -t >/dev/null 2>&1 && systemctl reload apache2; fi`
+
+// rsync mirrors a directory to the same path on the secondary. Logs in the
+// domain folders stay on each server.
+func rsync(s remote.Server, path string, preview bool) error {
+	if _, err := exec.LookPath("rsync"); err != nil {
+		return fmt.Errorf("rsync is not installed (apt install rsync, on both servers)")
+	}
+	if preview {
+		// Nothing is created on the secondary while previewing
+	} else if output, err := remote.Exec(s, "mkdir -p "+path, nil); err != nil {
+		return fmt.Errorf("could not create %s on %s: %v: %s", path, s.Name, err, strings.TrimSpace(string(output)))
+	}
+
+	args := append([]string{"-a", "--delete"}, s.RsyncArgs()...)
+	if path == ContentRoot {
+		args = append(args, "--exclude=/*/logs/")
+	}
+	if preview {
+		args = append(args, "--dry-run", "--itemize-changes")
+	}
+	args = append(args, strings.TrimSuffix(path, "/")+"/", s.Dest(path+"/"))
+
+	output, err := exec.Command("rsync", args...).CombinedOutput()
+	if preview {
+		for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+			if line != "" {
+				fmt.Printf("  %s\n", line)
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("could not copy %s to %s: %v: %s", path, s.Name, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// printDiffs shows how the state documents and text files would change on
+// the secondary
+func printDiffs(s remote.Server, changes []Change) {
+	for _, c := range changes {
+		var ours, theirs []byte
+		if name := strings.TrimPrefix(c.Path, statePrefix); name != c.Path {
+			ours, _, _ = store.Dump(name)
+			if c.Action != "add" {
+				if output, err := remote.Webstack(s, []string{"cluster", "dump", name}, nil); err == nil {
+					theirs = output
+				}
+			}
+		} else {
+			if info, err := os.Lstat(c.Path); err == nil && !info.Mode().IsRegular() {
+				continue
+			}
+			ours, _ = ioutil.ReadFile(c.Path)
+			if c.Action != "add" {
+				if output, err := remote.Exec(s, "cat -- "+c.Path, nil); err == nil {
+					theirs = output
+				}
+			}
+		}
+		// Certificates and keys are listed above but never printed
+		if bytes.IndexByte(ours, 0) >= 0 || len(ours) > 256*1024 || strings.HasSuffix(c.Path, ".pem") || strings.HasSuffix(c.Path, ".key") {
+			continue
+		}
+
+		previous, err := ioutil.TempFile("", "webstack-cluster-")
+		if err != nil {
+			continue
+		}
+		previous.Write(theirs)
+		previous.Close()
+
+		// diff reads the primary's copy from stdin and exits with 1 when the
+		// files differ, so only the output matters
+		fmt.Printf("\n%s:\n", displayPath(c.Path))
+		cmd := exec.Command("diff", "-u", "--label", s.Name, "--label", "primary", previous.Name(), "-")
+		cmd.Stdin = bytes.NewReader(ours)
+		output, _ := cmd.CombinedOutput()
+		os.Remove(previous.Name())
+		for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+			if line != "" {
+				fmt.Printf("   %s\n", line)
+			}
+		}
+	}
+}
+
+// displayPath shows state documents by their file name
+func displayPath(path string) string {
+	if name := strings.TrimPrefix(path, statePrefix); name != path {
+		return name + " (state)"
+	}
+	return path
+}
@@ -1,15 +1,29 @@
 package config
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
+	"strconv"
+	"webstack-cli/internal/store"
 )
 
 const configFile = "/etc/webstack/config.json"
 
+// configStore locks config.json and writes it atomically
+var configStore = store.New(configFile, 1)
+
+// Server-wide defaults, set with 'webstack config set'
+const (
+	DefaultBackendKey = "defaults.backend" // Backend of new domains without --backend
+	DefaultPHPKey     = "defaults.php"     // PHP version of new domains and 'install php' without a version
+	WebRootKey        = "defaults.webroot" // Folder new domains are created in, as <webroot>/<domain>
+)
+
+// Built-in values used while the defaults above are not set
+const (
+	FallbackPHPVersion = "8.2"
+	FallbackWebRoot    = "/var/www"
+)
+
 // ServerConfig represents configuration for a server
 type ServerConfig struct {
 	Installed bool   `json:"installed"`
@@ -24,6 +38,7 @@ type Config struct {
 	Version  string                   `json:"version"`
 	Servers  map[string]ServerConfig `json:"servers"`
 	Defaults map[string]interface{}  `json:"defaults"`
+	Pins     map[string]string        `json:"pins,omitempty"` // Component -> package version held by 'webstack upgrade --pin'
 }
 
 // DefaultConfig returns a new config with default values
@@ -56,50 +71,62 @@ func DefaultConfig() *Config {
 				Port:      5432,
 				Mode:      "backend",
 			},
+			"mongodb": {
+				Installed: false,
+				Port:      27017,
+				Mode:      "backend",
+			},
 		},
 		Defaults: map[string]interface{}{
-			"php_version":  "8.1",
 			"ssl_provider": "letsencrypt",
+			"harden_webroot": true,
 		},
 	}
 }
 
 // Load reads config from file
 func Load() (*Config, error) {
-	if _, err := os.Stat(configFile); os.IsNotExist(err) {
+	if !configStore.Exists() {
 		return DefaultConfig(), nil
 	}
 
-	data, err := ioutil.ReadFile(configFile)
-	if err != nil {
-		return nil, fmt.Errorf("error reading config file: %w", err)
-	}
-
 	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("error parsing config file: %w", err)
+	if err := configStore.Load(&cfg); err != nil {
+		return nil, fmt.Errorf("error loading config file: %w", err)
 	}
 
 	return &cfg, nil
 }
 
-// Save writes config to file
+// Save writes config to file. Prefer Update for read-modify-write changes,
+// which keeps another invocation's change from being overwritten.
 func (c *Config) Save() error {
-	// Ensure directory exists
-	dir := filepath.Dir(configFile)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("error creating config directory: %w", err)
+	if err := configStore.Save(c); err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
 	}
 
-	data, err := json.MarshalIndent(c, "", "  ")
-	if err != nil {
-		return fmt.Errorf("error marshaling config: %w", err)
-	}
+	return nil
+}
 
-	if err := ioutil.WriteFile(configFile, data, 0644); err != nil {
-		return fmt.Errorf("error writing config file: %w", err)
+// Update loads the config (the defaults when there is no config file yet),
+// lets fn change it and saves it while holding the config lock. Nothing is
+// saved when fn returns an error.
+func Update(fn func(c *Config) error) error {
+	var cfg Config
+	var fnErr error
+	err := configStore.Update(&cfg, func() error {
+		if cfg.Version == "" && cfg.Servers == nil && cfg.Defaults == nil {
+			cfg = *DefaultConfig()
+		}
+		fnErr = fn(&cfg)
+		return fnErr
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return fmt.Errorf("error updating config file: %w", err)
 	}
-
 	return nil
 }
 
@@ -141,6 +168,23 @@ func (c *Config) GetMode(name string) string {
 	return ""
 }
 
+// Pin returns the version a component is pinned to, or "" when it isn't
+func (c *Config) Pin(component string) string {
+	return c.Pins[component]
+}
+
+// SetPin pins a component to a version; an empty version unpins it
+func (c *Config) SetPin(component, version string) {
+	if version == "" {
+		delete(c.Pins, component)
+		return
+	}
+	if c.Pins == nil {
+		c.Pins = make(map[string]string)
+	}
+	c.Pins[component] = version
+}
+
 // SetDefault sets a default value
 func (c *Config) SetDefault(key string, value interface{}) {
 	if c.Defaults == nil {
@@ -149,6 +193,11 @@ func (c *Config) SetDefault(key string, value interface{}) {
 	c.Defaults[key] = value
 }
 
+// UnsetDefault removes a default value
+func (c *Config) UnsetDefault(key string) {
+	delete(c.Defaults, key)
+}
+
 // GetDefault gets a default value
 func (c *Config) GetDefault(key string, defaultValue interface{}) interface{} {
 	if val, ok := c.Defaults[key]; ok {
@@ -156,3 +205,48 @@ func (c *Config) GetDefault(key string, defaultValue interface{}) interface{} {
 	}
 	return defaultValue
 }
+
+// GetInt gets a default value as an integer, or defaultValue when it is not
+// set or not a number. JSON numbers and strings are both accepted.
+func (c *Config) GetInt(key string, defaultValue int) int {
+	switch v := c.GetDefault(key, nil).(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+// DefaultPHPVersion returns the defaults.php setting, else 8.2
+func (c *Config) DefaultPHPVersion() string {
+	if version, ok := c.GetDefault(DefaultPHPKey, "").(string); ok && version != "" {
+		return version
+	}
+	return FallbackPHPVersion
+}
+
+// WebRoot returns the defaults.webroot setting, else /var/www
+func (c *Config) WebRoot() string {
+	if root, ok := c.GetDefault(WebRootKey, "").(string); ok && root != "" {
+		return root
+	}
+	return FallbackWebRoot
+}
+
+// GetBool gets a default value as a boolean. Values stored as strings
+// (e.g. via "webstack config set") are parsed as well.
+func (c *Config) GetBool(key string) bool {
+	switch v := c.GetDefault(key, false).(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
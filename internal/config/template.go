@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	"text/template"
+	"webstack-cli/internal/osinfo"
 )
 
 // TemplateData contains data for template processing
@@ -78,7 +79,7 @@ func GetPHPSocket(version string) string {
 
 // GetPHPServiceName returns the service name for a PHP version
 func GetPHPServiceName(version string) string {
-	return fmt.Sprintf("php%s-fpm", version)
+	return osinfo.Current().PHPFPMService(version)
 }
 
 // CreateTemplateData creates TemplateData from domain configuration
@@ -12,6 +12,7 @@ import (
 	"sort"
 	"strings"
 	"time"
+	"webstack-cli/internal/dryrun"
 )
 
 const cronDir = "/var/spool/cron/crontabs"
@@ -402,7 +403,7 @@ func saveJobMetadata(job Job) error {
 		return err
 	}
 
-	return ioutil.WriteFile(metadataFile, data, 0644)
+	return dryrun.WriteFile(metadataFile, data, 0644)
 }
 
 // addJobToCrontab adds a job to the crontab
@@ -476,13 +477,13 @@ func writeCrontab(content string) error {
 
 	// Write to temp file first
 	tmpFile := cronFile + ".tmp"
-	if err := ioutil.WriteFile(tmpFile, []byte(content), 0600); err != nil {
+	if err := dryrun.WriteFile(tmpFile, []byte(content), 0600); err != nil {
 		return err
 	}
 
 	// Use crontab command to install
 	cmd := exec.Command("crontab", tmpFile)
-	if err := cmd.Run(); err != nil {
+	if err := dryrun.Run(cmd); err != nil {
 		os.Remove(tmpFile)
 		return fmt.Errorf("failed to install crontab: %w", err)
 	}
@@ -0,0 +1,598 @@
+package doctor
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/installer"
+	"webstack-cli/internal/notify"
+	"webstack-cli/internal/osinfo"
+	"webstack-cli/internal/phpfpm"
+	"webstack-cli/internal/service"
+	"webstack-cli/internal/ssl"
+	"webstack-cli/internal/ui"
+)
+
+// Certificates expiring within this window are reported as warnings
+const certWarningWindow = 14 * 24 * time.Hour
+
+const dnsTimeout = 3 * time.Second
+
+var (
+	phpSocketPattern  = regexp.MustCompile(`unix:(/run/php/[^\s;|"]+\.sock)`)
+	phpVersionPattern = regexp.MustCompile(`php([0-9]+\.[0-9]+)-fpm`)
+)
+
+// vhost directories checked for orphaned and broken files
+var vhostDirs = []struct {
+	server    string
+	available string
+	enabled   string
+}{
+	{"nginx", "/etc/nginx/sites-available", "/etc/nginx/sites-enabled"},
+	{"apache", filepath.Join(osinfo.Current().ApacheDir, "sites-available"), filepath.Join(osinfo.Current().ApacheDir, "sites-enabled")},
+}
+
+// Vhosts written by the installers rather than for a domain
+var systemVhosts = map[string]bool{"000-default": true, "default-ssl": true, "default": true}
+
+// Options controls a doctor run
+type Options struct {
+	Fix bool // Repair the problems that have a safe automatic fix
+}
+
+// problem is one finding of a check. fix is nil when the problem can only
+// be repaired by hand.
+type problem struct {
+	warning bool
+	message string
+	hint    string
+	fix     func() (string, error)
+	reload  bool // The fix changes vhosts, web servers need a reload
+}
+
+type report struct {
+	opts     Options
+	problems int
+	fixed    int
+	reload   bool // Vhost fixes are applied with a single reload at the end
+}
+
+// Run checks the server for configuration drift and broken services and
+// prints each problem with a suggested fix. With opts.Fix, problems that
+// have a safe automatic fix are repaired.
+func Run(opts Options) {
+	fmt.Println("🩺 WebStack Doctor")
+	fmt.Println("==================")
+
+	r := &report{opts: opts}
+	domains, err := domain.All()
+	if err != nil {
+		ui.Fail("❌ Could not load domains: %v\n", err)
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		cfg = config.DefaultConfig()
+	}
+
+	r.section("Virtual hosts", checkVhosts(domains))
+	r.section("Enabled sites", checkSymlinks())
+	r.section("PHP-FPM sockets", checkPHPSockets())
+	r.section("SSL certificates", checkCertificates(domains))
+	r.section("Services", checkServices(domains, opts.Fix))
+	r.section("Ports", checkPorts(cfg))
+	r.section("Component dependencies", checkDependencies())
+	r.section("DNS", checkDNS(domains))
+
+	if r.reload {
+		domain.ReloadWebServers()
+	}
+
+	fmt.Println()
+	switch {
+	case r.problems == 0:
+		fmt.Println("✅ No problems found")
+	case r.opts.Fix:
+		fmt.Printf("Summary: %d problem(s) found, %d fixed\n", r.problems, r.fixed)
+	default:
+		fmt.Printf("Summary: %d problem(s) found\n", r.problems)
+		fmt.Println("💡 Run 'sudo webstack doctor --fix' to repair the ones marked as fixable")
+	}
+}
+
+// section prints the findings of one check
+func (r *report) section(title string, problems []problem) {
+	fmt.Printf("\n🔍 %s\n", title)
+	if len(problems) == 0 {
+		fmt.Println("   No problems")
+		return
+	}
+
+	for _, p := range problems {
+		r.problems++
+
+		if r.opts.Fix && p.fix != nil {
+			result, err := p.fix()
+			if err == nil {
+				r.fixed++
+				r.reload = r.reload || p.reload
+				fmt.Printf("✅ Fixed: %s (%s)\n", p.message, result)
+				continue
+			}
+			p.hint = fmt.Sprintf("Automatic fix failed: %v. %s", err, p.hint)
+		}
+
+		if p.warning {
+			ui.Warn("⚠️  %s\n", p.message)
+		} else {
+			ui.Fail("❌ %s\n", p.message)
+		}
+		if p.hint != "" {
+			fmt.Printf("   💡 %s\n", p.hint)
+		}
+		if !r.opts.Fix && p.fix != nil {
+			fmt.Println("   🔧 Fixable with --fix")
+		}
+	}
+}
+
+// checkVhosts compares the vhost files with domains.json
+func checkVhosts(domains []domain.Domain) []problem {
+	var problems []problem
+
+	expected := map[string]bool{}
+	for _, d := range domains {
+		var missing []string
+		vhosts := domain.Vhosts(d)
+		for _, server := range []string{"nginx", "apache"} {
+			path, ok := vhosts[server]
+			if !ok {
+				continue
+			}
+			expected[server+"/"+d.Name] = true
+			if !exists(path) {
+				missing = append(missing, path)
+			}
+		}
+		if len(missing) > 0 {
+			problems = append(problems, problem{
+				message: fmt.Sprintf("%s is in domains.json but has no vhost (%s)", d.Name, strings.Join(missing, ", ")),
+				hint:    "Regenerate it with: sudo webstack domain rebuild-configs",
+				reload:  true,
+				fix: func() (string, error) {
+					if err := domain.GenerateConfig(d); err != nil {
+						return "", err
+					}
+					return "vhost regenerated", nil
+				},
+			})
+		}
+	}
+
+	for _, dirs := range vhostDirs {
+		files, _ := filepath.Glob(filepath.Join(dirs.available, "*.conf"))
+		for _, file := range files {
+			name := strings.TrimSuffix(filepath.Base(file), ".conf")
+			if systemVhosts[name] || expected[dirs.server+"/"+name] {
+				continue
+			}
+
+			link := filepath.Join(dirs.enabled, filepath.Base(file))
+			problems = append(problems, problem{
+				warning: true,
+				message: fmt.Sprintf("Orphaned %s vhost %s has no entry in domains.json", dirs.server, file),
+				hint:    fmt.Sprintf("Add the domain with 'webstack domain add %s' or remove the file", name),
+				reload:  true,
+				fix: func() (string, error) {
+					if err := dryrun.Remove(link); err != nil && !os.IsNotExist(err) {
+						return "", err
+					}
+					if err := dryrun.Rename(file, file+".orphaned"); err != nil {
+						return "", err
+					}
+					return "disabled and kept as " + file + ".orphaned", nil
+				},
+			})
+		}
+	}
+	return problems
+}
+
+// checkSymlinks reports sites-enabled entries pointing at missing files
+func checkSymlinks() []problem {
+	var problems []problem
+	for _, dirs := range vhostDirs {
+		entries, _ := ioutil.ReadDir(dirs.enabled)
+		for _, entry := range entries {
+			link := filepath.Join(dirs.enabled, entry.Name())
+			if entry.Mode()&os.ModeSymlink == 0 || exists(link) {
+				continue
+			}
+
+			target, _ := os.Readlink(link)
+			problems = append(problems, problem{
+				message: fmt.Sprintf("Broken symlink %s -> %s", link, target),
+				hint:    fmt.Sprintf("%s fails its configuration test while it exists; remove it", dirs.server),
+				reload:  true,
+				fix: func() (string, error) {
+					if err := dryrun.Remove(link); err != nil {
+						return "", err
+					}
+					return "symlink removed", nil
+				},
+			})
+		}
+	}
+	return problems
+}
+
+// checkPHPSockets reports PHP-FPM sockets referenced by enabled vhosts that
+// do not exist, which makes every PHP request fail with 502
+func checkPHPSockets() []problem {
+	var problems []problem
+
+	sockets := map[string][]string{}
+	for _, dirs := range vhostDirs {
+		files, _ := filepath.Glob(filepath.Join(dirs.enabled, "*"))
+		for _, file := range files {
+			content, err := ioutil.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			for _, match := range phpSocketPattern.FindAllStringSubmatch(string(content), -1) {
+				sockets[match[1]] = appendUnique(sockets[match[1]], filepath.Base(file))
+			}
+		}
+	}
+
+	for _, socket := range sortedKeys(sockets) {
+		if exists(socket) {
+			continue
+		}
+
+		p := problem{
+			message: fmt.Sprintf("PHP-FPM socket %s is missing (used by %s)", socket, strings.Join(sockets[socket], ", ")),
+			hint:    "Check that the PHP version is installed and its pool is valid",
+		}
+		if match := phpVersionPattern.FindStringSubmatch(filepath.Base(socket)); match != nil {
+			unit := osinfo.Current().PHPFPMService(match[1])
+			p.hint = fmt.Sprintf("Start %s (sudo systemctl restart %s) or run 'sudo webstack domain rebuild-configs' to rewrite the pool", unit, unit)
+			p.fix = func() (string, error) {
+				if err := service.Restart(unit); err != nil {
+					return "", err
+				}
+				if !dryrun.Enabled() && !exists(socket) {
+					return "", fmt.Errorf("%s is running but does not create %s", unit, socket)
+				}
+				return unit + " restarted", nil
+			}
+		}
+		problems = append(problems, p)
+	}
+	return problems
+}
+
+// checkCertificates reports missing, expired and soon-expiring certificates
+// of domains with SSL enabled
+func checkCertificates(domains []domain.Domain) []problem {
+	var problems []problem
+	for _, d := range domains {
+		if !d.SSLEnabled {
+			continue
+		}
+		name := d.Name
+
+		cert, err := readCertificate(d.SSLCertPath)
+		if err != nil {
+			problems = append(problems, problem{
+				message: fmt.Sprintf("Certificate of %s cannot be read: %v", name, err),
+				hint:    fmt.Sprintf("Issue a new one with: sudo webstack ssl enable %s", name),
+			})
+			continue
+		}
+
+		remaining := time.Until(cert.NotAfter)
+		if remaining > certWarningWindow {
+			continue
+		}
+
+		p := problem{
+			warning: remaining > 0,
+			message: fmt.Sprintf("Certificate of %s expires on %s", name, cert.NotAfter.Format("2006-01-02")),
+			hint:    fmt.Sprintf("Renew it with: sudo webstack ssl renew %s", name),
+		}
+		if remaining <= 0 {
+			p.message = fmt.Sprintf("Certificate of %s expired on %s", name, cert.NotAfter.Format("2006-01-02"))
+		}
+
+		if strings.HasPrefix(d.SSLCertPath, ssl.ImportDir) {
+			p.hint = fmt.Sprintf("Imported certificates are not renewed; import the renewed one with: sudo webstack ssl import %s --cert ... --key ...", name)
+		} else if cert.Issuer.String() == cert.Subject.String() {
+			p.hint = fmt.Sprintf("Self-signed certificates are not renewed; issue a new one with: sudo webstack ssl enable %s --type selfsigned", name)
+		} else {
+			path := d.SSLCertPath
+			p.fix = func() (string, error) {
+				ssl.Renew(name)
+				if dryrun.Enabled() {
+					return "renewal requested", nil
+				}
+				renewed, err := readCertificate(path)
+				if err != nil {
+					return "", err
+				}
+				if !renewed.NotAfter.After(cert.NotAfter) {
+					return "", fmt.Errorf("renewal did not produce a new certificate")
+				}
+				return "valid until " + renewed.NotAfter.Format("2006-01-02"), nil
+			}
+		}
+		problems = append(problems, p)
+	}
+	return problems
+}
+
+// checkServices reports enabled services that are not running. Without fix
+// they are also reported to the notification channels; with it a failed
+// restart is reported by the service package.
+func checkServices(domains []domain.Domain, fix bool) []problem {
+	layout := osinfo.Current()
+	var units []string
+	for _, unit := range []string{"nginx", "apache2", "mysql", "mariadb", "postgresql", "mongod", "bind9", "postfix", "dovecot"} {
+		units = appendUnique(units, layout.Service(unit))
+	}
+
+	for _, version := range phpfpm.Versions() {
+		units = append(units, layout.PHPFPMService(version))
+	}
+	for _, d := range domains {
+		if d.PHPVersion != "" {
+			units = appendUnique(units, layout.PHPFPMService(d.PHPVersion))
+		}
+	}
+
+	var problems []problem
+	seen := map[string]bool{}
+	for _, unit := range units {
+		status, err := service.Status(unit)
+		if err != nil || seen[status.Unit] {
+			continue
+		}
+		// Aliases such as mysql -> mariadb resolve to the same unit
+		seen[status.Unit] = true
+		if status.Active == "active" || !status.Enabled {
+			continue
+		}
+		if !fix {
+			notify.Send(notify.ServiceDown, "", unit+" is down", fmt.Sprintf("%s is enabled but %s (found by webstack doctor)", unit, status.Active))
+		}
+
+		problems = append(problems, problem{
+			message: fmt.Sprintf("%s is enabled but %s", unit, status.Active),
+			hint:    fmt.Sprintf("Check why it stopped: journalctl -u %s -n 20", unit),
+			fix: func() (string, error) {
+				if err := service.Restart(unit); err != nil {
+					return "", err
+				}
+				return "restarted", nil
+			},
+		})
+	}
+	return problems
+}
+
+// checkDependencies reports installed components whose requirements were
+// removed, such as phpMyAdmin without a database server
+func checkDependencies() []problem {
+	var problems []problem
+	for _, dp := range installer.DependencyProblems() {
+		problems = append(problems, problem{
+			message: fmt.Sprintf("%s is installed but needs %s", dp.Name, strings.Join(dp.Missing, ", ")),
+			hint:    "Install the missing components (webstack install --help) or remove " + dp.Name,
+		})
+	}
+	return problems
+}
+
+// checkPorts reports ports of the installed servers held by other processes
+func checkPorts(cfg *config.Config) []problem {
+	listeners, err := listeningPorts()
+	if err != nil {
+		return []problem{{warning: true, message: fmt.Sprintf("Could not list listening ports: %v", err)}}
+	}
+
+	// Port => processes allowed to listen on it
+	expected := map[int][]string{}
+	if cfg.IsInstalled("nginx") {
+		expected[cfg.GetPort("nginx")] = []string{"nginx"}
+		expected[443] = []string{"nginx"}
+	}
+	if cfg.IsInstalled("apache") {
+		expected[cfg.GetPort("apache")] = append(expected[cfg.GetPort("apache")], "apache2", "httpd")
+		if !cfg.IsInstalled("nginx") {
+			expected[443] = append(expected[443], "apache2", "httpd")
+		}
+	}
+	for _, db := range []string{"mysql", "mariadb"} {
+		if cfg.IsInstalled(db) {
+			expected[cfg.GetPort(db)] = append(expected[cfg.GetPort(db)], "mysqld", "mariadbd")
+		}
+	}
+	if cfg.IsInstalled("postgresql") {
+		expected[cfg.GetPort("postgresql")] = append(expected[cfg.GetPort("postgresql")], "postgres")
+	}
+	if cfg.IsInstalled("mongodb") {
+		expected[cfg.GetPort("mongodb")] = append(expected[cfg.GetPort("mongodb")], "mongod")
+	}
+
+	var ports []int
+	for port := range expected {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+
+	var problems []problem
+	for _, port := range ports {
+		if port == 0 {
+			continue
+		}
+		for _, process := range listeners[port] {
+			if containsString(expected[port], process) {
+				continue
+			}
+			problems = append(problems, problem{
+				message: fmt.Sprintf("Port %d is used by %s, expected %s", port, process, strings.Join(expected[port], " or ")),
+				hint:    fmt.Sprintf("Stop %s or move it to another port (sudo ss -ltnp 'sport = :%d')", process, port),
+			})
+		}
+	}
+	return problems
+}
+
+// listeningPorts returns the processes listening on each TCP port
+func listeningPorts() (map[int][]string, error) {
+	output, err := exec.Command("ss", "-Hltnp").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	processPattern := regexp.MustCompile(`\(\("([^"]+)"`)
+	listeners := map[int][]string{}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		local := fields[3]
+		port, err := strconv.Atoi(local[strings.LastIndex(local, ":")+1:])
+		if err != nil {
+			continue
+		}
+		process := "unknown process"
+		if match := processPattern.FindStringSubmatch(line); match != nil {
+			process = match[1]
+		}
+		listeners[port] = appendUnique(listeners[port], process)
+	}
+	return listeners, nil
+}
+
+// checkDNS reports domains that do not resolve to an address of this server
+func checkDNS(domains []domain.Domain) []problem {
+	local := localAddresses()
+
+	var problems []problem
+	for _, d := range domains {
+		if isLocalName(d.Name) {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+		addrs, err := net.DefaultResolver.LookupHost(ctx, d.Name)
+		cancel()
+		if err != nil {
+			problems = append(problems, problem{
+				warning: true,
+				message: fmt.Sprintf("%s does not resolve", d.Name),
+				hint:    "Add an A/AAAA record pointing at this server",
+			})
+			continue
+		}
+
+		pointsHere := false
+		for _, addr := range addrs {
+			if local[addr] {
+				pointsHere = true
+			}
+		}
+		if !pointsHere {
+			problems = append(problems, problem{
+				warning: true,
+				message: fmt.Sprintf("%s resolves to %s, not to this server", d.Name, strings.Join(addrs, ", ")),
+				hint:    "Update the A/AAAA records (ignore this behind NAT or a proxy such as Cloudflare)",
+			})
+		}
+	}
+	return problems
+}
+
+// localAddresses returns the IP addresses of the network interfaces
+func localAddresses() map[string]bool {
+	local := map[string]bool{}
+	addrs, _ := net.InterfaceAddrs()
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok {
+			local[ipnet.IP.String()] = true
+		}
+	}
+	return local
+}
+
+func isLocalName(name string) bool {
+	for _, suffix := range []string{".local", ".test", ".localhost", ".invalid"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return name == "localhost"
+}
+
+func readCertificate(path string) (*x509.Certificate, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no certificate path in domains.json")
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM certificate", path)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func appendUnique(list []string, value string) []string {
+	if containsString(list, value) {
+		return list
+	}
+	return append(list, value)
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,163 @@
+package domain
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"webstack-cli/internal/ui"
+)
+
+// AccessRule allows or denies an address, a network or all clients. Rules
+// are checked in order and the first match decides; clients matching none
+// are allowed.
+type AccessRule struct {
+	Action string `json:"action"` // "allow" or "deny"
+	Source string `json:"source"` // IP address, CIDR network or "all"
+}
+
+// parseAccessSource validates an address, a CIDR network or "all"
+func parseAccessSource(source string) (string, error) {
+	source = strings.TrimSpace(source)
+	if strings.EqualFold(source, "all") {
+		return "all", nil
+	}
+	if ip := net.ParseIP(source); ip != nil {
+		return ip.String(), nil
+	}
+	if _, network, err := net.ParseCIDR(source); err == nil {
+		return network.String(), nil
+	}
+	return "", fmt.Errorf("%s is not an IP address, a CIDR network or all", source)
+}
+
+// addAccessRule adds a rule, or changes the action of the rule for the same
+// source. Rules for single sources go before a trailing "all" rule, which
+// would otherwise decide first.
+func addAccessRule(rules []AccessRule, rule AccessRule) []AccessRule {
+	for i, r := range rules {
+		if r.Source == rule.Source {
+			rules[i] = rule
+			return rules
+		}
+	}
+	if n := len(rules); n > 0 && rule.Source != "all" && rules[n-1].Source == "all" {
+		return append(rules[:n-1:n-1], rule, rules[n-1])
+	}
+	return append(rules, rule)
+}
+
+// apacheAccess renders access rules as Apache 2.4 Require directives, which
+// have no first-match order: each allowed source starts a RequireAny with
+// the rules after it, each denied source a RequireAll
+func apacheAccess(rules []AccessRule, indent string) string {
+	if len(rules) == 0 {
+		return indent + "Require all granted\n"
+	}
+	r := rules[0]
+	if r.Source == "all" {
+		if r.Action == "allow" {
+			return indent + "Require all granted\n"
+		}
+		return indent + "Require all denied\n"
+	}
+	if r.Action == "allow" {
+		return indent + "<RequireAny>\n" +
+			indent + "    Require ip " + r.Source + "\n" +
+			apacheAccess(rules[1:], indent+"    ") +
+			indent + "</RequireAny>\n"
+	}
+	return indent + "<RequireAll>\n" +
+		indent + "    Require not ip " + r.Source + "\n" +
+		apacheAccess(rules[1:], indent+"    ") +
+		indent + "</RequireAll>\n"
+}
+
+// accessVars adds the access rules of a domain to the template variables
+func accessVars(d Domain, vars map[string]interface{}) {
+	vars["Access"] = d.Access
+	vars["ApacheAccess"] = ""
+	if len(d.Access) > 0 {
+		vars["ApacheAccess"] = strings.TrimRight(apacheAccess(d.Access, "        "), "\n")
+	}
+}
+
+// ManageAccess changes the access rules of a domain and regenerates its
+// configuration: action is allow or deny with a source, remove with a
+// source, or reset. Without an action the rules are shown.
+func ManageAccess(domainName, action, source string) {
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+
+	previous := append([]AccessRule(nil), d.Access...)
+	switch action {
+	case "":
+		showAccess(*d)
+		return
+	case "allow", "deny":
+		if source == "" {
+			ui.Invalid("Invalid access rule: %s needs an address, a network or all\n", action)
+			return
+		}
+		if source, err = parseAccessSource(source); err != nil {
+			ui.Invalid("Invalid access rule: %v\n", err)
+			return
+		}
+		d.Access = addAccessRule(d.Access, AccessRule{Action: action, Source: source})
+	case "remove":
+		if source, err = parseAccessSource(source); err != nil {
+			ui.Invalid("Invalid access rule: %v\n", err)
+			return
+		}
+		found := false
+		for i, r := range d.Access {
+			if r.Source == source {
+				d.Access = append(d.Access[:i:i], d.Access[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			ui.Fail("No access rule for %s configured for %s\n", source, domainName)
+			return
+		}
+	case "reset":
+		d.Access = nil
+	default:
+		ui.Invalid("Unknown access action: %s (use allow, deny, remove or reset)\n", action)
+		return
+	}
+
+	if err := saveDomain(*d); err != nil {
+		ui.Fail("❌ Could not save domain: %v\n", err)
+		return
+	}
+	if err := applyConfig(*d, false); err != nil {
+		d.Access = previous
+		if saveErr := saveDomain(*d); saveErr != nil {
+			ui.Warn("⚠️  Warning: Could not restore access rules: %v\n", saveErr)
+		}
+		ui.Fail("❌ Could not update access rules: %v\n", err)
+		return
+	}
+	reloadWebServers()
+
+	fmt.Printf("✅ Access rules of %s updated\n", domainName)
+	showAccess(*d)
+}
+
+func showAccess(d Domain) {
+	if len(d.Access) == 0 {
+		fmt.Printf("No access rules configured for %s (all clients allowed)\n", d.Name)
+		return
+	}
+	fmt.Printf("Access rules of %s (first match wins):\n", d.Name)
+	for _, r := range d.Access {
+		fmt.Printf("  %-5s %s\n", r.Action, r.Source)
+	}
+	if last := d.Access[len(d.Access)-1]; last.Source != "all" {
+		fmt.Println("  allow all (default)")
+	}
+}
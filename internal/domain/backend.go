@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/templates"
+)
+
+// webServer is a web server Nginx hands the domains of a backend to, the way
+// it proxies apache domains to Apache. Nginx keeps terminating SSL and adding
+// the headers, access rules and rate limits in front of it, so a web server
+// only serves the document root and PHP. Apache predates this and is wired
+// in directly; other servers implement webServer and register in webServers.
+type webServer interface {
+	// Backend is the name used with --backend, also the key of the server in
+	// config.json and in the reload and validation maps
+	Backend() string
+	// Label is the name shown in messages, e.g. "Caddy"
+	Label() string
+	// Unit is the systemd unit reloaded after vhost changes
+	Unit() string
+	// VhostPath is the file holding the vhost of a domain
+	VhostPath(domainName string) string
+	// Render renders the vhost of a domain with the template variables
+	Render(vars map[string]interface{}) (string, error)
+	// Test validates the live configuration
+	Test() error
+	// Port is the local port Nginx proxies the domains to
+	Port(cfg *config.Config) int
+}
+
+// webServers are the backends served behind Nginx besides Apache
+var webServers = []webServer{caddyServer{}}
+
+// backendServer returns the web server of a backend, if it has one
+func backendServer(backend string) (webServer, bool) {
+	for _, ws := range webServers {
+		if ws.Backend() == backend {
+			return ws, true
+		}
+	}
+	return nil, false
+}
+
+// checkBackendServer reports why a backend served behind Nginx can't be used
+// on this server
+func checkBackendServer(backend string) error {
+	ws, ok := backendServer(backend)
+	if !ok {
+		return nil
+	}
+	cfg, err := config.Load()
+	if err != nil || cfg == nil {
+		cfg = config.DefaultConfig()
+	}
+	if !cfg.IsInstalled("nginx") || apacheStandalone(cfg) {
+		return fmt.Errorf("the %s backend runs behind Nginx, which is not installed", backend)
+	}
+	if !cfg.IsInstalled(backend) {
+		return fmt.Errorf("%s is not installed (sudo webstack install %s)", ws.Label(), backend)
+	}
+	return nil
+}
+
+// writeBackendConfig writes the vhost of a domain for a web server
+func writeBackendConfig(ws webServer, domainName, rendered string) error {
+	configFile := ws.VhostPath(domainName)
+	if err := dryrun.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
+		return fmt.Errorf("could not create %s: %v", filepath.Dir(configFile), err)
+	}
+	if err := dryrun.WriteFile(configFile, []byte(rendered), 0644); err != nil {
+		return fmt.Errorf("could not write %s config file: %v", strings.ToLower(ws.Label()), err)
+	}
+	fmt.Printf("✅ %s configuration created: %s\n", ws.Label(), configFile)
+	return nil
+}
+
+// caddyServer serves PHP domains with Caddy on a local port behind Nginx
+type caddyServer struct{}
+
+// CaddySitesDir holds one site file per domain, imported by the Caddyfile
+const CaddySitesDir = "/etc/caddy/sites"
+
+// CaddyDefaultPort is the local port Caddy listens on unless config.json
+// says otherwise
+const CaddyDefaultPort = 8082
+
+func (caddyServer) Backend() string { return "caddy" }
+func (caddyServer) Label() string   { return "Caddy" }
+func (caddyServer) Unit() string    { return "caddy" }
+
+func (caddyServer) VhostPath(domainName string) string {
+	return filepath.Join(CaddySitesDir, domainName+".conf")
+}
+
+func (caddyServer) Render(vars map[string]interface{}) (string, error) {
+	content, err := templates.GetTemplate("caddy/domain.conf")
+	if err != nil {
+		return "", fmt.Errorf("could not read caddy template (domain.conf): %v", err)
+	}
+	tmpl, err := template.New("caddy").Parse(string(content))
+	if err != nil {
+		return "", fmt.Errorf("could not parse caddy template: %v", err)
+	}
+	// Caddy takes the socket path without the unix: prefix
+	caddyVars := map[string]interface{}{}
+	for k, v := range vars {
+		caddyVars[k] = v
+	}
+	caddyVars["PHPSocketPath"] = strings.TrimPrefix(fmt.Sprint(vars["PHPSocket"]), "unix:")
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, caddyVars); err != nil {
+		return "", fmt.Errorf("could not execute caddy template: %v", err)
+	}
+	return buf.String(), nil
+}
+
+func (caddyServer) Test() error {
+	if _, err := exec.LookPath("caddy"); err != nil {
+		return nil // Not installed, nothing to validate
+	}
+	output, err := exec.Command("caddy", "validate", "--config", "/etc/caddy/Caddyfile", "--adapter", "caddyfile").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("caddy validate failed:\n%s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func (caddyServer) Port(cfg *config.Config) int {
+	if port := cfg.GetPort("caddy"); port != 0 {
+		return port
+	}
+	return CaddyDefaultPort
+}
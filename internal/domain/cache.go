@@ -0,0 +1,394 @@
+package domain
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"webstack-cli/internal/bytesize"
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/templates"
+	"webstack-cli/internal/ui"
+)
+
+// nginxCacheConf holds the shared FastCGI and proxy cache zones, loaded by
+// nginx.conf like the compression include
+const nginxCacheConf = "/etc/nginx/includes/cache.conf"
+
+// Directories of the cache zones defined in nginxCacheConf
+var cacheDirs = []string{"/var/cache/nginx/fastcgi", "/var/cache/nginx/proxy"}
+
+const defaultCacheTTL = "10m"
+
+// defaultBypassCookies are the session and login cookies of common PHP apps;
+// requests carrying one are never answered from or stored in the cache
+var defaultBypassCookies = []string{
+	"PHPSESSID", "SESS", "laravel_session", "wordpress_logged_in", "wp-postpass",
+	"comment_author", "woocommerce_items_in_cart", "woocommerce_cart_hash",
+}
+
+// inlineCacheZone matches the FastCGI cache zone and bypass map older
+// nginx.conf templates carried, which clash with the include
+var inlineCacheZone = regexp.MustCompile(`(?ms)^[ \t]*# FastCGI cache\n.*?^[ \t]*# Cache bypass\n.*?^[ \t]*\}[ \t]*\n`)
+
+var (
+	cacheTTLPattern   = regexp.MustCompile(`^[1-9][0-9]*[smhd]$`)
+	cookieNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+// CacheSettings is the response cache of a domain: Nginx caches PHP-FPM
+// responses (FastCGI cache) or the responses of Apache and upstream apps
+// (proxy cache) for TTL
+type CacheSettings struct {
+	TTL           string   `json:"ttl"`
+	BypassCookies []string `json:"bypass_cookies,omitempty"` // Cookies skipping the cache besides the defaults
+}
+
+// CacheOptions holds the settings of 'webstack cache enable'
+type CacheOptions struct {
+	TTL           string   // How long responses are cached, e.g. 10m (default 10m)
+	BypassCookies []string // Extra cookie names skipping the cache
+}
+
+// cacheRules are the cache settings of a domain as rendered into its vhost
+type cacheRules struct {
+	TTL     string
+	Cookies string // Regular expression matched against the Cookie header
+}
+
+// cacheZonesInstalled reports whether the shared cache zones are defined
+func cacheZonesInstalled() bool {
+	_, err := os.Stat(nginxCacheConf)
+	return err == nil
+}
+
+// cacheVars returns the cache rules of a domain, or nil when the domain is
+// not cached or the cache zones are missing
+func cacheVars(d Domain) *cacheRules {
+	if d.Cache == nil || !cacheZonesInstalled() {
+		return nil
+	}
+	return newCacheRules(*d.Cache)
+}
+
+func newCacheRules(settings CacheSettings) *cacheRules {
+	var names []string
+	for _, name := range append(append([]string{}, defaultBypassCookies...), settings.BypassCookies...) {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	return &cacheRules{TTL: settings.TTL, Cookies: strings.Join(names, "|")}
+}
+
+// writeNginxCacheZones writes the shared cache zones, moving the zone older
+// nginx.conf templates defined inline into the include. Both files are
+// restored when nginx rejects the result.
+func writeNginxCacheZones() error {
+	original, readErr := os.ReadFile("/etc/nginx/nginx.conf")
+	if readErr == nil {
+		data := original
+		if inlineCacheZone.Match(data) {
+			data = inlineCacheZone.ReplaceAll(data, nil)
+		}
+		if strings.Contains(string(data), "keys_zone=fastcgi_cache:") {
+			return fmt.Errorf("nginx.conf defines its own fastcgi_cache zone; remove it to let webstack manage the cache")
+		}
+		if len(data) != len(original) {
+			if err := dryrun.WriteFile("/etc/nginx/nginx.conf", data, 0644); err != nil {
+				return fmt.Errorf("could not move the FastCGI cache zone out of nginx.conf: %v", err)
+			}
+		}
+	}
+
+	content, err := templates.GetNginxTemplate("cache.conf")
+	if err != nil {
+		return fmt.Errorf("could not read nginx cache template: %v", err)
+	}
+	for _, dir := range append([]string{filepath.Dir(nginxCacheConf)}, cacheDirs...) {
+		if err := dryrun.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("could not create %s: %v", dir, err)
+		}
+	}
+	if err := dryrun.WriteFile(nginxCacheConf, content, 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", nginxCacheConf, err)
+	}
+
+	if err := testWebServers(map[string]bool{"nginx": true}); err != nil {
+		dryrun.Remove(nginxCacheConf)
+		if readErr == nil {
+			dryrun.WriteFile("/etc/nginx/nginx.conf", original, 0644)
+		}
+		return fmt.Errorf("cache zones rejected by nginx: %v", err)
+	}
+	return nil
+}
+
+// EnableCache turns on the Nginx response cache of a domain, installing the
+// shared cache zones first when needed. Running it again changes the TTL or
+// the bypass cookies.
+func EnableCache(domainName string, opts CacheOptions) {
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+	if d.Backend == "static" {
+		ui.Fail("❌ %s is a static site; its files are served from disk and need no cache\n", d.Name)
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil || cfg == nil {
+		cfg = config.DefaultConfig()
+	}
+	if nginxTemplate, _ := vhostLayout(*d, cfg); nginxTemplate == "" {
+		ui.Fail("❌ Caching needs Nginx in front of %s\n", d.Name)
+		return
+	}
+
+	ttl := opts.TTL
+	if ttl == "" {
+		ttl = defaultCacheTTL
+	}
+	if !cacheTTLPattern.MatchString(ttl) {
+		ui.Invalid("Invalid TTL: %s (use a number with s, m, h or d, e.g. 10m)\n", ttl)
+		return
+	}
+	for _, name := range opts.BypassCookies {
+		if !cookieNamePattern.MatchString(name) {
+			ui.Invalid("Invalid cookie name: %s\n", name)
+			return
+		}
+	}
+
+	if !cacheZonesInstalled() {
+		if err := writeNginxCacheZones(); err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		fmt.Printf("✅ Cache zones written: %s\n", nginxCacheConf)
+	}
+
+	previous := d.Cache
+	d.Cache = &CacheSettings{TTL: ttl, BypassCookies: opts.BypassCookies}
+	if previous != nil && len(opts.BypassCookies) == 0 {
+		d.Cache.BypassCookies = previous.BypassCookies
+	}
+	if err := saveDomain(*d); err != nil {
+		ui.Fail("❌ Could not save domain: %v\n", err)
+		return
+	}
+	if err := applyConfig(*d, false); err != nil {
+		d.Cache = previous
+		if saveErr := saveDomain(*d); saveErr != nil {
+			ui.Warn("⚠️  Warning: Could not restore domain entry: %v\n", saveErr)
+		}
+		ui.Fail("❌ Could not enable the cache for %s: %v\n", d.Name, err)
+		return
+	}
+	reloadWebServers()
+
+	fmt.Printf("✅ Cache enabled for %s (TTL %s)\n", d.Name, ttl)
+	fmt.Printf("   Bypassed for requests with an Authorization header or these cookies: %s\n", strings.Join(append(append([]string{}, defaultBypassCookies...), d.Cache.BypassCookies...), ", "))
+	fmt.Println("   Responses show the cache result in the X-Cache-Status header")
+}
+
+// DisableCache turns off the response cache of a domain and purges its
+// cached responses
+func DisableCache(domainName string) {
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+	if d.Cache == nil {
+		fmt.Printf("ℹ️  The cache is not enabled for %s\n", d.Name)
+		return
+	}
+
+	previous := d.Cache
+	d.Cache = nil
+	if err := saveDomain(*d); err != nil {
+		ui.Fail("❌ Could not save domain: %v\n", err)
+		return
+	}
+	if err := applyConfig(*d, false); err != nil {
+		d.Cache = previous
+		if saveErr := saveDomain(*d); saveErr != nil {
+			ui.Warn("⚠️  Warning: Could not restore domain entry: %v\n", saveErr)
+		}
+		ui.Fail("❌ Could not disable the cache for %s: %v\n", d.Name, err)
+		return
+	}
+	reloadWebServers()
+
+	removed, err := purgeCache(*d, "")
+	if err != nil {
+		ui.Warn("⚠️  Warning: Could not purge the cache: %v\n", err)
+	}
+	fmt.Printf("✅ Cache disabled for %s (%d cached responses removed)\n", d.Name, removed)
+}
+
+// PurgeCache removes the cached responses of a domain, all of them or those
+// of one path. A path ending in * removes every URL starting with it.
+func PurgeCache(domainName, path string) {
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+	if path != "" && !strings.HasPrefix(path, "/") {
+		ui.Invalid("Invalid path: %s (must start with /)\n", path)
+		return
+	}
+
+	removed, err := purgeCache(*d, path)
+	if err != nil {
+		ui.Fail("❌ Could not purge the cache: %v\n", err)
+		return
+	}
+	target := d.Name
+	if path != "" {
+		target += path
+	}
+	fmt.Printf("✅ Purged %d cached responses for %s\n", removed, target)
+}
+
+// purgeCache deletes the cache files whose key belongs to the domain (and
+// path). Nginx treats a deleted file as a miss and fetches the page again.
+func purgeCache(d Domain, path string) (int, error) {
+	hosts := map[string]bool{d.Name: true}
+	if _, alias := canonicalHosts(d); alias != "" {
+		hosts[alias] = true
+	}
+
+	removed := 0
+	for _, dir := range cacheDirs {
+		err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			host, uri, ok := readCacheKey(file)
+			if !ok || !hosts[host] || !matchCachePath(uri, path) {
+				return nil
+			}
+			if err := dryrun.Remove(file); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			removed++
+			return nil
+		})
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// readCacheKey returns the host and request URI of a cache file, whose
+// header holds the key "<method> <scheme>://<host><uri>"
+func readCacheKey(file string) (string, string, bool) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", "", false
+	}
+	defer f.Close()
+
+	header := make([]byte, 4096)
+	n, _ := io.ReadFull(f, header)
+	_, key, ok := strings.Cut(string(header[:n]), "\nKEY: ")
+	if !ok {
+		return "", "", false
+	}
+	if end := strings.IndexByte(key, '\n'); end >= 0 {
+		key = key[:end]
+	}
+
+	_, url, ok := strings.Cut(key, " ")
+	if !ok {
+		return "", "", false
+	}
+	_, rest, ok := strings.Cut(url, "://")
+	if !ok {
+		return "", "", false
+	}
+	host, uri := rest, "/"
+	if slash := strings.IndexByte(rest, '/'); slash >= 0 {
+		host, uri = rest[:slash], rest[slash:]
+	}
+	return strings.ToLower(host), uri, true
+}
+
+// matchCachePath reports whether a cached URI is covered by a purge path
+func matchCachePath(uri, path string) bool {
+	if path == "" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(path, "*"); ok {
+		return strings.HasPrefix(uri, prefix)
+	}
+	return uri == path || strings.HasPrefix(uri, path+"?")
+}
+
+// CacheStatus shows whether the cache zones are installed, their size on
+// disk and the cached domains
+func CacheStatus() {
+	fmt.Println("Response cache")
+	fmt.Println("==============")
+	if cacheZonesInstalled() {
+		fmt.Printf("Zones: %s\n", nginxCacheConf)
+	} else {
+		fmt.Println("Zones: not installed (written by the first 'webstack cache enable')")
+	}
+	for _, dir := range cacheDirs {
+		files, size := dirUsage(dir)
+		fmt.Printf("  %-26s %d responses, %s\n", dir, files, bytesize.Format(size))
+	}
+
+	domains, err := loadDomains()
+	if err != nil {
+		ui.Fail("❌ Could not load domains: %v\n", err)
+		return
+	}
+	var cached []Domain
+	for _, d := range domains {
+		if d.Cache != nil {
+			cached = append(cached, d)
+		}
+	}
+	if len(cached) == 0 {
+		fmt.Println("No cached domains (enable one with: webstack cache enable <domain> --ttl 10m)")
+		return
+	}
+	sort.Slice(cached, func(i, j int) bool { return cached[i].Name < cached[j].Name })
+	fmt.Println("Cached domains:")
+	for _, d := range cached {
+		line := fmt.Sprintf("  %s: TTL %s", d.Name, d.Cache.TTL)
+		if len(d.Cache.BypassCookies) > 0 {
+			line += ", bypass cookies " + strings.Join(d.Cache.BypassCookies, ", ")
+		}
+		fmt.Println(line)
+	}
+}
+
+func dirUsage(dir string) (int, int64) {
+	files := 0
+	var size int64
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			files++
+			size += info.Size()
+		}
+		return nil
+	})
+	return files, size
+}
@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"text/template"
+	"webstack-cli/internal/templates"
+	"webstack-cli/internal/ui"
+)
+
+// canonicalHosts returns the host a domain is served under and the other of
+// its apex and www names, which the vhost also answers to redirect it ("" when
+// the domain has no canonical host set). The domain name itself is one of
+// the two.
+func canonicalHosts(d Domain) (string, string) {
+	if d.Canonical == "" {
+		return d.Name, ""
+	}
+
+	apex := strings.TrimPrefix(d.Name, "www.")
+	www := "www." + apex
+	if d.Canonical == "www" {
+		return www, apex
+	}
+	return apex, www
+}
+
+// forceHTTPS reports whether the plain HTTP listener of an SSL domain
+// redirects to HTTPS, which it does unless the domain opted out
+func forceHTTPS(d Domain) bool {
+	return d.ForceHTTPS == nil || *d.ForceHTTPS
+}
+
+// parseCanonical parses a --canonical value: www, apex or none
+func parseCanonical(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "www":
+		return "www", nil
+	case "apex", "non-www":
+		return "apex", nil
+	case "none", "off", "default":
+		return "", nil
+	}
+	return "", fmt.Errorf("%s (use www, apex or none)", value)
+}
+
+// redirectVars adds the HTTPS and canonical host redirect settings of a
+// domain to the template variables
+func redirectVars(d Domain, vars map[string]interface{}) {
+	canonical, alias := canonicalHosts(d)
+	vars["ForceHTTPS"] = forceHTTPS(d)
+	vars["CanonicalHost"] = canonical
+	vars["CanonicalPattern"] = regexp.QuoteMeta(canonical)
+	vars["AliasHost"] = alias
+}
+
+// renderPlainVhost renders the plain HTTP template matching an SSL template.
+// SSL domains that do not force HTTPS serve port 80 with it.
+func renderPlainVhost(server, sslTemplate string, vars map[string]interface{}) (string, error) {
+	name := strings.TrimSuffix(sslTemplate, "-ssl.conf") + ".conf"
+	content, err := templates.GetTemplate(server + "/" + name)
+	if err != nil {
+		return "", fmt.Errorf("could not read %s template (%s): %v", server, name, err)
+	}
+	tmpl, err := template.New(server).Parse(string(content))
+	if err != nil {
+		return "", fmt.Errorf("could not parse %s template: %v", server, err)
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("could not execute %s template: %v", server, err)
+	}
+	return buf.String() + "\n\n", nil
+}
+
+// warnCertificateNames warns when the certificate of an SSL domain does not
+// cover the name that redirects to the canonical host, as browsers reject
+// the HTTPS redirect then
+func warnCertificateNames(d Domain) {
+	_, alias := canonicalHosts(d)
+	if alias == "" || !d.SSLEnabled || d.SSLCertPath == "" {
+		return
+	}
+	data, err := ioutil.ReadFile(d.SSLCertPath)
+	if err != nil {
+		return
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil || cert.VerifyHostname(alias) == nil {
+		return
+	}
+	ui.Warn("⚠️  Warning: The certificate of %s does not cover %s, so HTTPS requests to it fail before the redirect\n", d.Name, alias)
+	fmt.Printf("   Reissue it with: webstack ssl enable %s --san %s\n", d.Name, alias)
+}
@@ -0,0 +1,335 @@
+package domain
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/osinfo"
+	"webstack-cli/internal/pkg"
+	"webstack-cli/internal/templates"
+	"webstack-cli/internal/ui"
+)
+
+// CompressionKey is the global setting compressing responses with gzip and,
+// where the module is installed, Brotli. Compression is on unless disabled.
+const CompressionKey = "compression"
+
+// nginxCompressionConf is the shared compression include loaded by nginx.conf
+const nginxCompressionConf = "/etc/nginx/includes/compression.conf"
+
+// apacheCompressionConf is the shared compression include loaded by
+// apache2.conf (httpd.conf on RHEL)
+func apacheCompressionConf() string {
+	return filepath.Join(osinfo.Current().ApacheDir, "includes", "compression.conf")
+}
+
+// nginxBrotliPackages are the Debian/Ubuntu packages of the Brotli module
+var nginxBrotliPackages = []string{"libnginx-mod-http-brotli-filter", "libnginx-mod-http-brotli-static"}
+
+// inlineGzip matches the gzip settings older nginx.conf templates carried,
+// which clash with the include
+var inlineGzip = regexp.MustCompile(`(?m)^[ \t]*(?:# Compression[ \t]*|gzip\w*[ \t].*;[ \t]*)\n`)
+
+// CompressionOptions holds the settings of 'webstack server compression'
+type CompressionOptions struct {
+	Domain   string // Opt a single domain in or out instead of the server
+	NoBrotli bool   // Do not install the Nginx Brotli module
+}
+
+// compressionEnabled reports whether compression is on server-wide
+func compressionEnabled(cfg *config.Config) bool {
+	if _, ok := cfg.Defaults[CompressionKey]; !ok {
+		return true
+	}
+	return cfg.GetBool(CompressionKey)
+}
+
+// nginxBrotliLoaded reports whether the Nginx Brotli filter module is enabled
+func nginxBrotliLoaded() bool {
+	matches, _ := filepath.Glob("/etc/nginx/modules-enabled/*brotli*")
+	return len(matches) > 0
+}
+
+// apacheBrotliAvailable reports whether Apache ships mod_brotli (2.4.26+)
+func apacheBrotliAvailable() bool {
+	module := "/etc/apache2/mods-available/brotli.load"
+	if osinfo.Current().IsRHEL() {
+		module = "/etc/httpd/modules/mod_brotli.so"
+	}
+	_, err := os.Stat(module)
+	return err == nil
+}
+
+// WriteNginxCompression writes the shared Nginx compression include, or
+// removes it when compression is disabled
+func WriteNginxCompression() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("could not load server config: %v", err)
+	}
+	return writeNginxCompression(compressionEnabled(cfg))
+}
+
+func writeNginxCompression(enabled bool) error {
+	if !enabled {
+		if err := dryrun.Remove(nginxCompressionConf); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not remove %s: %v", nginxCompressionConf, err)
+		}
+		return nil
+	}
+
+	// gzip directives left in nginx.conf would be duplicates of the include
+	if data, err := os.ReadFile("/etc/nginx/nginx.conf"); err == nil && inlineGzip.Match(data) {
+		if err := dryrun.WriteFile("/etc/nginx/nginx.conf", inlineGzip.ReplaceAll(data, nil), 0644); err != nil {
+			return fmt.Errorf("could not move gzip settings out of nginx.conf: %v", err)
+		}
+	}
+	return writeCompressionConf("nginx", nginxCompressionConf, nginxBrotliLoaded())
+}
+
+// WriteApacheCompression enables mod_deflate (and mod_brotli where Apache
+// ships it) and writes the shared Apache compression include, or disables
+// them when compression is disabled
+func WriteApacheCompression() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("could not load server config: %v", err)
+	}
+	return writeApacheCompression(compressionEnabled(cfg))
+}
+
+func writeApacheCompression(enabled bool) error {
+	modules := []string{"deflate"}
+	if apacheBrotliAvailable() {
+		modules = append(modules, "brotli")
+	}
+
+	if !enabled {
+		// Debian enables mod_deflate with its own compression rules, so the
+		// module has to go as well
+		if !osinfo.Current().IsRHEL() {
+			dryrun.Run(exec.Command("a2dismod", append([]string{"-q", "-f"}, modules...)...))
+		}
+		if err := dryrun.Remove(apacheCompressionConf()); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not remove %s: %v", apacheCompressionConf(), err)
+		}
+		return nil
+	}
+
+	if err := EnableApacheModules(modules...); err != nil {
+		return fmt.Errorf("could not enable %s: %v", strings.Join(modules, ", "), err)
+	}
+	return writeCompressionConf("apache", apacheCompressionConf(), apacheBrotliAvailable())
+}
+
+func writeCompressionConf(server, path string, brotli bool) error {
+	content, err := templates.GetTemplate(server + "/compression.conf")
+	if err != nil {
+		return fmt.Errorf("could not read %s compression template: %v", server, err)
+	}
+	tmpl, err := template.New("compression").Parse(string(content))
+	if err != nil {
+		return fmt.Errorf("could not parse %s compression template: %v", server, err)
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, map[string]interface{}{"Brotli": brotli}); err != nil {
+		return fmt.Errorf("could not render %s compression template: %v", server, err)
+	}
+
+	if err := dryrun.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("could not create %s: %v", filepath.Dir(path), err)
+	}
+	if err := dryrun.WriteFile(path, []byte(buf.String()), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", path, err)
+	}
+	return nil
+}
+
+// installNginxBrotli installs the Nginx Brotli module when the distribution
+// packages it
+func installNginxBrotli() {
+	if nginxBrotliLoaded() {
+		return
+	}
+	if exec.Command("apt-cache", "show", nginxBrotliPackages[0]).Run() != nil {
+		fmt.Println("ℹ️  The Nginx Brotli module is not packaged for this distribution, using gzip only")
+		return
+	}
+
+	fmt.Println("📦 Installing the Nginx Brotli module...")
+	if err := pkg.Install(nginxBrotliPackages...); err != nil {
+		ui.Warn("⚠️  Warning: Could not install the Nginx Brotli module, using gzip only: %v\n", err)
+	}
+}
+
+// EnableCompression turns compression on server-wide, installing the Nginx
+// Brotli module where available, or lifts a domain's opt-out
+func EnableCompression(opts CompressionOptions) {
+	if opts.Domain != "" {
+		setDomainCompression(opts.Domain, true)
+		return
+	}
+	setCompression(true, opts)
+}
+
+// DisableCompression turns compression off server-wide, or opts a domain
+// out of it
+func DisableCompression(opts CompressionOptions) {
+	if opts.Domain != "" {
+		setDomainCompression(opts.Domain, false)
+		return
+	}
+	setCompression(false, opts)
+}
+
+func setCompression(enabled bool, opts CompressionOptions) {
+	cfg, err := config.Load()
+	if err != nil {
+		ui.Fail("❌ Could not load config: %v\n", err)
+		return
+	}
+	if !cfg.IsInstalled("nginx") && !cfg.IsInstalled("apache") {
+		ui.Fail("❌ Neither Nginx nor Apache is installed\n")
+		return
+	}
+
+	err = config.Update(func(c *config.Config) error {
+		c.SetDefault(CompressionKey, enabled)
+		return nil
+	})
+	if err != nil {
+		ui.Fail("❌ Could not save config: %v\n", err)
+		return
+	}
+
+	servers := map[string]bool{}
+	brotliBefore := nginxBrotliLoaded()
+	if cfg.IsInstalled("nginx") {
+		if enabled && !opts.NoBrotli {
+			installNginxBrotli()
+		}
+		if err := writeNginxCompression(enabled); err != nil {
+			ui.Fail("❌ Nginx: %v\n", err)
+			return
+		}
+		servers["nginx"] = true
+	}
+	if cfg.IsInstalled("apache") {
+		if err := writeApacheCompression(enabled); err != nil {
+			ui.Fail("❌ Apache: %v\n", err)
+			return
+		}
+		servers["apache"] = true
+	}
+
+	// Opted-out domains render "brotli off" once the module is loaded
+	if nginxBrotliLoaded() != brotliBefore {
+		if domains, err := loadDomains(); err == nil {
+			for _, d := range domains {
+				if d.NoCompression {
+					if err := applyConfig(d, false); err != nil {
+						ui.Warn("⚠️  Warning: Could not update %s: %v\n", d.Name, err)
+					}
+				}
+			}
+		}
+	}
+
+	if err := testWebServers(servers); err != nil {
+		ui.Fail("❌ Configuration validation failed, web servers were not reloaded: %v\n", err)
+		return
+	}
+	reloadWebServers()
+
+	if !enabled {
+		fmt.Println("✅ Compression disabled")
+		return
+	}
+	methods := "gzip"
+	if (servers["nginx"] && nginxBrotliLoaded()) || (servers["apache"] && apacheBrotliAvailable()) {
+		methods = "Brotli and gzip"
+	}
+	fmt.Printf("✅ Compression enabled (%s)\n", methods)
+}
+
+func setDomainCompression(domainName string, enabled bool) {
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+	if d.NoCompression == !enabled {
+		fmt.Printf("ℹ️  Compression is already %s for %s\n", onOff(enabled), d.Name)
+		return
+	}
+
+	d.NoCompression = !enabled
+	if err := saveDomain(*d); err != nil {
+		ui.Fail("❌ Could not save domain: %v\n", err)
+		return
+	}
+	if err := applyConfig(*d, false); err != nil {
+		d.NoCompression = enabled
+		if saveErr := saveDomain(*d); saveErr != nil {
+			ui.Warn("⚠️  Warning: Could not restore domain entry: %v\n", saveErr)
+		}
+		ui.Fail("❌ Could not update %s: %v\n", d.Name, err)
+		return
+	}
+	reloadWebServers()
+
+	if enabled {
+		fmt.Printf("✅ Compression follows the server setting again for %s\n", d.Name)
+	} else {
+		fmt.Printf("✅ Compression disabled for %s\n", d.Name)
+	}
+}
+
+// CompressionStatus shows the server-wide compression setting, the methods
+// available to each web server and the domains that opted out
+func CompressionStatus() {
+	cfg, err := config.Load()
+	if err != nil {
+		ui.Fail("❌ Could not load config: %v\n", err)
+		return
+	}
+
+	fmt.Println("Compression")
+	fmt.Println("===========")
+	fmt.Printf("Server-wide: %s\n", onOff(compressionEnabled(cfg)))
+	if cfg.IsInstalled("nginx") {
+		brotli := "not installed ('webstack server compression enable' installs it)"
+		if nginxBrotliLoaded() {
+			brotli = "loaded"
+		}
+		fmt.Printf("Nginx:       gzip, Brotli module %s\n", brotli)
+	}
+	if cfg.IsInstalled("apache") {
+		brotli := "not available"
+		if apacheBrotliAvailable() {
+			brotli = "available"
+		}
+		fmt.Printf("Apache:      deflate, mod_brotli %s\n", brotli)
+	}
+
+	domains, err := loadDomains()
+	if err != nil {
+		ui.Fail("❌ Could not load domains: %v\n", err)
+		return
+	}
+	var optedOut []string
+	for _, d := range domains {
+		if d.NoCompression {
+			optedOut = append(optedOut, d.Name)
+		}
+	}
+	if len(optedOut) > 0 {
+		fmt.Printf("Opted out:   %s\n", strings.Join(optedOut, ", "))
+	}
+}
@@ -2,34 +2,85 @@ package domain
 
 import (
 	"bufio"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"text/template"
 	"webstack-cli/internal/config"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/notify"
+	"webstack-cli/internal/osinfo"
+	"webstack-cli/internal/prompt"
+	"webstack-cli/internal/service"
+	"webstack-cli/internal/store"
 	"webstack-cli/internal/templates"
+	"webstack-cli/internal/ui"
 )
 
 // Domain represents a domain configuration
 type Domain struct {
 	Name         string `json:"name"`
-	Backend      string `json:"backend"` // "nginx" or "apache"
-	PHPVersion   string `json:"php_version"`
+	Backend      string `json:"backend"` // "nginx", "apache", "static", "proxy" or a web server behind Nginx (e.g. "caddy")
+	Upstream     string `json:"upstream,omitempty"` // App URL or pool proxied to by the proxy backend, e.g. http://127.0.0.1:3000 or api
+	PHPVersion   string `json:"php_version,omitempty"` // Empty for static and proxy domains
 	DocumentRoot string `json:"document_root"`
+	DocRoot      string `json:"docroot,omitempty"` // Web root subfolder relative to htdocs, e.g. "public"
+	Home         string `json:"home,omitempty"`    // Folder holding htdocs, logs and configs; empty for /var/www/<name>
+	Preset       string `json:"preset,omitempty"`  // Framework preset: laravel, symfony, wordpress, nextcloud, roundcube, snappymail
+	Hardening    *bool  `json:"hardening,omitempty"` // Overrides the global harden_webroot setting
+	HTTP3        *bool  `json:"http3,omitempty"`     // Overrides the global http3 setting
+	NoCompression bool  `json:"no_compression,omitempty"` // Opts out of the server-wide gzip/Brotli compression
+	ForceHTTPS   *bool  `json:"force_https,omitempty"` // Redirect plain HTTP to HTTPS once SSL is enabled (default on)
+	Canonical    string `json:"canonical,omitempty"`   // Canonical host: "www" or "apex" (the other name redirects)
 	SSLEnabled   bool   `json:"ssl_enabled"`
 	SSLCertPath  string `json:"ssl_cert_path,omitempty"`  // Path to SSL certificate
 	SSLKeyPath   string `json:"ssl_key_path,omitempty"`   // Path to SSL private key
 	SSLEmail     string `json:"ssl_email,omitempty"`      // Email used for Let's Encrypt
+	Redirects    []Redirect `json:"redirects,omitempty"` // Per-domain HTTP redirects
+	Routes       []Route `json:"routes,omitempty"` // Sub-paths on another PHP version or document root
+	Protect      []Protection `json:"protect,omitempty"` // Paths behind HTTP basic auth
+	Access       []AccessRule `json:"access,omitempty"`  // IP allow/deny rules, first match wins
+	RateLimit    *RateLimit `json:"rate_limit,omitempty"` // Nginx request and connection limits per client IP
+	Headers      *SecurityHeaders `json:"headers,omitempty"` // Security header overrides
+	Cache        *CacheSettings `json:"cache,omitempty"` // Nginx response cache ('webstack cache')
+	PHPSettings  map[string]string `json:"php_settings,omitempty"` // php.ini overrides applied through a dedicated PHP-FPM pool
+	Vars         map[string]string `json:"vars,omitempty"` // Custom template variables ('webstack domain var'), exposed as .Vars
+	SQLite       []string `json:"sqlite,omitempty"` // SQLite databases ('webstack db database create sqlite'), files in <home>/databases
 }
 
 const domainsFile = "/etc/webstack/domains.json"
 
+// domainsStore locks domains.json and writes it atomically
+var domainsStore = store.New(domainsFile, 1)
+
+// AddOptions holds optional settings for a new domain
+type AddOptions struct {
+	DocRoot string // Web root subfolder relative to htdocs (e.g. "public" for Laravel/Symfony apps)
+	Root    string // Absolute document root outside the domain folder, e.g. /home/app/site/public
+	Preset  string // Framework preset applying the framework's recommended vhost rules
+	HTTP3   string // HTTP/3 (QUIC): "on", "off" or "default" (follow the http3 setting)
+	Upstream string // App URL for the proxy backend, e.g. http://127.0.0.1:3000
+}
+
+// EditOptions holds optional settings changed on an existing domain
+type EditOptions struct {
+	DocRoot   string // Web root subfolder relative to htdocs ("." for htdocs itself)
+	Root      string // Absolute document root outside the domain folder
+	Hardening string // Webroot hardening: "on", "off" or "default" (follow harden_webroot)
+	HTTP3     string // HTTP/3 (QUIC): "on", "off" or "default" (follow the http3 setting)
+	ForceHTTPS string // Redirect HTTP to HTTPS once SSL is enabled: "on", "off" or "default" (on)
+	Canonical string // Canonical host: "www", "apex" or "none"
+	Upstream  string // App URL for the proxy backend
+}
+
 // Add creates a new domain configuration
-func Add(domainName, backend, phpVersion string) {
+func Add(domainName, backend, phpVersion string, opts AddOptions) {
+	domainName = Normalize(domainName)
+	if !ValidName(domainName) {
+		ui.Invalid("Invalid domain name: %q\n", domainName)
+		return
+	}
 	fmt.Printf("Adding domain: %s\n", domainName)
 
 	// Interactive prompts if flags not provided
@@ -37,88 +88,190 @@ func Add(domainName, backend, phpVersion string) {
 		backend = promptBackend()
 	}
 
-	if phpVersion == "" {
+	// Static and proxy domains don't use PHP
+	if phpVersion == "" && backendUsesPHP(backend) {
 		phpVersion = promptPHPVersion()
 	}
 
 	// Validate inputs
 	if !isValidBackend(backend) {
-		fmt.Printf("Invalid backend: %s. Must be 'nginx' or 'apache'\n", backend)
+		ui.Invalid("Invalid backend: %s. Must be 'nginx', 'apache', 'static', 'proxy' or 'caddy'\n", backend)
+		return
+	}
+	if err := checkBackendServer(backend); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+
+	if !backendUsesPHP(backend) && (phpVersion != "" || opts.Preset != "") {
+		ui.Invalid("Invalid options: %s domains don't use --php or --preset\n", backend)
+		return
+	}
+
+	upstream := ""
+	if backend == "proxy" {
+		if opts.Upstream == "" {
+			ui.Invalid("Invalid upstream: the proxy backend needs --upstream (e.g. http://127.0.0.1:3000 or an upstream name)\n")
+			return
+		}
+		var err error
+		if upstream, err = parseUpstream(opts.Upstream); err != nil {
+			ui.Invalid("Invalid upstream: %v\n", err)
+			return
+		}
+		if cfg, err := config.Load(); err == nil && cfg != nil && apacheStandalone(cfg) {
+			ui.Fail("❌ The proxy backend needs Nginx, which is not installed\n")
+			return
+		}
+	} else if opts.Upstream != "" {
+		ui.Invalid("Invalid upstream: --upstream is only used with --backend proxy\n")
+		return
+	} else if backendUsesPHP(backend) && !isValidPHPVersion(phpVersion) {
+		ui.Invalid("Invalid PHP version: %s\n", phpVersion)
+		return
+	}
+
+	if opts.Preset != "" && !isValidPreset(opts.Preset) {
+		ui.Invalid("Invalid preset: %s. Available: %s\n", opts.Preset, strings.Join(templates.ListPresets(), ", "))
 		return
 	}
 
-	if !isValidPHPVersion(phpVersion) {
-		fmt.Printf("Invalid PHP version: %s\n", phpVersion)
+	http3, err := parseHTTP3(opts.HTTP3)
+	if opts.HTTP3 != "" && err != nil {
+		ui.Invalid("Invalid HTTP/3 value: %v\n", err)
 		return
 	}
 
-	// Set up domain directory structure
-	baseDir := fmt.Sprintf("/var/www/%s", domainName)
+	customRoot := ""
+	if opts.Root != "" {
+		if opts.DocRoot != "" || !backendUsesPHP(backend) && backend != "static" {
+			ui.Invalid("Invalid options: --root replaces --docroot and is not used by proxy domains\n")
+			return
+		}
+		if customRoot, err = CleanFolder(opts.Root); err != nil {
+			ui.Invalid("Invalid document root: %v\n", err)
+			return
+		}
+	}
+
+	docRoot := opts.DocRoot
+	if docRoot == "" && customRoot == "" {
+		docRoot = presetDocRoots[opts.Preset]
+	}
+	docRoot, err = normalizeDocRoot(docRoot)
+	if err != nil {
+		ui.Invalid("Invalid document root: %v\n", err)
+		return
+	}
+
+	// Set up domain directory structure under the defaults.webroot folder
+	webRoot := config.FallbackWebRoot
+	if cfg, err := config.Load(); err == nil && cfg != nil {
+		webRoot = cfg.WebRoot()
+	}
+	baseDir := filepath.Join(webRoot, domainName)
 	htdocsDir := filepath.Join(baseDir, "htdocs")
-	
+
 	domain := Domain{
 		Name:         domainName,
 		Backend:      backend,
+		Upstream:     upstream,
 		PHPVersion:   phpVersion,
-		DocumentRoot: htdocsDir, // Point to htdocs as the web root
+		DocumentRoot: filepath.Join(htdocsDir, docRoot), // Point to htdocs (or a subfolder) as the web root
+		DocRoot:      docRoot,
+		Home:         baseDir,
+		Preset:       opts.Preset,
+		HTTP3:        http3,
 		SSLEnabled:   false,
 	}
 
-	// Create directory structure: /var/www/domain/{ htdocs, logs, configs, error }
+	// An existing custom root keeps its files; only an empty one gets an index page
+	writeIndex := true
+	if customRoot != "" {
+		domain.DocumentRoot = customRoot
+		writeIndex = isEmptyDir(customRoot)
+	}
+
+	// Create directory structure: <webroot>/domain/{ htdocs, logs, configs, error }
 	dirs := []string{
-		htdocsDir,
+		domain.DocumentRoot,
 		filepath.Join(baseDir, "logs"),
 		filepath.Join(baseDir, "configs"),
 		filepath.Join(baseDir, "error"),
 	}
 
 	for _, dir := range dirs {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			fmt.Printf("Error creating directory %s: %v\n", dir, err)
+		if err := dryrun.MkdirAll(dir, 0755); err != nil {
+			ui.Fail("Error creating directory %s: %v\n", dir, err)
 			return
 		}
 	}
 
 	fmt.Printf("📁 Created domain directory structure:\n")
-	fmt.Printf("   %s/htdocs     - Web root (public files)\n", baseDir)
+	if customRoot != "" {
+		fmt.Printf("   %s - Web root (--root)\n", customRoot)
+	} else if docRoot != "" {
+		fmt.Printf("   %s/htdocs     - Application files (web root: htdocs/%s)\n", baseDir, docRoot)
+	} else {
+		fmt.Printf("   %s/htdocs     - Web root (public files)\n", baseDir)
+	}
 	fmt.Printf("   %s/logs       - Log files\n", baseDir)
-	fmt.Printf("   %s/configs    - Additional nginx configurations\n", baseDir)
+	fmt.Printf("   %s/configs    - Custom nginx/apache snippets (webstack domain config edit)\n", baseDir)
 	fmt.Printf("   %s/error      - Error pages symlink\n", baseDir)
 
-	// Create default index.php
-	createDefaultIndex(domain.DocumentRoot, domainName, phpVersion)
+	// Create default index.php (index.html for static sites)
+	if backend == "static" && writeIndex {
+		createStaticIndex(domain.DocumentRoot, domainName)
+	} else if backend != "proxy" && writeIndex {
+		createDefaultIndex(domain.DocumentRoot, domainName, phpVersion)
+	}
 
 	// Create error folder (error pages served from /etc/webstack/error/)
-	os.MkdirAll(filepath.Join(baseDir, "error"), 0755)
+	dryrun.MkdirAll(filepath.Join(baseDir, "error"), 0755)
 
 	// Save domain configuration
 	if err := saveDomain(domain); err != nil {
-		fmt.Printf("Error saving domain: %v\n", err)
+		ui.Fail("Error saving domain: %v\n", err)
 		return
 	}
 
-	// Generate web server configuration
-	if err := generateConfig(domain); err != nil {
-		fmt.Printf("Error generating configuration: %v\n", err)
+	// Generate and validate web server configuration
+	if err := applyConfig(domain, false); err != nil {
+		ui.Fail("Error generating configuration: %v\n", err)
+		if err := removeDomainEntry(domainName); err != nil {
+			ui.Warn("⚠️  Warning: Could not remove domain entry: %v\n", err)
+		}
 		return
 	}
 
 	// Reload web servers
 	reloadWebServers()
+	smokeTest(domain)
 
 	fmt.Printf("✅ Domain %s added successfully\n", domainName)
+	notify.Send(notify.DomainAdded, domainName, "Domain "+domainName+" added", fmt.Sprintf("Backend %s, document root %s", backend, domain.DocumentRoot))
 	fmt.Printf("   Backend: %s\n", backend)
-	fmt.Printf("   PHP Version: %s\n", phpVersion)
+	if backend == "proxy" {
+		fmt.Printf("   Upstream: %s\n", upstream)
+		return
+	}
+	if phpVersion != "" {
+		fmt.Printf("   PHP Version: %s\n", phpVersion)
+	}
 	fmt.Printf("   Document Root: %s\n", domain.DocumentRoot)
+	if domain.Preset != "" {
+		fmt.Printf("   Preset: %s (version %s)\n", domain.Preset, presetVersion(domain.Preset))
+	}
 }
 
 // Edit modifies an existing domain configuration
-func Edit(domainName, backend, phpVersion string) {
+func Edit(domainName, backend, phpVersion string, opts EditOptions) {
+	domainName = Normalize(domainName)
 	fmt.Printf("Editing domain: %s\n", domainName)
 
 	domains, err := loadDomains()
 	if err != nil {
-		fmt.Printf("Error loading domains: %v\n", err)
+		ui.Fail("Error loading domains: %v\n", err)
 		return
 	}
 
@@ -126,30 +279,154 @@ func Edit(domainName, backend, phpVersion string) {
 	for i, domain := range domains {
 		if domain.Name == domainName {
 			found = true
+			previous := domain
 
 			// Update backend if provided
 			if backend != "" {
 				if !isValidBackend(backend) {
-					fmt.Printf("Invalid backend: %s\n", backend)
+					ui.Invalid("Invalid backend: %s\n", backend)
 					return
 				}
+				if backend != domain.Backend {
+					if err := checkBackendServer(backend); err != nil {
+						ui.Fail("❌ %v\n", err)
+						return
+					}
+				}
 				domains[i].Backend = backend
 			}
 
+			// Update the app URL of a proxy domain if provided
+			if opts.Upstream != "" {
+				upstream, err := parseUpstream(opts.Upstream)
+				if err != nil {
+					ui.Invalid("Invalid upstream: %v\n", err)
+					return
+				}
+				domains[i].Upstream = upstream
+			}
+			if domains[i].Backend == "proxy" && domains[i].Upstream == "" {
+				ui.Invalid("Invalid upstream: the proxy backend needs --upstream (e.g. http://127.0.0.1:3000 or an upstream name)\n")
+				return
+			}
+			if domains[i].Backend != "proxy" {
+				domains[i].Upstream = ""
+			}
+
+			// Static and proxy domains drop their PHP version and overrides;
+			// switching back to a PHP backend starts from the default version
+			if !usesPHP(domains[i]) {
+				domains[i].PHPVersion = ""
+				domains[i].PHPSettings = nil
+			} else if domains[i].PHPVersion == "" && phpVersion == "" {
+				domains[i].PHPVersion = DefaultPHPVersion()
+			}
+
 			// Update PHP version if provided
 			if phpVersion != "" {
+				if !usesPHP(domains[i]) {
+					ui.Invalid("Invalid PHP version: %s domains don't use PHP\n", domains[i].Backend)
+					return
+				}
 				if !isValidPHPVersion(phpVersion) {
-					fmt.Printf("Invalid PHP version: %s\n", phpVersion)
+					ui.Invalid("Invalid PHP version: %s\n", phpVersion)
 					return
 				}
 				domains[i].PHPVersion = phpVersion
 			}
 
+			// Update document root subfolder if provided ("." resets it to htdocs)
+			if opts.DocRoot != "" {
+				normalized, err := normalizeDocRoot(opts.DocRoot)
+				if err != nil {
+					ui.Invalid("Invalid document root: %v\n", err)
+					return
+				}
+				domains[i].DocRoot = normalized
+				domains[i].DocumentRoot = filepath.Join(domains[i].HomeDir(), "htdocs", normalized)
+				if err := dryrun.MkdirAll(domains[i].DocumentRoot, 0755); err != nil {
+					ui.Fail("Error creating directory %s: %v\n", domains[i].DocumentRoot, err)
+					return
+				}
+			}
+
+			// Serve a folder outside the domain's own with --root
+			if opts.Root != "" {
+				if opts.DocRoot != "" || domains[i].Backend == "proxy" {
+					ui.Invalid("Invalid options: --root replaces --docroot and is not used by proxy domains\n")
+					return
+				}
+				root, err := CleanFolder(opts.Root)
+				if err != nil {
+					ui.Invalid("Invalid document root: %v\n", err)
+					return
+				}
+				if info, err := os.Stat(root); err != nil || !info.IsDir() {
+					ui.Invalid("Invalid document root: %s is not an existing folder\n", root)
+					return
+				}
+				domains[i].DocumentRoot = root
+				domains[i].DocRoot = ""
+			}
+
+			// Override webroot hardening if provided
+			if opts.Hardening != "" {
+				hardening, err := parseOverride(opts.Hardening)
+				if err != nil {
+					ui.Invalid("Invalid hardening value: %v\n", err)
+					return
+				}
+				domains[i].Hardening = hardening
+			}
+
+			// Override HTTP/3 if provided
+			if opts.HTTP3 != "" {
+				http3, err := parseHTTP3(opts.HTTP3)
+				if err != nil {
+					ui.Invalid("Invalid HTTP/3 value: %v\n", err)
+					return
+				}
+				domains[i].HTTP3 = http3
+			}
+
+			// Override the HTTPS redirect if provided
+			if opts.ForceHTTPS != "" {
+				force, err := parseOverride(opts.ForceHTTPS)
+				if err != nil {
+					ui.Invalid("Invalid force-https value: %v\n", err)
+					return
+				}
+				domains[i].ForceHTTPS = force
+			}
+
+			// Set the canonical host if provided
+			if opts.Canonical != "" {
+				canonical, err := parseCanonical(opts.Canonical)
+				if err != nil {
+					ui.Invalid("Invalid canonical host: %v\n", err)
+					return
+				}
+				domains[i].Canonical = canonical
+			}
+
 			// Interactive prompts if no flags provided
-			if backend == "" && phpVersion == "" {
+			if backend == "" && phpVersion == "" && opts.DocRoot == "" && opts.Root == "" && opts.Hardening == "" && opts.HTTP3 == "" && opts.ForceHTTPS == "" && opts.Canonical == "" && opts.Upstream == "" {
+				if domain.Backend == "proxy" {
+					ui.Invalid("%s proxies to %s; change it with --upstream or --backend\n", domain.Name, domain.Upstream)
+					return
+				}
+				if domain.Backend == "static" {
+					ui.Invalid("%s is a static site; change it with --backend\n", domain.Name)
+					return
+				}
+
 				fmt.Printf("Current backend: %s\n", domain.Backend)
 				newBackend := promptBackend()
 				if newBackend != domain.Backend {
+					if !isValidBackend(newBackend) || newBackend == "proxy" {
+						ui.Invalid("Invalid backend: %s (use --backend proxy --upstream URL for proxy domains)\n", newBackend)
+						return
+					}
 					domains[i].Backend = newBackend
 				}
 
@@ -160,19 +437,58 @@ func Edit(domainName, backend, phpVersion string) {
 				}
 			}
 
+			// The new PHP version must be running before the vhost points at it
+			switchedPHP := usesPHP(domains[i]) && domains[i].PHPVersion != previous.PHPVersion
+			if switchedPHP {
+				if err := checkPHPFPM(domains[i].PHPVersion); err != nil {
+					ui.Fail("❌ Cannot switch %s to PHP %s: %v\n", domainName, domains[i].PHPVersion, err)
+					return
+				}
+			}
+
 			// Save updated configuration
 			if err := saveDomains(domains); err != nil {
-				fmt.Printf("Error saving domains: %v\n", err)
+				ui.Fail("Error saving domains: %v\n", err)
 				return
 			}
 
-			// Regenerate configuration
-			if err := generateConfig(domains[i]); err != nil {
-				fmt.Printf("Error generating configuration: %v\n", err)
+			// Move the dedicated PHP-FPM pool to the new PHP version, or
+			// remove it when the domain no longer uses PHP
+			movedPool := domains[i].PHPVersion != previous.PHPVersion && (len(domains[i].PHPSettings) > 0 || len(previous.PHPSettings) > 0)
+			if movedPool {
+				versions, err := writePHPPool(domains[i])
+				if err != nil {
+					ui.Warn("⚠️  Warning: Could not move PHP-FPM pool: %v\n", err)
+				}
+				reloadPHPFPM(versions)
+			}
+
+			// Make sure PHP-FPM answers on the new socket before the web
+			// servers are reloaded onto it
+			if switchedPHP {
+				if err := pingPHPFPM(domains[i]); err != nil {
+					ui.Fail("❌ %v\n", err)
+					restoreDomain(domains, i, previous, movedPool)
+					fmt.Printf("↩️  %s stays on PHP %s\n", domainName, previous.PHPVersion)
+					return
+				}
+			}
+
+			// Regenerate and validate configuration
+			if err := applyConfig(domains[i], false); err != nil {
+				ui.Fail("Error generating configuration: %v\n", err)
+				restoreDomain(domains, i, previous, movedPool)
 				return
 			}
 
+			if opts.HTTP3 != "" {
+				pruneHTTP3Tuning(domains)
+			}
+			if opts.Canonical != "" {
+				warnCertificateNames(domains[i])
+			}
 			reloadWebServers()
+			smokeTest(domains[i])
 
 			fmt.Printf("✅ Domain %s updated successfully\n", domainName)
 			break
@@ -180,17 +496,28 @@ func Edit(domainName, backend, phpVersion string) {
 	}
 
 	if !found {
-		fmt.Printf("Domain %s not found\n", domainName)
+		ui.Fail("Domain %s not found\n", domainName)
 	}
 }
 
+// DeleteOptions holds optional settings for deleting a domain
+type DeleteOptions struct {
+	KeepFolder bool // Keep the domain folder without asking
+}
+
 // Delete removes a domain configuration
 func Delete(domainName string) {
+	DeleteWithOptions(domainName, DeleteOptions{})
+}
+
+// DeleteWithOptions removes a domain configuration
+func DeleteWithOptions(domainName string, opts DeleteOptions) {
+	domainName = Normalize(domainName)
 	fmt.Printf("Deleting domain: %s\n", domainName)
 
 	domains, err := loadDomains()
 	if err != nil {
-		fmt.Printf("Error loading domains: %v\n", err)
+		ui.Fail("Error loading domains: %v\n", err)
 		return
 	}
 
@@ -202,17 +529,38 @@ func Delete(domainName string) {
 			// Remove configuration files
 			removeConfig(domain)
 
-			// Ask if user wants to delete the domain folder
-			baseDir := filepath.Join("/var/www", domainName)
-			reader := bufio.NewReader(os.Stdin)
-			fmt.Printf("Delete domain folder %s? (y/N): ", baseDir)
-			response, _ := reader.ReadString('\n')
-			response = strings.TrimSpace(strings.ToLower(response))
+			// Remove the dedicated PHP-FPM pool
+			domain.PHPSettings = nil
+			if versions, err := writePHPPool(domain); err != nil {
+				ui.Warn("⚠️  Warning: Could not remove PHP-FPM pool: %v\n", err)
+			} else {
+				reloadPHPFPM(versions)
+			}
+
+			removeLogrotate(domainName)
+			dryrun.RemoveAll(AuthDir(domainName))
+			if domain.RateLimit != nil {
+				removed := domain
+				removed.RateLimit = nil
+				writeRateLimitZones(&removed)
+			}
 
-			if response == "y" || response == "yes" {
+			// Ask if user wants to delete the domain folder; in safe mode
+			// (WEBSTACK_FORBID_DESTRUCTIVE) it is kept unless --force is given
+			baseDir := domain.HomeDir()
+			deleteFolder := false
+			if !opts.KeepFolder {
+				if prompt.ForbidDestructive() && !prompt.Force {
+					fmt.Printf("ℹ️  %s is set, pass --force to delete the domain folder\n", prompt.ForbidDestructiveEnv)
+				} else {
+					deleteFolder = prompt.Force || prompt.Confirm(fmt.Sprintf("Delete domain folder %s?", baseDir))
+				}
+			}
+
+			if deleteFolder {
 				// Delete the entire domain folder
-				if err := os.RemoveAll(baseDir); err != nil {
-					fmt.Printf("⚠️  Warning: Could not delete domain folder: %v\n", err)
+				if err := dryrun.RemoveAll(baseDir); err != nil {
+					ui.Warn("⚠️  Warning: Could not delete domain folder: %v\n", err)
 				} else {
 					fmt.Printf("✅ Domain folder deleted: %s\n", baseDir)
 				}
@@ -220,25 +568,30 @@ func Delete(domainName string) {
 				fmt.Printf("ℹ️  Domain folder preserved: %s\n", baseDir)
 				fmt.Printf("   Contains: htdocs/, logs/, configs/, error/\n")
 			}
+			if domain.CustomRoot() {
+				fmt.Printf("ℹ️  The document root %s (--root) was left in place\n", domain.DocumentRoot)
+			}
 
 			// Remove from domains slice
 			domains = append(domains[:i], domains[i+1:]...)
 
 			// Save updated domains
 			if err := saveDomains(domains); err != nil {
-				fmt.Printf("Error saving domains: %v\n", err)
+				ui.Fail("Error saving domains: %v\n", err)
 				return
 			}
 
+			pruneHTTP3Tuning(domains)
 			reloadWebServers()
 
 			fmt.Printf("✅ Domain %s deleted successfully\n", domainName)
+			notify.Send(notify.DomainRemoved, domainName, "Domain "+domainName+" deleted", "")
 			break
 		}
 	}
 
 	if !found {
-		fmt.Printf("Domain %s not found\n", domainName)
+		ui.Fail("Domain %s not found\n", domainName)
 	}
 }
 
@@ -246,7 +599,7 @@ func Delete(domainName string) {
 func List() {
 	domains, err := loadDomains()
 	if err != nil {
-		fmt.Printf("Error loading domains: %v\n", err)
+		ui.Fail("Error loading domains: %v\n", err)
 		return
 	}
 
@@ -264,68 +617,72 @@ func List() {
 		}
 		fmt.Printf("Domain: %s\n", domain.Name)
 		fmt.Printf("  Backend: %s\n", domain.Backend)
-		fmt.Printf("  PHP Version: %s\n", domain.PHPVersion)
-		fmt.Printf("  Document Root: %s\n", domain.DocumentRoot)
-		fmt.Printf("  SSL: %s\n", sslStatus)
-		fmt.Println()
-	}
-}
-
-// RebuildConfigs regenerates configuration files for all domains
-func RebuildConfigs() {
-	fmt.Println("🔄 Rebuilding all domain configurations...")
-	fmt.Println("==========================================")
-
-	domains, err := loadDomains()
-	if err != nil {
-		fmt.Printf("Error loading domains: %v\n", err)
-		return
-	}
-
-	if len(domains) == 0 {
-		fmt.Println("No domains configured")
-		return
-	}
-
-	successCount := 0
-	errorCount := 0
-
-	for _, domain := range domains {
-		fmt.Printf("\n📝 Rebuilding config for %s (%s)...\n", domain.Name, domain.Backend)
-
-		// Remove old configs
-		removeConfig(domain)
-
-		// Generate new configs
-		if err := generateConfig(domain); err != nil {
-			fmt.Printf("❌ Error generating configuration for %s: %v\n", domain.Name, err)
-			errorCount++
+		if domain.Backend == "proxy" {
+			fmt.Printf("  Upstream: %s\n", domain.Upstream)
 		} else {
-			fmt.Printf("✅ Configuration rebuilt for %s\n", domain.Name)
-			successCount++
+			if usesPHP(domain) {
+				fmt.Printf("  PHP Version: %s\n", domain.PHPVersion)
+			}
+			fmt.Printf("  Document Root: %s\n", domain.DocumentRoot)
 		}
-	}
-
-	// Reload web servers once after all configs are regenerated
-	reloadWebServers()
-
-	fmt.Println("\n==========================================")
-	fmt.Printf("✅ Rebuilt: %d domain(s)\n", successCount)
-	if errorCount > 0 {
-		fmt.Printf("❌ Failed: %d domain(s)\n", errorCount)
+		if domain.Preset != "" {
+			fmt.Printf("  Preset: %s\n", domain.Preset)
+		}
+		if domain.Hardening != nil {
+			fmt.Printf("  Webroot Hardening: %s (domain override)\n", onOff(*domain.Hardening))
+		}
+		if domain.HTTP3 != nil {
+			fmt.Printf("  HTTP/3: %s (domain override)\n", onOff(*domain.HTTP3))
+		}
+		if domain.NoCompression {
+			fmt.Println("  Compression: off (domain opt-out)")
+		}
+		if domain.ForceHTTPS != nil {
+			fmt.Printf("  Force HTTPS: %s (domain override)\n", onOff(*domain.ForceHTTPS))
+		}
+		if canonical, alias := canonicalHosts(domain); alias != "" {
+			fmt.Printf("  Canonical Host: %s (%s redirects)\n", canonical, alias)
+		}
+		fmt.Printf("  SSL: %s\n", sslStatus)
+		if len(domain.Routes) > 0 {
+			fmt.Printf("  Routes: %d\n", len(domain.Routes))
+		}
+		if domain.RateLimit != nil {
+			fmt.Printf("  Rate Limit: %d/s, burst %d ('webstack domain ratelimit %s')\n", domain.RateLimit.RPS, domain.RateLimit.Burst, domain.Name)
+		}
+		if len(domain.Access) > 0 {
+			fmt.Printf("  Access Rules: %d ('webstack domain access %s')\n", len(domain.Access), domain.Name)
+		}
+		if len(domain.Protect) > 0 {
+			fmt.Printf("  Basic Auth: %d paths ('webstack domain protect %s')\n", len(domain.Protect), domain.Name)
+		}
+		if len(domain.Redirects) > 0 {
+			fmt.Printf("  Redirects: %d\n", len(domain.Redirects))
+		}
+		if domain.Headers != nil {
+			fmt.Println("  Security Headers: customized ('webstack domain headers " + domain.Name + "')")
+		}
+		if domain.Cache != nil {
+			fmt.Printf("  Cache: %s ('webstack cache')\n", domain.Cache.TTL)
+		}
+		if len(domain.Vars) > 0 {
+			fmt.Printf("  Template Variables: %d ('webstack domain var list %s')\n", len(domain.Vars), domain.Name)
+		}
+		fmt.Println()
 	}
 }
 
 // Helper functions
 func promptBackend() string {
 	reader := bufio.NewReader(os.Stdin)
-	fmt.Print("Choose backend (nginx/apache) [nginx]: ")
+	backend := DefaultBackend()
+	fmt.Printf("Choose backend (nginx/apache) [%s]: ", backend)
 
 	response, _ := reader.ReadString('\n')
 	response = strings.TrimSpace(response)
 
 	if response == "" {
-		return "nginx"
+		return backend
 	}
 
 	return strings.ToLower(response)
@@ -333,20 +690,123 @@ func promptBackend() string {
 
 func promptPHPVersion() string {
 	reader := bufio.NewReader(os.Stdin)
-	fmt.Print("Choose PHP version (5.6-8.4) [8.2]: ")
+	version := DefaultPHPVersion()
+	fmt.Printf("Choose PHP version (5.6-8.4) [%s]: ", version)
 
 	response, _ := reader.ReadString('\n')
 	response = strings.TrimSpace(response)
 
 	if response == "" {
-		return "8.2"
+		return version
 	}
 
 	return response
 }
 
+// DefaultBackend returns the backend offered for new domains: the
+// defaults.backend setting, else Apache on Apache-only servers and Nginx
+// everywhere else
+func DefaultBackend() string {
+	cfg, err := config.Load()
+	if err != nil || cfg == nil {
+		return "nginx"
+	}
+	if backend, ok := cfg.GetDefault(config.DefaultBackendKey, "").(string); ok && isValidBackend(backend) && backend != "proxy" {
+		return backend
+	}
+	if apacheStandalone(cfg) {
+		return "apache"
+	}
+	return "nginx"
+}
+
+// DefaultPHPVersion returns the PHP version offered for new domains: the
+// defaults.php setting, else 8.2
+func DefaultPHPVersion() string {
+	if cfg, err := config.Load(); err == nil && cfg != nil {
+		if version := cfg.DefaultPHPVersion(); isValidPHPVersion(version) {
+			return version
+		}
+	}
+	return config.FallbackPHPVersion
+}
+
+// normalizeDocRoot validates a web root subfolder and returns it relative to htdocs
+func normalizeDocRoot(docRoot string) (string, error) {
+	docRoot = strings.Trim(strings.TrimSpace(docRoot), "/")
+	if docRoot == "" {
+		return "", nil
+	}
+
+	cleaned := filepath.Clean(docRoot)
+	if cleaned == "." {
+		return "", nil
+	}
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("%s must be inside htdocs", docRoot)
+	}
+	if strings.ContainsAny(cleaned, " \t;{}\"'") {
+		return "", fmt.Errorf("%s contains characters not allowed in a vhost", docRoot)
+	}
+	return cleaned, nil
+}
+
+// hardeningEnabled reports whether webroot hardening rules are rendered for a
+// domain. The global harden_webroot setting is on unless disabled and can be
+// overridden per domain.
+func hardeningEnabled(d Domain, cfg *config.Config) bool {
+	if d.Hardening != nil {
+		return *d.Hardening
+	}
+	if _, ok := cfg.Defaults["harden_webroot"]; !ok {
+		return true
+	}
+	return cfg.GetBool("harden_webroot")
+}
+
+// parseOverride converts on/off/default into a per-domain override of a
+// global setting
+func parseOverride(value string) (*bool, error) {
+	switch strings.ToLower(value) {
+	case "on", "true", "yes":
+		enabled := true
+		return &enabled, nil
+	case "off", "false", "no":
+		enabled := false
+		return &enabled, nil
+	case "default":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%s (use on, off or default)", value)
+}
+
+func onOff(enabled bool) string {
+	if enabled {
+		return "on"
+	}
+	return "off"
+}
+
 func isValidBackend(backend string) bool {
-	return backend == "nginx" || backend == "apache"
+	if _, ok := backendServer(backend); ok {
+		return true
+	}
+	return backend == "nginx" || backend == "apache" || backend == "static" || backend == "proxy"
+}
+
+// backendUsesPHP reports whether domains of a backend are served through
+// PHP-FPM; static and proxy domains are not
+func backendUsesPHP(backend string) bool {
+	return backend != "static" && backend != "proxy"
+}
+
+func usesPHP(d Domain) bool {
+	return backendUsesPHP(d.Backend)
+}
+
+// ValidPHPVersion reports whether a PHP version can be used by a domain
+func ValidPHPVersion(version string) bool {
+	return isValidPHPVersion(version)
 }
 
 func isValidPHPVersion(version string) bool {
@@ -369,79 +829,118 @@ phpinfo();
 ?>`, domainName, phpVersion)
 
 	indexPath := filepath.Join(docRoot, "index.php")
-	if err := ioutil.WriteFile(indexPath, []byte(indexContent), 0644); err != nil {
-		fmt.Printf("Warning: Could not create index.php: %v\n", err)
+	if err := dryrun.WriteFile(indexPath, []byte(indexContent), 0644); err != nil {
+		ui.Warn("Warning: Could not create index.php: %v\n", err)
+	}
+}
+
+func createStaticIndex(docRoot, domainName string) {
+	indexContent := fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>%s</title></head>
+<body><h1>Welcome to %s</h1></body>
+</html>
+`, domainName, domainName)
+
+	indexPath := filepath.Join(docRoot, "index.html")
+	if err := dryrun.WriteFile(indexPath, []byte(indexContent), 0644); err != nil {
+		ui.Warn("Warning: Could not create index.html: %v\n", err)
 	}
 }
 
 func loadDomains() ([]Domain, error) {
 	var domains []Domain
 
-	if _, err := os.Stat(domainsFile); os.IsNotExist(err) {
+	if !domainsStore.Exists() {
 		// Create directory if it doesn't exist
-		if err := os.MkdirAll(filepath.Dir(domainsFile), 0755); err != nil {
+		if err := dryrun.MkdirAll(filepath.Dir(domainsFile), 0755); err != nil {
 			return nil, err
 		}
 		// Return empty slice if file doesn't exist
 		return domains, nil
 	}
 
-	data, err := ioutil.ReadFile(domainsFile)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := json.Unmarshal(data, &domains); err != nil {
+	if err := domainsStore.Load(&domains); err != nil {
 		return nil, err
 	}
 
-	return domains, nil
+	return migrateDomainNames(domains), nil
 }
 
+// saveDomain adds or replaces one domain, holding the domains.json lock
+// across the read and the write so concurrent changes are not lost
 func saveDomain(domain Domain) error {
-	domains, err := loadDomains()
-	if err != nil {
-		return err
-	}
-
-	// Check if domain already exists
-	for i, d := range domains {
-		if d.Name == domain.Name {
-			domains[i] = domain
-			return saveDomains(domains)
+	var domains []Domain
+	return domainsStore.Update(&domains, func() error {
+		for i, d := range domains {
+			if d.Name == domain.Name {
+				domains[i] = domain
+				return nil
+			}
 		}
-	}
+		domains = append(domains, domain)
+		return nil
+	})
+}
 
-	// Add new domain
-	domains = append(domains, domain)
-	return saveDomains(domains)
+// removeDomainEntry deletes a domain from domains.json without touching its files
+func removeDomainEntry(domainName string) error {
+	domainName = Normalize(domainName)
+	var domains []Domain
+	return domainsStore.Update(&domains, func() error {
+		for i, d := range domains {
+			if d.Name == domainName {
+				domains = append(domains[:i], domains[i+1:]...)
+				return nil
+			}
+		}
+		return nil
+	})
 }
 
 func saveDomains(domains []Domain) error {
-	// Create directory if it doesn't exist
-	if err := os.MkdirAll(filepath.Dir(domainsFile), 0755); err != nil {
-		return err
-	}
-
-	data, err := json.MarshalIndent(domains, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	return ioutil.WriteFile(domainsFile, data, 0644)
+	return domainsStore.Save(domains)
 }
 
+// GenerateConfig writes the web server configuration for a domain and
+// validates it, restoring the previous configuration on failure
 func GenerateConfig(d Domain) error {
-	return generateConfig(d)
+	return applyConfig(d, false)
+}
+
+// vhostConfig is the rendered web server configuration of a domain
+type vhostConfig struct {
+	nginx     string   // Nginx vhost, empty when Nginx doesn't serve the domain
+	apache    string   // Apache vhost, empty when Apache doesn't serve the domain
+	apacheSSL bool     // The Apache vhost terminates SSL itself
+	backend   string   // Vhost of the web server behind Nginx (see webServers), empty when none
+	http3     bool     // The Nginx vhost has a QUIC listener
+	warnings  []string // Problems that didn't stop rendering
 }
 
 func generateConfig(domain Domain) error {
 	fmt.Printf("⚙️  Generating configuration for %s...\n", domain.Name)
 
+	vc, err := renderConfig(domain, true)
+	if err != nil {
+		return err
+	}
+	for _, warning := range vc.warnings {
+		ui.Warn("⚠️  %s\n", warning)
+	}
+	return writeConfig(domain, vc)
+}
+
+// renderConfig renders the vhosts of a domain without writing anything, so
+// domains can be rendered in parallel and compared with the live files.
+// allowHTTP3 is cleared when the shared QUIC settings could not be written.
+func renderConfig(domain Domain, allowHTTP3 bool) (*vhostConfig, error) {
+	vc := &vhostConfig{}
+
 	// Load server config to determine ports and modes
 	cfg, err := config.Load()
 	if err != nil {
-		return fmt.Errorf("could not load server config: %v", err)
+		return nil, fmt.Errorf("could not load server config: %v", err)
 	}
 	if cfg == nil {
 		cfg = config.DefaultConfig()
@@ -451,9 +950,55 @@ func generateConfig(domain Domain) error {
 	templateVars := map[string]interface{}{
 		"Domain":       domain.Name,
 		"DocumentRoot": domain.DocumentRoot,
-		"PHPVersion":   strings.Split(domain.PHPVersion, ".")[0] + domain.PHPVersion[strings.LastIndex(domain.PHPVersion, "."):],
-		"PHPSocket":    fmt.Sprintf("unix:/run/php/php%s-fpm.sock", domain.PHPVersion),
+		"AppRoot":      domain.AppRoot(),
+		"ErrorDir":     filepath.Join(domain.HomeDir(), "error"),
+		"ConfigsDir":   configsDir(domain.Name),
+		"LogsDir":      logsDir(domain.Name),
+		"PHPVersion":   domain.PHPVersion,
+		"PHPSocket":    phpSocket(domain),
 		"ApachePort":   cfg.GetPort("apache"), // Get Apache port from config
+		"Redirects":    domain.Redirects,
+		"Routes":       routeVars(domain),
+		"Auth":         authVars(domain),
+		"AuthRealm":    authRealm,
+		"PresetNginx":  "",
+		"PresetApache": "",
+		"Hardening":    hardeningEnabled(domain, cfg),
+		"HTTP3":        false,
+		"NoCompression": domain.NoCompression,
+		"Brotli":       nginxBrotliLoaded(),
+		"Headers":      securityHeaders(domain, false),
+		"ApacheHeaders": []Header(nil),
+	}
+	redirectVars(domain, templateVars)
+	accessVars(domain, templateVars)
+	if err := upstreamVars(domain, templateVars); err != nil {
+		return nil, err
+	}
+	ws, behindNginx := backendServer(domain.Backend)
+	if behindNginx {
+		// Nginx proxies the domain to the web server over plain HTTP
+		templateVars["BackendPort"] = ws.Port(cfg)
+		templateVars["Upstream"] = fmt.Sprintf("http://127.0.0.1:%d", ws.Port(cfg))
+		templateVars["UpstreamTLS"] = false
+	}
+	templateVars["Cache"] = cacheVars(domain)
+	templateVars["RateLimit"] = rateLimitVars(domain)
+	templateVars["Vars"] = domainVars(domain)
+
+	// Render framework preset rules with the same variables as the main templates
+	if domain.Preset != "" {
+		for _, server := range []string{"nginx", "apache"} {
+			rules, err := renderPreset(domain.Preset, server, templateVars)
+			if err != nil {
+				return nil, err
+			}
+			key := "PresetNginx"
+			if server == "apache" {
+				key = "PresetApache"
+			}
+			templateVars[key] = rules
+		}
 	}
 
 	// If SSL is enabled for this domain, try to include certificate paths and use SSL templates
@@ -463,74 +1008,110 @@ func generateConfig(domain Domain) error {
 		if err != nil {
 			// SSL is enabled but cert paths are missing or empty
 			// Fall back to non-SSL config and warn user
-			fmt.Printf("⚠️  SSL enabled but certificate paths missing for %s. Generating non-SSL config.\n", domain.Name)
-			fmt.Printf("    Reason: %v\n", err)
+			vc.warnings = append(vc.warnings,
+				fmt.Sprintf("SSL enabled but certificate paths missing for %s. Generating non-SSL config.", domain.Name),
+				fmt.Sprintf("    Reason: %v", err))
 		} else {
 			// Add cert paths to template variables
 			templateVars["SSLCert"] = certPath
 			templateVars["SSLKey"] = keyPath
+			templateVars["Headers"] = securityHeaders(domain, true)
 			useSSL = true
 		}
 	}
 
-	if useSSL {
-		// SSL-enabled paths
-		if domain.Backend == "nginx" {
-			if err := generateNginxConfig(domain.Name, templateVars, "domain-ssl"); err != nil {
-				return err
-			}
-		} else if domain.Backend == "apache" {
-			// For Apache backend, check if Nginx is in proxy mode
-			nginxMode := cfg.GetMode("nginx")
-			if nginxMode == "proxy" {
-				// Nginx will proxy to Apache (proxy-ssl)
-				if err := generateNginxConfig(domain.Name, templateVars, "proxy-ssl"); err != nil {
-					return err
-				}
-				// Still need to generate Apache config for Nginx to proxy to
-				if err := generateApacheConfig(domain.Name, templateVars); err != nil {
-					return err
-				}
-			} else if !cfg.IsInstalled("nginx") || nginxMode == "standalone" {
-				// Generate Apache config for standalone mode
-				if err := generateApacheConfig(domain.Name, templateVars); err != nil {
-					return err
-				}
+	// Nginx serves the domain directly or proxies it to Apache; on
+	// Apache-only servers Apache serves it and terminates SSL itself
+	nginxTemplate, useApache := vhostLayout(domain, cfg)
+	if nginxTemplate != "" {
+		if useSSL {
+			nginxTemplate += "-ssl"
+
+			// HTTP/3 runs over TLS, so only SSL vhosts get a QUIC listener
+			if allowHTTP3 && http3Enabled(domain, cfg) {
+				templateVars["HTTP3"] = true
+				vc.http3 = true
 			}
 		}
-	} else {
-		// Non-SSL paths
-		if domain.Backend == "nginx" {
-			// Direct Nginx backend
-			if err := generateNginxConfig(domain.Name, templateVars, "domain"); err != nil {
+		if vc.nginx, err = renderNginxConfig(templateVars, nginxTemplate); err != nil {
+			return nil, err
+		}
+	}
+	if useApache {
+		// Behind Nginx the headers are already added by the proxy
+		if nginxTemplate == "" {
+			templateVars["ApacheHeaders"] = templateVars["Headers"]
+		}
+		apacheTemplate := "domain"
+		if domain.Backend == "static" {
+			apacheTemplate = "static"
+		}
+		if useSSL && nginxTemplate == "" {
+			apacheTemplate += "-ssl"
+			vc.apacheSSL = true
+		}
+		if vc.apache, err = renderApacheConfig(templateVars, apacheTemplate); err != nil {
+			return nil, err
+		}
+	}
+	if behindNginx && nginxTemplate != "" {
+		if vc.backend, err = ws.Render(templateVars); err != nil {
+			return nil, err
+		}
+	}
+
+	return vc, nil
+}
+
+// writeConfig writes the rendered vhosts of a domain and enables them
+func writeConfig(domain Domain, vc *vhostConfig) error {
+	// Vhosts log to <home>/logs, rotated by a per-domain logrotate config
+	if err := ensureLogsDir(domain); err != nil {
+		return err
+	}
+	if err := writeLogrotate(domain); err != nil {
+		ui.Warn("⚠️  Warning: %v\n", err)
+	}
+
+	if vc.http3 {
+		if err := ensureHTTP3Tuning(); err != nil {
+			ui.Warn("⚠️  Warning: HTTP/3 not enabled for %s: %v\n", domain.Name, err)
+			if vc, err = renderConfig(domain, false); err != nil {
 				return err
 			}
-		} else if domain.Backend == "apache" {
-			// For Apache backend, check server configuration
-			nginxMode := cfg.GetMode("nginx")
-			if nginxMode == "proxy" {
-				// Nginx is in proxy mode, generate proxy config
-				if err := generateNginxConfig(domain.Name, templateVars, "proxy"); err != nil {
-					return err
-				}
-				// Still need to generate Apache config for Nginx to proxy to
-				if err := generateApacheConfig(domain.Name, templateVars); err != nil {
-					return err
-				}
-			} else if !cfg.IsInstalled("nginx") || nginxMode == "standalone" {
-				// Generate Apache config for standalone mode
-				if err := generateApacheConfig(domain.Name, templateVars); err != nil {
-					return err
-				}
+		}
+	}
+
+	if vc.nginx != "" {
+		if err := writeNginxConfig(domain.Name, vc.nginx); err != nil {
+			return err
+		}
+	}
+	if vc.apache != "" {
+		if err := writeApacheConfig(domain.Name, vc.apache, vc.apacheSSL); err != nil {
+			return err
+		}
+	}
+	for _, ws := range webServers {
+		if vc.backend != "" && ws.Backend() == domain.Backend {
+			if err := writeBackendConfig(ws, domain.Name, vc.backend); err != nil {
+				return err
 			}
+			continue
+		}
+		// Left over from a previous backend of the domain
+		if _, err := os.Lstat(ws.VhostPath(domain.Name)); err == nil {
+			dryrun.Remove(ws.VhostPath(domain.Name))
 		}
 	}
 
 	return nil
 }
 
-func generateNginxConfig(domainName string, vars map[string]interface{}, configType string) error {
-	// configType can be "domain" (direct PHP-FPM) or "proxy" (Apache reverse proxy)
+func renderNginxConfig(vars map[string]interface{}, configType string) (string, error) {
+	// configType can be "domain" (direct PHP-FPM), "proxy" (Apache reverse proxy),
+	// "static" (files only) or "upstream" (reverse proxy to an app), each with
+	// an "-ssl" variant
 
 	// Read template from embedded filesystem
 	templateFilename := "domain.conf"
@@ -540,63 +1121,67 @@ func generateNginxConfig(domainName string, vars map[string]interface{}, configT
 		templateFilename = "domain-ssl.conf"
 	} else if configType == "proxy-ssl" {
 		templateFilename = "proxy-ssl.conf"
+	} else if configType == "static" {
+		templateFilename = "static.conf"
+	} else if configType == "static-ssl" {
+		templateFilename = "static-ssl.conf"
+	} else if configType == "upstream" {
+		templateFilename = "upstream.conf"
+	} else if configType == "upstream-ssl" {
+		templateFilename = "upstream-ssl.conf"
 	}
 
 	content, err := templates.GetNginxTemplate(templateFilename)
 	if err != nil {
-		return fmt.Errorf("could not read nginx template (%s): %v", templateFilename, err)
+		return "", fmt.Errorf("could not read nginx template (%s): %v", templateFilename, err)
 	}
 
 	// Parse template
 	tmpl, err := template.New("nginx").Parse(string(content))
 	if err != nil {
-		return fmt.Errorf("could not parse nginx template: %v", err)
+		return "", fmt.Errorf("could not parse nginx template: %v", err)
 	}
 
-	// Render into buffer so we can post-process (e.g., remove fastcgi_cache lines if main nginx.conf doesn't define them)
+	// Render into buffer so the plain HTTP vhost can be prepended
 	var buf strings.Builder
 	if err := tmpl.Execute(&buf, vars); err != nil {
-		return fmt.Errorf("could not execute nginx template: %v", err)
+		return "", fmt.Errorf("could not execute nginx template: %v", err)
 	}
 	rendered := buf.String()
 
-	// If the running nginx configuration doesn't define a fastcgi_cache zone, strip fastcgi_cache lines from rendered output
-	if data, err := ioutil.ReadFile("/etc/nginx/nginx.conf"); err == nil {
-		if !strings.Contains(string(data), "fastcgi_cache_path") && strings.Contains(rendered, "fastcgi_cache") {
-			// Remove any lines related to the FastCGI cache block inserted by template
-			outLines := []string{}
-			for _, line := range strings.Split(rendered, "\n") {
-				// skip cache-related lines
-				if strings.Contains(line, "# FastCGI cache settings") || strings.Contains(line, "fastcgi_cache ") || strings.Contains(line, "fastcgi_cache_valid") || strings.Contains(line, "fastcgi_cache_bypass") || strings.Contains(line, "fastcgi_no_cache") || strings.Contains(line, "$no_cache") {
-					continue
-				}
-				outLines = append(outLines, line)
-			}
-			rendered = strings.Join(outLines, "\n")
+	// Without a forced redirect the plain HTTP vhost serves port 80
+	if strings.HasSuffix(templateFilename, "-ssl.conf") && vars["ForceHTTPS"] == false {
+		plain, err := renderPlainVhost("nginx", templateFilename, vars)
+		if err != nil {
+			return "", err
 		}
+		rendered = plain + rendered
 	}
+	return rendered, nil
+}
 
+func writeNginxConfig(domainName, rendered string) error {
 	// Ensure sites-available directory exists
 	siteDir := "/etc/nginx/sites-available"
-	if err := os.MkdirAll(siteDir, 0755); err != nil {
+	if err := dryrun.MkdirAll(siteDir, 0755); err != nil {
 		return fmt.Errorf("could not create nginx sites-available directory: %v", err)
 	}
 
 	// Write config file
 	configFile := filepath.Join(siteDir, domainName+".conf")
-	if err := ioutil.WriteFile(configFile, []byte(rendered), 0644); err != nil {
+	if err := dryrun.WriteFile(configFile, []byte(rendered), 0644); err != nil {
 		return fmt.Errorf("could not write nginx config file: %v", err)
 	}
 
 	// Enable site by creating symlink in sites-enabled
 	enableDir := "/etc/nginx/sites-enabled"
-	if err := os.MkdirAll(enableDir, 0755); err != nil {
+	if err := dryrun.MkdirAll(enableDir, 0755); err != nil {
 		return fmt.Errorf("could not create nginx sites-enabled directory: %v", err)
 	}
 
 	enableLink := filepath.Join(enableDir, domainName+".conf")
-	os.Remove(enableLink) // Remove existing symlink if it exists
-	if err := os.Symlink(configFile, enableLink); err != nil {
+	dryrun.Remove(enableLink) // Remove existing symlink if it exists
+	if err := dryrun.Symlink(configFile, enableLink); err != nil {
 		return fmt.Errorf("could not create nginx sites-enabled symlink: %v", err)
 	}
 
@@ -604,55 +1189,72 @@ func generateNginxConfig(domainName string, vars map[string]interface{}, configT
 	return nil
 }
 
-func generateApacheConfig(domainName string, vars map[string]interface{}) error {
+func renderApacheConfig(vars map[string]interface{}, configType string) (string, error) {
+	// configType can be "domain" (PHP-FPM) or "static" (files only); the
+	// "-ssl" variants are used when Apache terminates SSL itself
+	templateFilename := configType + ".conf"
+	ssl := strings.HasSuffix(configType, "-ssl")
+
 	// Read template from embedded filesystem
-	content, err := templates.GetApacheTemplate("domain.conf")
+	content, err := templates.GetApacheTemplate(templateFilename)
 	if err != nil {
-		return fmt.Errorf("could not read apache template: %v", err)
+		return "", fmt.Errorf("could not read apache template (%s): %v", templateFilename, err)
 	}
 
 	// Parse and execute template
 	tmpl, err := template.New("apache").Parse(string(content))
 	if err != nil {
-		return fmt.Errorf("could not parse apache template: %v", err)
+		return "", fmt.Errorf("could not parse apache template: %v", err)
 	}
 
+	var buf strings.Builder
+	if ssl && vars["ForceHTTPS"] == false {
+		plain, err := renderPlainVhost("apache", templateFilename, vars)
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(plain)
+	}
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("could not execute apache template: %v", err)
+	}
+	return buf.String(), nil
+}
+
+func writeApacheConfig(domainName, rendered string, ssl bool) error {
 	// Ensure sites-available directory exists
-	siteDir := "/etc/apache2/sites-available"
-	if err := os.MkdirAll(siteDir, 0755); err != nil {
+	siteDir := apacheSitesAvailable()
+	if err := dryrun.MkdirAll(siteDir, 0755); err != nil {
 		return fmt.Errorf("could not create apache sites-available directory: %v", err)
 	}
 
 	// Write config file
 	configFile := filepath.Join(siteDir, domainName+".conf")
-	file, err := os.Create(configFile)
-	if err != nil {
+	if err := dryrun.WriteFile(configFile, []byte(rendered), 0644); err != nil {
 		return fmt.Errorf("could not create apache config file: %v", err)
 	}
-	defer file.Close()
-
-	if err := tmpl.Execute(file, vars); err != nil {
-		return fmt.Errorf("could not execute apache template: %v", err)
-	}
 
 	// Enable site using a2ensite
-	cmd := exec.Command("a2ensite", domainName)
-	if err := cmd.Run(); err != nil {
-		fmt.Printf("⚠️  Warning: Could not enable Apache site: %v\n", err)
+	if err := EnableApacheSite(domainName); err != nil {
+		ui.Warn("⚠️  Warning: Could not enable Apache site: %v\n", err)
 		// Don't fail, just warn
 	}
 
 	// Ensure required Apache modules for php-fpm proxying are enabled
-	mods := [][]string{
-		{"a2enmod", "proxy_fcgi"},
-		{"a2enmod", "proxy"},
-		{"a2enmod", "setenvif"},
-		{"a2enmod", "remoteip"},
+	mods := []string{"proxy_fcgi", "proxy", "setenvif", "remoteip"}
+	if ssl {
+		// The HTTPS vhost needs mod_ssl, and mod_headers for HSTS
+		mods = append(mods, "ssl", "headers")
 	}
 	for _, m := range mods {
-		cmd := exec.Command(m[0], m[1])
-		if err := cmd.Run(); err != nil {
-			fmt.Printf("⚠️  Warning: Could not enable Apache module %s: %v\n", m[1], err)
+		if err := EnableApacheModules(m); err != nil {
+			ui.Warn("⚠️  Warning: Could not enable Apache module %s: %v\n", m, err)
+		}
+	}
+
+	if ssl {
+		if err := ensureApacheSSLPort(); err != nil {
+			ui.Warn("⚠️  Warning: %v\n", err)
 		}
 	}
 
@@ -663,59 +1265,101 @@ func generateApacheConfig(domainName string, vars map[string]interface{}) error
 func removeConfig(domain Domain) {
 	fmt.Printf("⚙️  Removing configuration for %s...\n", domain.Name)
 
-	// Always remove Nginx config (both direct PHP and proxy configs)
+	// Remove the Nginx config (direct PHP or proxy) when Nginx has one; on
+	// Apache-only servers /etc/nginx is not touched
+	var removed []string
 	siteAvailablePath := filepath.Join("/etc/nginx/sites-available", domain.Name+".conf")
 	siteEnabledPath := filepath.Join("/etc/nginx/sites-enabled", domain.Name+".conf")
-
-	if err := os.Remove(siteAvailablePath); err != nil && !os.IsNotExist(err) {
-		fmt.Printf("⚠️  Warning: Could not remove nginx config: %v\n", err)
+	if _, err := os.Lstat(siteAvailablePath); err == nil {
+		if err := dryrun.Remove(siteAvailablePath); err != nil {
+			ui.Warn("⚠️  Warning: Could not remove nginx config: %v\n", err)
+		}
+		removed = append(removed, "Nginx")
 	}
-
-	if err := os.Remove(siteEnabledPath); err != nil && !os.IsNotExist(err) {
-		fmt.Printf("⚠️  Warning: Could not remove nginx symlink: %v\n", err)
+	if _, err := os.Lstat(siteEnabledPath); err == nil {
+		if err := dryrun.Remove(siteEnabledPath); err != nil {
+			ui.Warn("⚠️  Warning: Could not remove nginx symlink: %v\n", err)
+		}
 	}
 
-	if domain.Backend == "apache" {
+	// Apache serves apache-backend domains, and every domain on Apache-only servers
+	apacheSiteAvailablePath := filepath.Join(apacheSitesAvailable(), domain.Name+".conf")
+	if _, err := os.Stat(apacheSiteAvailablePath); err == nil || domain.Backend == "apache" {
 		// Disable site using a2dissite
-		cmd := exec.Command("a2dissite", domain.Name)
-		if err := cmd.Run(); err != nil {
-			fmt.Printf("⚠️  Warning: Could not disable Apache site: %v\n", err)
+		if err := disableApacheSite(domain.Name); err != nil {
+			ui.Warn("⚠️  Warning: Could not disable Apache site: %v\n", err)
 		}
 
 		// Remove apache config file
-		apacheSiteAvailablePath := filepath.Join("/etc/apache2/sites-available", domain.Name+".conf")
-		if err := os.Remove(apacheSiteAvailablePath); err != nil && !os.IsNotExist(err) {
-			fmt.Printf("⚠️  Warning: Could not remove apache config: %v\n", err)
+		if err := dryrun.Remove(apacheSiteAvailablePath); err != nil && !os.IsNotExist(err) {
+			ui.Warn("⚠️  Warning: Could not remove apache config: %v\n", err)
 		}
+		removed = append(removed, "Apache")
+	}
 
-		fmt.Printf("✅ Apache and Nginx configurations removed for %s\n", domain.Name)
-	} else {
-		fmt.Printf("✅ Nginx configuration removed for %s\n", domain.Name)
+	// Web servers behind Nginx, whatever the current backend of the domain
+	for _, ws := range webServers {
+		path := ws.VhostPath(domain.Name)
+		if _, err := os.Lstat(path); err != nil {
+			continue
+		}
+		if err := dryrun.Remove(path); err != nil {
+			ui.Warn("⚠️  Warning: Could not remove %s config: %v\n", strings.ToLower(ws.Label()), err)
+		}
+		removed = append(removed, ws.Label())
 	}
+
+	if len(removed) == 0 {
+		fmt.Printf("✅ No web server configuration to remove for %s\n", domain.Name)
+		return
+	}
+	fmt.Printf("✅ %s configuration removed for %s\n", strings.Join(removed, " and "), domain.Name)
+}
+
+// ReloadWebServers reloads Nginx and Apache, restarting them if the reload fails
+func ReloadWebServers() {
+	reloadWebServers()
 }
 
 func reloadWebServers() {
+	reload := map[string]bool{"nginx": true, "apache": true}
+	for _, ws := range webServers {
+		reload[ws.Backend()] = true
+	}
+	reloadServers(reload)
+}
+
+// reloadServers reloads the given web servers ("nginx", "apache" or a
+// backend of webServers)
+func reloadServers(reload map[string]bool) {
 	fmt.Println("⚙️  Reloading web servers...")
 
-	// Reload Nginx
-	nginxReloadCmd := exec.Command("systemctl", "reload", "nginx")
-	if err := nginxReloadCmd.Run(); err != nil {
-		fmt.Printf("⚠️  Warning: Could not reload Nginx: %v\n", err)
-	} else {
-		fmt.Println("✅ Nginx reloaded")
+	servers := []struct{ server, unit, label string }{
+		{"nginx", "nginx", "Nginx"},
+		{"apache", osinfo.Current().Service("apache2"), "Apache"},
 	}
-
-	// Reload Apache
-	apacheReloadCmd := exec.Command("systemctl", "reload", "apache2")
-	if err := apacheReloadCmd.Run(); err != nil {
-		fmt.Printf("⚠️  Warning: Could not reload Apache: %v\n", err)
-	} else {
-		fmt.Println("✅ Apache reloaded")
+	for _, ws := range webServers {
+		servers = append(servers, struct{ server, unit, label string }{ws.Backend(), ws.Unit(), ws.Label()})
+	}
+	for _, s := range servers {
+		if !reload[s.server] {
+			continue
+		}
+		err := service.Reload(s.unit)
+		switch {
+		case err == service.ErrNotInstalled:
+			continue
+		case err != nil:
+			ui.Warn("⚠️  Warning: Could not reload %s: %v\n", s.label, err)
+		default:
+			fmt.Printf("✅ %s reloaded\n", s.label)
+		}
 	}
 }
 
 // DomainExists checks if a domain exists in the configuration
 func DomainExists(domainName string) bool {
+	domainName = Normalize(domainName)
 	domains, err := loadDomains()
 	if err != nil {
 		return false
@@ -729,8 +1373,14 @@ func DomainExists(domainName string) bool {
 	return false
 }
 
+// All returns the domains configured in domains.json
+func All() ([]Domain, error) {
+	return loadDomains()
+}
+
 // GetDomain returns a domain by name
 func GetDomain(domainName string) (*Domain, error) {
+	domainName = Normalize(domainName)
 	domains, err := loadDomains()
 	if err != nil {
 		return nil, err
@@ -744,13 +1394,45 @@ func GetDomain(domainName string) (*Domain, error) {
 	return nil, fmt.Errorf("domain %s not found", domainName)
 }
 
+// HomeDir returns the folder holding the domain's htdocs, logs and configs
+func (d Domain) HomeDir() string {
+	if d.Home != "" {
+		return d.Home
+	}
+	return filepath.Join(config.FallbackWebRoot, d.Name)
+}
+
+// HomeDir returns the folder of a domain by name. Unknown domains get the
+// folder they had before defaults.webroot existed.
+func HomeDir(domainName string) string {
+	if d, err := GetDomain(domainName); err == nil {
+		return d.HomeDir()
+	}
+	return filepath.Join(config.FallbackWebRoot, domainName)
+}
+
 // UpdateDomain updates a domain in the configuration
 func UpdateDomain(domain Domain) error {
 	return saveDomain(domain)
 }
 
+// Reconfigure saves a domain, regenerates and validates its configuration,
+// reloads the web servers and checks that the site responds
+func Reconfigure(domain Domain) error {
+	if err := saveDomain(domain); err != nil {
+		return fmt.Errorf("could not save domain: %v", err)
+	}
+	if err := applyConfig(domain, false); err != nil {
+		return err
+	}
+	reloadWebServers()
+	smokeTest(domain)
+	return nil
+}
+
 // loadSSLCertPaths loads certificate and key paths for a domain from domains.json
 func loadSSLCertPaths(domainName string) (string, string, error) {
+	domainName = Normalize(domainName)
 	domains, err := loadDomains()
 	if err != nil {
 		return "", "", fmt.Errorf("could not load domains: %v", err)
@@ -0,0 +1,222 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"webstack-cli/internal/ui"
+)
+
+// SecurityHeaders are per-domain overrides of the security headers rendered
+// into the generated vhosts. An empty field keeps the default, "off" stops
+// sending the header.
+type SecurityHeaders struct {
+	HSTS              string `json:"hsts,omitempty"`               // Strict-Transport-Security, HTTPS only
+	CSP               string `json:"csp,omitempty"`                // Content-Security-Policy
+	XFrameOptions     string `json:"x_frame_options,omitempty"`    // DENY or SAMEORIGIN
+	ReferrerPolicy    string `json:"referrer_policy,omitempty"`    // Referrer-Policy
+	PermissionsPolicy string `json:"permissions_policy,omitempty"` // Permissions-Policy
+}
+
+// Header is a response header of a vhost. An empty Value removes the header.
+type Header struct {
+	Name  string
+	Value string
+}
+
+// HeadersOptions holds the changes of 'webstack domain headers'. Empty
+// fields are left unchanged, "default" restores the default.
+type HeadersOptions struct {
+	HSTS              string // "on", "off", "subdomains", "preload", "default" or a header value (max-age=...)
+	CSP               string
+	XFrameOptions     string // DENY, SAMEORIGIN, "off" or "default"
+	ReferrerPolicy    string
+	PermissionsPolicy string
+	Reset             bool // Drop all overrides
+}
+
+const defaultHSTS = "max-age=63072000"
+
+var hstsPresets = map[string]string{
+	"on":         defaultHSTS,
+	"subdomains": defaultHSTS + "; includeSubDomains",
+	"preload":    defaultHSTS + "; includeSubDomains; preload",
+}
+
+var referrerPolicies = []string{
+	"no-referrer", "no-referrer-when-downgrade", "origin", "origin-when-cross-origin",
+	"same-origin", "strict-origin", "strict-origin-when-cross-origin", "unsafe-url",
+}
+
+// securityHeaders returns the security headers of a domain in the order they
+// are rendered. Strict-Transport-Security is only sent over HTTPS.
+func securityHeaders(d Domain, ssl bool) []Header {
+	h := SecurityHeaders{}
+	if d.Headers != nil {
+		h = *d.Headers
+	}
+
+	var headers []Header
+	add := func(name, value, def string) {
+		switch value {
+		case "":
+			value = def
+		case "off":
+			value = ""
+		}
+		if value != "" || def != "" {
+			headers = append(headers, Header{Name: name, Value: value})
+		}
+	}
+
+	if ssl {
+		add("Strict-Transport-Security", h.HSTS, defaultHSTS)
+	}
+	add("X-Frame-Options", h.XFrameOptions, "SAMEORIGIN")
+	add("X-Content-Type-Options", "", "nosniff")
+	add("X-XSS-Protection", "", "1; mode=block")
+	add("Content-Security-Policy", h.CSP, "")
+	add("Referrer-Policy", h.ReferrerPolicy, "")
+	add("Permissions-Policy", h.PermissionsPolicy, "")
+	return headers
+}
+
+// validateHeaderValue checks that a header value can be safely rendered
+// into nginx and Apache configs
+func validateHeaderValue(name, value string) error {
+	if strings.ContainsAny(value, "\"\\$\r\n\t") {
+		return fmt.Errorf("%s contains characters not allowed in a vhost (\" \\ $ or control characters)", name)
+	}
+	return nil
+}
+
+// applyHeadersOptions merges the changes of opts into the overrides of a domain
+func applyHeadersOptions(current *SecurityHeaders, opts HeadersOptions) (*SecurityHeaders, error) {
+	h := SecurityHeaders{}
+	if current != nil && !opts.Reset {
+		h = *current
+	}
+
+	set := func(field *string, value string) {
+		switch value {
+		case "":
+		case "default":
+			*field = ""
+		default:
+			*field = value
+		}
+	}
+
+	hsts := strings.TrimSpace(opts.HSTS)
+	if preset, ok := hstsPresets[strings.ToLower(hsts)]; ok {
+		hsts = preset
+	} else if hsts != "" && hsts != "off" && hsts != "default" && !strings.HasPrefix(hsts, "max-age=") {
+		return nil, fmt.Errorf("HSTS must be on, off, subdomains, preload, default or a max-age=... value: %s", opts.HSTS)
+	} else if err := validateHeaderValue("Strict-Transport-Security", hsts); err != nil {
+		return nil, err
+	}
+	if hsts == defaultHSTS {
+		hsts = "default"
+	}
+	set(&h.HSTS, hsts)
+
+	xfo := strings.ToUpper(strings.TrimSpace(opts.XFrameOptions))
+	switch xfo {
+	case "", "DENY", "SAMEORIGIN":
+	case "OFF", "DEFAULT":
+		xfo = strings.ToLower(xfo)
+	default:
+		return nil, fmt.Errorf("X-Frame-Options must be DENY, SAMEORIGIN, off or default: %s", opts.XFrameOptions)
+	}
+	if xfo == "SAMEORIGIN" {
+		xfo = "default"
+	}
+	set(&h.XFrameOptions, xfo)
+
+	referrer := strings.ToLower(strings.TrimSpace(opts.ReferrerPolicy))
+	known := referrer == "" || referrer == "off" || referrer == "default"
+	for _, policy := range referrerPolicies {
+		known = known || referrer == policy
+	}
+	if !known {
+		return nil, fmt.Errorf("Referrer-Policy must be one of %s, off or default: %s", strings.Join(referrerPolicies, ", "), opts.ReferrerPolicy)
+	}
+	set(&h.ReferrerPolicy, referrer)
+
+	csp := strings.TrimSpace(opts.CSP)
+	if err := validateHeaderValue("Content-Security-Policy", csp); err != nil {
+		return nil, err
+	}
+	if csp == "off" {
+		csp = "default"
+	}
+	set(&h.CSP, csp)
+
+	permissions := strings.TrimSpace(opts.PermissionsPolicy)
+	if err := validateHeaderValue("Permissions-Policy", permissions); err != nil {
+		return nil, err
+	}
+	if permissions == "off" {
+		permissions = "default"
+	}
+	set(&h.PermissionsPolicy, permissions)
+
+	if h == (SecurityHeaders{}) {
+		return nil, nil
+	}
+	return &h, nil
+}
+
+// ManageHeaders changes the security headers of a domain and regenerates its
+// configuration, or shows them when opts holds no change
+func ManageHeaders(domainName string, opts HeadersOptions) {
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+
+	if opts == (HeadersOptions{}) {
+		showHeaders(*d)
+		return
+	}
+
+	headers, err := applyHeadersOptions(d.Headers, opts)
+	if err != nil {
+		ui.Invalid("Invalid header: %v\n", err)
+		return
+	}
+
+	previous := d.Headers
+	d.Headers = headers
+	if err := saveDomain(*d); err != nil {
+		ui.Fail("❌ Could not save domain: %v\n", err)
+		return
+	}
+	if err := applyConfig(*d, false); err != nil {
+		d.Headers = previous
+		if saveErr := saveDomain(*d); saveErr != nil {
+			ui.Warn("⚠️  Warning: Could not restore headers: %v\n", saveErr)
+		}
+		ui.Fail("❌ Could not update headers: %v\n", err)
+		return
+	}
+	reloadWebServers()
+	smokeTest(*d)
+
+	fmt.Printf("✅ Security headers updated for %s\n", d.Name)
+	showHeaders(*d)
+}
+
+func showHeaders(d Domain) {
+	fmt.Printf("Security headers for %s:\n", d.Name)
+	for _, h := range securityHeaders(d, true) {
+		value := h.Value
+		if value == "" {
+			value = "(not sent)"
+		}
+		if h.Name == "Strict-Transport-Security" && !d.SSLEnabled {
+			value += " (HTTPS only, SSL is not enabled)"
+		}
+		fmt.Printf("  %s: %s\n", h.Name, value)
+	}
+}
@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"sync"
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/firewall"
+	"webstack-cli/internal/ui"
+)
+
+// HTTP3Key is the global setting serving SSL domains over HTTP/3 (QUIC)
+const HTTP3Key = "http3"
+
+// http3Conf is included in the http block of nginx.conf while a domain
+// has HTTP/3 enabled
+const http3Conf = "/etc/nginx/includes/http3.conf"
+
+// http3Tuning holds the QUIC settings shared by all domains. Domains listen
+// on 443/udp without reuseport, which only one server block may set; this
+// catch-all server owns the reuseport socket so each QUIC packet reaches
+// the worker holding its connection.
+const http3Tuning = `# WebStack CLI - HTTP/3 (QUIC)
+# Written while a domain has HTTP/3 enabled ('webstack domain edit --http3')
+
+quic_retry on;
+
+server {
+	listen               443 quic reuseport;
+	server_name          _;
+	ssl_reject_handshake on;
+	return               444;
+}
+`
+
+// nginxVersion matches the version line of nginx -V, nginxHTTP3Module its
+// configure argument building HTTP/3 in
+var (
+	nginxVersion     = regexp.MustCompile(`nginx/(\d+)\.(\d+)\.(\d+)`)
+	nginxHTTP3Module = regexp.MustCompile(`--with-http_v3_module\b`)
+)
+
+var (
+	http3Probe     sync.Once
+	http3Supported bool
+	http3Reason    string
+)
+
+// NginxHTTP3Support probes nginx -V for HTTP/3: nginx 1.25.0 or later built
+// with the http_v3 module. The reason explains a missing support.
+func NginxHTTP3Support() (bool, string) {
+	http3Probe.Do(func() {
+		// nginx -V prints to stderr
+		output, err := exec.Command("nginx", "-V").CombinedOutput()
+		if err != nil {
+			http3Reason = "nginx is not installed"
+			return
+		}
+		m := nginxVersion.FindStringSubmatch(string(output))
+		if m == nil {
+			http3Reason = "could not read the nginx version"
+			return
+		}
+		major, _ := strconv.Atoi(m[1])
+		minor, _ := strconv.Atoi(m[2])
+		version := m[1] + "." + m[2] + "." + m[3]
+		if major == 1 && minor < 25 {
+			http3Reason = fmt.Sprintf("nginx %s is older than 1.25.0", version)
+			return
+		}
+		if !nginxHTTP3Module.Match(output) {
+			http3Reason = fmt.Sprintf("nginx %s is built without --with-http_v3_module", version)
+			return
+		}
+		http3Supported = true
+	})
+	return http3Supported, http3Reason
+}
+
+// http3Requested reports whether HTTP/3 is wanted for a domain. The global
+// http3 setting is off unless enabled and can be overridden per domain.
+func http3Requested(d Domain, cfg *config.Config) bool {
+	if d.HTTP3 != nil {
+		return *d.HTTP3
+	}
+	return cfg.GetBool(HTTP3Key)
+}
+
+// http3Enabled reports whether the HTTP/3 listener is rendered for a
+// domain served over SSL, warning when nginx cannot serve it
+func http3Enabled(d Domain, cfg *config.Config) bool {
+	if !http3Requested(d, cfg) {
+		return false
+	}
+	if supported, reason := NginxHTTP3Support(); !supported {
+		ui.Warn("⚠️  HTTP/3 skipped for %s: %s\n", d.Name, reason)
+		return false
+	}
+	return true
+}
+
+// ensureHTTP3Tuning writes the shared QUIC settings and opens 443/udp the
+// first time a domain is served over HTTP/3
+func ensureHTTP3Tuning() error {
+	if _, err := os.Stat(http3Conf); err == nil {
+		return nil
+	}
+	if err := dryrun.MkdirAll("/etc/nginx/includes", 0755); err != nil {
+		return fmt.Errorf("could not create nginx includes directory: %v", err)
+	}
+	if err := dryrun.WriteFile(http3Conf, []byte(http3Tuning), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", http3Conf, err)
+	}
+	if err := firewall.Open("nginx", "udp", 443); err != nil {
+		ui.Warn("⚠️  Warning: Could not open port 443/udp for HTTP/3: %v\n", err)
+	}
+	return nil
+}
+
+// removeHTTP3Tuning removes the shared QUIC settings and closes 443/udp once
+// no domain is served over HTTP/3
+func removeHTTP3Tuning() {
+	if _, err := os.Stat(http3Conf); err != nil {
+		return
+	}
+	if err := dryrun.Remove(http3Conf); err != nil {
+		ui.Warn("⚠️  Warning: Could not remove %s: %v\n", http3Conf, err)
+		return
+	}
+	if _, err := firewall.Close("nginx", "udp", false, 443); err != nil {
+		ui.Warn("⚠️  Warning: Could not close port 443/udp: %v\n", err)
+	}
+}
+
+// parseHTTP3 converts on/off/default into a per-domain override, refusing
+// to turn HTTP/3 on when nginx cannot serve it
+func parseHTTP3(value string) (*bool, error) {
+	enabled, err := parseOverride(value)
+	if err != nil || enabled == nil || !*enabled {
+		return enabled, err
+	}
+	if supported, reason := NginxHTTP3Support(); !supported {
+		return nil, fmt.Errorf("HTTP/3 is not available: %s", reason)
+	}
+	return enabled, nil
+}
+
+// pruneHTTP3Tuning removes the shared QUIC settings once no SSL domain
+// wants HTTP/3
+func pruneHTTP3Tuning(domains []Domain) {
+	cfg, err := config.Load()
+	if err != nil || cfg == nil {
+		return
+	}
+	for _, d := range domains {
+		if d.SSLEnabled && http3Requested(d, cfg) {
+			return
+		}
+	}
+	removeHTTP3Tuning()
+}
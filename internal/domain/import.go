@@ -0,0 +1,499 @@
+package domain
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/notify"
+	"webstack-cli/internal/osinfo"
+	"webstack-cli/internal/prompt"
+	"webstack-cli/internal/ui"
+)
+
+// ImportOptions controls how existing vhosts are adopted
+type ImportOptions struct {
+	Files []string // Vhost files to import; empty with Scan for every enabled site
+	Scan  bool     // Scan /etc/nginx/sites-enabled and /etc/apache2/sites-enabled
+	Yes   bool     // Import every importable domain without asking
+}
+
+// importCandidate is a domain found in an existing vhost
+type importCandidate struct {
+	Domain   Domain
+	Aliases  []string
+	Sources  []string // Vhost files the domain was found in
+	Problems []string // Reasons the domain can't be imported
+
+	apache        bool   // Found in an Apache vhost
+	apacheRoot    string // DocumentRoot of the Apache vhost
+	nginxRoot     string // root of the Nginx server block
+	proxiesApache bool   // Nginx proxies the domain to the Apache port
+	upstream      string // Nginx proxies the domain to another app
+}
+
+// vhostDirs are scanned for enabled sites by 'domain import --scan'. On
+// RHEL Apache vhosts are usually dropped into conf.d.
+func vhostDirs() []struct{ server, dir string } {
+	dirs := []struct{ server, dir string }{
+		{"nginx", "/etc/nginx/sites-enabled"},
+		{"apache", apacheSitesEnabled()},
+	}
+	if layout := osinfo.Current(); layout.IsRHEL() {
+		dirs = append(dirs, struct{ server, dir string }{"apache", filepath.Join(layout.ApacheDir, "conf.d")})
+	}
+	return dirs
+}
+
+var (
+	phpSocketVersion = regexp.MustCompile(`php(\d\.\d)-fpm`)
+	vhostNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+)
+
+// vhostSite holds the settings read from one server block or VirtualHost
+type vhostSite struct {
+	server    string // "nginx" or "apache"
+	file      string
+	names     []string
+	root      string
+	phpSocket string
+	proxyPass string
+	sslCert   string
+	sslKey    string
+}
+
+// Import adopts domains served by existing Nginx and Apache vhosts on a
+// server that wasn't set up by webstack. The vhosts are read, each domain is
+// shown for review and the accepted ones are added to domains.json. The
+// existing vhost files are left in place.
+func Import(opts ImportOptions) {
+	files := opts.Files
+	if opts.Scan {
+		for _, d := range vhostDirs() {
+			entries, _ := filepath.Glob(filepath.Join(d.dir, "*"))
+			files = append(files, entries...)
+		}
+	}
+	if len(files) == 0 && opts.Scan {
+		fmt.Printf("ℹ️  No enabled sites in /etc/nginx/sites-enabled or %s\n", apacheSitesEnabled())
+		return
+	}
+	if len(files) == 0 {
+		ui.Invalid("Invalid options: give vhost files to import or --scan for every enabled site\n")
+		return
+	}
+
+	candidates, err := importCandidates(files)
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	if len(candidates) == 0 {
+		fmt.Println("ℹ️  No domains found in the vhosts")
+		return
+	}
+
+	fmt.Printf("🔍 Found %d domain(s):\n", len(candidates))
+	for _, c := range candidates {
+		printImportCandidate(c)
+	}
+
+	imported := 0
+	for _, c := range candidates {
+		if len(c.Problems) > 0 {
+			continue
+		}
+		if !opts.Yes && !prompt.Confirm(fmt.Sprintf("Import %s?", c.Domain.Name)) {
+			fmt.Printf("⏭️  Skipped %s\n", c.Domain.Name)
+			continue
+		}
+		if err := importDomain(c); err != nil {
+			ui.Fail("❌ Could not import %s: %v\n", c.Domain.Name, err)
+			continue
+		}
+		imported++
+	}
+
+	fmt.Println()
+	fmt.Printf("✅ Imported: %d domain(s)\n", imported)
+	if imported > 0 {
+		fmt.Println("   The existing vhosts are still in use. To serve the domains from webstack's templates,")
+		fmt.Println("   review the generated vhosts with 'webstack domain rebuild-configs --diff', disable the")
+		fmt.Println("   old vhost files listed above and run 'webstack domain rebuild-configs'.")
+	}
+}
+
+// importCandidates reads the vhost files and merges the sites found into
+// one candidate per domain, sorted by name
+func importCandidates(files []string) ([]importCandidate, error) {
+	var sites []vhostSite
+	seen := map[string]bool{}
+	for _, file := range files {
+		resolved, err := filepath.EvalSymlinks(file)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %v", file, err)
+		}
+		if seen[resolved] {
+			continue
+		}
+		seen[resolved] = true
+
+		data, err := ioutil.ReadFile(resolved)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %v", file, err)
+		}
+		if strings.HasPrefix(file, osinfo.Current().ApacheDir+"/") || strings.Contains(string(data), "<VirtualHost") {
+			sites = append(sites, parseApacheVhosts(file, string(data))...)
+		} else {
+			sites = append(sites, parseNginxVhosts(file, string(data))...)
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil || cfg == nil {
+		cfg = config.DefaultConfig()
+	}
+	apachePort := cfg.GetPort("apache")
+
+	byName := map[string]*importCandidate{}
+	var names []string
+	for _, site := range sites {
+		name, aliases := primaryName(site.names)
+		if name == "" {
+			continue
+		}
+		c := byName[name]
+		if c == nil {
+			c = &importCandidate{Domain: Domain{Name: name}}
+			byName[name] = c
+			names = append(names, name)
+		}
+		c.Sources = appendUnique(c.Sources, site.file)
+		for _, alias := range aliases {
+			c.Aliases = appendUnique(c.Aliases, alias)
+		}
+		mergeSite(c, site, apachePort)
+	}
+	sort.Strings(names)
+
+	var candidates []importCandidate
+	for _, name := range names {
+		c := byName[name]
+		resolveBackend(c)
+		checkCandidate(c)
+		candidates = append(candidates, *c)
+	}
+	return candidates, nil
+}
+
+// mergeSite adds what a site tells about a domain to its candidate
+func mergeSite(c *importCandidate, site vhostSite, apachePort int) {
+	if site.sslCert != "" && site.sslKey != "" {
+		c.Domain.SSLEnabled = true
+		c.Domain.SSLCertPath, c.Domain.SSLKeyPath = site.sslCert, site.sslKey
+	}
+	if m := phpSocketVersion.FindStringSubmatch(site.phpSocket); m != nil {
+		c.Domain.PHPVersion = m[1]
+	}
+
+	if site.server == "apache" {
+		c.apache = true
+		if site.root != "" {
+			c.apacheRoot = site.root
+		}
+		return
+	}
+	switch {
+	case site.proxyPass != "" && isApacheUpstream(site.proxyPass, apachePort):
+		c.proxiesApache = true
+	case site.proxyPass != "":
+		c.upstream = site.proxyPass
+	case site.root != "":
+		c.nginxRoot = site.root
+	}
+}
+
+// resolveBackend picks the backend of a candidate: Apache behind the Nginx
+// proxy or alone, an app behind the Nginx proxy, or Nginx serving PHP or
+// static files itself
+func resolveBackend(c *importCandidate) {
+	d := &c.Domain
+	switch {
+	case c.apache && (c.proxiesApache || c.nginxRoot == "" && c.upstream == ""):
+		d.Backend = "apache"
+		d.DocumentRoot = c.apacheRoot
+	case c.upstream != "":
+		d.Backend = "proxy"
+		d.Upstream = c.upstream
+	default:
+		d.Backend = "nginx"
+		d.DocumentRoot = c.nginxRoot
+	}
+	if d.Backend != "proxy" && d.PHPVersion == "" {
+		d.Backend = "static"
+	}
+	if d.Backend == "proxy" {
+		d.PHPVersion = ""
+	}
+}
+
+// isApacheUpstream reports whether a proxy_pass target is the local Apache
+// backend port
+func isApacheUpstream(target string, apachePort int) bool {
+	u, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	return (host == "127.0.0.1" || host == "localhost") && u.Port() == strconv.Itoa(apachePort)
+}
+
+// checkCandidate records why a candidate can't be imported
+func checkCandidate(c *importCandidate) {
+	d := &c.Domain
+	if DomainExists(d.Name) {
+		c.Problems = append(c.Problems, "already managed by webstack")
+		return
+	}
+
+	switch d.Backend {
+	case "proxy":
+		upstream, err := parseUpstream(d.Upstream)
+		if err != nil {
+			c.Problems = append(c.Problems, fmt.Sprintf("unsupported proxy_pass %s", d.Upstream))
+		}
+		d.Upstream = upstream
+	case "nginx", "apache":
+		if !isValidPHPVersion(d.PHPVersion) {
+			c.Problems = append(c.Problems, fmt.Sprintf("unsupported PHP version %s", d.PHPVersion))
+		}
+	}
+	if d.Backend != "proxy" {
+		if d.DocumentRoot == "" {
+			c.Problems = append(c.Problems, "no document root found")
+		} else if root, err := CleanFolder(d.DocumentRoot); err != nil {
+			c.Problems = append(c.Problems, fmt.Sprintf("document root: %v", err))
+		} else {
+			d.DocumentRoot = root
+		}
+	}
+	if d.SSLEnabled {
+		for _, path := range []string{d.SSLCertPath, d.SSLKeyPath} {
+			if _, err := os.Stat(path); err != nil {
+				c.Problems = append(c.Problems, fmt.Sprintf("certificate file %s not found", path))
+			}
+		}
+	}
+}
+
+func printImportCandidate(c importCandidate) {
+	d := c.Domain
+	if len(c.Problems) > 0 {
+		ui.Warn("\n⚠️  %s\n", d.Name)
+	} else {
+		fmt.Printf("\n✅ %s\n", d.Name)
+	}
+	if len(c.Aliases) > 0 {
+		fmt.Printf("   Aliases: %s\n", strings.Join(c.Aliases, ", "))
+	}
+	fmt.Printf("   Backend: %s\n", d.Backend)
+	if d.Upstream != "" {
+		fmt.Printf("   Upstream: %s\n", d.Upstream)
+	}
+	if d.PHPVersion != "" {
+		fmt.Printf("   PHP Version: %s\n", d.PHPVersion)
+	}
+	if d.DocumentRoot != "" {
+		fmt.Printf("   Document Root: %s\n", d.DocumentRoot)
+	}
+	if d.SSLEnabled {
+		fmt.Printf("   SSL: %s\n", d.SSLCertPath)
+	}
+	fmt.Printf("   Source: %s\n", strings.Join(c.Sources, ", "))
+	for _, problem := range c.Problems {
+		fmt.Printf("   Not importable: %s\n", problem)
+	}
+}
+
+// importDomain adds a reviewed candidate to domains.json without touching
+// its vhosts, creating the logs, configs and error folders webstack's
+// templates use
+func importDomain(c importCandidate) error {
+	webRoot := config.FallbackWebRoot
+	if cfg, err := config.Load(); err == nil && cfg != nil {
+		webRoot = cfg.WebRoot()
+	}
+	d := c.Domain
+	d.Home = filepath.Join(webRoot, d.Name)
+
+	for _, dir := range []string{"logs", "configs", "error"} {
+		if err := dryrun.MkdirAll(filepath.Join(d.Home, dir), 0755); err != nil {
+			return fmt.Errorf("could not create %s: %v", filepath.Join(d.Home, dir), err)
+		}
+	}
+	if err := saveDomain(d); err != nil {
+		return fmt.Errorf("could not save domain: %v", err)
+	}
+
+	fmt.Printf("✅ Imported %s\n", d.Name)
+	notify.Send(notify.DomainAdded, d.Name, "Domain "+d.Name+" imported", "Imported from "+strings.Join(c.Sources, ", "))
+	return nil
+}
+
+// primaryName picks the domain of a vhost from its names: the bare name
+// when both example.com and www.example.com are listed. Catch-all, wildcard
+// and regex names are ignored.
+func primaryName(names []string) (string, []string) {
+	var valid []string
+	for _, name := range names {
+		name = Normalize(name)
+		if vhostNamePattern.MatchString(name) {
+			valid = appendUnique(valid, name)
+		}
+	}
+	if len(valid) == 0 {
+		return "", nil
+	}
+
+	primary := valid[0]
+	if strings.HasPrefix(primary, "www.") {
+		for _, name := range valid {
+			if name == strings.TrimPrefix(primary, "www.") {
+				primary = name
+			}
+		}
+	}
+	var aliases []string
+	for _, name := range valid {
+		if name != primary {
+			aliases = append(aliases, name)
+		}
+	}
+	return primary, aliases
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
+// parseNginxVhosts reads the top-level server blocks of an Nginx config.
+// The config is split into statements ending with ;, { or } so one-line
+// blocks are read too.
+func parseNginxVhosts(file, content string) []vhostSite {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		lines = append(lines, line)
+	}
+
+	var sites []vhostSite
+	var site *vhostSite
+	depth := 0
+	statement := ""
+	for _, r := range strings.Join(lines, " ") {
+		if r != ';' && r != '{' && r != '}' {
+			statement += string(r)
+			continue
+		}
+		fields := strings.Fields(statement)
+		statement = ""
+
+		switch r {
+		case '{':
+			if depth == 0 && len(fields) == 1 && fields[0] == "server" {
+				sites = append(sites, vhostSite{server: "nginx", file: file})
+				site = &sites[len(sites)-1]
+			}
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+			if depth == 0 {
+				site = nil
+			}
+		case ';':
+			if site == nil || len(fields) < 2 {
+				continue
+			}
+			value := strings.Trim(fields[1], `"'`)
+			switch fields[0] {
+			case "server_name":
+				site.names = append(site.names, fields[1:]...)
+			case "root":
+				// The root of the server block, not of a nested location
+				if depth == 1 {
+					site.root = value
+				}
+			case "fastcgi_pass":
+				site.phpSocket = value
+			case "proxy_pass":
+				if site.proxyPass == "" {
+					site.proxyPass = value
+				}
+			case "ssl_certificate":
+				site.sslCert = value
+			case "ssl_certificate_key":
+				site.sslKey = value
+			}
+		}
+	}
+	return sites
+}
+
+// parseApacheVhosts reads the VirtualHost sections of an Apache config
+func parseApacheVhosts(file, content string) []vhostSite {
+	var sites []vhostSite
+	var site *vhostSite
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		lower := strings.ToLower(line)
+		if strings.HasPrefix(lower, "<virtualhost") {
+			sites = append(sites, vhostSite{server: "apache", file: file})
+			site = &sites[len(sites)-1]
+			continue
+		}
+		if strings.HasPrefix(lower, "</virtualhost") {
+			site = nil
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if site == nil || len(fields) < 2 {
+			continue
+		}
+		value := strings.Trim(fields[1], `"'`)
+		switch strings.ToLower(fields[0]) {
+		case "servername", "serveralias":
+			site.names = append(site.names, fields[1:]...)
+		case "documentroot":
+			site.root = value
+		case "sethandler", "proxypassmatch":
+			if strings.Contains(line, "fpm") {
+				site.phpSocket = line
+			}
+		case "sslcertificatefile":
+			site.sslCert = value
+		case "sslcertificatekeyfile":
+			site.sslKey = value
+		}
+	}
+	return sites
+}
@@ -0,0 +1,213 @@
+package domain
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/ui"
+)
+
+// Log kinds accepted by TailLogs
+const (
+	LogAccess = "access"
+	LogError  = "error"
+	LogPHP    = "php"
+)
+
+// logrotate keeps this many rotated files of each domain log
+const logRotateKeep = 14
+
+// logsDir returns the directory holding the access, error and PHP logs of a domain
+func logsDir(domainName string) string {
+	return filepath.Join(HomeDir(domainName), "logs")
+}
+
+// logrotatePath returns the logrotate configuration deployed for a domain
+func logrotatePath(domainName string) string {
+	return filepath.Join("/etc/logrotate.d", "webstack-"+domainName)
+}
+
+// logFiles returns the log files of a kind for a domain. Nginx writes
+// access.log and error.log, an Apache backend apache-access.log and
+// apache-error.log, and a dedicated PHP-FPM pool php-error.log; domains on
+// the shared pool log PHP errors to the version's php-fpm log.
+func logFiles(d Domain, kind string) []string {
+	dir := logsDir(d.Name)
+	switch kind {
+	case LogAccess:
+		files := []string{filepath.Join(dir, "access.log")}
+		if d.Backend == "apache" {
+			files = append(files, filepath.Join(dir, "apache-access.log"))
+		}
+		return files
+	case LogError:
+		files := []string{filepath.Join(dir, "error.log")}
+		if d.Backend == "apache" {
+			files = append(files, filepath.Join(dir, "apache-error.log"))
+		}
+		return files
+	case LogPHP:
+		if !usesPHP(d) {
+			return nil
+		}
+		if len(d.PHPSettings) > 0 {
+			return []string{filepath.Join(dir, "php-error.log")}
+		}
+		return []string{fmt.Sprintf("/var/log/php%s-fpm.log", d.PHPVersion)}
+	}
+	return nil
+}
+
+// TailLogs prints the last lines of a domain's logs of the given kinds
+// (access and error when none are given) and keeps following them
+func TailLogs(domainName string, kinds []string, lines int, follow bool) {
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+
+	if len(kinds) == 0 {
+		kinds = []string{LogAccess, LogError}
+	}
+
+	var files []string
+	for _, kind := range kinds {
+		for _, file := range logFiles(*d, kind) {
+			if _, err := os.Stat(file); err == nil {
+				files = append(files, file)
+			}
+		}
+	}
+	if len(files) == 0 {
+		ui.Warn("⚠️  No log files found for %s in %s\n", d.Name, logsDir(d.Name))
+		fmt.Println("   Vhosts generated by older versions log to /var/log/nginx; run 'sudo webstack domain rebuild-configs'")
+		return
+	}
+
+	args := []string{"-n", strconv.Itoa(lines)}
+	if follow {
+		args = append(args, "-F")
+	}
+	// Log lines go to the terminal as they are, also with tail -F
+	cmd := exec.Command("tail", append(args, files...)...)
+	cmd.Stdout, cmd.Stderr = ui.Terminal()
+	cmd.Run()
+}
+
+// RotateLogs forces a rotation of the logs of one domain, or of all domains
+// when domainName is empty, deploying missing logrotate configurations first
+func RotateLogs(domainName string) {
+	var domains []Domain
+	if domainName != "" {
+		d, err := GetDomain(domainName)
+		if err != nil {
+			ui.Fail("Domain %s not found\n", domainName)
+			return
+		}
+		domains = append(domains, *d)
+	} else {
+		all, err := loadDomains()
+		if err != nil {
+			ui.Fail("Error loading domains: %v\n", err)
+			return
+		}
+		domains = all
+	}
+
+	if _, err := exec.LookPath("logrotate"); err != nil {
+		ui.Fail("❌ logrotate is not installed (apt install logrotate)\n")
+		return
+	}
+
+	for _, d := range domains {
+		if err := writeLogrotate(d); err != nil {
+			ui.Fail("❌ %v\n", err)
+			continue
+		}
+
+		cmd := exec.Command("logrotate", "--force", logrotatePath(d.Name))
+		if output, err := dryrun.CombinedOutput(cmd); err != nil {
+			ui.Fail("❌ Could not rotate logs of %s: %v\n%s", d.Name, err, output)
+			continue
+		}
+		fmt.Printf("✅ Logs rotated for %s\n", d.Name)
+	}
+}
+
+// ensureLogsDir creates the logs directory of a domain; nginx and Apache
+// refuse to start when a log directory is missing
+func ensureLogsDir(d Domain) error {
+	if err := dryrun.MkdirAll(logsDir(d.Name), 0755); err != nil {
+		return fmt.Errorf("could not create %s: %v", logsDir(d.Name), err)
+	}
+	return nil
+}
+
+// ensurePHPLog creates the PHP error log of a dedicated pool, owned by the
+// pool user because the workers open it themselves
+func ensurePHPLog(d Domain) {
+	path := filepath.Join(logsDir(d.Name), "php-error.log")
+	if _, err := os.Stat(path); err == nil || dryrun.Enabled() {
+		return
+	}
+	if err := touchFile(path); err != nil {
+		ui.Warn("⚠️  Warning: Could not create %s: %v\n", path, err)
+		return
+	}
+	exec.Command("chown", "www-data:www-data", path).Run()
+}
+
+func touchFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// writeLogrotate deploys the logrotate configuration of a domain. Logs are
+// truncated in place (copytruncate), so nginx, Apache and PHP-FPM keep
+// writing without being signalled.
+func writeLogrotate(d Domain) error {
+	content := fmt.Sprintf(`# Managed by webstack - rotates the logs of %s
+%s/*.log {
+	daily
+	rotate %d
+	missingok
+	notifempty
+	compress
+	delaycompress
+	dateext
+	copytruncate
+}
+`, d.Name, logsDir(d.Name), logRotateKeep)
+
+	// Nothing to deploy to when logrotate is not installed
+	if _, err := os.Stat(filepath.Dir(logrotatePath(d.Name))); os.IsNotExist(err) {
+		return nil
+	}
+
+	path := logrotatePath(d.Name)
+	if current, err := ioutil.ReadFile(path); err == nil && string(current) == content {
+		return nil
+	}
+	if err := dryrun.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("could not write logrotate configuration %s: %v", path, err)
+	}
+	return nil
+}
+
+// removeLogrotate removes the logrotate configuration of a deleted domain
+func removeLogrotate(domainName string) {
+	if err := dryrun.Remove(logrotatePath(domainName)); err != nil && !os.IsNotExist(err) {
+		ui.Warn("⚠️  Warning: Could not remove logrotate configuration: %v\n", err)
+	}
+}
@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"webstack-cli/internal/ui"
+)
+
+// Normalize returns the canonical form of a domain name as stored in the
+// state files: surrounding whitespace and trailing dots removed, lowercased.
+// "Example.COM." and "example.com" name the same site.
+func Normalize(domainName string) string {
+	return strings.ToLower(strings.TrimRight(strings.TrimSpace(domainName), "."))
+}
+
+// ValidName reports whether a normalized domain name can name the
+// directories and vhost files of a site
+func ValidName(domainName string) bool {
+	return domainName != "" && !strings.ContainsAny(domainName, "/ \t")
+}
+
+// dedupeDomains normalizes the names in domains.json and drops entries that
+// only differed by case or a trailing dot. The entry with SSL enabled wins,
+// otherwise the first one. It returns the names of the dropped entries.
+func dedupeDomains(domains []Domain) ([]Domain, []string) {
+	var result []Domain
+	var originals, dropped []string
+	index := map[string]int{}
+
+	for _, d := range domains {
+		original := d.Name
+		d.Name = Normalize(d.Name)
+
+		i, seen := index[d.Name]
+		if !seen {
+			index[d.Name] = len(result)
+			result = append(result, d)
+			originals = append(originals, original)
+			continue
+		}
+
+		if d.SSLEnabled && !result[i].SSLEnabled {
+			dropped = append(dropped, originals[i])
+			result[i], originals[i] = d, original
+			continue
+		}
+		dropped = append(dropped, original)
+	}
+	return result, dropped
+}
+
+// migrateDomainNames rewrites domains.json when it holds names that are not
+// normalized or duplicates, e.g. "Example.com." added by older versions next
+// to "example.com"
+func migrateDomainNames(domains []Domain) []Domain {
+	migrated, dropped := dedupeDomains(domains)
+
+	changed := len(dropped) > 0
+	for i := range migrated {
+		if migrated[i].Name != domains[i].Name {
+			changed = true
+		}
+	}
+	if !changed {
+		return domains
+	}
+
+	if err := saveDomains(migrated); err != nil {
+		ui.Warn("⚠️  Warning: Could not normalize domain names in %s: %v\n", domainsFile, err)
+		return migrated
+	}
+
+	fmt.Printf("ℹ️  Normalized domain names in %s\n", domainsFile)
+	for _, name := range dropped {
+		fmt.Printf("   Removed duplicate entry %q\n", name)
+	}
+	fmt.Println("   Run 'webstack domain rebuild-configs' to regenerate the vhosts under the normalized names")
+	return migrated
+}
+
+// nameVariants returns the vhosts written for another spelling of a
+// domain, e.g. Example.com.conf left next to example.com.conf
+func nameVariants(d Domain) []Domain {
+	var variants []Domain
+	seen := map[string]bool{}
+	for _, dir := range []string{"/etc/nginx/sites-available", apacheSitesAvailable()} {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.conf"))
+		for _, file := range files {
+			name := strings.TrimSuffix(filepath.Base(file), ".conf")
+			if name == d.Name || seen[name] || Normalize(name) != d.Name {
+				continue
+			}
+			seen[name] = true
+
+			variant := Domain{Name: name, Backend: "nginx"}
+			if _, err := os.Stat(filepath.Join(apacheSitesAvailable(), name+".conf")); err == nil {
+				variant.Backend = "apache"
+			}
+			variants = append(variants, variant)
+		}
+	}
+	return variants
+}
+
+// removeNameVariants removes vhosts written for another spelling of a domain
+func removeNameVariants(d Domain) {
+	for _, variant := range nameVariants(d) {
+		removeConfig(variant)
+	}
+}
@@ -0,0 +1,301 @@
+package domain
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/osinfo"
+	"webstack-cli/internal/phpfpm"
+	"webstack-cli/internal/service"
+	"webstack-cli/internal/templates"
+	"webstack-cli/internal/ui"
+)
+
+// PHPSettingsOptions holds the php.ini overrides changed on a domain
+type PHPSettingsOptions struct {
+	Set   map[string]string // Directive => value, e.g. memory_limit => 512M
+	Unset []string          // Directives to drop back to the default
+	Reset bool              // Remove all overrides (and the dedicated pool)
+}
+
+// defaultPHPSettings mirrors the shared webstack pool so a dedicated pool only
+// differs in the directives the domain overrides
+var defaultPHPSettings = map[string]string{
+	"disable_functions":   "exec,passthru,shell_exec,system,proc_open,popen",
+	"allow_url_fopen":     "off",
+	"allow_url_include":   "off",
+	"max_execution_time":  "300",
+	"max_input_time":      "300",
+	"memory_limit":        "256M",
+	"post_max_size":       "100M",
+	"upload_max_filesize": "100M",
+}
+
+var (
+	phpDirectivePattern = regexp.MustCompile(`^[a-z][a-z0-9_.]*$`)
+	phpSizePattern      = regexp.MustCompile(`^(-1|[0-9]+[KMG]?)$`)
+	phpSizeDirectives   = map[string]bool{"memory_limit": true, "post_max_size": true, "upload_max_filesize": true}
+)
+
+// PHPSettings changes the php.ini overrides of a domain. Domains with
+// overrides get a dedicated PHP-FPM pool with php_admin_value entries; the
+// overrides are stored in domains.json so rebuild-configs keeps them.
+func PHPSettings(domainName string, opts PHPSettingsOptions) {
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+	if !usesPHP(*d) {
+		ui.Fail("%s is a %s domain and doesn't use PHP\n", d.Name, d.Backend)
+		return
+	}
+
+	if !opts.Reset && len(opts.Set) == 0 && len(opts.Unset) == 0 {
+		listPHPSettings(*d)
+		return
+	}
+
+	for key, value := range opts.Set {
+		if err := validatePHPSetting(key, value); err != nil {
+			ui.Invalid("Invalid PHP setting: %v\n", err)
+			return
+		}
+	}
+
+	previous := *d
+	settings := map[string]string{}
+	if !opts.Reset {
+		for key, value := range d.PHPSettings {
+			settings[key] = value
+		}
+	}
+	for _, key := range opts.Unset {
+		delete(settings, key)
+	}
+	for key, value := range opts.Set {
+		settings[key] = value
+	}
+	d.PHPSettings = settings
+	if len(settings) == 0 {
+		d.PHPSettings = nil
+	}
+
+	if err := saveDomain(*d); err != nil {
+		ui.Fail("Error saving domain: %v\n", err)
+		return
+	}
+
+	// The pool must be running before the vhost points at its socket
+	versions, err := writePHPPool(*d)
+	if err == nil {
+		reloadPHPFPM(versions)
+		err = applyConfig(*d, false)
+	}
+	if err != nil {
+		ui.Fail("Error applying PHP settings: %v\n", err)
+		if err := saveDomain(previous); err != nil {
+			ui.Warn("⚠️  Warning: Could not restore domain entry: %v\n", err)
+		}
+		if versions, err := writePHPPool(previous); err == nil {
+			reloadPHPFPM(versions)
+		}
+		return
+	}
+
+	reloadWebServers()
+	smokeTest(*d)
+
+	fmt.Printf("✅ PHP settings updated for %s\n", d.Name)
+	listPHPSettings(*d)
+}
+
+func listPHPSettings(d Domain) {
+	if len(d.PHPSettings) == 0 {
+		fmt.Printf("ℹ️  %s has no PHP overrides and uses the shared PHP %s pool\n", d.Name, d.PHPVersion)
+		return
+	}
+
+	fmt.Printf("PHP overrides for %s (pool %s, PHP %s):\n", d.Name, d.Name, d.PHPVersion)
+	for _, key := range sortedKeys(d.PHPSettings) {
+		fmt.Printf("   %s = %s\n", key, d.PHPSettings[key])
+	}
+}
+
+func validatePHPSetting(key, value string) error {
+	if !phpDirectivePattern.MatchString(key) {
+		return fmt.Errorf("%q is not a php.ini directive", key)
+	}
+	if value == "" || strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("%s needs a single-line value", key)
+	}
+	if phpSizeDirectives[key] && !phpSizePattern.MatchString(value) {
+		return fmt.Errorf("%s must be a size like 512M or -1: %s", key, value)
+	}
+	return nil
+}
+
+// phpPoolPath returns the dedicated pool file of a domain for a PHP version
+func phpPoolPath(domainName, version string) string {
+	return filepath.Join(osinfo.Current().PHPPoolDir(version), domainName+".conf")
+}
+
+// phpSocket returns the PHP-FPM socket a domain's vhost passes requests to
+func phpSocket(d Domain) string {
+	if len(d.PHPSettings) > 0 {
+		return fmt.Sprintf("unix:/run/php/php%s-fpm-%s.sock", d.PHPVersion, d.Name)
+	}
+	return fmt.Sprintf("unix:/run/php/php%s-fpm.sock", d.PHPVersion)
+}
+
+// writePHPPool writes the dedicated pool of a domain with overrides and
+// removes pools left for other PHP versions (or all of them when the domain
+// has no overrides). It returns the PHP versions whose pools changed.
+func writePHPPool(d Domain) ([]string, error) {
+	var changed []string
+
+	// Remove stale pools, e.g. after the PHP version changed
+	stale, _ := filepath.Glob(phpPoolPath(d.Name, "*"))
+	for _, path := range stale {
+		version := filepath.Base(filepath.Dir(filepath.Dir(filepath.Dir(path))))
+		if version == d.PHPVersion && len(d.PHPSettings) > 0 {
+			continue
+		}
+		if err := dryrun.Remove(path); err != nil && !os.IsNotExist(err) {
+			return changed, fmt.Errorf("could not remove PHP-FPM pool %s: %v", path, err)
+		}
+		changed = append(changed, version)
+	}
+
+	if len(d.PHPSettings) == 0 {
+		return changed, nil
+	}
+
+	content, err := renderPHPPool(d)
+	if err != nil {
+		return changed, err
+	}
+
+	path := phpPoolPath(d.Name, d.PHPVersion)
+	previous, readErr := ioutil.ReadFile(path)
+	if readErr == nil {
+		// Keep the process manager values set with 'webstack php tune'
+		content = phpfpm.PreserveTuning(previous, content, d.Name)
+	}
+	if readErr == nil && bytes.Equal(previous, content) {
+		return changed, nil
+	}
+
+	if err := dryrun.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return changed, fmt.Errorf("could not create %s: %v", filepath.Dir(path), err)
+	}
+	ensurePHPLog(d)
+	if err := dryrun.WriteFile(path, content, 0644); err != nil {
+		return changed, fmt.Errorf("could not write PHP-FPM pool: %v", err)
+	}
+
+	// Validate before php-fpm is reloaded, restoring the previous pool on failure
+	if err := TestPHPFPM(d.PHPVersion); err != nil {
+		if readErr == nil {
+			ioutil.WriteFile(path, previous, 0644)
+		} else {
+			os.Remove(path)
+		}
+		return changed, err
+	}
+
+	fmt.Printf("✅ PHP-FPM pool written: %s\n", path)
+	return append(changed, d.PHPVersion), nil
+}
+
+func renderPHPPool(d Domain) ([]byte, error) {
+	content, err := templates.GetPHPTemplate("domain-pool.conf")
+	if err != nil {
+		return nil, fmt.Errorf("could not read PHP-FPM pool template: %v", err)
+	}
+
+	tmpl, err := template.New("domain-pool").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse PHP-FPM pool template: %v", err)
+	}
+
+	settings := map[string]string{}
+	for key, value := range defaultPHPSettings {
+		settings[key] = value
+	}
+	for key, value := range d.PHPSettings {
+		settings[key] = value
+	}
+
+	type setting struct{ Key, Value string }
+	var rendered []setting
+	for _, key := range sortedKeys(settings) {
+		rendered = append(rendered, setting{key, settings[key]})
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, map[string]interface{}{
+		"PHPVersion": d.PHPVersion,
+		"PoolName":   d.Name,
+		"Socket":     strings.TrimPrefix(phpSocket(d), "unix:"),
+		"LogsDir":    logsDir(d.Name),
+		"Settings":   rendered,
+	}); err != nil {
+		return nil, fmt.Errorf("could not render PHP-FPM pool template: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// TestPHPFPM validates the PHP-FPM configuration of a version
+func TestPHPFPM(version string) error {
+	if dryrun.Enabled() {
+		return nil
+	}
+	binary := osinfo.Current().PHPFPMBinary(version)
+	if _, err := exec.LookPath(binary); err != nil {
+		return nil // PHP version not installed, nothing to validate
+	}
+	if output, err := exec.Command(binary, "-t").CombinedOutput(); err != nil {
+		return fmt.Errorf("%s -t failed:\n%s", binary, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// reloadPHPFPM reloads the php-fpm services of the given versions
+func reloadPHPFPM(versions []string) {
+	seen := map[string]bool{}
+	for _, version := range versions {
+		if seen[version] {
+			continue
+		}
+		seen[version] = true
+
+		unit := osinfo.Current().PHPFPMService(version)
+		err := service.Reload(unit)
+		switch {
+		case err == service.ErrNotInstalled:
+			continue
+		case err != nil:
+			ui.Warn("⚠️  Warning: Could not reload %s: %v\n", unit, err)
+		default:
+			fmt.Printf("✅ %s reloaded\n", unit)
+		}
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/osinfo"
+	"webstack-cli/internal/phpfpm"
+	"webstack-cli/internal/service"
+	"webstack-cli/internal/ui"
+)
+
+// PHPInstalled reports whether the php-fpm service of a version exists
+func PHPInstalled(version string) bool {
+	_, err := service.Status(osinfo.Current().PHPFPMService(version))
+	return err != service.ErrNotInstalled
+}
+
+// checkPHPFPM makes sure a PHP version can take over a domain: its php-fpm
+// service runs and the shared pool's socket exists
+func checkPHPFPM(version string) error {
+	unit := osinfo.Current().PHPFPMService(version)
+	status, err := service.Status(unit)
+	switch {
+	case err == service.ErrNotInstalled:
+		return fmt.Errorf("PHP %s is not installed (use 'webstack install php %s')", version, version)
+	case err != nil:
+		return fmt.Errorf("could not check %s: %v", unit, err)
+	case status.Active != "active":
+		return fmt.Errorf("%s is %s (start it with 'systemctl start %s')", unit, status.Active, unit)
+	}
+
+	socket := fmt.Sprintf("/run/php/php%s-fpm.sock", version)
+	if _, err := os.Stat(socket); err != nil {
+		return fmt.Errorf("%s is running but %s is missing (run 'webstack install php %s' to restore the webstack pool)", unit, socket, version)
+	}
+	return nil
+}
+
+// pingPHPFPM sends a FastCGI request to the socket the domain's vhost will
+// pass requests to, before the web servers are reloaded onto it
+func pingPHPFPM(d Domain) error {
+	socket := phpSocket(d)
+	if dryrun.Enabled() {
+		fmt.Printf("🔎 [dry-run] would ping PHP-FPM on %s\n", strings.TrimPrefix(socket, "unix:"))
+		return nil
+	}
+	if err := phpfpm.Ping(socket); err != nil {
+		return fmt.Errorf("PHP-FPM does not answer on %s: %v", strings.TrimPrefix(socket, "unix:"), err)
+	}
+	return nil
+}
+
+// restoreDomain puts back the entry of a domain whose change was rejected,
+// and its dedicated PHP-FPM pool when the change had moved it
+func restoreDomain(domains []Domain, i int, previous Domain, movedPool bool) {
+	domains[i] = previous
+	if err := saveDomains(domains); err != nil {
+		ui.Warn("⚠️  Warning: Could not restore domain entry: %v\n", err)
+	}
+	if movedPool {
+		versions, err := writePHPPool(previous)
+		if err != nil {
+			ui.Warn("⚠️  Warning: Could not restore PHP-FPM pool: %v\n", err)
+		}
+		reloadPHPFPM(versions)
+	}
+}
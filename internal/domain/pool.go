@@ -0,0 +1,393 @@
+package domain
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/store"
+	"webstack-cli/internal/ui"
+)
+
+const poolsFile = "/etc/webstack/upstreams.json"
+
+var poolsStore = store.New(poolsFile, 1)
+
+// nginxUpstreamsConf holds the upstream blocks of the pools, loaded by
+// nginx.conf like the rate limiting include
+const nginxUpstreamsConf = "/etc/nginx/includes/upstreams.conf"
+
+// poolNamePattern keeps pool names usable in an nginx upstream name and
+// apart from app URLs, which always have a scheme
+var poolNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// PoolMethods are the balancing methods of a pool, mapped to the nginx
+// directive selecting them (round robin is the nginx default)
+var PoolMethods = map[string]string{
+	"round-robin": "",
+	"least-conn":  "least_conn;",
+	"ip-hash":     "ip_hash;",
+	"random":      "random two least_conn;",
+}
+
+// Default health check and keepalive settings of new pools
+const (
+	defaultMaxFails    = 3
+	defaultFailTimeout = 30
+	defaultKeepalive   = 32
+)
+
+// Pool is a named group of app servers proxy domains balance requests
+// across, referred to by name with --upstream
+type Pool struct {
+	Name        string   `json:"name"`
+	Servers     []string `json:"servers"`          // host:port
+	Backup      []string `json:"backup,omitempty"` // Only used when every server is down
+	Method      string   `json:"method"`           // Key of PoolMethods
+	MaxFails    int      `json:"max_fails"`        // Failed attempts before a server is skipped; 0 never skips
+	FailTimeout int      `json:"fail_timeout"`     // Seconds a failed server is skipped for
+	Keepalive   int      `json:"keepalive"`        // Idle connections kept open per worker; 0 for none
+}
+
+// PoolOptions holds the settings of 'webstack upstream create/edit'. Zero
+// values keep the current setting (or the default of a new pool).
+type PoolOptions struct {
+	Servers     []string // Replaces the servers
+	Backup      []string // Replaces the backup servers; "none" removes them
+	Method      string
+	MaxFails    int // -1 disables the health check
+	FailTimeout int
+	Keepalive   int // -1 disables keepalive
+}
+
+// isPoolName reports whether an --upstream value names a pool rather than
+// an app URL
+func isPoolName(value string) bool {
+	return poolNamePattern.MatchString(value)
+}
+
+// poolUpstream returns the nginx upstream name of a pool
+func poolUpstream(name string) string {
+	return "webstack_" + strings.ReplaceAll(name, "-", "_")
+}
+
+// loadPools reads upstreams.json
+func loadPools() ([]Pool, error) {
+	var pools []Pool
+	if !poolsStore.Exists() {
+		return pools, nil
+	}
+	if err := poolsStore.Load(&pools); err != nil {
+		return nil, err
+	}
+	return pools, nil
+}
+
+// findPool returns the pool with a name
+func findPool(pools []Pool, name string) (*Pool, bool) {
+	for i := range pools {
+		if pools[i].Name == name {
+			return &pools[i], true
+		}
+	}
+	return nil, false
+}
+
+// GetPool returns a pool by name
+func GetPool(name string) (*Pool, error) {
+	pools, err := loadPools()
+	if err != nil {
+		return nil, err
+	}
+	pool, ok := findPool(pools, name)
+	if !ok {
+		return nil, fmt.Errorf("upstream %s not found (see 'webstack upstream list')", name)
+	}
+	return pool, nil
+}
+
+// validateServer checks a host:port server address
+func validateServer(server string) error {
+	host, port, err := net.SplitHostPort(server)
+	if err != nil || host == "" {
+		return fmt.Errorf("%s (use host:port, e.g. 10.0.0.2:8080)", server)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%s has an invalid port", server)
+	}
+	if strings.ContainsAny(host, " \t;{}\"'$/") {
+		return fmt.Errorf("%s contains characters not allowed in an upstream", server)
+	}
+	return nil
+}
+
+// apply changes a pool with opts and validates the result
+func (p *Pool) apply(opts PoolOptions) error {
+	if len(opts.Servers) > 0 {
+		p.Servers = opts.Servers
+	}
+	if len(opts.Backup) == 1 && opts.Backup[0] == "none" {
+		p.Backup = nil
+	} else if len(opts.Backup) > 0 {
+		p.Backup = opts.Backup
+	}
+	if opts.Method != "" {
+		p.Method = opts.Method
+	}
+	if opts.MaxFails != 0 {
+		p.MaxFails = max(opts.MaxFails, 0)
+	}
+	if opts.FailTimeout != 0 {
+		p.FailTimeout = opts.FailTimeout
+	}
+	if opts.Keepalive != 0 {
+		p.Keepalive = max(opts.Keepalive, 0)
+	}
+
+	if len(p.Servers) == 0 {
+		return fmt.Errorf("an upstream needs at least one --server")
+	}
+	seen := map[string]bool{}
+	for _, server := range append(append([]string{}, p.Servers...), p.Backup...) {
+		if err := validateServer(server); err != nil {
+			return err
+		}
+		if seen[server] {
+			return fmt.Errorf("%s is listed twice", server)
+		}
+		seen[server] = true
+	}
+	if _, ok := PoolMethods[p.Method]; !ok {
+		return fmt.Errorf("unknown balancing method %s (use round-robin, least-conn, ip-hash or random)", p.Method)
+	}
+	if len(p.Backup) > 0 && (p.Method == "ip-hash" || p.Method == "random") {
+		return fmt.Errorf("backup servers can't be used with the %s method", p.Method)
+	}
+	if p.FailTimeout < 1 {
+		return fmt.Errorf("--fail-timeout must be at least 1 second")
+	}
+	return nil
+}
+
+// renderPools returns the nginx include defining every pool
+func renderPools(pools []Pool) string {
+	var b strings.Builder
+	b.WriteString("# WebStack CLI - Nginx upstream pools (managed with 'webstack upstream')\n")
+	b.WriteString("# Proxy domains refer to them with --upstream <name>\n")
+	b.WriteString("\n# Keeps upstream connections reusable unless the client upgrades (WebSocket)\n")
+	b.WriteString("map $http_upgrade $webstack_upstream_connection {\n\tdefault upgrade;\n\t''      '';\n}\n")
+	for _, p := range pools {
+		fmt.Fprintf(&b, "\n# %s\nupstream %s {\n", p.Name, poolUpstream(p.Name))
+		if directive := PoolMethods[p.Method]; directive != "" {
+			fmt.Fprintf(&b, "\t%s\n", directive)
+		}
+		health := fmt.Sprintf(" max_fails=%d fail_timeout=%ds", p.MaxFails, p.FailTimeout)
+		for _, server := range p.Servers {
+			fmt.Fprintf(&b, "\tserver %s%s;\n", server, health)
+		}
+		for _, server := range p.Backup {
+			fmt.Fprintf(&b, "\tserver %s%s backup;\n", server, health)
+		}
+		if p.Keepalive > 0 {
+			fmt.Fprintf(&b, "\tkeepalive %d;\n", p.Keepalive)
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+// writePools writes the upstream blocks of the pools
+func writePools(pools []Pool) error {
+	if _, err := os.Stat("/etc/nginx"); err != nil {
+		return nil // Apache-only server, the pools wait for Nginx
+	}
+	if err := dryrun.MkdirAll("/etc/nginx/includes", 0755); err != nil {
+		return fmt.Errorf("could not create /etc/nginx/includes: %v", err)
+	}
+	if err := dryrun.WriteFile(nginxUpstreamsConf, []byte(renderPools(pools)), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", nginxUpstreamsConf, err)
+	}
+	return nil
+}
+
+// updatePools changes the pools under the upstreams.json lock and rewrites
+// their include, validated with nginx -t. The previous include is put back
+// and nothing is stored when Nginx rejects the change.
+func updatePools(fn func(pools []Pool) ([]Pool, error)) error {
+	var pools []Pool
+	return poolsStore.Update(&pools, func() error {
+		previous := append([]Pool{}, pools...)
+		changed, err := fn(pools)
+		if err != nil {
+			return err
+		}
+		if err := writePools(changed); err != nil {
+			return err
+		}
+		if !dryrun.Enabled() {
+			if err := testWebServers(map[string]bool{"nginx": true}); err != nil {
+				writePools(previous)
+				return err
+			}
+		}
+		pools = changed
+		return nil
+	})
+}
+
+// poolUsers returns the proxy domains using a pool
+func poolUsers(name string) []string {
+	domains, _ := loadDomains()
+	var users []string
+	for _, d := range domains {
+		if d.Backend == "proxy" && d.Upstream == name {
+			users = append(users, d.Name)
+		}
+	}
+	return users
+}
+
+// CreatePool adds an upstream pool
+func CreatePool(name string, opts PoolOptions) {
+	if !isPoolName(name) {
+		ui.Invalid("Invalid upstream name: %s (use lowercase letters, digits, - and _)\n", name)
+		return
+	}
+	pool := Pool{Name: name, Method: "round-robin", MaxFails: defaultMaxFails, FailTimeout: defaultFailTimeout, Keepalive: defaultKeepalive}
+	if err := pool.apply(opts); err != nil {
+		ui.Invalid("Invalid upstream: %v\n", err)
+		return
+	}
+
+	err := updatePools(func(pools []Pool) ([]Pool, error) {
+		if _, exists := findPool(pools, name); exists {
+			return nil, fmt.Errorf("it already exists (change it with 'webstack upstream edit')")
+		}
+		return append(pools, pool), nil
+	})
+	if err != nil {
+		ui.Fail("❌ Could not create upstream %s: %v\n", name, err)
+		return
+	}
+	reloadServers(map[string]bool{"nginx": true})
+
+	fmt.Printf("✅ Upstream %s created\n", name)
+	showPool(pool)
+	fmt.Printf("💡 Proxy a domain to it: sudo webstack domain add app.example.com --backend proxy --upstream %s\n", name)
+}
+
+// EditPool changes the servers or settings of a pool, or shows it when opts
+// holds no change. Domains using it pick the change up without regenerating
+// their vhosts.
+func EditPool(name string, opts PoolOptions) {
+	pool, err := GetPool(name)
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	if len(opts.Servers) == 0 && len(opts.Backup) == 0 && opts.Method == "" && opts.MaxFails == 0 && opts.FailTimeout == 0 && opts.Keepalive == 0 {
+		showPool(*pool)
+		return
+	}
+	if err := pool.apply(opts); err != nil {
+		ui.Invalid("Invalid upstream: %v\n", err)
+		return
+	}
+
+	err = updatePools(func(pools []Pool) ([]Pool, error) {
+		current, ok := findPool(pools, name)
+		if !ok {
+			return nil, fmt.Errorf("it was deleted")
+		}
+		if err := current.apply(opts); err != nil {
+			return nil, err
+		}
+		*pool = *current
+		return pools, nil
+	})
+	if err != nil {
+		ui.Fail("❌ Could not update upstream %s: %v\n", name, err)
+		return
+	}
+	reloadServers(map[string]bool{"nginx": true})
+
+	fmt.Printf("✅ Upstream %s updated\n", name)
+	showPool(*pool)
+}
+
+// DeletePool removes a pool no domain uses
+func DeletePool(name string) {
+	if _, err := GetPool(name); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	if users := poolUsers(name); len(users) > 0 {
+		ui.Fail("❌ Upstream %s is used by %s; move them to another upstream first\n", name, strings.Join(users, ", "))
+		return
+	}
+
+	err := updatePools(func(pools []Pool) ([]Pool, error) {
+		var remaining []Pool
+		for _, p := range pools {
+			if p.Name != name {
+				remaining = append(remaining, p)
+			}
+		}
+		return remaining, nil
+	})
+	if err != nil {
+		ui.Fail("❌ Could not delete upstream %s: %v\n", name, err)
+		return
+	}
+	reloadServers(map[string]bool{"nginx": true})
+	fmt.Printf("✅ Upstream %s deleted\n", name)
+}
+
+// ListPools prints the pools and the domains using them
+func ListPools() {
+	pools, err := loadPools()
+	if err != nil {
+		ui.Fail("❌ Could not load upstreams: %v\n", err)
+		return
+	}
+	if len(pools) == 0 {
+		fmt.Println("ℹ️  No upstreams defined")
+		fmt.Println("   Create one with: sudo webstack upstream create <name> --server host:port --server host:port")
+		return
+	}
+	fmt.Printf("%-16s %-12s %-8s %s\n", "NAME", "METHOD", "SERVERS", "DOMAINS")
+	for _, p := range pools {
+		servers := strconv.Itoa(len(p.Servers))
+		if len(p.Backup) > 0 {
+			servers += fmt.Sprintf("+%d", len(p.Backup))
+		}
+		users := strings.Join(poolUsers(p.Name), ", ")
+		if users == "" {
+			users = "-"
+		}
+		fmt.Printf("%-16s %-12s %-8s %s\n", p.Name, p.Method, servers, users)
+	}
+}
+
+func showPool(p Pool) {
+	fmt.Printf("Upstream %s (%s):\n", p.Name, p.Method)
+	for _, server := range p.Servers {
+		fmt.Printf("  Server:       %s\n", server)
+	}
+	for _, server := range p.Backup {
+		fmt.Printf("  Backup:       %s\n", server)
+	}
+	if p.MaxFails > 0 {
+		fmt.Printf("  Health check: skipped for %ds after %d failed attempt(s)\n", p.FailTimeout, p.MaxFails)
+	} else {
+		fmt.Println("  Health check: off")
+	}
+	if p.Keepalive > 0 {
+		fmt.Printf("  Keepalive:    %d idle connection(s) per worker\n", p.Keepalive)
+	} else {
+		fmt.Println("  Keepalive:    off")
+	}
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"webstack-cli/internal/templates"
+)
+
+// presetDocRoots holds the web root subfolder a framework preset uses
+// unless --docroot is given
+var presetDocRoots = map[string]string{
+	"laravel": "public",
+	"symfony": "public",
+}
+
+var presetVersionPattern = regexp.MustCompile(`# Preset: \S+ \(version (\d+)\)`)
+
+// PresetDocRoot returns the web root subfolder a framework preset uses
+// unless another one is given, e.g. public for laravel
+func PresetDocRoot(preset string) string {
+	return presetDocRoots[preset]
+}
+
+func isValidPreset(preset string) bool {
+	for _, p := range templates.ListPresets() {
+		if p == preset {
+			return true
+		}
+	}
+	return false
+}
+
+// presetVersion returns the version of the embedded preset rules
+func presetVersion(preset string) string {
+	content, err := templates.GetPresetTemplate(preset, "nginx")
+	if err != nil {
+		return ""
+	}
+	if m := presetVersionPattern.FindSubmatch(content); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// renderPreset renders the vhost rules of a preset for a web server with the
+// same variables as the main template
+func renderPreset(preset, server string, vars map[string]interface{}) (string, error) {
+	content, err := templates.GetPresetTemplate(preset, server)
+	if err != nil {
+		return "", fmt.Errorf("could not read %s preset for %s: %v", preset, server, err)
+	}
+
+	tmpl, err := template.New(preset + "-" + server).Parse(string(content))
+	if err != nil {
+		return "", fmt.Errorf("could not parse %s preset for %s: %v", preset, server, err)
+	}
+
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("could not execute %s preset for %s: %v", preset, server, err)
+	}
+	return strings.TrimRight(buf.String(), "\n"), nil
+}
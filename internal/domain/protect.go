@@ -0,0 +1,273 @@
+package domain
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/random"
+	"webstack-cli/internal/ui"
+)
+
+// Protection is a path of a domain behind HTTP basic auth
+type Protection struct {
+	Path  string   `json:"path"`  // URL prefix, "/" for the whole site
+	Users []string `json:"users"` // Accounts in the path's htpasswd file
+}
+
+// ProtectOptions holds the changes of 'webstack domain protect'
+type ProtectOptions struct {
+	Path     string // URL prefix, "/" for the whole site
+	User     string // Account to add, or to remove with Remove
+	Password string // Generated when empty
+	Remove   bool   // Remove the user, or the protection when no user is given
+}
+
+// authVar is a protection as rendered into the vhost templates
+type authVar struct {
+	Path    string
+	Pattern string // Regular expression matching the path and what is below it
+	File    string
+}
+
+// authRealm is the realm browsers show in the login prompt
+const authRealm = "Restricted"
+
+// htpasswdDir holds one htpasswd file per protected path of each domain
+const htpasswdDir = "/etc/webstack/htpasswd"
+
+var authUserPattern = regexp.MustCompile(`^[A-Za-z0-9._@-]{1,64}$`)
+
+// AuthDir returns the folder of a domain's htpasswd files
+func AuthDir(domainName string) string {
+	return filepath.Join(htpasswdDir, domainName)
+}
+
+// htpasswdFile returns the htpasswd file of a protected path
+func htpasswdFile(domainName, protectedPath string) string {
+	name := "root"
+	if protectedPath != "/" {
+		name = strings.ReplaceAll(strings.Trim(protectedPath, "/"), "/", "_")
+	}
+	return filepath.Join(AuthDir(domainName), name)
+}
+
+// cleanProtectedPath validates the path given with --path
+func cleanProtectedPath(p string) (string, error) {
+	if p == "" {
+		return "/", nil
+	}
+	if !strings.HasPrefix(p, "/") {
+		return "", fmt.Errorf("path must start with /: %s", p)
+	}
+	p = path.Clean(p)
+	if strings.ContainsAny(p, " \t\r\n;{}\"'\\()[]*?^$|+") {
+		return "", fmt.Errorf("path contains characters not allowed in a vhost: %s", p)
+	}
+	return p, nil
+}
+
+// authVars returns the protections of a domain for the vhost templates,
+// shortest path first: nginx applies the last match, so the most specific
+// path's users win. ACME challenges stay reachable when the whole site is
+// protected.
+func authVars(d Domain) []authVar {
+	protections := append([]Protection(nil), d.Protect...)
+	sort.SliceStable(protections, func(i, j int) bool {
+		return len(protections[i].Path) < len(protections[j].Path)
+	})
+
+	var vars []authVar
+	for _, p := range protections {
+		pattern := "^/(?!\\.well-known/acme-challenge/)"
+		if p.Path != "/" {
+			pattern = "^" + regexp.QuoteMeta(p.Path) + "(?:/|$)"
+		}
+		vars = append(vars, authVar{Path: p.Path, Pattern: pattern, File: htpasswdFile(d.Name, p.Path)})
+	}
+	return vars
+}
+
+// Protect adds a basic auth user to a path of a domain, protecting the path
+// if it is not yet, or removes a user or the protection, and regenerates the
+// domain's configuration. Without a user or Remove the protections are shown.
+func Protect(domainName string, opts ProtectOptions) {
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+
+	if opts.User == "" && !opts.Remove {
+		showProtections(*d)
+		return
+	}
+
+	protectedPath, err := cleanProtectedPath(opts.Path)
+	if err != nil {
+		ui.Invalid("Invalid path: %v\n", err)
+		return
+	}
+	if opts.User != "" && !authUserPattern.MatchString(opts.User) {
+		ui.Invalid("Invalid user name: %s (letters, digits, . _ @ and -)\n", opts.User)
+		return
+	}
+
+	index := -1
+	for i, p := range d.Protect {
+		if p.Path == protectedPath {
+			index = i
+		}
+	}
+	if opts.Remove && index < 0 {
+		ui.Fail("No protection on %s configured for %s\n", protectedPath, domainName)
+		return
+	}
+
+	previous := append([]Protection(nil), d.Protect...)
+	file := htpasswdFile(d.Name, protectedPath)
+	password := opts.Password
+	generated := false
+
+	switch {
+	case opts.Remove && opts.User != "":
+		users := removeUser(d.Protect[index].Users, opts.User)
+		if len(users) == len(d.Protect[index].Users) {
+			ui.Fail("No user %s on %s of %s\n", opts.User, protectedPath, domainName)
+			return
+		}
+		if len(users) == 0 {
+			d.Protect = append(d.Protect[:index:index], d.Protect[index+1:]...)
+		} else {
+			d.Protect[index].Users = users
+		}
+	case opts.Remove:
+		d.Protect = append(d.Protect[:index:index], d.Protect[index+1:]...)
+	default:
+		if password == "" {
+			if password, err = random.String(16); err != nil {
+				ui.Fail("❌ Could not generate a password: %v\n", err)
+				return
+			}
+			generated = true
+		}
+		if index < 0 {
+			d.Protect = append(d.Protect, Protection{Path: protectedPath})
+			index = len(d.Protect) - 1
+		}
+		d.Protect[index].Users = append(removeUser(d.Protect[index].Users, opts.User), opts.User)
+	}
+
+	// The htpasswd file is written before the vhost points to it
+	previousFile, _ := ioutil.ReadFile(file)
+	if opts.Remove {
+		err = updateHtpasswd(file, opts.User, "")
+	} else {
+		err = updateHtpasswd(file, opts.User, password)
+	}
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+
+	if err := saveDomain(*d); err != nil {
+		ui.Fail("❌ Could not save domain: %v\n", err)
+		return
+	}
+	if err := applyConfig(*d, false); err != nil {
+		d.Protect = previous
+		if saveErr := saveDomain(*d); saveErr != nil {
+			ui.Warn("⚠️  Warning: Could not restore protections: %v\n", saveErr)
+		}
+		if previousFile != nil {
+			dryrun.WriteFile(file, previousFile, 0640)
+		} else {
+			dryrun.Remove(file)
+		}
+		ui.Fail("❌ Could not update protection: %v\n", err)
+		return
+	}
+	reloadWebServers()
+
+	switch {
+	case opts.Remove && opts.User != "":
+		fmt.Printf("✅ User %s removed from %s of %s\n", opts.User, protectedPath, domainName)
+	case opts.Remove:
+		fmt.Printf("✅ Protection of %s removed for %s\n", protectedPath, domainName)
+	default:
+		fmt.Printf("✅ %s of %s protected with basic auth\n", protectedPath, domainName)
+		fmt.Printf("   User: %s\n", opts.User)
+		if generated {
+			fmt.Printf("   Password: %s\n", password)
+		}
+	}
+}
+
+func showProtections(d Domain) {
+	if len(d.Protect) == 0 {
+		fmt.Printf("No basic auth protection configured for %s\n", d.Name)
+		return
+	}
+	fmt.Printf("Basic auth protection of %s:\n", d.Name)
+	fmt.Println("===================")
+	for _, p := range d.Protect {
+		fmt.Printf("  %-20s %s\n", p.Path, strings.Join(p.Users, ", "))
+	}
+}
+
+func removeUser(users []string, user string) []string {
+	var kept []string
+	for _, u := range users {
+		if u != user {
+			kept = append(kept, u)
+		}
+	}
+	return kept
+}
+
+// updateHtpasswd sets the password of a user in an htpasswd file, removes
+// the user when password is empty, or the whole file when user is empty or
+// no user is left. Passwords are hashed with APR1-MD5, which both Nginx and
+// Apache accept.
+func updateHtpasswd(file, user, password string) error {
+	content, _ := ioutil.ReadFile(file)
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if line != "" && user != "" && !strings.HasPrefix(line, user+":") {
+			lines = append(lines, line)
+		}
+	}
+
+	if password != "" {
+		cmd := exec.Command("openssl", "passwd", "-apr1", "-stdin")
+		cmd.Stdin = strings.NewReader(password + "\n")
+		hash, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("could not hash the password with openssl: %v", err)
+		}
+		lines = append(lines, user+":"+strings.TrimSpace(string(hash)))
+	}
+
+	if len(lines) == 0 {
+		if err := dryrun.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not remove %s: %v", file, err)
+		}
+		return nil
+	}
+
+	if err := dryrun.MkdirAll(filepath.Dir(file), 0750); err != nil {
+		return fmt.Errorf("could not create %s: %v", filepath.Dir(file), err)
+	}
+	if err := dryrun.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0640); err != nil {
+		return fmt.Errorf("could not write %s: %v", file, err)
+	}
+	// The web servers read the file as www-data
+	dryrun.Run(exec.Command("chgrp", "www-data", filepath.Dir(file), file))
+	return nil
+}
@@ -0,0 +1,204 @@
+package domain
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/ui"
+)
+
+// nginxRateLimitConf holds the limit_req and limit_conn zones of the rate
+// limited domains, loaded by nginx.conf like the cache include
+const nginxRateLimitConf = "/etc/nginx/includes/ratelimit.conf"
+
+// RateLimit limits the requests and connections per client IP of a domain
+type RateLimit struct {
+	RPS   int `json:"rps"`            // Requests per second
+	Burst int `json:"burst"`          // Requests over the rate served without delay before 429
+	Conn  int `json:"conn,omitempty"` // Concurrent connections; 0 for no limit
+}
+
+// RateLimitOptions holds the changes of 'webstack domain ratelimit'. Zero
+// values keep the current setting.
+type RateLimitOptions struct {
+	RPS     int
+	Burst   int
+	Conn    int // -1 removes the connection limit
+	Disable bool
+}
+
+// rateLimitRules are the limits of a domain as rendered into its vhost
+type rateLimitRules struct {
+	Zone     string
+	ConnZone string
+	Burst    int
+	Conn     int
+}
+
+// rateLimitZone returns the zone name of a domain, which nginx only allows
+// letters, digits and underscores in
+func rateLimitZone(domainName string) string {
+	return "webstack_" + strings.NewReplacer(".", "_", "-", "_").Replace(domainName)
+}
+
+// rateLimitVars returns the limits of a domain, or nil when it has none or
+// its zones are missing from the include
+func rateLimitVars(d Domain) *rateLimitRules {
+	if d.RateLimit == nil {
+		return nil
+	}
+	zone := rateLimitZone(d.Name)
+	content, _ := os.ReadFile(nginxRateLimitConf)
+	if !strings.Contains(string(content), "zone="+zone+"_req:") {
+		if err := writeRateLimitZones(nil); err != nil {
+			ui.Warn("⚠️  Warning: Rate limiting of %s not applied: %v\n", d.Name, err)
+			return nil
+		}
+	}
+	return &rateLimitRules{Zone: zone + "_req", ConnZone: zone + "_conn", Burst: d.RateLimit.Burst, Conn: d.RateLimit.Conn}
+}
+
+// writeRateLimitZones writes the zones of every rate limited domain, with
+// changed replacing the stored entry of the same domain
+func writeRateLimitZones(changed *Domain) error {
+	domains, err := loadDomains()
+	if err != nil {
+		return err
+	}
+	if changed != nil {
+		found := false
+		for i := range domains {
+			if domains[i].Name == changed.Name {
+				domains[i] = *changed
+				found = true
+			}
+		}
+		if !found {
+			domains = append(domains, *changed)
+		}
+	}
+
+	if _, err := os.Stat("/etc/nginx"); err != nil {
+		return nil // Apache-only server, the limits wait for Nginx
+	}
+
+	var b strings.Builder
+	b.WriteString("# WebStack CLI - Nginx rate limiting zones (managed with 'webstack domain ratelimit')\n")
+	b.WriteString("# One request and one connection zone per domain, keyed by client IP\n")
+	for _, d := range domains {
+		if d.RateLimit == nil {
+			continue
+		}
+		zone := rateLimitZone(d.Name)
+		fmt.Fprintf(&b, "\n# %s\n", d.Name)
+		fmt.Fprintf(&b, "limit_req_zone  $binary_remote_addr zone=%s_req:10m rate=%dr/s;\n", zone, d.RateLimit.RPS)
+		fmt.Fprintf(&b, "limit_conn_zone $binary_remote_addr zone=%s_conn:10m;\n", zone)
+	}
+
+	if err := dryrun.MkdirAll("/etc/nginx/includes", 0755); err != nil {
+		return fmt.Errorf("could not create /etc/nginx/includes: %v", err)
+	}
+	if err := dryrun.WriteFile(nginxRateLimitConf, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", nginxRateLimitConf, err)
+	}
+	return nil
+}
+
+// ManageRateLimit changes the rate limits of a domain and regenerates its
+// configuration, or shows them when opts holds no change
+func ManageRateLimit(domainName string, opts RateLimitOptions) {
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+
+	if opts == (RateLimitOptions{}) {
+		showRateLimit(*d)
+		return
+	}
+	if opts.RPS < 0 || opts.Burst < 0 || opts.Conn < -1 {
+		ui.Invalid("Invalid rate limit: values must not be negative\n")
+		return
+	}
+
+	previous := d.RateLimit
+	if opts.Disable {
+		if d.RateLimit == nil {
+			fmt.Printf("Rate limiting is not enabled for %s\n", domainName)
+			return
+		}
+		d.RateLimit = nil
+	} else {
+		limit := RateLimit{}
+		if d.RateLimit != nil {
+			limit = *d.RateLimit
+		}
+		if opts.RPS > 0 {
+			limit.RPS = opts.RPS
+		}
+		if opts.Burst > 0 {
+			limit.Burst = opts.Burst
+		}
+		if opts.Conn != 0 {
+			limit.Conn = max(opts.Conn, 0)
+		}
+		if limit.RPS == 0 {
+			ui.Invalid("Invalid rate limit: --rps is required to enable rate limiting\n")
+			return
+		}
+		if limit.Burst == 0 {
+			limit.Burst = limit.RPS * 2
+		}
+		d.RateLimit = &limit
+	}
+
+	if cfg, err := config.Load(); err == nil && cfg != nil {
+		if layout, _ := vhostLayout(*d, cfg); layout == "" {
+			ui.Warn("⚠️  %s is served by Apache alone; rate limiting needs Nginx in front and is stored until then\n", d.Name)
+		}
+	}
+
+	// Zones are written first: the vhost can only refer to existing zones
+	if err := writeRateLimitZones(d); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	if err := saveDomain(*d); err != nil {
+		ui.Fail("❌ Could not save domain: %v\n", err)
+		return
+	}
+	if err := applyConfig(*d, false); err != nil {
+		d.RateLimit = previous
+		if saveErr := saveDomain(*d); saveErr != nil {
+			ui.Warn("⚠️  Warning: Could not restore rate limits: %v\n", saveErr)
+		}
+		writeRateLimitZones(nil)
+		ui.Fail("❌ Could not update rate limits: %v\n", err)
+		return
+	}
+	reloadWebServers()
+
+	if d.RateLimit == nil {
+		fmt.Printf("✅ Rate limiting disabled for %s\n", domainName)
+		return
+	}
+	fmt.Printf("✅ Rate limiting updated for %s\n", domainName)
+	showRateLimit(*d)
+}
+
+func showRateLimit(d Domain) {
+	if d.RateLimit == nil {
+		fmt.Printf("Rate limiting is not enabled for %s\n", d.Name)
+		return
+	}
+	fmt.Printf("Rate limits of %s (per client IP):\n", d.Name)
+	fmt.Printf("  Requests:    %d/s, burst %d (then 429 Too Many Requests)\n", d.RateLimit.RPS, d.RateLimit.Burst)
+	if d.RateLimit.Conn > 0 {
+		fmt.Printf("  Connections: %d concurrent\n", d.RateLimit.Conn)
+	} else {
+		fmt.Println("  Connections: no limit")
+	}
+}
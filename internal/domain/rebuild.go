@@ -0,0 +1,307 @@
+package domain
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"webstack-cli/internal/templates"
+	"webstack-cli/internal/ui"
+)
+
+// RebuildOptions controls how RebuildAll regenerates the domain configurations
+type RebuildOptions struct {
+	Workers int  // Domains rendered in parallel; the number of CPUs when 0
+	Force   bool // Rewrite every vhost, even when the rendered one matches the live file
+	Diff    bool // Show the changes to the live vhosts before writing them
+}
+
+// Rebuild outcomes of a domain
+const (
+	rebuildUnchanged = "unchanged"
+	rebuildChanged   = "changed"
+	rebuildFailed    = "failed"
+)
+
+type rebuildResult struct {
+	domain  Domain
+	vc      *vhostConfig
+	status  string
+	servers map[string]bool // Web servers whose live vhost differs from the rendered one
+	err     error
+}
+
+// RebuildConfigs regenerates configuration files for all domains
+func RebuildConfigs() {
+	RebuildAll(RebuildOptions{})
+}
+
+// RebuildAll renders the vhosts of all domains in parallel, compares them
+// with the live files and only writes, validates and reloads what changed
+func RebuildAll(opts RebuildOptions) {
+	fmt.Println("🔄 Rebuilding all domain configurations...")
+	fmt.Println("==========================================")
+
+	domains, err := loadDomains()
+	if err != nil {
+		ui.Fail("Error loading domains: %v\n", err)
+		return
+	}
+
+	if len(domains) == 0 {
+		fmt.Println("No domains configured")
+		return
+	}
+
+	if overrides := templates.Overrides(); len(overrides) > 0 {
+		fmt.Printf("🧩 Using %d customized template(s) from %s\n", len(overrides), templates.OverrideDir)
+		for _, info := range overrides {
+			if info.Status == templates.StatusOutdated {
+				ui.Warn("⚠️  %s was exported from an older built-in version (see 'webstack template list')\n", info.Path)
+			}
+		}
+	}
+
+	results := renderAll(domains, opts.Workers)
+
+	// Dedicated PHP-FPM pools are rebuilt from the stored overrides; pools
+	// are only written when they differ
+	var phpVersions []string
+	for _, d := range domains {
+		if len(d.PHPSettings) > 0 {
+			versions, err := writePHPPool(d)
+			if err != nil {
+				ui.Warn("⚠️  Warning: Could not rebuild PHP-FPM pool for %s: %v\n", d.Name, err)
+			}
+			phpVersions = append(phpVersions, versions...)
+		}
+	}
+
+	reload := map[string]bool{}
+	var rebuilt []Domain
+	for i := range results {
+		r := &results[i]
+		if r.status == rebuildFailed {
+			ui.Fail("❌ Error rendering configuration for %s: %v\n", r.domain.Name, r.err)
+			continue
+		}
+		if r.status == rebuildUnchanged && !opts.Force {
+			continue
+		}
+
+		fmt.Printf("\n📝 Rebuilding config for %s (%s)...\n", r.domain.Name, r.domain.Backend)
+		for _, warning := range r.vc.warnings {
+			ui.Warn("⚠️  %s\n", warning)
+		}
+		if opts.Diff {
+			printVhostDiff(r.domain, r.vc)
+		}
+
+		// Replace old configs, keeping them if the new ones fail validation.
+		// Vhosts written for other spellings of the name are removed first.
+		removeNameVariants(r.domain)
+		if err := applyRendered(r.domain, true, r.vc); err != nil {
+			ui.Fail("❌ Error generating configuration for %s: %v\n", r.domain.Name, err)
+			r.status, r.err = rebuildFailed, err
+			continue
+		}
+		fmt.Printf("✅ Configuration rebuilt for %s\n", r.domain.Name)
+		r.status = rebuildChanged
+		rebuilt = append(rebuilt, r.domain)
+		for server := range r.servers {
+			reload[server] = true
+		}
+		if opts.Force {
+			reload["nginx"] = reload["nginx"] || r.vc.nginx != ""
+			reload["apache"] = reload["apache"] || r.vc.apache != ""
+			if r.vc.backend != "" {
+				reload[r.domain.Backend] = true
+			}
+		}
+	}
+
+	reloadPHPFPM(phpVersions)
+	pruneHTTP3Tuning(domains)
+
+	// Reload the web servers whose configs changed once, after all domains
+	if len(reload) > 0 {
+		reloadServers(reload)
+		smokeTest(rebuilt...)
+	}
+
+	printRebuildSummary(results)
+}
+
+// renderAll renders the vhosts of the domains with a pool of workers and
+// compares them with the live files. Results keep the order of domains.
+func renderAll(domains []Domain, workers int) []rebuildResult {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(domains) {
+		workers = len(domains)
+	}
+	fmt.Printf("🔍 Rendering %d domain(s) with %d worker(s)...\n", len(domains), workers)
+
+	results := make([]rebuildResult, len(domains))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+	step := len(domains) / 10
+	if step < 25 {
+		step = 25
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				d := domains[i]
+				r := rebuildResult{domain: d}
+				if r.vc, r.err = renderConfig(d, true); r.err != nil {
+					r.status = rebuildFailed
+				} else if r.servers = r.vc.changes(d); len(r.servers) > 0 {
+					r.status = rebuildChanged
+				} else {
+					r.status = rebuildUnchanged
+				}
+				results[i] = r
+
+				mu.Lock()
+				done++
+				if done%step == 0 && done < len(domains) {
+					fmt.Printf("   %d/%d rendered\n", done, len(domains))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range domains {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// changes returns the web servers whose live vhost of the domain differs
+// from the rendered one, including vhosts left under another spelling of
+// the name
+func (vc *vhostConfig) changes(d Domain) map[string]bool {
+	changed := map[string]bool{}
+	name := d.Name + ".conf"
+	if !vhostLive(filepath.Join("/etc/nginx/sites-available", name), filepath.Join("/etc/nginx/sites-enabled", name), vc.nginx) {
+		changed["nginx"] = true
+	}
+	if !vhostLive(filepath.Join(apacheSitesAvailable(), name), filepath.Join(apacheSitesEnabled(), name), vc.apache) {
+		changed["apache"] = true
+	}
+	for _, ws := range webServers {
+		// Only the vhost of the domain's backend is kept, others are removed
+		content := ""
+		if ws.Backend() == d.Backend {
+			content = vc.backend
+		}
+		if path := ws.VhostPath(d.Name); !vhostLive(path, path, content) {
+			changed[ws.Backend()] = true
+		}
+	}
+	for _, variant := range nameVariants(d) {
+		changed["nginx"] = true
+		if variant.Backend == "apache" {
+			changed["apache"] = true
+		}
+	}
+	return changed
+}
+
+// vhostLive reports whether a vhost file holds content and is enabled, or
+// is absent when content is empty
+func vhostLive(available, enabled, content string) bool {
+	data, err := ioutil.ReadFile(available)
+	if content == "" {
+		return os.IsNotExist(err)
+	}
+	if err != nil || string(data) != content {
+		return false
+	}
+	_, err = os.Stat(enabled)
+	return err == nil
+}
+
+// printVhostDiff shows a unified diff between the live vhosts of a domain
+// and the rendered ones
+func printVhostDiff(d Domain, vc *vhostConfig) {
+	name := d.Name + ".conf"
+	files := []struct{ path, content string }{
+		{filepath.Join("/etc/nginx/sites-available", name), vc.nginx},
+		{filepath.Join(apacheSitesAvailable(), name), vc.apache},
+	}
+	if ws, ok := backendServer(d.Backend); ok {
+		files = append(files, struct{ path, content string }{ws.VhostPath(d.Name), vc.backend})
+	}
+	for _, f := range files {
+		previous := f.path
+		if _, err := os.Stat(previous); err != nil {
+			if f.content == "" {
+				continue
+			}
+			previous = os.DevNull
+		}
+
+		// diff reads the rendered vhost from stdin and exits with 1 when the
+		// files differ, so only the output matters
+		cmd := exec.Command("diff", "-u", "--label", f.path+" (live)", "--label", f.path+" (new)", previous, "-")
+		cmd.Stdin = strings.NewReader(f.content)
+		output, _ := cmd.CombinedOutput()
+		for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+			if line != "" {
+				fmt.Printf("   %s\n", line)
+			}
+		}
+	}
+}
+
+// printRebuildSummary lists the changed and failed domains and the totals
+func printRebuildSummary(results []rebuildResult) {
+	counts := map[string]int{}
+	for _, r := range results {
+		counts[r.status]++
+	}
+
+	fmt.Println("\n==========================================")
+	if counts[rebuildChanged]+counts[rebuildFailed] > 0 {
+		fmt.Printf("%-40s %-8s %-10s %s\n", "DOMAIN", "BACKEND", "STATUS", "DETAILS")
+		for _, r := range results {
+			if r.status == rebuildUnchanged {
+				continue
+			}
+			details := ""
+			if r.err != nil {
+				details = strings.SplitN(r.err.Error(), "\n", 2)[0]
+			} else if len(r.servers) > 0 {
+				var servers []string
+				for _, server := range []string{"nginx", "apache", "caddy"} {
+					if r.servers[server] {
+						servers = append(servers, server)
+					}
+				}
+				details = strings.Join(servers, ", ")
+			}
+			fmt.Printf("%-40s %-8s %-10s %s\n", r.domain.Name, r.domain.Backend, r.status, details)
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("✅ Rebuilt: %d domain(s), unchanged: %d\n", counts[rebuildChanged], counts[rebuildUnchanged])
+	if counts[rebuildFailed] > 0 {
+		ui.Fail("❌ Failed: %d domain(s)\n", counts[rebuildFailed])
+	}
+}
@@ -0,0 +1,149 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"webstack-cli/internal/ui"
+)
+
+// Redirect is a per-domain HTTP redirect rendered into the generated vhosts
+type Redirect struct {
+	From string `json:"from"` // Request path, e.g. /old-page
+	To   string `json:"to"`   // Target path or absolute URL
+	Code int    `json:"code"` // 301, 302, 307 or 308
+}
+
+// Pattern returns From escaped for use in an Apache RedirectMatch expression
+func (r Redirect) Pattern() string {
+	return regexp.QuoteMeta(r.From)
+}
+
+var validRedirectCodes = []int{301, 302, 307, 308}
+
+// validateRedirect checks that a redirect can be safely rendered into nginx and Apache configs
+func validateRedirect(r Redirect) error {
+	if !strings.HasPrefix(r.From, "/") {
+		return fmt.Errorf("source path must start with /: %s", r.From)
+	}
+	if !strings.HasPrefix(r.To, "/") && !strings.HasPrefix(r.To, "http://") && !strings.HasPrefix(r.To, "https://") {
+		return fmt.Errorf("target must be a path or an http(s) URL: %s", r.To)
+	}
+	for _, value := range []string{r.From, r.To} {
+		if strings.ContainsAny(value, " \t\r\n;{}\"'\\") {
+			return fmt.Errorf("path contains characters not allowed in a vhost: %s", value)
+		}
+	}
+
+	for _, code := range validRedirectCodes {
+		if r.Code == code {
+			return nil
+		}
+	}
+	return fmt.Errorf("redirect code must be one of 301, 302, 307, 308: %d", r.Code)
+}
+
+// AddRewrite adds or replaces a redirect for a domain and regenerates its configuration
+func AddRewrite(domainName, from, to string, code int) {
+	redirect := Redirect{From: from, To: to, Code: code}
+	if err := validateRedirect(redirect); err != nil {
+		ui.Invalid("Invalid redirect: %v\n", err)
+		return
+	}
+
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+	previous := append([]Redirect(nil), d.Redirects...)
+
+	replaced := false
+	for i, r := range d.Redirects {
+		if r.From == from {
+			d.Redirects[i] = redirect
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		d.Redirects = append(d.Redirects, redirect)
+	}
+
+	if err := updateRedirects(*d, previous); err != nil {
+		ui.Fail("❌ Could not add redirect: %v\n", err)
+		return
+	}
+
+	fmt.Printf("✅ Redirect added for %s: %s → %s (%d)\n", domainName, from, to, code)
+}
+
+// RemoveRewrite removes the redirect for a source path and regenerates the configuration
+func RemoveRewrite(domainName, from string) {
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+	previous := append([]Redirect(nil), d.Redirects...)
+
+	found := false
+	for i, r := range d.Redirects {
+		if r.From == from {
+			d.Redirects = append(d.Redirects[:i], d.Redirects[i+1:]...)
+			found = true
+			break
+		}
+	}
+	if !found {
+		ui.Fail("No redirect from %s configured for %s\n", from, domainName)
+		return
+	}
+
+	if err := updateRedirects(*d, previous); err != nil {
+		ui.Fail("❌ Could not remove redirect: %v\n", err)
+		return
+	}
+
+	fmt.Printf("✅ Redirect from %s removed for %s\n", from, domainName)
+}
+
+// ListRewrites displays the redirects configured for a domain
+func ListRewrites(domainName string) {
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+
+	if len(d.Redirects) == 0 {
+		fmt.Printf("No redirects configured for %s\n", domainName)
+		return
+	}
+
+	fmt.Printf("Redirects for %s:\n", domainName)
+	fmt.Println("===================")
+	for _, r := range d.Redirects {
+		fmt.Printf("  %d  %s → %s\n", r.Code, r.From, r.To)
+	}
+}
+
+// updateRedirects saves the domain and applies its configuration, restoring
+// the previous redirects if the new configuration is rejected
+func updateRedirects(d Domain, previous []Redirect) error {
+	if err := saveDomain(d); err != nil {
+		return fmt.Errorf("could not save domain: %v", err)
+	}
+
+	if err := applyConfig(d, false); err != nil {
+		d.Redirects = previous
+		if saveErr := saveDomain(d); saveErr != nil {
+			ui.Warn("⚠️  Warning: Could not restore redirects: %v\n", saveErr)
+		}
+		return err
+	}
+
+	reloadWebServers()
+	smokeTest(d)
+	return nil
+}
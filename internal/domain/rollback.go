@@ -0,0 +1,272 @@
+package domain
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/osinfo"
+	"webstack-cli/internal/ui"
+)
+
+// configSnapshot holds copies of a domain's web server configuration files
+// taken before new ones are written, so they can be restored if the web
+// server rejects the new configuration
+type configSnapshot struct {
+	dir   string
+	files []snapshotFile
+}
+
+type snapshotFile struct {
+	path    string // Live path, e.g. /etc/nginx/sites-available/example.com.conf
+	exists  bool
+	link    string // Symlink target if the live path is a symlink
+	backup  string // Copy of the previous file inside the snapshot dir
+	server  string // "nginx", "apache" or a backend of webServers
+	visible bool   // Include in the diff shown on failure
+}
+
+// domainConfigPaths returns every configuration path generateConfig may touch
+func domainConfigPaths(domainName string) []snapshotFile {
+	name := domainName + ".conf"
+	files := []snapshotFile{
+		{path: filepath.Join("/etc/nginx/sites-available", name), server: "nginx", visible: true},
+		{path: filepath.Join("/etc/nginx/sites-enabled", name), server: "nginx"},
+		{path: filepath.Join(apacheSitesAvailable(), name), server: "apache", visible: true},
+		{path: filepath.Join(apacheSitesEnabled(), name), server: "apache"},
+	}
+	for _, ws := range webServers {
+		files = append(files, snapshotFile{path: ws.VhostPath(domainName), server: ws.Backend(), visible: true})
+	}
+	return files
+}
+
+// snapshotConfigs copies the current configuration of a domain to a temporary directory
+func snapshotConfigs(domainName string) (*configSnapshot, error) {
+	dir, err := ioutil.TempDir("", "webstack-config-")
+	if err != nil {
+		return nil, fmt.Errorf("could not create snapshot directory: %v", err)
+	}
+
+	snap := &configSnapshot{dir: dir}
+	for i, f := range domainConfigPaths(domainName) {
+		info, err := os.Lstat(f.path)
+		if os.IsNotExist(err) {
+			snap.files = append(snap.files, f)
+			continue
+		}
+		if err != nil {
+			snap.discard()
+			return nil, fmt.Errorf("could not read %s: %v", f.path, err)
+		}
+
+		f.exists = true
+		if info.Mode()&os.ModeSymlink != 0 {
+			if f.link, err = os.Readlink(f.path); err != nil {
+				snap.discard()
+				return nil, fmt.Errorf("could not read symlink %s: %v", f.path, err)
+			}
+		} else {
+			data, err := ioutil.ReadFile(f.path)
+			if err != nil {
+				snap.discard()
+				return nil, fmt.Errorf("could not read %s: %v", f.path, err)
+			}
+			f.backup = filepath.Join(dir, fmt.Sprintf("%d-%s", i, filepath.Base(f.path)))
+			if err := ioutil.WriteFile(f.backup, data, info.Mode().Perm()); err != nil {
+				snap.discard()
+				return nil, fmt.Errorf("could not back up %s: %v", f.path, err)
+			}
+		}
+		snap.files = append(snap.files, f)
+	}
+
+	return snap, nil
+}
+
+// restore puts the previous configuration back in place
+func (s *configSnapshot) restore() {
+	for _, f := range s.files {
+		os.Remove(f.path)
+		if !f.exists {
+			continue
+		}
+
+		if f.link != "" {
+			if err := os.Symlink(f.link, f.path); err != nil {
+				ui.Warn("⚠️  Warning: Could not restore %s: %v\n", f.path, err)
+			}
+			continue
+		}
+
+		data, err := ioutil.ReadFile(f.backup)
+		if err == nil {
+			err = ioutil.WriteFile(f.path, data, 0644)
+		}
+		if err != nil {
+			ui.Warn("⚠️  Warning: Could not restore %s: %v\n", f.path, err)
+		}
+	}
+}
+
+// discard removes the temporary copies
+func (s *configSnapshot) discard() {
+	os.RemoveAll(s.dir)
+}
+
+// changedServers returns the web servers whose configuration files differ from the snapshot
+func (s *configSnapshot) changedServers() map[string]bool {
+	changed := make(map[string]bool)
+	for _, f := range s.files {
+		info, err := os.Lstat(f.path)
+		switch {
+		case os.IsNotExist(err):
+			if f.exists {
+				changed[f.server] = true
+			}
+		case !f.exists:
+			changed[f.server] = true
+		case info.Mode()&os.ModeSymlink != 0:
+			link, _ := os.Readlink(f.path)
+			if link != f.link {
+				changed[f.server] = true
+			}
+		default:
+			current, _ := ioutil.ReadFile(f.path)
+			previous, _ := ioutil.ReadFile(f.backup)
+			if f.backup == "" || string(current) != string(previous) {
+				changed[f.server] = true
+			}
+		}
+	}
+	return changed
+}
+
+// diff returns a unified diff between the previous and the new configuration files
+func (s *configSnapshot) diff() string {
+	var out strings.Builder
+	for _, f := range s.files {
+		if !f.visible {
+			continue
+		}
+
+		previous := f.backup
+		if previous == "" {
+			previous = os.DevNull
+		}
+		current := f.path
+		if _, err := os.Stat(current); err != nil {
+			current = os.DevNull
+		}
+		if previous == os.DevNull && current == os.DevNull {
+			continue
+		}
+
+		// diff exits with 1 when the files differ, so only the output matters
+		result, _ := exec.Command("diff", "-u", "--label", f.path+" (previous)", "--label", f.path+" (new)", previous, current).CombinedOutput()
+		out.Write(result)
+	}
+	return out.String()
+}
+
+// TestWebServer validates the live configuration of a web server: nginx,
+// apache or a backend such as caddy. Servers that aren't installed pass.
+func TestWebServer(server string) error {
+	return testWebServers(map[string]bool{server: true})
+}
+
+// testWebServers validates the live configuration of the given web servers
+func testWebServers(servers map[string]bool) error {
+	tests := []struct {
+		server string
+		binary string
+		args   []string
+	}{
+		{"nginx", "nginx", []string{"-t"}},
+		{"apache", osinfo.Current().ApacheCtl, []string{"configtest"}},
+	}
+
+	for _, t := range tests {
+		if !servers[t.server] {
+			continue
+		}
+		if _, err := exec.LookPath(t.binary); err != nil {
+			continue // Web server not installed, nothing to validate
+		}
+
+		output, err := exec.Command(t.binary, t.args...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s %s failed:\n%s", t.binary, strings.Join(t.args, " "), strings.TrimSpace(string(output)))
+		}
+	}
+	for _, ws := range webServers {
+		if !servers[ws.Backend()] {
+			continue
+		}
+		if err := ws.Test(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyConfig generates the configuration for a domain and validates it with
+// nginx -t / apache2ctl configtest. If generation or validation fails, the
+// previous configuration is restored so a bad template cannot take other
+// sites down. When clean is set, the existing configuration is removed first.
+func applyConfig(domain Domain, clean bool) error {
+	return applyRendered(domain, clean, nil)
+}
+
+// applyRendered is applyConfig for vhosts already rendered by renderConfig;
+// a nil vc renders them
+func applyRendered(domain Domain, clean bool, vc *vhostConfig) error {
+	generate := func() error {
+		if vc == nil {
+			return generateConfig(domain)
+		}
+		return writeConfig(domain, vc)
+	}
+
+	if dryrun.Enabled() {
+		// Nothing is written, so there is nothing to validate or roll back
+		if clean {
+			removeConfig(domain)
+		}
+		return generate()
+	}
+
+	snap, err := snapshotConfigs(domain.Name)
+	if err != nil {
+		return err
+	}
+	defer snap.discard()
+
+	if clean {
+		removeConfig(domain)
+	}
+
+	if err := generate(); err != nil {
+		snap.restore()
+		fmt.Printf("↩️  Previous configuration for %s restored\n", domain.Name)
+		return err
+	}
+
+	if err := testWebServers(snap.changedServers()); err != nil {
+		diff := snap.diff()
+		snap.restore()
+		fmt.Printf("↩️  Previous configuration for %s restored\n", domain.Name)
+		if diff != "" {
+			fmt.Println("   Rejected changes:")
+			for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
+				fmt.Printf("   %s\n", line)
+			}
+		}
+		return fmt.Errorf("configuration validation failed, web servers were not reloaded: %v", err)
+	}
+
+	return nil
+}
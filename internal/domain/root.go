@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// systemFolders are never used as a web root or a domain's document root
+var systemFolders = []string{"/etc", "/usr", "/bin", "/sbin", "/lib", "/lib64", "/boot", "/proc", "/sys", "/dev", "/run", "/var/lib", "/var/log"}
+
+// CleanFolder validates an absolute folder given for defaults.webroot or
+// --root and returns it cleaned
+func CleanFolder(path string) (string, error) {
+	folder := filepath.Clean(strings.TrimSpace(path))
+	if !filepath.IsAbs(folder) || folder == "/" {
+		return "", fmt.Errorf("expected an absolute folder other than /, e.g. /srv/www")
+	}
+	if strings.ContainsAny(folder, " \t;{}\"'") {
+		return "", fmt.Errorf("%s contains characters not allowed in a vhost", folder)
+	}
+	for _, system := range systemFolders {
+		if folder == system || strings.HasPrefix(folder, system+"/") {
+			return "", fmt.Errorf("%s is a system folder", folder)
+		}
+	}
+	return folder, nil
+}
+
+// CustomRoot reports whether the document root was given with --root
+// instead of being htdocs (or a subfolder of it) in the domain's folder
+func (d Domain) CustomRoot() bool {
+	htdocs := filepath.Join(d.HomeDir(), "htdocs")
+	return d.DocumentRoot != htdocs && !strings.HasPrefix(d.DocumentRoot, htdocs+"/")
+}
+
+// AppRoot returns the folder holding the application: htdocs, or for a
+// custom root the root itself, or its parent when it is the public folder
+// of a preset (e.g. /home/app/site for /home/app/site/public)
+func (d Domain) AppRoot() string {
+	if !d.CustomRoot() {
+		return filepath.Join(d.HomeDir(), "htdocs")
+	}
+	if public := presetDocRoots[d.Preset]; public != "" && filepath.Base(d.DocumentRoot) == public {
+		return filepath.Dir(d.DocumentRoot)
+	}
+	return d.DocumentRoot
+}
+
+// isEmptyDir reports whether a folder is missing or has no entries, so a
+// default index page can be written without replacing anything
+func isEmptyDir(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	return os.IsNotExist(err) || (err == nil && len(entries) == 0)
+}
@@ -0,0 +1,228 @@
+package domain
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"webstack-cli/internal/ui"
+)
+
+// Route serves a sub-path of a domain with another PHP version, another
+// document root, or both, e.g. /legacy with PHP 7.4 from /srv/legacy
+type Route struct {
+	Path       string `json:"path"`                  // URL prefix, e.g. /blog
+	PHPVersion string `json:"php_version,omitempty"` // Empty for the domain's version
+	Root       string `json:"root,omitempty"`        // Folder served for the path; empty for the matching folder of the document root
+}
+
+// routeVar is a route as rendered into the vhost templates
+type routeVar struct {
+	Path      string // URL prefix with a trailing slash
+	Prefix    string // URL prefix without it, redirected to Path
+	Pattern   string // Path escaped for regular expressions
+	Alias     string // Root with a trailing slash, "" when the path is served from the document root
+	Dir       string // Folder the path maps to, with a trailing slash
+	PHPSocket string
+}
+
+// reservedRoutes are paths webstack serves itself on every domain
+var reservedRoutes = []string{"/error", "/.well-known"}
+
+// parseRoute validates a route and returns it with a clean path
+func parseRoute(r Route) (Route, error) {
+	if !strings.HasPrefix(r.Path, "/") {
+		return r, fmt.Errorf("path must start with /: %s", r.Path)
+	}
+	r.Path = path.Clean(r.Path)
+	if r.Path == "/" {
+		return r, fmt.Errorf("the whole domain is set with 'webstack domain edit'")
+	}
+	if strings.ContainsAny(r.Path, " \t\r\n;{}\"'\\()[]*?^$|+") {
+		return r, fmt.Errorf("path contains characters not allowed in a vhost: %s", r.Path)
+	}
+	for _, reserved := range reservedRoutes {
+		if r.Path == reserved || strings.HasPrefix(r.Path, reserved+"/") {
+			return r, fmt.Errorf("%s is served by webstack on every domain", reserved)
+		}
+	}
+
+	if r.PHPVersion == "" && r.Root == "" {
+		return r, fmt.Errorf("a route needs --php, --root or both")
+	}
+	if r.PHPVersion != "" && !isValidPHPVersion(r.PHPVersion) {
+		return r, fmt.Errorf("unknown PHP version %s", r.PHPVersion)
+	}
+	if r.Root != "" {
+		root, err := CleanFolder(r.Root)
+		if err != nil {
+			return r, err
+		}
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			return r, fmt.Errorf("%s is not an existing folder", root)
+		}
+		r.Root = root
+	}
+	return r, nil
+}
+
+// routeVars returns the routes of a domain for the vhost templates. Routes
+// on another PHP version use its shared pool; the domain's own version
+// keeps the domain's pool and its php.ini overrides.
+func routeVars(d Domain) []routeVar {
+	var vars []routeVar
+	for _, r := range d.Routes {
+		v := routeVar{
+			Path:      r.Path + "/",
+			Prefix:    r.Path,
+			Pattern:   regexp.QuoteMeta(r.Path + "/"),
+			Dir:       strings.TrimSuffix(d.DocumentRoot, "/") + r.Path + "/",
+			PHPSocket: phpSocket(d),
+		}
+		if r.Root != "" {
+			v.Alias = r.Root + "/"
+			v.Dir = v.Alias
+		}
+		if r.PHPVersion != "" && r.PHPVersion != d.PHPVersion {
+			v.PHPSocket = fmt.Sprintf("unix:/run/php/php%s-fpm.sock", r.PHPVersion)
+		}
+		vars = append(vars, v)
+	}
+	return vars
+}
+
+// AddRoute adds or replaces the route of a path and regenerates the
+// domain's configuration
+func AddRoute(domainName string, route Route) {
+	route, err := parseRoute(route)
+	if err != nil {
+		ui.Invalid("Invalid route: %v\n", err)
+		return
+	}
+
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+	if !backendUsesPHP(d.Backend) {
+		ui.Fail("❌ %s is a %s domain; routes need the nginx or apache backend\n", d.Name, d.Backend)
+		return
+	}
+	if route.PHPVersion == d.PHPVersion {
+		route.PHPVersion = ""
+	}
+	if route.PHPVersion != "" {
+		if err := checkPHPFPM(route.PHPVersion); err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+	}
+	if route.PHPVersion == "" && route.Root == "" {
+		ui.Invalid("Invalid route: %s already runs PHP %s\n", d.Name, d.PHPVersion)
+		return
+	}
+	previous := append([]Route(nil), d.Routes...)
+
+	replaced := false
+	for i, r := range d.Routes {
+		if r.Path == route.Path {
+			d.Routes[i] = route
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		d.Routes = append(d.Routes, route)
+	}
+
+	if err := updateRoutes(*d, previous); err != nil {
+		ui.Fail("❌ Could not add route: %v\n", err)
+		return
+	}
+
+	fmt.Printf("✅ Route added for %s: %s\n", domainName, describeRoute(*d, route))
+}
+
+// RemoveRoute removes the route of a path and regenerates the configuration
+func RemoveRoute(domainName, routePath string) {
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+	previous := append([]Route(nil), d.Routes...)
+
+	routePath = path.Clean(routePath)
+	found := false
+	for i, r := range d.Routes {
+		if r.Path == routePath {
+			d.Routes = append(d.Routes[:i], d.Routes[i+1:]...)
+			found = true
+			break
+		}
+	}
+	if !found {
+		ui.Fail("No route for %s configured for %s\n", routePath, domainName)
+		return
+	}
+
+	if err := updateRoutes(*d, previous); err != nil {
+		ui.Fail("❌ Could not remove route: %v\n", err)
+		return
+	}
+
+	fmt.Printf("✅ Route for %s removed for %s\n", routePath, domainName)
+}
+
+// ListRoutes displays the routes configured for a domain
+func ListRoutes(domainName string) {
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+
+	if len(d.Routes) == 0 {
+		fmt.Printf("No routes configured for %s (everything runs PHP %s from %s)\n", domainName, d.PHPVersion, d.DocumentRoot)
+		return
+	}
+
+	fmt.Printf("Routes for %s:\n", domainName)
+	fmt.Println("===================")
+	for _, r := range d.Routes {
+		fmt.Printf("  %s\n", describeRoute(*d, r))
+	}
+}
+
+func describeRoute(d Domain, r Route) string {
+	version, root := d.PHPVersion, d.DocumentRoot+r.Path
+	if r.PHPVersion != "" {
+		version = r.PHPVersion
+	}
+	if r.Root != "" {
+		root = r.Root
+	}
+	return fmt.Sprintf("%s → PHP %s, %s", r.Path, version, root)
+}
+
+// updateRoutes saves the domain and applies its configuration, restoring
+// the previous routes if the new configuration is rejected
+func updateRoutes(d Domain, previous []Route) error {
+	if err := saveDomain(d); err != nil {
+		return fmt.Errorf("could not save domain: %v", err)
+	}
+
+	if err := applyConfig(d, false); err != nil {
+		d.Routes = previous
+		if saveErr := saveDomain(d); saveErr != nil {
+			ui.Warn("⚠️  Warning: Could not restore routes: %v\n", saveErr)
+		}
+		return err
+	}
+
+	reloadWebServers()
+	smokeTest(d)
+	return nil
+}
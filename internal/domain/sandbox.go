@@ -0,0 +1,291 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"webstack-cli/internal/templates"
+	"webstack-cli/internal/ui"
+)
+
+// TemplateTestOptions holds the settings of a template test run
+type TemplateTestOptions struct {
+	Template string // Embedded template (domain.conf, apache/domain.conf) or a file on disk
+	Server   string // "nginx" or "apache"; detected from the template when empty
+	VarsFile string // JSON object overriding the sample template variables
+	Sandbox  string // Directory the template is rendered into
+	Watch    bool   // Re-run whenever the template or the variables file changes
+}
+
+// DefaultSandbox is where templates are rendered and checked by TestTemplate
+var DefaultSandbox = filepath.Join(os.TempDir(), "webstack-template-test")
+
+// TestTemplate renders a vhost template into a sandbox directory and runs
+// the web server's syntax check against it. The sandbox has its own main
+// configuration, logs and certificate, so live configs are never touched.
+func TestTemplate(opts TemplateTestOptions) {
+	if opts.Sandbox == "" {
+		opts.Sandbox = DefaultSandbox
+	}
+	if opts.Server == "" {
+		opts.Server = templateServer(opts.Template)
+	}
+	if opts.Server != "nginx" && opts.Server != "apache" {
+		ui.Invalid("Invalid server: %s. Use 'nginx' or 'apache'\n", opts.Server)
+		return
+	}
+
+	testTemplateOnce(opts)
+	if !opts.Watch {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("👀 Watching for changes (Ctrl+C to stop)...")
+	last := templateModTimes(opts)
+	for {
+		time.Sleep(time.Second)
+		current := templateModTimes(opts)
+		if current == last {
+			continue
+		}
+		last = current
+		fmt.Printf("\n🔄 Change detected at %s\n", time.Now().Format("15:04:05"))
+		testTemplateOnce(opts)
+	}
+}
+
+// CheckTemplate renders a template with the sample variables into the
+// default sandbox and syntax-checks it, reporting whether it passed
+func CheckTemplate(name, server string) bool {
+	return testTemplateOnce(TemplateTestOptions{Template: name, Server: server, Sandbox: DefaultSandbox})
+}
+
+// templateServer guesses the web server of a template from its path
+func templateServer(name string) string {
+	if strings.HasPrefix(name, "apache/") || strings.Contains(filepath.Base(name), "apache") {
+		return "apache"
+	}
+	return "nginx"
+}
+
+// templateModTimes returns the modification times of the template and
+// variables files, used to detect edits in watch mode
+func templateModTimes(opts TemplateTestOptions) string {
+	var stamps []string
+	for _, path := range []string{opts.Template, opts.VarsFile} {
+		if info, err := os.Stat(path); err == nil {
+			stamps = append(stamps, info.ModTime().String())
+		}
+	}
+	return strings.Join(stamps, "|")
+}
+
+func testTemplateOnce(opts TemplateTestOptions) bool {
+	content, source, err := readTestTemplate(opts.Template, opts.Server)
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return false
+	}
+
+	if err := os.RemoveAll(opts.Sandbox); err != nil {
+		ui.Fail("❌ Could not clean sandbox %s: %v\n", opts.Sandbox, err)
+		return false
+	}
+	for _, dir := range []string{"htdocs", "logs", "configs", "error", "ssl", "cache", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(opts.Sandbox, dir), 0755); err != nil {
+			ui.Fail("❌ Could not create sandbox: %v\n", err)
+			return false
+		}
+	}
+
+	vars, err := sandboxVars(opts)
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return false
+	}
+
+	tmpl, err := template.New(filepath.Base(source)).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		ui.Fail("❌ Could not parse %s: %v\n", source, err)
+		return false
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		ui.Fail("❌ Could not render %s: %v\n", source, err)
+		return false
+	}
+
+	site := filepath.Join(opts.Sandbox, "site.conf")
+	if err := ioutil.WriteFile(site, []byte(buf.String()), 0644); err != nil {
+		ui.Fail("❌ Could not write %s: %v\n", site, err)
+		return false
+	}
+	fmt.Printf("✅ Rendered %s: %s\n", source, site)
+
+	binary, args, err := writeSandboxMain(opts.Sandbox, opts.Server, site)
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return false
+	}
+	if _, err := exec.LookPath(binary); err != nil {
+		ui.Warn("⚠️  %s is not installed, syntax check skipped\n", binary)
+		return true
+	}
+
+	output, err := exec.Command(binary, args...).CombinedOutput()
+	if err != nil {
+		ui.Fail("❌ %s syntax check failed:\n", opts.Server)
+		for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+			fmt.Printf("   %s\n", line)
+		}
+		return false
+	}
+	fmt.Printf("✅ %s syntax check passed\n", opts.Server)
+	return true
+}
+
+// readTestTemplate reads a template file, falling back to the embedded
+// templates of the server ("domain-ssl.conf" or "nginx/domain-ssl.conf")
+func readTestTemplate(name, server string) ([]byte, string, error) {
+	if content, err := ioutil.ReadFile(name); err == nil {
+		return content, name, nil
+	}
+
+	embedded := name
+	if !strings.Contains(name, "/") {
+		embedded = server + "/" + name
+	}
+	content, err := templates.GetTemplate(embedded)
+	if err != nil {
+		return nil, "", fmt.Errorf("template %s not found on disk or in the embedded templates", name)
+	}
+	return content, embedded, nil
+}
+
+// sandboxVars returns sample template variables pointing into the sandbox,
+// overridden by the variables file
+func sandboxVars(opts TemplateTestOptions) (map[string]interface{}, error) {
+	sandbox := opts.Sandbox
+	vars := map[string]interface{}{
+		"Domain":       "example.test",
+		"DocumentRoot": filepath.Join(sandbox, "htdocs"),
+		"AppRoot":      filepath.Join(sandbox, "htdocs"),
+		"ErrorDir":     filepath.Join(sandbox, "error"),
+		"ConfigsDir":   filepath.Join(sandbox, "configs"),
+		"LogsDir":      filepath.Join(sandbox, "logs"),
+		"PHPVersion":   "8.3",
+		"PHPSocket":    "unix:" + filepath.Join(sandbox, "php-fpm.sock"),
+		"ApachePort":   8080,
+		"Redirects":    []Redirect{},
+		"Routes":       []routeVar{},
+		"Auth":         []authVar{},
+		"AuthRealm":    authRealm,
+		"Access":       []AccessRule{},
+		"ApacheAccess": "",
+		"PresetNginx":  "",
+		"PresetApache": "",
+		"Hardening":    true,
+		"HTTP3":        false,
+		"Headers":      securityHeaders(Domain{}, true),
+		"SSLCert":      filepath.Join(sandbox, "ssl", "certificate.crt"),
+		"SSLKey":       filepath.Join(sandbox, "ssl", "private.key"),
+	}
+	vars["NoCompression"] = false
+	vars["Brotli"] = false
+	vars["ApacheHeaders"] = vars["Headers"]
+	redirectVars(Domain{Name: "example.test"}, vars)
+	upstreamVars(Domain{Upstream: "http://127.0.0.1:3000"}, vars)
+	vars["Cache"] = newCacheRules(CacheSettings{TTL: defaultCacheTTL})
+	vars["RateLimit"] = rateLimitVars(Domain{Name: "example.test"})
+	vars["Vars"] = map[string]string{}
+
+	if opts.VarsFile != "" {
+		data, err := ioutil.ReadFile(opts.VarsFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read variables file: %v", err)
+		}
+		var overrides map[string]interface{}
+		if err := json.Unmarshal(data, &overrides); err != nil {
+			return nil, fmt.Errorf("could not parse variables file %s: %v", opts.VarsFile, err)
+		}
+		for key, value := range overrides {
+			vars[key] = value
+		}
+	}
+
+	// The syntax check loads the certificate, so provide a throwaway one
+	cert, _ := vars["SSLCert"].(string)
+	key, _ := vars["SSLKey"].(string)
+	if strings.HasPrefix(cert, sandbox) && strings.HasPrefix(key, sandbox) {
+		if _, err := exec.LookPath("openssl"); err == nil {
+			exec.Command("openssl", "req", "-x509", "-nodes", "-newkey", "rsa:2048", "-days", "1",
+				"-subj", fmt.Sprintf("/CN=%v", vars["Domain"]), "-keyout", key, "-out", cert).Run()
+		}
+	}
+	return vars, nil
+}
+
+// writeSandboxMain writes a minimal main configuration that only includes
+// the rendered site and defines what the vhost templates expect from the
+// main config, and returns the syntax check command for it
+func writeSandboxMain(sandbox, server, site string) (string, []string, error) {
+	if server == "apache" {
+		main := filepath.Join(sandbox, "apache2.conf")
+		content := fmt.Sprintf(`# WebStack template sandbox
+ServerRoot "/etc/apache2"
+PidFile %[1]s/apache2.pid
+ErrorLog %[1]s/logs/apache-main-error.log
+Mutex file:%[1]s default
+IncludeOptional mods-enabled/*.load
+IncludeOptional mods-enabled/*.conf
+Include %[2]s
+`, sandbox, site)
+		if err := ioutil.WriteFile(main, []byte(content), 0644); err != nil {
+			return "", nil, fmt.Errorf("could not write %s: %v", main, err)
+		}
+		return "apache2ctl", []string{"-t", "-f", main}, nil
+	}
+
+	main := filepath.Join(sandbox, "nginx.conf")
+	content := fmt.Sprintf(`# WebStack template sandbox
+pid       %[1]s/nginx.pid;
+error_log %[1]s/logs/nginx-main-error.log;
+include   /etc/nginx/modules-enabled/*.conf;
+
+events {
+	worker_connections 64;
+}
+
+http {
+	include                 /etc/nginx/mime.types;
+	client_body_temp_path   %[1]s/tmp/body;
+	proxy_temp_path         %[1]s/tmp/proxy;
+	fastcgi_temp_path       %[1]s/tmp/fastcgi;
+	uwsgi_temp_path         %[1]s/tmp/uwsgi;
+	scgi_temp_path          %[1]s/tmp/scgi;
+	fastcgi_cache_path      %[1]s/cache/fastcgi levels=1:2 keys_zone=fastcgi_cache:1m;
+	proxy_cache_path        %[1]s/cache/proxy levels=1:2 keys_zone=proxy_cache:1m;
+
+	log_format main '$remote_addr - $remote_user [$time_local] "$request" '
+	                '$status $body_bytes_sent "$http_referer" '
+	                '"$http_user_agent" "$http_x_forwarded_for"';
+
+	map $http_cookie $no_cache {
+		default 0;
+	}
+
+	include %[2]s;
+}
+`, sandbox, site)
+	if err := ioutil.WriteFile(main, []byte(content), 0644); err != nil {
+		return "", nil, fmt.Errorf("could not write %s: %v", main, err)
+	}
+	return "nginx", []string{"-t", "-p", sandbox, "-c", main}, nil
+}
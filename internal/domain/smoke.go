@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/ui"
+)
+
+const smokeTestTimeout = 5 * time.Second
+
+// SmokeTest requests the home page of each domain over the loopback interface
+// (with the domain's Host header) and reports the status codes, catching sites
+// that return 404/500 after a reload because of a bad root or socket path
+func SmokeTest(domainNames ...string) {
+	var domains []Domain
+	for _, name := range domainNames {
+		if d, err := GetDomain(name); err == nil {
+			domains = append(domains, *d)
+		}
+	}
+	smokeTest(domains...)
+}
+
+func smokeTest(domains ...Domain) {
+	if len(domains) == 0 {
+		return
+	}
+	if dryrun.Enabled() {
+		for _, d := range domains {
+			fmt.Printf("🔎 [dry-run] would request http://%s/ via 127.0.0.1\n", d.Name)
+		}
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil || cfg == nil {
+		cfg = config.DefaultConfig()
+	}
+
+	fmt.Println("🩺 Checking sites...")
+	for _, d := range domains {
+		url, status, err := requestLoopback(d, cfg)
+		switch {
+		case err != nil:
+			ui.Warn("⚠️  %s: no response from %s: %v\n", d.Name, url, err)
+		case status >= 400 && status != http.StatusUnauthorized:
+			hint := "check the document root and PHP-FPM socket"
+			if d.Backend == "proxy" {
+				hint = "check that the app is listening on " + d.Upstream
+			} else if d.Backend == "static" {
+				hint = "check the document root"
+			}
+			ui.Warn("⚠️  %s: %s returned %d %s (%s)\n", d.Name, url, status, http.StatusText(status), hint)
+		default:
+			fmt.Printf("✅ %s: %s returned %d %s\n", d.Name, url, status, http.StatusText(status))
+		}
+	}
+}
+
+// Probe requests / of a domain from the local web server, as the check after
+// a change does, and returns the URL and the status code
+func Probe(d Domain) (string, int, error) {
+	cfg, err := config.Load()
+	if err != nil || cfg == nil {
+		cfg = config.DefaultConfig()
+	}
+	return requestLoopback(d, cfg)
+}
+
+// requestLoopback sends a GET / for the domain to the local web server
+func requestLoopback(d Domain, cfg *config.Config) (string, int, error) {
+	scheme := "http"
+	port := cfg.GetPort("nginx")
+	if nginxTemplate, _ := vhostLayout(d, cfg); nginxTemplate == "" {
+		port = cfg.GetPort("apache")
+	}
+	if d.SSLEnabled {
+		scheme = "https"
+		port = 443
+	}
+	url := fmt.Sprintf("%s://%s/", scheme, d.Name)
+
+	// Connect to 127.0.0.1 whatever the domain resolves to
+	address := net.JoinHostPort("127.0.0.1", fmt.Sprint(port))
+	dialer := &net.Dialer{Timeout: smokeTestTimeout}
+	client := &http.Client{
+		Timeout: smokeTestTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, address)
+			},
+			TLSClientConfig: &tls.Config{ServerName: d.Name, InsecureSkipVerify: true},
+		},
+		// Report redirects instead of following them
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return url, 0, err
+	}
+	resp.Body.Close()
+	return url, resp.StatusCode, nil
+}
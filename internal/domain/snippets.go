@@ -0,0 +1,178 @@
+package domain
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/ui"
+)
+
+var snippetNamePattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// configsDir returns the directory holding the custom snippets of a domain.
+// Nginx includes configs/nginx*.conf, Apache configs/apache*.conf and Caddy
+// configs/caddy*.conf.
+func configsDir(domainName string) string {
+	return filepath.Join(HomeDir(domainName), "configs")
+}
+
+// snippetPath returns the snippet file for a web server, e.g. nginx.conf or
+// nginx-headers.conf when a name is given
+func snippetPath(domainName, server, name string) (string, error) {
+	if _, ok := backendServer(server); !ok && server != "nginx" && server != "apache" {
+		return "", fmt.Errorf("server must be nginx, apache or caddy: %s", server)
+	}
+	if name == "" {
+		return filepath.Join(configsDir(domainName), server+".conf"), nil
+	}
+	if !snippetNamePattern.MatchString(name) {
+		return "", fmt.Errorf("snippet name may only contain lowercase letters, digits, - and _: %s", name)
+	}
+	return filepath.Join(configsDir(domainName), server+"-"+name+".conf"), nil
+}
+
+// EditSnippet opens a custom vhost snippet of a domain in $EDITOR, validates
+// the web server configuration when the editor exits and reloads it. An
+// invalid snippet can be edited again or reverted, so it never stays in place.
+func EditSnippet(domainName, server, name string) {
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+
+	if server == "" {
+		server = "nginx"
+	}
+	path, err := snippetPath(d.Name, server, name)
+	if err != nil {
+		ui.Invalid("Invalid snippet: %v\n", err)
+		return
+	}
+
+	if dryrun.Enabled() {
+		fmt.Printf("🔎 [dry-run] would edit %s, validate the %s configuration and reload\n", path, server)
+		return
+	}
+
+	// Keep the previous content to revert an invalid snippet
+	previous, err := ioutil.ReadFile(path)
+	existed := err == nil
+	if !existed {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			ui.Fail("Error creating directory %s: %v\n", filepath.Dir(path), err)
+			return
+		}
+		previous = []byte(snippetHeader(d.Name, server))
+		if err := ioutil.WriteFile(path, previous, 0644); err != nil {
+			ui.Fail("Error creating snippet %s: %v\n", path, err)
+			return
+		}
+	}
+
+	for {
+		if err := openEditor(path); err != nil {
+			ui.Fail("❌ Could not run editor: %v\n", err)
+			revertSnippet(path, previous, existed)
+			return
+		}
+
+		current, err := ioutil.ReadFile(path)
+		if err != nil {
+			ui.Fail("❌ Could not read %s: %v\n", path, err)
+			return
+		}
+		if existed && bytes.Equal(current, previous) {
+			fmt.Println("ℹ️  No changes")
+			return
+		}
+
+		// Regenerate the vhost so older configurations gain the include,
+		// then validate with the snippet in place
+		err = applyConfig(*d, false)
+		if err == nil {
+			err = testWebServers(map[string]bool{server: true})
+		}
+		if err == nil {
+			break
+		}
+
+		ui.Fail("❌ %s configuration is invalid: %v\n", server, err)
+		if !promptYesNo("Edit the snippet again? (Y/n): ", true) {
+			revertSnippet(path, previous, existed)
+			return
+		}
+	}
+
+	fmt.Printf("✅ Snippet saved: %s\n", path)
+	reloadWebServers()
+	smokeTest(*d)
+}
+
+// snippetHeader is the initial content of a new snippet
+func snippetHeader(domainName, server string) string {
+	scope := "server block"
+	if server == "apache" {
+		scope = "<VirtualHost>"
+	} else if ws, ok := backendServer(server); ok {
+		scope = ws.Label() + " site block"
+	}
+	return fmt.Sprintf("# Custom %s rules for %s\n"+
+		"# Included in the %s of the generated vhost and kept across\n"+
+		"# 'webstack domain rebuild-configs'. Add locations, headers or redirects here.\n", server, domainName, scope)
+}
+
+// openEditor runs $VISUAL or $EDITOR (falling back to nano, then vi) on a file
+func openEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if _, err := exec.LookPath("nano"); err == nil {
+			editor = "nano"
+		}
+	}
+
+	// $EDITOR may contain arguments, e.g. "code --wait"
+	parts := strings.Fields(editor)
+	cmd := exec.Command(parts[0], append(parts[1:], path)...)
+	// Editors need the terminal, not the output filter
+	cmd.Stdin = os.Stdin
+	cmd.Stdout, cmd.Stderr = ui.Terminal()
+	return cmd.Run()
+}
+
+// revertSnippet restores the previous snippet, or removes a new one
+func revertSnippet(path string, previous []byte, existed bool) {
+	var err error
+	if existed {
+		err = ioutil.WriteFile(path, previous, 0644)
+	} else {
+		err = os.Remove(path)
+	}
+	if err != nil {
+		ui.Warn("⚠️  Warning: Could not revert %s: %v\n", path, err)
+		return
+	}
+	fmt.Printf("↩️  Snippet %s reverted, web servers were not reloaded\n", path)
+}
+
+func promptYesNo(question string, defaultYes bool) bool {
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print(question)
+	response, _ := reader.ReadString('\n')
+	response = strings.TrimSpace(strings.ToLower(response))
+	if response == "" {
+		return defaultYes
+	}
+	return response == "y" || response == "yes"
+}
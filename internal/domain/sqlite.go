@@ -0,0 +1,357 @@
+package domain
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"webstack-cli/internal/bytesize"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/osinfo"
+	"webstack-cli/internal/pkg"
+	"webstack-cli/internal/prompt"
+	"webstack-cli/internal/ui"
+
+	_ "modernc.org/sqlite"
+)
+
+// DefaultSQLiteName is the database created when no name is given
+const DefaultSQLiteName = "database"
+
+// sqliteNamePattern matches SQLite database names, which become file names
+var sqliteNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// sqliteSidecars are the files SQLite keeps next to a database
+var sqliteSidecars = []string{"-wal", "-shm", "-journal"}
+
+// SQLiteDir returns the folder of a domain's SQLite databases. It sits next
+// to htdocs, so the database files can't be downloaded.
+func (d Domain) SQLiteDir() string {
+	return filepath.Join(d.HomeDir(), "databases")
+}
+
+// SQLitePath returns the file of a SQLite database of a domain
+func (d Domain) SQLitePath(name string) string {
+	return filepath.Join(d.SQLiteDir(), name+".sqlite")
+}
+
+// CreateSQLite creates a SQLite database for a domain, writable by the web
+// server, and records it in domains.json so backups include it. An existing
+// file of that name is kept and recorded as it is.
+func CreateSQLite(domainName, name string) {
+	if name == "" {
+		name = DefaultSQLiteName
+	}
+	if !sqliteNamePattern.MatchString(name) {
+		ui.Invalid("Invalid database name %s (use letters, digits, - and _)\n", name)
+		return
+	}
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+	if hasSQLite(*d, name) {
+		ui.Fail("❌ %s already has the SQLite database %s: %s\n", d.Name, name, d.SQLitePath(name))
+		return
+	}
+
+	if usesPHP(*d) {
+		ensureSQLiteExtension(d.PHPVersion)
+	} else {
+		fmt.Printf("ℹ️  %s is a %s domain, no PHP extension is checked\n", d.Name, d.Backend)
+	}
+
+	path := d.SQLitePath(name)
+	if _, err := os.Stat(path); err == nil {
+		fmt.Printf("ℹ️  %s already exists and is kept\n", path)
+	} else {
+		fmt.Printf("🗄️  Creating SQLite database %s for %s...\n", name, d.Name)
+	}
+	if err := createSQLiteFile(d.SQLiteDir(), path); err != nil {
+		ui.Fail("❌ Could not create %s: %v\n", path, err)
+		return
+	}
+
+	d.SQLite = append(d.SQLite, name)
+	sort.Strings(d.SQLite)
+	if err := saveDomain(*d); err != nil {
+		ui.Fail("Error saving domain: %v\n", err)
+		return
+	}
+
+	fmt.Printf("✅ SQLite database %s created: %s\n", name, path)
+	fmt.Println("   Connect with:")
+	fmt.Printf("   PDO:     new PDO('sqlite:%s')\n", path)
+	fmt.Printf("   Laravel: DB_CONNECTION=sqlite and DB_DATABASE=%s\n", path)
+}
+
+// DeleteSQLite deletes a SQLite database of a domain (asking unless force
+// is set) and stops tracking it
+func DeleteSQLite(domainName, name string, force bool) {
+	if name == "" {
+		name = DefaultSQLiteName
+	}
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+	if !hasSQLite(*d, name) {
+		ui.Fail("❌ %s has no SQLite database %s\n", d.Name, name)
+		return
+	}
+	path := d.SQLitePath(name)
+
+	if !prompt.Allow(fmt.Sprintf("delete SQLite database %s", path)) {
+		return
+	}
+	if !force {
+		fmt.Printf("Are you sure you want to delete %s? This cannot be undone!\n", path)
+		if !prompt.ConfirmTyped() {
+			fmt.Println("Deletion cancelled")
+			return
+		}
+	}
+
+	for _, file := range append([]string{path}, sidecarPaths(path)...) {
+		if err := dryrun.Remove(file); err != nil && !os.IsNotExist(err) {
+			ui.Fail("❌ Could not delete %s: %v\n", file, err)
+			return
+		}
+	}
+
+	var kept []string
+	for _, existing := range d.SQLite {
+		if existing != name {
+			kept = append(kept, existing)
+		}
+	}
+	d.SQLite = kept
+	if err := saveDomain(*d); err != nil {
+		ui.Fail("Error saving domain: %v\n", err)
+		return
+	}
+	fmt.Printf("✅ SQLite database %s of %s deleted\n", name, d.Name)
+}
+
+// ListSQLite lists the SQLite databases of a domain, or of all domains
+// when domainName is empty
+func ListSQLite(domainName string) {
+	domains, err := All()
+	if err != nil {
+		ui.Fail("Error loading domains: %v\n", err)
+		return
+	}
+
+	fmt.Println("SQLite Databases:")
+	fmt.Println("─────────────────────────────────────────")
+	found := false
+	for _, d := range domains {
+		if domainName != "" && d.Name != domainName {
+			continue
+		}
+		for _, name := range d.SQLite {
+			found = true
+			size := "missing"
+			if info, err := os.Stat(d.SQLitePath(name)); err == nil {
+				size = bytesize.Format(info.Size())
+			}
+			fmt.Printf("  %-30s %-12s %10s  %s\n", d.Name, name, size, d.SQLitePath(name))
+		}
+	}
+	if !found {
+		fmt.Println("  No SQLite databases")
+	}
+}
+
+// SQLiteInfo shows the size, tables and journal mode of a SQLite database
+func SQLiteInfo(domainName, name string) {
+	if name == "" {
+		name = DefaultSQLiteName
+	}
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+	if !hasSQLite(*d, name) {
+		ui.Fail("❌ %s has no SQLite database %s\n", d.Name, name)
+		return
+	}
+
+	path := d.SQLitePath(name)
+	info, err := os.Stat(path)
+	if err != nil {
+		ui.Fail("❌ %s is tracked but missing: %v\n", path, err)
+		return
+	}
+
+	conn, err := openSQLite(path)
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	defer conn.Close()
+
+	var tables int
+	var journal string
+	conn.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`).Scan(&tables)
+	conn.QueryRow(`PRAGMA journal_mode`).Scan(&journal)
+
+	fmt.Printf("SQLite database %s of %s:\n", name, d.Name)
+	fmt.Printf("   File:         %s\n", path)
+	fmt.Printf("   Size:         %s\n", bytesize.Format(info.Size()))
+	fmt.Printf("   Tables:       %d\n", tables)
+	fmt.Printf("   Journal mode: %s\n", journal)
+	fmt.Printf("   Modified:     %s\n", info.ModTime().Format("2006-01-02 15:04"))
+}
+
+// SnapshotSQLite writes a consistent copy of a SQLite database of a domain
+// to dest, also while the site writes to it
+func SnapshotSQLite(d Domain, name, dest string) error {
+	// Opening a missing file would create an empty database
+	if _, err := os.Stat(d.SQLitePath(name)); err != nil {
+		return err
+	}
+	conn, err := openSQLite(d.SQLitePath(name))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	os.Remove(dest)
+	if _, err := conn.Exec(`VACUUM INTO ?`, dest); err != nil {
+		return fmt.Errorf("could not snapshot %s: %v", d.SQLitePath(name), err)
+	}
+	return nil
+}
+
+// RestoreSQLite replaces a SQLite database of a domain with a snapshot
+// taken by SnapshotSQLite
+func RestoreSQLite(d Domain, name, src string) error {
+	path := d.SQLitePath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+
+	source, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	tmp := path + ".restore"
+	destination, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0660)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(destination, source); err != nil {
+		destination.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := destination.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// A WAL left by the replaced database would be applied to the snapshot
+	for _, file := range sidecarPaths(path) {
+		os.Remove(file)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	os.Chmod(path, 0660)
+	exec.Command("chown", "www-data:www-data", filepath.Dir(path), path).Run()
+	return nil
+}
+
+// createSQLiteFile creates the database folder and file owned by www-data,
+// which needs to write to the folder for SQLite's journal
+func createSQLiteFile(dir, path string) error {
+	if err := dryrun.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+	if dryrun.Enabled() {
+		fmt.Printf("🔎 [dry-run] would create the SQLite database %s\n", path)
+	} else if _, err := os.Stat(path); os.IsNotExist(err) {
+		conn, err := openSQLite(path)
+		if err != nil {
+			return err
+		}
+		// WAL lets requests read while another one writes
+		_, err = conn.Exec(`PRAGMA journal_mode=WAL`)
+		conn.Close()
+		if err != nil {
+			return err
+		}
+		if err := os.Chmod(path, 0660); err != nil {
+			return err
+		}
+	}
+	return dryrun.Run(exec.Command("chown", "www-data:www-data", dir, path))
+}
+
+func openSQLite(path string) (*sql.DB, error) {
+	conn, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %v", path, err)
+	}
+	conn.SetMaxOpenConns(1)
+	return conn, nil
+}
+
+// ensureSQLiteExtension installs the SQLite extension of a PHP version
+// when PHP-FPM doesn't load pdo_sqlite and sqlite3
+func ensureSQLiteExtension(version string) {
+	binary := osinfo.Current().PHPFPMBinary(version)
+	output, err := exec.Command(binary, "-m").Output()
+	if err != nil {
+		ui.Warn("⚠️  Warning: Could not list the modules of PHP %s: %v\n", version, err)
+		return
+	}
+
+	modules := map[string]bool{}
+	for _, line := range strings.Split(string(output), "\n") {
+		modules[strings.ToLower(strings.TrimSpace(line))] = true
+	}
+	if modules["pdo_sqlite"] && modules["sqlite3"] {
+		fmt.Printf("✓ PHP %s has the pdo_sqlite and sqlite3 extensions\n", version)
+		return
+	}
+
+	fmt.Printf("📦 Installing the SQLite extension of PHP %s...\n", version)
+	if err := pkg.Install(fmt.Sprintf("php%s-sqlite3", version)); err != nil {
+		ui.Warn("⚠️  Warning: Could not install the SQLite extension of PHP %s: %v\n", version, err)
+		return
+	}
+	reloadPHPFPM([]string{version})
+}
+
+func hasSQLite(d Domain, name string) bool {
+	for _, existing := range d.SQLite {
+		if existing == name {
+			return true
+		}
+	}
+	return false
+}
+
+func sidecarPaths(path string) []string {
+	var paths []string
+	for _, suffix := range sqliteSidecars {
+		paths = append(paths, path+suffix)
+	}
+	return paths
+}
@@ -0,0 +1,297 @@
+package domain
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"webstack-cli/internal/bytesize"
+	"webstack-cli/internal/ui"
+)
+
+// StatsOptions holds the settings of a traffic report
+type StatsOptions struct {
+	Window string // Time window: 24h, 7d, 4w or "all"
+	Top    int    // Number of top URLs and IPs to list
+	JSON   bool   // Print the report as JSON
+}
+
+// TrafficStats is the traffic report of a domain built from its access logs
+type TrafficStats struct {
+	Domain        string         `json:"domain"`
+	Window        string         `json:"window"`
+	Since         *time.Time     `json:"since,omitempty"`
+	Hits          int            `json:"hits"`
+	Bytes         int64          `json:"bytes"`
+	UniqueIPs     int            `json:"unique_ips"`
+	StatusClasses map[string]int `json:"status_classes"`
+	StatusCodes   map[string]int `json:"status_codes"`
+	TopURLs       []StatsEntry   `json:"top_urls"`
+	TopIPs        []StatsEntry   `json:"top_ips"`
+	Skipped       int            `json:"skipped_lines"`
+}
+
+// StatsEntry is one row of a top list
+type StatsEntry struct {
+	Key   string `json:"key"`
+	Hits  int    `json:"hits"`
+	Bytes int64  `json:"bytes"`
+}
+
+// combinedLog matches the combined log format written by Nginx and Apache:
+// ip ident user [time] "request" status bytes ...
+var combinedLog = regexp.MustCompile(`^(\S+) \S+ \S+ \[([^\]]+)\] "([^"]*)" (\d{3}) (\d+|-)`)
+
+const combinedTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// ShowStats prints hits, bandwidth, status codes and the top URLs and IPs of
+// a domain over a time window, read from its access logs including rotated
+// (and compressed) ones
+func ShowStats(domainName string, opts StatsOptions) {
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+
+	if opts.Window == "" {
+		opts.Window = "24h"
+	}
+	if opts.Top <= 0 {
+		opts.Top = 10
+	}
+	window, err := parseStatsWindow(opts.Window)
+	if err != nil {
+		ui.Invalid("Invalid window: %s. Use e.g. 24h, 7d, 4w or all\n", opts.Window)
+		return
+	}
+
+	files := accessLogFiles(*d)
+	if len(files) == 0 {
+		ui.Warn("⚠️  No access logs found for %s in %s\n", d.Name, logsDir(d.Name))
+		return
+	}
+
+	var since time.Time
+	if window > 0 {
+		since = time.Now().Add(-window)
+	}
+	stats, err := collectStats(d.Name, files, since, opts.Top)
+	if err != nil {
+		ui.Fail("❌ Could not read access logs: %v\n", err)
+		return
+	}
+	stats.Window = opts.Window
+	if !since.IsZero() {
+		stats.Since = &since
+	}
+
+	if opts.JSON {
+		data, _ := json.MarshalIndent(stats, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	printStats(stats)
+}
+
+// parseStatsWindow parses a window such as 30m, 24h, 7d or 4w; "all" returns 0
+func parseStatsWindow(s string) (time.Duration, error) {
+	if s == "all" {
+		return 0, nil
+	}
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid window")
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid window")
+	}
+	switch s[len(s)-1] {
+	case 'm':
+		return time.Duration(n) * time.Minute, nil
+	case 'h':
+		return time.Duration(n) * time.Hour, nil
+	case 'd':
+		return time.Duration(n) * 24 * time.Hour, nil
+	case 'w':
+		return time.Duration(n) * 7 * 24 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("invalid window")
+}
+
+// accessLogFiles returns the current and rotated access logs of a domain.
+// Behind an Nginx proxy Apache logs the same requests again, so its log is
+// only used when Nginx has none.
+func accessLogFiles(d Domain) []string {
+	for _, name := range []string{"access.log", "apache-access.log"} {
+		files, _ := filepath.Glob(filepath.Join(logsDir(d.Name), name+"*"))
+		if len(files) > 0 {
+			return files
+		}
+	}
+	return nil
+}
+
+func collectStats(domainName string, files []string, since time.Time, top int) (*TrafficStats, error) {
+	stats := &TrafficStats{
+		Domain:        domainName,
+		StatusClasses: map[string]int{},
+		StatusCodes:   map[string]int{},
+	}
+	urls := map[string]*StatsEntry{}
+	ips := map[string]*StatsEntry{}
+
+	for _, file := range files {
+		// Rotated logs last written before the window hold nothing in it
+		if info, err := os.Stat(file); err != nil || (!since.IsZero() && info.ModTime().Before(since)) {
+			continue
+		}
+		if err := scanAccessLog(file, func(ip string, at time.Time, url, status string, bytes int64) {
+			if !since.IsZero() && at.Before(since) {
+				return
+			}
+			stats.Hits++
+			stats.Bytes += bytes
+			stats.StatusCodes[status]++
+			stats.StatusClasses[status[:1]+"xx"]++
+			countEntry(urls, url, bytes)
+			countEntry(ips, ip, bytes)
+		}, &stats.Skipped); err != nil {
+			return nil, err
+		}
+	}
+
+	stats.UniqueIPs = len(ips)
+	stats.TopURLs = topEntries(urls, top)
+	stats.TopIPs = topEntries(ips, top)
+	return stats, nil
+}
+
+// scanAccessLog calls fn for every request of a combined format log,
+// reading gzip-compressed rotations transparently
+func scanAccessLog(path string, fn func(ip string, at time.Time, url, status string, bytes int64), skipped *int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		m := combinedLog.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			*skipped++
+			continue
+		}
+		at, err := time.Parse(combinedTimeLayout, m[2])
+		if err != nil {
+			*skipped++
+			continue
+		}
+
+		// "GET /path?query HTTP/1.1" counts under /path
+		url := m[3]
+		if fields := strings.Fields(m[3]); len(fields) >= 2 {
+			url = fields[1]
+		}
+		if i := strings.IndexByte(url, '?'); i >= 0 {
+			url = url[:i]
+		}
+
+		bytes, _ := strconv.ParseInt(m[5], 10, 64)
+		fn(m[1], at, url, m[4], bytes)
+	}
+	return scanner.Err()
+}
+
+func countEntry(entries map[string]*StatsEntry, key string, bytes int64) {
+	e, ok := entries[key]
+	if !ok {
+		e = &StatsEntry{Key: key}
+		entries[key] = e
+	}
+	e.Hits++
+	e.Bytes += bytes
+}
+
+// topEntries returns the n entries with the most hits
+func topEntries(entries map[string]*StatsEntry, n int) []StatsEntry {
+	list := make([]StatsEntry, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, *e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Hits != list[j].Hits {
+			return list[i].Hits > list[j].Hits
+		}
+		return list[i].Key < list[j].Key
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+func printStats(stats *TrafficStats) {
+	period := "all logged traffic"
+	if stats.Since != nil {
+		period = "last " + stats.Window
+	}
+	fmt.Printf("📊 Traffic for %s (%s)\n", stats.Domain, period)
+	fmt.Println("─────────────────────────────────────────────────────────────────")
+	fmt.Printf("Hits:            %d\n", stats.Hits)
+	fmt.Printf("Bandwidth:       %s\n", bytesize.Format(stats.Bytes))
+	fmt.Printf("Unique IPs:      %d\n", stats.UniqueIPs)
+	if stats.Hits == 0 {
+		return
+	}
+
+	fmt.Println("\nStatus codes:")
+	for _, class := range sortedCounts(stats.StatusClasses) {
+		count := stats.StatusClasses[class]
+		fmt.Printf("  %s  %8d  %5.1f%%\n", class, count, float64(count)*100/float64(stats.Hits))
+		for _, code := range sortedCounts(stats.StatusCodes) {
+			if code[:1] == class[:1] {
+				fmt.Printf("    %s %8d\n", code, stats.StatusCodes[code])
+			}
+		}
+	}
+
+	fmt.Println("\nTop URLs:")
+	for _, e := range stats.TopURLs {
+		fmt.Printf("  %8d  %10s  %s\n", e.Hits, bytesize.Format(e.Bytes), e.Key)
+	}
+
+	fmt.Println("\nTop IPs:")
+	for _, e := range stats.TopIPs {
+		fmt.Printf("  %8d  %10s  %s\n", e.Hits, bytesize.Format(e.Bytes), e.Key)
+	}
+}
+
+func sortedCounts(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
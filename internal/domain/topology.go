@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/osinfo"
+)
+
+// apacheStandalone reports whether Apache serves the sites on its own, with
+// no Nginx in front of it
+func apacheStandalone(cfg *config.Config) bool {
+	return cfg.IsInstalled("apache") && !cfg.IsInstalled("nginx")
+}
+
+// vhostLayout returns the Nginx template a domain is served with ("domain",
+// "proxy", "static" or "upstream", empty when Nginx does not serve it) and
+// whether it needs an Apache vhost. On Apache-only servers every domain but
+// proxy domains is served by Apache, whatever its backend; proxy domains and
+// the domains of webServers are always proxied by Nginx.
+func vhostLayout(d Domain, cfg *config.Config) (string, bool) {
+	if _, ok := backendServer(d.Backend); d.Backend == "proxy" || ok {
+		return "upstream", false
+	}
+	if apacheStandalone(cfg) {
+		return "", true
+	}
+	if d.Backend == "static" {
+		return "static", false
+	}
+	if d.Backend != "apache" {
+		return "domain", false
+	}
+	if cfg.GetMode("nginx") == "proxy" {
+		return "proxy", true
+	}
+	if !cfg.IsInstalled("nginx") || cfg.GetMode("nginx") == "standalone" {
+		return "", true
+	}
+	return "", false
+}
+
+// Vhosts returns the vhost files generated for a domain, keyed by web
+// server ("nginx", "apache" or a backend of webServers)
+func Vhosts(d Domain) map[string]string {
+	cfg, err := config.Load()
+	if err != nil || cfg == nil {
+		cfg = config.DefaultConfig()
+	}
+
+	vhosts := map[string]string{}
+	nginxTemplate, apache := vhostLayout(d, cfg)
+	if nginxTemplate != "" {
+		vhosts["nginx"] = filepath.Join("/etc/nginx/sites-available", d.Name+".conf")
+	}
+	if apache {
+		vhosts["apache"] = filepath.Join(apacheSitesAvailable(), d.Name+".conf")
+	}
+	if ws, ok := backendServer(d.Backend); ok && nginxTemplate != "" {
+		vhosts[ws.Backend()] = ws.VhostPath(d.Name)
+	}
+	return vhosts
+}
+
+// apacheSitesAvailable and apacheSitesEnabled are Apache's vhost
+// directories. RHEL has no such directories; the installer creates them
+// below /etc/httpd and includes sites-enabled from httpd.conf.
+func apacheSitesAvailable() string {
+	return filepath.Join(osinfo.Current().ApacheDir, "sites-available")
+}
+
+func apacheSitesEnabled() string {
+	return filepath.Join(osinfo.Current().ApacheDir, "sites-enabled")
+}
+
+// EnableApacheSite links a vhost into sites-enabled, with a2ensite where
+// Apache ships it
+func EnableApacheSite(name string) error {
+	if !osinfo.Current().IsRHEL() {
+		return dryrun.Run(exec.Command("a2ensite", name))
+	}
+	link := filepath.Join(apacheSitesEnabled(), name+".conf")
+	if _, err := os.Lstat(link); err == nil {
+		return nil
+	}
+	if err := dryrun.MkdirAll(apacheSitesEnabled(), 0755); err != nil {
+		return err
+	}
+	return dryrun.Symlink(filepath.Join(apacheSitesAvailable(), name+".conf"), link)
+}
+
+// disableApacheSite removes a vhost from sites-enabled
+func disableApacheSite(name string) error {
+	if !osinfo.Current().IsRHEL() {
+		return dryrun.Run(exec.Command("a2dissite", name))
+	}
+	err := dryrun.Remove(filepath.Join(apacheSitesEnabled(), name+".conf"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// EnableApacheModules enables Apache modules with a2enmod. httpd on RHEL
+// loads the modules WebStack uses by default (conf.modules.d), mod_ssl
+// comes with the httpd installation.
+func EnableApacheModules(modules ...string) error {
+	if osinfo.Current().IsRHEL() {
+		return nil
+	}
+	return dryrun.Run(exec.Command("a2enmod", append([]string{"-q"}, modules...)...))
+}
+
+// apachePortsFile is where Apache's Listen directives live
+func apachePortsFile() string {
+	return filepath.Join(osinfo.Current().ApacheDir, "ports.conf")
+}
+
+// listen443 matches a Listen directive for port 443 (Listen 443, Listen 0.0.0.0:443 ssl)
+var listen443 = regexp.MustCompile(`(?m)^\s*Listen\s+(?:\S+:)?443\b`)
+
+// ensureApacheSSLPort makes Apache listen on 443 for standalone HTTPS vhosts.
+// ports.conf written by the installer already does; older or hand-edited
+// files get the directive appended.
+func ensureApacheSSLPort() error {
+	data, err := ioutil.ReadFile(apachePortsFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // Apache not installed, nothing to listen on
+		}
+		return fmt.Errorf("could not read %s: %v", apachePortsFile(), err)
+	}
+	if listen443.Match(data) {
+		return nil
+	}
+
+	content := strings.TrimRight(string(data), "\n") + `
+
+# HTTPS for standalone Apache (added by webstack)
+<IfModule ssl_module>
+    Listen 443 ssl
+</IfModule>
+`
+	if err := dryrun.WriteFile(apachePortsFile(), []byte(content), 0644); err != nil {
+		return fmt.Errorf("could not add Listen 443 to %s: %v", apachePortsFile(), err)
+	}
+	fmt.Printf("✅ Added Listen 443 to %s\n", apachePortsFile())
+	return nil
+}
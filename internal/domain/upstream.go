@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// parseUpstream validates the app URL of a proxy domain, e.g.
+// http://127.0.0.1:3000, and returns it without a trailing slash. A name
+// without a scheme refers to a pool created with 'webstack upstream create'.
+func parseUpstream(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if isPoolName(value) {
+		if _, err := GetPool(value); err != nil {
+			return "", err
+		}
+		return value, nil
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("%s (use a URL such as http://127.0.0.1:3000)", value)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("%s must start with http:// or https://", value)
+	}
+	// A path in proxy_pass rewrites the request URI, which apps don't expect
+	if strings.Trim(u.Path, "/") != "" || u.RawQuery != "" || u.User != nil {
+		return "", fmt.Errorf("%s must not have a path, query or credentials", value)
+	}
+	if strings.ContainsAny(u.Host, " \t;{}\"'$") {
+		return "", fmt.Errorf("%s contains characters not allowed in a vhost", value)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// upstreamVars adds the app URL of a proxy domain to the template variables.
+// Pools are proxied to by their nginx upstream name, rewriting the include
+// when it lacks the pool.
+func upstreamVars(d Domain, vars map[string]interface{}) error {
+	vars["Upstream"] = d.Upstream
+	vars["UpstreamTLS"] = strings.HasPrefix(d.Upstream, "https://")
+	vars["UpstreamPool"] = false
+	if d.Backend != "proxy" || !isPoolName(d.Upstream) {
+		return nil
+	}
+
+	pools, err := loadPools()
+	if err != nil {
+		return fmt.Errorf("could not load upstreams: %v", err)
+	}
+	if _, ok := findPool(pools, d.Upstream); !ok {
+		return fmt.Errorf("upstream %s of %s not found (see 'webstack upstream list')", d.Upstream, d.Name)
+	}
+	name := poolUpstream(d.Upstream)
+	content, _ := os.ReadFile(nginxUpstreamsConf)
+	if !strings.Contains(string(content), "upstream "+name+" {") {
+		if err := writePools(pools); err != nil {
+			return err
+		}
+	}
+	vars["Upstream"] = "http://" + name
+	vars["UpstreamPool"] = true
+	return nil
+}
@@ -0,0 +1,148 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"webstack-cli/internal/ui"
+)
+
+// Custom template variables are exposed to the vhost and preset templates as
+// .Vars, e.g. {{ index .Vars "APP_PORT" }} or {{ if .Vars.MAINTENANCE }}, so
+// they never shadow the built-in variables
+
+var varNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// validateVar checks that a custom variable can be safely rendered into
+// nginx and Apache configs
+func validateVar(key, value string) error {
+	if !varNamePattern.MatchString(key) {
+		return fmt.Errorf("name must start with a letter and only contain letters, digits and _: %s", key)
+	}
+	if strings.ContainsAny(value, "\r\n;{}\"'\\") {
+		return fmt.Errorf("value contains characters not allowed in a vhost: %s", value)
+	}
+	return nil
+}
+
+// SetVar sets a custom template variable of a domain and regenerates its
+// configuration
+func SetVar(domainName, key, value string) {
+	if err := validateVar(key, value); err != nil {
+		ui.Invalid("Invalid variable: %v\n", err)
+		return
+	}
+
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+	previous := copyVars(d.Vars)
+
+	if d.Vars == nil {
+		d.Vars = map[string]string{}
+	}
+	d.Vars[key] = value
+
+	if err := updateVars(*d, previous); err != nil {
+		ui.Fail("❌ Could not set variable: %v\n", err)
+		return
+	}
+
+	fmt.Printf("✅ %s = %s set for %s\n", key, value, domainName)
+}
+
+// UnsetVar removes a custom template variable of a domain and regenerates
+// its configuration
+func UnsetVar(domainName, key string) {
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+	if _, ok := d.Vars[key]; !ok {
+		ui.Fail("No variable %s set for %s\n", key, domainName)
+		return
+	}
+	previous := copyVars(d.Vars)
+
+	delete(d.Vars, key)
+	if len(d.Vars) == 0 {
+		d.Vars = nil
+	}
+
+	if err := updateVars(*d, previous); err != nil {
+		ui.Fail("❌ Could not remove variable: %v\n", err)
+		return
+	}
+
+	fmt.Printf("✅ %s removed for %s\n", key, domainName)
+}
+
+// ListVars displays the custom template variables of a domain
+func ListVars(domainName string) {
+	d, err := GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+
+	if len(d.Vars) == 0 {
+		fmt.Printf("No template variables set for %s\n", domainName)
+		return
+	}
+
+	keys := make([]string, 0, len(d.Vars))
+	for key := range d.Vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Printf("Template variables for %s:\n", domainName)
+	fmt.Println("===================")
+	for _, key := range keys {
+		fmt.Printf("  %s = %s\n", key, d.Vars[key])
+	}
+}
+
+// domainVars returns the custom variables passed to the templates, never nil
+// so templates can test .Vars.KEY on any domain
+func domainVars(d Domain) map[string]string {
+	if d.Vars == nil {
+		return map[string]string{}
+	}
+	return d.Vars
+}
+
+func copyVars(vars map[string]string) map[string]string {
+	if vars == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(vars))
+	for key, value := range vars {
+		copied[key] = value
+	}
+	return copied
+}
+
+// updateVars saves the domain and applies its configuration, restoring the
+// previous variables if the new configuration is rejected
+func updateVars(d Domain, previous map[string]string) error {
+	if err := saveDomain(d); err != nil {
+		return fmt.Errorf("could not save domain: %v", err)
+	}
+
+	if err := applyConfig(d, false); err != nil {
+		d.Vars = previous
+		if saveErr := saveDomain(d); saveErr != nil {
+			ui.Warn("⚠️  Warning: Could not restore variables: %v\n", saveErr)
+		}
+		return err
+	}
+
+	reloadWebServers()
+	smokeTest(d)
+	return nil
+}
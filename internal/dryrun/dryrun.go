@@ -0,0 +1,171 @@
+package dryrun
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var enabled bool
+
+// Enable turns on dry-run mode: commands, file writes and removals are
+// printed instead of being performed
+func Enable() {
+	enabled = true
+}
+
+// Enabled reports whether dry-run mode is active
+func Enabled() bool {
+	return enabled
+}
+
+func report(format string, args ...interface{}) {
+	fmt.Printf("🔎 [dry-run] "+format+"\n", args...)
+}
+
+// Run runs cmd, or prints it in dry-run mode
+func Run(cmd *exec.Cmd) error {
+	if enabled {
+		report("would run: %s", Describe(cmd))
+		return nil
+	}
+	start := time.Now()
+	err := cmd.Run()
+	logCommand(cmd, start, err)
+	return err
+}
+
+// CombinedOutput runs cmd and returns its output, or prints it in dry-run mode
+func CombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	if enabled {
+		report("would run: %s", Describe(cmd))
+		return nil, nil
+	}
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	logCommand(cmd, start, err)
+	return output, err
+}
+
+// logCommand records an executed command in the debug log
+func logCommand(cmd *exec.Cmd, start time.Time, err error) {
+	if err != nil {
+		slog.Debug("command failed", "cmd", Describe(cmd), "duration", time.Since(start).Round(time.Millisecond).String(), "error", err)
+		return
+	}
+	slog.Debug("command executed", "cmd", Describe(cmd), "duration", time.Since(start).Round(time.Millisecond).String())
+}
+
+// logFile records a file change in the debug log
+func logFile(op, path string, err error, attrs ...interface{}) {
+	attrs = append([]interface{}{"op", op, "path", path}, attrs...)
+	if err != nil {
+		slog.Debug("file change failed", append(attrs, "error", err)...)
+		return
+	}
+	slog.Debug("file changed", attrs...)
+}
+
+// WriteFile writes data to path, or prints the write in dry-run mode
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	if enabled {
+		report("would write %s (%d bytes, mode %04o)", path, len(data), perm)
+		return nil
+	}
+	err := ioutil.WriteFile(path, data, perm)
+	logFile("write", path, err, "bytes", len(data))
+	return err
+}
+
+// MkdirAll creates path, or prints it in dry-run mode
+func MkdirAll(path string, perm os.FileMode) error {
+	if enabled {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			report("would create directory %s", path)
+		}
+		return nil
+	}
+	return os.MkdirAll(path, perm)
+}
+
+// Remove removes path, or prints it in dry-run mode
+func Remove(path string) error {
+	if enabled {
+		if _, err := os.Lstat(path); err == nil {
+			report("would remove %s", path)
+		}
+		return nil
+	}
+	err := os.Remove(path)
+	logFile("remove", path, err)
+	return err
+}
+
+// Rename moves oldpath to newpath, or prints it in dry-run mode
+func Rename(oldpath, newpath string) error {
+	if enabled {
+		report("would rename %s to %s", oldpath, newpath)
+		return nil
+	}
+	err := os.Rename(oldpath, newpath)
+	logFile("rename", newpath, err, "from", oldpath)
+	return err
+}
+
+// RemoveAll removes path and everything below it, or prints it in dry-run mode
+func RemoveAll(path string) error {
+	if enabled {
+		if _, err := os.Lstat(path); err == nil {
+			report("would remove %s (recursively)", path)
+		}
+		return nil
+	}
+	err := os.RemoveAll(path)
+	logFile("remove recursively", path, err)
+	return err
+}
+
+// Symlink creates newname as a symlink to oldname, or prints it in dry-run mode
+func Symlink(oldname, newname string) error {
+	if enabled {
+		report("would link %s -> %s", newname, oldname)
+		return nil
+	}
+	err := os.Symlink(oldname, newname)
+	logFile("symlink", newname, err, "target", oldname)
+	return err
+}
+
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(IDENTIFIED\s+BY\s+)'[^']*'`),
+	regexp.MustCompile(`(?i)(PASSWORD\s+)'[^']*'`),
+}
+
+// Describe returns cmd as a shell-like command line with passwords masked
+func Describe(cmd *exec.Cmd) string {
+	parts := make([]string, 0, len(cmd.Args))
+	for i, arg := range cmd.Args {
+		if i > 0 && strings.HasPrefix(arg, "-p") && len(arg) > 2 && cmd.Args[0] == "mysql" {
+			arg = "-p****"
+		}
+		for _, re := range secretPatterns {
+			arg = re.ReplaceAllString(arg, "${1}'****'")
+		}
+		if arg == "" || strings.ContainsAny(arg, " \t\"'$*;|&<>()") {
+			arg = strconv.Quote(arg)
+		}
+		parts = append(parts, arg)
+	}
+
+	line := strings.Join(parts, " ")
+	if cmd.Stdin != nil {
+		line += " (with input)"
+	}
+	return line
+}
@@ -0,0 +1,107 @@
+package firewall
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"webstack-cli/internal/config"
+)
+
+// Firewall is a firewall tool the rules are applied with. Implementations
+// manage IPv4 and IPv6 together.
+type Firewall interface {
+	// Name is the backend name used in config.json ("iptables", "nftables", "ufw", "firewalld")
+	Name() string
+	// Available reports whether the tool is installed on this host
+	Available() bool
+	// Allow opens a port; an already open port is left as is
+	Allow(port int, proto string) error
+	// Remove deletes the rule opening a port
+	Remove(port int, proto string) error
+	// Allowed reports whether the rule opening a port is active
+	Allowed(port int, proto string) bool
+	// EnsureCore adds the rules every server needs to stay reachable
+	// (loopback, established connections, SSH) and returns how many were added
+	EnsureCore() int
+	// Block drops all traffic from an address
+	Block(ip string) error
+	// Unblock lifts a block
+	Unblock(ip string) error
+	// Blocked returns the blocked addresses
+	Blocked() ([]string, error)
+	// Flush removes every input rule except the core ones
+	Flush() error
+	// Reset drops incoming traffic by default, keeping only the core rules
+	Reset() error
+	// Ruleset returns the live rules as printed by the tool
+	Ruleset() (string, error)
+	// Persist saves the live rules so they survive a reboot
+	Persist()
+}
+
+// BackendKey is the config.json default selecting the firewall backend
+const BackendKey = "firewall_backend"
+
+// Backends lists the supported backends in detection order
+var Backends = []string{"ufw", "firewalld", "iptables", "nftables"}
+
+// errNoFirewall is returned when no supported firewall tool is installed
+var errNoFirewall = fmt.Errorf("no firewall tool found (install iptables, nftables, ufw or firewalld)")
+
+// New returns the backend of the given name, or nil for an unknown name
+func New(name string) Firewall {
+	switch name {
+	case "iptables":
+		return iptablesFirewall{}
+	case "nftables":
+		return nftablesFirewall{}
+	case "ufw":
+		return ufwFirewall{}
+	case "firewalld":
+		return firewalldFirewall{}
+	}
+	return nil
+}
+
+// Current returns the backend set with 'webstack config set firewall_backend',
+// or the one detected on this host when it is "auto" or unset
+func Current() Firewall {
+	if cfg, err := config.Load(); err == nil && cfg != nil {
+		if name, ok := cfg.GetDefault(BackendKey, "auto").(string); ok && name != "auto" {
+			if fw := New(name); fw != nil {
+				return fw
+			}
+		}
+	}
+	return Detect()
+}
+
+// Detect picks the backend managing this host's firewall: ufw or firewalld
+// when it is enabled, then iptables (which also drives nftables through
+// iptables-nft), then plain nftables. When none is installed the iptables backend is
+// returned and reports itself unavailable.
+func Detect() Firewall {
+	if fw := (ufwFirewall{}); fw.Available() && fw.active() {
+		return fw
+	}
+	if fw := (firewalldFirewall{}); fw.Available() && fw.active() {
+		return fw
+	}
+	for _, name := range []string{"iptables", "nftables"} {
+		if fw := New(name); fw.Available() {
+			return fw
+		}
+	}
+	return iptablesFirewall{}
+}
+
+// installed reports whether a binary is on the PATH
+func installed(binary string) bool {
+	_, err := exec.LookPath(binary)
+	return err == nil
+}
+
+// isIPv6 reports whether an address or network is IPv6
+func isIPv6(ip string) bool {
+	return strings.Contains(ip, ":")
+}
@@ -0,0 +1,250 @@
+package firewall
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"webstack-cli/internal/dryrun"
+)
+
+// rulesFile is the registry of the ports opened by webstack
+const rulesFile = "/etc/webstack/firewall.json"
+
+// Manual labels ports opened with 'webstack firewall open'
+const Manual = "manual"
+
+// Rule is an open port in the registry and the components that need it.
+// A port shared by several components (80/443 for Nginx and Apache) stays
+// open until the last of them closes it.
+type Rule struct {
+	Port       int      `json:"port"`
+	Protocol   string   `json:"protocol"` // "tcp" or "udp"
+	Components []string `json:"components"`
+}
+
+// RuleStatus is a registry rule with its state in the live firewall
+type RuleStatus struct {
+	Rule
+	Active bool // ACCEPT rule present in the live firewall
+}
+
+// Protocols expands "tcp", "udp" or "both" into the protocols to apply
+func Protocols(protocol string) ([]string, error) {
+	switch protocol {
+	case "tcp", "udp":
+		return []string{protocol}, nil
+	case "both", "":
+		return []string{"tcp", "udp"}, nil
+	}
+	return nil, fmt.Errorf("invalid protocol %q (use tcp, udp or both)", protocol)
+}
+
+// Open opens ports for a component and records them in the registry.
+// Opening a port that is already open only adds the component label.
+func Open(component, protocol string, ports ...int) error {
+	protocols, err := Protocols(protocol)
+	if err != nil {
+		return err
+	}
+
+	rules, err := load()
+	if err != nil {
+		return err
+	}
+
+	// The ports are recorded even without a firewall tool, so 'firewall
+	// rebuild' can apply them once one is installed
+	fw := Current()
+	for _, port := range ports {
+		for _, proto := range protocols {
+			rules = addComponent(rules, port, proto, component)
+		}
+	}
+	if err := save(rules); err != nil {
+		return err
+	}
+	if !fw.Available() {
+		return fmt.Errorf("%v; the port is recorded and opened by 'webstack firewall rebuild' once one is installed", errNoFirewall)
+	}
+
+	for _, port := range ports {
+		for _, proto := range protocols {
+			if err := fw.Allow(port, proto); err != nil {
+				return err
+			}
+		}
+	}
+	fw.Persist()
+	return nil
+}
+
+// Close removes a component's claim on ports. A port is only closed in the
+// firewall when no other component still needs it; force closes it anyway.
+// It returns the ports kept open for other components.
+func Close(component, protocol string, force bool, ports ...int) ([]Rule, error) {
+	protocols, err := Protocols(protocol)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	fw := Current()
+	var kept []Rule
+	for _, port := range ports {
+		for _, proto := range protocols {
+			var remaining []string
+			rules, remaining = removeComponent(rules, port, proto, component, force)
+			if len(remaining) > 0 {
+				kept = append(kept, Rule{Port: port, Protocol: proto, Components: remaining})
+				continue
+			}
+			if fw.Available() {
+				if err := fw.Remove(port, proto); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if err := save(rules); err != nil {
+		return nil, err
+	}
+	fw.Persist()
+	return kept, nil
+}
+
+// Rules returns the registry with the live state of every rule
+func Rules() ([]RuleStatus, error) {
+	rules, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	fw := Current()
+	var statuses []RuleStatus
+	for _, r := range rules {
+		statuses = append(statuses, RuleStatus{
+			Rule:   r,
+			Active: fw.Available() && fw.Allowed(r.Port, r.Protocol),
+		})
+	}
+	return statuses, nil
+}
+
+// Rebuild re-applies the core rules (loopback, established connections, SSH)
+// and every registry rule missing from the live firewall, e.g. after a
+// flush or a reboot without a saved ruleset. It returns how many rules
+// were added.
+func Rebuild() (int, error) {
+	rules, err := load()
+	if err != nil {
+		return 0, err
+	}
+
+	fw := Current()
+	if !fw.Available() {
+		return 0, errNoFirewall
+	}
+
+	added := fw.EnsureCore()
+	for _, r := range rules {
+		if fw.Allowed(r.Port, r.Protocol) {
+			continue
+		}
+		if err := fw.Allow(r.Port, r.Protocol); err != nil {
+			return added, err
+		}
+		added++
+	}
+
+	fw.Persist()
+	return added, nil
+}
+
+// Persist saves the live rules of the current backend so they survive a reboot
+func Persist() {
+	Current().Persist()
+}
+
+func addComponent(rules []Rule, port int, proto, component string) []Rule {
+	for i, r := range rules {
+		if r.Port != port || r.Protocol != proto {
+			continue
+		}
+		for _, c := range r.Components {
+			if c == component {
+				return rules
+			}
+		}
+		rules[i].Components = append(r.Components, component)
+		sort.Strings(rules[i].Components)
+		return rules
+	}
+	return append(rules, Rule{Port: port, Protocol: proto, Components: []string{component}})
+}
+
+// removeComponent drops a component from a rule and returns the components
+// still holding it; the rule is removed when none are left or force is set
+func removeComponent(rules []Rule, port int, proto, component string, force bool) ([]Rule, []string) {
+	for i, r := range rules {
+		if r.Port != port || r.Protocol != proto {
+			continue
+		}
+		var remaining []string
+		for _, c := range r.Components {
+			if c != component {
+				remaining = append(remaining, c)
+			}
+		}
+		if len(remaining) == 0 || force {
+			return append(rules[:i], rules[i+1:]...), nil
+		}
+		rules[i].Components = remaining
+		return rules, remaining
+	}
+	return rules, nil
+}
+
+func load() ([]Rule, error) {
+	data, err := ioutil.ReadFile(rulesFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", rulesFile, err)
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %v", rulesFile, err)
+	}
+	return rules, nil
+}
+
+func save(rules []Rule) error {
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Port != rules[j].Port {
+			return rules[i].Port < rules[j].Port
+		}
+		return rules[i].Protocol < rules[j].Protocol
+	})
+
+	data, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := dryrun.MkdirAll(filepath.Dir(rulesFile), 0755); err != nil {
+		return err
+	}
+	if err := dryrun.WriteFile(rulesFile, data, 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", rulesFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package firewall
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"webstack-cli/internal/dryrun"
+)
+
+// firewalldSource matches the blocked address of a rich rule
+var firewalldSource = regexp.MustCompile(`source address="([^"]+)" drop`)
+
+// firewalldFirewall applies rules with firewall-cmd to the default zone,
+// the firewall of RHEL, AlmaLinux and Rocky Linux. Changes go to the
+// runtime configuration and Persist makes them permanent.
+type firewalldFirewall struct{}
+
+func (firewalldFirewall) Name() string { return "firewalld" }
+
+func (firewalldFirewall) Available() bool {
+	return installed("firewall-cmd")
+}
+
+// active reports whether firewalld is running
+func (firewalldFirewall) active() bool {
+	return exec.Command("firewall-cmd", "--state").Run() == nil
+}
+
+func (fw firewalldFirewall) Allow(port int, proto string) error {
+	if fw.Allowed(port, proto) {
+		return nil
+	}
+	if err := dryrun.Run(exec.Command("firewall-cmd", "--add-port="+ufwPort(port, proto))); err != nil {
+		return fmt.Errorf("firewalld: could not open %d/%s: %v", port, proto, err)
+	}
+	return nil
+}
+
+func (firewalldFirewall) Remove(port int, proto string) error {
+	if err := dryrun.Run(exec.Command("firewall-cmd", "--remove-port="+ufwPort(port, proto))); err != nil {
+		return fmt.Errorf("firewalld: could not close %d/%s: %v", port, proto, err)
+	}
+	return nil
+}
+
+func (firewalldFirewall) Allowed(port int, proto string) bool {
+	return exec.Command("firewall-cmd", "--query-port="+ufwPort(port, proto)).Run() == nil
+}
+
+// EnsureCore opens SSH; firewalld accepts loopback and established
+// connections itself
+func (firewalldFirewall) EnsureCore() int {
+	if exec.Command("firewall-cmd", "--query-service=ssh").Run() == nil {
+		return 0
+	}
+	if dryrun.Run(exec.Command("firewall-cmd", "--add-service=ssh")) != nil {
+		return 0
+	}
+	return 1
+}
+
+func (firewalldFirewall) Block(ip string) error {
+	if err := dryrun.Run(exec.Command("firewall-cmd", "--add-rich-rule="+blockRule(ip))); err != nil {
+		return fmt.Errorf("firewalld: could not block %s: %v", ip, err)
+	}
+	return nil
+}
+
+func (firewalldFirewall) Unblock(ip string) error {
+	if err := dryrun.Run(exec.Command("firewall-cmd", "--remove-rich-rule="+blockRule(ip))); err != nil {
+		return fmt.Errorf("firewalld: could not unblock %s: %v", ip, err)
+	}
+	return nil
+}
+
+func (firewalldFirewall) Blocked() ([]string, error) {
+	output, err := exec.Command("firewall-cmd", "--list-rich-rules").Output()
+	if err != nil {
+		return nil, fmt.Errorf("firewalld: %v", err)
+	}
+	var ips []string
+	for _, m := range firewalldSource.FindAllStringSubmatch(string(output), -1) {
+		ips = append(ips, m[1])
+	}
+	return ips, nil
+}
+
+// Flush closes every port and service except SSH
+func (fw firewalldFirewall) Flush() error {
+	output, err := exec.Command("firewall-cmd", "--list-ports").Output()
+	if err != nil {
+		return fmt.Errorf("firewalld: %v", err)
+	}
+	for _, port := range strings.Fields(string(output)) {
+		if err := dryrun.Run(exec.Command("firewall-cmd", "--remove-port="+port)); err != nil {
+			return fmt.Errorf("firewalld: could not close %s: %v", port, err)
+		}
+	}
+	output, _ = exec.Command("firewall-cmd", "--list-services").Output()
+	for _, service := range strings.Fields(string(output)) {
+		if service != "ssh" {
+			dryrun.Run(exec.Command("firewall-cmd", "--remove-service="+service))
+		}
+	}
+	fw.EnsureCore()
+	return nil
+}
+
+// Reset keeps only SSH open and lifts the blocks; the default zone
+// rejects everything else
+func (fw firewalldFirewall) Reset() error {
+	dryrun.Run(exec.Command("systemctl", "enable", "--now", "firewalld"))
+	if err := fw.Flush(); err != nil {
+		return err
+	}
+	blocked, _ := fw.Blocked()
+	for _, ip := range blocked {
+		fw.Unblock(ip)
+	}
+	return nil
+}
+
+func (firewalldFirewall) Ruleset() (string, error) {
+	output, err := exec.Command("firewall-cmd", "--list-all").Output()
+	if err != nil {
+		return "", fmt.Errorf("firewalld: %v", err)
+	}
+	return string(output), nil
+}
+
+// Persist makes the runtime configuration permanent
+func (firewalldFirewall) Persist() {
+	dryrun.Run(exec.Command("firewall-cmd", "--runtime-to-permanent"))
+}
+
+// blockRule is the rich rule dropping traffic from an address
+func blockRule(ip string) string {
+	family := "ipv4"
+	if isIPv6(ip) {
+		family = "ipv6"
+	}
+	return fmt.Sprintf(`rule family="%s" source address="%s" drop`, family, ip)
+}
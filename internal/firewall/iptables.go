@@ -0,0 +1,191 @@
+package firewall
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"webstack-cli/internal/dryrun"
+)
+
+// blockSet is the ipset holding the addresses blocked with iptables
+const blockSet = "banned_ips"
+
+// iptablesFirewall applies rules with iptables and ip6tables, and blocks
+// addresses through an ipset. ip6tables is optional on IPv4-only hosts.
+type iptablesFirewall struct{}
+
+// binaries are the tools rules are applied with
+var binaries = []string{"iptables", "ip6tables"}
+
+// coreRules keep the server reachable whatever else is flushed
+var coreRules = [][]string{
+	{"-i", "lo", "-j", "ACCEPT"},
+	{"-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "ACCEPT"},
+	{"-p", "tcp", "--dport", "22", "-j", "ACCEPT"},
+}
+
+func (iptablesFirewall) Name() string { return "iptables" }
+
+func (iptablesFirewall) Available() bool {
+	return installed("iptables")
+}
+
+// Allow adds the ACCEPT rule for a port unless it is already present
+func (iptablesFirewall) Allow(port int, proto string) error {
+	for _, binary := range available() {
+		if exists(binary, port, proto) {
+			continue
+		}
+		if err := dryrun.Run(exec.Command(binary, ruleArgs("-A", port, proto)...)); err != nil {
+			return fmt.Errorf("%s: could not open %d/%s: %v", binary, port, proto, err)
+		}
+	}
+	return nil
+}
+
+// Remove deletes every copy of the ACCEPT rule for a port; older versions
+// appended duplicates on each install
+func (iptablesFirewall) Remove(port int, proto string) error {
+	for _, binary := range available() {
+		for i := 0; i < 10 && exists(binary, port, proto); i++ {
+			if err := dryrun.Run(exec.Command(binary, ruleArgs("-D", port, proto)...)); err != nil {
+				return fmt.Errorf("%s: could not close %d/%s: %v", binary, port, proto, err)
+			}
+			if dryrun.Enabled() {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// Allowed reports whether the IPv4 rule is present; IPv6 follows it
+func (iptablesFirewall) Allowed(port int, proto string) bool {
+	return exists("iptables", port, proto)
+}
+
+func (iptablesFirewall) EnsureCore() int {
+	added := 0
+	for _, binary := range available() {
+		for _, rule := range coreRules {
+			if exec.Command(binary, append([]string{"-C", "INPUT"}, rule...)...).Run() == nil {
+				continue
+			}
+			if dryrun.Run(exec.Command(binary, append([]string{"-A", "INPUT"}, rule...)...)) == nil {
+				added++
+			}
+		}
+	}
+	return added
+}
+
+func (iptablesFirewall) Block(ip string) error {
+	dryrun.Run(exec.Command("ipset", "create", blockSet, "hash:ip", "-exist"))
+	if err := dryrun.Run(exec.Command("ipset", "add", blockSet, ip, "-exist")); err != nil {
+		return fmt.Errorf("could not add %s to the %s ipset: %v", ip, blockSet, err)
+	}
+
+	drop := []string{"INPUT", "-m", "set", "--match-set", blockSet, "src", "-j", "DROP"}
+	for _, binary := range available() {
+		if exec.Command(binary, append([]string{"-C"}, drop...)...).Run() == nil {
+			continue
+		}
+		dryrun.Run(exec.Command(binary, append([]string{"-I"}, drop...)...))
+	}
+	return nil
+}
+
+func (iptablesFirewall) Unblock(ip string) error {
+	if err := dryrun.Run(exec.Command("ipset", "del", blockSet, ip)); err != nil {
+		return fmt.Errorf("could not remove %s from the %s ipset: %v", ip, blockSet, err)
+	}
+	return nil
+}
+
+// Blocked returns the members of the ipset
+func (iptablesFirewall) Blocked() ([]string, error) {
+	output, err := exec.Command("ipset", "list", blockSet).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ipset %s not available", blockSet)
+	}
+
+	var ips []string
+	members := false
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "Members:" {
+			members = true
+			continue
+		}
+		if members && line != "" {
+			ips = append(ips, strings.Fields(line)[0])
+		}
+	}
+	return ips, nil
+}
+
+func (fw iptablesFirewall) Flush() error {
+	for _, binary := range available() {
+		if err := dryrun.Run(exec.Command(binary, "-F", "INPUT")); err != nil {
+			return fmt.Errorf("%s: could not flush INPUT: %v", binary, err)
+		}
+	}
+	fw.EnsureCore()
+	return nil
+}
+
+func (fw iptablesFirewall) Reset() error {
+	for _, binary := range available() {
+		if err := dryrun.Run(exec.Command(binary, "-F")); err != nil {
+			return fmt.Errorf("%s: could not flush rules: %v", binary, err)
+		}
+	}
+	// Core rules go in before the DROP policy so SSH is never cut
+	fw.EnsureCore()
+	for _, binary := range available() {
+		dryrun.Run(exec.Command(binary, "-P", "INPUT", "DROP"))
+		dryrun.Run(exec.Command(binary, "-P", "FORWARD", "DROP"))
+		dryrun.Run(exec.Command(binary, "-P", "OUTPUT", "ACCEPT"))
+	}
+	return nil
+}
+
+func (iptablesFirewall) Ruleset() (string, error) {
+	var out strings.Builder
+	for _, binary := range available() {
+		output, err := exec.Command(binary, "-L", "-n", "-v").Output()
+		if err != nil {
+			return out.String(), fmt.Errorf("%s: %v", binary, err)
+		}
+		fmt.Fprintf(&out, "# %s\n%s\n", binary, output)
+	}
+	return out.String(), nil
+}
+
+// Persist saves the live rules for iptables-persistent
+func (iptablesFirewall) Persist() {
+	dryrun.Run(exec.Command("bash", "-c", "iptables-save > /etc/iptables/rules.v4 2>/dev/null || true"))
+	dryrun.Run(exec.Command("bash", "-c", "ip6tables-save > /etc/iptables/rules.v6 2>/dev/null || true"))
+	dryrun.Run(exec.Command("bash", "-c", "ipset save > /etc/iptables/ipset.rules 2>/dev/null || true"))
+}
+
+func ruleArgs(action string, port int, proto string) []string {
+	return []string{action, "INPUT", "-p", proto, "--dport", strconv.Itoa(port), "-j", "ACCEPT"}
+}
+
+// exists reports whether the ACCEPT rule for a port is in the live firewall
+func exists(binary string, port int, proto string) bool {
+	return exec.Command(binary, ruleArgs("-C", port, proto)...).Run() == nil
+}
+
+// available returns the iptables tools installed on this host
+func available() []string {
+	var found []string
+	for _, binary := range binaries {
+		if installed(binary) {
+			found = append(found, binary)
+		}
+	}
+	return found
+}
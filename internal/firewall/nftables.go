@@ -0,0 +1,237 @@
+package firewall
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"webstack-cli/internal/dryrun"
+)
+
+// nftables rules live in their own inet table (IPv4 and IPv6 together), so
+// the distribution's ruleset is left alone
+const (
+	nftFamily = "inet"
+	nftTable  = "webstack"
+	nftChain  = "input"
+)
+
+// nftablesConf is loaded by nftables.service at boot
+const nftablesConf = "/etc/nftables.conf"
+
+// nftHandle extracts the handle nft -a appends to a rule
+var nftHandle = regexp.MustCompile(`# handle (\d+)$`)
+
+// nftablesFirewall applies rules with nft
+type nftablesFirewall struct{}
+
+// nftCoreRules keep the server reachable whatever else is flushed
+var nftCoreRules = []string{
+	`iifname "lo" accept`,
+	"ct state established,related accept",
+	"tcp dport 22 accept",
+}
+
+func (nftablesFirewall) Name() string { return "nftables" }
+
+func (nftablesFirewall) Available() bool {
+	return installed("nft")
+}
+
+func (fw nftablesFirewall) Allow(port int, proto string) error {
+	if err := fw.ensureTable("accept"); err != nil {
+		return err
+	}
+	rule := nftPortRule(port, proto)
+	if fw.hasRule(rule) {
+		return nil
+	}
+	if err := fw.addRule(rule); err != nil {
+		return fmt.Errorf("nft: could not open %d/%s: %v", port, proto, err)
+	}
+	return nil
+}
+
+func (fw nftablesFirewall) Remove(port int, proto string) error {
+	for _, handle := range fw.handles(nftPortRule(port, proto)) {
+		if err := dryrun.Run(exec.Command("nft", "delete", "rule", nftFamily, nftTable, nftChain, "handle", handle)); err != nil {
+			return fmt.Errorf("nft: could not close %d/%s: %v", port, proto, err)
+		}
+	}
+	return nil
+}
+
+func (fw nftablesFirewall) Allowed(port int, proto string) bool {
+	return fw.hasRule(nftPortRule(port, proto))
+}
+
+func (fw nftablesFirewall) EnsureCore() int {
+	if fw.ensureTable("accept") != nil {
+		return 0
+	}
+	added := 0
+	for _, rule := range nftCoreRules {
+		if fw.hasRule(rule) {
+			continue
+		}
+		if fw.addRule(rule) == nil {
+			added++
+		}
+	}
+	return added
+}
+
+func (fw nftablesFirewall) Block(ip string) error {
+	if err := fw.ensureTable("accept"); err != nil {
+		return err
+	}
+	set := nftBlockSet(ip)
+	if err := dryrun.Run(exec.Command("nft", "add", "element", nftFamily, nftTable, set, "{ "+ip+" }")); err != nil {
+		return fmt.Errorf("nft: could not block %s: %v", ip, err)
+	}
+	return nil
+}
+
+func (nftablesFirewall) Unblock(ip string) error {
+	set := nftBlockSet(ip)
+	if err := dryrun.Run(exec.Command("nft", "delete", "element", nftFamily, nftTable, set, "{ "+ip+" }")); err != nil {
+		return fmt.Errorf("nft: could not unblock %s: %v", ip, err)
+	}
+	return nil
+}
+
+// Blocked returns the elements of both block sets
+func (nftablesFirewall) Blocked() ([]string, error) {
+	var ips []string
+	for _, set := range []string{"banned_ips", "banned_ips6"} {
+		output, err := exec.Command("nft", "list", "set", nftFamily, nftTable, set).Output()
+		if err != nil {
+			continue
+		}
+		text := string(output)
+		start := strings.Index(text, "elements = {")
+		if start < 0 {
+			continue
+		}
+		text = text[start+len("elements = {"):]
+		if end := strings.Index(text, "}"); end >= 0 {
+			text = text[:end]
+		}
+		for _, ip := range strings.Split(text, ",") {
+			if ip = strings.TrimSpace(ip); ip != "" {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips, nil
+}
+
+func (fw nftablesFirewall) Flush() error {
+	if err := fw.ensureTable("accept"); err != nil {
+		return err
+	}
+	if err := dryrun.Run(exec.Command("nft", "flush", "chain", nftFamily, nftTable, nftChain)); err != nil {
+		return fmt.Errorf("nft: could not flush %s: %v", nftChain, err)
+	}
+	fw.addBlockRules()
+	fw.EnsureCore()
+	return nil
+}
+
+func (fw nftablesFirewall) Reset() error {
+	dryrun.Run(exec.Command("nft", "delete", "table", nftFamily, nftTable))
+	if err := fw.ensureTable("drop"); err != nil {
+		return err
+	}
+	fw.EnsureCore()
+	return nil
+}
+
+func (nftablesFirewall) Ruleset() (string, error) {
+	output, err := exec.Command("nft", "list", "ruleset").Output()
+	if err != nil {
+		return "", fmt.Errorf("nft: %v", err)
+	}
+	return string(output), nil
+}
+
+// Persist writes the live ruleset to /etc/nftables.conf
+func (nftablesFirewall) Persist() {
+	dryrun.Run(exec.Command("bash", "-c",
+		"{ echo '#!/usr/sbin/nft -f'; echo 'flush ruleset'; nft list ruleset; } > "+nftablesConf+".tmp && mv "+nftablesConf+".tmp "+nftablesConf))
+}
+
+// ensureTable creates the webstack table, its input chain with the given
+// policy and the block sets. Existing objects are left as they are, so the
+// policy only applies to a new chain.
+func (fw nftablesFirewall) ensureTable(policy string) error {
+	if exec.Command("nft", "list", "chain", nftFamily, nftTable, nftChain).Run() == nil {
+		return nil
+	}
+
+	commands := [][]string{
+		{"add", "table", nftFamily, nftTable},
+		{"add", "chain", nftFamily, nftTable, nftChain, "{ type filter hook input priority 0; policy " + policy + "; }"},
+		{"add", "set", nftFamily, nftTable, "banned_ips", "{ type ipv4_addr; flags interval; }"},
+		{"add", "set", nftFamily, nftTable, "banned_ips6", "{ type ipv6_addr; flags interval; }"},
+	}
+	for _, args := range commands {
+		if err := dryrun.Run(exec.Command("nft", args...)); err != nil {
+			return fmt.Errorf("nft: could not create table %s %s: %v", nftFamily, nftTable, err)
+		}
+	}
+	fw.addBlockRules()
+	return nil
+}
+
+// addBlockRules drops traffic from the block sets ahead of every other rule
+func (fw nftablesFirewall) addBlockRules() {
+	for _, rule := range []string{"ip saddr @banned_ips drop", "ip6 saddr @banned_ips6 drop"} {
+		if !fw.hasRule(rule) {
+			dryrun.Run(exec.Command("nft", append([]string{"insert", "rule", nftFamily, nftTable, nftChain}, strings.Fields(rule)...)...))
+		}
+	}
+}
+
+func (nftablesFirewall) addRule(rule string) error {
+	return dryrun.Run(exec.Command("nft", append([]string{"add", "rule", nftFamily, nftTable, nftChain}, strings.Fields(rule)...)...))
+}
+
+// hasRule reports whether the input chain holds a rule
+func (fw nftablesFirewall) hasRule(rule string) bool {
+	return len(fw.handles(rule)) > 0
+}
+
+// handles returns the handles of every copy of a rule in the input chain
+func (nftablesFirewall) handles(rule string) []string {
+	output, err := exec.Command("nft", "-a", "list", "chain", nftFamily, nftTable, nftChain).Output()
+	if err != nil {
+		return nil
+	}
+
+	var handles []string
+	for _, line := range strings.Split(string(output), "\n") {
+		m := nftHandle.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		body := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), m[0]))
+		if body == rule {
+			handles = append(handles, m[1])
+		}
+	}
+	return handles
+}
+
+// nftPortRule is the rule opening a port, as nft lists it
+func nftPortRule(port int, proto string) string {
+	return fmt.Sprintf("%s dport %d accept", proto, port)
+}
+
+// nftBlockSet returns the set a blocked address goes in
+func nftBlockSet(ip string) string {
+	if isIPv6(ip) {
+		return "banned_ips6"
+	}
+	return "banned_ips"
+}
@@ -0,0 +1,141 @@
+package firewall
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"webstack-cli/internal/dryrun"
+)
+
+// ufwFirewall applies rules with ufw, which handles loopback, established
+// connections and both IP families itself and saves every change
+type ufwFirewall struct{}
+
+func (ufwFirewall) Name() string { return "ufw" }
+
+func (ufwFirewall) Available() bool {
+	return installed("ufw")
+}
+
+// active reports whether ufw is enabled
+func (ufwFirewall) active() bool {
+	output, err := exec.Command("ufw", "status").Output()
+	return err == nil && strings.Contains(string(output), "Status: active")
+}
+
+func (fw ufwFirewall) Allow(port int, proto string) error {
+	if fw.Allowed(port, proto) {
+		return nil
+	}
+	if err := dryrun.Run(exec.Command("ufw", "allow", ufwPort(port, proto))); err != nil {
+		return fmt.Errorf("ufw: could not open %d/%s: %v", port, proto, err)
+	}
+	return nil
+}
+
+func (ufwFirewall) Remove(port int, proto string) error {
+	if err := dryrun.Run(exec.Command("ufw", "delete", "allow", ufwPort(port, proto))); err != nil {
+		return fmt.Errorf("ufw: could not close %d/%s: %v", port, proto, err)
+	}
+	return nil
+}
+
+// Allowed looks the rule up in the added rules, which ufw lists even while
+// it is disabled
+func (fw ufwFirewall) Allowed(port int, proto string) bool {
+	return fw.hasRule("allow " + ufwPort(port, proto))
+}
+
+func (fw ufwFirewall) EnsureCore() int {
+	if fw.hasRule("allow 22/tcp") || fw.hasRule("allow OpenSSH") {
+		return 0
+	}
+	if dryrun.Run(exec.Command("ufw", "allow", "22/tcp")) != nil {
+		return 0
+	}
+	return 1
+}
+
+// Block inserts the deny rule first, ufw matches rules in order
+func (ufwFirewall) Block(ip string) error {
+	if err := dryrun.Run(exec.Command("ufw", "insert", "1", "deny", "from", ip)); err != nil {
+		return fmt.Errorf("ufw: could not block %s: %v", ip, err)
+	}
+	return nil
+}
+
+func (ufwFirewall) Unblock(ip string) error {
+	if err := dryrun.Run(exec.Command("ufw", "delete", "deny", "from", ip)); err != nil {
+		return fmt.Errorf("ufw: could not unblock %s: %v", ip, err)
+	}
+	return nil
+}
+
+func (fw ufwFirewall) Blocked() ([]string, error) {
+	var ips []string
+	for _, rule := range fw.added() {
+		if strings.HasPrefix(rule, "deny from ") {
+			ips = append(ips, strings.Fields(rule)[2])
+		}
+	}
+	return ips, nil
+}
+
+func (fw ufwFirewall) Flush() error {
+	return fw.Reset()
+}
+
+// Reset restores ufw's defaults (deny incoming, allow outgoing) with SSH open
+func (fw ufwFirewall) Reset() error {
+	if err := dryrun.Run(exec.Command("ufw", "--force", "reset")); err != nil {
+		return fmt.Errorf("ufw: could not reset: %v", err)
+	}
+	dryrun.Run(exec.Command("ufw", "default", "deny", "incoming"))
+	dryrun.Run(exec.Command("ufw", "default", "allow", "outgoing"))
+	fw.EnsureCore()
+	if err := dryrun.Run(exec.Command("ufw", "--force", "enable")); err != nil {
+		return fmt.Errorf("ufw: could not enable: %v", err)
+	}
+	return nil
+}
+
+func (ufwFirewall) Ruleset() (string, error) {
+	output, err := exec.Command("ufw", "status", "verbose").Output()
+	if err != nil {
+		return "", fmt.Errorf("ufw: %v", err)
+	}
+	return string(output), nil
+}
+
+// Persist does nothing, ufw saves its rules itself
+func (ufwFirewall) Persist() {}
+
+func (fw ufwFirewall) hasRule(rule string) bool {
+	for _, r := range fw.added() {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// added returns the rules added to ufw without the leading "ufw"
+// ("allow 80/tcp", "deny from 203.0.113.7")
+func (ufwFirewall) added() []string {
+	output, err := exec.Command("ufw", "show", "added").Output()
+	if err != nil {
+		return nil
+	}
+
+	var rules []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "ufw ") {
+			rules = append(rules, strings.TrimPrefix(line, "ufw "))
+		}
+	}
+	return rules
+}
+
+func ufwPort(port int, proto string) string {
+	return fmt.Sprintf("%d/%s", port, proto)
+}
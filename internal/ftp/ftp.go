@@ -0,0 +1,526 @@
+package ftp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/firewall"
+	"webstack-cli/internal/pkg"
+	"webstack-cli/internal/random"
+	"webstack-cli/internal/templates"
+	"webstack-cli/internal/ui"
+)
+
+const (
+	accountsFile  = "/etc/webstack/ftp.json"
+	vsftpdConf    = "/etc/vsftpd.conf"
+	pamFile       = "/etc/pam.d/vsftpd-webstack"
+	sshdDropIn    = "/etc/ssh/sshd_config.d/webstack-sftp.conf"
+	ftpDir        = "/etc/webstack/ftp"
+	userListFile  = "/etc/webstack/ftp/users"
+	certFile      = "/etc/webstack/ftp/vsftpd.crt"
+	keyFile       = "/etc/webstack/ftp/vsftpd.key"
+	accountsGroup = "webstack-ftp"
+
+	// DefaultPassivePorts is the passive data port range opened in the firewall
+	DefaultPassivePorts = "40000-40049"
+)
+
+// Account is an FTP/SFTP user locked into the directory of a domain
+type Account struct {
+	User      string    `json:"user"`
+	Domain    string    `json:"domain"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// InstallOptions controls the FTP server installation
+type InstallOptions struct {
+	PassivePorts string // Range such as 40000-40049
+}
+
+var userPattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+
+// Install installs vsftpd with explicit TLS for FTP accounts, enables SFTP
+// for the same accounts in OpenSSH and opens the FTP ports. It reports
+// whether vsftpd is up.
+func Install(opts InstallOptions) bool {
+	if opts.PassivePorts == "" {
+		opts.PassivePorts = DefaultPassivePorts
+	}
+	minPort, maxPort, err := parsePortRange(opts.PassivePorts)
+	if err != nil {
+		ui.Invalid("Invalid passive port range: %v\n", err)
+		return false
+	}
+
+	fmt.Println("📦 Installing vsftpd...")
+	if err := pkg.Install("vsftpd", "openssl"); err != nil {
+		ui.Fail("❌ Error installing vsftpd: %v\n", err)
+		return false
+	}
+
+	if err := dryrun.Run(exec.Command("groupadd", "-f", accountsGroup)); err != nil {
+		ui.Fail("❌ Could not create the %s group: %v\n", accountsGroup, err)
+		return false
+	}
+	if err := dryrun.MkdirAll(ftpDir, 0755); err != nil {
+		ui.Fail("❌ Could not create %s: %v\n", ftpDir, err)
+		return false
+	}
+	if err := ensureCertificate(); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return false
+	}
+	if err := writeUserList(); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return false
+	}
+
+	// A PAM service without pam_shells, as the accounts have no login shell
+	pam := "# WebStack CLI - vsftpd authentication for 'webstack ftp' accounts\n" +
+		"auth     required pam_unix.so\n" +
+		"account  required pam_unix.so\n" +
+		"session  required pam_unix.so\n"
+	if err := dryrun.WriteFile(pamFile, []byte(pam), 0644); err != nil {
+		ui.Fail("❌ Could not write %s: %v\n", pamFile, err)
+		return false
+	}
+
+	conf, err := render("vsftpd.conf", map[string]interface{}{
+		"PassiveMin": minPort,
+		"PassiveMax": maxPort,
+		"CertFile":   certFile,
+		"KeyFile":    keyFile,
+		"UserList":   userListFile,
+	})
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return false
+	}
+	if err := dryrun.WriteFile(vsftpdConf, []byte(conf), 0644); err != nil {
+		ui.Fail("❌ Could not write %s: %v\n", vsftpdConf, err)
+		return false
+	}
+	dryrun.Run(exec.Command("systemctl", "enable", "vsftpd"))
+	if err := dryrun.Run(exec.Command("systemctl", "restart", "vsftpd")); err != nil {
+		ui.Fail("❌ vsftpd did not start: %v\n", err)
+		fmt.Println("   View logs: sudo journalctl -xeu vsftpd.service")
+		return false
+	}
+	fmt.Println("✅ vsftpd configured (FTP with explicit TLS)")
+
+	if err := configureSFTP(); err != nil {
+		ui.Warn("⚠️  Warning: SFTP not configured: %v\n", err)
+	} else {
+		fmt.Println("✅ SFTP enabled for FTP accounts")
+	}
+
+	// Control connection and passive data ports; SFTP uses the SSH port
+	ports := []int{21}
+	for port := minPort; port <= maxPort; port++ {
+		ports = append(ports, port)
+	}
+	if err := firewall.Open("ftp", "tcp", ports...); err != nil {
+		ui.Warn("⚠️  Warning: Could not open the FTP ports: %v\n", err)
+	} else {
+		fmt.Printf("🔓 Opened port 21 and passive ports %d-%d\n", minPort, maxPort)
+	}
+
+	fmt.Println("✅ FTP server installed")
+	fmt.Println("💡 Create an account with: sudo webstack ftp user add <domain> <user>")
+	return true
+}
+
+// Uninstall removes vsftpd, the SFTP settings, the accounts and the firewall
+// rules. Domain files are kept.
+func Uninstall() {
+	accounts, err := loadAccounts()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	for _, account := range accounts {
+		if err := dryrun.Run(exec.Command("userdel", account.User)); err != nil {
+			ui.Warn("⚠️  Warning: Could not remove user %s: %v\n", account.User, err)
+		}
+	}
+
+	dryrun.Run(exec.Command("systemctl", "stop", "vsftpd"))
+	dryrun.Run(exec.Command("systemctl", "disable", "vsftpd"))
+	if err := pkg.Purge("vsftpd"); err != nil {
+		ui.Warn("⚠️  Warning: apt purge returned an error: %v\n", err)
+	}
+	dryrun.Remove(pamFile)
+	dryrun.RemoveAll(ftpDir)
+	dryrun.Remove(accountsFile)
+	if _, err := os.Stat(sshdDropIn); err == nil {
+		dryrun.Remove(sshdDropIn)
+		reloadSSH()
+	}
+	dryrun.Run(exec.Command("groupdel", accountsGroup))
+
+	if rules, err := firewall.Rules(); err == nil {
+		var ports []int
+		for _, rule := range rules {
+			for _, component := range rule.Components {
+				if component == "ftp" {
+					ports = append(ports, rule.Port)
+				}
+			}
+		}
+		if len(ports) > 0 {
+			if _, err := firewall.Close("ftp", "tcp", false, ports...); err != nil {
+				ui.Warn("⚠️  Warning: Could not close the FTP ports: %v\n", err)
+			}
+		}
+	}
+	fmt.Println("✅ FTP server uninstalled, domain files were kept")
+}
+
+// Installed reports whether vsftpd was set up by Install
+func Installed() bool {
+	_, err := os.Stat(pamFile)
+	return err == nil
+}
+
+// parsePortRange parses "40000-40049"
+func parsePortRange(value string) (int, int, error) {
+	parts := strings.SplitN(value, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("%s (use a range such as %s)", value, DefaultPassivePorts)
+	}
+	minPort, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+	maxPort, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err1 != nil || err2 != nil || minPort < 1024 || maxPort > 65535 || minPort > maxPort {
+		return 0, 0, fmt.Errorf("%s (ports must be between 1024 and 65535)", value)
+	}
+	if maxPort-minPort >= 500 {
+		return 0, 0, fmt.Errorf("%s spans more than 500 ports", value)
+	}
+	return minPort, maxPort, nil
+}
+
+// ensureCertificate creates a self-signed certificate for FTPS unless one
+// exists. Clients are asked to trust it on the first connection.
+func ensureCertificate() error {
+	if _, err := os.Stat(certFile); err == nil {
+		return nil
+	}
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "localhost"
+	}
+	fmt.Println("🔐 Creating a self-signed certificate for FTPS...")
+	cmd := exec.Command("openssl", "req", "-x509", "-nodes", "-newkey", "rsa:2048", "-days", "3650",
+		"-keyout", keyFile, "-out", certFile, "-subj", "/CN="+hostname)
+	if err := dryrun.Run(cmd); err != nil {
+		return fmt.Errorf("could not create the FTPS certificate: %v", err)
+	}
+	return dryrun.Run(exec.Command("chmod", "600", keyFile))
+}
+
+// configureSFTP locks the members of the accounts group into SFTP in their
+// home directory
+func configureSFTP() error {
+	if _, err := os.Stat("/etc/ssh/sshd_config"); err != nil {
+		return fmt.Errorf("OpenSSH server is not installed")
+	}
+	data, err := ioutil.ReadFile("/etc/ssh/sshd_config")
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(data), "sshd_config.d/") {
+		return fmt.Errorf("sshd_config does not include /etc/ssh/sshd_config.d")
+	}
+
+	conf, err := render("sshd-sftp.conf", map[string]interface{}{"Group": accountsGroup})
+	if err != nil {
+		return err
+	}
+	if err := dryrun.MkdirAll(filepath.Dir(sshdDropIn), 0755); err != nil {
+		return err
+	}
+	if err := dryrun.WriteFile(sshdDropIn, []byte(conf), 0644); err != nil {
+		return err
+	}
+	if output, err := exec.Command("sshd", "-t").CombinedOutput(); err != nil && !dryrun.Enabled() {
+		dryrun.Remove(sshdDropIn)
+		return fmt.Errorf("sshd rejected the configuration: %s", strings.TrimSpace(string(output)))
+	}
+	reloadSSH()
+	return nil
+}
+
+// reloadSSH reloads OpenSSH, whose unit is ssh on Debian and Ubuntu
+func reloadSSH() {
+	if err := dryrun.Run(exec.Command("systemctl", "reload", "ssh")); err != nil {
+		dryrun.Run(exec.Command("systemctl", "reload", "sshd"))
+	}
+}
+
+func render(name string, vars map[string]interface{}) (string, error) {
+	content, err := templates.GetFTPTemplate(name)
+	if err != nil {
+		return "", fmt.Errorf("could not read template %s: %v", name, err)
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return "", fmt.Errorf("could not parse template %s: %v", name, err)
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("could not execute template %s: %v", name, err)
+	}
+	return buf.String(), nil
+}
+
+// AddUser creates an FTP/SFTP account for a domain. Its home directory is
+// the domain's folder (/var/www/<domain> by default); uploads go to htdocs, which the account shares with
+// PHP-FPM through the www-data group.
+func AddUser(domainName, user, password string) {
+	if !Installed() {
+		ui.Fail("❌ The FTP server is not installed. Install it with: sudo webstack install ftp\n")
+		return
+	}
+	d, err := domain.GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+	if !userPattern.MatchString(user) {
+		ui.Invalid("Invalid user name: %s (lowercase letters, digits, - and _, starting with a letter)\n", user)
+		return
+	}
+	if _, err := exec.Command("id", "-u", user).Output(); err == nil {
+		ui.Fail("❌ A system user named %s already exists\n", user)
+		return
+	}
+
+	generated := password == ""
+	if generated {
+		if password, err = random.String(16); err != nil {
+			ui.Fail("❌ Could not generate a password: %v\n", err)
+			return
+		}
+	}
+
+	home := d.HomeDir()
+	htdocs := filepath.Join(home, "htdocs")
+
+	// The chroot must be owned by root and not writable by the account
+	dryrun.Run(exec.Command("chown", "root:root", home))
+	dryrun.Run(exec.Command("chmod", "755", home))
+
+	cmd := exec.Command("useradd", "--home-dir", home, "--no-create-home", "--shell", "/usr/sbin/nologin",
+		"--gid", "www-data", "--groups", accountsGroup, "--comment", "webstack ftp "+d.Name, user)
+	if err := dryrun.Run(cmd); err != nil {
+		ui.Fail("❌ Could not create user %s: %v\n", user, err)
+		return
+	}
+	if err := setPassword(user, password); err != nil {
+		ui.Fail("❌ %v\n", err)
+		dryrun.Run(exec.Command("userdel", user))
+		return
+	}
+
+	// Group writable, with new files inheriting the www-data group
+	dryrun.Run(exec.Command("chgrp", "-R", "www-data", htdocs))
+	dryrun.Run(exec.Command("chmod", "-R", "g+rwX", htdocs))
+	dryrun.Run(exec.Command("find", htdocs, "-type", "d", "-exec", "chmod", "g+s", "{}", "+"))
+
+	accounts, err := loadAccounts()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	accounts = append(accounts, Account{User: user, Domain: d.Name, CreatedAt: time.Now()})
+	if err := saveAccounts(accounts); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	if err := writeUserList(); err != nil {
+		ui.Warn("⚠️  Warning: %v\n", err)
+	}
+
+	fmt.Printf("✅ FTP account %s created for %s\n", user, d.Name)
+	fmt.Printf("   Directory: %s (upload to htdocs/)\n", home)
+	if d.CustomRoot() {
+		fmt.Printf("   ⚠️  The document root %s (--root) is outside the chroot and not reachable over FTP\n", d.DocumentRoot)
+	}
+	fmt.Println("   FTP: port 21, explicit TLS required (FTPES)")
+	fmt.Println("   SFTP: SSH port with the same credentials")
+	if generated {
+		fmt.Printf("   Password: %s\n", password)
+	}
+}
+
+// SetPassword changes the password of an FTP/SFTP account
+func SetPassword(user, password string) {
+	if _, err := findAccount(user); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	generated := password == ""
+	if generated {
+		var err error
+		if password, err = random.String(16); err != nil {
+			ui.Fail("❌ Could not generate a password: %v\n", err)
+			return
+		}
+	}
+	if err := setPassword(user, password); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	fmt.Printf("✅ Password of %s changed\n", user)
+	if generated {
+		fmt.Printf("   Password: %s\n", password)
+	}
+}
+
+// DeleteUser removes an FTP/SFTP account; the domain files are kept
+func DeleteUser(user string) {
+	account, err := findAccount(user)
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	// End open sessions before removing the user
+	dryrun.Run(exec.Command("pkill", "-u", user))
+	if err := dryrun.Run(exec.Command("userdel", user)); err != nil {
+		ui.Fail("❌ Could not remove user %s: %v\n", user, err)
+		return
+	}
+
+	accounts, err := loadAccounts()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	var kept []Account
+	for _, a := range accounts {
+		if a.User != user {
+			kept = append(kept, a)
+		}
+	}
+	if err := saveAccounts(kept); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	if err := writeUserList(); err != nil {
+		ui.Warn("⚠️  Warning: %v\n", err)
+	}
+	fmt.Printf("✅ FTP account %s of %s removed\n", user, account.Domain)
+}
+
+// ListUsers prints the FTP/SFTP accounts, optionally of one domain
+func ListUsers(domainName string) {
+	accounts, err := loadAccounts()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	domainName = domain.Normalize(domainName)
+
+	var shown []Account
+	for _, a := range accounts {
+		if domainName == "" || a.Domain == domainName {
+			shown = append(shown, a)
+		}
+	}
+	if len(shown) == 0 {
+		fmt.Println("ℹ️  No FTP accounts")
+		return
+	}
+	sort.Slice(shown, func(i, j int) bool {
+		if shown[i].Domain != shown[j].Domain {
+			return shown[i].Domain < shown[j].Domain
+		}
+		return shown[i].User < shown[j].User
+	})
+
+	fmt.Printf("%-20s %-30s %s\n", "USER", "DOMAIN", "CREATED")
+	for _, a := range shown {
+		note := ""
+		if !domain.DomainExists(a.Domain) {
+			note = "  (domain deleted)"
+		}
+		fmt.Printf("%-20s %-30s %s%s\n", a.User, a.Domain, a.CreatedAt.Format("2006-01-02"), note)
+	}
+}
+
+func setPassword(user, password string) error {
+	cmd := exec.Command("chpasswd")
+	cmd.Stdin = strings.NewReader(user + ":" + password + "\n")
+	if err := dryrun.Run(cmd); err != nil {
+		return fmt.Errorf("could not set the password of %s: %v", user, err)
+	}
+	return nil
+}
+
+// writeUserList writes the vsftpd user list, which only lets the accounts
+// log in
+func writeUserList() error {
+	accounts, err := loadAccounts()
+	if err != nil {
+		return err
+	}
+	var users strings.Builder
+	for _, a := range accounts {
+		users.WriteString(a.User + "\n")
+	}
+	if err := dryrun.WriteFile(userListFile, []byte(users.String()), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", userListFile, err)
+	}
+	return nil
+}
+
+func findAccount(user string) (*Account, error) {
+	accounts, err := loadAccounts()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range accounts {
+		if a.User == user {
+			return &a, nil
+		}
+	}
+	return nil, fmt.Errorf("no FTP account named %s", user)
+}
+
+func loadAccounts() ([]Account, error) {
+	data, err := ioutil.ReadFile(accountsFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", accountsFile, err)
+	}
+	var accounts []Account
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %v", accountsFile, err)
+	}
+	return accounts, nil
+}
+
+func saveAccounts(accounts []Account) error {
+	data, err := json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := dryrun.WriteFile(accountsFile, data, 0600); err != nil {
+		return fmt.Errorf("could not write %s: %v", accountsFile, err)
+	}
+	return nil
+}
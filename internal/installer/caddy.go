@@ -0,0 +1,127 @@
+package installer
+
+import (
+	"fmt"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/pkg"
+	"webstack-cli/internal/ui"
+)
+
+// caddyConfigFile is the main Caddyfile. Sites are generated per domain in
+// domain.CaddySitesDir and imported from it.
+const caddyConfigFile = "/etc/caddy/Caddyfile"
+
+// InstallCaddy installs Caddy as a backend for 'domain add --backend caddy'.
+// Caddy listens on a local port and Nginx keeps serving ports 80 and 443,
+// so automatic HTTPS is turned off.
+func InstallCaddy() {
+	component := components["caddy"]
+	fmt.Printf("📦 Installing %s...\n", component.Name)
+
+	if status := checkComponentStatus(components["nginx"]); status != Installed {
+		ui.Fail("❌ Caddy runs behind Nginx, install it first: sudo webstack install nginx\n")
+		return
+	}
+
+	if checkComponentStatus(component) == Installed {
+		switch promptForAction(component.Name) {
+		case "keep":
+			fmt.Printf("✅ Keeping existing %s installation\n", component.Name)
+			return
+		case "skip":
+			fmt.Printf("⏭️  Skipping %s installation\n", component.Name)
+			return
+		case "uninstall":
+			removeCaddy()
+			return
+		case "reinstall":
+			fmt.Printf("🔄 Reinstalling %s...\n", component.Name)
+			if err := uninstallComponent(component); err != nil {
+				ui.Fail("Error uninstalling %s: %v\n", component.Name, err)
+				return
+			}
+		}
+	}
+
+	if err := pkg.Update(); err != nil {
+		ui.Fail("Error updating package list: %v\n", err)
+		return
+	}
+	if err := pkg.Install(component.PackageName); err != nil {
+		ui.Fail("❌ Error installing %s: %v\n", component.Name, err)
+		return
+	}
+
+	fmt.Printf("⚙️  Configuring %s (127.0.0.1:%d)...\n", component.Name, domain.CaddyDefaultPort)
+	if err := dryrun.MkdirAll(domain.CaddySitesDir, 0755); err != nil {
+		ui.Fail("❌ Error creating %s: %v\n", domain.CaddySitesDir, err)
+		return
+	}
+	caddyfile := fmt.Sprintf(`# Managed by WebStack CLI
+# Nginx terminates SSL and proxies the caddy backend domains to 127.0.0.1:%d.
+# Each domain has a site file in %s.
+{
+	auto_https off
+	admin localhost:2019
+	servers {
+		trusted_proxies static 127.0.0.1/32
+	}
+}
+
+import %s/*.conf
+`, domain.CaddyDefaultPort, domain.CaddySitesDir, domain.CaddySitesDir)
+	if err := dryrun.WriteFile(caddyConfigFile, []byte(caddyfile), 0644); err != nil {
+		ui.Fail("❌ Error writing %s: %v\n", caddyConfigFile, err)
+		return
+	}
+
+	// PHP-FPM sockets belong to the www-data group
+	if err := runCommand("usermod", "-aG", "www-data", "caddy"); err != nil {
+		ui.Warn("⚠️  Warning: Could not add caddy to the www-data group: %v\n", err)
+	}
+
+	if err := runCommand("systemctl", "enable", component.ServiceName); err != nil {
+		ui.Fail("Error enabling %s: %v\n", component.Name, err)
+	}
+	if err := runCommand("systemctl", "restart", component.ServiceName); err != nil {
+		ui.Fail("❌ Error starting %s: %v\n", component.Name, err)
+		fmt.Printf("   View logs: sudo journalctl -xeu %s.service\n", component.ServiceName)
+		return
+	}
+
+	if err := UpdateServerConfig("caddy", true, domain.CaddyDefaultPort, "backend"); err != nil {
+		ui.Warn("⚠️  Warning: Could not update config: %v\n", err)
+	}
+
+	fmt.Printf("✅ %s installed successfully\n", component.Name)
+	fmt.Println("💡 Serve a domain with it: sudo webstack domain add example.com --backend caddy")
+}
+
+// UninstallCaddy removes Caddy
+func UninstallCaddy() {
+	component := components["caddy"]
+	if checkComponentStatus(component) != Installed {
+		fmt.Printf("ℹ️  %s is not installed\n", component.Name)
+		return
+	}
+
+	if !confirmRemoval("uninstall "+component.Name, fmt.Sprintf("Uninstall %s? Domains with the caddy backend stop working", component.Name)) {
+		fmt.Printf("⏭️  Skipping %s uninstall\n", component.Name)
+		return
+	}
+	removeCaddy()
+}
+
+func removeCaddy() {
+	component := components["caddy"]
+	if err := uninstallComponent(component); err != nil {
+		ui.Fail("❌ Error uninstalling %s: %v\n", component.Name, err)
+		return
+	}
+	if err := UpdateServerConfig("caddy", false, 0, ""); err != nil {
+		ui.Warn("⚠️  Warning: Could not update config: %v\n", err)
+	}
+	fmt.Printf("✅ %s uninstalled successfully\n", component.Name)
+	fmt.Println("💡 Move its domains to another backend: sudo webstack domain edit <domain> --backend nginx")
+}
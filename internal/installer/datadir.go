@@ -0,0 +1,215 @@
+package installer
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"webstack-cli/internal/bytesize"
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/mysql"
+	"webstack-cli/internal/osinfo"
+	"webstack-cli/internal/prompt"
+	"webstack-cli/internal/ui"
+)
+
+// PurgeData lets the database installers and uninstallers delete existing
+// data directories without asking (--purge-data)
+var PurgeData bool
+
+// dataDumpDir keeps the dumps taken before data directories are deleted
+const dataDumpDir = "/var/backups/webstack/purged"
+
+// dataDirGlobs are the data directories the clean-slate installs and the
+// uninstalls of a database engine delete
+var dataDirGlobs = map[string][]string{
+	"mysql":      {"/var/lib/mysql*"},
+	"postgresql": {"/var/lib/postgresql*"},
+	"mongodb":    {"/var/lib/mongodb", "/var/lib/mongo"}, // Debian, RHEL
+}
+
+// dataEngine returns the engine whose data a package's removal deletes
+func dataEngine(packageName string) string {
+	// The first package is the server, e.g. "postgresql postgresql-contrib"
+	switch strings.Fields(packageName + " ")[0] {
+	case "mysql-server", "mariadb-server":
+		return "mysql"
+	case "postgresql":
+		return "postgresql"
+	case "mongodb-org":
+		return "mongodb"
+	}
+	return ""
+}
+
+// dataDir is a data directory that still holds files
+type dataDir struct {
+	Path     string
+	Size     int64
+	Files    int
+	Modified time.Time // Newest file
+}
+
+// existingData returns the data directories of an engine that hold files
+func existingData(engine string) []dataDir {
+	var dirs []dataDir
+	for _, glob := range dataDirGlobs[engine] {
+		matches, _ := filepath.Glob(glob)
+		for _, path := range matches {
+			d := dataDir{Path: path}
+			filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+				if err != nil || !info.Mode().IsRegular() {
+					return nil
+				}
+				d.Files++
+				d.Size += info.Size()
+				if info.ModTime().After(d.Modified) {
+					d.Modified = info.ModTime()
+				}
+				return nil
+			})
+			if d.Files > 0 {
+				dirs = append(dirs, d)
+			}
+		}
+	}
+	return dirs
+}
+
+// guardDataRemoval decides whether the data directories of an engine
+// (mysql covers MariaDB too) may be deleted. Directories holding files are
+// listed; they are deleted with --purge-data, or once the user confirms
+// twice, the second time by typing the hostname, after being offered a
+// dump. Without a terminal, in install profiles and under
+// WEBSTACK_ASSUME_YES only --purge-data deletes them. Installing and
+// uninstalling a database server call it before touching its data.
+func guardDataRemoval(engine, name string) bool {
+	dirs := existingData(engine)
+	if len(dirs) == 0 {
+		return true
+	}
+
+	ui.Warn("⚠️  Existing %s data found:\n", name)
+	for _, d := range dirs {
+		fmt.Printf("   %-26s %10s in %d file(s), last modified %s\n", d.Path, bytesize.Format(d.Size), d.Files, d.Modified.Format("2006-01-02 15:04"))
+	}
+	if PurgeData {
+		fmt.Println("🗑️  --purge-data given, the data is deleted")
+		return true
+	}
+	if dryrun.Enabled() {
+		fmt.Println("ℹ️  A real run deletes it only with --purge-data or after confirming with the hostname")
+		return true
+	}
+	if unattended != nil || prompt.AssumeYes() || !prompt.Interactive() {
+		ui.Fail("❌ Refusing to delete the %s data without --purge-data\n", name)
+		return false
+	}
+	if !prompt.Allow(fmt.Sprintf("delete the %s data", name)) {
+		return false
+	}
+
+	if !prompt.Confirm(fmt.Sprintf("Delete the %s data directories listed above? This cannot be undone", name)) {
+		fmt.Printf("ℹ️  The %s data was kept\n", name)
+		return false
+	}
+	if !prompt.ConfirmHostname() {
+		ui.Fail("❌ The hostname did not match, the %s data was kept\n", name)
+		return false
+	}
+
+	if prompt.Ask(fmt.Sprintf("Save a dump to %s first?", dataDumpDir), true) {
+		path, err := dumpData(engine, dirs)
+		if err != nil {
+			ui.Fail("❌ Could not save the %s data, it was kept: %v\n", name, err)
+			os.Remove(path)
+			return false
+		}
+		fmt.Printf("✅ %s data saved to %s\n", name, path)
+	}
+	return true
+}
+
+// dumpData saves the data of an engine before it is deleted: a dump of all
+// databases while the server runs (SQL, or a mongodump archive), otherwise
+// an archive of the data directories
+func dumpData(engine string, dirs []dataDir) (string, error) {
+	if err := os.MkdirAll(dataDumpDir, 0700); err != nil {
+		return "", err
+	}
+	stamp := time.Now().Format("20060102-150405")
+
+	var cmd *exec.Cmd
+	ext := "sql.gz"
+	switch {
+	case engine == "mysql" && (isServiceActive(osinfo.Current().Service("mysql")) || isServiceActive("mariadb")):
+		// The password goes in an option file, not the environment
+		dump, cleanup, err := mysql.Command("mysqldump", "root", mysql.RootPassword("mysql"), "--all-databases", "--single-transaction", "--routines", "--events", "--triggers")
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+		cmd = dump
+	case engine == "postgresql" && isServiceActive("postgresql"):
+		cmd = exec.Command("sudo", "-u", "postgres", "pg_dumpall")
+	case engine == "mongodb" && isServiceActive("mongod"):
+		cmd = exec.Command("mongodump", "--archive", "--quiet")
+		if password := mongoDBAdminPassword(); password != "" {
+			// The password is read from a --config file, not the command line
+			quoted, _ := json.Marshal(password)
+			configFile, err := secretFile("webstack-mongodump-*.yaml", "password: "+string(quoted)+"\n")
+			if err != nil {
+				return "", err
+			}
+			defer os.Remove(configFile)
+			cmd.Args = append(cmd.Args, "-u", mongoDBAdminUser, "--config", configFile, "--authenticationDatabase", "admin")
+		}
+		ext = "archive.gz"
+	default:
+		path := filepath.Join(dataDumpDir, fmt.Sprintf("%s-data-%s.tar.gz", engine, stamp))
+		args := []string{"-czf", path, "-C", "/"}
+		for _, d := range dirs {
+			args = append(args, strings.TrimPrefix(d.Path, "/"))
+		}
+		fmt.Printf("📦 The server is not running, archiving the data directories to %s...\n", path)
+		if output, err := exec.Command("tar", args...).CombinedOutput(); err != nil {
+			return path, fmt.Errorf("tar failed: %v %s", err, strings.TrimSpace(string(output)))
+		}
+		return path, nil
+	}
+
+	path := filepath.Join(dataDumpDir, fmt.Sprintf("%s-%s.%s", engine, stamp, ext))
+	fmt.Printf("📦 Dumping all databases to %s...\n", path)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	stderr := &strings.Builder{}
+	cmd.Stdout = gz
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return path, fmt.Errorf("%s failed: %v %s", cmd.Args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	if err := gz.Close(); err != nil {
+		return path, err
+	}
+	return path, f.Sync()
+}
+
+// mongoDBAdminPassword returns the password of the MongoDB admin user saved
+// by the installer
+func mongoDBAdminPassword() string {
+	cfg, err := config.Load()
+	if err != nil {
+		return ""
+	}
+	password, _ := cfg.GetDefault(mongoDBPasswordKey, "").(string)
+	return password
+}
@@ -0,0 +1,60 @@
+package installer
+
+import (
+	"fmt"
+	"webstack-cli/internal/ftp"
+	"webstack-cli/internal/ui"
+)
+
+// InstallFTP installs vsftpd for FTP/SFTP accounts scoped to a domain.
+// Keeping an existing installation still applies a new passive port range.
+func InstallFTP(opts ftp.InstallOptions) {
+	component := components["ftp"]
+	if checkComponentStatus(component) == Installed {
+		action := promptForAction(component.Name)
+		switch action {
+		case "keep":
+			if opts.PassivePorts == "" {
+				fmt.Printf("✅ Keeping existing %s installation\n", component.Name)
+				return
+			}
+		case "skip":
+			fmt.Printf("⏭️  Skipping %s installation\n", component.Name)
+			return
+		case "uninstall":
+			removeFTP()
+			return
+		case "reinstall":
+			fmt.Printf("🔄 Reinstalling %s...\n", component.Name)
+			ftp.Uninstall()
+		}
+	}
+
+	if !ftp.Install(opts) {
+		return
+	}
+	if err := UpdateServerConfig("ftp", true, 21, ""); err != nil {
+		ui.Warn("⚠️  Warning: Could not update config: %v\n", err)
+	}
+}
+
+// UninstallFTP removes vsftpd and every FTP account
+func UninstallFTP() {
+	component := components["ftp"]
+	if checkComponentStatus(component) != Installed {
+		fmt.Printf("ℹ️  %s is not installed\n", component.Name)
+		return
+	}
+	if !confirmRemoval("uninstall the FTP server", "Uninstall the FTP server and remove every FTP account?") {
+		fmt.Printf("⏭️  Skipping %s uninstall\n", component.Name)
+		return
+	}
+	removeFTP()
+}
+
+func removeFTP() {
+	ftp.Uninstall()
+	if err := UpdateServerConfig("ftp", false, 0, ""); err != nil {
+		ui.Warn("⚠️  Warning: Could not update config: %v\n", err)
+	}
+}
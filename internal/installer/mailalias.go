@@ -0,0 +1,321 @@
+package installer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/ui"
+)
+
+// postfixVirtualFile maps aliases and catch-alls (@domain) to the addresses
+// receiving their mail
+const postfixVirtualFile = "/etc/postfix/virtual"
+
+// mailAlias forwards an address, or with an empty user every address of a
+// domain without a mailbox (catch-all), to one or more addresses
+type mailAlias struct {
+	Source       string
+	Destinations []string
+}
+
+// parseAliasSource validates an alias address: user@domain, or @domain or
+// *@domain for a catch-all. The domain must be a mail domain.
+func parseAliasSource(source string) (string, error) {
+	source = normalizeMailAddress(strings.TrimPrefix(strings.TrimSpace(source), "*"))
+	i := strings.LastIndex(source, "@")
+	if i < 0 || i == len(source)-1 || strings.ContainsAny(source, " \t,:") {
+		return "", fmt.Errorf("%s is not an address (user@domain) or a catch-all (@domain)", source)
+	}
+	domains, _ := ioutil.ReadFile("/etc/postfix/vdomains")
+	if !strings.Contains("\n"+string(domains), "\n"+source[i+1:]+"\t") {
+		return "", fmt.Errorf("%s is not a mail domain (add it with 'webstack mail add domain %s')", source[i+1:], source[i+1:])
+	}
+	return source, nil
+}
+
+// parseAliasDestination validates a destination, which may be external
+func parseAliasDestination(destination string) (string, error) {
+	destination = normalizeMailAddress(destination)
+	i := strings.LastIndex(destination, "@")
+	if i <= 0 || i == len(destination)-1 || strings.ContainsAny(destination, " \t,:") {
+		return "", fmt.Errorf("%s is not an address (user@domain)", destination)
+	}
+	return destination, nil
+}
+
+// loadMailAliases reads the aliases from the virtual table. Identity lines,
+// which keep the mailboxes of a catch-all domain reachable, are left out;
+// writeMailAliases adds them again.
+func loadMailAliases() []mailAlias {
+	content, err := ioutil.ReadFile(postfixVirtualFile)
+	if err != nil {
+		return nil
+	}
+	var aliases []mailAlias
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		var destinations []string
+		for _, d := range strings.Split(strings.Join(fields[1:], ""), ",") {
+			if d != "" {
+				destinations = append(destinations, d)
+			}
+		}
+		if len(destinations) == 1 && destinations[0] == fields[0] {
+			continue
+		}
+		aliases = append(aliases, mailAlias{Source: mailMapKey(fields[0]), Destinations: destinations})
+	}
+	return aliases
+}
+
+// mailboxes returns the addresses with a mailbox in the vmailbox map
+func mailboxes() []string {
+	content, _ := ioutil.ReadFile("/etc/postfix/vmailbox")
+	var addresses []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && !strings.HasPrefix(fields[0], "#") {
+			addresses = append(addresses, fields[0])
+		}
+	}
+	return addresses
+}
+
+// writeMailAliases writes the virtual table and rebuilds its map. Postfix
+// resolves a catch-all before the mailboxes of its domain, so each mailbox
+// of a catch-all domain without an alias of its own gets an identity line.
+func writeMailAliases(aliases []mailAlias) error {
+	var b strings.Builder
+	b.WriteString("# WebStack CLI - Mail aliases and forwarders (managed with 'webstack mail alias')\n")
+	catchAll := map[string]bool{}
+	sources := map[string]bool{}
+	for _, a := range aliases {
+		fmt.Fprintf(&b, "%s\t%s\n", a.Source, strings.Join(a.Destinations, ", "))
+		if strings.HasPrefix(a.Source, "@") {
+			catchAll[a.Source[1:]] = true
+		}
+		sources[a.Source] = true
+	}
+	identities := false
+	for _, mailbox := range mailboxes() {
+		if catchAll[aliasDomain(mailbox)] && !sources[mailbox] {
+			if !identities {
+				b.WriteString("\n# Mailboxes of catch-all domains, delivered before the catch-all\n")
+				identities = true
+			}
+			fmt.Fprintf(&b, "%s\t%s\n", mailbox, mailbox)
+		}
+	}
+
+	if err := dryrun.WriteFile(postfixVirtualFile, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", postfixVirtualFile, err)
+	}
+	if err := runCommandQuiet("postmap", postfixVirtualFile); err != nil {
+		return fmt.Errorf("postmap %s failed: %v", postfixVirtualFile, err)
+	}
+	return nil
+}
+
+// syncMailAliases rewrites the virtual table after mailboxes changed, so
+// the identity lines of catch-all domains follow them
+func syncMailAliases() {
+	if _, err := os.Stat(postfixVirtualFile); err != nil {
+		return
+	}
+	if err := writeMailAliases(loadMailAliases()); err != nil {
+		ui.Warn("⚠️  Warning: Could not update mail aliases: %v\n", err)
+	}
+}
+
+// enableVirtualAliases points Postfix to the virtual table, creating it
+func enableVirtualAliases() error {
+	if _, err := os.Stat(postfixVirtualFile); os.IsNotExist(err) {
+		if err := writeMailAliases(nil); err != nil {
+			return err
+		}
+	}
+	return runCommandQuiet("postconf", "-e", "virtual_alias_maps=hash:"+postfixVirtualFile)
+}
+
+func aliasDomain(address string) string {
+	return address[strings.LastIndex(address, "@")+1:]
+}
+
+// AddMailAlias forwards an address or, as @domain, a catch-all to one or
+// more destinations; destinations are added to an existing alias
+func AddMailAlias(source string, destinations []string) {
+	migrateMailMaps()
+	source, err := parseAliasSource(source)
+	if err != nil {
+		ui.Invalid("Invalid alias: %v\n", err)
+		return
+	}
+	var targets []string
+	for _, d := range destinations {
+		for _, part := range strings.Split(d, ",") {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			target, err := parseAliasDestination(part)
+			if err != nil {
+				ui.Invalid("Invalid alias: %v\n", err)
+				return
+			}
+			targets = append(targets, target)
+		}
+	}
+	if len(targets) == 0 {
+		ui.Invalid("Invalid alias: at least one destination address is required\n")
+		return
+	}
+
+	aliases := loadMailAliases()
+	index := -1
+	for i, a := range aliases {
+		if a.Source == source {
+			index = i
+		}
+	}
+	if index < 0 {
+		aliases = append(aliases, mailAlias{Source: source})
+		index = len(aliases) - 1
+	}
+	for _, target := range targets {
+		if !containsString(aliases[index].Destinations, target) {
+			aliases[index].Destinations = append(aliases[index].Destinations, target)
+		}
+	}
+
+	if err := enableVirtualAliases(); err != nil {
+		ui.Fail("❌ Could not enable aliases in Postfix: %v\n", err)
+		return
+	}
+	if err := writeMailAliases(aliases); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	runCommandQuiet("postfix", "reload")
+
+	if strings.HasPrefix(source, "@") {
+		fmt.Printf("✅ Catch-all of %s → %s\n", source[1:], strings.Join(aliases[index].Destinations, ", "))
+		fmt.Println("   Addresses with a mailbox keep receiving their own mail")
+	} else {
+		fmt.Printf("✅ Alias %s → %s\n", source, strings.Join(aliases[index].Destinations, ", "))
+	}
+	if containsString(mailboxes(), source) && !containsString(aliases[index].Destinations, source) {
+		ui.Warn("⚠️  %s has a mailbox, which no longer receives mail; add %s as a destination to keep a copy\n", source, source)
+	}
+}
+
+// DeleteMailAlias removes an alias, or one destination of it
+func DeleteMailAlias(source, destination string) {
+	migrateMailMaps()
+	source = normalizeMailAddress(strings.TrimPrefix(strings.TrimSpace(source), "*"))
+	destination = normalizeMailAddress(destination)
+
+	aliases := loadMailAliases()
+	index := -1
+	for i, a := range aliases {
+		if a.Source == source {
+			index = i
+		}
+	}
+	if index < 0 {
+		ui.Fail("❌ No alias %s configured\n", source)
+		return
+	}
+
+	if destination != "" {
+		var kept []string
+		for _, d := range aliases[index].Destinations {
+			if d != destination {
+				kept = append(kept, d)
+			}
+		}
+		if len(kept) == len(aliases[index].Destinations) {
+			ui.Fail("❌ %s is not forwarded to %s\n", source, destination)
+			return
+		}
+		aliases[index].Destinations = kept
+	}
+	if destination == "" || len(aliases[index].Destinations) == 0 {
+		aliases = append(aliases[:index:index], aliases[index+1:]...)
+	}
+
+	if err := writeMailAliases(aliases); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	runCommandQuiet("postfix", "reload")
+
+	if destination != "" {
+		fmt.Printf("✅ %s no longer forwarded to %s\n", source, destination)
+	} else {
+		fmt.Printf("✅ Alias %s deleted\n", source)
+	}
+}
+
+// ListMailAliases lists the aliases and catch-alls, optionally of one domain
+func ListMailAliases(domain string) {
+	migrateMailMaps()
+	if domain != "" {
+		domain = mailMapKey(domain)
+	}
+	fmt.Println("📋 Mail Aliases")
+	fmt.Println("===============")
+
+	count := 0
+	for _, a := range loadMailAliases() {
+		if domain != "" && aliasDomain(a.Source) != domain {
+			continue
+		}
+		source := a.Source
+		if strings.HasPrefix(source, "@") {
+			source = "*" + source + " (catch-all)"
+		}
+		fmt.Printf("  • %s → %s\n", source, strings.Join(a.Destinations, ", "))
+		count++
+	}
+
+	if count == 0 {
+		ui.Fail("❌ No mail aliases configured yet\n")
+	} else {
+		fmt.Printf("\n✅ Total: %d alias(es)\n", count)
+	}
+}
+
+// removeDomainAliases drops the aliases of a deleted mail domain
+func removeDomainAliases(domain string) {
+	aliases := loadMailAliases()
+	var kept []mailAlias
+	for _, a := range aliases {
+		if aliasDomain(a.Source) != domain {
+			kept = append(kept, a)
+		}
+	}
+	if len(kept) == len(aliases) {
+		return
+	}
+	if err := writeMailAliases(kept); err != nil {
+		ui.Warn("⚠️  Warning: Could not remove the aliases of %s: %v\n", domain, err)
+		return
+	}
+	fmt.Printf("🗑️  Removed %d alias(es) of %s\n", len(aliases)-len(kept), domain)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
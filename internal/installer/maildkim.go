@@ -0,0 +1,335 @@
+package installer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/pkg"
+	"webstack-cli/internal/ui"
+)
+
+const (
+	// dkimKeyDir holds the <domain>.private.key files of AddMailDomain
+	dkimKeyDir = "/etc/postfix/dkim"
+	// dkimSelector is the selector of the published default._domainkey records
+	dkimSelector = "default"
+	// dkimMilter is where OpenDKIM listens; a TCP socket works from Postfix's chroot
+	dkimMilter = "inet:localhost:8891"
+
+	openDKIMConf = "/etc/opendkim.conf"
+	openDKIMDir  = "/etc/opendkim"
+)
+
+var (
+	dkimKeyTable     = filepath.Join(openDKIMDir, "key.table")
+	dkimSigningTable = filepath.Join(openDKIMDir, "signing.table")
+	dkimTrustedHosts = filepath.Join(openDKIMDir, "trusted.hosts")
+)
+
+// openDKIMConfig signs mail of the domains in the signing table submitted
+// from localhost or by authenticated users, and verifies incoming mail
+const openDKIMConfig = `# WebStack CLI - OpenDKIM signing (managed with 'webstack mail dkim')
+Syslog                  yes
+UMask                   007
+UserID                  opendkim
+Mode                    sv
+Canonicalization        relaxed/simple
+OversignHeaders         From
+KeyTable                refile:/etc/opendkim/key.table
+SigningTable            refile:/etc/opendkim/signing.table
+ExternalIgnoreList      /etc/opendkim/trusted.hosts
+InternalHosts           /etc/opendkim/trusted.hosts
+Socket                  inet:8891@localhost
+PidFile                 /run/opendkim/opendkim.pid
+`
+
+// installOpenDKIM installs OpenDKIM, configures it for the mail domains
+// and adds it to Postfix as milter
+func installOpenDKIM() error {
+	if !isPackageInstalled("opendkim") {
+		fmt.Println("📦 Installing OpenDKIM for DKIM signing...")
+		if err := pkg.Install("opendkim", "opendkim-tools"); err != nil {
+			return fmt.Errorf("could not install OpenDKIM: %v", err)
+		}
+	}
+	return configureOpenDKIM()
+}
+
+// configureOpenDKIM writes the OpenDKIM configuration and tables and wires
+// the milter into Postfix
+func configureOpenDKIM() error {
+	if err := dryrun.MkdirAll(openDKIMDir, 0755); err != nil {
+		return err
+	}
+	if err := dryrun.WriteFile(openDKIMConf, []byte(openDKIMConfig), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", openDKIMConf, err)
+	}
+	if err := dryrun.WriteFile(dkimTrustedHosts, []byte("127.0.0.1\n::1\nlocalhost\n"), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", dkimTrustedHosts, err)
+	}
+	setOpenDKIMSocket()
+
+	if _, err := writeDKIMTables(); err != nil {
+		return err
+	}
+
+	// Mail submitted with sendmail (PHP's mail()) passes non_smtpd_milters
+	milters := dkimMilter
+	if current, _ := exec.Command("postconf", "-h", "smtpd_milters").Output(); strings.TrimSpace(string(current)) != "" {
+		milters = strings.TrimSpace(string(current))
+		if !strings.Contains(milters, dkimMilter) {
+			milters += ", " + dkimMilter
+		}
+	}
+	for _, setting := range []string{
+		"smtpd_milters=" + milters,
+		"non_smtpd_milters=$smtpd_milters",
+		"milter_default_action=accept",
+		"milter_protocol=6",
+	} {
+		runCommandQuiet("postconf", "-e", setting)
+	}
+
+	runCommandQuiet("systemctl", "enable", "opendkim")
+	if err := runCommandQuiet("systemctl", "restart", "opendkim"); err != nil {
+		ui.Warn("⚠️  Warning: Could not restart OpenDKIM: %v\n", err)
+	}
+	runCommandQuiet("postfix", "reload")
+	return nil
+}
+
+// setOpenDKIMSocket points the Debian service defaults to the TCP socket,
+// which otherwise override the Socket of opendkim.conf
+func setOpenDKIMSocket() {
+	const defaults = "/etc/default/opendkim"
+	content, err := ioutil.ReadFile(defaults)
+	if err != nil {
+		return
+	}
+	lines := strings.Split(string(content), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "SOCKET=") {
+			lines[i] = `SOCKET="inet:8891@localhost"`
+		}
+	}
+	if updated := strings.Join(lines, "\n"); updated != string(content) {
+		dryrun.WriteFile(defaults, []byte(updated), 0644)
+		if _, err := os.Stat("/lib/opendkim/opendkim.service.generate"); err == nil {
+			runCommandQuiet("/lib/opendkim/opendkim.service.generate")
+			runCommandQuiet("systemctl", "daemon-reload")
+		}
+	}
+}
+
+// dkimDomains returns the mail domains that have a DKIM key
+func dkimDomains() []string {
+	content, _ := ioutil.ReadFile("/etc/postfix/vdomains")
+	var domains []string
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if _, err := os.Stat(dkimKeyFile(fields[0])); err == nil {
+			domains = append(domains, fields[0])
+		}
+	}
+	return domains
+}
+
+func dkimKeyFile(domain string) string {
+	return filepath.Join(dkimKeyDir, domain+".private.key")
+}
+
+// writeDKIMTables writes the key and signing tables for the mail domains
+// with a key and lets OpenDKIM read the keys. It returns the domains.
+func writeDKIMTables() ([]string, error) {
+	domains := dkimDomains()
+	var keys, signing strings.Builder
+	keys.WriteString("# WebStack CLI - DKIM keys of the mail domains\n")
+	signing.WriteString("# WebStack CLI - sender addresses signed per mail domain\n")
+	for _, domain := range domains {
+		record := dkimSelector + "._domainkey." + domain
+		fmt.Fprintf(&keys, "%s %s:%s:%s\n", record, domain, dkimSelector, dkimKeyFile(domain))
+		fmt.Fprintf(&signing, "*@%s %s\n", domain, record)
+	}
+
+	if err := dryrun.WriteFile(dkimKeyTable, []byte(keys.String()), 0644); err != nil {
+		return nil, fmt.Errorf("could not write %s: %v", dkimKeyTable, err)
+	}
+	if err := dryrun.WriteFile(dkimSigningTable, []byte(signing.String()), 0644); err != nil {
+		return nil, fmt.Errorf("could not write %s: %v", dkimSigningTable, err)
+	}
+
+	// The keys stay private to root and the opendkim group
+	runCommandQuiet("chown", "-R", "root:opendkim", dkimKeyDir)
+	runCommandQuiet("chmod", "750", dkimKeyDir)
+	for _, domain := range domains {
+		runCommandQuiet("chmod", "640", dkimKeyFile(domain))
+	}
+	return domains, nil
+}
+
+// syncDKIM updates the signing tables after mail domains changed
+func syncDKIM() {
+	if _, err := os.Stat(openDKIMConf); err != nil {
+		return
+	}
+	if _, err := writeDKIMTables(); err != nil {
+		ui.Warn("⚠️  Warning: Could not update DKIM signing: %v\n", err)
+		return
+	}
+	runCommandQuiet("systemctl", "reload-or-restart", "opendkim")
+}
+
+// SetupDKIM installs and wires OpenDKIM on a server whose mail stack was
+// installed before DKIM signing, and shows the signed domains
+func SetupDKIM() {
+	migrateMailMaps()
+	if !isPackageInstalled("postfix") {
+		ui.Fail("❌ Postfix is not installed (run 'webstack install mail')\n")
+		return
+	}
+	if err := installOpenDKIM(); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	DKIMStatus()
+}
+
+// DKIMStatus shows whether Postfix passes mail to OpenDKIM and which
+// domains are signed
+func DKIMStatus() {
+	fmt.Println("🔏 DKIM Signing")
+	fmt.Println("===============")
+
+	running := exec.Command("systemctl", "is-active", "--quiet", "opendkim").Run() == nil
+	milters, _ := exec.Command("postconf", "-h", "smtpd_milters").Output()
+	wired := strings.Contains(string(milters), dkimMilter)
+
+	status, milter := "not running", "not configured"
+	if running {
+		status = "running"
+	}
+	if wired {
+		milter = dkimMilter
+	}
+	fmt.Printf("  OpenDKIM:        %s\n", status)
+	fmt.Printf("  Postfix milter:  %s\n", milter)
+	if !wired || !running {
+		fmt.Println("  💡 Set up signing with: sudo webstack mail dkim setup")
+	}
+
+	domains := dkimDomains()
+	if len(domains) == 0 {
+		fmt.Println("  No mail domain has a DKIM key yet")
+		return
+	}
+	fmt.Println("  Signed domains (selector default):")
+	for _, domain := range domains {
+		fmt.Printf("    • %s\n", domain)
+	}
+}
+
+// VerifyDKIM checks that the published key of a domain matches its private
+// key and that mail sent through Postfix carries a DKIM-Signature header.
+// The test message goes to a mailbox of the domain, or to the address given
+// with to, e.g. a mail tester.
+func VerifyDKIM(domain, to string) {
+	domain = mailMapKey(domain)
+	migrateMailMaps()
+	fmt.Printf("🔏 Verifying DKIM signing for %s\n", domain)
+
+	keyFile := dkimKeyFile(domain)
+	if _, err := os.Stat(keyFile); err != nil {
+		ui.Fail("❌ %s has no DKIM key (add it with 'webstack mail add domain %s')\n", domain, domain)
+		return
+	}
+
+	// The DNS record must publish the public half of the key
+	output, err := exec.Command("opendkim-testkey", "-d", domain, "-s", dkimSelector, "-k", keyFile, "-vvv").CombinedOutput()
+	switch {
+	case err == nil && strings.Contains(string(output), "key OK"):
+		fmt.Printf("✅ DNS record %s._domainkey.%s matches the key\n", dkimSelector, domain)
+	case err == nil:
+		fmt.Printf("✅ DNS record %s._domainkey.%s matches the key (not secured with DNSSEC)\n", dkimSelector, domain)
+	default:
+		ui.Warn("⚠️  DNS record %s._domainkey.%s could not be verified: %s\n", dkimSelector, domain, strings.TrimSpace(string(output)))
+		fmt.Printf("   Publish it with: webstack mail dns show %s\n", domain)
+	}
+
+	milters, _ := exec.Command("postconf", "-h", "smtpd_milters").Output()
+	if !strings.Contains(string(milters), dkimMilter) {
+		ui.Fail("❌ Postfix does not pass mail to OpenDKIM\n")
+		fmt.Println("   Set up signing with: sudo webstack mail dkim setup")
+		return
+	}
+
+	external := to != ""
+	var mailbox string
+	if !external {
+		for _, address := range mailboxes() {
+			if aliasDomain(address) == domain {
+				to = address
+				break
+			}
+		}
+		if to == "" {
+			ui.Fail("❌ %s has no mailbox to receive the test message; use --to with an address\n", domain)
+			return
+		}
+		user := to[:strings.LastIndex(to, "@")]
+		mailbox = filepath.Join("/var/mail/vhosts", domain, user)
+	}
+
+	token := make([]byte, 8)
+	rand.Read(token)
+	id := hex.EncodeToString(token)
+	message := fmt.Sprintf("From: postmaster@%s\r\nTo: %s\r\nSubject: WebStack DKIM test %s\r\nDate: %s\r\nMessage-ID: <%s@%s>\r\n\r\nThis message tests the DKIM signing of %s.\r\n",
+		domain, to, id, time.Now().Format(time.RFC1123Z), id, domain, domain)
+
+	send := exec.Command("sendmail", "-f", "postmaster@"+domain, to)
+	send.Stdin = strings.NewReader(message)
+	if err := dryrun.Run(send); err != nil {
+		ui.Fail("❌ Could not send the test message: %v\n", err)
+		return
+	}
+	fmt.Printf("📨 Test message sent from postmaster@%s to %s\n", domain, to)
+	if external || dryrun.Enabled() {
+		fmt.Println("   Check its headers for DKIM-Signature and dkim=pass")
+		return
+	}
+
+	// Wait for local delivery and read the headers of the message
+	pattern := regexp.MustCompile(`(?im)^DKIM-Signature:.*\bd=` + regexp.QuoteMeta(domain) + `\b`)
+	for attempt := 0; attempt < 20; attempt++ {
+		time.Sleep(500 * time.Millisecond)
+		for _, dir := range []string{"new", "cur"} {
+			files, _ := filepath.Glob(filepath.Join(mailbox, dir, "*"))
+			for _, file := range files {
+				content, err := ioutil.ReadFile(file)
+				if err != nil || !strings.Contains(string(content), "WebStack DKIM test "+id) {
+					continue
+				}
+				dryrun.Remove(file)
+				headers := strings.SplitN(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n\n", 2)[0]
+				if pattern.MatchString(headers) {
+					fmt.Printf("✅ Outgoing mail of %s carries a DKIM-Signature (d=%s, s=%s)\n", domain, domain, dkimSelector)
+				} else {
+					ui.Fail("❌ The test message arrived without a DKIM-Signature for %s\n", domain)
+					fmt.Println("   Check 'journalctl -u opendkim' and the tables in /etc/opendkim")
+				}
+				return
+			}
+		}
+	}
+	ui.Warn("⚠️  The test message did not arrive in %s within 10 seconds; check 'mailq'\n", mailbox)
+}
@@ -0,0 +1,386 @@
+package installer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/pkg"
+	"webstack-cli/internal/ui"
+)
+
+// dovecotMasterConf adds a master passdb: '<account>*webstack-migrate'
+// logs in to any mailbox with the master password, so imapsync can write
+// into accounts whose passwords are only stored as hashes
+const dovecotMasterConf = "/etc/dovecot/conf.d/10-webstack-master.conf"
+
+// dovecotMasterUsers holds the master user only while a migration runs
+const dovecotMasterUsers = "/etc/dovecot/master-users"
+
+const migrationMasterUser = "webstack-migrate"
+
+// imapsyncLogDir keeps the full imapsync log of every migrated mailbox
+const imapsyncLogDir = "/var/log/webstack/imapsync"
+
+// MailMigration copies the mailbox of an account on another IMAP server
+// into a local mail account
+type MailMigration struct {
+	Source   string // IMAP host of the old server, optionally host:port
+	Security string // "ssl" (default, port 993), "starttls" or "none" (port 143)
+	User     string // Login on the old server
+	Password string // Password on the old server
+	Dest     string // Local mail account receiving the mail
+}
+
+// migrationResult is the outcome of one imapsync run
+type migrationResult struct {
+	Dest          string
+	Transferred   int
+	FoldersSynced string
+	Errors        int
+	FailedFolders []string
+	Log           string
+	Err           error
+}
+
+var (
+	imapsyncFolderPattern      = regexp.MustCompile(`^Folder\s+(\d+/\d+)\s+\[(.*?)\]\s+->\s+\[`)
+	imapsyncTransferredPattern = regexp.MustCompile(`^Messages transferred\s*:\s*(\d+)`)
+	imapsyncFoldersPattern     = regexp.MustCompile(`^Folders synced\s*:\s*(\d+/\d+)`)
+	imapsyncErrorsPattern      = regexp.MustCompile(`^Detected (\d+) errors`)
+	imapsyncFailurePattern     = regexp.MustCompile(`(?i)could not|failure|^Err `)
+	imapsyncBracketPattern     = regexp.MustCompile(`\[([^\]]+)\]`)
+)
+
+// ensureImapsync installs imapsync when it is missing
+func ensureImapsync() error {
+	if _, err := exec.LookPath("imapsync"); err == nil {
+		return nil
+	}
+	fmt.Println("📦 Installing imapsync...")
+	if err := pkg.Install("imapsync"); err != nil {
+		return fmt.Errorf("could not install imapsync: %v", err)
+	}
+	return nil
+}
+
+// enableMigrationMaster configures the Dovecot master passdb and sets a
+// fresh random master password, which it returns
+func enableMigrationMaster() (string, error) {
+	conf := fmt.Sprintf(`# WebStack CLI - Master login for 'webstack mail migrate'
+# %s is only filled while a migration runs
+auth_master_user_separator = *
+
+passdb {
+  driver = passwd-file
+  args = scheme=%s %s
+  master = yes
+  pass = yes
+}
+`, dovecotMasterUsers, mailPasswordScheme, dovecotMasterUsers)
+	if current, _ := ioutil.ReadFile(dovecotMasterConf); string(current) != conf {
+		if err := dryrun.WriteFile(dovecotMasterConf, []byte(conf), 0644); err != nil {
+			return "", fmt.Errorf("could not write %s: %v", dovecotMasterConf, err)
+		}
+	}
+
+	password := generateRandomPassword(32)
+	hash, err := hashMailPassword(password)
+	if err != nil {
+		return "", err
+	}
+	if err := dryrun.WriteFile(dovecotMasterUsers, []byte(migrationMasterUser+":"+hash+"\n"), 0640); err != nil {
+		return "", fmt.Errorf("could not write %s: %v", dovecotMasterUsers, err)
+	}
+	runCommandQuiet("chown", "root:dovecot", dovecotMasterUsers)
+	if err := runCommandQuiet("systemctl", "reload", "dovecot"); err != nil {
+		return "", fmt.Errorf("could not reload Dovecot: %v", err)
+	}
+	return password, nil
+}
+
+// disableMigrationMaster empties the master users file again
+func disableMigrationMaster() {
+	if err := dryrun.WriteFile(dovecotMasterUsers, nil, 0640); err != nil {
+		ui.Warn("⚠️  Warning: Could not clear %s: %v\n", dovecotMasterUsers, err)
+	}
+	runCommandQuiet("doveadm", "auth", "cache", "flush")
+}
+
+// writePassfile writes a password to a private temporary file, keeping it
+// off the imapsync command line
+func writePassfile(password string) (string, error) {
+	f, err := ioutil.TempFile("", "webstack-imapsync-")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(password + "\n"); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// imapsyncSourceArgs returns the host, port and TLS options of the old server
+func imapsyncSourceArgs(m MailMigration) ([]string, error) {
+	host, port := m.Source, ""
+	if h, p, err := net.SplitHostPort(m.Source); err == nil {
+		host, port = h, p
+	}
+	var tls string
+	switch strings.ToLower(m.Security) {
+	case "", "ssl", "tls":
+		tls = "--ssl1"
+		if port == "" {
+			port = "993"
+		}
+	case "starttls":
+		tls = "--tls1"
+	case "none", "plain":
+		tls = "--notls1"
+	default:
+		return nil, fmt.Errorf("security must be ssl, starttls or none: %s", m.Security)
+	}
+	if port == "" {
+		port = "143"
+	}
+	return []string{"--host1", host, "--port1", port, tls}, nil
+}
+
+// runImapsync copies one mailbox, printing the folders as they are synced
+func runImapsync(m MailMigration, masterPassword string) migrationResult {
+	result := migrationResult{Dest: m.Dest}
+
+	sourceArgs, err := imapsyncSourceArgs(m)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	passfile1, err := writePassfile(m.Password)
+	if err != nil {
+		result.Err = fmt.Errorf("could not write password file: %v", err)
+		return result
+	}
+	defer os.Remove(passfile1)
+	passfile2, err := writePassfile(masterPassword)
+	if err != nil {
+		result.Err = fmt.Errorf("could not write password file: %v", err)
+		return result
+	}
+	defer os.Remove(passfile2)
+
+	dryrun.MkdirAll(imapsyncLogDir, 0700)
+	logName := fmt.Sprintf("%s-%s.log", m.Dest, time.Now().Format("20060102-150405"))
+	result.Log = filepath.Join(imapsyncLogDir, logName)
+
+	args := append(sourceArgs,
+		"--user1", m.User, "--passfile1", passfile1,
+		"--host2", "127.0.0.1", "--port2", "143",
+		"--user2", m.Dest+"*"+migrationMasterUser, "--passfile2", passfile2,
+		"--automap", "--nofoldersizes",
+		"--logdir", imapsyncLogDir, "--logfile", logName,
+	)
+	cmd := exec.Command("imapsync", args...)
+	if dryrun.Enabled() {
+		dryrun.Run(cmd)
+		return result
+	}
+
+	output, err := cmd.StdoutPipe()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		result.Err = fmt.Errorf("could not start imapsync: %v", err)
+		return result
+	}
+
+	folder := ""
+	scanner := bufio.NewScanner(output)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if match := imapsyncFolderPattern.FindStringSubmatch(line); match != nil {
+			folder = match[2]
+			fmt.Printf("   📁 [%s] %s\n", match[1], folder)
+			continue
+		}
+		if match := imapsyncTransferredPattern.FindStringSubmatch(line); match != nil {
+			result.Transferred, _ = strconv.Atoi(match[1])
+		} else if match := imapsyncFoldersPattern.FindStringSubmatch(line); match != nil {
+			result.FoldersSynced = match[1]
+		} else if match := imapsyncErrorsPattern.FindStringSubmatch(line); match != nil {
+			result.Errors, _ = strconv.Atoi(match[1])
+		} else if imapsyncFailurePattern.MatchString(line) {
+			failed := folder
+			if match := imapsyncBracketPattern.FindStringSubmatch(line); match != nil {
+				failed = match[1]
+			}
+			if failed != "" && !containsString(result.FailedFolders, failed) {
+				result.FailedFolders = append(result.FailedFolders, failed)
+				fmt.Printf("   ⚠️  %s: %s\n", failed, line)
+			}
+		}
+	}
+	io.Copy(ioutil.Discard, output)
+
+	if err := cmd.Wait(); err != nil {
+		result.Err = fmt.Errorf("imapsync failed: %v", err)
+	}
+	return result
+}
+
+// MigrateMailbox copies a mailbox from another IMAP server into a local
+// mail account, prompting for the source password when it is empty
+func MigrateMailbox(m MailMigration) {
+	if m.Password == "" {
+		fmt.Printf("Password of %s on %s: ", m.User, m.Source)
+		response, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		m.Password = strings.TrimRight(response, "\r\n")
+	}
+	migrateMailboxes([]MailMigration{m})
+}
+
+// MigrateMailboxesCSV copies the mailboxes listed in a CSV file with the
+// columns source,user,password,dest[,security]; a header line and lines
+// starting with # are skipped
+func MigrateMailboxesCSV(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		ui.Fail("❌ Could not open %s: %v\n", path, err)
+		return
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		ui.Invalid("Invalid CSV file: %v\n", err)
+		return
+	}
+
+	var migrations []MailMigration
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "source") {
+			continue
+		}
+		if len(record) < 4 {
+			ui.Invalid("Invalid CSV file: line %d needs source,user,password,dest[,security]\n", i+1)
+			return
+		}
+		m := MailMigration{
+			Source:   strings.TrimSpace(record[0]),
+			User:     strings.TrimSpace(record[1]),
+			Password: record[2],
+			Dest:     strings.TrimSpace(record[3]),
+		}
+		if len(record) > 4 {
+			m.Security = strings.TrimSpace(record[4])
+		}
+		migrations = append(migrations, m)
+	}
+	if len(migrations) == 0 {
+		ui.Fail("❌ No mailboxes listed in %s\n", path)
+		return
+	}
+	migrateMailboxes(migrations)
+}
+
+// migrateMailboxes checks the destination accounts, runs imapsync for each
+// mailbox with the master login enabled and prints a report
+func migrateMailboxes(migrations []MailMigration) {
+	migrateMailMaps()
+	users, err := ioutil.ReadFile(dovecotUsersFile)
+	if err != nil {
+		ui.Fail("❌ No mail accounts configured yet\n")
+		return
+	}
+	for i := range migrations {
+		m := &migrations[i]
+		m.Dest = normalizeMailAddress(m.Dest)
+		if m.Source == "" || m.User == "" {
+			ui.Invalid("Invalid migration: source host and user are required (%s)\n", m.Dest)
+			return
+		}
+		if m.Password == "" {
+			ui.Invalid("Invalid migration: no source password for %s\n", m.User)
+			return
+		}
+		if _, err := imapsyncSourceArgs(*m); err != nil {
+			ui.Invalid("Invalid migration: %v\n", err)
+			return
+		}
+		if !strings.Contains("\n"+string(users), "\n"+m.Dest+":") {
+			ui.Fail("❌ Mail account %s not found (create it with 'webstack mail add account %s')\n", m.Dest, m.Dest)
+			return
+		}
+	}
+
+	if err := ensureImapsync(); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	masterPassword, err := enableMigrationMaster()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	defer disableMigrationMaster()
+
+	var results []migrationResult
+	for i, m := range migrations {
+		fmt.Printf("📬 [%d/%d] %s@%s → %s\n", i+1, len(migrations), m.User, m.Source, m.Dest)
+		result := runImapsync(m, masterPassword)
+		if result.Err != nil {
+			fmt.Printf("   ❌ %v\n", result.Err)
+		}
+		results = append(results, result)
+	}
+
+	fmt.Println()
+	fmt.Println("📊 Mail Migration Report")
+	fmt.Println("========================")
+	fmt.Printf("  %-36s %12s %10s %7s  %s\n", "ACCOUNT", "TRANSFERRED", "FOLDERS", "ERRORS", "STATUS")
+	failed := 0
+	for _, r := range results {
+		status := "✅ ok"
+		if r.Err != nil || r.Errors > 0 || len(r.FailedFolders) > 0 {
+			status = "❌ failed"
+			failed++
+		}
+		folders := r.FoldersSynced
+		if folders == "" {
+			folders = "-"
+		}
+		fmt.Printf("  %-36s %12d %10s %7d  %s\n", r.Dest, r.Transferred, folders, r.Errors, status)
+		if len(r.FailedFolders) > 0 {
+			fmt.Printf("      Failed folders: %s\n", strings.Join(r.FailedFolders, ", "))
+		}
+		if r.Log != "" && (r.Err != nil || r.Errors > 0 || len(r.FailedFolders) > 0) {
+			fmt.Printf("      Log: %s\n", r.Log)
+		}
+	}
+
+	fmt.Println()
+	if failed > 0 {
+		ui.Warn("⚠️  %d of %d mailbox(es) had errors; running the migration again only copies missing messages\n", failed, len(results))
+		return
+	}
+	fmt.Printf("✅ %d mailbox(es) migrated\n", len(results))
+}
@@ -0,0 +1,100 @@
+package installer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/ui"
+)
+
+// normalizeMailAddress trims an address and normalizes its domain part the
+// same way domain names are normalized elsewhere (lowercase, no trailing dot)
+func normalizeMailAddress(email string) string {
+	email = strings.TrimSpace(email)
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		return email[:i+1] + domain.Normalize(email[i+1:])
+	}
+	return email
+}
+
+// mailMapKey normalizes the key of a Postfix map or Dovecot users line
+func mailMapKey(key string) string {
+	if strings.Contains(key, "@") {
+		return normalizeMailAddress(key)
+	}
+	return domain.Normalize(key)
+}
+
+// migrateMailMaps normalizes the keys of the virtual domain, mailbox and
+// Dovecot users files and drops entries that only differed by case or a
+// trailing dot. Older versions stored the names as typed.
+func migrateMailMaps() {
+	maps := []struct {
+		path    string
+		sep     string
+		postmap bool
+	}{
+		{"/etc/postfix/vdomains", "\t", true},
+		{"/etc/postfix/vmailbox", "\t", true},
+		{"/etc/dovecot/users", ":", false},
+	}
+
+	for _, m := range maps {
+		content, err := ioutil.ReadFile(m.path)
+		if err != nil {
+			continue
+		}
+
+		var lines []string
+		var dropped []string
+		seen := map[string]bool{}
+		changed := false
+		for _, line := range strings.Split(string(content), "\n") {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				lines = append(lines, line)
+				continue
+			}
+
+			key, rest := trimmed, ""
+			if m.sep == ":" {
+				if i := strings.Index(trimmed, ":"); i >= 0 {
+					key, rest = trimmed[:i], trimmed[i:]
+				}
+			} else if fields := strings.Fields(trimmed); len(fields) > 0 {
+				key, rest = fields[0], strings.TrimPrefix(trimmed, fields[0])
+			}
+
+			normalized := mailMapKey(key)
+			if seen[normalized] {
+				dropped = append(dropped, key)
+				changed = true
+				continue
+			}
+			seen[normalized] = true
+
+			if normalized != key {
+				line = normalized + rest
+				changed = true
+			}
+			lines = append(lines, line)
+		}
+
+		if !changed {
+			continue
+		}
+		if err := dryrun.WriteFile(m.path, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+			ui.Warn("⚠️  Warning: Could not normalize %s: %v\n", m.path, err)
+			continue
+		}
+		fmt.Printf("ℹ️  Normalized domain names in %s\n", m.path)
+		for _, key := range dropped {
+			fmt.Printf("   Removed duplicate entry %q\n", key)
+		}
+		if m.postmap {
+			runCommandQuiet("postmap", m.path)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package installer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/ui"
+)
+
+// dovecotUsersFile holds the virtual mail accounts, one
+// email:{SCHEME}hash:uid:gid::home:: line each
+const dovecotUsersFile = "/etc/dovecot/users"
+
+// mailPasswordScheme is the Dovecot scheme of the stored password hashes
+const mailPasswordScheme = "SHA512-CRYPT"
+
+// hashMailPassword returns a password as a Dovecot {SHA512-CRYPT} hash.
+// openssl and doveadm (the fallback on systems with an openssl lacking -6)
+// read the password from stdin so it never shows up in ps.
+func hashMailPassword(password string) (string, error) {
+	if password == "" || strings.ContainsAny(password, "\n\r:") {
+		return "", fmt.Errorf("passwords must not be empty or contain ':' or line breaks")
+	}
+
+	cmd := exec.Command("openssl", "passwd", "-6", "-stdin")
+	cmd.Stdin = strings.NewReader(password + "\n")
+	if hash, err := cmd.Output(); err == nil && strings.HasPrefix(string(hash), "$6$") {
+		return "{" + mailPasswordScheme + "}" + strings.TrimSpace(string(hash)), nil
+	}
+
+	// Without -p, doveadm asks for the password and its confirmation
+	cmd = exec.Command("doveadm", "pw", "-s", mailPasswordScheme)
+	cmd.Stdin = strings.NewReader(password + "\n" + password + "\n")
+	hash, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("could not hash the password with openssl or doveadm: %v", err)
+	}
+	return strings.TrimSpace(string(hash)), nil
+}
+
+// writeDovecotUsers writes the users file readable by Dovecot only, as it
+// holds the password hashes
+func writeDovecotUsers(lines []string) error {
+	content := strings.Join(lines, "\n")
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if err := dryrun.WriteFile(dovecotUsersFile, []byte(content), 0640); err != nil {
+		return err
+	}
+	runCommandQuiet("chown", "root:dovecot", dovecotUsersFile)
+	runCommandQuiet("chmod", "640", dovecotUsersFile)
+	return nil
+}
+
+// migrateMailPasswords replaces the {PLAIN} passwords older versions wrote
+// to the Dovecot users file with SHA512-CRYPT hashes
+func migrateMailPasswords() {
+	content, err := ioutil.ReadFile(dovecotUsersFile)
+	if err != nil || !strings.Contains(string(content), ":{PLAIN}") {
+		return
+	}
+
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	migrated := 0
+	for i, line := range lines {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) < 3 || strings.HasPrefix(strings.TrimSpace(line), "#") || !strings.HasPrefix(fields[1], "{PLAIN}") {
+			continue
+		}
+		hash, err := hashMailPassword(strings.TrimPrefix(fields[1], "{PLAIN}"))
+		if err != nil {
+			ui.Warn("⚠️  Warning: Could not hash the password of %s: %v\n", fields[0], err)
+			continue
+		}
+		lines[i] = fields[0] + ":" + hash + ":" + fields[2]
+		migrated++
+	}
+	if migrated == 0 {
+		return
+	}
+
+	if err := writeDovecotUsers(lines); err != nil {
+		ui.Warn("⚠️  Warning: Could not update %s: %v\n", dovecotUsersFile, err)
+		return
+	}
+	fmt.Printf("🔐 Hashed %d plain text mail password(s) in %s\n", migrated, dovecotUsersFile)
+}
+
+// SetMailPassword changes the password of a mail account, generating one
+// when password is empty
+func SetMailPassword(email, password string) {
+	email = normalizeMailAddress(email)
+	migrateMailMaps()
+	migrateMailPasswords()
+
+	content, err := ioutil.ReadFile(dovecotUsersFile)
+	if err != nil {
+		ui.Fail("❌ No mail accounts configured yet\n")
+		return
+	}
+
+	generated := false
+	if password == "" {
+		password = generateRandomPassword(20)
+		generated = true
+	}
+	hash, err := hashMailPassword(password)
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	found := false
+	for i, line := range lines {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) == 3 && fields[0] == email {
+			lines[i] = fields[0] + ":" + hash + ":" + fields[2]
+			found = true
+		}
+	}
+	if !found {
+		ui.Fail("❌ Mail account %s not found\n", email)
+		return
+	}
+
+	if err := writeDovecotUsers(lines); err != nil {
+		ui.Fail("❌ Error writing Dovecot users file: %v\n", err)
+		return
+	}
+	// Dovecot caches successful logins; flush so the old password stops working
+	runCommandQuiet("doveadm", "auth", "cache", "flush", email)
+
+	fmt.Printf("✅ Password of %s changed\n", email)
+	if generated {
+		fmt.Printf("   Password: %s\n", password)
+	}
+}
@@ -0,0 +1,286 @@
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"webstack-cli/internal/bytesize"
+	"webstack-cli/internal/ui"
+)
+
+// queuedMail is one message as printed by 'postqueue -j'
+type queuedMail struct {
+	QueueName   string `json:"queue_name"`
+	QueueID     string `json:"queue_id"`
+	ArrivalTime int64  `json:"arrival_time"`
+	MessageSize int64  `json:"message_size"`
+	Sender      string `json:"sender"`
+	Recipients  []struct {
+		Address     string `json:"address"`
+		DelayReason string `json:"delay_reason"`
+	} `json:"recipients"`
+}
+
+// MailQueueStatus summarizes the Postfix queue for 'webstack system status'
+type MailQueueStatus struct {
+	Installed    bool
+	Total        int
+	Deferred     int
+	Hold         int
+	OldestAge    time.Duration // Age of the oldest deferred message
+	OldestReason string        // Why the oldest deferred message is delayed
+}
+
+var queueIDPattern = regexp.MustCompile(`^[0-9A-Za-z]+$`)
+
+// readMailQueue returns the queued messages, oldest first
+func readMailQueue() ([]queuedMail, error) {
+	output, err := exec.Command("postqueue", "-j").Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not read the mail queue (is Postfix running?): %v", err)
+	}
+	var queue []queuedMail
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var mail queuedMail
+		if err := json.Unmarshal(scanner.Bytes(), &mail); err != nil {
+			continue
+		}
+		queue = append(queue, mail)
+	}
+	sort.Slice(queue, func(i, j int) bool { return queue[i].ArrivalTime < queue[j].ArrivalTime })
+	return queue, nil
+}
+
+// delayReason returns the first delay reason of a message
+func (m queuedMail) delayReason() string {
+	for _, r := range m.Recipients {
+		if r.DelayReason != "" {
+			return r.DelayReason
+		}
+	}
+	return ""
+}
+
+func (m queuedMail) age() time.Duration {
+	return time.Since(time.Unix(m.ArrivalTime, 0))
+}
+
+// FormatAge formats a duration as minutes, hours or days
+func FormatAge(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
+// GetMailQueueStatus counts the queued, deferred and held messages
+func GetMailQueueStatus() MailQueueStatus {
+	status := MailQueueStatus{}
+	if _, err := exec.LookPath("postqueue"); err != nil {
+		return status
+	}
+	status.Installed = true
+	queue, err := readMailQueue()
+	if err != nil {
+		return status
+	}
+	status.Total = len(queue)
+	for _, m := range queue {
+		switch m.QueueName {
+		case "deferred":
+			if status.Deferred == 0 {
+				status.OldestAge = m.age()
+				status.OldestReason = m.delayReason()
+			}
+			status.Deferred++
+		case "hold":
+			status.Hold++
+		}
+	}
+	return status
+}
+
+// ListMailQueue prints the queued messages, optionally of one queue
+// (active, deferred, hold or incoming)
+func ListMailQueue(queueName string) {
+	switch queueName {
+	case "", "active", "deferred", "hold", "incoming":
+	default:
+		ui.Invalid("Invalid queue: %s. Use active, deferred, hold or incoming\n", queueName)
+		return
+	}
+	queue, err := readMailQueue()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+
+	fmt.Println("📮 Mail Queue")
+	fmt.Println("=============")
+	counts := map[string]int{}
+	shown := 0
+	for _, m := range queue {
+		counts[m.QueueName]++
+		if queueName != "" && m.QueueName != queueName {
+			continue
+		}
+		if shown == 0 {
+			fmt.Printf("  %-14s %-9s %5s %10s  %s\n", "ID", "QUEUE", "AGE", "SIZE", "FROM → TO")
+		}
+		shown++
+		sender := m.Sender
+		if sender == "" {
+			sender = "<> (bounce)"
+		}
+		var recipients []string
+		for _, r := range m.Recipients {
+			recipients = append(recipients, r.Address)
+		}
+		fmt.Printf("  %-14s %-9s %5s %10s  %s → %s\n", m.QueueID, m.QueueName, FormatAge(m.age()), bytesize.Format(m.MessageSize), sender, strings.Join(recipients, ", "))
+		if reason := m.delayReason(); reason != "" {
+			fmt.Printf("  %-14s ↳ %s\n", "", reason)
+		}
+	}
+
+	if shown == 0 {
+		if queueName != "" {
+			fmt.Printf("✅ No messages in the %s queue\n", queueName)
+		} else {
+			fmt.Println("✅ The mail queue is empty")
+		}
+		return
+	}
+	fmt.Printf("\nTotal: %d message(s) (active %d, deferred %d, hold %d, incoming %d)\n",
+		len(queue), counts["active"], counts["deferred"], counts["hold"], counts["incoming"])
+	if counts["deferred"] > 0 && (queueName == "" || queueName == "deferred") {
+		fmt.Println("💡 Retry now with 'webstack mail queue flush'; Postfix returns messages deferred for 5 days to the sender")
+	}
+}
+
+// FlushMailQueue retries the delivery of all deferred messages, or of the
+// given ones
+func FlushMailQueue(ids []string) {
+	if len(ids) == 0 {
+		if err := runCommandQuiet("postqueue", "-f"); err != nil {
+			ui.Fail("❌ Could not flush the mail queue: %v\n", err)
+			return
+		}
+		fmt.Println("✅ Delivery of all queued messages scheduled")
+		return
+	}
+	if !checkQueueIDs(ids) {
+		return
+	}
+	for _, id := range ids {
+		if err := runCommandQuiet("postqueue", "-i", id); err != nil {
+			ui.Fail("❌ Could not flush %s: %v\n", id, err)
+			return
+		}
+	}
+	fmt.Printf("✅ Delivery of %d message(s) scheduled\n", len(ids))
+}
+
+// DeleteQueuedMail deletes queued messages; with all set it empties the
+// queue, or only queueName when given
+func DeleteQueuedMail(ids []string, all bool, queueName string) {
+	if all {
+		args := []string{"-d", "ALL"}
+		switch queueName {
+		case "":
+		case "active", "deferred", "hold", "incoming":
+			args = append(args, queueName)
+		default:
+			ui.Invalid("Invalid queue: %s. Use active, deferred, hold or incoming\n", queueName)
+			return
+		}
+		if err := runCommandQuiet("postsuper", args...); err != nil {
+			ui.Fail("❌ Could not delete the queued messages: %v\n", err)
+			return
+		}
+		if queueName != "" {
+			fmt.Printf("✅ Deleted all messages in the %s queue\n", queueName)
+		} else {
+			fmt.Println("✅ Deleted all queued messages")
+		}
+		return
+	}
+
+	if len(ids) == 0 {
+		ui.Invalid("Invalid arguments: give queue IDs (see 'webstack mail queue list') or --all\n")
+		return
+	}
+	if !checkQueueIDs(ids) {
+		return
+	}
+	args := []string{}
+	for _, id := range ids {
+		args = append(args, "-d", id)
+	}
+	if err := runCommandQuiet("postsuper", args...); err != nil {
+		ui.Fail("❌ Could not delete the messages: %v\n", err)
+		return
+	}
+	fmt.Printf("✅ Deleted %d message(s): %s\n", len(ids), strings.Join(ids, ", "))
+}
+
+// HoldQueuedMail puts messages on hold, where Postfix leaves them until
+// they are released, or releases them
+func HoldQueuedMail(ids []string, release bool) {
+	if !checkQueueIDs(ids) {
+		return
+	}
+	flag := "-h"
+	if release {
+		flag = "-H"
+	}
+	args := []string{}
+	for _, id := range ids {
+		args = append(args, flag, id)
+	}
+	if err := runCommandQuiet("postsuper", args...); err != nil {
+		ui.Fail("❌ Could not update the messages: %v\n", err)
+		return
+	}
+	if release {
+		fmt.Printf("✅ Released %d message(s) for delivery: %s\n", len(ids), strings.Join(ids, ", "))
+	} else {
+		fmt.Printf("✅ Put %d message(s) on hold: %s\n", len(ids), strings.Join(ids, ", "))
+		fmt.Println("   Release them with 'webstack mail queue release <id>'")
+	}
+}
+
+// checkQueueIDs validates queue IDs and checks that they are queued
+func checkQueueIDs(ids []string) bool {
+	queue, err := readMailQueue()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return false
+	}
+	queued := map[string]bool{}
+	for _, m := range queue {
+		queued[m.QueueID] = true
+	}
+	for _, id := range ids {
+		if !queueIDPattern.MatchString(id) {
+			ui.Invalid("Invalid queue ID: %s\n", id)
+			return false
+		}
+		if !queued[id] {
+			ui.Fail("❌ No queued message %s (see 'webstack mail queue list')\n", id)
+			return false
+		}
+	}
+	return true
+}
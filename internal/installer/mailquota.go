@@ -0,0 +1,298 @@
+package installer
+
+import (
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"webstack-cli/internal/bytesize"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/store"
+	"webstack-cli/internal/ui"
+)
+
+// mailQuotaConf enables the Dovecot quota plugin with Maildir++ quotas. It
+// sorts before 20-imap.conf and 20-lmtp.conf so their mail_plugins extend
+// the plugin list including quota, and LMTP rejects mail over the quota.
+const mailQuotaConf = "/etc/dovecot/conf.d/10-webstack-quota.conf"
+
+// MailQuotas holds the quotas set with 'webstack mail quota set'. Accounts
+// without their own quota get the quota of their domain.
+type MailQuotas struct {
+	Domains  map[string]string `json:"domains,omitempty"`  // Default quota of the accounts of a domain, e.g. "5G"
+	Accounts map[string]string `json:"accounts,omitempty"` // Quota of single accounts, e.g. "2G"
+}
+
+var mailQuotaStore = store.New("/etc/webstack/mail-quota.json", 1)
+
+// quotaRuleField is the userdb extra field in /etc/dovecot/users carrying
+// the quota of an account
+const quotaRuleField = "userdb_quota_rule=*:storage="
+
+// parseQuota validates a size like 500M, 2G or 1T and returns it in
+// Dovecot's notation; "" means no quota
+func parseQuota(size string) (string, error) {
+	size = strings.ToUpper(strings.TrimSpace(size))
+	switch size {
+	case "0", "NONE", "UNLIMITED", "OFF":
+		return "", nil
+	}
+	number := strings.TrimSuffix(size, "B")
+	unit := ""
+	if n := len(number); n > 0 && strings.ContainsAny(number[n-1:], "KMGT") {
+		number, unit = number[:n-1], number[n-1:]
+	}
+	value, err := strconv.ParseUint(number, 10, 64)
+	if err != nil || value == 0 || unit == "" {
+		return "", fmt.Errorf("%s is not a size like 500M, 2G or 1T (or none)", size)
+	}
+	return fmt.Sprintf("%d%s", value, unit), nil
+}
+
+// quotaBytes returns the bytes of a quota in Dovecot's notation
+func quotaBytes(quota string) int64 {
+	if quota == "" {
+		return 0
+	}
+	value, _ := strconv.ParseInt(quota[:len(quota)-1], 10, 64)
+	return value << (10 * (strings.Index("KMGT", quota[len(quota)-1:]) + 1))
+}
+
+// effectiveQuota returns the quota applying to an account
+func (q MailQuotas) effectiveQuota(email string) string {
+	if quota := q.Accounts[email]; quota != "" {
+		return quota
+	}
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		return q.Domains[email[i+1:]]
+	}
+	return ""
+}
+
+func loadMailQuotas() MailQuotas {
+	var q MailQuotas
+	if err := mailQuotaStore.Load(&q); err != nil {
+		ui.Warn("⚠️  Warning: Could not read mail quotas: %v\n", err)
+	}
+	return q
+}
+
+// writeMailQuotaConf writes the Dovecot quota configuration. Accounts
+// without a quota rule are unlimited.
+func writeMailQuotaConf() error {
+	if _, err := os.Stat("/etc/dovecot/conf.d"); err != nil {
+		return fmt.Errorf("Dovecot is not installed (run 'webstack install mail')")
+	}
+	conf := `# WebStack CLI - Mail quotas (managed with 'webstack mail quota')
+# Per-account limits are userdb_quota_rule fields in /etc/dovecot/users
+mail_plugins = $mail_plugins quota
+
+protocol imap {
+  mail_plugins = $mail_plugins imap_quota
+}
+
+plugin {
+  quota = maildir:User quota
+  quota_rule = *:storage=0
+  quota_rule2 = Trash:storage=+10%
+  quota_grace = 10%
+  quota_exceeded_message = Mailbox is full: the quota of this account is exceeded.
+}
+`
+	current, _ := ioutil.ReadFile(mailQuotaConf)
+	if string(current) == conf {
+		return nil
+	}
+	return dryrun.WriteFile(mailQuotaConf, []byte(conf), 0644)
+}
+
+// applyMailQuotas writes the quota of every account into its userdb extra
+// fields in /etc/dovecot/users
+func applyMailQuotas(q MailQuotas) error {
+	content, err := ioutil.ReadFile(dovecotUsersFile)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		// email:password:uid:gid:gecos:home:shell:extra fields
+		fields := strings.SplitN(line, ":", 8)
+		for len(fields) < 8 {
+			fields = append(fields, "")
+		}
+		var extra []string
+		for _, field := range strings.Fields(fields[7]) {
+			if !strings.HasPrefix(field, quotaRuleField) {
+				extra = append(extra, field)
+			}
+		}
+		if quota := q.effectiveQuota(fields[0]); quota != "" {
+			extra = append(extra, quotaRuleField+quota)
+		}
+		fields[7] = strings.Join(extra, " ")
+		lines[i] = strings.Join(fields, ":")
+	}
+	return writeDovecotUsers(lines)
+}
+
+// SetMailQuota sets the quota of an account (user@domain) or the default
+// quota of a domain's accounts; "none" removes it
+func SetMailQuota(target, size string) {
+	target = mailMapKey(target)
+	migrateMailMaps()
+
+	quota, err := parseQuota(size)
+	if err != nil {
+		ui.Invalid("Invalid quota: %v\n", err)
+		return
+	}
+
+	isAccount := strings.Contains(target, "@")
+	if isAccount {
+		users, _ := ioutil.ReadFile(dovecotUsersFile)
+		if !strings.Contains("\n"+string(users), "\n"+target+":") {
+			ui.Fail("❌ Mail account %s not found\n", target)
+			return
+		}
+	} else {
+		domains, _ := ioutil.ReadFile("/etc/postfix/vdomains")
+		if !strings.Contains("\n"+string(domains), "\n"+target+"\t") {
+			ui.Fail("❌ Mail domain %s not found\n", target)
+			return
+		}
+	}
+
+	if err := writeMailQuotaConf(); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+
+	var q MailQuotas
+	err = mailQuotaStore.Update(&q, func() error {
+		entries := &q.Domains
+		if isAccount {
+			entries = &q.Accounts
+		}
+		if *entries == nil {
+			*entries = map[string]string{}
+		}
+		if quota == "" {
+			delete(*entries, target)
+		} else {
+			(*entries)[target] = quota
+		}
+		return applyMailQuotas(q)
+	})
+	if err != nil {
+		ui.Fail("❌ Could not save mail quota: %v\n", err)
+		return
+	}
+	runCommandQuiet("systemctl", "reload", "dovecot")
+
+	switch {
+	case quota == "" && isAccount:
+		fmt.Printf("✅ Quota of %s removed", target)
+		if fallback := q.effectiveQuota(target); fallback != "" {
+			fmt.Printf(" (domain quota of %s applies)", fallback)
+		}
+		fmt.Println()
+	case quota == "":
+		fmt.Printf("✅ Default quota of %s removed\n", target)
+	case isAccount:
+		fmt.Printf("✅ Quota of %s set to %s\n", target, quota)
+	default:
+		fmt.Printf("✅ Default quota of the accounts of %s set to %s\n", target, quota)
+		fmt.Println("   Accounts with their own quota keep it")
+	}
+}
+
+// MailQuotaReport shows the mailbox usage and quota of every account and
+// the totals of each domain
+func MailQuotaReport() {
+	migrateMailMaps()
+	content, err := ioutil.ReadFile(dovecotUsersFile)
+	if err != nil {
+		ui.Fail("❌ No mail accounts configured yet\n")
+		return
+	}
+	q := loadMailQuotas()
+
+	type domainTotal struct {
+		accounts int
+		used     int64
+	}
+	totals := map[string]*domainTotal{}
+
+	fmt.Println("📊 Mail Quota Report")
+	fmt.Println("====================")
+	fmt.Printf("  %-36s %12s %12s %6s\n", "ACCOUNT", "USED", "QUOTA", "USE%")
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, ":", 8)
+		email := fields[0]
+		home := ""
+		if len(fields) > 5 {
+			home = fields[5]
+		}
+		used := dirSize(home)
+
+		quota := q.effectiveQuota(email)
+		limit, percent := "unlimited", "-"
+		if quota != "" {
+			limit = quota
+			percent = fmt.Sprintf("%d%%", used*100/quotaBytes(quota))
+		}
+		fmt.Printf("  %-36s %12s %12s %6s\n", email, bytesize.Format(used), limit, percent)
+
+		domain := email[strings.LastIndex(email, "@")+1:]
+		if totals[domain] == nil {
+			totals[domain] = &domainTotal{}
+		}
+		totals[domain].accounts++
+		totals[domain].used += used
+	}
+
+	domains := make([]string, 0, len(totals))
+	for domain := range totals {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	fmt.Println()
+	fmt.Printf("  %-36s %12s %12s %8s\n", "DOMAIN", "USED", "DEFAULT", "ACCOUNTS")
+	for _, domain := range domains {
+		quota := q.Domains[domain]
+		if quota == "" {
+			quota = "unlimited"
+		}
+		fmt.Printf("  %-36s %12s %12s %8d\n", domain, bytesize.Format(totals[domain].used), quota, totals[domain].accounts)
+	}
+}
+
+// dirSize returns the size of the files below a mailbox
+func dirSize(dir string) int64 {
+	var size int64
+	if dir == "" {
+		return 0
+	}
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.Type().IsRegular() {
+			if info, err := entry.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
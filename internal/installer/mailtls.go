@@ -0,0 +1,128 @@
+package installer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"regexp"
+	"strings"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/ui"
+)
+
+// dovecotSSLConf points Dovecot to the mail certificate. It sorts after
+// Debian's 10-ssl.conf, whose snakeoil certificate it overrides.
+const dovecotSSLConf = "/etc/dovecot/conf.d/99-webstack-ssl.conf"
+
+// smtpsService is the master.cf entry of SMTP over implicit TLS (port 465)
+const smtpsService = `smtps     inet  n       -       y       -       -       smtpd
+  -o syslog_name=postfix/smtps
+  -o smtpd_tls_wrappermode=yes
+  -o smtpd_recipient_restrictions=permit_mynetworks,permit_sasl_authenticated,reject_unauth_destination
+  -o smtpd_relay_restrictions=permit_sasl_authenticated,reject
+  -o smtpd_sasl_auth_enable=yes
+  -o smtpd_sasl_type=dovecot
+  -o smtpd_sasl_path=private/auth
+`
+
+// ConfigureMailTLS serves a certificate on SMTP, submission, IMAP and POP3.
+// Postfix offers STARTTLS on port 25 and requires TLS for logins; the
+// submission port requires STARTTLS and port 465 uses implicit TLS.
+func ConfigureMailTLS(host, certPath, keyPath string) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	hasPostfix := isPackageInstalled("postfix")
+	hasDovecot := isPackageInstalled("dovecot-core")
+	if !hasPostfix && !hasDovecot {
+		ui.Fail("❌ The mail server is not installed (run 'webstack install mail')\n")
+		return
+	}
+
+	if hasPostfix {
+		fmt.Println("⚙️  Configuring TLS in Postfix...")
+		settings := []string{
+			"smtpd_tls_cert_file=" + certPath,
+			"smtpd_tls_key_file=" + keyPath,
+			"smtpd_tls_security_level=may",
+			"smtpd_tls_auth_only=yes",
+			"smtpd_tls_mandatory_protocols=!SSLv2,!SSLv3,!TLSv1,!TLSv1.1",
+			"smtpd_tls_protocols=!SSLv2,!SSLv3",
+			"smtpd_tls_session_cache_database=btree:${data_directory}/smtpd_scache",
+			"smtp_tls_security_level=may",
+			"smtp_tls_session_cache_database=btree:${data_directory}/smtp_scache",
+		}
+		for _, setting := range settings {
+			if err := runCommandQuiet("postconf", "-e", setting); err != nil {
+				ui.Fail("❌ Could not set %s: %v\n", setting, err)
+				return
+			}
+		}
+		if err := configureMasterTLS(); err != nil {
+			ui.Warn("⚠️  Warning: Could not update master.cf: %v\n", err)
+		}
+		if err := runCommandQuiet("postfix", "reload"); err != nil {
+			ui.Warn("⚠️  Warning: Could not reload Postfix: %v\n", err)
+		}
+	}
+
+	if hasDovecot {
+		fmt.Println("⚙️  Configuring TLS in Dovecot...")
+		conf := fmt.Sprintf(`# WebStack CLI - Mail TLS certificate for %s (managed with 'webstack mail ssl')
+ssl = yes
+ssl_cert = <%s
+ssl_key = <%s
+ssl_min_protocol = TLSv1.2
+`, host, certPath, keyPath)
+		if err := dryrun.WriteFile(dovecotSSLConf, []byte(conf), 0644); err != nil {
+			ui.Fail("❌ Could not write %s: %v\n", dovecotSSLConf, err)
+			return
+		}
+		if err := runCommandQuiet("systemctl", "restart", "dovecot"); err != nil {
+			ui.Warn("⚠️  Warning: Could not restart Dovecot: %v\n", err)
+		}
+	}
+
+	fmt.Printf("✅ Mail TLS enabled for %s\n", host)
+	fmt.Printf("   Certificate: %s\n", certPath)
+	fmt.Printf("   Private Key: %s\n", keyPath)
+	fmt.Println("   SMTP:        25 (STARTTLS), 587 (STARTTLS required), 465 (TLS)")
+	fmt.Println("   IMAP/POP3:   993 and 995 (TLS), 143 and 110 (STARTTLS)")
+	fmt.Println("   Renewals reload Postfix and Dovecot automatically")
+
+	if hasPostfix {
+		if out, err := exec.Command("postconf", "-h", "myhostname").Output(); err == nil {
+			if name := strings.TrimSpace(string(out)); name != host {
+				ui.Warn("⚠️  Postfix announces itself as %s; to match the certificate run:\n", name)
+				fmt.Printf("   sudo postconf -e myhostname=%s && sudo postfix reload\n", host)
+			}
+		}
+	}
+}
+
+// configureMasterTLS requires STARTTLS on the submission port and adds the
+// smtps service on port 465
+func configureMasterTLS() error {
+	masterCfPath := "/etc/postfix/master.cf"
+	content, err := ioutil.ReadFile(masterCfPath)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(content), "\n")
+	inSubmission := false
+	for i, line := range lines {
+		if line != "" && line[0] != ' ' && line[0] != '\t' {
+			inSubmission = strings.HasPrefix(line, "submission ")
+			continue
+		}
+		if inSubmission && strings.TrimSpace(line) == "-o smtpd_tls_security_level=may" {
+			lines[i] = strings.Replace(line, "=may", "=encrypt", 1)
+		}
+	}
+	master := strings.Join(lines, "\n")
+	if !regexp.MustCompile(`(?m)^smtps\s+inet`).MatchString(master) {
+		master = strings.TrimRight(master, "\n") + "\n\n" + smtpsService
+	}
+	if master == string(content) {
+		return nil
+	}
+	return dryrun.WriteFile(masterCfPath, []byte(master), 0644)
+}
@@ -0,0 +1,233 @@
+package installer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"strings"
+	"webstack-cli/internal/ui"
+)
+
+// mailDNSExpectation is a record from /etc/postfix/dns-records/<domain>.txt
+type mailDNSExpectation struct {
+	Name  string
+	Value string
+}
+
+var (
+	dnsRecordHeaderPattern = regexp.MustCompile(`^(\w+) Record`)
+	spfIPPattern           = regexp.MustCompile(`ip4:([0-9.]+)`)
+	dmarcPolicyPattern     = regexp.MustCompile(`(?:^|;)\s*p=(\w+)`)
+	dkimKeyPattern         = regexp.MustCompile(`(?:^|;)\s*p=([A-Za-z0-9+/=]*)`)
+)
+
+// loadMailDNSExpectations parses the records generated for a mail domain,
+// keyed by SPF, DKIM and DMARC
+func loadMailDNSExpectations(domain string) (map[string]mailDNSExpectation, error) {
+	content, err := ioutil.ReadFile(fmt.Sprintf("/etc/postfix/dns-records/%s.txt", domain))
+	if err != nil {
+		return nil, err
+	}
+	records := map[string]mailDNSExpectation{}
+	kind := ""
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if match := dnsRecordHeaderPattern.FindStringSubmatch(line); match != nil {
+			kind = match[1]
+			continue
+		}
+		if kind == "" {
+			continue
+		}
+		record := records[kind]
+		if strings.HasPrefix(trimmed, "Name:") {
+			record.Name = strings.TrimSpace(strings.TrimPrefix(trimmed, "Name:"))
+		} else if strings.HasPrefix(trimmed, "Value:") {
+			record.Value = strings.TrimSpace(strings.TrimPrefix(trimmed, "Value:"))
+		}
+		records[kind] = record
+	}
+	return records, nil
+}
+
+// lookupTXTPrefix returns the TXT records of a name starting with prefix
+func lookupTXTPrefix(name, prefix string) []string {
+	txts, _ := net.LookupTXT(name)
+	var found []string
+	for _, txt := range txts {
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(txt)), strings.ToLower(prefix)) {
+			found = append(found, strings.TrimSpace(txt))
+		}
+	}
+	return found
+}
+
+// resolvesTo reports whether a host name has the address ip
+func resolvesTo(host, ip string) bool {
+	addrs, err := net.LookupHost(strings.TrimSuffix(host, "."))
+	if err != nil {
+		return false
+	}
+	return containsString(addrs, ip)
+}
+
+// VerifyMailDNS checks the live MX, SPF, DKIM and DMARC records of a mail
+// domain against the generated ones and the reverse DNS of the server
+func VerifyMailDNS(domain string) {
+	domain = mailMapKey(domain)
+
+	expected, err := loadMailDNSExpectations(domain)
+	if err != nil {
+		ui.Fail("❌ No DNS records generated for %s\n", domain)
+		fmt.Printf("💡 Add the domain first: webstack mail add domain %s\n", domain)
+		return
+	}
+
+	// The SPF record names the public address the records were made for,
+	// which differs from the local one behind NAT
+	serverIP := getServerIP()
+	if match := spfIPPattern.FindStringSubmatch(expected["SPF"].Value); match != nil {
+		serverIP = match[1]
+	}
+
+	fmt.Printf("🔍 Verifying mail DNS for %s (server %s)\n", domain, serverIP)
+	fmt.Println()
+	passed, failed, warned := 0, 0, 0
+	pass := func(check, format string, args ...interface{}) {
+		fmt.Printf("  ✅ %-6s %s\n", check, fmt.Sprintf(format, args...))
+		passed++
+	}
+	fail := func(check, format string, args ...interface{}) {
+		fmt.Printf("  ❌ %-6s %s\n", check, fmt.Sprintf(format, args...))
+		failed++
+	}
+	warn := func(check, format string, args ...interface{}) {
+		fmt.Printf("  ⚠️  %-6s %s\n", check, fmt.Sprintf(format, args...))
+		warned++
+	}
+	hint := func(format string, args ...interface{}) {
+		fmt.Printf("            %s\n", fmt.Sprintf(format, args...))
+	}
+
+	// MX: one of the mail exchangers must be this server
+	mxs, err := net.LookupMX(domain)
+	if err != nil || len(mxs) == 0 {
+		fail("MX", "no MX record for %s", domain)
+		hint("Add: %s MX 10 mail.%s (with an A record pointing to %s)", domain, domain, serverIP)
+	} else {
+		var hosts []string
+		ours := ""
+		for _, mx := range mxs {
+			host := strings.TrimSuffix(mx.Host, ".")
+			hosts = append(hosts, fmt.Sprintf("%s (%d)", host, mx.Pref))
+			if ours == "" && resolvesTo(host, serverIP) {
+				ours = host
+			}
+		}
+		if ours != "" {
+			pass("MX", "%s → %s", ours, serverIP)
+		} else {
+			fail("MX", "%s: none resolves to %s", strings.Join(hosts, ", "), serverIP)
+		}
+	}
+
+	// SPF: exactly one record, authorizing this server
+	spfExpected := expected["SPF"].Value
+	spfs := lookupTXTPrefix(domain, "v=spf1")
+	switch {
+	case len(spfs) == 0:
+		fail("SPF", "no SPF record for %s", domain)
+		hint("Add TXT: %s", spfExpected)
+	case len(spfs) > 1:
+		fail("SPF", "%d SPF records, receivers reject all of them; merge them into one", len(spfs))
+	case spfs[0] == spfExpected:
+		pass("SPF", "%s", spfs[0])
+	case strings.Contains(spfs[0], "ip4:"+serverIP+" ") || strings.HasSuffix(spfs[0], "ip4:"+serverIP):
+		pass("SPF", "%s (authorizes %s)", spfs[0], serverIP)
+	default:
+		fail("SPF", "%s does not list ip4:%s", spfs[0], serverIP)
+		hint("Expected: %s", spfExpected)
+	}
+
+	// DKIM: the published key must be the one OpenDKIM signs with
+	dkimName := expected["DKIM"].Name
+	if dkimName == "" {
+		dkimName = dkimSelector + "._domainkey." + domain
+	}
+	dkims := lookupTXTPrefix(dkimName, "v=DKIM1")
+	expectedKey := ""
+	if match := dkimKeyPattern.FindStringSubmatch(expected["DKIM"].Value); match != nil {
+		expectedKey = match[1]
+	}
+	switch {
+	case len(dkims) == 0:
+		fail("DKIM", "no DKIM record at %s", dkimName)
+		hint("Add TXT: %s (see 'webstack mail dns show %s')", dkimName, domain)
+	case expectedKey == "":
+		warn("DKIM", "%s is published, but no key was generated to compare with", dkimName)
+	default:
+		published := ""
+		if match := dkimKeyPattern.FindStringSubmatch(strings.Join(strings.Fields(dkims[0]), "")); match != nil {
+			published = match[1]
+		}
+		if published == expectedKey {
+			pass("DKIM", "%s matches the signing key", dkimName)
+		} else {
+			fail("DKIM", "%s publishes a different key than the signing key", dkimName)
+			hint("Republish the value from 'webstack mail dns show %s'", domain)
+		}
+	}
+
+	// DMARC: a policy must exist; p=none only monitors
+	dmarcName := "_dmarc." + domain
+	dmarcs := lookupTXTPrefix(dmarcName, "v=DMARC1")
+	switch {
+	case len(dmarcs) == 0:
+		fail("DMARC", "no DMARC record at %s", dmarcName)
+		hint("Add TXT: %s", expected["DMARC"].Value)
+	case len(dmarcs) > 1:
+		fail("DMARC", "%d DMARC records at %s; keep one", len(dmarcs), dmarcName)
+	default:
+		policy := ""
+		if match := dmarcPolicyPattern.FindStringSubmatch(dmarcs[0]); match != nil {
+			policy = strings.ToLower(match[1])
+		}
+		switch policy {
+		case "quarantine", "reject":
+			pass("DMARC", "%s", dmarcs[0])
+		case "none":
+			warn("DMARC", "p=none only monitors; use p=quarantine once reports look clean")
+		default:
+			fail("DMARC", "%s has no valid policy (p=)", dmarcs[0])
+		}
+	}
+
+	// PTR: the server address must resolve back to a name resolving to it
+	names, err := net.LookupAddr(serverIP)
+	if err != nil || len(names) == 0 {
+		fail("PTR", "%s has no reverse DNS; many receivers reject its mail", serverIP)
+		hint("Ask your hosting provider to set the PTR of %s to your mail host name", serverIP)
+	} else {
+		confirmed := ""
+		for _, name := range names {
+			if resolvesTo(name, serverIP) {
+				confirmed = strings.TrimSuffix(name, ".")
+				break
+			}
+		}
+		if confirmed != "" {
+			pass("PTR", "%s → %s (forward-confirmed)", serverIP, confirmed)
+		} else {
+			warn("PTR", "%s → %s, which does not resolve back to %s", serverIP, strings.TrimSuffix(names[0], "."), serverIP)
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("Summary: %d passed, %d warning(s), %d failed\n", passed, warned, failed)
+	if failed == 0 && warned == 0 {
+		fmt.Printf("✅ %s is ready to send mail\n", domain)
+	} else if failed > 0 {
+		fmt.Println("💡 DNS changes can take up to the record TTL to show up; run the check again afterwards")
+	}
+}
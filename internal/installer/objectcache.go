@@ -0,0 +1,317 @@
+package installer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/pkg"
+	"webstack-cli/internal/ui"
+)
+
+const (
+	redisConfigFile     = "/etc/redis/redis.conf"
+	memcachedConfigFile = "/etc/memcached.conf"
+	// memcachedDropIn has systemd create /run/memcached for the socket
+	memcachedDropIn = "/etc/systemd/system/memcached.service.d/webstack.conf"
+
+	// RedisSocket and MemcachedSocket are the unix sockets PHP connects to.
+	// The www-data group PHP-FPM runs as may read and write them.
+	RedisSocket     = "/run/redis/redis-server.sock"
+	MemcachedSocket = "/run/memcached/memcached.sock"
+)
+
+// ObjectCacheOptions controls a Redis or Memcached install
+type ObjectCacheOptions struct {
+	MemoryMB int // Memory limit in megabytes; 0 uses the default
+}
+
+// ObjectCacheStatus describes an installed Redis or Memcached server for
+// 'system status'
+type ObjectCacheStatus struct {
+	Name      string
+	Installed bool
+	Running   bool
+	Socket    string // Empty when the server only listens on TCP
+	Memory    string // Configured memory limit, e.g. "256mb"
+}
+
+// objectCache describes how one object cache server is installed
+type objectCache struct {
+	key           string // Registry key
+	defaultMemory int    // Memory limit in megabytes when none is given
+	group         string // Group owning the socket, www-data is added to it
+	configure     func(memoryMB int) error
+}
+
+var objectCaches = map[string]objectCache{
+	"redis": {
+		key:           "redis",
+		defaultMemory: 256,
+		group:         "redis",
+		configure:     configureRedis,
+	},
+	"memcached": {
+		key:           "memcached",
+		defaultMemory: 64,
+		group:         "memcache",
+		configure:     configureMemcached,
+	},
+}
+
+// InstallRedis installs Redis as an object cache listening on a unix socket
+func InstallRedis(opts ObjectCacheOptions) {
+	installObjectCache(objectCaches["redis"], opts)
+}
+
+// InstallMemcached installs Memcached as an object cache listening on a unix
+// socket
+func InstallMemcached(opts ObjectCacheOptions) {
+	installObjectCache(objectCaches["memcached"], opts)
+}
+
+func installObjectCache(cache objectCache, opts ObjectCacheOptions) {
+	component := components[cache.key]
+	fmt.Printf("📦 Installing %s...\n", component.Name)
+
+	if opts.MemoryMB < 0 {
+		ui.Invalid("Invalid memory limit: %d MB\n", opts.MemoryMB)
+		return
+	}
+	memory := opts.MemoryMB
+	if memory == 0 {
+		memory = cache.defaultMemory
+	}
+
+	if checkComponentStatus(component) == Installed {
+		action := promptForAction(component.Name)
+		switch action {
+		case "keep":
+			// Keeping the package still applies a new memory limit
+			if opts.MemoryMB == 0 {
+				fmt.Printf("✅ Keeping existing %s installation\n", component.Name)
+				return
+			}
+			applyObjectCacheConfig(cache, memory)
+			return
+		case "skip":
+			fmt.Printf("⏭️  Skipping %s installation\n", component.Name)
+			return
+		case "uninstall":
+			removeObjectCache(cache)
+			return
+		case "reinstall":
+			fmt.Printf("🔄 Reinstalling %s...\n", component.Name)
+			if err := uninstallComponent(component); err != nil {
+				ui.Fail("Error uninstalling %s: %v\n", component.Name, err)
+				return
+			}
+		}
+	}
+
+	if err := pkg.Update(); err != nil {
+		ui.Fail("Error updating package list: %v\n", err)
+		return
+	}
+	if err := pkg.Install(component.PackageName); err != nil {
+		ui.Fail("❌ Error installing %s: %v\n", component.Name, err)
+		return
+	}
+
+	if !applyObjectCacheConfig(cache, memory) {
+		return
+	}
+
+	if err := UpdateServerConfig(cache.key, true, 0, "socket"); err != nil {
+		ui.Warn("⚠️  Warning: Could not update config: %v\n", err)
+	}
+
+	fmt.Printf("✅ %s installed successfully\n", component.Name)
+	fmt.Printf("💡 PHP connects through %s; the redis and memcached extensions come with every PHP version\n", objectCacheSocket(cache.key))
+}
+
+// applyObjectCacheConfig writes the memory limit and socket settings and
+// restarts the server. It returns false when the server did not start.
+func applyObjectCacheConfig(cache objectCache, memoryMB int) bool {
+	name := components[cache.key].Name
+	service := components[cache.key].ServiceName
+
+	fmt.Printf("⚙️  Configuring %s (%d MB, socket %s)...\n", name, memoryMB, objectCacheSocket(cache.key))
+	if err := cache.configure(memoryMB); err != nil {
+		ui.Fail("❌ Error configuring %s: %v\n", name, err)
+		return false
+	}
+
+	// PHP-FPM runs the pools as www-data, which needs the server's group to
+	// use the socket
+	if err := runCommand("usermod", "-aG", cache.group, "www-data"); err != nil {
+		ui.Warn("⚠️  Warning: Could not add www-data to the %s group: %v\n", cache.group, err)
+	}
+
+	if err := runCommand("systemctl", "enable", service); err != nil {
+		ui.Fail("Error enabling %s: %v\n", name, err)
+	}
+	if err := runCommand("systemctl", "restart", service); err != nil {
+		ui.Fail("❌ Error starting %s: %v\n", name, err)
+		fmt.Printf("   View logs: sudo journalctl -xeu %s.service\n", service)
+		return false
+	}
+
+	// Workers only pick up the new group membership when they are restarted
+	for _, version := range supportedPHPVersions {
+		if checkPHPVersion(version) == Installed {
+			runCommandQuiet("systemctl", "restart", config.GetPHPServiceName(version))
+		}
+	}
+	return true
+}
+
+// configureRedis sets the memory limit, an LRU eviction policy suited to an
+// object cache and the unix socket in redis.conf. Redis keeps listening on
+// 127.0.0.1:6379 for clients that do not support sockets.
+func configureRedis(memoryMB int) error {
+	return updateDirectives(redisConfigFile, [][2]string{
+		{"maxmemory", fmt.Sprintf("%dmb", memoryMB)},
+		{"maxmemory-policy", "allkeys-lru"},
+		{"unixsocket", RedisSocket},
+		{"unixsocketperm", "770"},
+	})
+}
+
+// configureMemcached sets the memory limit and the unix socket in
+// memcached.conf. With a socket Memcached no longer listens on TCP.
+func configureMemcached(memoryMB int) error {
+	dropIn := "# Managed by WebStack CLI\n[Service]\nRuntimeDirectory=memcached\nRuntimeDirectoryMode=0755\n"
+	if err := dryrun.MkdirAll("/etc/systemd/system/memcached.service.d", 0755); err != nil {
+		return fmt.Errorf("could not create systemd drop-in directory: %v", err)
+	}
+	if err := dryrun.WriteFile(memcachedDropIn, []byte(dropIn), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", memcachedDropIn, err)
+	}
+	runCommandQuiet("systemctl", "daemon-reload")
+
+	return updateDirectives(memcachedConfigFile, [][2]string{
+		{"-m", fmt.Sprintf("%d", memoryMB)},
+		{"-s", MemcachedSocket},
+		{"-a", "0770"},
+	})
+}
+
+// updateDirectives sets "key value" lines in a config file, replacing the
+// first active or commented-out line of each key and appending missing ones
+func updateDirectives(path string, directives [][2]string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %v", path, err)
+	}
+
+	content := string(data)
+	for _, directive := range directives {
+		line := directive[0] + " " + directive[1]
+		pattern := regexp.MustCompile(`(?m)^#?[ \t]*` + regexp.QuoteMeta(directive[0]) + `[ \t].*$`)
+		if loc := pattern.FindStringIndex(content); loc != nil {
+			content = content[:loc[0]] + line + content[loc[1]:]
+			continue
+		}
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += line + "\n"
+	}
+
+	if err := dryrun.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", path, err)
+	}
+	return nil
+}
+
+// UninstallRedis removes Redis
+func UninstallRedis() {
+	uninstallObjectCache(objectCaches["redis"])
+}
+
+// UninstallMemcached removes Memcached
+func UninstallMemcached() {
+	uninstallObjectCache(objectCaches["memcached"])
+}
+
+func uninstallObjectCache(cache objectCache) {
+	component := components[cache.key]
+	if checkComponentStatus(component) != Installed {
+		fmt.Printf("ℹ️  %s is not installed\n", component.Name)
+		return
+	}
+
+	if !confirmRemoval("uninstall "+component.Name, fmt.Sprintf("Uninstall %s?", component.Name)) {
+		fmt.Printf("⏭️  Skipping %s uninstall\n", component.Name)
+		return
+	}
+	removeObjectCache(cache)
+}
+
+func removeObjectCache(cache objectCache) {
+	component := components[cache.key]
+	if err := uninstallComponent(component); err != nil {
+		ui.Fail("❌ Error uninstalling %s: %v\n", component.Name, err)
+		return
+	}
+	if cache.key == "memcached" {
+		dryrun.RemoveAll("/etc/systemd/system/memcached.service.d")
+		runCommandQuiet("systemctl", "daemon-reload")
+	}
+
+	if err := UpdateServerConfig(cache.key, false, 0, ""); err != nil {
+		ui.Warn("⚠️  Warning: Could not update config: %v\n", err)
+	}
+	fmt.Printf("✅ %s uninstalled successfully\n", component.Name)
+}
+
+func objectCacheSocket(key string) string {
+	if key == "redis" {
+		return RedisSocket
+	}
+	return MemcachedSocket
+}
+
+// GetObjectCacheStatus returns the state of Redis and Memcached
+func GetObjectCacheStatus() []ObjectCacheStatus {
+	var statuses []ObjectCacheStatus
+	for _, key := range []string{"redis", "memcached"} {
+		component := components[key]
+		status := ObjectCacheStatus{Name: component.Name}
+		if checkComponentStatus(component) != Installed {
+			statuses = append(statuses, status)
+			continue
+		}
+
+		status.Installed = true
+		status.Running = isServiceActive(component.ServiceName)
+		if _, err := os.Stat(objectCacheSocket(key)); err == nil {
+			status.Socket = objectCacheSocket(key)
+		}
+		if key == "redis" {
+			status.Memory = readDirective(redisConfigFile, "maxmemory")
+		} else if memory := readDirective(memcachedConfigFile, "-m"); memory != "" {
+			status.Memory = memory + "mb"
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// readDirective returns the value of the active "key value" line of a config
+// file, or "" when there is none
+func readDirective(path, key string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	pattern := regexp.MustCompile(`(?m)^[ \t]*` + regexp.QuoteMeta(key) + `[ \t]+(\S+)`)
+	if match := pattern.FindStringSubmatch(string(data)); match != nil {
+		return match[1]
+	}
+	return ""
+}
@@ -0,0 +1,170 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/osinfo"
+	"webstack-cli/internal/pkg"
+)
+
+// PHPOptions holds the settings of a PHP installation
+type PHPOptions struct {
+	// NoExternalRepo installs the distribution's own PHP packages instead of
+	// adding ppa:ondrej/php (Ubuntu) or packages.sury.org (Debian). RHEL
+	// always needs Remi, which installs PHP versions side by side.
+	NoExternalRepo bool
+}
+
+// Sury's Debian repository, the Debian counterpart of ppa:ondrej/php
+const (
+	suryKeyURL  = "https://packages.sury.org/php/apt.gpg"
+	suryKeyring = "/usr/share/keyrings/deb.sury.org-php.gpg"
+	suryList    = "/etc/apt/sources.list.d/php.list"
+)
+
+// remiReleaseURL is the release package of the Remi repository for a RHEL
+// major version
+const remiReleaseURL = "https://rpms.remirepo.net/enterprise/remi-release-%s.rpm"
+
+// distroPHPFPM matches the PHP-FPM packages in apt-cache search output
+var distroPHPFPM = regexp.MustCompile(`(?m)^php(\d+\.\d+)-fpm\s`)
+
+// preparePHPRepository makes the packages of a PHP version installable:
+// ppa:ondrej/php on Ubuntu, packages.sury.org on Debian, or the
+// distribution's own PHP when opts.NoExternalRepo is set. RHEL-family
+// systems use the Remi repository.
+func preparePHPRepository(version string, opts PHPOptions) error {
+	info, err := osinfo.Detect()
+	if err != nil {
+		return err
+	}
+	if info.UsesDnf() {
+		if opts.NoExternalRepo {
+			return fmt.Errorf("--no-external-repo is not supported on %s, PHP versions come from the Remi repository", info.PrettyName)
+		}
+		return addRemiRepository(info)
+	}
+	if !info.UsesApt() {
+		return fmt.Errorf("PHP installation needs Debian, Ubuntu or a RHEL-family system, detected %s", info.PrettyName)
+	}
+
+	if opts.NoExternalRepo {
+		fmt.Printf("ℹ️  Using the PHP packages of %s (no external repository)\n", info.PrettyName)
+		if err := pkg.Update(); err != nil {
+			return fmt.Errorf("could not update package list: %v", err)
+		}
+		return checkDistroPHP(version, info)
+	}
+
+	if info.IsUbuntu() {
+		err = addOndrejPPA(info)
+	} else {
+		err = addSuryRepository(info)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := pkg.Update(); err != nil {
+		return fmt.Errorf("could not update package list: %v", err)
+	}
+	return nil
+}
+
+// addOndrejPPA adds ppa:ondrej/php unless it is already configured
+func addOndrejPPA(info *osinfo.Info) error {
+	if existing, _ := filepath.Glob("/etc/apt/sources.list.d/ondrej-ubuntu-php-*"); len(existing) > 0 {
+		return nil
+	}
+
+	fmt.Printf("📦 Adding ppa:ondrej/php for %s...\n", info.PrettyName)
+	if err := pkg.Install("software-properties-common"); err != nil {
+		return fmt.Errorf("could not install prerequisites: %v", err)
+	}
+	if err := runCommand("add-apt-repository", "-y", "ppa:ondrej/php"); err != nil {
+		return fmt.Errorf("could not add ppa:ondrej/php: %v", err)
+	}
+	return nil
+}
+
+// addSuryRepository adds packages.sury.org/php with its signing key unless
+// it is already configured
+func addSuryRepository(info *osinfo.Info) error {
+	if _, err := os.Stat(suryList); err == nil {
+		return nil
+	}
+	if info.Codename == "" {
+		return fmt.Errorf("could not detect the release codename of %s for packages.sury.org", info.PrettyName)
+	}
+
+	fmt.Printf("📦 Adding packages.sury.org/php for %s...\n", info.PrettyName)
+	if err := pkg.Install("ca-certificates", "curl"); err != nil {
+		return fmt.Errorf("could not install prerequisites: %v", err)
+	}
+	if err := runCommand("curl", "-fsSLo", suryKeyring, suryKeyURL); err != nil {
+		return fmt.Errorf("could not download the packages.sury.org signing key: %v", err)
+	}
+
+	source := fmt.Sprintf("deb [signed-by=%s] https://packages.sury.org/php/ %s main\n", suryKeyring, info.Codename)
+	if err := dryrun.WriteFile(suryList, []byte(source), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", suryList, err)
+	}
+	return nil
+}
+
+// addRemiRepository adds EPEL and the Remi repository unless they are
+// already configured
+func addRemiRepository(info *osinfo.Info) error {
+	if _, err := os.Stat("/etc/yum.repos.d/remi-safe.repo"); err == nil {
+		return nil
+	}
+	major := info.MajorVersion()
+	if major == "" {
+		return fmt.Errorf("could not detect the major version of %s for the Remi repository", info.PrettyName)
+	}
+
+	fmt.Printf("📦 Adding EPEL and the Remi repository for %s...\n", info.PrettyName)
+	if err := pkg.Install("epel-release"); err != nil {
+		return fmt.Errorf("could not install EPEL: %v", err)
+	}
+	if err := pkg.Install(fmt.Sprintf(remiReleaseURL, major)); err != nil {
+		return fmt.Errorf("could not add the Remi repository: %v", err)
+	}
+	if err := pkg.Update(); err != nil {
+		return fmt.Errorf("could not update package list: %v", err)
+	}
+	return nil
+}
+
+// checkDistroPHP fails when the distribution does not package the
+// requested PHP version, listing the versions it does
+func checkDistroPHP(version string, info *osinfo.Info) error {
+	output, err := exec.Command("apt-cache", "search", "--names-only", `^php[0-9.]+-fpm$`).Output()
+	if err != nil {
+		return fmt.Errorf("could not search the package list: %v", err)
+	}
+
+	var versions []string
+	for _, m := range distroPHPFPM.FindAllStringSubmatch(string(output), -1) {
+		if m[1] == version {
+			return nil
+		}
+		versions = append(versions, m[1])
+	}
+	if dryrun.Enabled() {
+		return nil
+	}
+
+	sort.Strings(versions)
+	if len(versions) == 0 {
+		return fmt.Errorf("%s does not package PHP-FPM", info.PrettyName)
+	}
+	return fmt.Errorf("%s packages PHP %s, not %s; install without --no-external-repo for other versions",
+		info.PrettyName, strings.Join(versions, ", "), version)
+}
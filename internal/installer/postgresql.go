@@ -0,0 +1,226 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/osinfo"
+	"webstack-cli/internal/pkg"
+	"webstack-cli/internal/postgres"
+	"webstack-cli/internal/ui"
+)
+
+// pgdgList is the apt source of the PostgreSQL project's repository (PGDG),
+// which has every supported major version for every Debian and Ubuntu release
+const pgdgList = "/etc/apt/sources.list.d/pgdg.list"
+
+// pgdgScript adds the PGDG repository; it comes with postgresql-common
+const pgdgScript = "/usr/share/postgresql-common/pgdg/apt.postgresql.org.sh"
+
+// pgUpgradeBackupDir keeps the dump taken before a cluster upgrade
+const pgUpgradeBackupDir = "/var/backups/webstack/postgresql-upgrade"
+
+// pgMajorVersion matches PostgreSQL major versions (10 and later)
+var pgMajorVersion = regexp.MustCompile(`^[1-9][0-9]$`)
+
+// postgreSQLInstalled reports whether a PostgreSQL server is installed, by
+// the metapackage or by a versioned package from PGDG (postgresql-16)
+func postgreSQLInstalled() bool {
+	if checkComponentStatus(components["postgresql"]) == Installed {
+		return true
+	}
+	return len(postgreSQLServerPackages()) > 0
+}
+
+// postgreSQLServerPackages returns the installed versioned server packages
+func postgreSQLServerPackages() []string {
+	var packages []string
+	for _, name := range pkg.Matching("postgresql-[0-9]*") {
+		if pgMajorVersion.MatchString(name[len("postgresql-"):]) {
+			packages = append(packages, name)
+		}
+	}
+	return packages
+}
+
+// postgreSQLPackages returns the packages installing a PostgreSQL major
+// version, or the distribution's default one when version is empty
+func postgreSQLPackages(version string) []string {
+	if version == "" || osinfo.Current().IsRHEL() {
+		return []string{"postgresql", "postgresql-contrib"}
+	}
+	// The contrib modules are part of the server package since PostgreSQL 10
+	return []string{"postgresql-" + version}
+}
+
+// preparePostgreSQLRepository makes a PostgreSQL major version installable:
+// the PGDG repository on Debian and Ubuntu, the dnf module stream on RHEL
+func preparePostgreSQLRepository(version string) error {
+	if osinfo.Current().IsRHEL() {
+		fmt.Printf("📦 Enabling the postgresql:%s module stream...\n", version)
+		runCommandQuiet("dnf", "-y", "module", "reset", "postgresql")
+		if err := runCommand("dnf", "-y", "module", "enable", "postgresql:"+version); err != nil {
+			return fmt.Errorf("PostgreSQL %s is not available as a module stream: %v", version, err)
+		}
+		return nil
+	}
+
+	if _, err := os.Stat(pgdgList); err == nil {
+		return pkg.Update()
+	}
+	fmt.Println("📦 Adding the PostgreSQL (PGDG) repository...")
+	if err := pkg.Install("postgresql-common", "ca-certificates", "curl"); err != nil {
+		return fmt.Errorf("could not install postgresql-common: %v", err)
+	}
+	if err := runCommand(pgdgScript, "-y"); err != nil {
+		return fmt.Errorf("could not add the PGDG repository: %v", err)
+	}
+	return nil
+}
+
+// addPostgreSQLVersion installs another major version next to the existing
+// clusters. Debian and Ubuntu create its main cluster on the next free port.
+func addPostgreSQLVersion(version string) {
+	clusters, _ := postgres.Clusters()
+	fmt.Printf("📦 Installing PostgreSQL %s next to the existing clusters (%s)...\n", version, postgres.IDs(clusters))
+	if err := preparePostgreSQLRepository(version); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	if err := pkg.Install(postgreSQLPackages(version)...); err != nil {
+		ui.Fail("❌ PostgreSQL %s installation failed: %v\n", version, err)
+		return
+	}
+
+	c, err := postgres.Find(version)
+	if err != nil {
+		ui.Warn("⚠️  Warning: No cluster of PostgreSQL %s was created: %v\n", version, err)
+		fmt.Printf("   Create one with: sudo pg_createcluster %s main --start\n", version)
+		return
+	}
+	fmt.Printf("✅ PostgreSQL %s installed, cluster %s listens on port %d\n", version, c.ID(), c.Port)
+	fmt.Printf("   Connect with: sudo -u postgres psql -p %d\n", c.Port)
+}
+
+// UpgradePostgreSQLCluster upgrades a cluster (16/main, or "" for the one on
+// port 5432) to a newer major version with pg_upgradecluster. Its databases
+// are unavailable meanwhile. A dump of the
+// cluster is saved first, and the old cluster is kept, stopped, on another
+// port until it is dropped.
+func UpgradePostgreSQLCluster(ref, version string) error {
+	if osinfo.Current().IsRHEL() {
+		return fmt.Errorf("cluster upgrades need pg_upgradecluster (Debian and Ubuntu); on RHEL use postgresql-setup --upgrade")
+	}
+	if !pgMajorVersion.MatchString(version) {
+		return fmt.Errorf("Invalid PostgreSQL version %s (use a major version such as 17)", version)
+	}
+	c, err := postgres.Find(ref)
+	if err != nil {
+		return err
+	}
+	current, _ := strconv.Atoi(c.Version)
+	target, _ := strconv.Atoi(version)
+	if target <= current {
+		return fmt.Errorf("cluster %s is already at PostgreSQL %s", c.ID(), c.Version)
+	}
+	if existing, err := postgres.Find(version + "/" + c.Name); err == nil && clusterHasDatabases(existing) {
+		return fmt.Errorf("cluster %s already exists and has databases, drop it first with: sudo pg_dropcluster --stop %s %s", existing.ID(), version, c.Name)
+	}
+
+	fmt.Printf("⬆️  Upgrading PostgreSQL cluster %s (port %d) to PostgreSQL %s\n", c.ID(), c.Port, version)
+
+	// A dump to fall back on, as pg_upgradecluster changes both clusters
+	dump, err := dumpPostgreSQLCluster(c)
+	if err != nil {
+		return fmt.Errorf("could not dump cluster %s, nothing was changed: %v", c.ID(), err)
+	}
+	if dump != "" {
+		fmt.Printf("💾 Dump of %s saved to %s\n", c.ID(), dump)
+	}
+
+	if err := preparePostgreSQLRepository(version); err != nil {
+		return err
+	}
+	if err := pkg.Install(postgreSQLPackages(version)...); err != nil {
+		return fmt.Errorf("PostgreSQL %s installation failed: %v", version, err)
+	}
+
+	// Installing a version creates an empty main cluster, which would block
+	// the upgrade of a cluster of the same name
+	if created, err := postgres.Find(version + "/" + c.Name); err == nil && !clusterHasDatabases(created) {
+		fmt.Printf("🧹 Dropping the empty cluster %s created by the package...\n", created.ID())
+		if err := runCommand("pg_dropcluster", "--stop", created.Version, created.Name); err != nil {
+			return fmt.Errorf("could not drop the empty cluster %s: %v", created.ID(), err)
+		}
+	}
+
+	fmt.Printf("🔄 Running pg_upgradecluster %s %s...\n", c.Version, c.Name)
+	if err := runCommand("pg_upgradecluster", "-v", version, "-m", "upgrade", c.Version, c.Name); err != nil {
+		return fmt.Errorf("pg_upgradecluster failed, cluster %s is unchanged (dump: %s): %v", c.ID(), dump, err)
+	}
+
+	upgraded, err := postgres.Find(version + "/" + c.Name)
+	if err != nil {
+		return err
+	}
+	if upgraded.Port == postgres.DefaultPort {
+		if err := UpdateServerConfig("postgresql", true, upgraded.Port, "backend"); err != nil {
+			ui.Warn("⚠️  Warning: Could not update config: %v\n", err)
+		}
+	}
+
+	fmt.Printf("✅ Cluster %s upgraded to %s, listening on port %d\n", c.ID(), upgraded.ID(), upgraded.Port)
+	if old, err := postgres.Find(c.ID()); err == nil {
+		fmt.Printf("   The old cluster %s is kept, stopped, on port %d\n", old.ID(), old.Port)
+		fmt.Printf("   Once the sites work, remove it with: sudo pg_dropcluster %s %s\n", old.Version, old.Name)
+	}
+	return nil
+}
+
+// dumpPostgreSQLCluster saves a pg_dumpall of a cluster, returning its path
+func dumpPostgreSQLCluster(c postgres.Cluster) (string, error) {
+	if !c.Online() {
+		return "", fmt.Errorf("cluster %s is %s", c.ID(), c.Status)
+	}
+	if dryrun.Enabled() {
+		fmt.Printf("🔎 [dry-run] would dump cluster %s to %s\n", c.ID(), pgUpgradeBackupDir)
+		return "", nil
+	}
+	if err := os.MkdirAll(pgUpgradeBackupDir, 0700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(pgUpgradeBackupDir, fmt.Sprintf("%s-%s.sql", c.Key(), time.Now().Format("20060102-150405")))
+	output, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer output.Close()
+
+	cmd := c.Command("pg_dumpall")
+	cmd.Stdout = output
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// clusterHasDatabases reports whether a cluster has databases besides the
+// ones initdb creates
+func clusterHasDatabases(c postgres.Cluster) bool {
+	if !c.Online() {
+		return true
+	}
+	output, err := c.Command("psql", "-Atc", "SELECT count(*) FROM pg_database WHERE datname NOT IN ('postgres', 'template0', 'template1')").Output()
+	if err != nil {
+		return true
+	}
+	count, _ := strconv.Atoi(strings.TrimSpace(string(output)))
+	return count > 0
+}
@@ -0,0 +1,205 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/ui"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StackProfile is an unattended 'install all', read from a YAML (or JSON)
+// file so cloud-init and image builds never stop at a prompt:
+//
+//	web_server: both
+//	database: mariadb
+//	database_password: $DB_ROOT_PASSWORD
+//	php: ["8.3", "8.2"]
+//	extras: [redis]
+//
+// Unlike Profile, which 'webstack init' builds from its questions, every
+// answer InstallAll would ask for comes from the file.
+type StackProfile struct {
+	WebServer        string   `yaml:"web_server"`        // nginx, apache, both (Nginx in front of Apache) or none
+	NginxVersion     string   `yaml:"nginx_version"`     // Package version, default: latest
+	ApacheVersion    string   `yaml:"apache_version"`    // Package version, default: latest
+	Database         string   `yaml:"database"`          // mysql, mariadb, postgresql or none
+	DatabaseVersion  string   `yaml:"database_version"`  // Package version, default: latest
+	DatabasePassword string   `yaml:"database_password"` // Root (postgres) password; empty or auto generates one
+	PHP              []string `yaml:"php"`               // PHP-FPM versions
+	DefaultPHP       string   `yaml:"default_php"`       // defaults.php, default: the first of php
+	Extras           []string `yaml:"extras"`            // redis, memcached or caddy
+	Existing         string   `yaml:"existing"`          // Installed components: keep (default), reinstall or skip
+}
+
+// profileExtras are the components a profile can add to the stack
+var profileExtras = map[string]func(){
+	"redis":     func() { InstallRedis(ObjectCacheOptions{}) },
+	"memcached": func() { InstallMemcached(ObjectCacheOptions{}) },
+	"caddy":     InstallCaddy,
+}
+
+// unattended answers the prompts of the installer while a profile is
+// installed; nil prompts on the terminal
+var unattended *StackProfile
+
+// LoadStackProfile reads and validates a profile. Values written as $NAME or
+// ${NAME} are read from the environment, so passwords can stay out of the file.
+func LoadStackProfile(path string) (*StackProfile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", path, err)
+	}
+	defer file.Close()
+
+	p := StackProfile{WebServer: "nginx", Database: "none", Existing: "keep"}
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&p); err != nil {
+		return nil, fmt.Errorf("Invalid profile %s: %v", path, err)
+	}
+	p.DatabasePassword = os.ExpandEnv(p.DatabasePassword)
+
+	var problems []string
+	switch p.WebServer {
+	case "nginx", "apache", "both", "none":
+	default:
+		problems = append(problems, fmt.Sprintf("invalid web_server %s (use nginx, apache, both or none)", p.WebServer))
+	}
+	switch p.Database {
+	case "mysql", "mariadb", "postgresql", "none":
+	default:
+		problems = append(problems, fmt.Sprintf("invalid database %s (use mysql, mariadb, postgresql or none)", p.Database))
+	}
+	if p.Database == "none" && (p.DatabaseVersion != "" || p.DatabasePassword != "") {
+		problems = append(problems, "database_version and database_password need a database")
+	}
+	if strings.ContainsAny(p.DatabasePassword, "'\\") {
+		problems = append(problems, "database_password must not contain quotes or backslashes")
+	}
+	for _, version := range p.PHP {
+		if !isSupportedPHP(version) {
+			problems = append(problems, fmt.Sprintf("unsupported PHP version %s (use %s)", version, strings.Join(supportedPHPVersions, ", ")))
+		}
+	}
+	if p.DefaultPHP != "" && !contains(p.PHP, p.DefaultPHP) {
+		problems = append(problems, fmt.Sprintf("default_php %s is not in php", p.DefaultPHP))
+	}
+	for _, extra := range p.Extras {
+		if profileExtras[extra] == nil {
+			problems = append(problems, fmt.Sprintf("unknown extra %s (use redis, memcached or caddy)", extra))
+		}
+	}
+	if contains(p.Extras, "caddy") && p.WebServer != "nginx" && p.WebServer != "both" {
+		problems = append(problems, "caddy runs behind Nginx, set web_server to nginx or both")
+	}
+	switch p.Existing {
+	case "keep", "reinstall", "skip":
+	default:
+		problems = append(problems, fmt.Sprintf("invalid existing %s (use keep, reinstall or skip)", p.Existing))
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("Invalid profile %s:\n  - %s", path, strings.Join(problems, "\n  - "))
+	}
+	return &p, nil
+}
+
+func isSupportedPHP(version string) bool {
+	return contains(supportedPHPVersions, version)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// InstallStack installs the components of a profile in dependency order
+// without asking anything, then writes the provisioning report
+func InstallStack(p *StackProfile) {
+	fmt.Println("🚀 WebStack Unattended Installation")
+	fmt.Println("===================================")
+	started := time.Now()
+	problems := len(ui.Problems())
+
+	unattended = p
+	defer func() { unattended = nil }()
+
+	steps := map[string]func(){}
+	if p.WebServer == "nginx" || p.WebServer == "both" {
+		steps["nginx"] = func() { InstallNginxVersion(p.NginxVersion) }
+	}
+	if p.WebServer == "apache" || p.WebServer == "both" {
+		steps["apache"] = func() { InstallApacheVersion(p.ApacheVersion) }
+	}
+	switch p.Database {
+	case "mysql":
+		steps["mysql"] = func() { InstallMySQLVersion(p.DatabaseVersion) }
+	case "mariadb":
+		steps["mariadb"] = func() { InstallMariaDBVersion(p.DatabaseVersion) }
+	case "postgresql":
+		steps["postgresql"] = func() { InstallPostgreSQLVersion(p.DatabaseVersion) }
+	}
+	if len(p.PHP) > 0 {
+		steps[phpRequirement] = func() {
+			for _, version := range p.PHP {
+				InstallPHP(version)
+			}
+		}
+	}
+
+	var names []string
+	for _, name := range []string{"nginx", "apache", "mysql", "mariadb", "postgresql", phpRequirement} {
+		if steps[name] != nil {
+			names = append(names, name)
+		}
+	}
+	for _, name := range installOrder(names) {
+		fmt.Println()
+		steps[name]()
+	}
+	for _, extra := range p.Extras {
+		fmt.Println()
+		profileExtras[extra]()
+	}
+
+	if len(p.PHP) > 0 {
+		defaultPHP := p.DefaultPHP
+		if defaultPHP == "" {
+			defaultPHP = p.PHP[0]
+		}
+		err := config.Update(func(cfg *config.Config) error {
+			cfg.SetDefault(config.DefaultPHPKey, defaultPHP)
+			return nil
+		})
+		if err != nil {
+			ui.Warn("⚠️  Warning: Could not set the default PHP version: %v\n", err)
+		}
+	}
+
+	fmt.Println("\n✅ Installation completed!")
+	newInstallReport(started, problems).finish()
+}
+
+// profileAction is the answer of promptForAction while a profile is installed
+func profileAction(componentName string) string {
+	fmt.Printf("ℹ️  %s is already installed, profile says: %s\n", componentName, unattended.Existing)
+	return unattended.Existing
+}
+
+// profilePassword is the database password of the profile, generated when
+// it has none
+func profilePassword() string {
+	if unattended.DatabasePassword == "" || unattended.DatabasePassword == "auto" {
+		fmt.Println("✓ Auto-generated password will be used")
+		return generateRandomPassword(24)
+	}
+	fmt.Println("✓ Password set from the profile")
+	return unattended.DatabasePassword
+}
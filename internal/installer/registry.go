@@ -0,0 +1,384 @@
+package installer
+
+import (
+	"sort"
+	"strings"
+	"webstack-cli/internal/osinfo"
+	"webstack-cli/internal/ui"
+)
+
+// Component represents a component that can be installed
+type Component struct {
+	Name        string
+	CheckCmd    []string
+	PackageName string
+	ServiceName string
+	// Depends lists the components this one needs. A requirement such as
+	// "nginx|apache" is met by either of them; "php" by any PHP-FPM version.
+	Depends []string
+}
+
+// phpRequirement is met by any installed PHP-FPM version. PHP versions are
+// not registry entries, they are listed by GetPHPVersionsStatus.
+const phpRequirement = "php"
+
+// supportedPHPVersions are the PHP-FPM versions offered by the installer
+var supportedPHPVersions = []string{"5.6", "7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3", "8.4"}
+
+// Component registry, keyed by the name used in config.json and on the command line
+var components = map[string]Component{
+	"nginx": {
+		Name:        "Nginx",
+		CheckCmd:    []string{"dpkg", "-l", "nginx"},
+		PackageName: "nginx",
+		ServiceName: "nginx",
+	},
+	"apache": {
+		Name:        "Apache",
+		CheckCmd:    []string{"dpkg", "-l", "apache2"},
+		PackageName: "apache2",
+		ServiceName: "apache2",
+	},
+	"caddy": {
+		Name:        "Caddy",
+		CheckCmd:    []string{"dpkg", "-l", "caddy"},
+		PackageName: "caddy",
+		ServiceName: "caddy",
+		Depends:     []string{"nginx"},
+	},
+	"mysql": {
+		Name:        "MySQL",
+		CheckCmd:    []string{"dpkg", "-l", "mysql-server"},
+		PackageName: "mysql-server",
+		ServiceName: "mysql",
+	},
+	"mariadb": {
+		Name:        "MariaDB",
+		CheckCmd:    []string{"dpkg", "-l", "mariadb-server"},
+		PackageName: "mariadb-server",
+		ServiceName: "mariadb",
+	},
+	"postgresql": {
+		Name:        "PostgreSQL",
+		CheckCmd:    []string{"dpkg", "-l", "postgresql"},
+		PackageName: "postgresql postgresql-contrib",
+		ServiceName: "postgresql",
+	},
+	"mongodb": {
+		Name:        "MongoDB",
+		CheckCmd:    []string{"dpkg", "-l", "mongodb-org"},
+		PackageName: "mongodb-org",
+		ServiceName: "mongod",
+	},
+	"bind9": {
+		Name:        "Bind9 DNS",
+		CheckCmd:    []string{"dpkg", "-l", "bind9"},
+		PackageName: "bind9 bind9-utils bind9-doc",
+		ServiceName: "bind9",
+	},
+	"redis": {
+		Name:        "Redis",
+		CheckCmd:    []string{"dpkg", "-l", "redis-server"},
+		PackageName: "redis-server",
+		ServiceName: "redis-server",
+	},
+	"memcached": {
+		Name:        "Memcached",
+		CheckCmd:    []string{"dpkg", "-l", "memcached"},
+		PackageName: "memcached",
+		ServiceName: "memcached",
+	},
+	"ftp": {
+		Name:        "vsftpd FTP server",
+		CheckCmd:    []string{"dpkg", "-l", "vsftpd"},
+		PackageName: "vsftpd",
+		ServiceName: "vsftpd",
+	},
+	"phpmyadmin": {
+		Name:     "phpMyAdmin",
+		CheckCmd: []string{"test", "-d", "/var/www/phpmyadmin"},
+		Depends:  []string{"nginx|apache", phpRequirement, "mysql|mariadb"},
+	},
+	"postfix": {
+		Name:        "Postfix",
+		CheckCmd:    []string{"dpkg", "-l", "postfix"},
+		PackageName: "postfix",
+		ServiceName: "postfix",
+	},
+	"dovecot": {
+		Name:        "Dovecot",
+		CheckCmd:    []string{"dpkg", "-l", "dovecot-core"},
+		PackageName: "dovecot-core",
+		ServiceName: "dovecot",
+		// Postfix authenticates SMTP clients through Dovecot SASL and
+		// delivers to it over LMTP
+		Depends: []string{"postfix"},
+	},
+	"clamav": {
+		Name:        "ClamAV",
+		CheckCmd:    []string{"dpkg", "-l", "clamav-daemon"},
+		PackageName: "clamav clamav-daemon amavis",
+		ServiceName: "clamav-daemon",
+		Depends:     []string{"postfix"},
+	},
+	"spamassassin": {
+		Name:        "SpamAssassin",
+		CheckCmd:    []string{"dpkg", "-l", "spamassassin"},
+		PackageName: "spamassassin spamc",
+		ServiceName: "spamd",
+		Depends:     []string{"postfix"},
+	},
+}
+
+// init names the services of the components after their units on RHEL
+// (httpd, mysqld, named ...); packages are translated by the pkg package
+func init() {
+	for name, component := range components {
+		component.ServiceName = osinfo.Current().Service(component.ServiceName)
+		components[name] = component
+	}
+}
+
+// DependencyProblem is an installed component with unmet requirements
+type DependencyProblem struct {
+	Component string   // Registry key
+	Name      string   // Display name
+	Missing   []string // Unmet requirements, e.g. "Nginx or Apache"
+}
+
+// componentInstalled reports whether a registry component, or any PHP
+// version for the "php" requirement, is installed
+func componentInstalled(name string) bool {
+	if name == phpRequirement {
+		for _, version := range supportedPHPVersions {
+			if checkPHPVersion(version) == Installed {
+				return true
+			}
+		}
+		return false
+	}
+
+	comp, ok := components[name]
+	if !ok {
+		return false
+	}
+	return checkComponentStatus(comp) == Installed
+}
+
+// requirementMet reports whether any alternative of a requirement is installed
+func requirementMet(requirement string) bool {
+	for _, name := range strings.Split(requirement, "|") {
+		if componentInstalled(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// requirementName formats a requirement for messages ("Nginx or Apache")
+func requirementName(requirement string) string {
+	var names []string
+	for _, name := range strings.Split(requirement, "|") {
+		names = append(names, displayName(name))
+	}
+	return strings.Join(names, " or ")
+}
+
+func displayName(name string) string {
+	if name == phpRequirement {
+		return "PHP"
+	}
+	if comp, ok := components[name]; ok {
+		return comp.Name
+	}
+	return name
+}
+
+// MissingDependencies returns the requirements of a component that are not
+// installed, formatted for messages
+func MissingDependencies(name string) []string {
+	var missing []string
+	for _, requirement := range components[name].Depends {
+		if !requirementMet(requirement) {
+			missing = append(missing, requirementName(requirement))
+		}
+	}
+	return missing
+}
+
+// InstalledDependents returns the installed components that would lose a
+// requirement if the given component were removed
+func InstalledDependents(name string) []string {
+	var dependents []string
+	for _, key := range registryKeys() {
+		for _, requirement := range components[key].Depends {
+			if !requiresOnly(requirement, name) || !componentInstalled(key) {
+				continue
+			}
+			dependents = append(dependents, components[key].Name)
+			break
+		}
+	}
+	return dependents
+}
+
+// requiresOnly reports whether name is the only installed alternative of a
+// requirement
+func requiresOnly(requirement, name string) bool {
+	found := false
+	for _, alternative := range strings.Split(requirement, "|") {
+		if alternative == name {
+			found = true
+		} else if componentInstalled(alternative) {
+			return false
+		}
+	}
+	return found
+}
+
+// DependencyProblems returns the installed components whose requirements
+// are not installed
+func DependencyProblems() []DependencyProblem {
+	var problems []DependencyProblem
+	for _, key := range registryKeys() {
+		comp := components[key]
+		if len(comp.Depends) == 0 || !componentInstalled(key) {
+			continue
+		}
+		if missing := MissingDependencies(key); len(missing) > 0 {
+			problems = append(problems, DependencyProblem{Component: key, Name: comp.Name, Missing: missing})
+		}
+	}
+	return problems
+}
+
+// warnDependents asks for confirmation before removing a component other
+// installed components need. It returns false when the user backs out.
+func warnDependents(name string) bool {
+	dependents := InstalledDependents(name)
+	if len(dependents) == 0 {
+		return true
+	}
+	ui.Warn("⚠️  %s is needed by: %s\n", displayName(name), strings.Join(dependents, ", "))
+	return confirmRemoval("remove "+displayName(name), "Remove it anyway?")
+}
+
+// installOrder sorts components so each one comes after the components it
+// depends on. Requirements outside the list are ignored; components without
+// a relation between them keep their order.
+func installOrder(names []string) []string {
+	return sortComponents(names, func(name, other string) bool {
+		return dependsOn(name, other)
+	})
+}
+
+// uninstallOrder sorts components so each one is removed before the
+// components it depends on
+func uninstallOrder(names []string) []string {
+	return sortComponents(names, func(name, other string) bool {
+		return dependsOn(other, name)
+	})
+}
+
+// sortComponents orders names so that every component comes after the
+// ones it must follow (after(name, other) is true)
+func sortComponents(names []string, after func(name, other string) bool) []string {
+	var order []string
+	visited := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		// Marked before visiting the others, so a cycle in the registry
+		// cannot recurse forever
+		visited[name] = true
+		for _, other := range names {
+			if other != name && after(name, other) {
+				visit(other)
+			}
+		}
+		order = append(order, name)
+	}
+
+	for _, name := range names {
+		visit(name)
+	}
+	return order
+}
+
+// dependsOn reports whether any requirement of a component names other
+func dependsOn(name, other string) bool {
+	for _, requirement := range components[name].Depends {
+		for _, alternative := range strings.Split(requirement, "|") {
+			if alternative == other {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func registryKeys() []string {
+	keys := make([]string, 0, len(components))
+	for key := range components {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// otherPHPVersionInstalled reports whether a PHP version other than the
+// given one is installed
+func otherPHPVersionInstalled(version string) bool {
+	for _, v := range supportedPHPVersions {
+		if v != version && checkPHPVersion(v) == Installed {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportedPHPVersions returns the PHP-FPM versions offered by the installer
+func SupportedPHPVersions() []string {
+	return append([]string(nil), supportedPHPVersions...)
+}
+
+// Profile is a stack installed in one go, as chosen by 'webstack init'
+type Profile struct {
+	WebServer  string // "nginx", "apache" or "both" (Nginx in front of Apache)
+	Database   string // "mysql", "mariadb", "postgresql" or empty for none
+	PHPVersion string // Empty for no PHP
+}
+
+// InstallProfile installs the components of a profile in dependency order
+func InstallProfile(p Profile) {
+	steps := map[string]func(){}
+	if p.WebServer == "nginx" || p.WebServer == "both" {
+		steps["nginx"] = InstallNginx
+	}
+	if p.WebServer == "apache" || p.WebServer == "both" {
+		steps["apache"] = InstallApache
+	}
+	switch p.Database {
+	case "mysql":
+		steps["mysql"] = InstallMySQL
+	case "mariadb":
+		steps["mariadb"] = InstallMariaDB
+	case "postgresql":
+		steps["postgresql"] = InstallPostgreSQL
+	}
+	if p.PHPVersion != "" {
+		steps[phpRequirement] = func() { InstallPHP(p.PHPVersion) }
+	}
+
+	var names []string
+	for _, name := range []string{"nginx", "apache", "mysql", "mariadb", "postgresql", phpRequirement} {
+		if steps[name] != nil {
+			names = append(names, name)
+		}
+	}
+	for _, name := range installOrder(names) {
+		steps[name]()
+	}
+}
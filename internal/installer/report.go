@@ -0,0 +1,165 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/notify"
+	"webstack-cli/internal/pkg"
+	"webstack-cli/internal/ui"
+)
+
+// installReportFile is the provisioning report written after InstallAll
+const installReportFile = "/etc/webstack/install-report.txt"
+
+// reportComponents are the components InstallAll may provision, in the
+// order of the report; PHP versions are added after them
+var reportComponents = []string{"nginx", "apache", "mysql", "mariadb", "postgresql"}
+
+// installReport summarizes what InstallAll provisioned, so the warnings of
+// a long installation don't scroll away
+type installReport struct {
+	started     time.Time
+	finished    time.Time
+	components  []reportComponent
+	credentials []string // Files holding generated passwords
+	warnings    []string // Warning and error lines printed during the run
+}
+
+// reportComponent is an installed component
+type reportComponent struct {
+	name    string
+	version string
+	port    int
+	mode    string
+	running bool
+}
+
+// newInstallReport collects the state of the server after InstallAll.
+// problems is the number of warnings and errors reported before it started.
+func newInstallReport(started time.Time, problems int) *installReport {
+	r := &installReport{started: started, finished: time.Now()}
+
+	cfg, err := config.Load()
+	if err != nil || cfg == nil {
+		cfg = config.DefaultConfig()
+	}
+	for _, key := range reportComponents {
+		component := components[key]
+		if checkComponentStatus(component) != Installed {
+			continue
+		}
+		r.components = append(r.components, reportComponent{
+			name:    component.Name,
+			version: packageVersion(strings.Fields(component.PackageName)[0]),
+			port:    cfg.GetPort(key),
+			mode:    cfg.GetMode(key),
+			running: isServiceActive(component.ServiceName),
+		})
+	}
+	for _, version := range supportedPHPVersions {
+		if checkPHPVersion(version) != Installed {
+			continue
+		}
+		service := fmt.Sprintf("php%s-fpm", version)
+		r.components = append(r.components, reportComponent{
+			name:    "PHP " + version + " (FPM)",
+			version: packageVersion(service),
+			mode:    "socket",
+			running: isServiceActive(service),
+		})
+	}
+
+	r.credentials, _ = filepath.Glob("/etc/webstack/*-credentials.txt")
+	if all := ui.Problems(); len(all) > problems {
+		r.warnings = all[problems:]
+	}
+	return r
+}
+
+// packageVersion returns the installed version of a Debian package
+func packageVersion(name string) string {
+	if version := pkg.Version(name); version != "" {
+		return version
+	}
+	return "unknown"
+}
+
+// String renders the report as plain text
+func (r *installReport) String() string {
+	var b strings.Builder
+	hostname, _ := os.Hostname()
+	b.WriteString("WebStack CLI - Provisioning report\n")
+	fmt.Fprintf(&b, "Server:   %s\n", hostname)
+	fmt.Fprintf(&b, "Started:  %s\n", r.started.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&b, "Finished: %s (%s)\n", r.finished.Format("2006-01-02 15:04:05"), r.finished.Sub(r.started).Round(time.Second))
+
+	b.WriteString("\nComponents\n")
+	if len(r.components) == 0 {
+		b.WriteString("  (none installed)\n")
+	}
+	for _, c := range r.components {
+		port := "-"
+		if c.port != 0 {
+			port = fmt.Sprint(c.port)
+		}
+		mode := c.mode
+		if mode == "" {
+			mode = "-"
+		}
+		status := "running"
+		if !c.running {
+			status = "NOT RUNNING"
+		}
+		fmt.Fprintf(&b, "  %-18s %-28s port %-6s %-11s %s\n", c.name, c.version, port, mode, status)
+	}
+
+	b.WriteString("\nGenerated credentials (readable by root only)\n")
+	if len(r.credentials) == 0 {
+		b.WriteString("  (none)\n")
+	}
+	for _, path := range r.credentials {
+		fmt.Fprintf(&b, "  %s\n", path)
+	}
+
+	fmt.Fprintf(&b, "\nWarnings and errors (%d)\n", len(r.warnings))
+	if len(r.warnings) == 0 {
+		b.WriteString("  (none)\n")
+	}
+	for _, line := range r.warnings {
+		fmt.Fprintf(&b, "  %s\n", ui.ToASCII(line))
+	}
+	return b.String()
+}
+
+// finish writes the report, prints where it is and sends it to the
+// notification channels subscribed to install.completed
+func (r *installReport) finish() {
+	if err := dryrun.WriteFile(installReportFile, []byte(r.String()), 0600); err != nil {
+		ui.Warn("⚠️  Warning: Could not write the provisioning report: %v\n", err)
+	} else {
+		fmt.Printf("📄 Provisioning report: %s\n", installReportFile)
+	}
+
+	stopped := 0
+	for _, c := range r.components {
+		if !c.running {
+			stopped++
+		}
+	}
+	if stopped > 0 || len(r.warnings) > 0 {
+		// Indented so the summary doesn't count as another warning
+		fmt.Printf("   %d component(s) installed, %d not running, %d warning(s) and error(s) during the installation\n",
+			len(r.components), stopped, len(r.warnings))
+	}
+
+	title := fmt.Sprintf("Installation completed: %d component(s)", len(r.components))
+	if len(r.warnings) > 0 {
+		title += fmt.Sprintf(", %d warning(s)", len(r.warnings))
+	}
+	notify.Send(notify.InstallDone, "", title, r.String())
+}
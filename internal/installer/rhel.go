@@ -0,0 +1,115 @@
+package installer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/osinfo"
+	"webstack-cli/internal/ui"
+)
+
+// httpdInclude makes httpd on RHEL read the Debian layout the domain
+// configurations are written for
+const httpdInclude = `# WebStack CLI - Debian-style layout for httpd
+Define APACHE_LOG_DIR /var/log/httpd
+
+Include ports.conf
+IncludeOptional includes/*.conf
+IncludeOptional sites-enabled/*.conf
+`
+
+// httpdListen matches the Listen directives of httpd.conf, moved to ports.conf
+var httpdListen = regexp.MustCompile(`(?m)^Listen\s`)
+
+// phpRuntimeDir keeps /run/php, where the pools put their sockets, across
+// reboots; the Remi packages don't create it
+const phpRuntimeDir = "d /run/php 0755 root root -\n"
+
+// apacheUnit is the systemd unit of Apache, httpd on RHEL
+func apacheUnit() string {
+	return osinfo.Current().Service("apache2")
+}
+
+// apacheFile returns a path in Apache's configuration directory
+func apacheFile(elem ...string) string {
+	return filepath.Join(append([]string{osinfo.Current().ApacheDir}, elem...)...)
+}
+
+// prepareWebUser creates the www-data user the pool and nginx.conf
+// templates run as. Debian and Ubuntu ship it; on RHEL it is added and the
+// web server users join its group to reach the PHP-FPM sockets.
+func prepareWebUser() {
+	if !osinfo.Current().IsRHEL() {
+		return
+	}
+	if exec.Command("id", "-u", "www-data").Run() != nil {
+		if err := dryrun.Run(exec.Command("useradd", "--system", "--user-group", "--no-create-home", "--shell", "/sbin/nologin", "www-data")); err != nil {
+			ui.Warn("⚠️  Warning: Could not create the www-data user: %v\n", err)
+			return
+		}
+		fmt.Println("✅ Created the www-data user")
+	}
+	for _, user := range []string{"apache", "nginx"} {
+		if exec.Command("id", "-u", user).Run() == nil {
+			dryrun.Run(exec.Command("usermod", "-aG", "www-data", user))
+		}
+	}
+	if err := dryrun.MkdirAll("/run/php", 0755); err != nil {
+		ui.Warn("⚠️  Warning: Could not create /run/php: %v\n", err)
+	}
+	if err := dryrun.WriteFile("/etc/tmpfiles.d/webstack-php.conf", []byte(phpRuntimeDir), 0644); err != nil {
+		ui.Warn("⚠️  Warning: Could not write /etc/tmpfiles.d/webstack-php.conf: %v\n", err)
+	}
+}
+
+// configureHTTPD gives httpd on RHEL the ports.conf, includes and
+// sites-enabled layout of apache2: Listen moves from httpd.conf to
+// ports.conf, which the installer rewrites for the proxy and standalone modes
+func configureHTTPD() {
+	for _, dir := range []string{"sites-available", "sites-enabled", "includes"} {
+		if err := dryrun.MkdirAll(apacheFile(dir), 0755); err != nil {
+			ui.Warn("⚠️  Warning: Could not create %s: %v\n", apacheFile(dir), err)
+		}
+	}
+
+	include := apacheFile("conf.d", "webstack.conf")
+	if err := dryrun.WriteFile(include, []byte(httpdInclude), 0644); err != nil {
+		ui.Warn("⚠️  Warning: Could not write %s: %v\n", include, err)
+	} else {
+		fmt.Printf("✅ Updated %s\n", include)
+	}
+
+	conf := osinfo.Current().ApacheConf
+	data, err := ioutil.ReadFile(conf)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			ui.Warn("⚠️  Warning: Could not read %s: %v\n", conf, err)
+		}
+		return
+	}
+	if httpdListen.Match(data) {
+		data = httpdListen.ReplaceAll(data, []byte("#Listen "))
+		if err := dryrun.WriteFile(conf, data, 0644); err != nil {
+			ui.Warn("⚠️  Warning: Could not write %s: %v\n", conf, err)
+		}
+	}
+}
+
+// initPostgreSQL creates the database cluster and starts the server, both
+// left to the administrator by the RHEL packages
+func initPostgreSQL() {
+	if !osinfo.Current().IsRHEL() {
+		return
+	}
+	if _, err := os.Stat("/var/lib/pgsql/data/PG_VERSION"); os.IsNotExist(err) {
+		if err := runCommand("postgresql-setup", "--initdb"); err != nil {
+			ui.Warn("⚠️  Warning: Could not initialize the PostgreSQL cluster: %v\n", err)
+			return
+		}
+	}
+	runCommand("systemctl", "start", "postgresql")
+}
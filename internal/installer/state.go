@@ -0,0 +1,108 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/ui"
+)
+
+// installStateFile is kept on disk, not under /run, so an installation
+// interrupted by a reboot can be resumed
+const installStateFile = "/var/lib/webstack/install-state.json"
+
+// installState records the progress of InstallAll so an interrupted
+// installation can be resumed without answering every prompt again
+type installState struct {
+	StartedAt time.Time       `json:"started_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Completed []string        `json:"completed"`
+	Answers   map[string]bool `json:"answers"`
+}
+
+func newInstallState() *installState {
+	return &installState{
+		StartedAt: time.Now(),
+		Answers:   make(map[string]bool),
+	}
+}
+
+// loadInstallState reads the saved progress, returning nil if there is none
+func loadInstallState() (*installState, error) {
+	data, err := ioutil.ReadFile(installStateFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read install state: %v", err)
+	}
+
+	var state installState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("could not parse install state: %v", err)
+	}
+	if state.Answers == nil {
+		state.Answers = make(map[string]bool)
+	}
+	return &state, nil
+}
+
+func (s *installState) save() error {
+	if err := dryrun.MkdirAll(filepath.Dir(installStateFile), 0755); err != nil {
+		return fmt.Errorf("could not create state directory: %v", err)
+	}
+
+	s.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return dryrun.WriteFile(installStateFile, data, 0644)
+}
+
+func (s *installState) isCompleted(step string) bool {
+	for _, done := range s.Completed {
+		if done == step {
+			return true
+		}
+	}
+	return false
+}
+
+// complete marks a step as done and persists the state immediately
+func (s *installState) complete(step string) {
+	if !s.isCompleted(step) {
+		s.Completed = append(s.Completed, step)
+	}
+	if err := s.save(); err != nil {
+		ui.Warn("⚠️  Warning: Could not save install progress: %v\n", err)
+	}
+}
+
+// ask returns the saved answer for key, or prompts and records the answer
+func (s *installState) ask(key, question string) bool {
+	if answer, ok := s.Answers[key]; ok {
+		return answer
+	}
+
+	answer := improvedAskYesNo(question)
+	s.Answers[key] = answer
+	if err := s.save(); err != nil {
+		ui.Warn("⚠️  Warning: Could not save install progress: %v\n", err)
+	}
+	return answer
+}
+
+func clearInstallState() {
+	dryrun.Remove(installStateFile)
+}
+
+// HasInterruptedInstall reports whether a previous InstallAll did not finish
+func HasInterruptedInstall() bool {
+	state, err := loadInstallState()
+	return err == nil && state != nil
+}
@@ -0,0 +1,253 @@
+package installer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/pkg"
+	"webstack-cli/internal/service"
+	"webstack-cli/internal/snapshot"
+	"webstack-cli/internal/ui"
+)
+
+// upgradeGlobs are the installed packages upgraded together with the main
+// package of a component, which is often a metapackage (mysql-server
+// depends on mysql-server-8.0). %s is the PHP version.
+var upgradeGlobs = map[string][]string{
+	"nginx":      {"nginx-*", "libnginx-mod-*"},
+	"apache":     {"apache2-*", "libapache2-mod-*"},
+	"mysql":      {"mysql-server-*", "mysql-client-*", "mysql-common"},
+	"mariadb":    {"mariadb-server-*", "mariadb-client-*", "mariadb-common"},
+	"postgresql": {"postgresql-[0-9]*", "postgresql-client-*", "postgresql-common"},
+	"mongodb":    {"mongodb-org-*", "mongodb-mongosh"},
+	"redis":      {"redis-tools"},
+	"bind9":      {"bind9-*"},
+	"dovecot":    {"dovecot-*"},
+	"clamav":     {"clamav-*", "clamav"},
+	"php":        {"php%s-*"},
+}
+
+// configTests validate the configuration of a component after an upgrade,
+// before its service is restarted
+var configTests = map[string]func() error{
+	"nginx":  func() error { return domain.TestWebServer("nginx") },
+	"apache": func() error { return domain.TestWebServer("apache") },
+	"caddy":  func() error { return domain.TestWebServer("caddy") },
+}
+
+// ComponentUpgrade is an installed component and the version an upgrade
+// would install
+type ComponentUpgrade struct {
+	Component string   // Registry key, or php8.3 for a PHP version
+	Name      string   // Display name
+	Packages  []string // Installed packages upgraded together, the main package first
+	Unit      string   // systemd unit restarted after the upgrade, if any
+	Installed string   // Version of the main package
+	Candidate string   // Version an upgrade installs
+	Pinned    string   // Version pinned with --pin
+}
+
+// Available reports whether an upgrade would install another version
+func (u ComponentUpgrade) Available() bool {
+	return u.Pinned == "" && u.Candidate != "" && u.Candidate != u.Installed
+}
+
+// UpgradeOptions holds the settings of 'webstack upgrade <component>'
+type UpgradeOptions struct {
+	Version string // Package version of the main package to install instead of the newest
+}
+
+// RefreshPackageLists updates the package lists so candidates are current
+func RefreshPackageLists() error {
+	fmt.Println("🔄 Refreshing the package lists...")
+	return pkg.Update()
+}
+
+// ComponentUpgrades returns the installed components with their installed
+// and candidate versions, sorted by name
+func ComponentUpgrades() []ComponentUpgrade {
+	cfg, _ := LoadOrCreateConfig()
+	var upgrades []ComponentUpgrade
+	for name := range components {
+		if u, err := componentUpgrade(name, cfg); err == nil {
+			upgrades = append(upgrades, u)
+		}
+	}
+	for _, version := range supportedPHPVersions {
+		if u, err := componentUpgrade("php"+version, cfg); err == nil {
+			upgrades = append(upgrades, u)
+		}
+	}
+	sort.Slice(upgrades, func(i, j int) bool { return upgrades[i].Component < upgrades[j].Component })
+	return upgrades
+}
+
+// FindComponentUpgrade returns the upgrade of an installed component
+// (nginx, php8.3 ...)
+func FindComponentUpgrade(name string) (ComponentUpgrade, error) {
+	cfg, _ := LoadOrCreateConfig()
+	return componentUpgrade(name, cfg)
+}
+
+func componentUpgrade(name string, cfg *config.Config) (ComponentUpgrade, error) {
+	u := ComponentUpgrade{Component: name}
+	var globs []string
+	if version := strings.TrimPrefix(name, "php"); version != name && isSupportedPHP(version) {
+		u.Name = "PHP " + version
+		u.Packages = []string{fmt.Sprintf("php%s-fpm", version)}
+		u.Unit = config.GetPHPServiceName(version)
+		for _, glob := range upgradeGlobs["php"] {
+			globs = append(globs, fmt.Sprintf(glob, version))
+		}
+	} else {
+		component, ok := components[name]
+		if !ok {
+			return u, fmt.Errorf("Unknown component %s", name)
+		}
+		if len(component.CheckCmd) != 3 || component.CheckCmd[0] != "dpkg" {
+			return u, fmt.Errorf("%s is not installed from packages", component.Name)
+		}
+		u.Name = component.Name
+		u.Packages = []string{component.CheckCmd[2]}
+		u.Unit = component.ServiceName
+		globs = append(strings.Fields(component.PackageName), upgradeGlobs[name]...)
+	}
+
+	u.Installed = pkg.Version(u.Packages[0])
+	if u.Installed == "" {
+		return u, fmt.Errorf("%s is not installed", u.Name)
+	}
+	for _, glob := range globs {
+		for _, p := range pkg.Matching(glob) {
+			if !contains(u.Packages, p) {
+				u.Packages = append(u.Packages, p)
+			}
+		}
+	}
+	u.Candidate = pkg.Candidate(u.Packages[0])
+	if cfg != nil {
+		u.Pinned = cfg.Pin(name)
+	}
+	return u, nil
+}
+
+// PrintChangelog prints the changelog entries between the installed and the
+// candidate version, at most limit lines
+func PrintChangelog(u ComponentUpgrade, limit int) {
+	changelog, err := pkg.Changelog(u.Packages[0], u.Installed)
+	if err != nil {
+		ui.Warn("⚠️  Warning: Could not fetch the changelog: %v\n", err)
+		return
+	}
+	if changelog == "" {
+		fmt.Println("ℹ️  No changelog entries")
+		return
+	}
+	lines := strings.Split(changelog, "\n")
+	if len(lines) > limit {
+		lines = append(lines[:limit], fmt.Sprintf("... %d more line(s)", len(lines)-limit))
+	}
+	fmt.Printf("📝 Changes in %s since %s:\n", u.Packages[0], u.Installed)
+	for _, line := range lines {
+		fmt.Println("   " + line)
+	}
+}
+
+// UpgradeComponent upgrades the packages of a component. The configuration
+// is snapshotted before and after, and the service is only restarted once
+// its configuration passes the component's test (nginx -t, apachectl
+// configtest, php-fpm -t); on Debian and Ubuntu the packages' own restarts
+// are suppressed until then.
+func UpgradeComponent(u ComponentUpgrade, opts UpgradeOptions) error {
+	if u.Pinned != "" {
+		return fmt.Errorf("%s is pinned to %s (unpin it with 'webstack upgrade %s --unpin')", u.Name, u.Pinned, u.Component)
+	}
+	target := u.Candidate
+	packages := append([]string(nil), u.Packages...)
+	if opts.Version != "" {
+		target = opts.Version
+		packages[0] += "=" + opts.Version
+	}
+	fmt.Printf("📦 Upgrading %s from %s to %s...\n", u.Name, u.Installed, target)
+
+	var before *snapshot.Snapshot
+	if !dryrun.Enabled() {
+		snap, err := snapshot.Create(fmt.Sprintf("before upgrading %s %s", u.Component, u.Installed), false)
+		if err != nil {
+			return fmt.Errorf("could not snapshot the configuration: %v", err)
+		}
+		before = snap
+		fmt.Printf("📸 Snapshot %s taken before the upgrade\n", snap.ID)
+	}
+
+	if err := pkg.Upgrade(u.Unit != "", packages...); err != nil {
+		return fmt.Errorf("upgrade failed: %v", err)
+	}
+
+	if before != nil {
+		if changes, err := before.Diff(); err == nil && len(changes) > 0 {
+			ui.Warn("⚠️  The upgrade changed %d configuration file(s):\n", len(changes))
+			for _, c := range changes {
+				fmt.Printf("   %-8s %s\n", c.Kind, c.Path)
+			}
+			fmt.Printf("   Review with: sudo webstack snapshot diff %s\n", before.ID)
+		}
+		if snap, err := snapshot.Create(fmt.Sprintf("after upgrading %s to %s", u.Component, pkg.Version(u.Packages[0])), false); err == nil {
+			fmt.Printf("📸 Snapshot %s taken after the upgrade\n", snap.ID)
+		}
+	}
+
+	if u.Unit == "" {
+		fmt.Printf("✅ %s upgraded to %s\n", u.Name, pkg.Version(u.Packages[0]))
+		return nil
+	}
+
+	test := configTests[u.Component]
+	if version := strings.TrimPrefix(u.Component, "php"); version != u.Component {
+		test = func() error { return domain.TestPHPFPM(version) }
+	}
+	if test != nil && !dryrun.Enabled() {
+		if err := test(); err != nil {
+			ui.Fail("❌ The configuration test failed after the upgrade, %s was not restarted:\n", u.Unit)
+			fmt.Printf("   %v\n", err)
+			if before != nil {
+				fmt.Printf("   Fix the configuration (compare with 'sudo webstack snapshot diff %s') and restart %s\n", before.ID, u.Unit)
+			}
+			return fmt.Errorf("%s configuration test failed", u.Name)
+		}
+		fmt.Println("✅ Configuration test passed")
+	}
+
+	if err := service.Restart(u.Unit); err != nil && err != service.ErrNotInstalled {
+		return fmt.Errorf("could not restart %s: %v", u.Unit, err)
+	}
+	fmt.Printf("✅ %s upgraded to %s\n", u.Name, pkg.Version(u.Packages[0]))
+	return nil
+}
+
+// PinComponent holds the packages of a component at their installed
+// versions and records the pin in config.json, so neither 'webstack
+// upgrade' nor unattended-upgrades moves them
+func PinComponent(u ComponentUpgrade) error {
+	if err := pkg.Hold(u.Packages...); err != nil {
+		return fmt.Errorf("could not hold the packages of %s: %v", u.Name, err)
+	}
+	return config.Update(func(cfg *config.Config) error {
+		cfg.SetPin(u.Component, u.Installed)
+		return nil
+	})
+}
+
+// UnpinComponent releases a pin of PinComponent
+func UnpinComponent(u ComponentUpgrade) error {
+	if err := pkg.Unhold(u.Packages...); err != nil {
+		return fmt.Errorf("could not release the packages of %s: %v", u.Name, err)
+	}
+	return config.Update(func(cfg *config.Config) error {
+		cfg.SetPin(u.Component, "")
+		return nil
+	})
+}
@@ -0,0 +1,182 @@
+// Package logging keeps a structured log of every command run: the lines
+// printed to the user and the debug records of the internal packages
+// (commands executed, files written, service actions). Records go to the
+// persistent log /var/log/webstack/cli.log, to an optional --log-file and,
+// with --verbose, the debug records also to stderr. Internal packages log
+// with the log/slog package functions once Setup has installed the logger.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultFile is the persistent log every command appends to
+const DefaultFile = "/var/log/webstack/cli.log"
+
+// Formats of the log files
+const (
+	Text = "text"
+	JSON = "json"
+)
+
+// Options controls where records are written
+type Options struct {
+	Verbose bool   // Print debug records to stderr and keep them in the log files
+	Format  string // Format of the log files: text (key=value) or json
+	File    string // Log file written in addition to DefaultFile
+}
+
+var files []*os.File
+
+// Until Setup runs (e.g. for --help) nothing is logged
+func init() {
+	slog.SetDefault(slog.New(fanout(nil)))
+}
+
+// Setup installs the default slog logger. The persistent log is skipped
+// when it cannot be opened (e.g. without root), an explicit log file is not.
+func Setup(opts Options) error {
+	if opts.Format == "" {
+		opts.Format = Text
+	}
+	if opts.Format != Text && opts.Format != JSON {
+		return fmt.Errorf("Invalid log format %s (use %s or %s)", opts.Format, Text, JSON)
+	}
+
+	level := slog.LevelInfo
+	if opts.Verbose {
+		level = slog.LevelDebug
+	}
+
+	var writers []io.Writer
+	if f, err := open(DefaultFile); err == nil {
+		writers = append(writers, f)
+	}
+	if opts.File != "" {
+		f, err := open(opts.File)
+		if err != nil {
+			return fmt.Errorf("could not open the log file %s: %v", opts.File, err)
+		}
+		writers = append(writers, f)
+	}
+
+	var handlers fanout
+	if len(writers) > 0 {
+		handlerOpts := &slog.HandlerOptions{Level: level}
+		if opts.Format == JSON {
+			handlers = append(handlers, slog.NewJSONHandler(io.MultiWriter(writers...), handlerOpts))
+		} else {
+			handlers = append(handlers, slog.NewTextHandler(io.MultiWriter(writers...), handlerOpts))
+		}
+	}
+	if opts.Verbose {
+		// The printed output is already on the terminal, only the debug
+		// records are added to it
+		handlers = append(handlers, debugOnly{slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level:       slog.LevelDebug,
+			ReplaceAttr: dropTime,
+		})})
+	}
+
+	slog.SetDefault(slog.New(handlers).With("pid", os.Getpid()))
+	return nil
+}
+
+// Close closes the log files
+func Close() {
+	for _, f := range files {
+		f.Close()
+	}
+	files = nil
+}
+
+// Output logs a line printed to the user at the level of its marker
+func Output(level slog.Level, line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	slog.Log(context.Background(), level, line, "source", "output")
+}
+
+func open(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, f)
+	return f, nil
+}
+
+func dropTime(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.TimeKey {
+		return slog.Attr{}
+	}
+	return a
+}
+
+// fanout passes records to several handlers
+type fanout []slog.Handler
+
+func (h fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h fanout) Handle(ctx context.Context, r slog.Record) error {
+	var first error
+	for _, handler := range h {
+		if handler.Enabled(ctx, r.Level) {
+			if err := handler.Handle(ctx, r.Clone()); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+func (h fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := make(fanout, len(h))
+	for i, handler := range h {
+		next[i] = handler.WithAttrs(attrs)
+	}
+	return next
+}
+
+func (h fanout) WithGroup(name string) slog.Handler {
+	next := make(fanout, len(h))
+	for i, handler := range h {
+		next[i] = handler.WithGroup(name)
+	}
+	return next
+}
+
+// debugOnly passes only debug records to its handler
+type debugOnly struct {
+	slog.Handler
+}
+
+func (h debugOnly) Enabled(ctx context.Context, level slog.Level) bool {
+	return level < slog.LevelInfo && h.Handler.Enabled(ctx, level)
+}
+
+func (h debugOnly) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return debugOnly{h.Handler.WithAttrs(attrs)}
+}
+
+func (h debugOnly) WithGroup(name string) slog.Handler {
+	return debugOnly{h.Handler.WithGroup(name)}
+}
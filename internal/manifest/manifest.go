@@ -0,0 +1,505 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/prompt"
+	"webstack-cli/internal/ssl"
+	"webstack-cli/internal/templates"
+	"webstack-cli/internal/ui"
+	"webstack-cli/internal/worker"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest is the desired set of domains, read from a YAML (or JSON) file:
+//
+//	email: admin@example.com
+//	domains:
+//	  - name: example.com
+//	    backend: nginx
+//	    php: "8.3"
+//	    ssl: letsencrypt
+//	    aliases: [www.example.com]
+type Manifest struct {
+	Email   string `yaml:"email"` // Let's Encrypt account (default: defaults.ssl_email)
+	Domains []Spec `yaml:"domains"`
+}
+
+// Spec is the desired state of a domain. An empty backend or PHP version
+// keeps the value of an existing domain and uses the defaults for a new one.
+type Spec struct {
+	Name     string   `yaml:"name"`
+	Backend  string   `yaml:"backend"`  // nginx, apache, static or proxy
+	PHP      string   `yaml:"php"`      // PHP version of nginx and apache domains
+	DocRoot  string   `yaml:"docroot"`  // Web root subfolder of htdocs (default: the preset's, else htdocs)
+	Root     string   `yaml:"root"`     // Absolute document root outside the domain folder
+	Preset   string   `yaml:"preset"`   // Framework preset, only set when the domain is created
+	Upstream string   `yaml:"upstream"` // App URL of proxy domains
+	SSL      string   `yaml:"ssl"`      // letsencrypt (or true), selfsigned, off (or false); empty leaves SSL alone
+	Aliases  []string `yaml:"aliases"`  // The www or apex name of the domain, redirected to it
+}
+
+// Plan actions
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Change is a step of the plan bringing the domains in line with the manifest
+type Change struct {
+	Action  string
+	Name    string
+	Details []string // The differences, e.g. "php: 8.1 → 8.3"
+
+	spec      Spec
+	backend   string
+	php       string
+	edit      domain.EditOptions
+	canonical string // Canonical host set after creating the domain
+	sslType   string // Certificate type to enable: letsencrypt or selfsigned
+	sslOff    bool
+}
+
+// Plan holds the changes and the domains left alone
+type Plan struct {
+	Changes   []Change
+	Unchanged []string
+	Unmanaged []string // Domains missing from the manifest, deleted with prune
+	email     string
+}
+
+// Load reads and validates a manifest
+func Load(path string) (*Manifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var m Manifest
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&m); err != nil {
+		return nil, fmt.Errorf("Invalid manifest %s: %v", path, err)
+	}
+
+	var problems []string
+	seen := map[string]bool{}
+	for i := range m.Domains {
+		s := &m.Domains[i]
+		s.Name = domain.Normalize(s.Name)
+		if s.Name == "" || strings.ContainsAny(s.Name, "/ \t") {
+			problems = append(problems, fmt.Sprintf("domain %d: invalid name %q", i+1, s.Name))
+			continue
+		}
+		if seen[s.Name] {
+			problems = append(problems, fmt.Sprintf("%s: listed twice", s.Name))
+		}
+		seen[s.Name] = true
+		for _, problem := range validate(s) {
+			problems = append(problems, s.Name+": "+problem)
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("Invalid manifest %s:\n  - %s", path, strings.Join(problems, "\n  - "))
+	}
+	return &m, nil
+}
+
+// validate checks a spec and normalizes its values
+func validate(s *Spec) []string {
+	var problems []string
+	s.Backend = strings.ToLower(s.Backend)
+	switch s.Backend {
+	case "", "nginx", "apache", "static", "proxy", "caddy":
+	default:
+		problems = append(problems, fmt.Sprintf("invalid backend %s (use nginx, apache, static, proxy or caddy)", s.Backend))
+	}
+	usesPHP := s.Backend != "static" && s.Backend != "proxy"
+	if s.PHP != "" && !usesPHP {
+		problems = append(problems, fmt.Sprintf("%s domains don't use php", s.Backend))
+	} else if s.PHP != "" && !domain.ValidPHPVersion(s.PHP) {
+		problems = append(problems, fmt.Sprintf("invalid PHP version %s", s.PHP))
+	}
+	if s.Upstream != "" && s.Backend != "proxy" {
+		problems = append(problems, "upstream is only used with backend proxy")
+	}
+	if s.DocRoot != "" && s.Root != "" {
+		problems = append(problems, "root replaces docroot, set only one")
+	}
+	if s.Root != "" {
+		if root, err := domain.CleanFolder(s.Root); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid root: %v", err))
+		} else {
+			s.Root = root
+		}
+	}
+	if s.Backend == "proxy" && (s.DocRoot != "" || s.Root != "" || s.Preset != "") {
+		problems = append(problems, "proxy domains don't use docroot, root or preset")
+	}
+	if s.Preset != "" && !validPreset(s.Preset) {
+		problems = append(problems, fmt.Sprintf("invalid preset %s (available: %s)", s.Preset, strings.Join(templates.ListPresets(), ", ")))
+	}
+
+	switch strings.ToLower(s.SSL) {
+	case "", "off", "false", "no":
+	case "true", "yes", "letsencrypt", "lets-encrypt":
+	case "selfsigned", "self-signed":
+	default:
+		problems = append(problems, fmt.Sprintf("invalid ssl %s (use letsencrypt, selfsigned or off)", s.SSL))
+	}
+
+	if _, err := canonical(*s); err != nil {
+		problems = append(problems, err.Error())
+	}
+	return problems
+}
+
+func validPreset(preset string) bool {
+	for _, p := range templates.ListPresets() {
+		if p == preset {
+			return true
+		}
+	}
+	return false
+}
+
+// canonical returns the canonical host setting matching the aliases: the
+// only alias supported is the www or apex name of the domain, which
+// redirects to it
+func canonical(s Spec) (string, error) {
+	if len(s.Aliases) == 0 {
+		return "", nil
+	}
+	alias := domain.Normalize(s.Aliases[0])
+	switch {
+	case len(s.Aliases) > 1:
+	case alias == "www."+s.Name:
+		return "apex", nil
+	case strings.HasPrefix(s.Name, "www.") && alias == strings.TrimPrefix(s.Name, "www."):
+		return "www", nil
+	}
+	return "", fmt.Errorf("unsupported aliases %s (only the www or apex name of the domain)", strings.Join(s.Aliases, ", "))
+}
+
+// sslState returns whether a spec wants SSL and the certificate type, and
+// whether it manages SSL at all
+func sslState(s Spec) (enabled bool, certType string, managed bool) {
+	switch strings.ToLower(s.SSL) {
+	case "":
+		return false, "", false
+	case "off", "false", "no":
+		return false, "", true
+	case "selfsigned", "self-signed":
+		return true, "selfsigned", true
+	}
+	return true, "letsencrypt", true
+}
+
+// NewPlan compares the manifest with the configured domains. Domains missing
+// from the manifest are only deleted with prune.
+func NewPlan(m *Manifest, prune bool) (*Plan, error) {
+	current, err := domain.All()
+	if err != nil {
+		return nil, fmt.Errorf("could not load domains: %v", err)
+	}
+	byName := map[string]domain.Domain{}
+	for _, d := range current {
+		byName[d.Name] = d
+	}
+
+	plan := &Plan{email: m.Email}
+	listed := map[string]bool{}
+	for _, s := range m.Domains {
+		listed[s.Name] = true
+		d, exists := byName[s.Name]
+		if !exists {
+			if s.Backend == "proxy" && s.Upstream == "" {
+				return nil, fmt.Errorf("%s: the proxy backend needs an upstream", s.Name)
+			}
+			plan.Changes = append(plan.Changes, createChange(s))
+			continue
+		}
+		change, err := updateChange(s, d)
+		if err != nil {
+			return nil, err
+		}
+		if len(change.Details) == 0 {
+			plan.Unchanged = append(plan.Unchanged, s.Name)
+			continue
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	for _, d := range current {
+		if listed[d.Name] {
+			continue
+		}
+		if prune {
+			plan.Changes = append(plan.Changes, Change{Action: ActionDelete, Name: d.Name})
+		} else {
+			plan.Unmanaged = append(plan.Unmanaged, d.Name)
+		}
+	}
+	return plan, nil
+}
+
+func createChange(s Spec) Change {
+	c := Change{Action: ActionCreate, Name: s.Name, spec: s, backend: s.Backend, php: s.PHP}
+	if c.backend == "" {
+		c.backend = domain.DefaultBackend()
+	}
+	if c.php == "" && c.backend != "static" && c.backend != "proxy" {
+		c.php = domain.DefaultPHPVersion()
+	}
+	c.canonical, _ = canonical(s)
+
+	c.Details = append(c.Details, "backend: "+c.backend)
+	if c.php != "" {
+		c.Details = append(c.Details, "php: "+c.php)
+	}
+	if s.Upstream != "" {
+		c.Details = append(c.Details, "upstream: "+s.Upstream)
+	}
+	if s.Preset != "" {
+		c.Details = append(c.Details, "preset: "+s.Preset)
+	}
+	if s.Root != "" {
+		c.Details = append(c.Details, "root: "+s.Root)
+	} else if docRoot := specDocRoot(s, s.Preset); docRoot != "" {
+		c.Details = append(c.Details, "docroot: "+docRoot)
+	}
+	if len(s.Aliases) > 0 {
+		c.Details = append(c.Details, "aliases: "+strings.Join(s.Aliases, ", "))
+	}
+	if enabled, certType, _ := sslState(s); enabled {
+		c.sslType = certType
+		c.Details = append(c.Details, "ssl: "+certType)
+	}
+	return c
+}
+
+// updateChange compares a spec with an existing domain
+func updateChange(s Spec, d domain.Domain) (Change, error) {
+	c := Change{Action: ActionUpdate, Name: s.Name, spec: s}
+	differs := func(field, from, to string) {
+		if from == "" {
+			from = "(none)"
+		}
+		if to == "" {
+			to = "(none)"
+		}
+		c.Details = append(c.Details, fmt.Sprintf("%s: %s → %s", field, from, to))
+	}
+
+	if s.Preset != "" && s.Preset != d.Preset {
+		return c, fmt.Errorf("%s: the preset can only be set when the domain is created (current: %s, manifest: %s)", s.Name, orNone(d.Preset), s.Preset)
+	}
+
+	backend := d.Backend
+	if s.Backend != "" && s.Backend != d.Backend {
+		backend = s.Backend
+		c.backend = s.Backend
+		differs("backend", d.Backend, s.Backend)
+	}
+	usesPHP := backend != "static" && backend != "proxy"
+	if usesPHP && s.PHP != "" && s.PHP != d.PHPVersion {
+		c.php = s.PHP
+		differs("php", d.PHPVersion, s.PHP)
+	}
+	if backend == "proxy" {
+		if s.Upstream == "" && d.Backend != "proxy" {
+			return c, fmt.Errorf("%s: the proxy backend needs an upstream", s.Name)
+		}
+		if s.Upstream != "" && strings.TrimRight(s.Upstream, "/") != strings.TrimRight(d.Upstream, "/") {
+			c.edit.Upstream = s.Upstream
+			differs("upstream", d.Upstream, s.Upstream)
+		}
+	}
+
+	// The document root is declarative: without root or docroot the domain
+	// serves htdocs, or the preset's subfolder of it
+	if backend != "proxy" {
+		if s.Root != "" {
+			if d.DocumentRoot != s.Root {
+				c.edit.Root = s.Root
+				differs("root", d.DocumentRoot, s.Root)
+			}
+		} else if docRoot := specDocRoot(s, d.Preset); d.CustomRoot() || d.DocRoot != docRoot {
+			c.edit.DocRoot = docRoot
+			if docRoot == "" {
+				c.edit.DocRoot = "."
+			}
+			from := filepath.Join("htdocs", d.DocRoot)
+			if d.CustomRoot() {
+				from = d.DocumentRoot
+			}
+			differs("docroot", from, filepath.Join("htdocs", docRoot))
+		}
+	}
+
+	want, _ := canonical(s)
+	if want != d.Canonical {
+		c.edit.Canonical = want
+		if want == "" {
+			c.edit.Canonical = "none"
+		}
+		differs("canonical", d.Canonical, want)
+	}
+
+	if enabled, certType, managed := sslState(s); managed && enabled != d.SSLEnabled {
+		if enabled {
+			c.sslType = certType
+			differs("ssl", "off", certType)
+		} else {
+			c.sslOff = true
+			differs("ssl", "on", "off")
+		}
+	}
+	return c, nil
+}
+
+// specDocRoot returns the htdocs subfolder a spec serves
+func specDocRoot(s Spec, preset string) string {
+	docRoot := strings.Trim(s.DocRoot, "/")
+	if docRoot == "." {
+		docRoot = ""
+	}
+	if s.DocRoot == "" && s.Root == "" {
+		docRoot = domain.PresetDocRoot(preset)
+	}
+	return docRoot
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
+
+// Empty reports whether the plan changes nothing
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// Print shows the plan
+func (p *Plan) Print() {
+	counts := map[string]int{}
+	for _, c := range p.Changes {
+		counts[c.Action]++
+	}
+	fmt.Printf("📋 Plan: %d to create, %d to update, %d to delete, %d unchanged\n",
+		counts[ActionCreate], counts[ActionUpdate], counts[ActionDelete], len(p.Unchanged))
+
+	symbols := map[string]string{ActionCreate: "+", ActionUpdate: "~", ActionDelete: "-"}
+	for _, c := range p.Changes {
+		fmt.Printf("  %s %s\n", symbols[c.Action], c.Name)
+		for _, detail := range c.Details {
+			fmt.Printf("      %s\n", detail)
+		}
+	}
+	if len(p.Unmanaged) > 0 {
+		fmt.Printf("ℹ️  Not in the manifest (kept, --prune deletes them): %s\n", strings.Join(p.Unmanaged, ", "))
+	}
+}
+
+// Confirm asks before the plan is applied, unless yes is set. Plans that
+// delete domains (--prune) are refused under WEBSTACK_FORBID_DESTRUCTIVE
+// unless --force is given.
+func (p *Plan) Confirm(yes bool) bool {
+	deletions := 0
+	for _, c := range p.Changes {
+		if c.Action == ActionDelete {
+			deletions++
+		}
+	}
+	if deletions > 0 && !prompt.Allow(fmt.Sprintf("delete %d domain(s) missing from the manifest", deletions)) {
+		return false
+	}
+	return yes || prompt.Confirm("Apply these changes?")
+}
+
+// Apply carries out the plan: creates and updates in manifest order, then
+// deletions. Each change is verified against the manifest afterwards; it
+// returns the names of the domains that failed.
+func (p *Plan) Apply() []string {
+	var failed []string
+	for _, c := range p.Changes {
+		fmt.Println()
+		fmt.Printf("==> %s %s\n", c.Action, c.Name)
+		var ok bool
+		switch c.Action {
+		case ActionCreate:
+			ok = p.create(c)
+		case ActionUpdate:
+			ok = p.update(c)
+		case ActionDelete:
+			domain.DeleteWithOptions(c.Name, domain.DeleteOptions{KeepFolder: true})
+			ok = !domain.DomainExists(c.Name)
+			if ok {
+				// Workers of a deleted domain would restart forever
+				worker.RemoveDomain(c.Name)
+			}
+		}
+		if !ok {
+			ui.Fail("❌ Could not %s %s\n", c.Action, c.Name)
+			failed = append(failed, c.Name)
+		}
+	}
+	return failed
+}
+
+func (p *Plan) create(c Change) bool {
+	domain.Add(c.Name, c.backend, c.php, domain.AddOptions{
+		DocRoot:  c.spec.DocRoot,
+		Root:     c.spec.Root,
+		Preset:   c.spec.Preset,
+		Upstream: c.spec.Upstream,
+	})
+	if !domain.DomainExists(c.Name) {
+		return false
+	}
+	if c.canonical != "" {
+		domain.Edit(c.Name, "", "", domain.EditOptions{Canonical: c.canonical})
+	}
+	if c.sslType != "" {
+		ssl.EnableWithType(c.Name, p.email, c.sslType)
+	}
+	return p.verify(c.spec)
+}
+
+func (p *Plan) update(c Change) bool {
+	e := c.edit
+	if c.backend != "" || c.php != "" || e.DocRoot != "" || e.Root != "" || e.Canonical != "" || e.Upstream != "" {
+		domain.Edit(c.Name, c.backend, c.php, e)
+	}
+	if c.sslType != "" {
+		ssl.EnableWithType(c.Name, p.email, c.sslType)
+	}
+	if c.sslOff {
+		ssl.Disable(c.Name)
+	}
+	return p.verify(c.spec)
+}
+
+// verify reports whether a domain matches its spec after applying it
+func (p *Plan) verify(s Spec) bool {
+	d, err := domain.GetDomain(s.Name)
+	if err != nil {
+		return false
+	}
+	c, err := updateChange(s, *d)
+	if err != nil {
+		return false
+	}
+	for _, detail := range c.Details {
+		ui.Warn("⚠️  Not applied: %s\n", detail)
+	}
+	return len(c.Details) == 0
+}
@@ -0,0 +1,490 @@
+package monitor
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/notify"
+	"webstack-cli/internal/osinfo"
+	"webstack-cli/internal/phpfpm"
+	"webstack-cli/internal/service"
+	"webstack-cli/internal/ssl"
+	"webstack-cli/internal/store"
+	"webstack-cli/internal/ui"
+)
+
+// Settings managed with 'webstack config set'
+const (
+	IntervalKey      = "monitor.interval"       // Seconds between two rounds of checks
+	DiskThresholdKey = "monitor.disk_threshold" // Used space in percent that raises disk.full
+	CertDaysKey      = "monitor.cert_days"      // Days before expiry that raise ssl.expiring
+)
+
+const (
+	defaultInterval      = 60
+	defaultDiskThreshold = 90
+	defaultCertDays      = 14
+)
+
+const (
+	unitName = "webstack-monitor"
+	unitFile = "/etc/systemd/system/webstack-monitor.service"
+	stateDir = "/var/lib/webstack/monitor"
+)
+
+// stateStore keeps the last result of every check, so notifications fire
+// only when a check changes between ok and failing
+var stateStore = store.File{Path: filepath.Join(stateDir, "state.json"), Schema: 1}
+
+// Filesystems checked for free space; paths on the same filesystem are
+// reported once
+var diskPaths = []string{"/", "/var/www", "/var/lib/mysql", "/var/lib/postgresql", "/var/backups"}
+
+// Services checked for liveness, besides the PHP-FPM versions found
+var serviceUnits = []string{"nginx", "apache2", "mysql", "mariadb", "postgresql", "redis-server", "memcached", "bind9", "postfix", "dovecot", "vsftpd"}
+
+// Result is the outcome of one check
+type Result struct {
+	Check     string    `json:"check"`  // http, service, disk, ssl or fpm
+	Target    string    `json:"target"` // Domain, unit, mount point or pool
+	OK        bool      `json:"ok"`
+	Message   string    `json:"message"`
+	Since     time.Time `json:"since"` // When the check last changed between ok and failing
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+func (r Result) key() string {
+	return r.Check + ":" + r.Target
+}
+
+// State is the content of the state file
+type State struct {
+	CheckedAt time.Time         `json:"checked_at"`
+	Results   map[string]Result `json:"results"`
+}
+
+// thresholds are read from config.json at the start of every round, so a
+// change applies without restarting the service
+type thresholds struct {
+	interval time.Duration
+	disk     int
+	certDays int
+}
+
+func loadThresholds() thresholds {
+	cfg, err := config.Load()
+	if err != nil {
+		cfg = config.DefaultConfig()
+	}
+	return thresholds{
+		interval: time.Duration(cfg.GetInt(IntervalKey, defaultInterval)) * time.Second,
+		disk:     cfg.GetInt(DiskThresholdKey, defaultDiskThreshold),
+		certDays: cfg.GetInt(CertDaysKey, defaultCertDays),
+	}
+}
+
+// Run checks the server every interval until it is stopped. It is the main
+// loop of the webstack-monitor service.
+func Run() {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	fmt.Println("🩺 WebStack monitor started")
+	for {
+		t := loadThresholds()
+		results := Check(t)
+		failing := 0
+		for _, r := range results {
+			if !r.OK {
+				failing++
+			}
+		}
+		fmt.Printf("Checked %d targets, %d failing\n", len(results), failing)
+
+		select {
+		case <-stop:
+			fmt.Println("⏹️  WebStack monitor stopped")
+			return
+		case <-time.After(t.interval):
+		}
+	}
+}
+
+// RunOnce runs every check once, records the results and returns them
+func RunOnce() []Result {
+	return Check(loadThresholds())
+}
+
+// Check runs every check, sends notifications for checks that started or
+// stopped failing and records the results
+func Check(t thresholds) []Result {
+	now := time.Now()
+	var results []Result
+	results = append(results, checkServices()...)
+	results = append(results, checkHTTP()...)
+	results = append(results, checkDisks(t.disk)...)
+	results = append(results, checkCertificates(t.certDays)...)
+	results = append(results, checkFPMPools()...)
+
+	var state State
+	err := stateStore.Update(&state, func() error {
+		previous := state.Results
+		state.Results = map[string]Result{}
+		for i := range results {
+			r := &results[i]
+			r.CheckedAt = now
+			r.Since = now
+			prev, seen := previous[r.key()]
+			if seen && prev.OK == r.OK {
+				r.Since = prev.Since
+			} else if !r.OK {
+				alert(*r)
+			} else if seen {
+				notify.Send(notify.Recovered, domainOf(*r), fmt.Sprintf("Recovered: %s %s", r.Check, r.Target), r.Message)
+			}
+			state.Results[r.key()] = *r
+		}
+		state.CheckedAt = now
+		return nil
+	})
+	if err != nil {
+		ui.Warn("⚠️  Warning: Could not record the results: %v\n", err)
+	}
+	return results
+}
+
+// Status returns the results of the last round of checks
+func Status() (*State, error) {
+	var state State
+	if err := stateStore.Load(&state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Sorted returns the results of a state ordered by check and target
+func (s *State) Sorted() []Result {
+	var results []Result
+	for _, r := range s.Results {
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].key() < results[j].key() })
+	return results
+}
+
+func alert(r Result) {
+	events := map[string]string{
+		"http":    notify.HTTPDown,
+		"service": notify.ServiceDown,
+		"disk":    notify.DiskFull,
+		"ssl":     notify.SSLExpiring,
+		"fpm":     notify.FPMSaturated,
+	}
+	notify.Send(events[r.Check], domainOf(r), fmt.Sprintf("%s %s: %s", r.Check, r.Target, r.Message), r.Message)
+}
+
+func domainOf(r Result) string {
+	if r.Check == "http" || r.Check == "ssl" {
+		return r.Target
+	}
+	return ""
+}
+
+// checkServices reports enabled services that are not running
+func checkServices() []Result {
+	layout := osinfo.Current()
+	var units []string
+	for _, unit := range serviceUnits {
+		units = append(units, layout.Service(unit))
+	}
+	for _, version := range phpfpm.Versions() {
+		units = append(units, layout.PHPFPMService(version))
+	}
+
+	var results []Result
+	seen := map[string]bool{}
+	for _, unit := range units {
+		status, err := service.Status(unit)
+		// Aliases such as mysql -> mariadb resolve to the same unit
+		if err != nil || !status.Enabled || seen[status.Unit] {
+			continue
+		}
+		seen[status.Unit] = true
+		r := Result{Check: "service", Target: unit, OK: status.Active == "active", Message: status.Active}
+		results = append(results, r)
+	}
+	return results
+}
+
+// checkHTTP requests every domain from the local web server. Server errors
+// and no answer fail; 4xx answers mean the site is up.
+func checkHTTP() []Result {
+	domains, err := domain.All()
+	if err != nil {
+		return nil
+	}
+	var results []Result
+	for _, d := range domains {
+		url, status, err := domain.Probe(d)
+		r := Result{Check: "http", Target: d.Name, OK: true}
+		switch {
+		case err != nil:
+			r.OK = false
+			r.Message = fmt.Sprintf("no response from %s: %v", url, err)
+		case status >= 500:
+			r.OK = false
+			r.Message = fmt.Sprintf("%s returned %d %s", url, status, http.StatusText(status))
+		default:
+			r.Message = fmt.Sprintf("%d %s", status, http.StatusText(status))
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// checkDisks reports filesystems with more than threshold percent used
+func checkDisks(threshold int) []Result {
+	paths := diskPaths
+	if cfg, err := config.Load(); err == nil {
+		paths = append(paths, cfg.WebRoot())
+	}
+
+	var results []Result
+	seen := map[[2]int32]bool{}
+	for _, path := range paths {
+		var fs syscall.Statfs_t
+		if err := syscall.Statfs(path, &fs); err != nil || fs.Blocks == 0 {
+			continue
+		}
+		if seen[fs.Fsid.X__val] {
+			continue
+		}
+		seen[fs.Fsid.X__val] = true
+
+		total := fs.Blocks * uint64(fs.Bsize)
+		free := fs.Bavail * uint64(fs.Bsize)
+		used := 100 - int(free*100/total)
+		results = append(results, Result{
+			Check:   "disk",
+			Target:  path,
+			OK:      used < threshold,
+			Message: fmt.Sprintf("%d%% used, %s free (threshold %d%%)", used, formatBytes(free), threshold),
+		})
+	}
+	return results
+}
+
+// checkCertificates reports enabled certificates expiring within days
+func checkCertificates(days int) []Result {
+	certs, err := ssl.Certificates()
+	if err != nil {
+		return nil
+	}
+	var results []Result
+	for _, cert := range certs {
+		if !cert.Enabled || cert.ExpiresAt.IsZero() {
+			continue
+		}
+		left := int(time.Until(cert.ExpiresAt).Hours() / 24)
+		r := Result{Check: "ssl", Target: cert.Domain, OK: left >= days}
+		if left < 0 {
+			r.Message = fmt.Sprintf("expired on %s", cert.ExpiresAt.Format("2006-01-02"))
+		} else {
+			r.Message = fmt.Sprintf("expires in %d days (%s)", left, cert.ExpiresAt.Format("2006-01-02"))
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// checkFPMPools reports PHP-FPM pools running pm.max_children workers, at
+// which point new requests queue up
+func checkFPMPools() []Result {
+	workers := fpmWorkers()
+	var results []Result
+	for _, version := range phpfpm.Versions() {
+		files, _ := filepath.Glob(filepath.Join(osinfo.Current().PHPPoolDir(version), "*.conf"))
+		for _, file := range files {
+			for pool, maxChildren := range poolLimits(file) {
+				if maxChildren == 0 {
+					continue
+				}
+				running := workers[version+"/"+pool]
+				results = append(results, Result{
+					Check:   "fpm",
+					Target:  fmt.Sprintf("php%s/%s", version, pool),
+					OK:      running < maxChildren,
+					Message: fmt.Sprintf("%d of %d workers (pm.max_children)", running, maxChildren),
+				})
+			}
+		}
+	}
+	return results
+}
+
+// poolLimits reads the pools of a pool file and their pm.max_children
+func poolLimits(file string) map[string]int {
+	limits := map[string]int{}
+	f, err := os.Open(file)
+	if err != nil {
+		return limits
+	}
+	defer f.Close()
+
+	pool := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			pool = strings.Trim(line, "[]")
+			if pool != "global" {
+				limits[pool] = 0
+			}
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if found && pool != "" && strings.TrimSpace(key) == "pm.max_children" {
+			limits[pool], _ = strconv.Atoi(strings.TrimSpace(value))
+		}
+	}
+	return limits
+}
+
+// fpmWorkers counts the running PHP-FPM workers per "<version>/<pool>". The
+// workers are named "php-fpm: pool <name>" and are children of the master
+// process "php-fpm: master process (/etc/php/<version>/fpm/php-fpm.conf)",
+// or (/etc/opt/remi/php<version>/php-fpm.conf) on RHEL.
+func fpmWorkers() map[string]int {
+	masters := map[string]string{} // pid -> version
+	type worker struct{ ppid, pool string }
+	var workers []worker
+
+	entries, _ := ioutil.ReadDir("/proc")
+	for _, entry := range entries {
+		pid := entry.Name()
+		if _, err := strconv.Atoi(pid); err != nil {
+			continue
+		}
+		cmdline, err := ioutil.ReadFile(filepath.Join("/proc", pid, "cmdline"))
+		if err != nil {
+			continue
+		}
+		title := strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+		switch {
+		case strings.HasPrefix(title, "php-fpm: master process"):
+			if i := strings.Index(title, "/etc/php/"); i >= 0 {
+				masters[pid] = strings.SplitN(title[i+len("/etc/php/"):], "/", 2)[0]
+			} else if i := strings.Index(title, "/etc/opt/remi/php"); i >= 0 {
+				masters[pid] = osinfo.Current().PHPVersion(strings.SplitN(title[i:], "/php-fpm.conf", 2)[0] + "/php-fpm.d")
+			}
+		case strings.HasPrefix(title, "php-fpm: pool "):
+			workers = append(workers, worker{ppid: parentPID(pid), pool: strings.TrimPrefix(title, "php-fpm: pool ")})
+		}
+	}
+
+	counts := map[string]int{}
+	for _, w := range workers {
+		if version, ok := masters[w.ppid]; ok {
+			counts[version+"/"+w.pool]++
+		}
+	}
+	return counts
+}
+
+func parentPID(pid string) string {
+	data, err := ioutil.ReadFile(filepath.Join("/proc", pid, "stat"))
+	if err != nil {
+		return ""
+	}
+	// The command in parentheses may contain spaces; the fields after it
+	// are state and ppid
+	fields := strings.Fields(string(data[strings.LastIndex(string(data), ")")+1:]))
+	if len(fields) < 2 {
+		return ""
+	}
+	return fields[1]
+}
+
+// Enable installs and starts the webstack-monitor service running this
+// binary
+func Enable() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("could not find the webstack binary: %v", err)
+	}
+	unit := fmt.Sprintf(`[Unit]
+Description=WebStack monitoring agent
+After=network-online.target
+
+[Service]
+ExecStart=%s monitor run --no-emoji --no-color
+Restart=always
+RestartSec=10
+Nice=10
+
+[Install]
+WantedBy=multi-user.target
+`, executable)
+
+	if err := dryrun.WriteFile(unitFile, []byte(unit), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", unitFile, err)
+	}
+	if err := dryrun.Run(exec.Command("systemctl", "daemon-reload")); err != nil {
+		return fmt.Errorf("systemctl daemon-reload failed: %v", err)
+	}
+	if err := dryrun.Run(exec.Command("systemctl", "enable", unitName)); err != nil {
+		return fmt.Errorf("could not enable %s: %v", unitName, err)
+	}
+	// Restart picks up a new binary or unit when it was already running
+	return service.Restart(unitName)
+}
+
+// Disable stops and removes the webstack-monitor service
+func Disable() error {
+	if _, err := os.Stat(unitFile); os.IsNotExist(err) {
+		return fmt.Errorf("the monitor is not enabled")
+	}
+	dryrun.Run(exec.Command("systemctl", "disable", "--now", unitName))
+	if err := dryrun.Remove(unitFile); err != nil {
+		return err
+	}
+	return dryrun.Run(exec.Command("systemctl", "daemon-reload"))
+}
+
+// Enabled reports whether the service is installed, and its systemd state
+func Enabled() (bool, string) {
+	if _, err := os.Stat(unitFile); os.IsNotExist(err) {
+		return false, ""
+	}
+	status, err := service.Status(unitName)
+	if err != nil {
+		return true, "unknown"
+	}
+	return true, status.Active
+}
+
+func formatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,166 @@
+// Package mysql runs the MySQL/MariaDB client without putting passwords on
+// its command line, and quotes the values and names put in SQL statements.
+package mysql
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/dryrun"
+)
+
+// literalEscaper escapes the characters MySQL string literals can't hold
+// as they are
+var literalEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`'`, `\'`,
+	"\x00", `\0`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\x1a", `\Z`,
+)
+
+// privileges are the privileges GRANT accepts from --privileges
+var privileges = map[string]bool{
+	"ALL": true, "ALL PRIVILEGES": true, "ALTER": true, "ALTER ROUTINE": true,
+	"CREATE": true, "CREATE ROUTINE": true, "CREATE TEMPORARY TABLES": true,
+	"CREATE USER": true, "CREATE VIEW": true, "DELETE": true, "DROP": true,
+	"EVENT": true, "EXECUTE": true, "FILE": true, "INDEX": true, "INSERT": true,
+	"LOCK TABLES": true, "PROCESS": true, "REFERENCES": true, "RELOAD": true,
+	"REPLICATION CLIENT": true, "REPLICATION SLAVE": true, "SELECT": true,
+	"SHOW DATABASES": true, "SHOW VIEW": true, "SHUTDOWN": true, "SUPER": true,
+	"TRIGGER": true, "UPDATE": true, "USAGE": true,
+}
+
+// optionEscaper escapes a value of a double-quoted option file setting
+var optionEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// RootPassword returns the root password saved by the installer, the
+// MariaDB one first when engine is "mariadb"
+func RootPassword(engine string) string {
+	keys := []string{"mysql_root_password", "mariadb_root_password"}
+	if engine == "mariadb" {
+		keys = []string{"mariadb_root_password", "mysql_root_password"}
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return ""
+	}
+	for _, key := range keys {
+		if pass, ok := cfg.GetDefault(key, "").(string); ok && pass != "" {
+			return pass
+		}
+	}
+	return ""
+}
+
+// Command returns a client command (mysql, mysqldump ...) logged in as
+// user. The password goes in an option file only root can read instead of
+// the argument list, where ps would show it. Call the returned function to
+// remove the file once the command finished.
+func Command(name, user, password string, args ...string) (*exec.Cmd, func(), error) {
+	cleanup := func() {}
+	var options []string
+	if password != "" {
+		path, err := optionFile(password)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		// The option file has to be the first argument
+		options = []string{"--defaults-extra-file=" + path}
+		cleanup = func() { os.Remove(path) }
+	}
+	options = append(options, "-u", user)
+	return exec.Command(name, append(options, args...)...), cleanup, nil
+}
+
+// Exec runs SQL statements, passed on stdin, and returns the client's
+// output. In dry-run mode it only prints the command.
+func Exec(user, password, statements string) ([]byte, error) {
+	cmd, cleanup, err := Command("mysql", user, password)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	cmd.Stdin = strings.NewReader(statements)
+	return dryrun.CombinedOutput(cmd)
+}
+
+// Query runs a query and returns its rows, tab-separated, without the
+// column names
+func Query(user, password, query string) ([]byte, error) {
+	cmd, cleanup, err := Command("mysql", user, password, "-sN")
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	cmd.Stdin = strings.NewReader(query)
+	return cmd.Output()
+}
+
+// Table runs a query and returns its result as a table, like the
+// interactive client shows it
+func Table(user, password, query string) ([]byte, error) {
+	cmd, cleanup, err := Command("mysql", user, password, "--table")
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	cmd.Stdin = strings.NewReader(query)
+	return cmd.CombinedOutput()
+}
+
+// Quote returns s as a string literal, e.g. for passwords and user names
+func Quote(s string) string {
+	return "'" + literalEscaper.Replace(s) + "'"
+}
+
+// QuoteIdent returns s as a quoted name of a database, table or column
+func QuoteIdent(s string) string {
+	return "`" + strings.ReplaceAll(s, "`", "``") + "`"
+}
+
+// Privileges checks a comma-separated list of privileges (SELECT,INSERT or
+// ALL) and returns it as GRANT takes it. Privilege names can't be quoted,
+// so anything but a known privilege is refused.
+func Privileges(list string) (string, error) {
+	var names []string
+	for _, given := range strings.Split(list, ",") {
+		name := strings.Join(strings.Fields(strings.ToUpper(given)), " ")
+		if !privileges[name] {
+			return "", fmt.Errorf("unknown privilege %q", strings.TrimSpace(given))
+		}
+		if name == "ALL" {
+			name = "ALL PRIVILEGES"
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ", "), nil
+}
+
+// Account returns the quoted 'user'@'host' of an account
+func Account(user, host string) string {
+	return Quote(user) + "@" + Quote(host)
+}
+
+// optionFile writes a [client] option file holding a password, readable by
+// its owner only, and returns its path
+func optionFile(password string) (string, error) {
+	f, err := ioutil.TempFile("", "webstack-mysql-*.cnf")
+	if err != nil {
+		return "", fmt.Errorf("could not create the MySQL option file: %v", err)
+	}
+	defer f.Close()
+	err = f.Chmod(0600)
+	if err == nil {
+		_, err = fmt.Fprintf(f, "[client]\npassword=\"%s\"\n", optionEscaper.Replace(password))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("could not write the MySQL option file: %v", err)
+	}
+	return f.Name(), nil
+}
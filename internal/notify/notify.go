@@ -0,0 +1,291 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/store"
+	"webstack-cli/internal/ui"
+)
+
+const configFile = "/etc/webstack/notify.json"
+
+// notifyStore holds webhook URLs and SMTP credentials, so only root reads it
+var notifyStore = store.File{Path: configFile, Schema: 1, Perm: 0600}
+
+const sendTimeout = 10 * time.Second
+
+// Events a channel can subscribe to
+const (
+	SSLRenewed      = "ssl.renewed"
+	SSLFailed       = "ssl.failed"
+	ServiceDown     = "service.down"
+	BackupCompleted = "backup.completed"
+	BackupFailed    = "backup.failed"
+	DomainAdded     = "domain.added"
+	DomainRemoved   = "domain.removed"
+	HTTPDown        = "http.down"
+	DiskFull        = "disk.full"
+	SSLExpiring     = "ssl.expiring"
+	FPMSaturated    = "fpm.saturated"
+	Recovered       = "monitor.recovered"
+	ConfigDrift     = "config.drift"
+	InstallDone     = "install.completed"
+)
+
+// Events lists every event with a description, for 'webstack notify events'
+var Events = []struct{ Name, Description string }{
+	{SSLRenewed, "A certificate was renewed"},
+	{SSLFailed, "A certificate could not be renewed"},
+	{ServiceDown, "A service stopped and could not be restarted, or was found stopped by 'webstack doctor' or the monitor"},
+	{BackupCompleted, "A backup was created"},
+	{BackupFailed, "A backup could not be created"},
+	{DomainAdded, "A domain was added"},
+	{DomainRemoved, "A domain was deleted"},
+	{HTTPDown, "The monitor got no answer or a server error from a domain"},
+	{DiskFull, "The monitor found a filesystem above monitor.disk_threshold"},
+	{SSLExpiring, "The monitor found a certificate expiring within monitor.cert_days"},
+	{FPMSaturated, "The monitor found a PHP-FPM pool at pm.max_children"},
+	{Recovered, "A problem found by the monitor is gone"},
+	{ConfigDrift, "A scheduled snapshot found configuration changed since the last snapshot"},
+	{InstallDone, "'webstack install all' finished, with its provisioning report"},
+}
+
+// Channel types
+var channelTypes = map[string]bool{"slack": true, "discord": true, "webhook": true, "email": true}
+
+// Config is the content of notify.json
+type Config struct {
+	Channels []Channel `json:"channels"`
+	SMTP     SMTP      `json:"smtp,omitempty"`
+}
+
+// Channel is a destination for notifications
+type Channel struct {
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`             // slack, discord, webhook or email
+	URL    string   `json:"url,omitempty"`    // Webhook URL for slack, discord and webhook
+	Secret string   `json:"secret,omitempty"` // Signs generic webhook payloads (X-Webstack-Signature)
+	To     []string `json:"to,omitempty"`     // Recipients for email
+	Events []string `json:"events,omitempty"` // Empty for every event
+}
+
+// SMTP is the mail server used for email channels. Without a host, mail is
+// handed to the local sendmail (Postfix from 'webstack install mail').
+type SMTP struct {
+	Host     string `json:"host,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	From     string `json:"from,omitempty"`
+}
+
+// Event is something that happened on the server
+type Event struct {
+	Type    string    `json:"event"`
+	Title   string    `json:"title"`
+	Message string    `json:"message,omitempty"`
+	Domain  string    `json:"domain,omitempty"`
+	Host    string    `json:"host"`
+	Time    time.Time `json:"time"`
+}
+
+// Load reads notify.json
+func Load() (*Config, error) {
+	var cfg Config
+	if err := notifyStore.Load(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Update changes notify.json under its lock
+func Update(fn func(*Config) error) error {
+	var cfg Config
+	return notifyStore.Update(&cfg, func() error {
+		return fn(&cfg)
+	})
+}
+
+// Send delivers an event to every channel subscribed to it. Delivery is best
+// effort: a failing channel prints a warning and never fails the operation
+// that raised the event.
+func Send(eventType, domainName, title, message string) {
+	cfg, err := Load()
+	if err != nil || len(cfg.Channels) == 0 {
+		return
+	}
+	event := newEvent(eventType, domainName, title, message)
+	for _, ch := range cfg.Channels {
+		if !ch.Subscribed(eventType) {
+			continue
+		}
+		if err := deliver(cfg, ch, event); err != nil {
+			ui.Warn("⚠️  Warning: Could not notify %s: %v\n", ch.Name, err)
+		}
+	}
+}
+
+// Test sends a test event to one channel
+func Test(cfg *Config, ch Channel) error {
+	return deliver(cfg, ch, newEvent("test", "", "Test notification", "Notifications from webstack reach this channel."))
+}
+
+func newEvent(eventType, domainName, title, message string) Event {
+	host, _ := os.Hostname()
+	return Event{Type: eventType, Title: title, Message: message, Domain: domainName, Host: host, Time: time.Now().UTC()}
+}
+
+// Subscribed reports whether the channel receives an event
+func (ch Channel) Subscribed(eventType string) bool {
+	if len(ch.Events) == 0 {
+		return true
+	}
+	for _, e := range ch.Events {
+		if e == eventType || e == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks a channel before it is saved
+func (ch Channel) Validate() error {
+	if ch.Name == "" {
+		return fmt.Errorf("a channel needs a name")
+	}
+	if !channelTypes[ch.Type] {
+		return fmt.Errorf("unknown channel type %q (use slack, discord, webhook or email)", ch.Type)
+	}
+	if ch.Type == "email" {
+		if len(ch.To) == 0 {
+			return fmt.Errorf("email channels need at least one recipient")
+		}
+	} else if !strings.HasPrefix(ch.URL, "https://") && !strings.HasPrefix(ch.URL, "http://") {
+		return fmt.Errorf("%s channels need an http(s) URL", ch.Type)
+	}
+	for _, e := range ch.Events {
+		if !knownEvent(e) {
+			return fmt.Errorf("unknown event %q (see 'webstack notify events')", e)
+		}
+	}
+	return nil
+}
+
+func knownEvent(name string) bool {
+	if name == "*" {
+		return true
+	}
+	for _, e := range Events {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func deliver(cfg *Config, ch Channel, event Event) error {
+	if dryrun.Enabled() {
+		fmt.Printf("🔎 [dry-run] would notify %s (%s): %s\n", ch.Name, ch.Type, event.Title)
+		return nil
+	}
+	switch ch.Type {
+	case "slack":
+		return post(ch.URL, map[string]string{"text": chatText(event)}, "")
+	case "discord":
+		return post(ch.URL, map[string]string{"content": chatText(event)}, "")
+	case "webhook":
+		return post(ch.URL, event, ch.Secret)
+	case "email":
+		return sendMail(cfg.SMTP, ch.To, event)
+	}
+	return fmt.Errorf("unknown channel type %q", ch.Type)
+}
+
+func chatText(e Event) string {
+	text := fmt.Sprintf("*[%s] %s*", e.Host, e.Title)
+	if e.Message != "" {
+		text += "\n" + e.Message
+	}
+	return text
+}
+
+// post sends a JSON payload, signing it with HMAC-SHA256 when a secret is set
+func post(url string, payload interface{}, secret string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "webstack-cli")
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set("X-Webstack-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	client := &http.Client{Timeout: sendTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+func sendMail(cfg SMTP, to []string, e Event) error {
+	from := cfg.From
+	if from == "" {
+		from = "webstack@" + e.Host
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: [%s] %s\r\n", e.Host, e.Title)
+	fmt.Fprintf(&msg, "Date: %s\r\n", e.Time.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n\r\nEvent: %s\r\nServer: %s\r\n", e.Message, e.Type, e.Host)
+	if e.Domain != "" {
+		fmt.Fprintf(&msg, "Domain: %s\r\n", e.Domain)
+	}
+
+	if cfg.Host == "" {
+		cmd := exec.Command("/usr/sbin/sendmail", append([]string{"-i", "-f", from}, to...)...)
+		cmd.Stdin = &msg
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("sendmail failed: %v %s (configure a server with 'webstack notify smtp')", err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+	}
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	// SendMail upgrades to STARTTLS when the server offers it
+	return smtp.SendMail(cfg.Host+":"+strconv.Itoa(port), auth, from, to, msg.Bytes())
+}
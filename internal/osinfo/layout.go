@@ -0,0 +1,134 @@
+package osinfo
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Layout describes how a distribution family packages the web stack: the
+// package manager, service names and configuration paths. Everything else
+// (the www-data user, /run/php sockets, sites-available/sites-enabled
+// directories) is set up the Debian way on every family.
+type Layout struct {
+	Family         string // "debian" or "rhel"
+	PackageManager string // "apt" or "dnf"
+	ApacheDir      string // Apache's ServerRoot
+	ApacheConf     string // Apache's main configuration file
+	ApacheCtl      string // Apache's control script
+	ApacheLogDir   string // Where Apache logs
+	services       map[string]string
+}
+
+// Debian is the layout of Debian, Ubuntu and their derivatives
+var Debian = Layout{
+	Family:         "debian",
+	PackageManager: "apt",
+	ApacheDir:      "/etc/apache2",
+	ApacheConf:     "/etc/apache2/apache2.conf",
+	ApacheCtl:      "apache2ctl",
+	ApacheLogDir:   "/var/log/apache2",
+}
+
+// RHEL is the layout of RHEL, AlmaLinux and Rocky Linux, with PHP from the
+// Remi repository
+var RHEL = Layout{
+	Family:         "rhel",
+	PackageManager: "dnf",
+	ApacheDir:      "/etc/httpd",
+	ApacheConf:     "/etc/httpd/conf/httpd.conf",
+	ApacheCtl:      "apachectl",
+	ApacheLogDir:   "/var/log/httpd",
+	services: map[string]string{
+		"apache2":       "httpd",
+		"mysql":         "mysqld",
+		"redis-server":  "redis",
+		"bind9":         "named",
+		"clamav-daemon": "clamd@scan",
+		"spamd":         "spamassassin",
+	},
+}
+
+var (
+	current     Layout
+	currentOnce sync.Once
+)
+
+// Current returns the layout of the running distribution. Hosts that can't
+// be detected, or run neither family, get the Debian layout.
+func Current() Layout {
+	currentOnce.Do(func() {
+		current = Debian
+		if info, err := Detect(); err == nil && info.UsesDnf() {
+			current = RHEL
+		}
+	})
+	return current
+}
+
+// IsRHEL reports whether this is the RHEL layout
+func (l Layout) IsRHEL() bool {
+	return l.Family == "rhel"
+}
+
+// Service returns the systemd unit of a service known by its Debian name
+// (apache2, mysql, php8.3-fpm, ...)
+func (l Layout) Service(name string) string {
+	if unit, ok := l.services[name]; ok {
+		return unit
+	}
+	if strings.HasPrefix(name, "php") && strings.HasSuffix(name, "-fpm") {
+		return l.PHPFPMService(strings.TrimSuffix(strings.TrimPrefix(name, "php"), "-fpm"))
+	}
+	return name
+}
+
+// PHPFPMService returns the PHP-FPM unit of a PHP version
+func (l Layout) PHPFPMService(version string) string {
+	if l.IsRHEL() {
+		return fmt.Sprintf("php%s-php-fpm", remiVersion(version))
+	}
+	return fmt.Sprintf("php%s-fpm", version)
+}
+
+// PHPFPMBinary returns the PHP-FPM binary of a PHP version, for -t
+func (l Layout) PHPFPMBinary(version string) string {
+	if l.IsRHEL() {
+		return fmt.Sprintf("/opt/remi/php%s/root/usr/sbin/php-fpm", remiVersion(version))
+	}
+	return "php-fpm" + version
+}
+
+// PHPPoolDir returns the directory of the PHP-FPM pools of a PHP version
+func (l Layout) PHPPoolDir(version string) string {
+	if l.IsRHEL() {
+		return fmt.Sprintf("/etc/opt/remi/php%s/php-fpm.d", remiVersion(version))
+	}
+	return fmt.Sprintf("/etc/php/%s/fpm/pool.d", version)
+}
+
+// PHPPoolGlob matches the pool directories of every PHP version; the
+// version is the part PHPVersion extracts
+func (l Layout) PHPPoolGlob() string {
+	if l.IsRHEL() {
+		return "/etc/opt/remi/php*/php-fpm.d"
+	}
+	return "/etc/php/*/fpm/pool.d"
+}
+
+// PHPVersion returns the PHP version of a directory matched by PHPPoolGlob
+func (l Layout) PHPVersion(poolDir string) string {
+	if l.IsRHEL() {
+		version := strings.TrimPrefix(strings.TrimSuffix(poolDir, "/php-fpm.d"), "/etc/opt/remi/php")
+		if len(version) < 2 {
+			return version
+		}
+		return version[:1] + "." + version[1:]
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(poolDir, "/etc/php/"), "/fpm/pool.d")
+}
+
+// remiVersion is the version as Remi writes it in package names, 83 for 8.3
+func remiVersion(version string) string {
+	return strings.ReplaceAll(version, ".", "")
+}
@@ -0,0 +1,104 @@
+package osinfo
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// osReleaseFile describes the running distribution on systemd-era systems
+const osReleaseFile = "/etc/os-release"
+
+// Info describes the running distribution
+type Info struct {
+	ID         string   // "ubuntu", "debian", "linuxmint", ...
+	IDLike     []string // Distributions this one derives from
+	VersionID  string   // "22.04", "12"
+	Codename   string   // "jammy", "bookworm"
+	PrettyName string   // "Ubuntu 22.04.4 LTS"
+}
+
+// Detect reads the distribution from /etc/os-release
+func Detect() (*Info, error) {
+	f, err := os.Open(osReleaseFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not detect the distribution: %v", err)
+	}
+	defer f.Close()
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		values[key] = strings.Trim(value, `"'`)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", osReleaseFile, err)
+	}
+
+	info := &Info{
+		ID:         values["ID"],
+		IDLike:     strings.Fields(values["ID_LIKE"]),
+		VersionID:  values["VERSION_ID"],
+		Codename:   values["VERSION_CODENAME"],
+		PrettyName: values["PRETTY_NAME"],
+	}
+	// Ubuntu derivatives (Mint, Pop!_OS) name the Ubuntu release they are
+	// built on, which is the one external repositories publish for
+	if codename := values["UBUNTU_CODENAME"]; codename != "" {
+		info.Codename = codename
+	}
+	if info.PrettyName == "" {
+		info.PrettyName = strings.TrimSpace(info.ID + " " + info.VersionID)
+	}
+	return info, nil
+}
+
+// IsUbuntu reports whether the distribution is Ubuntu or derives from it
+func (i *Info) IsUbuntu() bool {
+	return i.is("ubuntu")
+}
+
+// IsDebian reports whether the distribution is Debian or a Debian
+// derivative other than Ubuntu
+func (i *Info) IsDebian() bool {
+	return i.is("debian") && !i.IsUbuntu()
+}
+
+// UsesApt reports whether packages are installed with apt
+func (i *Info) UsesApt() bool {
+	return i.IsUbuntu() || i.IsDebian()
+}
+
+func (i *Info) is(id string) bool {
+	if i.ID == id {
+		return true
+	}
+	for _, like := range i.IDLike {
+		if like == id {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRHEL reports whether the distribution is Red Hat Enterprise Linux or a
+// rebuild of it (AlmaLinux, Rocky Linux, CentOS Stream, Oracle Linux)
+func (i *Info) IsRHEL() bool {
+	return i.is("rhel") || i.is("centos") || i.is("fedora")
+}
+
+// UsesDnf reports whether packages are installed with dnf
+func (i *Info) UsesDnf() bool {
+	return i.IsRHEL() && !i.UsesApt()
+}
+
+// MajorVersion returns the major release, "9" for 9.4
+func (i *Info) MajorVersion() string {
+	major, _, _ := strings.Cut(i.VersionID, ".")
+	return major
+}
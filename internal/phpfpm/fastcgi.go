@@ -0,0 +1,150 @@
+package phpfpm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// A minimal FastCGI client: enough to GET the status page of a pool
+// straight from its socket, without going through a web server.
+
+const (
+	fcgiVersion      = 1
+	fcgiBeginRequest = 1
+	fcgiEndRequest   = 3
+	fcgiParams       = 4
+	fcgiStdin        = 5
+	fcgiStdout       = 6
+	fcgiStderr       = 7
+	fcgiResponder    = 1
+	fcgiRequestID    = 1
+)
+
+func fastcgiGet(listen, path, query string, timeout time.Duration) ([]byte, error) {
+	stdout, stderr, err := fastcgiRequest(listen, path, query, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return parseResponse(stdout, stderr)
+}
+
+// Ping checks that PHP-FPM answers FastCGI requests on a socket (a path) or
+// host:port. Any complete response counts, including "File not found."
+func Ping(listen string) error {
+	_, _, err := fastcgiRequest(strings.TrimPrefix(listen, "unix:"), "/webstack-ping", "", 5*time.Second)
+	return err
+}
+
+func fastcgiRequest(listen, path, query string, timeout time.Duration) ([]byte, string, error) {
+	network, address := "unix", listen
+	if !strings.HasPrefix(listen, "/") {
+		network = "tcp"
+		if !strings.Contains(listen, ":") {
+			address = "127.0.0.1:" + listen
+		}
+	}
+	conn, err := net.DialTimeout(network, address, timeout)
+	if err != nil {
+		return nil, "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	var req bytes.Buffer
+	writeRecord(&req, fcgiBeginRequest, []byte{0, fcgiResponder, 0, 0, 0, 0, 0, 0})
+	writeRecord(&req, fcgiParams, encodeParams(map[string]string{
+		"GATEWAY_INTERFACE": "FastCGI/1.0",
+		"REQUEST_METHOD":    "GET",
+		"SCRIPT_NAME":       path,
+		"SCRIPT_FILENAME":   path,
+		"REQUEST_URI":       path + "?" + query,
+		"QUERY_STRING":      query,
+		"SERVER_PROTOCOL":   "HTTP/1.1",
+		"SERVER_SOFTWARE":   "webstack-cli",
+	}))
+	writeRecord(&req, fcgiParams, nil)
+	writeRecord(&req, fcgiStdin, nil)
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return nil, "", err
+	}
+
+	var stdout, stderr bytes.Buffer
+	reader := bufio.NewReader(conn)
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			return nil, "", fmt.Errorf("incomplete FastCGI response: %v", err)
+		}
+		recordType := header[1]
+		length := binary.BigEndian.Uint16(header[4:6])
+		padding := int(header[6])
+		content := make([]byte, int(length)+padding)
+		if _, err := io.ReadFull(reader, content); err != nil {
+			return nil, "", fmt.Errorf("incomplete FastCGI response: %v", err)
+		}
+		content = content[:length]
+
+		switch recordType {
+		case fcgiStdout:
+			stdout.Write(content)
+		case fcgiStderr:
+			stderr.Write(content)
+		case fcgiEndRequest:
+			return stdout.Bytes(), stderr.String(), nil
+		}
+	}
+}
+
+func writeRecord(buf *bytes.Buffer, recordType byte, content []byte) {
+	header := []byte{fcgiVersion, recordType, 0, fcgiRequestID, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(header[4:6], uint16(len(content)))
+	buf.Write(header)
+	buf.Write(content)
+}
+
+func encodeParams(params map[string]string) []byte {
+	var buf bytes.Buffer
+	for name, value := range params {
+		writeLength(&buf, len(name))
+		writeLength(&buf, len(value))
+		buf.WriteString(name)
+		buf.WriteString(value)
+	}
+	return buf.Bytes()
+}
+
+func writeLength(buf *bytes.Buffer, n int) {
+	if n < 128 {
+		buf.WriteByte(byte(n))
+		return
+	}
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(n)|1<<31)
+	buf.Write(b[:])
+}
+
+// parseResponse splits the CGI headers from the body and fails on an error
+// status, e.g. "File not found." when pm.status_path does not match
+func parseResponse(response []byte, stderr string) ([]byte, error) {
+	head, body, found := bytes.Cut(response, []byte("\r\n\r\n"))
+	if !found {
+		return nil, fmt.Errorf("malformed FastCGI response")
+	}
+	for _, line := range strings.Split(string(head), "\r\n") {
+		name, value, _ := strings.Cut(line, ":")
+		if strings.EqualFold(name, "Status") && !strings.HasPrefix(strings.TrimSpace(value), "200") {
+			detail := strings.TrimSpace(string(body))
+			if detail == "" {
+				detail = strings.TrimSpace(stderr)
+			}
+			return nil, fmt.Errorf("status page returned %s: %s", strings.TrimSpace(value), detail)
+		}
+	}
+	return body, nil
+}
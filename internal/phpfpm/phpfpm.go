@@ -0,0 +1,202 @@
+package phpfpm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"webstack-cli/internal/osinfo"
+)
+
+// StatusPath is the pm.status_path of the webstack pool templates
+const StatusPath = "/fpm-status"
+
+// Pool is a pool section of a PHP-FPM pool file
+type Pool struct {
+	Name       string
+	Version    string
+	File       string
+	Listen     string // Socket path, or host:port
+	StatusPath string // Empty when the pool has no status page
+	Slowlog    string // Empty when slow requests are not logged
+}
+
+// Status is the JSON status page of a pool
+type Status struct {
+	Pool               string `json:"pool"`
+	ProcessManager     string `json:"process manager"`
+	StartSince         int    `json:"start since"`
+	AcceptedConn       int    `json:"accepted conn"`
+	ListenQueue        int    `json:"listen queue"`
+	MaxListenQueue     int    `json:"max listen queue"`
+	ListenQueueLen     int    `json:"listen queue len"`
+	IdleProcesses      int    `json:"idle processes"`
+	ActiveProcesses    int    `json:"active processes"`
+	TotalProcesses     int    `json:"total processes"`
+	MaxActiveProcesses int    `json:"max active processes"`
+	MaxChildrenReached int    `json:"max children reached"`
+	SlowRequests       int    `json:"slow requests"`
+}
+
+// SlowRequest is an entry of a pool's slowlog
+type SlowRequest struct {
+	Time   string // As written by PHP-FPM, e.g. 15-Oct-2026 11:44:28
+	PID    string
+	Script string
+	Frame  string // Innermost call of the backtrace, e.g. "sleep() /var/www/app/index.php:3"
+}
+
+// Versions returns the PHP versions with a PHP-FPM configuration
+func Versions() []string {
+	layout := osinfo.Current()
+	dirs, _ := filepath.Glob(layout.PHPPoolGlob())
+	var versions []string
+	for _, dir := range dirs {
+		versions = append(versions, layout.PHPVersion(dir))
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// Pools reads the pools configured for a PHP version
+func Pools(version string) ([]Pool, error) {
+	files, err := filepath.Glob(filepath.Join(osinfo.Current().PHPPoolDir(version), "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no PHP-FPM pools found for PHP %s", version)
+	}
+	var pools []Pool
+	for _, file := range files {
+		filePools, err := readPoolFile(file, version)
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, filePools...)
+	}
+	return pools, nil
+}
+
+func readPoolFile(file, version string) ([]Pool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var pools []Pool
+	var current *Pool
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.Trim(line, "[]")
+			current = nil
+			if name != "global" {
+				pools = append(pools, Pool{Name: name, Version: version, File: file})
+				current = &pools[len(pools)-1]
+			}
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found || current == nil {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		// $pool is the only variable the webstack templates would use
+		value = strings.ReplaceAll(value, "$pool", current.Name)
+		switch strings.TrimSpace(key) {
+		case "listen":
+			current.Listen = value
+		case "pm.status_path":
+			current.StatusPath = value
+		case "slowlog":
+			current.Slowlog = value
+		}
+	}
+	return pools, scanner.Err()
+}
+
+// Status requests the status page of a pool over its FastCGI socket
+func (p Pool) Status() (*Status, error) {
+	if p.StatusPath == "" {
+		return nil, fmt.Errorf("pm.status_path is not set")
+	}
+	body, err := fastcgiGet(p.Listen, p.StatusPath, "json", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	var status Status
+	if err := json.Unmarshal(body, &status); err != nil {
+		return nil, fmt.Errorf("unexpected status page: %v", err)
+	}
+	return &status, nil
+}
+
+var slowHeader = regexp.MustCompile(`^\[([^\]]+)\]\s+\[pool ([^\]]+)\] pid (\d+)`)
+
+// slowlogTail limits how much of a large slowlog is read
+const slowlogTail = 512 * 1024
+
+// SlowRequests returns the last limit entries of the pool's slowlog, newest
+// first
+func (p Pool) SlowRequests(limit int) ([]SlowRequest, error) {
+	if p.Slowlog == "" {
+		return nil, nil
+	}
+	f, err := os.Open(p.Slowlog)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.Size() > slowlogTail {
+		f.Seek(-slowlogTail, 2)
+	}
+
+	var entries []SlowRequest
+	var current *SlowRequest
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := slowHeader.FindStringSubmatch(line); m != nil {
+			current = nil
+			if m[2] == p.Name {
+				entries = append(entries, SlowRequest{Time: m[1], PID: m[3]})
+				current = &entries[len(entries)-1]
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "script_filename = "):
+			current.Script = strings.TrimPrefix(line, "script_filename = ")
+		case strings.HasPrefix(line, "[0x") && current.Frame == "":
+			if i := strings.Index(line, "] "); i >= 0 {
+				current.Frame = line[i+2:]
+			}
+		}
+	}
+
+	// Newest first
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, scanner.Err()
+}
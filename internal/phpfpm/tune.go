@@ -0,0 +1,327 @@
+package phpfpm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/osinfo"
+	"webstack-cli/internal/service"
+	"webstack-cli/internal/ui"
+)
+
+// TuningKeys are the process manager directives 'webstack php tune' manages,
+// in the order they are written
+var TuningKeys = []string{
+	"pm",
+	"pm.max_children",
+	"pm.start_servers",
+	"pm.min_spare_servers",
+	"pm.max_spare_servers",
+	"pm.process_idle_timeout",
+	"pm.max_requests",
+}
+
+// Process managers
+var Managers = []string{"dynamic", "static", "ondemand"}
+
+// Tuning reads the process manager directives of the pool
+func (p Pool) Tuning() (map[string]string, error) {
+	content, err := ioutil.ReadFile(p.File)
+	if err != nil {
+		return nil, err
+	}
+	return readTuning(content, p.Name), nil
+}
+
+func readTuning(content []byte, pool string) map[string]string {
+	values := map[string]string{}
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.Trim(line, "[]")
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if found && section == pool && isTuningKey(strings.TrimSpace(key)) {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return values
+}
+
+func isTuningKey(key string) bool {
+	for _, k := range TuningKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// setTuning sets directives in the pool section of a pool file, replacing
+// the existing lines and adding missing ones after the last pm line. An
+// empty value removes the directive.
+func setTuning(content []byte, pool string, values map[string]string) []byte {
+	lines := strings.Split(string(content), "\n")
+	var out []string
+	section := ""
+	written := map[string]bool{}
+	lastPM := -1 // Index in out of the last pm line of the pool section
+	sectionEnd := -1
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			if section == pool && sectionEnd < 0 {
+				sectionEnd = len(out)
+			}
+			section = strings.Trim(trimmed, "[]")
+			out = append(out, line)
+			continue
+		}
+		key, _, found := strings.Cut(trimmed, "=")
+		key = strings.TrimSpace(key)
+		if found && section == pool && isTuningKey(key) {
+			if value, ok := values[key]; ok {
+				written[key] = true
+				if value == "" {
+					continue
+				}
+				line = key + " = " + value
+			}
+			out = append(out, line)
+			lastPM = len(out) - 1
+			continue
+		}
+		out = append(out, line)
+	}
+	if section == pool && sectionEnd < 0 {
+		sectionEnd = len(out)
+	}
+
+	var missing []string
+	for _, key := range TuningKeys {
+		if value, ok := values[key]; ok && value != "" && !written[key] {
+			missing = append(missing, key+" = "+value)
+		}
+	}
+	at := lastPM + 1
+	if lastPM < 0 {
+		at = sectionEnd
+	}
+	if len(missing) > 0 && at >= 0 {
+		out = append(out[:at], append(missing, out[at:]...)...)
+	}
+	return []byte(strings.Join(out, "\n"))
+}
+
+// Tune writes process manager directives into the pool, validates the
+// configuration with php-fpm -t and reloads PHP-FPM. The previous pool
+// file is restored when validation fails.
+func (p Pool) Tune(values map[string]string) error {
+	previous, err := ioutil.ReadFile(p.File)
+	if err != nil {
+		return err
+	}
+	content := setTuning(previous, p.Name, values)
+	if bytes.Equal(previous, content) {
+		return nil
+	}
+	if err := dryrun.WriteFile(p.File, content, 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", p.File, err)
+	}
+	if err := test(p.Version); err != nil {
+		ioutil.WriteFile(p.File, previous, 0644)
+		return err
+	}
+
+	unit := osinfo.Current().PHPFPMService(p.Version)
+	if err := service.Reload(unit); err != nil && err != service.ErrNotInstalled {
+		ui.Warn("⚠️  Warning: Could not reload %s: %v\n", unit, err)
+	}
+	return nil
+}
+
+// PreserveTuning carries the process manager directives of a pool file
+// about to be rewritten (e.g. by the installer or rebuild-configs) over to
+// its new content, so tuning done with 'webstack php tune' is kept
+func PreserveTuning(previous, content []byte, pool string) []byte {
+	values := readTuning(previous, pool)
+	if len(values) == 0 {
+		return content
+	}
+	// Directives of the template that were removed while tuning stay removed
+	for _, key := range TuningKeys {
+		if _, ok := values[key]; !ok {
+			values[key] = ""
+		}
+	}
+	return setTuning(content, pool, values)
+}
+
+func test(version string) error {
+	if dryrun.Enabled() {
+		return nil
+	}
+	binary := osinfo.Current().PHPFPMBinary(version)
+	if _, err := exec.LookPath(binary); err != nil {
+		return nil // PHP version not installed, nothing to validate
+	}
+	if output, err := exec.Command(binary, "-t").CombinedOutput(); err != nil {
+		return fmt.Errorf("%s -t failed:\n%s", binary, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// AutoReport explains how Auto computed pm.max_children
+type AutoReport struct {
+	MemTotal     uint64 // Bytes
+	MemAvailable uint64 // Bytes, plus the memory of the running PHP-FPM workers
+	Budget       uint64 // Bytes for this pool
+	WorkerSize   uint64 // Average resident size of a worker in bytes
+	Samples      int    // Workers the size was measured on; 0 means the default was used
+	Pools        int    // PHP-FPM pools sharing the memory
+}
+
+// headroom is the share of the available memory given to PHP-FPM
+const headroom = 0.8
+
+// defaultWorkerSize is assumed when no worker is running to measure
+const defaultWorkerSize = 64 << 20
+
+// Auto computes pm.max_children for the pool from the memory available to
+// PHP-FPM and the average size of its workers, and spare server counts
+// that fit it. The memory is shared evenly between all PHP-FPM pools.
+func (p Pool) Auto(pm string) (map[string]string, AutoReport, error) {
+	var report AutoReport
+	meminfo, err := readMeminfo()
+	if err != nil {
+		return nil, report, fmt.Errorf("could not read /proc/meminfo: %v", err)
+	}
+	report.MemTotal = meminfo["MemTotal"]
+
+	workers := workerSizes()
+	var fpmTotal, poolTotal, allTotal uint64
+	var poolCount, allCount int
+	for _, w := range workers {
+		fpmTotal += w.rss
+		allTotal += w.rss
+		allCount++
+		if w.pool == p.Name {
+			poolTotal += w.rss
+			poolCount++
+		}
+	}
+	report.MemAvailable = meminfo["MemAvailable"] + fpmTotal
+
+	switch {
+	case poolCount > 0:
+		report.WorkerSize, report.Samples = poolTotal/uint64(poolCount), poolCount
+	case allCount > 0:
+		report.WorkerSize, report.Samples = allTotal/uint64(allCount), allCount
+	default:
+		report.WorkerSize = defaultWorkerSize
+	}
+
+	for _, version := range Versions() {
+		pools, _ := Pools(version)
+		report.Pools += len(pools)
+	}
+	if report.Pools == 0 {
+		report.Pools = 1
+	}
+	report.Budget = uint64(float64(report.MemAvailable)*headroom) / uint64(report.Pools)
+
+	maxChildren := int(report.Budget / report.WorkerSize)
+	if maxChildren < 2 {
+		maxChildren = 2
+	}
+	if maxChildren > 500 {
+		maxChildren = 500
+	}
+	return Values(pm, maxChildren), report, nil
+}
+
+// Values returns the directives for a process manager and pm.max_children,
+// with spare server counts derived from it
+func Values(pm string, maxChildren int) map[string]string {
+	values := map[string]string{
+		"pm":                      pm,
+		"pm.max_children":         strconv.Itoa(maxChildren),
+		"pm.start_servers":        "",
+		"pm.min_spare_servers":    "",
+		"pm.max_spare_servers":    "",
+		"pm.process_idle_timeout": "",
+	}
+	switch pm {
+	case "dynamic":
+		minSpare := max(1, maxChildren/8)
+		maxSpare := max(minSpare+1, maxChildren/2)
+		start := max(minSpare, maxChildren/4)
+		values["pm.min_spare_servers"] = strconv.Itoa(minSpare)
+		values["pm.max_spare_servers"] = strconv.Itoa(min(maxSpare, maxChildren))
+		values["pm.start_servers"] = strconv.Itoa(min(start, maxSpare))
+	case "ondemand":
+		values["pm.process_idle_timeout"] = "10s"
+	}
+	return values
+}
+
+func readMeminfo() (map[string]uint64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info := map[string]uint64{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 {
+			kb, _ := strconv.ParseUint(fields[1], 10, 64)
+			info[strings.TrimSuffix(fields[0], ":")] = kb * 1024
+		}
+	}
+	return info, scanner.Err()
+}
+
+type worker struct {
+	pool string
+	rss  uint64
+}
+
+// workerSizes returns the resident size of every running PHP-FPM worker
+func workerSizes() []worker {
+	var workers []worker
+	dirs, _ := filepath.Glob("/proc/[0-9]*")
+	for _, dir := range dirs {
+		cmdline, err := ioutil.ReadFile(filepath.Join(dir, "cmdline"))
+		if err != nil {
+			continue
+		}
+		title := strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+		if !strings.HasPrefix(title, "php-fpm: pool ") {
+			continue
+		}
+		status, err := ioutil.ReadFile(filepath.Join(dir, "status"))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(status), "\n") {
+			if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "VmRSS:" {
+				kb, _ := strconv.ParseUint(fields[1], 10, 64)
+				workers = append(workers, worker{pool: strings.TrimPrefix(title, "php-fpm: pool "), rss: kb * 1024})
+			}
+		}
+	}
+	return workers
+}
@@ -0,0 +1,351 @@
+// Package pkg runs apt and dpkg (dnf and rpm on RHEL) for the rest of the
+// CLI. Every operation waits for the dpkg and apt locks (held by
+// unattended-upgrades for a while after boot, or by an apt run in another
+// shell), retries failures that go away on their own and returns the reason
+// the package manager printed in its error. Packages are always named the
+// Debian way and translated on RHEL.
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+	"webstack-cli/internal/dryrun"
+)
+
+var (
+	// AssumeReady skips waiting for the locks (--assume-apt-ready), for
+	// image builds where nothing else runs apt
+	AssumeReady bool
+
+	// LockTimeout is how long an operation waits for the locks
+	LockTimeout = 10 * time.Minute
+
+	// Attempts is how often an operation with a transient failure is tried
+	Attempts = 3
+)
+
+// lockFiles are the locks apt and dpkg take, the frontend lock first
+var lockFiles = []string{
+	"/var/lib/dpkg/lock-frontend",
+	"/var/lib/dpkg/lock",
+	"/var/lib/apt/lists/lock",
+	"/var/cache/apt/archives/lock",
+}
+
+// transientErrors are stderr messages of failures worth retrying: a lock
+// taken between the wait and the run, or a mirror that didn't answer
+var transientErrors = []string{
+	"Could not get lock",
+	"Unable to acquire the dpkg frontend lock",
+	"Unable to lock directory",
+	"is another process using it",
+	"Temporary failure resolving",
+	"Could not connect to",
+	"Connection timed out",
+	"Failed to fetch",
+	"Hash Sum mismatch",
+	"Unable to fetch some archives",
+	"Curl error",
+	"Cannot download",
+	"Failed to download metadata",
+}
+
+// Error is a failed package manager operation
+type Error struct {
+	Command  string   // apt-get install, dpkg --configure, dnf install ...
+	Packages []string // Packages the operation was given
+	ExitCode int      // -1 when the command could not be started
+	Stderr   string   // Last part of what the command printed on stderr
+	Err      error
+}
+
+func (e *Error) Error() string {
+	msg := e.Command
+	if len(e.Packages) > 0 {
+		msg += " " + strings.Join(e.Packages, " ")
+	}
+	msg += " failed"
+	if e.ExitCode >= 0 {
+		msg += fmt.Sprintf(" (exit status %d)", e.ExitCode)
+	} else if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if reason := e.Reason(); reason != "" {
+		msg += ": " + reason
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Reason returns the error lines of stderr (E: ..., dpkg: error ..., dnf's
+// Error: ...), or its last line when there are none
+func (e *Error) Reason() string {
+	var reasons, lines []string
+	for _, line := range strings.Split(e.Stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+		if strings.HasPrefix(line, "E: ") || strings.HasPrefix(line, "dpkg: error") || strings.HasPrefix(line, "Error: ") {
+			reasons = append(reasons, line)
+		}
+	}
+	if len(reasons) == 0 && len(lines) > 0 {
+		reasons = lines[len(lines)-1:]
+	}
+	if len(reasons) > 3 {
+		reasons = reasons[len(reasons)-3:]
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// Transient reports whether trying the operation again may succeed
+func (e *Error) Transient() bool {
+	for _, msg := range transientErrors {
+		if strings.Contains(e.Stderr, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// Update refreshes the package lists
+func Update() error {
+	if usesDnf() {
+		return dnf(false, nil, "makecache")
+	}
+	return aptGet(false, nil, "update")
+}
+
+// Install installs packages (name, name=version or name=version*)
+func Install(packages ...string) error {
+	if usesDnf() {
+		return dnf(false, packages, "install", "-y")
+	}
+	return aptGet(false, packages, "install", "-y")
+}
+
+// InstallMinimal installs packages without their recommended packages
+func InstallMinimal(packages ...string) error {
+	if usesDnf() {
+		return dnf(false, packages, "install", "-y", "--setopt=install_weak_deps=False")
+	}
+	return aptGet(false, packages, "install", "-y", "--no-install-recommends")
+}
+
+// Purge removes packages and their configuration; names may be globs
+// such as php8.1*
+func Purge(packages ...string) error {
+	if usesDnf() {
+		return dnf(false, packages, "remove", "-y")
+	}
+	return aptGet(false, packages, "purge", "-y")
+}
+
+// Autoremove removes packages nothing depends on anymore
+func Autoremove() error {
+	if usesDnf() {
+		return dnf(true, nil, "autoremove", "-y")
+	}
+	return aptGet(true, nil, "autoremove", "-y")
+}
+
+// Clean empties the package cache
+func Clean() error {
+	if usesDnf() {
+		return dnf(true, nil, "clean", "all")
+	}
+	if err := aptGet(true, nil, "clean"); err != nil {
+		return err
+	}
+	return aptGet(true, nil, "autoclean")
+}
+
+// FixBroken completes or removes half-installed dependencies. dnf
+// transactions don't leave any behind.
+func FixBroken() error {
+	if usesDnf() {
+		return nil
+	}
+	return aptGet(true, nil, "--fix-broken", "install", "-y")
+}
+
+// Configure finishes interrupted package configurations (dpkg --configure -a)
+func Configure() error {
+	if usesDnf() {
+		return nil
+	}
+	return run(true, nil, "dpkg", "--configure", "-a")
+}
+
+// ForcePurge removes packages with dpkg even when their scripts fail, the
+// last resort when apt purge leaves a package half-removed
+func ForcePurge(packages ...string) error {
+	if usesDnf() {
+		return run(true, rpmPackages(packages), "rpm", "-e", "--nodeps", "--noscripts")
+	}
+	return run(true, packages, "dpkg", "--purge", "--force-all")
+}
+
+// Installed reports whether a package is installed
+func Installed(name string) bool {
+	if usesDnf() {
+		return rpmInstalled(name)
+	}
+	output, err := exec.Command("dpkg-query", "-W", "-f=${Status}", name).Output()
+	return err == nil && strings.Contains(string(output), "install ok installed")
+}
+
+// Version returns the installed version of a package, or "" when it isn't
+// installed
+func Version(name string) string {
+	if usesDnf() {
+		return rpmVersion(name)
+	}
+	if !Installed(name) {
+		return ""
+	}
+	output, err := exec.Command("dpkg-query", "-W", "-f=${Version}", name).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// WaitLock waits until no other process holds the apt or dpkg locks, at
+// most LockTimeout. Callers that limit how long an operation may take wait
+// first, so the wait doesn't count against their limit. dnf waits for its
+// own lock.
+func WaitLock() error {
+	if AssumeReady || dryrun.Enabled() || usesDnf() {
+		return nil
+	}
+	pid, held := lockHolder()
+	if !held {
+		return nil
+	}
+	fmt.Printf("⏳ Waiting for the package manager lock held by %s (up to %s)...\n", processName(pid), LockTimeout)
+	deadline := time.Now().Add(LockTimeout)
+	for held {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the package manager lock is still held by %s after %s (use --assume-apt-ready to skip waiting)", processName(pid), LockTimeout)
+		}
+		time.Sleep(3 * time.Second)
+		pid, held = lockHolder()
+	}
+	return nil
+}
+
+// lockHolder returns the process holding one of the locks. Locks that
+// can't be opened (missing, or not running as root) count as free.
+func lockHolder() (int, bool) {
+	for _, path := range lockFiles {
+		file, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			continue
+		}
+		lock := syscall.Flock_t{Type: syscall.F_WRLCK, Whence: io.SeekStart}
+		err = syscall.FcntlFlock(file.Fd(), syscall.F_GETLK, &lock)
+		file.Close()
+		if err == nil && lock.Type != syscall.F_UNLCK {
+			return int(lock.Pid), true
+		}
+	}
+	return 0, false
+}
+
+// processName describes a process as name (pid N)
+func processName(pid int) string {
+	if pid <= 0 {
+		return "another process"
+	}
+	comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return fmt.Sprintf("pid %d", pid)
+	}
+	return fmt.Sprintf("%s (pid %d)", strings.TrimSpace(string(comm)), pid)
+}
+
+func aptGet(quiet bool, packages []string, args ...string) error {
+	return run(quiet, packages, "apt-get", args...)
+}
+
+// run runs an apt-get or dpkg operation with the packages appended to args,
+// waiting for the locks and retrying transient failures
+func run(quiet bool, packages []string, name string, args ...string) error {
+	// Named by the apt-get subcommand, or the dpkg action
+	command := name + " " + args[0]
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			command = name + " " + arg
+			break
+		}
+	}
+
+	var err error
+	for attempt := 1; attempt <= Attempts; attempt++ {
+		if err = WaitLock(); err != nil {
+			return &Error{Command: command, Packages: packages, ExitCode: -1, Err: err}
+		}
+		err = runOnce(quiet, command, packages, name, append(args, packages...)...)
+		var pkgErr *Error
+		if err == nil || !errors.As(err, &pkgErr) || !pkgErr.Transient() || attempt == Attempts {
+			return err
+		}
+		delay := time.Duration(attempt*10) * time.Second
+		fmt.Printf("🔄 %s failed (%s), retrying in %s (attempt %d of %d)\n", command, pkgErr.Reason(), delay, attempt+1, Attempts)
+		time.Sleep(delay)
+	}
+	return err
+}
+
+func runOnce(quiet bool, command string, packages []string, name string, args ...string) error {
+	stderr := &tailBuffer{}
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), "DEBIAN_FRONTEND=noninteractive", "DEBCONF_NONINTERACTIVE_SEEN=true")
+	if quiet {
+		cmd.Stderr = stderr
+	} else {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+	}
+
+	err := dryrun.Run(cmd)
+	if err == nil {
+		return nil
+	}
+	exitCode := -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	}
+	return &Error{Command: command, Packages: packages, ExitCode: exitCode, Stderr: stderr.String(), Err: err}
+}
+
+// tailBuffer keeps the last 8 KiB written to it
+type tailBuffer struct {
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	const max = 8 << 10
+	b.data = append(b.data, p...)
+	if len(b.data) > max {
+		b.data = b.data[len(b.data)-max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.data)
+}
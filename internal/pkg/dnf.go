@@ -0,0 +1,133 @@
+package pkg
+
+import (
+	"os/exec"
+	"regexp"
+	"strings"
+	"webstack-cli/internal/osinfo"
+)
+
+// rpmNames are the RHEL packages of Debian packages named differently; an
+// empty list means the package is part of another one there. Callers use
+// the Debian names everywhere.
+var rpmNames = map[string][]string{
+	"apache2":                    {"httpd", "mod_ssl"},
+	"postgresql":                 {"postgresql-server"},
+	"redis-server":               {"redis"},
+	"bind9":                      {"bind"},
+	"bind9-utils":                {"bind-utils"},
+	"bind9-doc":                  nil,
+	"dovecot-core":               {"dovecot"},
+	"dovecot-imapd":              nil,
+	"dovecot-pop3d":              nil,
+	"dovecot-lmtpd":              nil,
+	"clamav-daemon":              {"clamd"},
+	"amavisd-new":                nil,
+	"spamc":                      nil,
+	"iptables-persistent":        {"iptables-services"},
+	"software-properties-common": nil,
+}
+
+// phpPackage matches Debian PHP packages: php8.3-fpm, php8.3*
+var phpPackage = regexp.MustCompile(`^php(\d)\.(\d+)(.*)$`)
+
+// usesDnf reports whether this host installs packages with dnf
+func usesDnf() bool {
+	return osinfo.Current().PackageManager == "dnf"
+}
+
+func dnf(quiet bool, packages []string, args ...string) error {
+	return run(quiet, rpmPackages(packages), "dnf", args...)
+}
+
+// rpmPackages translates Debian package names, keeping versions
+// (nginx=1.24* becomes nginx-1.24*)
+func rpmPackages(packages []string) []string {
+	var rpms []string
+	for _, name := range packages {
+		name, version, versioned := strings.Cut(name, "=")
+		for _, rpm := range rpmName(name) {
+			if versioned {
+				rpm += "-" + version
+				versioned = false // Only the main package is pinned
+			}
+			rpms = append(rpms, rpm)
+		}
+	}
+	return rpms
+}
+
+// rpmName returns the RHEL packages of a Debian package. PHP comes from
+// Remi's parallel installable packages: php8.3-fpm is php83-php-fpm.
+func rpmName(name string) []string {
+	if m := phpPackage.FindStringSubmatch(name); m != nil {
+		suffix := m[3]
+		switch suffix {
+		case "-mysql":
+			suffix = "-mysqlnd"
+		case "-sqlite3":
+			suffix = "-pdo" // Holds pdo_sqlite and sqlite3
+		}
+		if strings.HasPrefix(suffix, "-") {
+			suffix = "-php" + suffix
+		}
+		return []string{"php" + m[1] + m[2] + suffix}
+	}
+	if rpms, ok := rpmNames[name]; ok {
+		return rpms
+	}
+	return []string{name}
+}
+
+// rpmInstalled reports whether the RHEL packages of a Debian package are
+// installed
+func rpmInstalled(name string) bool {
+	rpms := rpmName(name)
+	return len(rpms) > 0 && exec.Command("rpm", "-q", rpms[0]).Run() == nil
+}
+
+func rpmVersion(name string) string {
+	rpms := rpmName(name)
+	if len(rpms) == 0 {
+		return ""
+	}
+	output, err := exec.Command("rpm", "-q", "--qf", "%{VERSION}-%{RELEASE}", rpms[0]).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// dnfCandidate returns the version dnf upgrades a package to, else the
+// installed version
+func dnfCandidate(name string) string {
+	rpm := rpmName(name)
+	if len(rpm) == 0 {
+		return ""
+	}
+	output, _ := exec.Command("dnf", "-q", "list", "--upgrades", rpm[0]).Output()
+	for _, line := range strings.Split(string(output), "\n") {
+		// nginx.x86_64   1:1.24.0-1.el9   appstream
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && strings.HasPrefix(fields[0], rpm[0]+".") {
+			return fields[1]
+		}
+	}
+	return rpmVersion(name)
+}
+
+// dnfVersionlock installs the versionlock plugin unless dnf has it
+func dnfVersionlock() error {
+	if exec.Command("dnf", "versionlock", "--help").Run() == nil {
+		return nil
+	}
+	return dnf(true, []string{"python3-dnf-plugin-versionlock"}, "install", "-y")
+}
+
+// rpmMatching returns the installed RHEL packages matching the translation
+// of a Debian glob
+func rpmMatching(pattern string) []string {
+	args := append([]string{"-qa", "--qf", "%{NAME}\\n"}, rpmName(pattern)...)
+	output, _ := exec.Command("rpm", args...).Output()
+	return strings.Fields(string(output))
+}
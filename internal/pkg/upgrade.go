@@ -0,0 +1,127 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"webstack-cli/internal/dryrun"
+)
+
+// policyRC makes invoke-rc.d skip the service restarts of maintainer
+// scripts while it exists, see /usr/share/doc/init-system-helpers/README.policy-rc.d
+const policyRC = "/usr/sbin/policy-rc.d"
+
+// Candidate returns the version an upgrade of a package installs, which is
+// the installed version when it is up to date, or "" when no repository
+// has the package
+func Candidate(name string) string {
+	if usesDnf() {
+		return dnfCandidate(name)
+	}
+	output, err := exec.Command("apt-cache", "policy", name).Output()
+	if err != nil {
+		return ""
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if version := strings.TrimPrefix(line, "Candidate: "); version != line && version != "(none)" {
+			return version
+		}
+	}
+	return ""
+}
+
+// Changelog returns the changelog entries of a package newer than the
+// installed version. apt downloads it from the distribution; dnf needs
+// its changelog plugin (dnf-plugins-core).
+func Changelog(name, installed string) (string, error) {
+	if usesDnf() {
+		rpm := rpmName(name)
+		if len(rpm) == 0 {
+			return "", nil
+		}
+		output, err := exec.Command("dnf", "-q", "changelog", "--upgrades", rpm[0]).CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("dnf changelog %s failed: %s", name, strings.TrimSpace(string(output)))
+		}
+		return strings.TrimSpace(string(output)), nil
+	}
+
+	output, err := exec.Command("apt-get", "changelog", "-qq", name).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("apt-get changelog %s failed: %s", name, strings.TrimSpace(string(output)))
+	}
+	// Entries start with "<source> (<version>) <distribution>; urgency=..."
+	var entries []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if installed != "" && !strings.HasPrefix(line, " ") && strings.Contains(line, "("+installed+")") {
+			break
+		}
+		entries = append(entries, line)
+	}
+	return strings.TrimSpace(strings.Join(entries, "\n")), nil
+}
+
+// Upgrade upgrades installed packages (name, or name=version for a given
+// version), keeping configuration files changed since they were installed.
+// With noRestart the packages' scripts don't restart their services on
+// Debian and Ubuntu, so the caller can validate the configuration first.
+func Upgrade(noRestart bool, packages ...string) error {
+	if usesDnf() {
+		return dnf(false, packages, "upgrade", "-y")
+	}
+	if noRestart && !dryrun.Enabled() {
+		if _, err := os.Stat(policyRC); os.IsNotExist(err) {
+			if err := ioutil.WriteFile(policyRC, []byte("#!/bin/sh\n# Written by webstack upgrade\nexit 101\n"), 0755); err != nil {
+				return fmt.Errorf("could not write %s: %v", policyRC, err)
+			}
+			defer os.Remove(policyRC)
+		}
+	}
+	return aptGet(false, packages, "install", "-y", "--only-upgrade", "--allow-downgrades",
+		"-o", "Dpkg::Options::=--force-confdef", "-o", "Dpkg::Options::=--force-confold")
+}
+
+// Hold keeps packages at their installed version: apt-mark hold, or the
+// dnf versionlock plugin, which is installed when missing
+func Hold(packages ...string) error {
+	if usesDnf() {
+		if err := dnfVersionlock(); err != nil {
+			return err
+		}
+		return dnf(true, packages, "versionlock", "add")
+	}
+	return run(true, packages, "apt-mark", "hold")
+}
+
+// Unhold lets packages held by Hold be upgraded again
+func Unhold(packages ...string) error {
+	if usesDnf() {
+		if err := dnfVersionlock(); err != nil {
+			return err
+		}
+		return dnf(true, packages, "versionlock", "delete")
+	}
+	return run(true, packages, "apt-mark", "unhold")
+}
+
+// Matching returns the installed packages matching a glob such as php8.3-*
+func Matching(pattern string) []string {
+	if usesDnf() {
+		return rpmMatching(pattern)
+	}
+	output, _ := exec.Command("dpkg-query", "-W", "-f=${db:Status-Abbrev} ${Package}\n", pattern).Output()
+	var names []string
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "ii" {
+			names = append(names, fields[1])
+		}
+	}
+	return names
+}
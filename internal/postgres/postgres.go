@@ -0,0 +1,217 @@
+// Package postgres finds the PostgreSQL clusters of the server, so commands
+// use the port and configuration of the right one.
+package postgres
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"webstack-cli/internal/osinfo"
+)
+
+// DefaultPort is the port of the cluster used when none is named
+const DefaultPort = 5432
+
+// rhelDataDir is the only cluster of the RHEL packages
+const rhelDataDir = "/var/lib/pgsql/data"
+
+// portSetting matches the port line of postgresql.conf
+var portSetting = regexp.MustCompile(`(?m)^\s*port\s*=\s*(\d+)`)
+
+// Cluster is a PostgreSQL cluster: a data directory served by its own
+// server process on its own port. Debian and Ubuntu can run several, of
+// one or more major versions (pg_lsclusters).
+type Cluster struct {
+	Version   string // Major version, e.g. 16
+	Name      string // e.g. main
+	Port      int
+	Status    string // online or down
+	Owner     string
+	DataDir   string
+	ConfigDir string // Folder of postgresql.conf and pg_hba.conf
+}
+
+// ID returns the version/name of a cluster, e.g. 16/main
+func (c Cluster) ID() string {
+	return c.Version + "/" + c.Name
+}
+
+// Key returns the version-name of a cluster, e.g. 16-main, as used in
+// systemd units and file names
+func (c Cluster) Key() string {
+	return c.Version + "-" + c.Name
+}
+
+// Online reports whether the server of a cluster is running
+func (c Cluster) Online() bool {
+	return strings.HasPrefix(c.Status, "online")
+}
+
+// ConfigFile returns the postgresql.conf of a cluster
+func (c Cluster) ConfigFile() string {
+	return filepath.Join(c.ConfigDir, "postgresql.conf")
+}
+
+// HbaFile returns the pg_hba.conf of a cluster
+func (c Cluster) HbaFile() string {
+	return filepath.Join(c.ConfigDir, "pg_hba.conf")
+}
+
+// Unit returns the systemd unit of a cluster
+func (c Cluster) Unit() string {
+	if osinfo.Current().IsRHEL() {
+		return "postgresql"
+	}
+	return "postgresql@" + c.Key()
+}
+
+// Command returns a client command (psql, pg_dump, createdb ...) run as the
+// owner of a cluster and connecting to its port
+func (c Cluster) Command(name string, args ...string) *exec.Cmd {
+	return exec.Command("sudo", append([]string{"-u", c.Owner, name, "-p", strconv.Itoa(c.Port)}, args...)...)
+}
+
+// SQL returns psql running the statements it reads from stdin on a
+// cluster, stopping at the first error. Unlike -c, the statements (and the
+// passwords in them) don't show up in the process list.
+func (c Cluster) SQL(statements string) *exec.Cmd {
+	cmd := c.Command("psql", "-X", "-q", "-v", "ON_ERROR_STOP=1")
+	cmd.Stdin = strings.NewReader(statements)
+	return cmd
+}
+
+// Quote returns s as a string literal, e.g. for passwords
+func Quote(s string) string {
+	s = strings.ReplaceAll(s, "'", "''")
+	if strings.Contains(s, `\`) {
+		// An escape string reads the same whatever standard_conforming_strings is
+		return "E'" + strings.ReplaceAll(s, `\`, `\\`) + "'"
+	}
+	return "'" + s + "'"
+}
+
+// QuoteIdent returns s as a quoted name of a role, database or table
+func QuoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// Clusters returns the PostgreSQL clusters of this server, sorted by
+// version and name
+func Clusters() ([]Cluster, error) {
+	if osinfo.Current().IsRHEL() {
+		return rhelClusters(), nil
+	}
+	if _, err := exec.LookPath("pg_lsclusters"); err != nil {
+		return nil, nil
+	}
+	output, err := exec.Command("pg_lsclusters", "--no-header").Output()
+	if err != nil {
+		return nil, fmt.Errorf("pg_lsclusters failed: %v", err)
+	}
+	return parseClusters(string(output)), nil
+}
+
+// Find returns a cluster by version/name (16/main or 16-main) or version
+// (16). An empty reference is the cluster on port 5432, or the only one.
+func Find(ref string) (Cluster, error) {
+	clusters, err := Clusters()
+	if err != nil {
+		return Cluster{}, err
+	}
+	if len(clusters) == 0 {
+		return Cluster{}, fmt.Errorf("no PostgreSQL cluster found")
+	}
+
+	if ref == "" {
+		for _, c := range clusters {
+			if c.Port == DefaultPort {
+				return c, nil
+			}
+		}
+		if len(clusters) == 1 {
+			return clusters[0], nil
+		}
+		return Cluster{}, fmt.Errorf("no PostgreSQL cluster listens on port %d, name one of: %s", DefaultPort, IDs(clusters))
+	}
+
+	ref = strings.Replace(ref, "-", "/", 1)
+	var matches []Cluster
+	for _, c := range clusters {
+		if c.ID() == ref || c.Version == ref {
+			matches = append(matches, c)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Cluster{}, fmt.Errorf("unknown PostgreSQL cluster %s (clusters: %s)", ref, IDs(clusters))
+	case 1:
+		return matches[0], nil
+	}
+	return Cluster{}, fmt.Errorf("PostgreSQL %s has several clusters, name one of: %s", ref, IDs(matches))
+}
+
+// IDs returns the version/name of clusters, comma-separated
+func IDs(clusters []Cluster) string {
+	var ids []string
+	for _, c := range clusters {
+		ids = append(ids, c.ID())
+	}
+	return strings.Join(ids, ", ")
+}
+
+// parseClusters reads the output of pg_lsclusters --no-header:
+// Ver Cluster Port Status Owner Data-directory Log-file
+func parseClusters(output string) []Cluster {
+	var clusters []Cluster
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+		port, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		clusters = append(clusters, Cluster{
+			Version:   fields[0],
+			Name:      fields[1],
+			Port:      port,
+			Status:    fields[3],
+			Owner:     fields[4],
+			DataDir:   fields[5],
+			ConfigDir: filepath.Join("/etc/postgresql", fields[0], fields[1]),
+		})
+	}
+	return clusters
+}
+
+// rhelClusters returns the single cluster of the RHEL packages, once it is
+// initialized
+func rhelClusters() []Cluster {
+	version, err := ioutil.ReadFile(filepath.Join(rhelDataDir, "PG_VERSION"))
+	if err != nil {
+		return nil
+	}
+	c := Cluster{
+		Version:   strings.TrimSpace(string(version)),
+		Name:      "main",
+		Port:      DefaultPort,
+		Status:    "down",
+		Owner:     "postgres",
+		DataDir:   rhelDataDir,
+		ConfigDir: rhelDataDir,
+	}
+	if data, err := ioutil.ReadFile(c.ConfigFile()); err == nil {
+		if m := portSetting.FindSubmatch(data); m != nil {
+			c.Port, _ = strconv.Atoi(string(m[1]))
+		}
+	}
+	if exec.Command("systemctl", "is-active", "--quiet", "postgresql").Run() == nil {
+		c.Status = "online"
+	}
+	return []Cluster{c}
+}
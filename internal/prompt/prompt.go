@@ -0,0 +1,166 @@
+// Package prompt asks the confirmations of all commands, so they behave the
+// same way in scripts. WEBSTACK_ASSUME_YES confirms without asking (for CI);
+// WEBSTACK_FORBID_DESTRUCTIVE makes destructive actions (uninstalls,
+// deletions, restores, flushes) refuse to run unless --force is given, as a
+// safety net for production hosts.
+package prompt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"webstack-cli/internal/ui"
+)
+
+// Environment variables read by the package
+const (
+	AssumeYesEnv         = "WEBSTACK_ASSUME_YES"
+	ForbidDestructiveEnv = "WEBSTACK_FORBID_DESTRUCTIVE"
+)
+
+// Force is set when the command was given --force: destructive actions are
+// allowed under WEBSTACK_FORBID_DESTRUCTIVE and run without asking
+var Force bool
+
+// One reader for all prompts, so answers piped in together aren't lost
+// in the buffer of an earlier prompt
+var reader = bufio.NewReader(os.Stdin)
+
+// AssumeYes reports whether WEBSTACK_ASSUME_YES is set
+func AssumeYes() bool {
+	return envEnabled(AssumeYesEnv)
+}
+
+// ForbidDestructive reports whether WEBSTACK_FORBID_DESTRUCTIVE is set
+func ForbidDestructive() bool {
+	return envEnabled(ForbidDestructiveEnv)
+}
+
+// envEnabled treats 1, true, yes and on as set
+func envEnabled(name string) bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(name))) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// Confirm asks a yes/no question that defaults to no. It is answered yes
+// under WEBSTACK_ASSUME_YES, and no when there is no input to read.
+func Confirm(question string) bool {
+	fmt.Printf("%s (y/N): ", question)
+	if AssumeYes() {
+		fmt.Printf("y (%s)\n", AssumeYesEnv)
+		return true
+	}
+	return readYesNo(false)
+}
+
+// Ask asks an optional question, such as whether to install an extra
+// component or reboot. Unlike Confirm, WEBSTACK_ASSUME_YES answers it with
+// its default, so CI runs don't opt into extras or reboots.
+func Ask(question string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	fmt.Printf("%s (%s): ", question, hint)
+	if AssumeYes() {
+		fmt.Printf("%s (%s)\n", answer(def), AssumeYesEnv)
+		return def
+	}
+	return readYesNo(def)
+}
+
+// ConfirmTyped asks for 'yes' to be typed in full, for actions that can't
+// be undone. It is answered yes under WEBSTACK_ASSUME_YES.
+func ConfirmTyped() bool {
+	fmt.Print("Type 'yes' to confirm: ")
+	if AssumeYes() {
+		fmt.Printf("yes (%s)\n", AssumeYesEnv)
+		return true
+	}
+	response, _ := reader.ReadString('\n')
+	return strings.TrimSpace(response) == "yes"
+}
+
+// Line reads a line of input without asking, e.g. a password given as - on
+// the command line so it doesn't show up in the process list
+func Line() string {
+	line, _ := reader.ReadString('\n')
+	return strings.TrimRight(line, "\r\n")
+}
+
+// ConfirmHostname asks for the hostname of the server to be typed, so data
+// that can't be recovered is only deleted on the server the user means.
+// WEBSTACK_ASSUME_YES doesn't answer it.
+func ConfirmHostname() bool {
+	hostname, _ := os.Hostname()
+	fmt.Printf("Type the hostname of this server (%s) to confirm: ", hostname)
+	response, _ := reader.ReadString('\n')
+	return hostname != "" && strings.TrimSpace(response) == hostname
+}
+
+// Interactive reports whether stdin is a terminal someone can answer on
+func Interactive() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Allow checks the safe-mode policy for a destructive action: under
+// WEBSTACK_FORBID_DESTRUCTIVE it is refused unless --force was given
+func Allow(action string) bool {
+	if !ForbidDestructive() || Force {
+		return true
+	}
+	ui.Fail("❌ Refusing to %s: %s is set, pass --force to allow it\n", action, ForbidDestructiveEnv)
+	return false
+}
+
+// Destructive checks the policy for a destructive action and asks for
+// confirmation, which --force skips
+func Destructive(action, question string) bool {
+	if !Allow(action) {
+		return false
+	}
+	if Force {
+		return true
+	}
+	return Confirm(question)
+}
+
+// readYesNo reads y or n, returning def on an empty line or end of input
+func readYesNo(def bool) bool {
+	for {
+		response, err := reader.ReadString('\n')
+		if err == io.EOF && strings.TrimSpace(response) == "" {
+			// No one to answer, e.g. run from a script or the API
+			fmt.Println(answer(def))
+			return def
+		}
+		if err != nil && err != io.EOF {
+			ui.Fail("Error reading input: %v\n", err)
+			return def
+		}
+
+		switch strings.TrimSpace(strings.ToLower(response)) {
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		case "":
+			return def
+		default:
+			fmt.Print("Please enter y or n: ")
+		}
+	}
+}
+
+func answer(yes bool) string {
+	if yes {
+		return "y"
+	}
+	return "n"
+}
@@ -0,0 +1,25 @@
+// Package random generates the passwords, keys and salts written to
+// configurations, from crypto/rand.
+package random
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// chars are the characters of generated strings; alphanumeric, so they
+// need no quoting in configuration files and SQL
+const chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// String returns a random alphanumeric string of length n
+func String(n int) (string, error) {
+	b := make([]byte, n)
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = chars[idx.Int64()]
+	}
+	return string(b), nil
+}
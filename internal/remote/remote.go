@@ -0,0 +1,312 @@
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"webstack-cli/internal/store"
+)
+
+const remotesFile = "/etc/webstack/remotes.json"
+
+var remotesStore = store.New(remotesFile, 1)
+
+// parallel is the number of servers a command runs on at the same time
+const parallel = 10
+
+// Server is a remote machine managed over SSH. Commands run its webstack
+// binary, so it has to be installed there.
+type Server struct {
+	Name   string `json:"name"`
+	Host   string `json:"host"`
+	User   string `json:"user,omitempty"`   // Default: root
+	Port   int    `json:"port,omitempty"`   // Default: 22
+	Key    string `json:"key,omitempty"`    // SSH identity file, default: the SSH agent and ~/.ssh
+	Sudo   bool   `json:"sudo,omitempty"`   // Run webstack with sudo when the user isn't root
+	Binary string `json:"binary,omitempty"` // Default: webstack
+}
+
+// Registry is the content of remotes.json
+type Registry struct {
+	Servers []Server `json:"servers"`
+}
+
+// Result is the outcome of a command on a server
+type Result struct {
+	Server   Server
+	Output   []byte
+	ExitCode int
+	Err      error // The command could not be run, e.g. SSH failed
+}
+
+var (
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	safeArg     = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+)
+
+// Load reads remotes.json
+func Load() (*Registry, error) {
+	var r Registry
+	if err := remotesStore.Load(&r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Update changes remotes.json under its lock
+func Update(fn func(*Registry) error) error {
+	var r Registry
+	return remotesStore.Update(&r, func() error {
+		return fn(&r)
+	})
+}
+
+// Validate checks a server before it is saved
+func (s Server) Validate() error {
+	if !namePattern.MatchString(s.Name) {
+		return fmt.Errorf("name must start with a letter or digit and only contain letters, digits, '.', '_' and '-': %s", s.Name)
+	}
+	if s.Name == "all" {
+		return fmt.Errorf("name all is reserved for every server")
+	}
+	if s.Host == "" || strings.ContainsAny(s.Host, " @/") || strings.HasPrefix(s.Host, "-") {
+		return fmt.Errorf("invalid host: %s", s.Host)
+	}
+	if s.User != "" && (strings.ContainsAny(s.User, " @/") || strings.HasPrefix(s.User, "-")) {
+		return fmt.Errorf("invalid user: %s", s.User)
+	}
+	if s.Port < 0 || s.Port > 65535 {
+		return fmt.Errorf("invalid port: %d", s.Port)
+	}
+	if s.Key != "" {
+		if _, err := os.Stat(s.Key); err != nil {
+			return fmt.Errorf("key %s not found", s.Key)
+		}
+	}
+	return nil
+}
+
+// Target returns user@host for display
+func (s Server) Target() string {
+	user := s.User
+	if user == "" {
+		user = "root"
+	}
+	target := user + "@" + s.Host
+	if s.Port != 0 && s.Port != 22 {
+		target += ":" + strconv.Itoa(s.Port)
+	}
+	return target
+}
+
+// Find returns the registered servers matching names (or hosts); all selects
+// every server
+func (r *Registry) Find(names []string) ([]Server, error) {
+	var servers []Server
+	seen := map[string]bool{}
+	for _, name := range names {
+		if name == "all" {
+			for _, s := range r.Servers {
+				if !seen[s.Name] {
+					servers = append(servers, s)
+					seen[s.Name] = true
+				}
+			}
+			continue
+		}
+		found := false
+		for _, s := range r.Servers {
+			if s.Name == name || s.Host == name {
+				found = true
+				if !seen[s.Name] {
+					servers = append(servers, s)
+					seen[s.Name] = true
+				}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("server %s is not registered (see 'webstack remote list')", name)
+		}
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no servers registered (add one with 'webstack remote add <host>')")
+	}
+	return servers, nil
+}
+
+// sshArgs returns the ssh options reaching the server. Batch mode fails
+// instead of asking for passwords when nobody can answer; an interactive
+// command gets a terminal for prompts.
+func (s Server) sshArgs(interactive bool) []string {
+	args := []string{"-o", "ConnectTimeout=10"}
+	if interactive {
+		args = append(args, "-t")
+	} else {
+		args = append(args, "-o", "BatchMode=yes")
+	}
+	if s.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(s.Port))
+	}
+	if s.Key != "" {
+		args = append(args, "-i", s.Key)
+	}
+	user := s.User
+	if user == "" {
+		user = "root"
+	}
+	return append(args, "-l", user)
+}
+
+// sudo returns the prefix running a remote command as root
+func (s Server) sudo(interactive bool) string {
+	switch {
+	case !s.Sudo:
+		return ""
+	case interactive:
+		return "sudo "
+	}
+	return "sudo -n "
+}
+
+// command builds the ssh command running webstack with args on the server
+func (s Server) command(args []string, interactive bool) *exec.Cmd {
+	binary := s.Binary
+	if binary == "" {
+		binary = "webstack"
+	}
+	remote := []string{quote(binary)}
+	for _, arg := range args {
+		remote = append(remote, quote(arg))
+	}
+	sshArgs := append(s.sshArgs(interactive), s.Host, "--", s.sudo(interactive)+strings.Join(remote, " "))
+	return exec.Command("ssh", sshArgs...)
+}
+
+// Exec runs a shell command on the server as root and returns its output
+func Exec(s Server, command string, stdin io.Reader) ([]byte, error) {
+	sshArgs := append(s.sshArgs(false), s.Host, "--", s.sudo(false)+"sh -c "+quote(command))
+	cmd := exec.Command("ssh", sshArgs...)
+	cmd.Stdin = stdin
+	output, err := cmd.CombinedOutput()
+	// Report SSH failures rather than a bare exit status 255
+	if _, sshErr := exitCode(err); sshErr != nil {
+		return output, sshErr
+	}
+	return output, err
+}
+
+// Webstack runs webstack with args on the server, reading stdin, and returns
+// its output
+func Webstack(s Server, args []string, stdin io.Reader) ([]byte, error) {
+	cmd := s.command(args, false)
+	cmd.Stdin = stdin
+	output, err := cmd.CombinedOutput()
+	// Report SSH failures rather than a bare exit status 255
+	if _, sshErr := exitCode(err); sshErr != nil {
+		return output, sshErr
+	}
+	return output, err
+}
+
+// RsyncArgs returns the rsync options copying files to the server over the
+// same SSH connection settings
+func (s Server) RsyncArgs() []string {
+	shell := []string{"ssh"}
+	for _, arg := range s.sshArgs(false) {
+		shell = append(shell, quote(arg))
+	}
+	args := []string{"-e", strings.Join(shell, " ")}
+	if s.Sudo {
+		args = append(args, "--rsync-path=sudo -n rsync")
+	}
+	return args
+}
+
+// Dest returns the rsync destination of a path on the server
+func (s Server) Dest(path string) string {
+	return s.Host + ":" + path
+}
+
+// quote makes an argument safe for the remote shell
+func quote(arg string) string {
+	if safeArg.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// Stream runs webstack with args on one server, connected to the terminal so
+// prompts work, and returns its exit code
+func Stream(s Server, args []string, interactive bool) (int, error) {
+	cmd := s.command(args, interactive)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return exitCode(cmd.Run())
+}
+
+// Run runs webstack with args on the servers in parallel. Results keep the
+// order of servers.
+func Run(servers []Server, args []string) []Result {
+	results := make([]Result, len(servers))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, s := range servers {
+		wg.Add(1)
+		go func(i int, s Server) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			var output bytes.Buffer
+			cmd := s.command(args, false)
+			cmd.Stdout, cmd.Stderr = &output, &output
+			code, err := exitCode(cmd.Run())
+			results[i] = Result{Server: s, Output: output.Bytes(), ExitCode: code, Err: err}
+		}(i, s)
+	}
+	wg.Wait()
+	return results
+}
+
+// exitCode returns the exit code of a finished command. ssh exits with 255
+// when the connection or authentication fails.
+func exitCode(err error) (int, error) {
+	if err == nil {
+		return 0, nil
+	}
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return 255, err
+	}
+	if exitErr.ExitCode() == 255 {
+		return 255, fmt.Errorf("ssh connection failed")
+	}
+	return exitErr.ExitCode(), nil
+}
+
+// Check connects to a server and returns the version of its webstack binary
+func Check(s Server) (string, error) {
+	result := Run([]Server{s}, []string{"version", "--no-emoji"})[0]
+	output := strings.TrimSpace(string(result.Output))
+	if result.Err != nil || result.ExitCode != 0 {
+		if output == "" && result.Err != nil {
+			output = result.Err.Error()
+		}
+		return "", fmt.Errorf("%s", lastLine(output))
+	}
+	return strings.TrimPrefix(firstLine(output), "WebStack CLI "), nil
+}
+
+func firstLine(text string) string {
+	return strings.SplitN(text, "\n", 2)[0]
+}
+
+func lastLine(text string) string {
+	lines := strings.Split(text, "\n")
+	return lines[len(lines)-1]
+}
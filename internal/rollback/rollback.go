@@ -0,0 +1,401 @@
+package rollback
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/osinfo"
+	"webstack-cli/internal/service"
+	"webstack-cli/internal/store"
+	"webstack-cli/internal/ui"
+)
+
+// Dir holds one directory per snapshot, named after the time it was taken
+const Dir = "/var/lib/webstack/rollback"
+
+// keep is the number of snapshots kept; older ones are pruned
+const keep = 20
+
+// patterns are the configuration files a snapshot captures. Restoring one
+// puts back the files that matched and removes files that match now but did
+// not then, e.g. the vhost of a domain added after the snapshot.
+var patterns = []string{
+	"/etc/webstack/*.json",
+	"/etc/webstack/cron/*",
+	"/etc/nginx/sites-available/*",
+	"/etc/nginx/sites-enabled/*",
+	"/etc/apache2/sites-available/*",
+	"/etc/apache2/sites-enabled/*",
+	"/etc/httpd/sites-available/*",
+	"/etc/httpd/sites-enabled/*",
+	"/etc/php/*/fpm/pool.d/*",
+	"/etc/opt/remi/php*/php-fpm.d/*",
+	"/etc/logrotate.d/webstack-*",
+}
+
+// Snapshot describes a snapshot taken before a command
+type Snapshot struct {
+	ID        string    `json:"-"`
+	Command   string    `json:"command"`
+	Created   time.Time `json:"created"`
+	Documents []string  `json:"documents"` // State documents present, e.g. domains.json
+	Files     []File    `json:"files"`
+	Restored  time.Time `json:"restored,omitempty"`
+}
+
+// File is a configuration file captured in a snapshot
+type File struct {
+	Path string      `json:"path"`
+	Mode os.FileMode `json:"mode"`
+	Link string      `json:"link,omitempty"` // Target when the file is a symlink
+}
+
+// Take snapshots the state documents and configuration files before command
+// changes them
+func Take(command string) (*Snapshot, error) {
+	now := time.Now()
+	id := now.Format("20060102-150405")
+	dir := filepath.Join(Dir, id)
+	for i := 2; exists(dir); i++ {
+		id = fmt.Sprintf("%s-%d", now.Format("20060102-150405"), i)
+		dir = filepath.Join(Dir, id)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("could not create %s: %v", dir, err)
+	}
+
+	snap := &Snapshot{ID: id, Command: command, Created: now}
+	err := snap.capture(dir)
+	if err == nil {
+		err = snap.writeManifest()
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	prune()
+	return snap, nil
+}
+
+func (s *Snapshot) capture(dir string) error {
+	// Documents go through the store so they are captured from SQLite too
+	for _, name := range store.Names() {
+		data, found, err := store.Dump(name)
+		if err != nil {
+			return fmt.Errorf("could not snapshot %s: %v", name, err)
+		}
+		if !found {
+			continue
+		}
+		if err := writeCopy(filepath.Join(dir, "state", name), data, 0600); err != nil {
+			return err
+		}
+		s.Documents = append(s.Documents, name)
+	}
+
+	for _, path := range matches() {
+		info, err := os.Lstat(path)
+		if err != nil || !(info.Mode().IsRegular() || info.Mode()&os.ModeSymlink != 0) {
+			continue
+		}
+		file := File{Path: path, Mode: info.Mode().Perm()}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if file.Link, err = os.Readlink(path); err != nil {
+				return fmt.Errorf("could not snapshot %s: %v", path, err)
+			}
+		} else {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("could not snapshot %s: %v", path, err)
+			}
+			if err := writeCopy(filepath.Join(dir, "files", path), data, 0600); err != nil {
+				return err
+			}
+		}
+		s.Files = append(s.Files, file)
+	}
+	return nil
+}
+
+// matches returns the files matching patterns, leaving out the state
+// documents, which are captured through the store
+func matches() []string {
+	var paths []string
+	for _, pattern := range patterns {
+		found, _ := filepath.Glob(pattern)
+		for _, path := range found {
+			if filepath.Dir(path) == "/etc/webstack" && store.Managed(filepath.Base(path)) {
+				continue
+			}
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// Last returns the most recent snapshot
+func Last() (*Snapshot, error) {
+	snaps, err := List()
+	if err != nil {
+		return nil, err
+	}
+	if len(snaps) == 0 {
+		return nil, fmt.Errorf("no snapshots in %s", Dir)
+	}
+	return snaps[0], nil
+}
+
+// Get returns the snapshot with the given ID
+func Get(id string) (*Snapshot, error) {
+	if id == "" || strings.ContainsAny(id, "/.") {
+		return nil, fmt.Errorf("invalid snapshot ID %q", id)
+	}
+	return load(id)
+}
+
+// List returns the snapshots, newest first
+func List() ([]*Snapshot, error) {
+	entries, err := ioutil.ReadDir(Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snaps []*Snapshot
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		snap, err := load(entry.Name())
+		if err != nil {
+			continue
+		}
+		snaps = append(snaps, snap)
+	}
+	sort.Slice(snaps, func(i, j int) bool {
+		if !snaps[i].Created.Equal(snaps[j].Created) {
+			return snaps[i].Created.After(snaps[j].Created)
+		}
+		return snaps[i].ID > snaps[j].ID
+	})
+	return snaps, nil
+}
+
+func load(id string) (*Snapshot, error) {
+	data, err := ioutil.ReadFile(filepath.Join(Dir, id, "manifest.json"))
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s not found", id)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("could not parse snapshot %s: %v", id, err)
+	}
+	snap.ID = id
+	return &snap, nil
+}
+
+func (s *Snapshot) writeManifest() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(Dir, s.ID, "manifest.json"), data, 0600)
+}
+
+// Restore puts the state documents and configuration files back as they were
+// when the snapshot was taken and reloads the services using them
+func (s *Snapshot) Restore() error {
+	dir := filepath.Join(Dir, s.ID)
+	var failed []string
+
+	for _, name := range store.Names() {
+		if err := s.restoreDocument(dir, name); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+
+	captured := map[string]bool{}
+	for _, file := range s.Files {
+		captured[file.Path] = true
+	}
+	changed := map[string]bool{}
+	for _, path := range matches() {
+		if captured[path] {
+			continue
+		}
+		if err := dryrun.Remove(path); err != nil && !os.IsNotExist(err) {
+			failed = append(failed, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		fmt.Printf("🗑️  Removed %s\n", path)
+		changed[path] = true
+	}
+	for _, file := range s.Files {
+		restored, err := s.restoreFile(dir, file)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", file.Path, err))
+			continue
+		}
+		if restored {
+			changed[file.Path] = true
+		}
+	}
+
+	reload(changed)
+
+	if len(failed) > 0 {
+		return fmt.Errorf("could not restore:\n  %s", strings.Join(failed, "\n  "))
+	}
+	if dryrun.Enabled() {
+		return nil
+	}
+	s.Restored = time.Now()
+	return s.writeManifest()
+}
+
+func (s *Snapshot) restoreDocument(dir, name string) error {
+	current, found, err := store.Dump(name)
+	if err != nil {
+		return err
+	}
+	if !contains(s.Documents, name) {
+		if !found {
+			return nil
+		}
+		if err := store.Delete(name); err != nil {
+			return err
+		}
+		fmt.Printf("🗑️  Removed %s\n", name)
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "state", name))
+	if err != nil {
+		return err
+	}
+	if found && string(current) == string(data) {
+		return nil
+	}
+	if err := store.Restore(name, data); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Restored %s\n", name)
+	return nil
+}
+
+// restoreFile puts a file back, reporting whether it had changed
+func (s *Snapshot) restoreFile(dir string, file File) (bool, error) {
+	if err := dryrun.MkdirAll(filepath.Dir(file.Path), 0755); err != nil {
+		return false, err
+	}
+	if file.Link != "" {
+		if current, err := os.Readlink(file.Path); err == nil && current == file.Link {
+			return false, nil
+		}
+		if err := dryrun.Remove(file.Path); err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		if err := dryrun.Symlink(file.Link, file.Path); err != nil {
+			return false, err
+		}
+		fmt.Printf("✅ Restored %s -> %s\n", file.Path, file.Link)
+		return true, nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "files", file.Path))
+	if err != nil {
+		return false, err
+	}
+	if current, err := ioutil.ReadFile(file.Path); err == nil && string(current) == string(data) {
+		return false, nil
+	}
+	// A symlink in its place would be written through
+	if info, err := os.Lstat(file.Path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := dryrun.Remove(file.Path); err != nil {
+			return false, err
+		}
+	}
+	if err := dryrun.WriteFile(file.Path, data, file.Mode); err != nil {
+		return false, err
+	}
+	fmt.Printf("✅ Restored %s\n", file.Path)
+	return true, nil
+}
+
+// reload reloads the web servers and the PHP-FPM versions whose
+// configuration was changed by a restore
+func reload(changed map[string]bool) {
+	layout := osinfo.Current()
+	units := map[string]bool{}
+	for path := range changed {
+		switch {
+		case strings.HasPrefix(path, "/etc/nginx/"):
+			units["nginx"] = true
+		case strings.HasPrefix(path, layout.ApacheDir+"/"):
+			units[layout.Service("apache2")] = true
+		case strings.HasPrefix(path, "/etc/php/"), strings.HasPrefix(path, "/etc/opt/remi/"):
+			// <pool dir of the version>/<domain>.conf
+			units[layout.PHPFPMService(layout.PHPVersion(filepath.Dir(path)))] = true
+		}
+	}
+
+	var names []string
+	for unit := range units {
+		names = append(names, unit)
+	}
+	sort.Strings(names)
+	for _, unit := range names {
+		err := service.Reload(unit)
+		switch {
+		case err == service.ErrNotInstalled:
+			continue
+		case err != nil:
+			ui.Warn("⚠️  Warning: Could not reload %s: %v\n", unit, err)
+		default:
+			fmt.Printf("✅ %s reloaded\n", unit)
+		}
+	}
+}
+
+// prune removes all but the newest snapshots
+func prune() {
+	snaps, err := List()
+	if err != nil || len(snaps) <= keep {
+		return
+	}
+	for _, snap := range snaps[keep:] {
+		os.RemoveAll(filepath.Join(Dir, snap.ID))
+	}
+}
+
+func writeCopy(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, data, perm); err != nil {
+		return fmt.Errorf("could not write %s: %v", path, err)
+	}
+	return nil
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,338 @@
+// Package selfupdate replaces the webstack binary with a GitHub release.
+// Downloads are checked against the checksums.txt of the release, and the
+// checksums against their ed25519 signature when the binary was built with
+// a public key. The new binary is renamed over the old one, so a failed
+// update never leaves a half-written binary behind.
+package selfupdate
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Repository is the GitHub repository the releases come from
+const Repository = "script-php/webstack-cli"
+
+// ChannelKey is the config.json setting choosing the release channel
+const ChannelKey = "update_channel"
+
+// Channels: stable follows the latest release, edge also takes pre-releases
+const (
+	Stable = "stable"
+	Edge   = "edge"
+)
+
+// PublicKey is the base64 ed25519 key the checksums of releases are signed
+// with, set at build time (-X webstack-cli/internal/selfupdate.PublicKey=...).
+// Builds without one verify checksums only.
+var PublicKey = ""
+
+var client = &http.Client{Timeout: 2 * time.Minute}
+
+// Release is a GitHub release
+type Release struct {
+	Tag        string  `json:"tag_name"`
+	Name       string  `json:"name"`
+	Prerelease bool    `json:"prerelease"`
+	Draft      bool    `json:"draft"`
+	Published  string  `json:"published_at"`
+	URL        string  `json:"html_url"`
+	Assets     []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// asset returns the download URL of a file of the release
+func (r *Release) asset(name string) (string, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL, true
+		}
+	}
+	return "", false
+}
+
+// AssetName is the release binary for this platform, e.g. webstack-linux-amd64
+func AssetName() string {
+	name := fmt.Sprintf("webstack-%s-%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Latest returns the newest release of a channel
+func Latest(channel string) (*Release, error) {
+	if channel == Stable || channel == "" {
+		var release Release
+		if err := getJSON(fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", Repository), &release); err != nil {
+			return nil, err
+		}
+		return &release, nil
+	}
+	if channel != Edge {
+		return nil, fmt.Errorf("unknown channel %s (use %s or %s)", channel, Stable, Edge)
+	}
+
+	var releases []Release
+	if err := getJSON(fmt.Sprintf("https://api.github.com/repos/%s/releases?per_page=20", Repository), &releases); err != nil {
+		return nil, err
+	}
+	for i := range releases {
+		if !releases[i].Draft {
+			return &releases[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no releases found")
+}
+
+func getJSON(url string, v interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not reach GitHub: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GitHub answered HTTP %d for %s", resp.StatusCode, url)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("could not parse the release information: %v", err)
+	}
+	return nil
+}
+
+// Newer reports whether tag is a newer version than current. Builds that
+// aren't a release (dev) are older than any release.
+func Newer(current, tag string) bool {
+	cur, ok := parseVersion(current)
+	if !ok {
+		return true
+	}
+	next, ok := parseVersion(tag)
+	if !ok {
+		return false
+	}
+	for i := 0; i < 3; i++ {
+		if next.numbers[i] != cur.numbers[i] {
+			return next.numbers[i] > cur.numbers[i]
+		}
+	}
+	// 1.2.0 is newer than 1.2.0-rc.1; pre-releases compare by their suffix
+	switch {
+	case cur.pre == next.pre:
+		return false
+	case next.pre == "":
+		return true
+	case cur.pre == "":
+		return false
+	}
+	return comparePre(next.pre, cur.pre) > 0
+}
+
+// comparePre compares two pre-release suffixes the way semver does: by
+// their dot-separated identifiers, numeric ones by value (rc.10 is newer
+// than rc.9) and lower than alphanumeric ones, and a shorter suffix is
+// lower when the identifiers it has are equal
+func comparePre(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an > bn {
+					return 1
+				}
+				return -1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return len(as) - len(bs)
+}
+
+type version struct {
+	numbers [3]int
+	pre     string
+}
+
+// parseVersion parses v1.2.3 and v1.2.3-rc.1
+func parseVersion(s string) (version, bool) {
+	var v version
+	s = strings.TrimPrefix(s, "v")
+	s, v.pre, _ = strings.Cut(s, "-")
+	parts := strings.Split(s, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return v, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return v, false
+		}
+		v.numbers[i] = n
+	}
+	return v, true
+}
+
+// Install downloads the binary of a release, verifies it and replaces the
+// binary at path with it. The replaced binary is kept as <path>.previous.
+func Install(release *Release, path string) error {
+	binaryURL, ok := release.asset(AssetName())
+	if !ok {
+		return fmt.Errorf("release %s has no binary for %s/%s", release.Tag, runtime.GOOS, runtime.GOARCH)
+	}
+	checksumsURL, ok := release.asset("checksums.txt")
+	if !ok {
+		return fmt.Errorf("release %s has no checksums.txt, refusing to install an unverified binary", release.Tag)
+	}
+
+	checksums, err := download(checksumsURL)
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(release, checksums); err != nil {
+		return err
+	}
+	expected, err := checksumOf(checksums, AssetName())
+	if err != nil {
+		return err
+	}
+
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return fmt.Errorf("could not resolve %s: %v", path, err)
+	}
+	// The new binary is written next to the old one so the rename is atomic
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".webstack-update-")
+	if err != nil {
+		return fmt.Errorf("could not write to %s: %v", filepath.Dir(path), err)
+	}
+	defer os.Remove(tmp.Name())
+
+	fmt.Printf("📥 Downloading %s...\n", binaryURL)
+	resp, err := client.Get(binaryURL)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("download failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		tmp.Close()
+		return fmt.Errorf("download failed with HTTP %d", resp.StatusCode)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), resp.Body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("download failed: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	tmp.Close()
+
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != expected {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", AssetName(), expected, sum)
+	}
+	fmt.Println("✅ Checksum verified")
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	if output, err := exec.Command(tmp.Name(), "version").CombinedOutput(); err != nil {
+		return fmt.Errorf("the downloaded binary does not run: %v %s", err, strings.TrimSpace(string(output)))
+	}
+
+	previous := path + ".previous"
+	os.Remove(previous)
+	if err := os.Link(path, previous); err != nil {
+		return fmt.Errorf("could not keep the current binary as %s: %v", previous, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("could not replace %s: %v", path, err)
+	}
+	return nil
+}
+
+// verifySignature checks checksums.txt against checksums.txt.sig with
+// PublicKey. Without a key there is nothing to check against.
+func verifySignature(release *Release, checksums []byte) error {
+	if PublicKey == "" {
+		fmt.Println("ℹ️  This build has no release signing key, verifying the checksum only")
+		return nil
+	}
+	key, err := base64.StdEncoding.DecodeString(PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("the release signing key of this build is invalid")
+	}
+	signatureURL, ok := release.asset("checksums.txt.sig")
+	if !ok {
+		return fmt.Errorf("release %s has no checksums.txt.sig, refusing to install an unsigned release", release.Tag)
+	}
+	signature, err := download(signatureURL)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), checksums, signature) {
+		return fmt.Errorf("the signature of the checksums of %s is invalid", release.Tag)
+	}
+	fmt.Println("✅ Release signature verified")
+	return nil
+}
+
+// checksumOf finds the SHA-256 of a file in sha256sum output
+func checksumOf(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(strings.NewReader(string(checksums)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("checksums.txt has no entry for %s", name)
+}
+
+// download fetches a small release file
+func download(url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("could not download %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not download %s: HTTP %d", url, resp.StatusCode)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
@@ -0,0 +1,32 @@
+package selfupdate
+
+import "testing"
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		current string
+		tag     string
+		want    bool
+	}{
+		{"v1.2.0", "v1.2.1", true},
+		{"v1.2.1", "v1.2.0", false},
+		{"v1.2.0", "v1.2.0", false},
+		{"v1.9.0", "v1.10.0", true},
+		{"dev", "v1.0.0", true},
+		{"v1.0.0", "latest", false},
+		{"v1.2.0-rc.1", "v1.2.0", true},
+		{"v1.2.0", "v1.2.0-rc.1", false},
+		{"v1.2.0-rc.9", "v1.2.0-rc.10", true},
+		{"v1.2.0-rc.10", "v1.2.0-rc.9", false},
+		{"v1.2.0-alpha", "v1.2.0-beta", true},
+		{"v1.2.0-alpha", "v1.2.0-alpha.1", true},
+		{"v1.2.0-alpha.1", "v1.2.0-alpha.beta", true},
+		{"v1.2.0-alpha.beta", "v1.2.0-alpha.1", false},
+		{"v1.2.0-beta.11", "v1.2.0-rc.1", true},
+	}
+	for _, tt := range tests {
+		if got := Newer(tt.current, tt.tag); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.current, tt.tag, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/notify"
+	"webstack-cli/internal/ui"
+)
+
+// Restart attempts after a failed reload, waiting restartDelay before the
+// second attempt and doubling it each time
+const (
+	restartAttempts = 3
+	restartDelay    = 2 * time.Second
+)
+
+// ErrNotInstalled is returned when systemd has no unit for the service
+var ErrNotInstalled = errors.New("service not installed")
+
+// Reload reloads a systemd service so it picks up new configuration. If the
+// service is not running or the reload fails, it is restarted with a bounded
+// number of retries so the new configuration does not stay unapplied.
+func Reload(name string) error {
+	if dryrun.Enabled() {
+		return dryrun.Run(exec.Command("systemctl", "reload", name))
+	}
+
+	loadState, activeState, err := status(name)
+	if err != nil {
+		// No usable systemd, nothing to fall back on
+		return exec.Command("systemctl", "reload", name).Run()
+	}
+	if loadState == "not-found" {
+		return ErrNotInstalled
+	}
+	slog.Debug("reloading service", "unit", name, "state", activeState)
+
+	if activeState == "active" {
+		output, err := exec.Command("systemctl", "reload", name).CombinedOutput()
+		if err == nil {
+			return nil
+		}
+		ui.Warn("⚠️  Reloading %s failed (%s), restarting it...\n", name, commandError(err, output))
+	} else {
+		ui.Warn("⚠️  %s is %s, starting it...\n", name, activeState)
+	}
+
+	return restart(name)
+}
+
+// UnitStatus is the systemd state of a service
+type UnitStatus struct {
+	Unit    string // Resolved unit, e.g. mariadb.service for the mysql alias
+	Active  string // ActiveState, e.g. "active" or "failed"
+	Enabled bool   // Started at boot
+}
+
+// Status returns the systemd state of a service, or ErrNotInstalled
+func Status(name string) (UnitStatus, error) {
+	props, err := show(name, "Id", "LoadState", "ActiveState", "UnitFileState")
+	if err != nil {
+		return UnitStatus{}, err
+	}
+	if props["LoadState"] == "not-found" {
+		return UnitStatus{}, ErrNotInstalled
+	}
+	return UnitStatus{
+		Unit:    props["Id"],
+		Active:  props["ActiveState"],
+		Enabled: props["UnitFileState"] == "enabled",
+	}, nil
+}
+
+// Restart restarts a service with the same retries as a failed reload
+func Restart(name string) error {
+	if dryrun.Enabled() {
+		return dryrun.Run(exec.Command("systemctl", "restart", name))
+	}
+	return restart(name)
+}
+
+// restart restarts a service until it reports active, backing off between attempts
+func restart(name string) error {
+	var lastErr error
+	delay := restartDelay
+	for attempt := 1; attempt <= restartAttempts; attempt++ {
+		slog.Debug("restarting service", "unit", name, "attempt", attempt)
+		output, err := exec.Command("systemctl", "restart", name).CombinedOutput()
+		if err == nil {
+			if _, activeState, _ := status(name); activeState == "active" {
+				fmt.Printf("   %s restarted (attempt %d/%d)\n", name, attempt, restartAttempts)
+				return nil
+			}
+			lastErr = fmt.Errorf("not active after restart")
+		} else {
+			lastErr = errors.New(commandError(err, output))
+		}
+
+		if attempt < restartAttempts {
+			fmt.Printf("   Attempt %d/%d failed: %v, retrying in %s...\n", attempt, restartAttempts, lastErr, delay)
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	err := fmt.Errorf("%s did not start after %d attempts: %v (see: journalctl -u %s -n 20)", name, restartAttempts, lastErr, name)
+	notify.Send(notify.ServiceDown, "", name+" is down", err.Error())
+	return err
+}
+
+// status returns the systemd LoadState and ActiveState of a unit
+func status(name string) (string, string, error) {
+	props, err := show(name, "LoadState", "ActiveState")
+	if err != nil {
+		return "", "", err
+	}
+	return props["LoadState"], props["ActiveState"], nil
+}
+
+// show reads unit properties with systemctl show
+func show(name string, properties ...string) (map[string]string, error) {
+	args := []string{"show"}
+	for _, property := range properties {
+		args = append(args, "-p", property)
+	}
+	output, err := exec.Command("systemctl", append(args, name)...).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	props := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if found {
+			props[key] = value
+		}
+	}
+	if props["LoadState"] == "" {
+		return nil, fmt.Errorf("could not read state of %s", name)
+	}
+	return props, nil
+}
+
+// commandError combines an exec error with the last line of its output
+func commandError(err error, output []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		return fmt.Sprintf("%v: %s", err, last)
+	}
+	return err.Error()
+}
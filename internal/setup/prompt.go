@@ -0,0 +1,72 @@
+package setup
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// wizard reads every answer from one reader, so piped answers are not
+// lost to the buffering of a reader per question. At the end of the input
+// every question takes its default.
+type wizard struct {
+	reader *bufio.Reader
+}
+
+// ask prints a question with its default and returns the answer
+func (w *wizard) ask(question, def string) string {
+	if def != "" {
+		fmt.Printf("%s [%s]: ", question, def)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+
+	response, _ := w.reader.ReadString('\n')
+	response = strings.TrimSpace(response)
+	if response == "" {
+		return def
+	}
+	return response
+}
+
+func (w *wizard) askYesNo(question string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		fmt.Printf("%s (%s): ", question, hint)
+		response, _ := w.reader.ReadString('\n')
+		switch strings.TrimSpace(strings.ToLower(response)) {
+		case "":
+			return def
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+		fmt.Println("Please enter y or n")
+	}
+}
+
+// choose offers numbered options and returns the value picked by number or
+// by name
+func (w *wizard) choose(question string, values []string, def string, labels ...string) string {
+	fmt.Printf("%s:\n", question)
+	for i, label := range labels {
+		fmt.Printf("  [%d] %s\n", i+1, label)
+	}
+	for {
+		answer := w.ask("Choose", def)
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(values) {
+			return values[n-1]
+		}
+		for _, v := range values {
+			if strings.EqualFold(v, answer) {
+				return v
+			}
+		}
+		fmt.Printf("Please enter 1-%d\n", len(values))
+	}
+}
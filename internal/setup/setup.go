@@ -0,0 +1,320 @@
+package setup
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"webstack-cli/internal/backup"
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/firewall"
+	"webstack-cli/internal/installer"
+	"webstack-cli/internal/pkg"
+	"webstack-cli/internal/ssl"
+	"webstack-cli/internal/ui"
+)
+
+// Answers are the choices made in the setup wizard
+type Answers struct {
+	Hostname   string
+	Timezone   string
+	Profile    installer.Profile
+	AdminEmail string // Let's Encrypt registrations (defaults.ssl_email)
+	Backups    bool   // Daily full backups to /var/backups/webstack
+	BackupTime string // "HH:MM"
+	BackupKeep int    // Days
+	Security   bool   // Firewall core rules, fail2ban and webroot hardening
+	InstallNow bool   // Install the selected stack right away
+}
+
+var (
+	hostnamePattern   = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+	backupTimePattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+)
+
+// fail2banPackages and firewallPackages make up the security baseline;
+// the firewall packages are only installed when no firewall tool is found
+var (
+	fail2banPackages = []string{"fail2ban"}
+	firewallPackages = []string{"iptables", "ipset", "iptables-persistent"}
+)
+
+// Run walks a new server through hostname, timezone, stack, admin email,
+// backups and security baseline, then applies the answers
+func Run() {
+	w := &wizard{reader: bufio.NewReader(os.Stdin)}
+
+	fmt.Println("🚀 WebStack Setup")
+	fmt.Println("=================")
+	fmt.Println("Press Enter to keep the value in brackets.")
+
+	answers := w.collect()
+
+	fmt.Println("\n📋 Summary")
+	fmt.Println("==========")
+	printSummary(answers)
+
+	if !w.askYesNo("\nApply these settings?", true) {
+		fmt.Println("✋ Setup cancelled, nothing was changed")
+		return
+	}
+	apply(answers)
+}
+
+// collect asks every question of the wizard
+func (w *wizard) collect() Answers {
+	var a Answers
+
+	fmt.Println("\n1/6 Hostname")
+	current, _ := os.Hostname()
+	for {
+		a.Hostname = w.ask("Server hostname (FQDN, e.g. server.example.com)", current)
+		if hostnamePattern.MatchString(a.Hostname) {
+			break
+		}
+		ui.Invalid("Invalid hostname: %s\n", a.Hostname)
+	}
+
+	fmt.Println("\n2/6 Timezone")
+	for {
+		a.Timezone = w.ask("Timezone (e.g. UTC, Europe/Berlin)", currentTimezone())
+		if validTimezone(a.Timezone) {
+			break
+		}
+		ui.Invalid("Unknown timezone: %s\n", a.Timezone)
+	}
+
+	fmt.Println("\n3/6 Stack")
+	a.Profile.WebServer = w.choose("Web server", []string{"nginx", "apache", "both"}, "nginx",
+		"Nginx", "Apache", "Nginx in front of Apache (.htaccess support)")
+	a.Profile.Database = w.choose("Database", []string{"mysql", "mariadb", "postgresql", "none"}, "mariadb",
+		"MySQL", "MariaDB", "PostgreSQL", "None")
+	if a.Profile.Database == "none" {
+		a.Profile.Database = ""
+	}
+	versions := installer.SupportedPHPVersions()
+	phpDefault := config.FallbackPHPVersion
+	if cfg, err := config.Load(); err == nil {
+		phpDefault = cfg.DefaultPHPVersion()
+	}
+	for {
+		a.Profile.PHPVersion = w.ask(fmt.Sprintf("PHP version (%s-%s, none)", versions[0], versions[len(versions)-1]), phpDefault)
+		if a.Profile.PHPVersion == "none" {
+			a.Profile.PHPVersion = ""
+			break
+		}
+		if contains(versions, a.Profile.PHPVersion) {
+			break
+		}
+		ui.Invalid("Invalid PHP version: %s\n", a.Profile.PHPVersion)
+	}
+
+	fmt.Println("\n4/6 Admin email")
+	for {
+		a.AdminEmail = w.ask("Email for Let's Encrypt registrations (empty to skip)", currentEmail())
+		if a.AdminEmail == "" || (strings.Contains(a.AdminEmail, "@") && !strings.ContainsAny(a.AdminEmail, " \t")) {
+			break
+		}
+		ui.Invalid("Invalid email address: %s\n", a.AdminEmail)
+	}
+
+	fmt.Println("\n5/6 Backups")
+	a.Backups = w.askYesNo("Run daily full backups to /var/backups/webstack?", true)
+	if a.Backups {
+		for {
+			a.BackupTime = w.ask("Backup time (HH:MM, UTC)", "02:00")
+			if backupTimePattern.MatchString(a.BackupTime) {
+				break
+			}
+			ui.Invalid("Invalid time: %s\n", a.BackupTime)
+		}
+		for {
+			keep, err := strconv.Atoi(w.ask("Keep backups for how many days", "30"))
+			if err == nil && keep > 0 {
+				a.BackupKeep = keep
+				break
+			}
+			ui.Invalid("Invalid number of days\n")
+		}
+	}
+
+	fmt.Println("\n6/6 Security baseline")
+	fmt.Println("Opens only SSH plus the ports of installed components, installs fail2ban")
+	fmt.Println("and denies .git, .env and backup files in generated vhosts.")
+	a.Security = w.askYesNo("Apply the security baseline?", true)
+
+	a.InstallNow = w.askYesNo("\nInstall the selected stack now?", true)
+	return a
+}
+
+func printSummary(a Answers) {
+	fmt.Printf("Hostname:    %s\n", a.Hostname)
+	fmt.Printf("Timezone:    %s\n", a.Timezone)
+	fmt.Printf("Web server:  %s\n", a.Profile.WebServer)
+	fmt.Printf("Database:    %s\n", orNone(a.Profile.Database))
+	fmt.Printf("PHP:         %s\n", orNone(a.Profile.PHPVersion))
+	fmt.Printf("Admin email: %s\n", orNone(a.AdminEmail))
+	if a.Backups {
+		fmt.Printf("Backups:     daily at %s UTC, kept %d days\n", a.BackupTime, a.BackupKeep)
+	} else {
+		fmt.Println("Backups:     none")
+	}
+	fmt.Printf("Security:    %s\n", map[bool]string{true: "baseline", false: "unchanged"}[a.Security])
+	fmt.Printf("Install now: %s\n", map[bool]string{true: "yes", false: "no ('webstack install' later)"}[a.InstallNow])
+}
+
+// apply carries out the answers, continuing past failed steps so one
+// problem does not leave the rest of the server unconfigured
+func apply(a Answers) {
+	fmt.Println("\n⚙️  Applying settings...")
+
+	if current, _ := os.Hostname(); a.Hostname != current {
+		if err := dryrun.Run(exec.Command("hostnamectl", "set-hostname", a.Hostname)); err != nil {
+			ui.Warn("⚠️  Warning: Could not set hostname: %v\n", err)
+		} else {
+			fmt.Printf("✅ Hostname set to %s\n", a.Hostname)
+		}
+	}
+
+	if a.Timezone != currentTimezone() {
+		if err := dryrun.Run(exec.Command("timedatectl", "set-timezone", a.Timezone)); err != nil {
+			ui.Warn("⚠️  Warning: Could not set timezone: %v\n", err)
+		} else {
+			fmt.Printf("✅ Timezone set to %s\n", a.Timezone)
+		}
+	}
+
+	if err := writeConfig(a); err != nil {
+		ui.Fail("❌ Could not save config: %v\n", err)
+		return
+	}
+	fmt.Println("✅ Defaults saved to /etc/webstack/config.json")
+
+	if a.InstallNow {
+		fmt.Println("\n📦 Installing the selected stack...")
+		installer.InstallProfile(a.Profile)
+	}
+
+	if a.Security {
+		applySecurityBaseline()
+	}
+
+	if a.Backups {
+		if dryrun.Enabled() {
+			fmt.Printf("🔎 [dry-run] would enable daily backups at %s, kept %d days\n", a.BackupTime, a.BackupKeep)
+		} else if err := backup.EnableSchedule(a.BackupTime, "full", a.BackupKeep, "", "", ""); err != nil {
+			ui.Warn("⚠️  Warning: Could not enable backups: %v\n", err)
+		} else {
+			fmt.Println("✅ Daily backups enabled")
+		}
+	}
+
+	fmt.Println("\n✅ Setup complete")
+	if !a.InstallNow {
+		fmt.Println("   Install the stack with: sudo webstack install all")
+	}
+	fmt.Println("   Add a first site with:  sudo webstack domain add example.com")
+	fmt.Println("   Check the server with:  sudo webstack doctor")
+}
+
+// writeConfig stores the defaults new domains and certificates use
+func writeConfig(a Answers) error {
+	backend := "nginx"
+	if a.Profile.WebServer == "apache" {
+		backend = "apache"
+	}
+	return config.Update(func(cfg *config.Config) error {
+		cfg.SetDefault(config.DefaultBackendKey, backend)
+		if a.Profile.PHPVersion != "" {
+			cfg.SetDefault(config.DefaultPHPKey, a.Profile.PHPVersion)
+		}
+		if a.AdminEmail != "" {
+			cfg.SetDefault(ssl.DefaultEmailKey, a.AdminEmail)
+		}
+		if a.Security {
+			cfg.SetDefault("harden_webroot", true)
+		}
+		return nil
+	})
+}
+
+// applySecurityBaseline installs fail2ban (and a firewall tool if there is
+// none) and applies the core firewall rules and the registered ports
+func applySecurityBaseline() {
+	fmt.Println("\n🔒 Applying the security baseline...")
+
+	packages := append([]string(nil), fail2banPackages...)
+	hasFirewall := firewall.Current().Available()
+	if !hasFirewall {
+		packages = append(packages, firewallPackages...)
+	}
+	if err := pkg.Install(packages...); err != nil {
+		ui.Warn("⚠️  Warning: Could not install %s: %v\n", strings.Join(packages, ", "), err)
+	}
+	dryrun.Run(exec.Command("systemctl", "enable", "--now", "fail2ban"))
+
+	if dryrun.Enabled() && !hasFirewall {
+		fmt.Println("🔎 [dry-run] would apply the core firewall rules and registered ports")
+		return
+	}
+
+	added, err := firewall.Rebuild()
+	if err != nil {
+		ui.Warn("⚠️  Warning: Could not apply firewall rules: %v\n", err)
+		return
+	}
+	fmt.Printf("✅ Firewall rules applied (%d added), SSH kept open\n", added)
+}
+
+// currentTimezone returns the system timezone, UTC when it is unknown
+func currentTimezone() string {
+	if output, err := exec.Command("timedatectl", "show", "-p", "Timezone", "--value").Output(); err == nil {
+		if tz := strings.TrimSpace(string(output)); tz != "" {
+			return tz
+		}
+	}
+	if target, err := os.Readlink("/etc/localtime"); err == nil {
+		if i := strings.Index(target, "zoneinfo/"); i >= 0 {
+			return target[i+len("zoneinfo/"):]
+		}
+	}
+	return "UTC"
+}
+
+func validTimezone(tz string) bool {
+	if tz == "" || strings.Contains(tz, "..") {
+		return false
+	}
+	info, err := os.Stat(filepath.Join("/usr/share/zoneinfo", tz))
+	return err == nil && !info.IsDir()
+}
+
+func currentEmail() string {
+	cfg, err := config.Load()
+	if err != nil || cfg == nil {
+		return ""
+	}
+	email, _ := cfg.GetDefault(ssl.DefaultEmailKey, "").(string)
+	return email
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
@@ -0,0 +1,90 @@
+package snapshot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"webstack-cli/internal/dryrun"
+)
+
+const (
+	timerName   = "webstack-snapshot.timer"
+	serviceFile = "/etc/systemd/system/webstack-snapshot.service"
+	timerFile   = "/etc/systemd/system/webstack-snapshot.timer"
+)
+
+var timePattern = regexp.MustCompile(`^([01]\d|2[0-3]):[0-5]\d$`)
+
+// EnableSchedule installs a systemd timer taking a snapshot every day at
+// HH:MM when the configuration changed since the last one
+func EnableSchedule(at string) error {
+	if !timePattern.MatchString(at) {
+		return fmt.Errorf("Invalid time: %s. Use HH:MM", at)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("could not find the webstack binary: %v", err)
+	}
+
+	service := fmt.Sprintf(`[Unit]
+Description=WebStack configuration snapshot
+
+[Service]
+Type=oneshot
+ExecStart=%s snapshot create --scheduled --no-emoji --no-color
+SyslogIdentifier=webstack-snapshot
+`, executable)
+	timer := fmt.Sprintf(`[Unit]
+Description=Daily WebStack configuration snapshot
+
+[Timer]
+OnCalendar=*-*-* %s:00
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`, at)
+
+	if err := dryrun.WriteFile(serviceFile, []byte(service), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", serviceFile, err)
+	}
+	if err := dryrun.WriteFile(timerFile, []byte(timer), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", timerFile, err)
+	}
+	if err := dryrun.Run(exec.Command("systemctl", "daemon-reload")); err != nil {
+		return fmt.Errorf("systemctl daemon-reload failed: %v", err)
+	}
+	if err := dryrun.Run(exec.Command("systemctl", "enable", "--now", timerName)); err != nil {
+		return fmt.Errorf("could not enable %s: %v", timerName, err)
+	}
+	return nil
+}
+
+// DisableSchedule stops and removes the snapshot timer
+func DisableSchedule() error {
+	if _, err := os.Stat(timerFile); os.IsNotExist(err) {
+		return fmt.Errorf("scheduled snapshots are not enabled")
+	}
+	dryrun.Run(exec.Command("systemctl", "disable", "--now", timerName))
+	dryrun.Remove(timerFile)
+	dryrun.Remove(serviceFile)
+	return dryrun.Run(exec.Command("systemctl", "daemon-reload"))
+}
+
+// ScheduleTime returns the HH:MM of the snapshot timer, or "" when it is
+// not enabled
+func ScheduleTime() string {
+	data, err := ioutil.ReadFile(timerFile)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "OnCalendar=*-*-* ") {
+			return strings.TrimSuffix(strings.TrimPrefix(line, "OnCalendar=*-*-* "), ":00")
+		}
+	}
+	return "enabled"
+}
@@ -0,0 +1,478 @@
+package snapshot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"webstack-cli/internal/store"
+	"webstack-cli/internal/ui"
+)
+
+// Dir holds one directory per configuration snapshot, named after the time
+// it was taken
+const Dir = "/var/lib/webstack/snapshots"
+
+// keep is the number of snapshots kept; older ones are pruned
+const keep = 30
+
+// maxFileSize leaves out files too large to be hand-edited configuration
+const maxFileSize = 1 << 20
+
+// patterns are the configuration files a snapshot captures, besides the
+// state documents and the live firewall rules
+var patterns = []string{
+	"/etc/webstack/*.json",
+	"/etc/webstack/*.conf",
+	"/etc/webstack/cron/*",
+	"/etc/nginx/nginx.conf",
+	"/etc/nginx/conf.d/*",
+	"/etc/nginx/sites-available/*",
+	"/etc/nginx/sites-enabled/*",
+	"/etc/apache2/apache2.conf",
+	"/etc/apache2/ports.conf",
+	"/etc/apache2/sites-available/*",
+	"/etc/apache2/sites-enabled/*",
+	"/etc/httpd/conf.d/webstack.conf",
+	"/etc/httpd/ports.conf",
+	"/etc/httpd/sites-available/*",
+	"/etc/httpd/sites-enabled/*",
+	"/etc/php/*/fpm/pool.d/*",
+	"/etc/opt/remi/php*/php-fpm.d/*",
+	"/etc/logrotate.d/webstack-*",
+	"/etc/postfix/main.cf",
+	"/etc/postfix/master.cf",
+	"/etc/postfix/vdomains",
+	"/etc/postfix/vmailbox",
+	"/etc/postfix/virtual",
+	"/etc/dovecot/dovecot.conf",
+	"/etc/dovecot/conf.d/*",
+	"/etc/opendkim.conf",
+	"/etc/opendkim/KeyTable",
+	"/etc/opendkim/SigningTable",
+	"/etc/opendkim/TrustedHosts",
+	"/etc/iptables/rules.v4",
+	"/etc/iptables/rules.v6",
+	"/etc/nftables.conf",
+	"/etc/fail2ban/jail.local",
+}
+
+// liveRules are the firewall rules loaded in the kernel, captured as the
+// output of these commands
+var liveRules = []struct {
+	path    string
+	command []string
+}{
+	{"live:iptables", []string{"iptables-save"}},
+	{"live:ip6tables", []string{"ip6tables-save"}},
+}
+
+// generatedPatterns are the files 'domain rebuild-configs' rewrites
+var generatedPatterns = []string{
+	"/etc/nginx/sites-available/",
+	"/etc/apache2/sites-available/",
+	"/etc/httpd/sites-available/",
+	"/etc/php/*/fpm/pool.d/",
+	"/etc/opt/remi/php*/php-fpm.d/",
+}
+
+// Snapshot describes a configuration snapshot
+type Snapshot struct {
+	ID        string    `json:"-"`
+	Created   time.Time `json:"created"`
+	Note      string    `json:"note,omitempty"`
+	Scheduled bool      `json:"scheduled,omitempty"`
+	Files     []File    `json:"files"`
+}
+
+// File is a configuration file, state document or ruleset in a snapshot
+type File struct {
+	Path   string      `json:"path"`
+	Mode   os.FileMode `json:"mode"`
+	Link   string      `json:"link,omitempty"` // Target when the file is a symlink
+	SHA256 string      `json:"sha256"`
+}
+
+// Change is a difference between a snapshot and the current configuration
+type Change struct {
+	Path string
+	Kind string // "modified", "added" or "removed"
+}
+
+// entry is a file of the current configuration
+type entry struct {
+	File
+	data []byte
+}
+
+// counterPattern matches the packet and byte counters of iptables-save,
+// which change all the time
+var counterPattern = regexp.MustCompile(`\[\d+:\d+\]`)
+
+// Create snapshots the current configuration
+func Create(note string, scheduled bool) (*Snapshot, error) {
+	entries, err := current()
+	if err != nil {
+		return nil, err
+	}
+	return create(entries, note, scheduled)
+}
+
+func create(entries []entry, note string, scheduled bool) (*Snapshot, error) {
+	now := time.Now()
+	id := now.Format("20060102-150405")
+	dir := filepath.Join(Dir, id)
+	for i := 2; exists(dir); i++ {
+		id = fmt.Sprintf("%s-%d", now.Format("20060102-150405"), i)
+		dir = filepath.Join(Dir, id)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("could not create %s: %v", dir, err)
+	}
+
+	snap := &Snapshot{ID: id, Created: now, Note: note, Scheduled: scheduled}
+	for _, e := range entries {
+		if e.Link == "" {
+			if err := writeCopy(filepath.Join(dir, "files", storedName(e.Path)), e.data); err != nil {
+				os.RemoveAll(dir)
+				return nil, err
+			}
+		}
+		snap.Files = append(snap.Files, e.File)
+	}
+	if err := snap.writeManifest(); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	prune()
+	return snap, nil
+}
+
+// current reads the configuration a snapshot captures, sorted by path
+func current() ([]entry, error) {
+	var entries []entry
+
+	// Documents go through the store so they are captured from SQLite too
+	for _, name := range store.Names() {
+		data, found, err := store.Dump(name)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %v", name, err)
+		}
+		if found {
+			entries = append(entries, newEntry(filepath.Join("/etc/webstack", name), 0600, "", data))
+		}
+	}
+
+	for _, pattern := range patterns {
+		found, _ := filepath.Glob(pattern)
+		for _, path := range found {
+			if filepath.Dir(path) == "/etc/webstack" && store.Managed(filepath.Base(path)) {
+				continue
+			}
+			info, err := os.Lstat(path)
+			if err != nil {
+				continue
+			}
+			switch {
+			case info.Mode()&os.ModeSymlink != 0:
+				link, err := os.Readlink(path)
+				if err != nil {
+					return nil, fmt.Errorf("could not read %s: %v", path, err)
+				}
+				entries = append(entries, newEntry(path, info.Mode().Perm(), link, nil))
+			case info.Mode().IsRegular() && info.Size() <= maxFileSize:
+				data, err := ioutil.ReadFile(path)
+				if err != nil {
+					return nil, fmt.Errorf("could not read %s: %v", path, err)
+				}
+				entries = append(entries, newEntry(path, info.Mode().Perm(), "", data))
+			}
+		}
+	}
+
+	for _, rules := range liveRules {
+		if _, err := exec.LookPath(rules.command[0]); err != nil {
+			continue
+		}
+		output, err := exec.Command(rules.command[0], rules.command[1:]...).Output()
+		if err != nil || len(bytes.TrimSpace(output)) == 0 {
+			continue
+		}
+		entries = append(entries, newEntry(rules.path, 0600, "", normalizeRules(output)))
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+func newEntry(path string, mode os.FileMode, link string, data []byte) entry {
+	sum := sha256.Sum256(data)
+	if link != "" {
+		sum = sha256.Sum256([]byte("link:" + link))
+	}
+	return entry{File: File{Path: path, Mode: mode, Link: link, SHA256: fmt.Sprintf("%x", sum)}, data: data}
+}
+
+// normalizeRules drops the comments with timestamps and the counters from
+// iptables-save output, so only rule changes show up as drift
+func normalizeRules(output []byte) []byte {
+	var lines []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, counterPattern.ReplaceAllString(line, "[0:0]"))
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// storedName maps a path to its copy inside a snapshot
+func storedName(path string) string {
+	if strings.HasPrefix(path, "live:") {
+		return filepath.Join("live", strings.TrimPrefix(path, "live:"))
+	}
+	return path
+}
+
+// Diff compares the current configuration with the snapshot
+func (s *Snapshot) Diff() ([]Change, error) {
+	entries, err := current()
+	if err != nil {
+		return nil, err
+	}
+	return s.diff(entries), nil
+}
+
+func (s *Snapshot) diff(entries []entry) []Change {
+	captured := map[string]File{}
+	for _, f := range s.Files {
+		captured[f.Path] = f
+	}
+
+	var changes []Change
+	seen := map[string]bool{}
+	for _, e := range entries {
+		seen[e.Path] = true
+		old, ok := captured[e.Path]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Path: e.Path, Kind: "added"})
+		case old.SHA256 != e.SHA256:
+			changes = append(changes, Change{Path: e.Path, Kind: "modified"})
+		}
+	}
+	for _, f := range s.Files {
+		if !seen[f.Path] {
+			changes = append(changes, Change{Path: f.Path, Kind: "removed"})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// CreateScheduled is run by the snapshot timer: it reports and notifies the
+// changes since the last snapshot and snapshots them, or does nothing when
+// there are none
+func CreateScheduled() (*Snapshot, []Change, error) {
+	entries, err := current()
+	if err != nil {
+		return nil, nil, err
+	}
+	last, err := Last()
+	if err != nil {
+		snap, err := create(entries, "first scheduled snapshot", true)
+		return snap, nil, err
+	}
+	changes := last.diff(entries)
+	if len(changes) == 0 {
+		return nil, nil, nil
+	}
+	snap, err := create(entries, fmt.Sprintf("%d change(s) since %s", len(changes), last.ID), true)
+	return snap, changes, err
+}
+
+// Generated returns the changes to files 'domain rebuild-configs' rewrites
+func Generated(changes []Change) []Change {
+	var generated []Change
+	for _, c := range changes {
+		if c.Kind == "removed" {
+			continue
+		}
+		for _, pattern := range generatedPatterns {
+			if ok, _ := filepath.Match(pattern+"*", c.Path); ok {
+				generated = append(generated, c)
+				break
+			}
+		}
+	}
+	return generated
+}
+
+// PrintDiff prints a unified diff of each changed file against the snapshot
+func (s *Snapshot) PrintDiff(changes []Change) {
+	entries, err := current()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	tmpDir, err := ioutil.TempDir("", "webstack-snapshot-")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, c := range changes {
+		fmt.Printf("\n%s (%s)\n", c.Path, c.Kind)
+		s.printDiff(c, entries, tmpDir)
+	}
+}
+
+func (s *Snapshot) printDiff(c Change, entries []entry, tmpDir string) {
+	var file File
+	for _, f := range s.Files {
+		if f.Path == c.Path {
+			file = f
+		}
+	}
+
+	// Both sides go to temporary files: documents and rulesets are not files,
+	// and a symlink is shown as its target
+	before := filepath.Join(tmpDir, "before")
+	after := filepath.Join(tmpDir, "after")
+	var oldData, newData []byte
+	if c.Kind != "added" {
+		if file.Link != "" {
+			oldData = []byte("-> " + file.Link + "\n")
+		} else {
+			oldData, _ = ioutil.ReadFile(filepath.Join(Dir, s.ID, "files", storedName(c.Path)))
+		}
+	}
+	if c.Kind != "removed" {
+		for _, e := range entries {
+			if e.Path != c.Path {
+				continue
+			}
+			if e.Link != "" {
+				newData = []byte("-> " + e.Link + "\n")
+			} else {
+				newData = e.data
+			}
+		}
+	}
+	if bytes.IndexByte(oldData, 0) >= 0 || bytes.IndexByte(newData, 0) >= 0 {
+		fmt.Println("    (binary file differs)")
+		return
+	}
+	ioutil.WriteFile(before, oldData, 0600)
+	ioutil.WriteFile(after, newData, 0600)
+
+	output, _ := exec.Command("diff", "-u", "--label", c.Path+" ("+s.ID+")", "--label", c.Path+" (now)", before, after).Output()
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		fmt.Println("    " + line)
+	}
+}
+
+// Last returns the most recent snapshot
+func Last() (*Snapshot, error) {
+	snaps, err := List()
+	if err != nil {
+		return nil, err
+	}
+	if len(snaps) == 0 {
+		return nil, fmt.Errorf("no snapshots in %s (create one with 'webstack snapshot create')", Dir)
+	}
+	return snaps[0], nil
+}
+
+// Get returns the snapshot with the given ID
+func Get(id string) (*Snapshot, error) {
+	if id == "" || strings.ContainsAny(id, "/.") {
+		return nil, fmt.Errorf("invalid snapshot ID %q", id)
+	}
+	return load(id)
+}
+
+// List returns the snapshots, newest first
+func List() ([]*Snapshot, error) {
+	entries, err := ioutil.ReadDir(Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snaps []*Snapshot
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		snap, err := load(e.Name())
+		if err != nil {
+			continue
+		}
+		snaps = append(snaps, snap)
+	}
+	sort.Slice(snaps, func(i, j int) bool {
+		if !snaps[i].Created.Equal(snaps[j].Created) {
+			return snaps[i].Created.After(snaps[j].Created)
+		}
+		return snaps[i].ID > snaps[j].ID
+	})
+	return snaps, nil
+}
+
+func load(id string) (*Snapshot, error) {
+	data, err := ioutil.ReadFile(filepath.Join(Dir, id, "manifest.json"))
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s not found", id)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("could not parse snapshot %s: %v", id, err)
+	}
+	snap.ID = id
+	return &snap, nil
+}
+
+func (s *Snapshot) writeManifest() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(Dir, s.ID, "manifest.json"), data, 0600)
+}
+
+// prune removes all but the newest snapshots
+func prune() {
+	snaps, err := List()
+	if err != nil || len(snaps) <= keep {
+		return
+	}
+	for _, snap := range snaps[keep:] {
+		os.RemoveAll(filepath.Join(Dir, snap.ID))
+	}
+}
+
+func writeCopy(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("could not write %s: %v", path, err)
+	}
+	return nil
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,142 @@
+package ssl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/ui"
+)
+
+// acmeAccountEmails returns the emails of the accounts stored by the
+// built-in ACME client
+func acmeAccountEmails() ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(acmeDir, "accounts"))
+	if err != nil {
+		return nil, err
+	}
+	var emails []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			emails = append(emails, entry.Name())
+		}
+	}
+	return emails, nil
+}
+
+// ListAccounts shows the Let's Encrypt accounts of the built-in ACME client
+// and the certificates issued with each of them
+func ListAccounts() {
+	emails, err := acmeAccountEmails()
+	if err != nil || len(emails) == 0 {
+		fmt.Println("No ACME accounts (one is registered with the first certificate, or: webstack ssl account register <email>)")
+		return
+	}
+
+	certs, err := loadSSLCerts()
+	if err != nil {
+		ui.Fail("Error loading SSL certificates: %v\n", err)
+		return
+	}
+
+	fmt.Printf("ACME accounts (%s)\n", acmeDirectoryURL())
+	fmt.Println("=============")
+	for _, email := range emails {
+		account, err := loadACMEAccount(email)
+		if err != nil {
+			ui.Fail("❌ %s: %v\n", email, err)
+			continue
+		}
+
+		status := "not registered"
+		if account.Registration != nil {
+			status = account.Registration.Body.Status
+			if status == "" {
+				status = "valid"
+			}
+		}
+		fmt.Printf("%s (%s)\n", email, status)
+		if account.Registration != nil {
+			fmt.Printf("  URI:          %s\n", account.Registration.URI)
+		}
+
+		var domains []string
+		for _, cert := range certs {
+			if cert.Client == "builtin" && cert.Email == email {
+				domains = append(domains, cert.Domain)
+			}
+		}
+		if len(domains) > 0 {
+			fmt.Printf("  Certificates: %s\n", strings.Join(domains, ", "))
+		}
+	}
+}
+
+// RegisterAccount registers a Let's Encrypt account for an email address,
+// or confirms the existing registration
+func RegisterAccount(email string) {
+	if !strings.Contains(email, "@") || strings.ContainsAny(email, " \t/") {
+		ui.Invalid("Invalid email address: %s\n", email)
+		return
+	}
+	if dryrun.Enabled() {
+		fmt.Printf("🔎 [dry-run] would register a Let's Encrypt account for %s\n", email)
+		return
+	}
+
+	client, err := newACMEClient(email)
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	reg, err := client.Registration.QueryRegistration()
+	if err != nil {
+		ui.Fail("❌ Could not query account: %v%s\n", err, acmeErrorHint(err))
+		return
+	}
+	fmt.Printf("✅ Account for %s is %s\n", email, reg.Body.Status)
+	fmt.Printf("   URI: %s\n", reg.URI)
+}
+
+// DeactivateAccount deactivates the Let's Encrypt account of an email
+// address and removes its key. Renewals of its certificates register a new
+// account with the same email.
+func DeactivateAccount(email string) {
+	dir := filepath.Join(acmeDir, "accounts", email)
+	if strings.Contains(email, "/") || strings.HasPrefix(email, ".") {
+		ui.Invalid("Invalid email address: %s\n", email)
+		return
+	}
+	if _, err := ioutil.ReadDir(dir); err != nil {
+		ui.Fail("No ACME account for %s\n", email)
+		return
+	}
+
+	account, err := loadACMEAccount(email)
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	if account.Registration != nil {
+		if dryrun.Enabled() {
+			fmt.Printf("🔎 [dry-run] would deactivate the Let's Encrypt account %s\n", account.Registration.URI)
+		} else {
+			client, err := newACMEClient(email)
+			if err != nil {
+				ui.Fail("❌ %v\n", err)
+				return
+			}
+			if err := client.Registration.DeleteRegistration(); err != nil {
+				ui.Fail("❌ Could not deactivate account: %v%s\n", err, acmeErrorHint(err))
+				return
+			}
+		}
+	}
+
+	if err := dryrun.RemoveAll(dir); err != nil {
+		ui.Fail("❌ Could not remove %s: %v\n", dir, err)
+		return
+	}
+	fmt.Printf("✅ Account for %s deactivated\n", email)
+}
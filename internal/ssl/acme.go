@@ -0,0 +1,332 @@
+package ssl
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/dryrun"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/go-acme/lego/v4/challenge"
+	"github.com/go-acme/lego/v4/challenge/dns01"
+	"github.com/go-acme/lego/v4/challenge/http01"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/providers/dns/cloudflare"
+	"github.com/go-acme/lego/v4/providers/dns/route53"
+	"github.com/go-acme/lego/v4/providers/http/webroot"
+	"github.com/go-acme/lego/v4/registration"
+)
+
+// The built-in ACME client keeps its accounts and certificates here;
+// certificates issued by certbot stay under /etc/letsencrypt
+const acmeDir = "/etc/webstack/acme"
+
+// Certificates are renewed when they expire within this window
+const acmeRenewBefore = 30 * 24 * time.Hour
+
+// acmeAccount is a Let's Encrypt account, one per email address
+type acmeAccount struct {
+	Email        string                 `json:"email"`
+	Registration *registration.Resource `json:"registration,omitempty"`
+	key          crypto.PrivateKey
+}
+
+func (a *acmeAccount) GetEmail() string                        { return a.Email }
+func (a *acmeAccount) GetRegistration() *registration.Resource { return a.Registration }
+func (a *acmeAccount) GetPrivateKey() crypto.PrivateKey        { return a.key }
+
+// defaultACMEClient returns the configured ACME client ("builtin" unless
+// 'webstack config set acme_client certbot' was used)
+func defaultACMEClient() string {
+	if cfg, err := config.Load(); err == nil && cfg != nil {
+		if client, ok := cfg.GetDefault("acme_client", "").(string); ok && client != "" {
+			return client
+		}
+	}
+	return "builtin"
+}
+
+// acmeDirectoryURL returns the ACME directory: production (default), staging
+// or a custom URL set with 'webstack config set acme_directory'
+func acmeDirectoryURL() string {
+	directory := ""
+	if cfg, err := config.Load(); err == nil && cfg != nil {
+		directory, _ = cfg.GetDefault("acme_directory", "").(string)
+	}
+	switch directory {
+	case "", "production":
+		return lego.LEDirectoryProduction
+	case "staging":
+		return lego.LEDirectoryStaging
+	default:
+		return directory
+	}
+}
+
+func acmeCertDir(domainName string) string {
+	return filepath.Join(acmeDir, "certificates", domainName)
+}
+
+// loadACMEAccount loads the account of an email address, creating its key
+// on first use
+func loadACMEAccount(email string) (*acmeAccount, error) {
+	dir := filepath.Join(acmeDir, "accounts", email)
+	keyPath := filepath.Join(dir, "account.key")
+
+	account := &acmeAccount{Email: email}
+	if data, err := ioutil.ReadFile(filepath.Join(dir, "account.json")); err == nil {
+		if err := json.Unmarshal(data, account); err != nil {
+			return nil, fmt.Errorf("could not parse ACME account for %s: %v", email, err)
+		}
+	}
+
+	if data, err := ioutil.ReadFile(keyPath); err == nil {
+		key, err := certcrypto.ParsePEMPrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse ACME account key %s: %v", keyPath, err)
+		}
+		account.key = key
+		return account, nil
+	}
+
+	key, err := certcrypto.GeneratePrivateKey(certcrypto.EC256)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate ACME account key: %v", err)
+	}
+	account.key = key
+	account.Registration = nil
+
+	if err := dryrun.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("could not create %s: %v", dir, err)
+	}
+	if err := dryrun.WriteFile(keyPath, certcrypto.PEMEncode(key), 0600); err != nil {
+		return nil, fmt.Errorf("could not write ACME account key: %v", err)
+	}
+	return account, nil
+}
+
+func saveACMEAccount(account *acmeAccount) error {
+	data, err := json.MarshalIndent(account, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(acmeDir, "accounts", account.Email, "account.json")
+	return dryrun.WriteFile(path, data, 0600)
+}
+
+// newACMEClient returns a client for the account of email, registering the
+// account with Let's Encrypt if needed
+func newACMEClient(email string) (*lego.Client, error) {
+	account, err := loadACMEAccount(email)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := lego.NewConfig(account)
+	cfg.CADirURL = acmeDirectoryURL()
+
+	client, err := lego.NewClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not reach ACME directory %s: %v", cfg.CADirURL, err)
+	}
+
+	if account.Registration == nil {
+		fmt.Printf("📝 Registering Let's Encrypt account for %s...\n", email)
+		reg, err := client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+		if err != nil {
+			return nil, fmt.Errorf("account registration failed: %v", err)
+		}
+		account.Registration = reg
+		if err := saveACMEAccount(account); err != nil {
+			return nil, fmt.Errorf("could not save ACME account: %v", err)
+		}
+	}
+
+	return client, nil
+}
+
+// obtainCertificateACME requests a certificate with the built-in ACME client.
+// HTTP-01 uses the shared challenge web root so web servers keep running; a
+// standalone listener on port 80 is only used when no web server is running.
+func obtainCertificateACME(domainName, email string, opts LetsEncryptOptions) (string, string, time.Time, error) {
+	names := opts.Names(domainName)
+
+	if dryrun.Enabled() {
+		fmt.Printf("🔎 [dry-run] would request a certificate for %s via %s-01\n", strings.Join(names, ", "), opts.Challenge)
+		return filepath.Join(acmeCertDir(domainName), "fullchain.pem"), filepath.Join(acmeCertDir(domainName), "privkey.pem"), time.Now().AddDate(0, 3, 0), nil
+	}
+
+	client, err := newACMEClient(email)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	if opts.Challenge == "dns" {
+		provider, err := acmeDNSProvider(opts.DNSProvider)
+		if err != nil {
+			return "", "", time.Time{}, err
+		}
+		if err := client.Challenge.SetDNS01Provider(provider); err != nil {
+			return "", "", time.Time{}, err
+		}
+	} else if webServerRunning() {
+		if err := prepareWebroot(domainName); err != nil {
+			return "", "", time.Time{}, err
+		}
+		provider, err := webroot.NewHTTPProvider(acmeWebroot)
+		if err != nil {
+			return "", "", time.Time{}, err
+		}
+		if err := client.Challenge.SetHTTP01Provider(provider); err != nil {
+			return "", "", time.Time{}, err
+		}
+	} else {
+		// Nothing listens on port 80, so answer the challenge ourselves
+		if err := client.Challenge.SetHTTP01Provider(http01.NewProviderServer("", "80")); err != nil {
+			return "", "", time.Time{}, err
+		}
+	}
+
+	resource, err := client.Certificate.Obtain(certificate.ObtainRequest{Domains: names, Bundle: true})
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("%v%s", err, acmeErrorHint(err))
+	}
+
+	return saveACMECertificate(domainName, resource)
+}
+
+// saveACMECertificate writes the certificate chain and key of a domain
+func saveACMECertificate(domainName string, resource *certificate.Resource) (string, string, time.Time, error) {
+	dir := acmeCertDir(domainName)
+	certPath := filepath.Join(dir, "fullchain.pem")
+	keyPath := filepath.Join(dir, "privkey.pem")
+
+	parsed, err := certcrypto.ParsePEMCertificate(resource.Certificate)
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("could not parse issued certificate: %v", err)
+	}
+
+	if err := dryrun.MkdirAll(dir, 0700); err != nil {
+		return "", "", time.Time{}, fmt.Errorf("could not create %s: %v", dir, err)
+	}
+	if err := dryrun.WriteFile(keyPath, resource.PrivateKey, 0600); err != nil {
+		return "", "", time.Time{}, fmt.Errorf("could not write private key: %v", err)
+	}
+	if err := dryrun.WriteFile(certPath, resource.Certificate, 0644); err != nil {
+		return "", "", time.Time{}, fmt.Errorf("could not write certificate: %v", err)
+	}
+
+	return certPath, keyPath, parsed.NotAfter, nil
+}
+
+// renewCertificateACME requests a new certificate for an existing entry with
+// the names and challenge it was issued with
+func renewCertificateACME(cert *SSLCertificate) error {
+	opts := LetsEncryptOptions{
+		Challenge:   cert.Challenge,
+		AltNames:    cert.AltNames,
+		DNSProvider: cert.DNSProvider,
+		Client:      "builtin",
+	}
+	if opts.Challenge == "" {
+		opts.Challenge = "http"
+	}
+
+	_, _, expiresAt, err := obtainCertificateACME(cert.Domain, cert.Email, opts)
+	if err != nil {
+		return err
+	}
+
+	cert.IssuedAt = time.Now()
+	cert.ExpiresAt = expiresAt
+	refreshCertificate(cert)
+	return saveSSLCert(*cert)
+}
+
+// acmeDNSProvider returns the DNS-01 provider for a --dns-provider value
+func acmeDNSProvider(name string) (challenge.Provider, error) {
+	switch name {
+	case "bind":
+		return bindDNSProvider{}, nil
+	case "cloudflare":
+		credentials, err := ensureCloudflareCredentials()
+		if err != nil {
+			return nil, err
+		}
+		token, err := readCloudflareToken(credentials)
+		if err != nil {
+			return nil, err
+		}
+		cfg := cloudflare.NewDefaultConfig()
+		cfg.AuthToken = token
+		return cloudflare.NewDNSProviderConfig(cfg)
+	case "route53":
+		fmt.Println("   Using AWS credentials from the environment or /root/.aws/credentials")
+		return route53.NewDNSProvider()
+	}
+	return nil, fmt.Errorf("invalid DNS provider: %s", name)
+}
+
+// readCloudflareToken reads the API token from the certbot credentials file
+func readCloudflareToken(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !dryrun.Enabled() {
+		return "", fmt.Errorf("could not read Cloudflare credentials: %v", err)
+	}
+	if m := regexp.MustCompile(`(?m)^dns_cloudflare_api_token\s*=\s*(\S+)`).FindSubmatch(data); m != nil {
+		return string(m[1]), nil
+	}
+	if token := os.Getenv("CLOUDFLARE_API_TOKEN"); token != "" {
+		return token, nil
+	}
+	return "", fmt.Errorf("no Cloudflare API token found in %s", path)
+}
+
+// bindDNSProvider solves DNS-01 challenges in the local bind9 zones
+type bindDNSProvider struct{}
+
+func (bindDNSProvider) Present(domainName, token, keyAuth string) error {
+	info := dns01.GetChallengeInfo(domainName, keyAuth)
+	return updateBindTXT(strings.TrimPrefix(domainName, "*."), info.Value, "auth")
+}
+
+func (bindDNSProvider) CleanUp(domainName, token, keyAuth string) error {
+	info := dns01.GetChallengeInfo(domainName, keyAuth)
+	return updateBindTXT(strings.TrimPrefix(domainName, "*."), info.Value, "cleanup")
+}
+
+// Timeout gives secondaries time to pick up the NOTIFY
+func (bindDNSProvider) Timeout() (time.Duration, time.Duration) {
+	return 3 * time.Minute, 5 * time.Second
+}
+
+// acmeErrorHint explains the most common ACME failures
+func acmeErrorHint(err error) string {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "rateLimited"):
+		return "\n   Let's Encrypt rate limit reached; wait before retrying or test with: webstack config set acme_directory staging"
+	case strings.Contains(msg, "urn:ietf:params:acme:error:dns"), strings.Contains(msg, "NXDOMAIN"):
+		return "\n   The name does not resolve publicly; check the A/AAAA records"
+	case strings.Contains(msg, "urn:ietf:params:acme:error:connection"), strings.Contains(msg, "urn:ietf:params:acme:error:unauthorized"):
+		return "\n   Let's Encrypt could not fetch the challenge over HTTP; make sure port 80 reaches this server, or use --challenge dns"
+	case strings.Contains(msg, "urn:ietf:params:acme:error:caa"):
+		return "\n   A CAA record forbids Let's Encrypt from issuing for this name"
+	case strings.Contains(msg, "time limit exceeded"), strings.Contains(msg, "propagation"):
+		return "\n   The TXT record did not propagate in time; check the DNS provider and retry"
+	}
+	return ""
+}
+
+// isACMECertDue reports whether a certificate should be renewed
+func isACMECertDue(cert SSLCertificate) bool {
+	return time.Until(cert.ExpiresAt) < acmeRenewBefore
+}
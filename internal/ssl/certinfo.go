@@ -0,0 +1,108 @@
+package ssl
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"webstack-cli/internal/ui"
+)
+
+// readCertificate parses the first certificate of a PEM file
+func readCertificate(path string) (*x509.Certificate, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no certificate path in %s", sslConfigFile)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM certificate", path)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// refreshCertificate updates the validity and issuer of an entry from its
+// certificate file and reports whether anything changed. Renewals by certbot
+// or by hand replace the file without touching ssl.json.
+func refreshCertificate(cert *SSLCertificate) (*x509.Certificate, bool) {
+	parsed, err := readCertificate(cert.CertPath)
+	if err != nil {
+		return nil, false
+	}
+
+	issuer := issuerName(parsed)
+	changed := !cert.ExpiresAt.Equal(parsed.NotAfter) || !cert.IssuedAt.Equal(parsed.NotBefore) || cert.Issuer != issuer
+	cert.IssuedAt = parsed.NotBefore
+	cert.ExpiresAt = parsed.NotAfter
+	cert.Issuer = issuer
+	return parsed, changed
+}
+
+// refreshCertificates refreshes every entry from its certificate file and
+// saves ssl.json when one changed. The parsed certificates are returned by
+// index; entries whose file cannot be read are nil.
+func refreshCertificates(certs []SSLCertificate) []*x509.Certificate {
+	parsed := make([]*x509.Certificate, len(certs))
+	changed := false
+	for i := range certs {
+		var updated bool
+		parsed[i], updated = refreshCertificate(&certs[i])
+		changed = changed || updated
+	}
+	if changed {
+		if err := saveSSLCerts(certs); err != nil {
+			ui.Warn("⚠️  Warning: Could not update %s: %v\n", sslConfigFile, err)
+		}
+	}
+	return parsed
+}
+
+// refreshCertificateEntry refreshes the ssl.json entry of a domain after its
+// certificate file was replaced
+func refreshCertificateEntry(domainName string) {
+	certs, err := loadSSLCerts()
+	if err != nil {
+		return
+	}
+	for i := range certs {
+		if certs[i].Domain != domainName {
+			continue
+		}
+		if _, changed := refreshCertificate(&certs[i]); changed {
+			if err := saveSSLCerts(certs); err != nil {
+				ui.Warn("⚠️  Warning: Could not update %s: %v\n", sslConfigFile, err)
+			}
+		}
+		return
+	}
+}
+
+func issuerName(cert *x509.Certificate) string {
+	if cert.Issuer.Organization != nil && cert.Issuer.CommonName != "" {
+		return fmt.Sprintf("%s (%s)", cert.Issuer.CommonName, strings.Join(cert.Issuer.Organization, ", "))
+	}
+	if cert.Issuer.CommonName != "" {
+		return cert.Issuer.CommonName
+	}
+	return cert.Issuer.String()
+}
+
+// keyType describes the public key of a certificate, e.g. "ECDSA P-256"
+func keyType(cert *x509.Certificate) string {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + key.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return cert.PublicKeyAlgorithm.String()
+}
@@ -0,0 +1,407 @@
+package ssl
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/pkg"
+)
+
+// LetsEncryptOptions controls how a Let's Encrypt certificate is requested
+type LetsEncryptOptions struct {
+	Challenge   string   // "http" (default) or "dns"
+	Wildcard    bool     // Also request *.domain (requires DNS-01)
+	AltNames    []string // Additional subject alternative names
+	DNSProvider string   // "bind", "cloudflare" or "route53" (DNS-01 only)
+	Client      string   // ACME client: "builtin" (default) or "certbot"
+}
+
+const bindLocalConf = "/etc/bind/named.conf.local"
+const dnsCredentialsDir = "/etc/webstack/dns"
+const acmeRecordMarker = "; webstack-acme"
+
+// Names returns the full list of names the certificate should cover
+func (o LetsEncryptOptions) Names(domainName string) []string {
+	names := []string{domainName}
+	if o.Wildcard {
+		names = append(names, "*."+domainName)
+	}
+	for _, name := range o.AltNames {
+		name = domain.Normalize(name)
+		if name == "" || containsString(names, name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// normalize fills in defaults and validates the option combination
+func (o *LetsEncryptOptions) normalize(domainName string) error {
+	o.Challenge = strings.TrimSpace(strings.ToLower(o.Challenge))
+	o.DNSProvider = strings.TrimSpace(strings.ToLower(o.DNSProvider))
+	o.Client = strings.TrimSpace(strings.ToLower(o.Client))
+
+	if o.Client == "" {
+		o.Client = defaultACMEClient()
+	}
+	if o.Client != "builtin" && o.Client != "certbot" {
+		return fmt.Errorf("invalid ACME client: %s. Use 'builtin' or 'certbot'", o.Client)
+	}
+
+	hasWildcard := o.Wildcard
+	for _, name := range o.AltNames {
+		if strings.HasPrefix(strings.TrimSpace(name), "*.") {
+			hasWildcard = true
+		}
+	}
+
+	switch o.Challenge {
+	case "", "http", "http-01":
+		if hasWildcard {
+			if o.Challenge != "" {
+				return fmt.Errorf("wildcard certificates require the DNS-01 challenge (use --challenge dns)")
+			}
+			o.Challenge = "dns"
+		} else {
+			o.Challenge = "http"
+		}
+	case "dns", "dns-01":
+		o.Challenge = "dns"
+	default:
+		return fmt.Errorf("invalid challenge type: %s. Use 'http' or 'dns'", o.Challenge)
+	}
+
+	if o.Challenge != "dns" {
+		return nil
+	}
+
+	switch o.DNSProvider {
+	case "":
+		if _, _, err := findBindZone(domainName); err != nil {
+			return fmt.Errorf("no DNS provider specified and %v (use --dns-provider cloudflare|route53)", err)
+		}
+		o.DNSProvider = "bind"
+	case "bind", "bind9":
+		o.DNSProvider = "bind"
+	case "cloudflare", "route53":
+	default:
+		return fmt.Errorf("invalid DNS provider: %s. Use 'bind', 'cloudflare' or 'route53'", o.DNSProvider)
+	}
+
+	return nil
+}
+
+// requestCertificateDNS requests a certificate using the DNS-01 challenge
+func requestCertificateDNS(domainName, email string, opts LetsEncryptOptions) (string, string, error) {
+	args := []string{
+		"certonly",
+		"--non-interactive",
+		"--agree-tos",
+		"--email", email,
+		"--cert-name", domainName,
+		"--preferred-challenges", "dns",
+	}
+
+	switch opts.DNSProvider {
+	case "bind":
+		for _, name := range opts.Names(domainName) {
+			if _, _, err := findBindZone(strings.TrimPrefix(name, "*.")); err != nil {
+				return "", "", err
+			}
+		}
+
+		hookBinary, err := os.Executable()
+		if err != nil {
+			return "", "", fmt.Errorf("could not determine webstack binary path: %v", err)
+		}
+		args = append(args,
+			"--manual",
+			"--manual-auth-hook", hookBinary+" ssl dns-hook auth",
+			"--manual-cleanup-hook", hookBinary+" ssl dns-hook cleanup",
+		)
+
+	case "cloudflare":
+		if err := ensureCertbotPlugin("python3-certbot-dns-cloudflare"); err != nil {
+			return "", "", err
+		}
+		credentials, err := ensureCloudflareCredentials()
+		if err != nil {
+			return "", "", err
+		}
+		args = append(args,
+			"--dns-cloudflare",
+			"--dns-cloudflare-credentials", credentials,
+			"--dns-cloudflare-propagation-seconds", "30",
+		)
+
+	case "route53":
+		if err := ensureCertbotPlugin("python3-certbot-dns-route53"); err != nil {
+			return "", "", err
+		}
+		fmt.Println("   Using AWS credentials from the environment or /root/.aws/credentials")
+		args = append(args, "--dns-route53")
+	}
+
+	for _, name := range opts.Names(domainName) {
+		args = append(args, "-d", name)
+	}
+
+	cmd := exec.Command("certbot", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := dryrun.Run(cmd); err != nil {
+		return "", "", fmt.Errorf("certbot DNS-01 certificate request failed: %v", err)
+	}
+
+	certPath := fmt.Sprintf("/etc/letsencrypt/live/%s/fullchain.pem", domainName)
+	keyPath := fmt.Sprintf("/etc/letsencrypt/live/%s/privkey.pem", domainName)
+
+	if _, err := os.Stat(certPath); os.IsNotExist(err) {
+		return "", "", fmt.Errorf("certificate file not found at %s", certPath)
+	}
+	if _, err := os.Stat(keyPath); os.IsNotExist(err) {
+		return "", "", fmt.Errorf("key file not found at %s", keyPath)
+	}
+
+	return certPath, keyPath, nil
+}
+
+// DNSHook is invoked by certbot as manual auth/cleanup hook for the bind
+// DNS provider. It reads CERTBOT_DOMAIN and CERTBOT_VALIDATION from the
+// environment and adds or removes the _acme-challenge TXT record in the
+// local bind9 zone.
+func DNSHook(action string) error {
+	domainName := strings.TrimPrefix(os.Getenv("CERTBOT_DOMAIN"), "*.")
+	validation := os.Getenv("CERTBOT_VALIDATION")
+	if domainName == "" || validation == "" {
+		return fmt.Errorf("CERTBOT_DOMAIN and CERTBOT_VALIDATION must be set (this command is meant to be run by certbot)")
+	}
+
+	if err := updateBindTXT(domainName, validation, action); err != nil {
+		return err
+	}
+
+	if action == "auth" {
+		// Give secondaries a moment to pick up the NOTIFY
+		time.Sleep(10 * time.Second)
+	}
+
+	return nil
+}
+
+// updateBindTXT adds ("auth") or removes ("cleanup") the _acme-challenge TXT
+// record of a name in its local bind9 zone and reloads the zone
+func updateBindTXT(domainName, validation, action string) error {
+	zoneName, zoneFile, err := findBindZone(domainName)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(zoneFile)
+	if err != nil {
+		return fmt.Errorf("could not read zone file %s: %v", zoneFile, err)
+	}
+
+	recordName := "_acme-challenge." + domainName + "."
+	record := fmt.Sprintf("%s 60 IN TXT \"%s\" %s", recordName, validation, acmeRecordMarker)
+
+	content := string(data)
+	switch action {
+	case "auth":
+		if !strings.Contains(content, record) {
+			content = strings.TrimRight(content, "\n") + "\n" + record + "\n"
+		}
+	case "cleanup":
+		var kept []string
+		for _, line := range strings.Split(content, "\n") {
+			if strings.Contains(line, acmeRecordMarker) && strings.Contains(line, recordName) && strings.Contains(line, validation) {
+				continue
+			}
+			kept = append(kept, line)
+		}
+		content = strings.Join(kept, "\n")
+	default:
+		return fmt.Errorf("unknown hook action: %s", action)
+	}
+
+	content = incrementZoneSerial(content)
+
+	if err := dryrun.WriteFile(zoneFile, []byte(content), 0644); err != nil {
+		return fmt.Errorf("could not write zone file %s: %v", zoneFile, err)
+	}
+
+	if err := exec.Command("named-checkzone", zoneName, zoneFile).Run(); err != nil {
+		dryrun.WriteFile(zoneFile, data, 0644)
+		return fmt.Errorf("zone %s is invalid after update, changes reverted", zoneName)
+	}
+
+	if err := runCommand("rndc", "reload", zoneName); err != nil {
+		runCommand("systemctl", "reload", "bind9")
+	}
+
+	return nil
+}
+
+// findBindZone finds the bind9 master zone responsible for a name by
+// walking up its labels, returning the zone name and zone file path
+func findBindZone(name string) (string, string, error) {
+	data, err := ioutil.ReadFile(bindLocalConf)
+	if err != nil {
+		return "", "", fmt.Errorf("bind9 is not configured (%s not found)", bindLocalConf)
+	}
+
+	zones := parseBindZones(string(data))
+
+	labels := strings.Split(domain.Normalize(name), ".")
+	for i := 0; i < len(labels)-1; i++ {
+		candidate := strings.Join(labels[i:], ".")
+		if file, ok := zones[candidate]; ok {
+			if file == "" {
+				file = fmt.Sprintf("/var/lib/bind/db.%s", candidate)
+			}
+			if _, err := os.Stat(file); err != nil {
+				return "", "", fmt.Errorf("zone file for %s not found at %s", candidate, file)
+			}
+			return candidate, file, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("no bind9 zone found for %s (configure it with: sudo webstack dns config --zone <zone> --type master)", name)
+}
+
+// parseBindZones returns master zone names mapped to their zone file
+func parseBindZones(conf string) map[string]string {
+	zones := make(map[string]string)
+
+	current := ""
+	isMaster := false
+	file := ""
+	scanner := bufio.NewScanner(strings.NewReader(conf))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if strings.HasPrefix(line, "zone ") {
+			parts := strings.Split(line, "\"")
+			if len(parts) >= 2 {
+				current = domain.Normalize(parts[1])
+				isMaster = false
+				file = ""
+			}
+			continue
+		}
+
+		if current == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "type "):
+			isMaster = strings.Contains(line, "master") || strings.Contains(line, "primary")
+		case strings.HasPrefix(line, "file "):
+			parts := strings.Split(line, "\"")
+			if len(parts) >= 2 {
+				file = parts[1]
+			}
+		case strings.HasPrefix(line, "};"):
+			if isMaster {
+				zones[current] = file
+			}
+			current = ""
+		}
+	}
+
+	return zones
+}
+
+// incrementZoneSerial bumps the SOA serial marked with a "; Serial" comment
+func incrementZoneSerial(zoneContent string) string {
+	lines := strings.Split(zoneContent, "\n")
+
+	for i, line := range lines {
+		if !strings.Contains(line, "; Serial") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		serial, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+
+		// Prefer a date-based serial when it is ahead of the current one
+		next := serial + 1
+		if dateSerial, err := strconv.Atoi(time.Now().Format("20060102") + "01"); err == nil && dateSerial > next {
+			next = dateSerial
+		}
+		lines[i] = strings.Replace(line, fields[0], strconv.Itoa(next), 1)
+		return strings.Join(lines, "\n")
+	}
+
+	return zoneContent
+}
+
+// ensureCertbotPlugin installs a certbot DNS plugin package if missing
+func ensureCertbotPlugin(name string) error {
+	if pkg.Installed(name) {
+		return nil
+	}
+
+	fmt.Printf("📦 Installing %s...\n", name)
+	if err := pkg.Install(name); err != nil {
+		return fmt.Errorf("could not install %s: %v", name, err)
+	}
+	return nil
+}
+
+// ensureCloudflareCredentials makes sure a Cloudflare API token is stored
+// for certbot, taking it from CLOUDFLARE_API_TOKEN or prompting for it
+func ensureCloudflareCredentials() (string, error) {
+	credentials := filepath.Join(dnsCredentialsDir, "cloudflare.ini")
+
+	token := os.Getenv("CLOUDFLARE_API_TOKEN")
+	if token == "" {
+		if _, err := os.Stat(credentials); err == nil {
+			return credentials, nil
+		}
+
+		fmt.Print("Enter Cloudflare API token (Zone:DNS:Edit): ")
+		reader := bufio.NewReader(os.Stdin)
+		response, _ := reader.ReadString('\n')
+		token = strings.TrimSpace(response)
+	}
+
+	if token == "" {
+		return "", fmt.Errorf("a Cloudflare API token is required (set CLOUDFLARE_API_TOKEN)")
+	}
+
+	if err := dryrun.MkdirAll(dnsCredentialsDir, 0700); err != nil {
+		return "", fmt.Errorf("could not create %s: %v", dnsCredentialsDir, err)
+	}
+
+	content := fmt.Sprintf("# Managed by WebStack\ndns_cloudflare_api_token = %s\n", token)
+	if err := dryrun.WriteFile(credentials, []byte(content), 0600); err != nil {
+		return "", fmt.Errorf("could not write Cloudflare credentials: %v", err)
+	}
+
+	return credentials, nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package ssl
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/ui"
+)
+
+// ImportDir holds certificates bought from commercial CAs and imported with
+// 'webstack ssl import', one directory per domain
+const ImportDir = "/etc/webstack/ssl"
+
+// ImportOptions holds the files of 'webstack ssl import'
+type ImportOptions struct {
+	Cert  string // PEM certificate, optionally followed by its chain
+	Key   string // PEM private key matching the certificate
+	Chain string // PEM intermediate certificates, if not part of Cert
+}
+
+// Import installs a certificate issued outside webstack for a domain. The
+// key must match the certificate and the certificate must be valid now and
+// cover the domain. Imported certificates are not renewed automatically.
+func Import(domainName string, opts ImportOptions) {
+	domainName = domain.Normalize(domainName)
+	fmt.Printf("Importing SSL certificate for domain: %s\n", domainName)
+
+	if !domainExists(domainName) {
+		ui.Fail("Domain %s is not configured. Please add the domain first.\n", domainName)
+		return
+	}
+	if opts.Cert == "" || opts.Key == "" {
+		ui.Fail("❌ Both --cert and --key are required\n")
+		return
+	}
+
+	certPEM, err := ioutil.ReadFile(opts.Cert)
+	if err != nil {
+		ui.Fail("❌ Could not read certificate: %v\n", err)
+		return
+	}
+	keyPEM, err := ioutil.ReadFile(opts.Key)
+	if err != nil {
+		ui.Fail("❌ Could not read private key: %v\n", err)
+		return
+	}
+	chainPEM := certPEM
+	if opts.Chain != "" {
+		chain, err := ioutil.ReadFile(opts.Chain)
+		if err != nil {
+			ui.Fail("❌ Could not read chain: %v\n", err)
+			return
+		}
+		chainPEM = append(append(append([]byte(nil), certPEM...), '\n'), chain...)
+	}
+
+	leaf, intermediates, err := validateImport(domainName, chainPEM, keyPEM)
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: domainName, Intermediates: intermediates}); err != nil {
+		ui.Warn("⚠️  Warning: The certificate does not verify against the system CAs: %v\n", err)
+		fmt.Println("   Pass the intermediate certificates of the CA with --chain")
+	}
+	if remaining := time.Until(leaf.NotAfter); remaining < acmeRenewBefore {
+		ui.Warn("⚠️  Warning: The certificate expires in %d days\n", int(remaining.Hours()/24))
+	}
+
+	dir := filepath.Join(ImportDir, domainName)
+	certPath := filepath.Join(dir, "fullchain.pem")
+	keyPath := filepath.Join(dir, "privkey.pem")
+	if err := dryrun.MkdirAll(dir, 0700); err != nil {
+		ui.Fail("❌ Could not create %s: %v\n", dir, err)
+		return
+	}
+	if err := dryrun.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		ui.Fail("❌ Could not write private key: %v\n", err)
+		return
+	}
+	if err := dryrun.WriteFile(certPath, encodeChain(chainPEM), 0644); err != nil {
+		ui.Fail("❌ Could not write certificate: %v\n", err)
+		return
+	}
+
+	cert := SSLCertificate{
+		Domain:    domainName,
+		Enabled:   true,
+		IssuedAt:  leaf.NotBefore,
+		ExpiresAt: leaf.NotAfter,
+		CertPath:  certPath,
+		KeyPath:   keyPath,
+		AltNames:  altNames(leaf, domainName),
+		Client:    "import",
+		Issuer:    issuerName(leaf),
+	}
+	if err := saveSSLCert(cert); err != nil {
+		ui.Fail("Error saving SSL configuration: %v\n", err)
+		return
+	}
+
+	// A previous Let's Encrypt certificate would be renewed over the import
+	if err := removeAutoRenewal(domainName); err != nil {
+		ui.Warn("⚠️  Warning: Could not remove auto-renewal: %v\n", err)
+	}
+
+	if err := enableSSLForDomain(domainName, certPath, keyPath, ""); err != nil {
+		ui.Fail("Error updating domain configuration: %v\n", err)
+		return
+	}
+	if err := generateSSLConfig(domainName); err != nil {
+		ui.Fail("Error generating SSL configuration: %v\n", err)
+		return
+	}
+
+	reloadWebServers()
+	domain.SmokeTest(domainName)
+
+	fmt.Printf("✅ Certificate imported for %s\n", domainName)
+	fmt.Printf("   Issuer: %s\n", issuerName(leaf))
+	fmt.Printf("   Expires: %s\n", leaf.NotAfter.Format("2006-01-02"))
+	fmt.Printf("   Import the renewed certificate before then: webstack ssl import %s --cert ... --key ...\n", domainName)
+}
+
+// validateImport checks that the key matches the first certificate of the
+// chain, that the certificate is currently valid and that it covers the
+// domain. It returns the certificate and the rest of the chain.
+func validateImport(domainName string, chainPEM, keyPEM []byte) (*x509.Certificate, *x509.CertPool, error) {
+	pair, err := tls.X509KeyPair(chainPEM, keyPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("certificate and key do not match: %v", err)
+	}
+
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse certificate: %v", err)
+	}
+	now := time.Now()
+	if now.After(leaf.NotAfter) {
+		return nil, nil, fmt.Errorf("certificate expired on %s", leaf.NotAfter.Format("2006-01-02"))
+	}
+	if now.Before(leaf.NotBefore) {
+		return nil, nil, fmt.Errorf("certificate is not valid before %s", leaf.NotBefore.Format("2006-01-02"))
+	}
+	if err := leaf.VerifyHostname(domainName); err != nil {
+		return nil, nil, fmt.Errorf("certificate does not cover %s (names: %s)", domainName, strings.Join(leaf.DNSNames, ", "))
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, der := range pair.Certificate[1:] {
+		if cert, err := x509.ParseCertificate(der); err == nil {
+			intermediates.AddCert(cert)
+		}
+	}
+	return leaf, intermediates, nil
+}
+
+// encodeChain re-encodes the certificates of a PEM bundle, dropping any
+// other blocks and text between them
+func encodeChain(data []byte) []byte {
+	var out []byte
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return out
+		}
+		if block.Type == "CERTIFICATE" {
+			out = append(out, pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: block.Bytes})...)
+		}
+	}
+}
+
+// altNames returns the names a certificate covers besides the domain
+func altNames(cert *x509.Certificate, domainName string) []string {
+	var names []string
+	for _, name := range cert.DNSNames {
+		if name != domainName {
+			names = append(names, name)
+		}
+	}
+	return names
+}
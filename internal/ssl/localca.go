@@ -0,0 +1,245 @@
+package ssl
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/ui"
+)
+
+// localCADir holds the local development CA that signs the self-signed
+// certificates of the domains, so trusting its root once is enough
+const localCADir = "/etc/ssl/webstack/ca"
+
+var (
+	localCACert = filepath.Join(localCADir, "webstack-ca.crt")
+	localCAKey  = filepath.Join(localCADir, "webstack-ca.key")
+)
+
+// localCAClient marks ssl.json entries issued by the local CA
+const localCAClient = "local-ca"
+
+// localCertValidity stays below the 825 days macOS and iOS accept for
+// certificates of locally trusted roots
+const localCertValidity = 825 * 24 * time.Hour
+
+// loadLocalCA reads the local CA certificate and key
+func loadLocalCA() (*x509.Certificate, crypto.Signer, error) {
+	cert, err := readCertificate(localCACert)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := ioutil.ReadFile(localCAKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, fmt.Errorf("%s is not a PEM key", localCAKey)
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse %s: %v", localCAKey, err)
+	}
+	return cert, key, nil
+}
+
+// ensureLocalCA returns the local CA, creating it on first use. The CA is
+// valid for 10 years and may only sign server certificates.
+func ensureLocalCA() (*x509.Certificate, crypto.Signer, error) {
+	if _, err := os.Stat(localCACert); err == nil {
+		return loadLocalCA()
+	}
+
+	fmt.Println("🔐 Creating the WebStack local CA...")
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not generate the CA key: %v", err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "localhost"
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "WebStack Local CA (" + hostname + ")", Organization: []string{"WebStack CLI"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create the CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := dryrun.MkdirAll(localCADir, 0755); err != nil {
+		return nil, nil, fmt.Errorf("could not create %s: %v", localCADir, err)
+	}
+	if err := writeKey(localCAKey, key); err != nil {
+		return nil, nil, err
+	}
+	if err := dryrun.WriteFile(localCACert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return nil, nil, fmt.Errorf("could not write %s: %v", localCACert, err)
+	}
+	fmt.Printf("✅ Local CA created: %s\n", localCACert)
+	fmt.Println("   Trust it once with: sudo webstack ssl trust-ca")
+	return cert, key, nil
+}
+
+// issueLocalCert writes a certificate for a domain and its www name signed
+// by the local CA
+func issueLocalCert(domainName, certPath, keyPath string) error {
+	caCert, caKey, err := ensureLocalCA()
+	if err != nil {
+		return err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("could not generate the key: %v", err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return err
+	}
+
+	apex := strings.TrimPrefix(domainName, "www.")
+	names := []string{domainName}
+	if domainName != apex {
+		names = append(names, apex)
+	} else if domainName != "localhost" {
+		names = append(names, "www."+apex)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: domainName, Organization: []string{"WebStack CLI"}},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(localCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if domainName == "localhost" {
+		template.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return fmt.Errorf("could not sign the certificate: %v", err)
+	}
+
+	if err := writeKey(keyPath, key); err != nil {
+		return err
+	}
+	if err := dryrun.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", certPath, err)
+	}
+	return nil
+}
+
+// issuedByLocalCA reports whether a certificate file was signed by the
+// current local CA
+func issuedByLocalCA(certPath string) bool {
+	cert, err := readCertificate(certPath)
+	if err != nil {
+		return false
+	}
+	caCert, err := readCertificate(localCACert)
+	if err != nil {
+		return false
+	}
+	return cert.CheckSignatureFrom(caCert) == nil
+}
+
+// renewLocalCert issues a new local CA certificate for an ssl.json entry
+// and records its validity
+func renewLocalCert(cert *SSLCertificate) error {
+	if err := issueLocalCert(cert.Domain, cert.CertPath, cert.KeyPath); err != nil {
+		return err
+	}
+	refreshCertificate(cert)
+	return saveSSLCert(*cert)
+}
+
+func writeKey(path string, key *ecdsa.PrivateKey) error {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := dryrun.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return fmt.Errorf("could not write %s: %v", path, err)
+	}
+	return nil
+}
+
+func randomSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("could not generate a serial number: %v", err)
+	}
+	return serial, nil
+}
+
+// TrustCA prints the local CA root and how to trust it in the operating
+// system and browsers, creating the CA if no certificate was issued yet
+func TrustCA() {
+	caCert, _, err := ensureLocalCA()
+	if err != nil {
+		ui.Fail("❌ Could not load the local CA: %v\n", err)
+		return
+	}
+	fingerprint := sha256.Sum256(caCert.Raw)
+	hexParts := make([]string, len(fingerprint))
+	for i, b := range fingerprint {
+		hexParts[i] = fmt.Sprintf("%02X", b)
+	}
+
+	fmt.Println("WebStack local CA")
+	fmt.Println("===================")
+	fmt.Printf("  Certificate: %s\n", localCACert)
+	fmt.Printf("  Subject:     %s\n", caCert.Subject.CommonName)
+	fmt.Printf("  Expires:     %s\n", caCert.NotAfter.Format("2006-01-02"))
+	fmt.Printf("  SHA-256:     %s\n", strings.Join(hexParts, ":"))
+	fmt.Println()
+	fmt.Println("Copy the certificate to the machine running the browser, e.g.:")
+	fmt.Printf("  scp root@server:%s .\n", localCACert)
+	fmt.Println()
+	fmt.Println("Then trust it once:")
+	fmt.Println("  Debian/Ubuntu: sudo cp webstack-ca.crt /usr/local/share/ca-certificates/ && sudo update-ca-certificates")
+	fmt.Println("  Fedora/RHEL:   sudo cp webstack-ca.crt /etc/pki/ca-trust/source/anchors/ && sudo update-ca-trust")
+	fmt.Println("  macOS:         sudo security add-trusted-cert -d -r trustRoot -k /Library/Keychains/System.keychain webstack-ca.crt")
+	fmt.Println("  Windows:       certutil -addstore -f ROOT webstack-ca.crt   (as Administrator)")
+	fmt.Println("  Firefox:       Settings → Privacy & Security → Certificates → View Certificates → Authorities → Import")
+	fmt.Println()
+	fmt.Println("Compare the SHA-256 fingerprint before trusting the copy. Keep the key")
+	fmt.Printf("(%s) private: anyone holding it can issue certificates your browser trusts.\n", localCAKey)
+	fmt.Println()
+	fmt.Println("PEM:")
+	fmt.Print(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw})))
+}
@@ -0,0 +1,117 @@
+package ssl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/ui"
+)
+
+// MailCertificate returns the certificate and key for the mail host name
+// served by Postfix and Dovecot. An enabled certificate of the name is
+// reused; otherwise one is issued with the built-in ACME client, or by the
+// local CA for local names, and renewed like the web certificates. The
+// entry is marked so renewals reload Postfix and Dovecot.
+func MailCertificate(host, email string) (string, string, error) {
+	host = domain.Normalize(host)
+	if host == "" || !strings.Contains(host, ".") && host != "localhost" {
+		return "", "", fmt.Errorf("%q is not a host name like mail.example.com", host)
+	}
+
+	certs, err := loadSSLCerts()
+	if err != nil {
+		return "", "", fmt.Errorf("could not load SSL certificates: %v", err)
+	}
+	for _, cert := range certs {
+		if cert.Domain != host || !cert.Enabled {
+			continue
+		}
+		if _, err := os.Stat(cert.CertPath); err != nil {
+			break
+		}
+		fmt.Printf("✅ Using the existing certificate of %s (expires %s)\n", host, cert.ExpiresAt.Format("2006-01-02"))
+		if !cert.Mail {
+			cert.Mail = true
+			if err := saveSSLCert(cert); err != nil {
+				return "", "", fmt.Errorf("could not update %s: %v", sslConfigFile, err)
+			}
+		}
+		return cert.CertPath, cert.KeyPath, nil
+	}
+
+	isLocal := strings.HasSuffix(host, ".local") || strings.HasSuffix(host, ".test") || host == "localhost"
+	if isLocal {
+		certPath := filepath.Join("/etc/ssl/webstack", host+".crt")
+		keyPath := filepath.Join("/etc/ssl/webstack", host+".key")
+		fmt.Println("🔑 Generating certificate signed by the WebStack local CA...")
+		if err := issueLocalCert(host, certPath, keyPath); err != nil {
+			return "", "", fmt.Errorf("could not generate self-signed certificate: %v", err)
+		}
+		cert := SSLCertificate{
+			Domain:    host,
+			Email:     "self-signed@localhost",
+			Enabled:   true,
+			IssuedAt:  time.Now(),
+			ExpiresAt: time.Now().Add(localCertValidity),
+			CertPath:  certPath,
+			KeyPath:   keyPath,
+			Client:    localCAClient,
+			Mail:      true,
+		}
+		refreshCertificate(&cert)
+		if err := saveSSLCert(cert); err != nil {
+			return "", "", fmt.Errorf("error saving SSL configuration: %v", err)
+		}
+		fmt.Println("ℹ️  Mail clients trust it once the local CA is trusted: sudo webstack ssl trust-ca")
+		return certPath, keyPath, nil
+	}
+
+	if email == "" {
+		email = defaultEmail()
+	}
+	if email == "" {
+		return "", "", fmt.Errorf("an email address is required for Let's Encrypt (use --email or set %s)", DefaultEmailKey)
+	}
+
+	fmt.Printf("🔍 Validating %s for Let's Encrypt...\n", host)
+	if err := validateDomainForLetsEncrypt(host); err != nil {
+		return "", "", err
+	}
+
+	// HTTP-01 is answered by the default server, so the mail host needs no
+	// web domain of its own, only an A record pointing to this server
+	opts := LetsEncryptOptions{Challenge: "http", Client: "builtin"}
+	if err := opts.normalize(host); err != nil {
+		return "", "", err
+	}
+	fmt.Printf("📜 Requesting a Let's Encrypt certificate for %s...\n", host)
+	certPath, keyPath, expiresAt, err := obtainCertificateACME(host, email, opts)
+	if err != nil {
+		return "", "", fmt.Errorf("could not obtain certificate: %v", err)
+	}
+
+	cert := SSLCertificate{
+		Domain:    host,
+		Email:     email,
+		Enabled:   true,
+		IssuedAt:  time.Now(),
+		ExpiresAt: expiresAt,
+		CertPath:  certPath,
+		KeyPath:   keyPath,
+		Challenge: opts.Challenge,
+		Client:    opts.Client,
+		Mail:      true,
+	}
+	refreshCertificate(&cert)
+	if err := saveSSLCert(cert); err != nil {
+		return "", "", fmt.Errorf("error saving SSL configuration: %v", err)
+	}
+
+	if err := setupAutoRenewal(host, email, opts.Client); err != nil {
+		ui.Warn("⚠️  Warning: Could not setup auto-renewal: %v\n", err)
+	}
+	return certPath, keyPath, nil
+}
@@ -2,34 +2,53 @@ package ssl
 
 import (
 	"bufio"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"text/template"
 	"time"
+	"webstack-cli/internal/config"
 	"webstack-cli/internal/cron"
 	"webstack-cli/internal/domain"
-	"webstack-cli/internal/templates"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/notify"
+	"webstack-cli/internal/pkg"
+	"webstack-cli/internal/store"
+	"webstack-cli/internal/ui"
 )
 
 // SSLCertificate represents an SSL certificate
 type SSLCertificate struct {
-	Domain    string    `json:"domain"`
-	Email     string    `json:"email"`
-	Enabled   bool      `json:"enabled"`
-	IssuedAt  time.Time `json:"issued_at"`
-	ExpiresAt time.Time `json:"expires_at"`
-	CertPath  string    `json:"cert_path"`
-	KeyPath   string    `json:"key_path"`
+	Domain      string    `json:"domain"`
+	Email       string    `json:"email"`
+	Enabled     bool      `json:"enabled"`
+	IssuedAt    time.Time `json:"issued_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	CertPath    string    `json:"cert_path"`
+	KeyPath     string    `json:"key_path"`
+	Challenge   string    `json:"challenge,omitempty"`
+	AltNames    []string  `json:"alt_names,omitempty"`
+	DNSProvider string    `json:"dns_provider,omitempty"`
+	Client      string    `json:"client,omitempty"` // "builtin", "certbot", "import" or "local-ca" (empty: issued by certbot)
+	Issuer      string    `json:"issuer,omitempty"` // Read from the certificate file
+	Mail        bool      `json:"mail,omitempty"`   // Also served by Postfix and Dovecot, reloaded after renewals
 }
 
 const sslConfigFile = "/etc/webstack/ssl.json"
 
+// sslStore locks ssl.json and writes it atomically
+var sslStore = store.New(sslConfigFile, 1)
+
+// DefaultEmailKey is the setting used for Let's Encrypt when no email is given
+const DefaultEmailKey = "defaults.ssl_email"
+
+// deployHook reloads the servers using a certificate after certbot renewed
+// it; Postfix and Dovecot only read it when they (re)start
+const deployHook = "systemctl reload nginx || true; systemctl reload apache2 || true; " +
+	"systemctl reload postfix || true; systemctl reload dovecot || true"
+
 // Enable creates and enables SSL certificate for a domain (interactive mode)
 func Enable(domainName, email string) {
 	EnableWithType(domainName, email, "")
@@ -38,11 +57,18 @@ func Enable(domainName, email string) {
 // EnableWithType creates and enables SSL certificate for a domain with specified type
 // certType can be "selfsigned", "letsencrypt", or empty string for interactive mode
 func EnableWithType(domainName, email, certType string) {
+	EnableWithOptions(domainName, email, certType, LetsEncryptOptions{})
+}
+
+// EnableWithOptions creates and enables SSL certificate for a domain. opts control the
+// Let's Encrypt challenge (HTTP-01 or DNS-01), wildcard and additional SAN names.
+func EnableWithOptions(domainName, email, certType string, opts LetsEncryptOptions) {
+	domainName = domain.Normalize(domainName)
 	fmt.Printf("Enabling SSL for domain: %s\n", domainName)
 
 	// Check if domain exists
 	if !domainExists(domainName) {
-		fmt.Printf("Domain %s is not configured. Please add the domain first.\n", domainName)
+		ui.Fail("Domain %s is not configured. Please add the domain first.\n", domainName)
 		return
 	}
 
@@ -54,18 +80,23 @@ func EnableWithType(domainName, email, certType string) {
 
 	var useSSLType string
 
+	// Wildcard, SAN and challenge options only make sense for Let's Encrypt
+	if certType == "" && (opts.Wildcard || len(opts.AltNames) > 0 || opts.Challenge != "") {
+		certType = "letsencrypt"
+	}
+
 	// If cert type is specified via flag, use it directly
 	if certType == "selfsigned" || certType == "self-signed" {
 		useSSLType = "self-signed"
 	} else if certType == "letsencrypt" || certType == "lets-encrypt" {
 		useSSLType = "letsencrypt"
 	} else if certType != "" {
-		fmt.Printf("❌ Invalid certificate type: %s. Use 'selfsigned' or 'letsencrypt'\n", certType)
+		ui.Invalid("❌ Invalid certificate type: %s. Use 'selfsigned' or 'letsencrypt'\n", certType)
 		return
 	} else {
 		// Interactive mode
 		if isLocalDomain {
-			fmt.Printf("⚠️  %s appears to be a local development domain.\n", domainName)
+			ui.Warn("⚠️  %s appears to be a local development domain.\n", domainName)
 			fmt.Println("\nSSL Certificate Options:")
 			fmt.Println("  [1] Self-signed certificate (recommended for development)")
 			fmt.Println("  [2] Let's Encrypt (requires internet and valid DNS)")
@@ -115,97 +146,139 @@ func EnableWithType(domainName, email, certType string) {
 	// Handle self-signed
 	if useSSLType == "self-signed" {
 		if err := enableSSLWithSelfSigned(domainName); err != nil {
-			fmt.Printf("Error enabling SSL with self-signed certificate: %v\n", err)
+			ui.Fail("Error enabling SSL with self-signed certificate: %v\n", err)
 			return
 		}
 		return
 	}
 
 	// Handle Let's Encrypt
-	// Prompt for email if not provided
+	// Use the configured email or prompt for it if not provided
+	if email == "" {
+		email = defaultEmail()
+	}
 	if email == "" {
 		email = promptEmail()
 	}
 
 	if email == "" {
-		fmt.Println("Email is required for Let's Encrypt registration")
+		ui.Fail("Email is required for Let's Encrypt registration\n")
 		return
 	}
 
-	// Install certbot if not installed
-	if err := ensureCertbotInstalled(); err != nil {
-		fmt.Printf("Error installing certbot: %v\n", err)
+	if err := opts.normalize(domainName); err != nil {
+		ui.Fail("❌ %v\n", err)
 		return
 	}
 
-	// Validate domain before requesting certificate
-	fmt.Println("🔍 Validating domain configuration...")
-	if err := validateDomainForLetsEncrypt(domainName); err != nil {
-		fmt.Printf("❌ Domain validation failed: %v\n", err)
-		fmt.Println("\nPlease ensure:")
-		fmt.Println("  - Domain is publicly resolvable")
-		fmt.Println("  - Server IP matches domain DNS record")
-		fmt.Println("  - Port 80 is accessible from internet")
-		fmt.Println("  - No firewall blocking port 80")
-		return
+	names := opts.Names(domainName)
+	var certPath, keyPath string
+	expiresAt := time.Now().AddDate(0, 3, 0) // 3 months
+
+	if opts.Challenge != "dns" {
+		// Validate domain before requesting certificate
+		fmt.Println("🔍 Validating domain configuration...")
+		for _, name := range names {
+			if err := validateDomainForLetsEncrypt(name); err != nil {
+				ui.Fail("❌ Domain validation failed: %v\n", err)
+				fmt.Println("\nPlease ensure:")
+				fmt.Println("  - Domain is publicly resolvable")
+				fmt.Println("  - Server IP matches domain DNS record")
+				fmt.Println("  - Port 80 is accessible from internet")
+				fmt.Println("  - No firewall blocking port 80")
+				fmt.Println("  - Or use the DNS-01 challenge: --challenge dns")
+				return
+			}
+		}
+		fmt.Println("✅ Domain validation passed")
 	}
-	fmt.Println("✅ Domain validation passed")
 
-	// Stop web servers temporarily for standalone mode
-	fmt.Println("⚙️  Temporarily stopping web servers...")
-	stopWebServers()
+	if opts.Client == "builtin" {
+		// Built-in ACME client: HTTP-01 via the shared web root, web servers keep running
+		fmt.Printf("🔒 Requesting SSL certificate via %s-01 for: %s\n", opts.Challenge, strings.Join(names, ", "))
+		var err error
+		certPath, keyPath, expiresAt, err = obtainCertificateACME(domainName, email, opts)
+		if err != nil {
+			ui.Fail("Error requesting certificate: %v\n", err)
+			fmt.Println("   To retry with certbot instead: --acme-client certbot")
+			return
+		}
+	} else {
+		// Install certbot if not installed
+		if err := ensureCertbotInstalled(); err != nil {
+			ui.Fail("Error installing certbot: %v\n", err)
+			return
+		}
 
-	// Request certificate
-	fmt.Println("🔒 Requesting SSL certificate...")
-	certPath, keyPath, err := requestCertificate(domainName, email)
-	if err != nil {
-		fmt.Printf("Error requesting certificate: %v\n", err)
-		startWebServers()
-		return
+		if opts.Challenge == "dns" {
+			// DNS-01 does not need port 80, so web servers keep running
+			fmt.Printf("🔒 Requesting SSL certificate via DNS-01 (%s) for: %s\n", opts.DNSProvider, strings.Join(names, ", "))
+			var err error
+			certPath, keyPath, err = requestCertificateDNS(domainName, email, opts)
+			if err != nil {
+				ui.Fail("Error requesting certificate: %v\n", err)
+				return
+			}
+		} else {
+			// HTTP-01 via the shared web root, web servers keep running
+			fmt.Println("🔒 Requesting SSL certificate...")
+			var err error
+			certPath, keyPath, err = requestCertificate(domainName, email, names)
+			if err != nil {
+				ui.Fail("Error requesting certificate: %v\n", err)
+				return
+			}
+		}
 	}
 
-	// Start web servers again
-	startWebServers()
-
 	// Save SSL configuration
 	cert := SSLCertificate{
-		Domain:    domainName,
-		Email:     email,
-		Enabled:   true,
-		IssuedAt:  time.Now(),
-		ExpiresAt: time.Now().AddDate(0, 3, 0), // 3 months
-		CertPath:  certPath,
-		KeyPath:   keyPath,
-	}
+		Domain:      domainName,
+		Email:       email,
+		Enabled:     true,
+		IssuedAt:    time.Now(),
+		ExpiresAt:   expiresAt,
+		CertPath:    certPath,
+		KeyPath:     keyPath,
+		Challenge:   opts.Challenge,
+		AltNames:    names[1:],
+		DNSProvider: opts.DNSProvider,
+		Client:      opts.Client,
+	}
+	refreshCertificate(&cert)
 
 	if err := saveSSLCert(cert); err != nil {
-		fmt.Printf("Error saving SSL configuration: %v\n", err)
+		ui.Fail("Error saving SSL configuration: %v\n", err)
 		return
 	}
 
 	// Update domain configuration to use SSL
 	if err := enableSSLForDomain(domainName, certPath, keyPath, email); err != nil {
-		fmt.Printf("Error updating domain configuration: %v\n", err)
+		ui.Fail("Error updating domain configuration: %v\n", err)
 		return
 	}
 
 	// Generate SSL-enabled configuration
 	if err := generateSSLConfig(domainName); err != nil {
-		fmt.Printf("Error generating SSL configuration: %v\n", err)
+		ui.Fail("Error generating SSL configuration: %v\n", err)
 		return
 	}
 
 	// Reload web servers
 	reloadWebServers()
+	domain.SmokeTest(domainName)
 
 	fmt.Printf("✅ SSL enabled successfully for %s\n", domainName)
 	fmt.Printf("   Certificate: %s\n", certPath)
 	fmt.Printf("   Private Key: %s\n", keyPath)
+	if len(names) > 1 {
+		fmt.Printf("   Names: %s\n", strings.Join(names, ", "))
+	}
 
 	// Setup auto-renewal for Let's Encrypt certificates
 	if useSSLType == "letsencrypt" {
-		if err := setupAutoRenewal(domainName, email); err != nil {
-			fmt.Printf("⚠️  Warning: Could not setup auto-renewal: %v\n", err)
+		if err := setupAutoRenewal(domainName, email, opts.Client); err != nil {
+			ui.Warn("⚠️  Warning: Could not setup auto-renewal: %v\n", err)
 			fmt.Println("   You can manually renew with: webstack-cli ssl renew " + domainName)
 		} else {
 			fmt.Println("✅ Auto-renewal configured (renewal attempted 30 days before expiry)")
@@ -215,11 +288,12 @@ func EnableWithType(domainName, email, certType string) {
 
 // Disable removes SSL certificate for a domain
 func Disable(domainName string) {
+	domainName = domain.Normalize(domainName)
 	fmt.Printf("Disabling SSL for domain: %s\n", domainName)
 
 	certs, err := loadSSLCerts()
 	if err != nil {
-		fmt.Printf("Error loading SSL certificates: %v\n", err)
+		ui.Fail("Error loading SSL certificates: %v\n", err)
 		return
 	}
 
@@ -231,28 +305,29 @@ func Disable(domainName string) {
 
 			// Save updated configuration
 			if err := saveSSLCerts(certs); err != nil {
-				fmt.Printf("Error saving SSL configuration: %v\n", err)
+				ui.Fail("Error saving SSL configuration: %v\n", err)
 				return
 			}
 
 			// Remove auto-renewal cronjob
 			if err := removeAutoRenewal(domainName); err != nil {
-				fmt.Printf("⚠️  Warning: Could not remove auto-renewal: %v\n", err)
+				ui.Warn("⚠️  Warning: Could not remove auto-renewal: %v\n", err)
 			}
 
 			// Update domain configuration to disable SSL
 			if err := disableSSLForDomain(domainName); err != nil {
-				fmt.Printf("Error updating domain configuration: %v\n", err)
+				ui.Fail("Error updating domain configuration: %v\n", err)
 				return
 			}
 
 			// Generate non-SSL configuration
 			if err := generateNonSSLConfig(domainName); err != nil {
-				fmt.Printf("Error generating configuration: %v\n", err)
+				ui.Fail("Error generating configuration: %v\n", err)
 				return
 			}
 
 			reloadWebServers()
+			domain.SmokeTest(domainName)
 
 			fmt.Printf("✅ SSL disabled for %s\n", domainName)
 			fmt.Println("Note: Certificate files are preserved for future use")
@@ -261,18 +336,19 @@ func Disable(domainName string) {
 	}
 
 	if !found {
-		fmt.Printf("No SSL certificate found for domain %s\n", domainName)
+		ui.Fail("No SSL certificate found for domain %s\n", domainName)
 	}
 }
 
 // Renew renews SSL certificate for a specific domain
 func Renew(domainName string) {
+	domainName = domain.Normalize(domainName)
 	fmt.Printf("Renewing SSL certificate for: %s\n", domainName)
 
 	// Load certificate info
 	certs, err := loadSSLCerts()
 	if err != nil {
-		fmt.Printf("Error loading SSL certificates: %v\n", err)
+		ui.Fail("Error loading SSL certificates: %v\n", err)
 		return
 	}
 
@@ -285,31 +361,92 @@ func Renew(domainName string) {
 	}
 
 	if cert == nil {
-		fmt.Printf("No SSL certificate found for domain %s\n", domainName)
+		ui.Fail("No SSL certificate found for domain %s\n", domainName)
 		return
 	}
+	refreshCertificates(certs)
 
 	// Check days until expiry
 	daysUntilExpiry := int(time.Until(cert.ExpiresAt).Hours() / 24)
 	fmt.Printf("Current certificate expires in %d days\n", daysUntilExpiry)
 
-	// Run certbot renew
-	if err := runCommand("certbot", "renew", "--cert-name", domainName, "--force-renewal"); err != nil {
-		fmt.Printf("❌ Error renewing certificate: %v\n", err)
+	if cert.Client == "import" {
+		fmt.Printf("ℹ️  The certificate of %s was imported and cannot be renewed by webstack\n", domainName)
+		fmt.Printf("   Import the renewed certificate with: webstack ssl import %s --cert ... --key ...\n", domainName)
+		return
+	}
+
+	if cert.Client == localCAClient {
+		if err := renewLocalCert(cert); err != nil {
+			ui.Fail("❌ Error renewing certificate: %v\n", err)
+			return
+		}
+
+		reloadWebServers()
+		domain.SmokeTest(domainName)
+		if cert.Mail {
+			reloadMailServers()
+		}
+
+		fmt.Printf("✅ Local CA certificate reissued for %s\n", domainName)
+		fmt.Printf("   Expires: %s\n", cert.ExpiresAt.Format("2006-01-02 15:04:05"))
+		return
+	}
+
+	if cert.Client == "builtin" {
+		if err := renewCertificateACME(cert); err != nil {
+			ui.Fail("❌ Error renewing certificate: %v\n", err)
+			notifyRenewal(domainName, time.Time{}, err)
+			return
+		}
+
+		reloadWebServers()
+		domain.SmokeTest(domainName)
+		if cert.Mail {
+			reloadMailServers()
+		}
+
+		fmt.Printf("✅ SSL certificate renewed for %s\n", domainName)
+		fmt.Printf("   Expires: %s\n", cert.ExpiresAt.Format("2006-01-02 15:04:05"))
+		notifyRenewal(domainName, cert.ExpiresAt, nil)
+		return
+	}
+
+	// Run certbot renew; HTTP-01 certificates switch to the shared web root
+	args := []string{"renew", "--cert-name", domainName, "--force-renewal"}
+	if cert.Challenge != "dns" {
+		challengeArgs, err := certbotChallengeArgs(domainName)
+		if err != nil {
+			ui.Fail("❌ Error renewing certificate: %v\n", err)
+			notifyRenewal(domainName, time.Time{}, err)
+			return
+		}
+		args = append(args, challengeArgs...)
+	}
+	if err := runCommand("certbot", args...); err != nil {
+		ui.Fail("❌ Error renewing certificate: %v\n", err)
+		notifyRenewal(domainName, time.Time{}, err)
 		return
 	}
 
 	// Reload web servers
 	reloadWebServers()
+	domain.SmokeTest(domainName)
+	if cert.Mail {
+		reloadMailServers()
+	}
 
-	// Verify renewal succeeded
-	certFile := fmt.Sprintf("/etc/letsencrypt/live/%s/fullchain.pem", domainName)
-	if data, err := os.Stat(certFile); err == nil {
+	// Verify renewal succeeded and record the new validity
+	if parsed, _ := refreshCertificate(cert); parsed != nil {
+		if err := saveSSLCerts(certs); err != nil {
+			ui.Warn("⚠️  Warning: Could not update %s: %v\n", sslConfigFile, err)
+		}
 		fmt.Printf("✅ SSL certificate renewed for %s\n", domainName)
-		fmt.Printf("   Modified: %s\n", data.ModTime().Format("2006-01-02 15:04:05"))
+		fmt.Printf("   Expires: %s\n", parsed.NotAfter.Format("2006-01-02 15:04:05"))
 		fmt.Println("   Web servers reloaded successfully")
+		notifyRenewal(domainName, parsed.NotAfter, nil)
 	} else {
-		fmt.Printf("⚠️  Warning: Could not verify certificate update\n")
+		ui.Warn("⚠️  Warning: Could not verify certificate update\n")
 	}
 }
 
@@ -319,7 +456,7 @@ func RenewAll() {
 
 	certs, err := loadSSLCerts()
 	if err != nil {
-		fmt.Printf("Error loading SSL certificates: %v\n", err)
+		ui.Fail("Error loading SSL certificates: %v\n", err)
 		return
 	}
 
@@ -327,6 +464,7 @@ func RenewAll() {
 		fmt.Println("No SSL certificates configured")
 		return
 	}
+	refreshCertificates(certs)
 
 	// Show summary before renewal
 	fmt.Println("\nCertificates to renew:")
@@ -338,30 +476,157 @@ func RenewAll() {
 		fmt.Printf("  • %s (expires in %d days)\n", cert.Domain, daysUntilExpiry)
 	}
 
+	// The built-in client renews certificates expiring within 30 days
+	failed := false
+	usesCertbot := false
+	for i := range certs {
+		if !certs[i].Enabled {
+			continue
+		}
+		if certs[i].Client == "import" {
+			continue
+		}
+		if certs[i].Client == localCAClient {
+			if isACMECertDue(certs[i]) {
+				fmt.Printf("🔒 Reissuing %s from the local CA...\n", certs[i].Domain)
+				if err := renewLocalCert(&certs[i]); err != nil {
+					ui.Fail("❌ Error renewing %s: %v\n", certs[i].Domain, err)
+					failed = true
+				}
+			}
+			continue
+		}
+		if certs[i].Client != "builtin" {
+			usesCertbot = true
+			continue
+		}
+		if !isACMECertDue(certs[i]) {
+			continue
+		}
+		fmt.Printf("🔒 Renewing %s...\n", certs[i].Domain)
+		err := renewCertificateACME(&certs[i])
+		if err != nil {
+			ui.Fail("❌ Error renewing %s: %v\n", certs[i].Domain, err)
+			failed = true
+		}
+		notifyRenewal(certs[i].Domain, certs[i].ExpiresAt, err)
+	}
+
 	// Run certbot renew (renews all that need renewal)
-	if err := runCommand("certbot", "renew", "--quiet"); err != nil {
-		fmt.Printf("❌ Error renewing certificates: %v\n", err)
-		return
+	if usesCertbot {
+		if err := runCommand("certbot", "renew", "--quiet"); err != nil {
+			ui.Fail("❌ Error renewing certificates: %v\n", err)
+			notifyRenewal("", time.Time{}, err)
+			return
+		}
+		if renewed, err := loadSSLCerts(); err == nil {
+			refreshCertificates(renewed)
+		}
 	}
 
 	reloadWebServers()
+	for _, cert := range certs {
+		if cert.Enabled && cert.Mail {
+			reloadMailServers()
+			break
+		}
+	}
+	if failed {
+		ui.Warn("⚠️  Some certificates could not be renewed\n")
+		return
+	}
 	fmt.Println("✅ All SSL certificates processed (only those expiring soon were renewed)")
 	fmt.Println("   Web servers reloaded successfully")
 }
 
+// RenewIfDue renews a certificate issued by the built-in client when it
+// expires within 30 days. It is run daily by the renewal cron job.
+func RenewIfDue(domainName string) {
+	domainName = domain.Normalize(domainName)
+	certs, err := loadSSLCerts()
+	if err != nil {
+		ui.Fail("Error loading SSL certificates: %v\n", err)
+		return
+	}
+	refreshCertificates(certs)
+
+	for i := range certs {
+		if certs[i].Domain != domainName {
+			continue
+		}
+		if certs[i].Client == "import" {
+			fmt.Printf("ℹ️  The certificate of %s was imported and is not renewed automatically\n", domainName)
+			return
+		}
+		if certs[i].Client == localCAClient {
+			if isACMECertDue(certs[i]) {
+				Renew(domainName)
+			}
+			return
+		}
+		if certs[i].Client != "builtin" {
+			// certbot only renews certificates that are due
+			args := []string{"renew", "--cert-name", domainName, "--quiet"}
+			if certs[i].Challenge != "dns" {
+				challengeArgs, err := certbotChallengeArgs(domainName)
+				if err != nil {
+					ui.Fail("❌ Error renewing certificate: %v\n", err)
+					notifyRenewal(domainName, time.Time{}, err)
+					return
+				}
+				args = append(args, challengeArgs...)
+			}
+			if err := runCommand("certbot", args...); err != nil {
+				ui.Fail("❌ Error renewing certificate: %v\n", err)
+				notifyRenewal(domainName, time.Time{}, err)
+			}
+			refreshCertificateEntry(domainName)
+			if certs[i].Mail {
+				reloadMailServers()
+			}
+			return
+		}
+		if !isACMECertDue(certs[i]) {
+			fmt.Printf("ℹ️  Certificate for %s is valid until %s, no renewal needed\n", domainName, certs[i].ExpiresAt.Format("2006-01-02"))
+			return
+		}
+		Renew(domainName)
+		return
+	}
+
+	ui.Fail("No SSL certificate found for domain %s\n", domainName)
+}
+
 // Status shows SSL certificate status for a domain
 func Status(domainName string) {
+	domainName = domain.Normalize(domainName)
 	certs, err := loadSSLCerts()
 	if err != nil {
-		fmt.Printf("Error loading SSL certificates: %v\n", err)
+		ui.Fail("Error loading SSL certificates: %v\n", err)
 		return
 	}
 
-	for _, cert := range certs {
+	parsed := refreshCertificates(certs)
+	for i, cert := range certs {
 		if cert.Domain == domainName {
 			fmt.Printf("SSL Status for %s:\n", domainName)
 			fmt.Printf("  Enabled: %t\n", cert.Enabled)
-			fmt.Printf("  Email: %s\n", cert.Email)
+			if cert.Email != "" {
+				fmt.Printf("  Email: %s\n", cert.Email)
+			}
+			if cert.Challenge != "" {
+				fmt.Printf("  Challenge: %s-01\n", cert.Challenge)
+			}
+			if parsed[i] != nil {
+				fmt.Printf("  Issuer: %s\n", cert.Issuer)
+				fmt.Printf("  Names: %s\n", strings.Join(parsed[i].DNSNames, ", "))
+				fmt.Printf("  Key: %s\n", keyType(parsed[i]))
+			} else {
+				fmt.Printf("  ⚠️  Certificate file %s cannot be read, dates are from %s\n", cert.CertPath, sslConfigFile)
+				if len(cert.AltNames) > 0 {
+					fmt.Printf("  Additional names: %s\n", strings.Join(cert.AltNames, ", "))
+				}
+			}
 			fmt.Printf("  Issued: %s\n", cert.IssuedAt.Format("2006-01-02 15:04:05"))
 			fmt.Printf("  Expires: %s\n", cert.ExpiresAt.Format("2006-01-02 15:04:05"))
 
@@ -375,14 +640,14 @@ func Status(domainName string) {
 		}
 	}
 
-	fmt.Printf("No SSL certificate found for domain %s\n", domainName)
+	ui.Fail("No SSL certificate found for domain %s\n", domainName)
 }
 
 // StatusAll shows SSL certificate status for all domains
 func StatusAll() {
 	certs, err := loadSSLCerts()
 	if err != nil {
-		fmt.Printf("Error loading SSL certificates: %v\n", err)
+		ui.Fail("Error loading SSL certificates: %v\n", err)
 		return
 	}
 
@@ -394,7 +659,8 @@ func StatusAll() {
 	fmt.Println("SSL Certificate Status:")
 	fmt.Println("======================")
 
-	for _, cert := range certs {
+	parsed := refreshCertificates(certs)
+	for i, cert := range certs {
 		status := "Disabled"
 		if cert.Enabled {
 			status = "Enabled"
@@ -404,6 +670,9 @@ func StatusAll() {
 
 		fmt.Printf("Domain: %s\n", cert.Domain)
 		fmt.Printf("  Status: %s\n", status)
+		if parsed[i] != nil {
+			fmt.Printf("  Issuer: %s, %s\n", cert.Issuer, keyType(parsed[i]))
+		}
 		fmt.Printf("  Expires: %s (%d days)\n", cert.ExpiresAt.Format("2006-01-02"), daysUntilExpiry)
 
 		if daysUntilExpiry <= 30 && cert.Enabled {
@@ -426,26 +695,36 @@ func promptEmail() string {
 	return strings.TrimSpace(response)
 }
 
+// defaultEmail returns the defaults.ssl_email setting
+func defaultEmail() string {
+	cfg, err := config.Load()
+	if err != nil || cfg == nil {
+		return ""
+	}
+	email, _ := cfg.GetDefault(DefaultEmailKey, "").(string)
+	return email
+}
+
 func domainExists(domainName string) bool {
 	return domain.DomainExists(domainName)
 }
 
 func ensureCertbotInstalled() error {
 	// Check if certbot is installed
-	if err := runCommand("which", "certbot"); err != nil {
+	if err := exec.Command("which", "certbot").Run(); err != nil {
 		fmt.Println("📦 Installing certbot...")
 
 		// Try apt first (simpler and more reliable)
-		if err := runCommand("apt", "update"); err != nil {
-			return fmt.Errorf("apt update failed: %v", err)
+		if err := pkg.Update(); err != nil {
+			return err
 		}
 
 		// Install certbot and python3-certbot-nginx for Nginx support
-		if err := runCommand("apt", "install", "-y", "certbot", "python3-certbot-nginx"); err != nil {
-			fmt.Printf("⚠️  Warning: apt install failed, trying alternative method: %v\n", err)
+		if err := pkg.Install("certbot", "python3-certbot-nginx"); err != nil {
+			ui.Warn("⚠️  Warning: apt install failed, trying alternative method: %v\n", err)
 
 			// Fallback to snap if apt fails
-			if err := runCommand("apt", "install", "-y", "snapd"); err != nil {
+			if err := pkg.Install("snapd"); err != nil {
 				return fmt.Errorf("could not install snapd: %v", err)
 			}
 
@@ -464,19 +743,25 @@ func ensureCertbotInstalled() error {
 	return nil
 }
 
-func requestCertificate(domainName, email string) (string, string, error) {
-	// Use certbot standalone mode
+func requestCertificate(domainName, email string, names []string) (string, string, error) {
 	args := []string{
 		"certonly",
-		"--standalone",
 		"--non-interactive",
 		"--agree-tos",
 		"--email", email,
-		"-d", domainName,
+		"--cert-name", domainName,
+	}
+	challengeArgs, err := certbotChallengeArgs(domainName)
+	if err != nil {
+		return "", "", err
+	}
+	args = append(args, challengeArgs...)
+	for _, name := range names {
+		args = append(args, "-d", name)
 	}
 
 	if err := runCommand("certbot", args...); err != nil {
-		return "", "", fmt.Errorf("certbot certificate request failed: %v. Make sure port 80 is not in use", err)
+		return "", "", fmt.Errorf("certbot certificate request failed: %v. Make sure port 80 is reachable", err)
 	}
 
 	certPath := fmt.Sprintf("/etc/letsencrypt/live/%s/fullchain.pem", domainName)
@@ -493,71 +778,53 @@ func requestCertificate(domainName, email string) (string, string, error) {
 	return certPath, keyPath, nil
 }
 
-func stopWebServers() {
-	runCommand("systemctl", "stop", "nginx")
-	runCommand("systemctl", "stop", "apache2")
-}
-
-func startWebServers() {
-	runCommand("systemctl", "start", "nginx")
-	runCommand("systemctl", "start", "apache2")
+func reloadWebServers() {
+	domain.ReloadWebServers()
 }
 
-func reloadWebServers() {
-	runCommand("systemctl", "reload", "nginx")
-	runCommand("systemctl", "reload", "apache2")
+// reloadMailServers makes Postfix and Dovecot pick up a renewed certificate
+func reloadMailServers() {
+	for _, service := range []string{"postfix", "dovecot"} {
+		if exec.Command("systemctl", "is-active", "--quiet", service).Run() == nil {
+			dryrun.Run(exec.Command("systemctl", "reload", service))
+		}
+	}
 }
 
 func enableSSLWithSelfSigned(domainName string) error {
 	// Create self-signed certificate directory
 	sslDir := "/etc/ssl/webstack"
-	if err := os.MkdirAll(sslDir, 0755); err != nil {
+	if err := dryrun.MkdirAll(sslDir, 0755); err != nil {
 		return fmt.Errorf("could not create SSL directory: %v", err)
 	}
 
 	certPath := filepath.Join(sslDir, domainName+".crt")
 	keyPath := filepath.Join(sslDir, domainName+".key")
 
-	// Check if certificate already exists
-	if _, err := os.Stat(certPath); err == nil {
-		fmt.Printf("✅ Using existing self-signed certificate for %s\n", domainName)
+	// Reuse a certificate of the local CA; older standalone ones are replaced
+	if issuedByLocalCA(certPath) {
+		fmt.Printf("✅ Using existing local CA certificate for %s\n", domainName)
 		if err := saveAndEnableSSL(domainName, certPath, keyPath); err != nil {
 			return err
 		}
 		return nil
 	}
 
-	// Generate self-signed certificate
-	fmt.Println("🔑 Generating self-signed certificate...")
-	args := []string{
-		"req",
-		"-x509",
-		"-newkey", "rsa:2048",
-		"-keyout", keyPath,
-		"-out", certPath,
-		"-days", "365",
-		"-nodes",
-		"-subj", fmt.Sprintf("/CN=%s", domainName),
-	}
-
-	if err := runCommand("openssl", args...); err != nil {
+	// Sign a certificate with the local CA
+	fmt.Println("🔑 Generating certificate signed by the WebStack local CA...")
+	if err := issueLocalCert(domainName, certPath, keyPath); err != nil {
 		return fmt.Errorf("could not generate self-signed certificate: %v", err)
 	}
 
 	fmt.Printf("✅ Self-signed certificate generated\n")
 
-	// Set proper permissions
-	os.Chmod(keyPath, 0600)
-	os.Chmod(certPath, 0644)
-
 	if err := saveAndEnableSSL(domainName, certPath, keyPath); err != nil {
 		return err
 	}
 
-	fmt.Printf("⚠️  Self-signed certificate warning:\n")
-	fmt.Printf("   This certificate is self-signed and not trusted by browsers.\n")
-	fmt.Printf("   You'll see a security warning when accessing https://%s\n", domainName)
-	fmt.Printf("   This is normal for development. Use it only for testing.\n")
+	fmt.Printf("ℹ️  Browsers trust https://%s once the local CA is trusted:\n", domainName)
+	fmt.Printf("   sudo webstack ssl trust-ca\n")
+	fmt.Printf("   This is meant for development; use Let's Encrypt for public sites.\n")
 
 	return nil
 }
@@ -569,10 +836,12 @@ func saveAndEnableSSL(domainName, certPath, keyPath string) error {
 		Email:     "self-signed@localhost",
 		Enabled:   true,
 		IssuedAt:  time.Now(),
-		ExpiresAt: time.Now().AddDate(1, 0, 0), // 1 year
+		ExpiresAt: time.Now().Add(localCertValidity),
 		CertPath:  certPath,
 		KeyPath:   keyPath,
+		Client:    localCAClient,
 	}
+	refreshCertificate(&cert)
 
 	if err := saveSSLCert(cert); err != nil {
 		return fmt.Errorf("error saving SSL configuration: %v", err)
@@ -590,6 +859,7 @@ func saveAndEnableSSL(domainName, certPath, keyPath string) error {
 
 	// Reload web servers
 	reloadWebServers()
+	domain.SmokeTest(domainName)
 
 	fmt.Printf("✅ SSL enabled successfully for %s\n", domainName)
 	fmt.Printf("   Certificate: %s\n", certPath)
@@ -600,63 +870,102 @@ func saveAndEnableSSL(domainName, certPath, keyPath string) error {
 
 func runCommand(name string, args ...string) error {
 	cmd := exec.Command(name, args...)
-	return cmd.Run()
+	return dryrun.Run(cmd)
+}
+
+// notifyRenewal reports a renewal, or its failure when err is set, to the
+// notification channels. An empty domain stands for all certbot certificates.
+func notifyRenewal(domainName string, expires time.Time, err error) {
+	name := domainName
+	if name == "" {
+		name = "certbot certificates"
+	}
+	if err != nil {
+		notify.Send(notify.SSLFailed, domainName, "Certificate renewal failed for "+name, err.Error())
+		return
+	}
+	notify.Send(notify.SSLRenewed, domainName, "Certificate renewed for "+name, "Valid until "+expires.Format("2006-01-02"))
+}
+
+// Certificates returns the certificates recorded in ssl.json
+func Certificates() ([]SSLCertificate, error) {
+	return loadSSLCerts()
 }
 
 func loadSSLCerts() ([]SSLCertificate, error) {
 	var certs []SSLCertificate
 
-	if _, err := os.Stat(sslConfigFile); os.IsNotExist(err) {
+	if !sslStore.Exists() {
 		// Create directory if it doesn't exist
-		if err := os.MkdirAll(filepath.Dir(sslConfigFile), 0755); err != nil {
+		if err := dryrun.MkdirAll(filepath.Dir(sslConfigFile), 0755); err != nil {
 			return nil, err
 		}
 		return certs, nil
 	}
 
-	data, err := ioutil.ReadFile(sslConfigFile)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := json.Unmarshal(data, &certs); err != nil {
+	if err := sslStore.Load(&certs); err != nil {
 		return nil, err
 	}
 
-	return certs, nil
+	return migrateCertDomains(certs), nil
 }
 
-func saveSSLCert(cert SSLCertificate) error {
-	certs, err := loadSSLCerts()
-	if err != nil {
-		return err
-	}
+// migrateCertDomains normalizes the domain names in ssl.json and drops
+// entries that only differed by case or a trailing dot, keeping the enabled
+// certificate (or the first one)
+func migrateCertDomains(certs []SSLCertificate) []SSLCertificate {
+	var migrated []SSLCertificate
+	index := map[string]int{}
+	changed := false
 
-	// Check if certificate already exists
-	for i, c := range certs {
-		if c.Domain == cert.Domain {
-			certs[i] = cert
-			return saveSSLCerts(certs)
+	for _, cert := range certs {
+		name := domain.Normalize(cert.Domain)
+		if name != cert.Domain {
+			changed = true
+			cert.Domain = name
 		}
-	}
 
-	// Add new certificate
-	certs = append(certs, cert)
-	return saveSSLCerts(certs)
-}
+		i, seen := index[name]
+		if !seen {
+			index[name] = len(migrated)
+			migrated = append(migrated, cert)
+			continue
+		}
 
-func saveSSLCerts(certs []SSLCertificate) error {
-	// Create directory if it doesn't exist
-	if err := os.MkdirAll(filepath.Dir(sslConfigFile), 0755); err != nil {
-		return err
+		changed = true
+		if cert.Enabled && !migrated[i].Enabled {
+			migrated[i] = cert
+		}
+		fmt.Printf("ℹ️  Removed duplicate SSL entry for %s from %s\n", name, sslConfigFile)
 	}
 
-	data, err := json.MarshalIndent(certs, "", "  ")
-	if err != nil {
-		return err
+	if !changed {
+		return certs
+	}
+	if err := saveSSLCerts(migrated); err != nil {
+		ui.Warn("⚠️  Warning: Could not normalize domain names in %s: %v\n", sslConfigFile, err)
 	}
+	return migrated
+}
 
-	return ioutil.WriteFile(sslConfigFile, data, 0644)
+// saveSSLCert adds or replaces the entry of one certificate, holding the
+// ssl.json lock across the read and the write
+func saveSSLCert(cert SSLCertificate) error {
+	var certs []SSLCertificate
+	return sslStore.Update(&certs, func() error {
+		for i, c := range certs {
+			if c.Domain == cert.Domain {
+				certs[i] = cert
+				return nil
+			}
+		}
+		certs = append(certs, cert)
+		return nil
+	})
+}
+
+func saveSSLCerts(certs []SSLCertificate) error {
+	return sslStore.Save(certs)
 }
 
 func enableSSLForDomain(domainName, certPath, keyPath, email string) error {
@@ -704,7 +1013,6 @@ func disableSSLForDomain(domainName string) error {
 }
 
 func generateSSLConfig(domainName string) error {
-	// TODO: Generate SSL-enabled configuration from templates
 	fmt.Printf("⚙️  Generating SSL configuration for %s...\n", domainName)
 
 	// Get the domain
@@ -713,100 +1021,11 @@ func generateSSLConfig(domainName string) error {
 		return fmt.Errorf("could not find domain: %v", err)
 	}
 
-	// Get SSL certificate paths from ssl.json
-	certs, err := loadSSLCerts()
-	if err != nil {
-		return fmt.Errorf("could not load SSL certs: %v", err)
-	}
-
-	var certPath, keyPath string
-	for _, cert := range certs {
-		if cert.Domain == domainName {
-			certPath = cert.CertPath
-			keyPath = cert.KeyPath
-			break
-		}
-	}
-
-	if certPath == "" || keyPath == "" {
-		return fmt.Errorf("SSL certificate not found for domain %s", domainName)
-	}
-
-	// Prepare template variables
-	templateVars := map[string]interface{}{
-		"Domain":       d.Name,
-		"DocumentRoot": d.DocumentRoot,
-		"PHPVersion":   strings.Split(d.PHPVersion, ".")[0] + d.PHPVersion[strings.LastIndex(d.PHPVersion, "."):],
-		"PHPSocket":    fmt.Sprintf("unix:/run/php/php%s-fpm.sock", d.PHPVersion),
-		"SSLCert":      certPath,
-		"SSLKey":       keyPath,
-	}
-
-	if d.Backend == "nginx" {
-		// Generate Nginx SSL config
-		content, err := templates.GetNginxTemplate("domain-ssl.conf")
-		if err != nil {
-			return fmt.Errorf("could not read nginx SSL template: %v", err)
-		}
-
-		tmpl, err := template.New("nginx-ssl").Parse(string(content))
-		if err != nil {
-			return fmt.Errorf("could not parse nginx SSL template: %v", err)
-		}
-
-		// Write config file
-		configFile := filepath.Join("/etc/nginx/sites-available", d.Name+".conf")
-		file, err := os.Create(configFile)
-		if err != nil {
-			return fmt.Errorf("could not create nginx config file: %v", err)
-		}
-		defer file.Close()
-
-		if err := tmpl.Execute(file, templateVars); err != nil {
-			return fmt.Errorf("could not execute nginx SSL template: %v", err)
-		}
-
-		// Update symlink
-		enableLink := filepath.Join("/etc/nginx/sites-enabled", d.Name+".conf")
-		os.Remove(enableLink)
-		if err := os.Symlink(configFile, enableLink); err != nil {
-			return fmt.Errorf("could not create nginx sites-enabled symlink: %v", err)
-		}
-
-		fmt.Printf("✅ Nginx SSL configuration created: %s\n", configFile)
-
-	} else if d.Backend == "apache" {
-		// Generate Nginx proxy SSL config for Apache backend
-		content, err := templates.GetNginxTemplate("proxy-ssl.conf")
-		if err != nil {
-			return fmt.Errorf("could not read nginx proxy SSL template: %v", err)
-		}
-
-		tmpl, err := template.New("nginx-proxy-ssl").Parse(string(content))
-		if err != nil {
-			return fmt.Errorf("could not parse nginx proxy SSL template: %v", err)
-		}
-
-		// Write Nginx config file
-		nginxConfigFile := filepath.Join("/etc/nginx/sites-available", d.Name+".conf")
-		file, err := os.Create(nginxConfigFile)
-		if err != nil {
-			return fmt.Errorf("could not create nginx config file: %v", err)
-		}
-		defer file.Close()
-
-		if err := tmpl.Execute(file, templateVars); err != nil {
-			return fmt.Errorf("could not execute nginx proxy SSL template: %v", err)
-		}
-
-		// Update Nginx symlink
-		enableLink := filepath.Join("/etc/nginx/sites-enabled", d.Name+".conf")
-		os.Remove(enableLink)
-		if err := os.Symlink(nginxConfigFile, enableLink); err != nil {
-			return fmt.Errorf("could not create nginx sites-enabled symlink: %v", err)
-		}
-
-		fmt.Printf("✅ Nginx proxy SSL configuration created: %s\n", nginxConfigFile)
+	// enableSSLForDomain stored the certificate paths on the domain, so the
+	// domain templates render the SSL variant with all per-domain settings
+	// (redirects, presets, document root)
+	if err := domain.GenerateConfig(*d); err != nil {
+		return fmt.Errorf("could not generate config: %v", err)
 	}
 
 	return nil
@@ -860,17 +1079,28 @@ func validateDomainForLetsEncrypt(domainName string) error {
 }
 
 // setupAutoRenewal configures automatic certificate renewal via cronjob
-func setupAutoRenewal(domainName, email string) error {
+func setupAutoRenewal(domainName, email, client string) error {
+	renewCommand := fmt.Sprintf("/usr/bin/certbot renew --cert-name %s --quiet", domainName)
+	if client == "builtin" {
+		binary, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("could not determine webstack binary path: %v", err)
+		}
+		renewCommand = fmt.Sprintf("%s ssl renew %s --if-due", binary, domainName)
+	}
+
 	// Create a renewal script
 	renewScript := fmt.Sprintf(`#!/bin/bash
 # WebStack SSL Certificate Renewal Script for %s
 # Auto-generated renewal script
 
-/usr/bin/certbot renew --cert-name %s --quiet
+%s
 if [ $? -eq 0 ]; then
-    # Reload web servers on successful renewal
+    # Reload web and mail servers on successful renewal
     /usr/bin/systemctl reload nginx 2>/dev/null
     /usr/bin/systemctl reload apache2 2>/dev/null
+    /usr/bin/systemctl reload postfix 2>/dev/null
+    /usr/bin/systemctl reload dovecot 2>/dev/null
     
     # Log successful renewal
     echo "$(date): Certificate renewed successfully for %s" >> /var/log/webstack/ssl-renewal.log
@@ -880,15 +1110,15 @@ else
     # Send email notification (optional)
     echo "Certificate renewal failed for %s. Check /var/log/webstack/ssl-renewal.log" | mail -s "WebStack SSL Renewal Failed" "%s" 2>/dev/null
 fi
-`, domainName, domainName, domainName, domainName, domainName, email)
+`, domainName, renewCommand, domainName, domainName, domainName, email)
 
 	// Create log directory
 	logDir := "/var/log/webstack"
-	os.MkdirAll(logDir, 0755)
+	dryrun.MkdirAll(logDir, 0755)
 
 	// Write renewal script
 	scriptPath := filepath.Join("/usr/local/bin", fmt.Sprintf("webstack-renewal-%s.sh", domainName))
-	if err := ioutil.WriteFile(scriptPath, []byte(renewScript), 0755); err != nil {
+	if err := dryrun.WriteFile(scriptPath, []byte(renewScript), 0755); err != nil {
 		return fmt.Errorf("could not create renewal script: %v", err)
 	}
 
@@ -911,7 +1141,7 @@ fi
 	newCrontab := existingCrons + cronjobEntry + "\n"
 	cmd = exec.Command("crontab", "-")
 	cmd.Stdin = strings.NewReader(newCrontab)
-	if err := cmd.Run(); err != nil {
+	if err := dryrun.Run(cmd); err != nil {
 		return fmt.Errorf("could not add cronjob: %v", err)
 	}
 
@@ -946,12 +1176,12 @@ func removeAutoRenewal(domainName string) error {
 	newCrontab := strings.Join(newLines, "\n")
 	cmd = exec.Command("crontab", "-")
 	cmd.Stdin = strings.NewReader(newCrontab)
-	if err := cmd.Run(); err != nil {
+	if err := dryrun.Run(cmd); err != nil {
 		return fmt.Errorf("could not update cronjob: %v", err)
 	}
 
 	// Remove script file
-	os.Remove(scriptPath)
+	dryrun.Remove(scriptPath)
 
 	return nil
 }
@@ -970,7 +1200,7 @@ func ManageAutorenew(action string) {
 	case "trigger":
 		triggerRenewal()
 	default:
-		fmt.Printf("❌ Unknown action: %s\n", action)
+		ui.Invalid("❌ Unknown action: %s\n", action)
 		fmt.Println("Usage: webstack-cli ssl autorenew [enable|disable|status|trigger]")
 	}
 }
@@ -982,20 +1212,20 @@ func triggerRenewal() {
 
 	// Check if certbot is installed
 	if err := ensureCertbotInstalled(); err != nil {
-		fmt.Printf("Error: certbot not installed: %v\n", err)
+		ui.Fail("Error: certbot not installed: %v\n", err)
 		return
 	}
 
 	// Run certbot renew with verbose output for testing
-	fmt.Println("\n📋 Running: certbot renew --deploy-hook 'systemctl reload nginx || true; systemctl reload apache2 || true'")
+	fmt.Printf("\n📋 Running: certbot renew --deploy-hook '%s'\n", deployHook)
 	fmt.Println("   Note: This will only renew certificates expiring within 30 days")
 
-	cmd := exec.Command("certbot", "renew", "--deploy-hook", "systemctl reload nginx || true; systemctl reload apache2 || true")
+	cmd := exec.Command("certbot", "renew", "--deploy-hook", deployHook)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	if err := cmd.Run(); err != nil {
-		fmt.Printf("\n❌ Renewal trigger failed: %v\n", err)
+	if err := dryrun.Run(cmd); err != nil {
+		ui.Fail("\n❌ Renewal trigger failed: %v\n", err)
 		fmt.Println("\nTo run a dry-run (test without making changes):")
 		fmt.Println("  sudo webstack-cli ssl autorenew trigger --dry-run")
 		return
@@ -1011,7 +1241,7 @@ func enableAutorenew() {
 
 	// Check if certbot is installed
 	if err := ensureCertbotInstalled(); err != nil {
-		fmt.Printf("Error: certbot not installed: %v\n", err)
+		ui.Fail("Error: certbot not installed: %v\n", err)
 		return
 	}
 
@@ -1046,7 +1276,7 @@ func enableAutorenew() {
 		return
 	}
 
-	fmt.Println("❌ Failed to enable automatic renewal")
+	ui.Fail("❌ Failed to enable automatic renewal\n")
 	fmt.Println("   Try enabling systemd timer manually:")
 	fmt.Println("   sudo systemctl enable --now webstack-certbot-renew.timer")
 }
@@ -1071,7 +1301,7 @@ func disableAutorenew() {
 		}
 	}
 
-	fmt.Println("⚠️  No automatic renewal found to disable")
+	ui.Warn("⚠️  No automatic renewal found to disable\n")
 }
 
 // checkAutorenewStatus checks if automatic renewal is enabled
@@ -1082,7 +1312,7 @@ func checkAutorenewStatus() {
 	if isSystemdTimerActive("webstack-certbot-renew.timer") {
 		fmt.Println("\n✅ Status: ENABLED (systemd timer)")
 		fmt.Println("\nSystemd Timer Details:")
-		runCommand("systemctl", "status", "webstack-certbot-renew.timer")
+		exec.Command("systemctl", "status", "webstack-certbot-renew.timer").Run()
 		return
 	}
 
@@ -1090,11 +1320,11 @@ func checkAutorenewStatus() {
 	if isCronJobActive() {
 		fmt.Println("\n✅ Status: ENABLED (cron)")
 		fmt.Println("\nCron Job Details:")
-		runCommand("crontab", "-l")
+		exec.Command("crontab", "-l").Run()
 		return
 	}
 
-	fmt.Println("\n❌ Status: DISABLED")
+	ui.Fail("\n❌ Status: DISABLED\n")
 	fmt.Println("\nTo enable automatic renewal, run:")
 	fmt.Println("  webstack-cli ssl autorenew enable")
 }
@@ -1125,7 +1355,7 @@ After=network.target
 
 [Service]
 Type=oneshot
-ExecStart=/usr/bin/certbot renew --quiet --deploy-hook "systemctl reload nginx || true; systemctl reload apache2 || true"
+ExecStart=/usr/bin/certbot renew --quiet --deploy-hook "` + deployHook + `"
 StandardOutput=journal
 StandardError=journal
 
@@ -1133,7 +1363,7 @@ StandardError=journal
 WantedBy=multi-user.target
 `
 
-	if err := ioutil.WriteFile(serviceFile, []byte(serviceContent), 0644); err != nil {
+	if err := dryrun.WriteFile(serviceFile, []byte(serviceContent), 0644); err != nil {
 		return fmt.Errorf("could not create service file: %v", err)
 	}
 
@@ -1153,7 +1383,7 @@ OnBootSec=5min
 WantedBy=timers.target
 `
 
-	if err := ioutil.WriteFile(timerFile, []byte(timerContent), 0644); err != nil {
+	if err := dryrun.WriteFile(timerFile, []byte(timerContent), 0644); err != nil {
 		return fmt.Errorf("could not create timer file: %v", err)
 	}
 
@@ -1185,8 +1415,8 @@ func disableSystemdTimer() error {
 	}
 
 	// Remove service and timer files
-	os.Remove("/etc/systemd/system/webstack-certbot-renew.service")
-	os.Remove("/etc/systemd/system/webstack-certbot-renew.timer")
+	dryrun.Remove("/etc/systemd/system/webstack-certbot-renew.service")
+	dryrun.Remove("/etc/systemd/system/webstack-certbot-renew.timer")
 
 	// Reload systemd daemon
 	runCommand("systemctl", "daemon-reload")
@@ -1196,7 +1426,7 @@ func disableSystemdTimer() error {
 
 // enableCronJob creates a cron job for automatic renewal
 func enableCronJob() error {
-	cronjob := `0 3,15 * * * /usr/bin/certbot renew --quiet --deploy-hook "systemctl reload nginx || true; systemctl reload apache2 || true"` + "\n"
+	cronjob := `0 3,15 * * * /usr/bin/certbot renew --quiet --deploy-hook "` + deployHook + `"` + "\n"
 
 	// Get current crontab
 	cmd := exec.Command("crontab", "-l")
@@ -1212,7 +1442,7 @@ func enableCronJob() error {
 	cmd = exec.Command("crontab", "-")
 	cmd.Stdin = strings.NewReader(newCrontab)
 
-	if err := cmd.Run(); err != nil {
+	if err := dryrun.Run(cmd); err != nil {
 		return fmt.Errorf("could not update crontab: %v", err)
 	}
 
@@ -1242,7 +1472,7 @@ func disableCronJob() error {
 	cmd = exec.Command("crontab", "-")
 	cmd.Stdin = strings.NewReader(newCrontab)
 
-	if err := cmd.Run(); err != nil {
+	if err := dryrun.Run(cmd); err != nil {
 		return fmt.Errorf("could not update crontab: %v", err)
 	}
 
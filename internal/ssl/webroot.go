@@ -0,0 +1,69 @@
+package ssl
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/osinfo"
+)
+
+// acmeWebroot is the shared HTTP-01 web root. Every generated vhost and the
+// default server serve /.well-known/acme-challenge/ from it, so certificates
+// are issued without stopping the web servers.
+const acmeWebroot = "/var/www/webstack"
+
+// webServerRunning reports whether Nginx or Apache is serving port 80
+func webServerRunning() bool {
+	for _, service := range []string{"nginx", osinfo.Current().Service("apache2")} {
+		if exec.Command("systemctl", "is-active", "--quiet", service).Run() == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// prepareWebroot creates the shared challenge directory and regenerates the
+// vhosts of a domain that were generated before they served it. Names
+// without a domain, like a mail host, are answered by the default server.
+func prepareWebroot(domainName string) error {
+	if err := dryrun.MkdirAll(filepath.Join(acmeWebroot, ".well-known", "acme-challenge"), 0755); err != nil {
+		return fmt.Errorf("could not create challenge directory: %v", err)
+	}
+	if !domainExists(domainName) {
+		return nil
+	}
+
+	d, err := domain.GetDomain(domainName)
+	if err != nil {
+		return err
+	}
+	for _, path := range domain.Vhosts(*d) {
+		data, err := os.ReadFile(path)
+		if err == nil && strings.Contains(string(data), "/.well-known/acme-challenge/") {
+			continue
+		}
+		if err := domain.GenerateConfig(*d); err != nil {
+			return err
+		}
+		reloadWebServers()
+		break
+	}
+	return nil
+}
+
+// certbotChallengeArgs selects certbot's HTTP-01 authenticator for a
+// domain: the shared web root while a web server is running, a standalone
+// listener otherwise
+func certbotChallengeArgs(domainName string) ([]string, error) {
+	if !webServerRunning() {
+		return []string{"--standalone"}, nil
+	}
+	if err := prepareWebroot(domainName); err != nil {
+		return nil, err
+	}
+	return []string{"--webroot", "-w", acmeWebroot}, nil
+}
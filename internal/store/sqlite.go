@@ -0,0 +1,459 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/ui"
+
+	_ "modernc.org/sqlite"
+)
+
+// DatabasePath is the SQLite database holding the state once it has been
+// migrated with 'webstack state migrate'. While it exists every File is
+// stored in it instead of in its JSON file.
+const DatabasePath = "/etc/webstack/webstack.db"
+
+// migratedSuffix is appended to a JSON file once its document was imported
+const migratedSuffix = ".migrated"
+
+var (
+	registryMu sync.Mutex
+	// registry holds the files created with New, which 'webstack state'
+	// migrates and exports
+	registry = map[string]File{}
+
+	dbOnce sync.Once
+	db     *sql.DB
+	dbErr  error
+)
+
+// New returns the File for a JSON state file and registers it, so it is
+// moved into the SQLite database by 'webstack state migrate'
+func New(path string, schema int) File {
+	f := File{Path: path, Schema: schema}
+	registryMu.Lock()
+	registry[f.key()] = f
+	registryMu.Unlock()
+	return f
+}
+
+// key names the document of a file in the database, e.g. domains.json
+func (f File) key() string {
+	return filepath.Base(f.Path)
+}
+
+// sqliteEnabled reports whether the state lives in the SQLite database
+func sqliteEnabled() bool {
+	_, err := os.Stat(DatabasePath)
+	return err == nil
+}
+
+// database opens the SQLite database once per process
+func database() (*sql.DB, error) {
+	dbOnce.Do(func() {
+		db, dbErr = openDatabase(DatabasePath)
+	})
+	return db, dbErr
+}
+
+func openDatabase(path string) (*sql.DB, error) {
+	conn, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(FULL)")
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %v", path, err)
+	}
+	// One connection keeps the pragmas and avoids SQLITE_BUSY between
+	// connections of the same process
+	conn.SetMaxOpenConns(1)
+	_, err = conn.Exec(`CREATE TABLE IF NOT EXISTS documents (
+		name       TEXT PRIMARY KEY,
+		schema     INTEGER NOT NULL,
+		data       TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not prepare %s: %v", path, err)
+	}
+	return conn, nil
+}
+
+// readSQLite reads the document of a file from the database. A document
+// that is not in the database yet is imported from the JSON file once.
+func (f File) readSQLite() (int, json.RawMessage, bool, error) {
+	conn, err := database()
+	if err != nil {
+		return 0, nil, false, err
+	}
+
+	var schema int
+	var data string
+	err = conn.QueryRow(`SELECT schema, data FROM documents WHERE name = ?`, f.key()).Scan(&schema, &data)
+	if err == nil {
+		return schema, json.RawMessage(data), true, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, nil, false, fmt.Errorf("could not read %s from %s: %v", f.key(), DatabasePath, err)
+	}
+
+	schema, document, found, err := f.readJSON()
+	if err != nil || !found {
+		return schema, document, found, err
+	}
+	if err := f.importJSON(conn, schema, document); err != nil {
+		return 0, nil, false, err
+	}
+	return schema, document, true, nil
+}
+
+// importJSON stores a document read from the JSON file and renames the file,
+// so later changes cannot go to the stale copy
+func (f File) importJSON(conn *sql.DB, schema int, document json.RawMessage) error {
+	if dryrun.Enabled() {
+		return nil
+	}
+	// Another invocation may have imported and changed it meanwhile
+	_, err := conn.Exec(`INSERT INTO documents (name, schema, data, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(name) DO NOTHING`, f.key(), schema, string(document), time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("could not store %s in %s: %v", f.key(), DatabasePath, err)
+	}
+	if err := os.Rename(f.Path, f.Path+migratedSuffix); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("imported %s into %s but could not rename it: %v", f.Path, DatabasePath, err)
+	}
+	fmt.Printf("ℹ️  Moved %s into %s\n", f.Path, DatabasePath)
+	return nil
+}
+
+// storedInDatabase reports whether the database holds the document
+func (f File) storedInDatabase() bool {
+	conn, err := database()
+	if err != nil {
+		return false
+	}
+	var one int
+	return conn.QueryRow(`SELECT 1 FROM documents WHERE name = ?`, f.key()).Scan(&one) == nil
+}
+
+func (f File) writeSQLite(document json.RawMessage) error {
+	if dryrun.Enabled() {
+		fmt.Printf("🔎 [dry-run] would store %s in %s (%d bytes)\n", f.key(), DatabasePath, len(document))
+		return nil
+	}
+	conn, err := database()
+	if err != nil {
+		return err
+	}
+	return upsert(conn, f.key(), f.Schema, document)
+}
+
+func upsert(conn *sql.DB, name string, schema int, document json.RawMessage) error {
+	_, err := conn.Exec(`INSERT INTO documents (name, schema, data, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET schema = excluded.schema, data = excluded.data, updated_at = excluded.updated_at`,
+		name, schema, string(document), time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("could not store %s in %s: %v", name, DatabasePath, err)
+	}
+	return nil
+}
+
+// Document describes one state document for 'webstack state status'
+type Document struct {
+	Name      string
+	Schema    int
+	Size      int
+	UpdatedAt string // Empty for JSON files
+}
+
+// Backend returns "sqlite" or "json"
+func Backend() string {
+	if sqliteEnabled() {
+		return "sqlite"
+	}
+	return "json"
+}
+
+// Documents lists the registered state documents that exist
+func Documents() ([]Document, error) {
+	var docs []Document
+	for _, f := range registered() {
+		if sqliteEnabled() {
+			conn, err := database()
+			if err != nil {
+				return nil, err
+			}
+			var doc Document
+			err = conn.QueryRow(`SELECT name, schema, length(data), updated_at FROM documents WHERE name = ?`, f.key()).
+				Scan(&doc.Name, &doc.Schema, &doc.Size, &doc.UpdatedAt)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, doc)
+			continue
+		}
+		schema, document, found, err := f.readJSON()
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			docs = append(docs, Document{Name: f.key(), Schema: schema, Size: len(document)})
+		}
+	}
+	return docs, nil
+}
+
+// Migrate creates the SQLite database and moves every registered JSON file
+// into it. It returns the names of the imported documents.
+func Migrate() ([]string, error) {
+	if sqliteEnabled() {
+		return nil, fmt.Errorf("the state is already stored in %s", DatabasePath)
+	}
+	if dryrun.Enabled() {
+		fmt.Printf("🔎 [dry-run] would create %s and import the JSON state files\n", DatabasePath)
+		return nil, nil
+	}
+
+	unlock, err := lockAll()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	conn, err := openDatabase(DatabasePath + ".new")
+	if err != nil {
+		return nil, err
+	}
+	var imported, files []string
+	for _, f := range registered() {
+		schema, document, found, err := f.readJSON()
+		if err == nil && found {
+			err = upsert(conn, f.key(), schema, document)
+			imported = append(imported, f.key())
+			files = append(files, f.Path)
+		}
+		if err != nil {
+			conn.Close()
+			os.Remove(DatabasePath + ".new")
+			return nil, err
+		}
+	}
+	conn.Close()
+
+	// The database only takes over once it holds every document
+	if err := os.Rename(DatabasePath+".new", DatabasePath); err != nil {
+		return nil, err
+	}
+	os.Chmod(DatabasePath, 0600)
+	for _, path := range files {
+		if err := os.Rename(path, path+migratedSuffix); err != nil {
+			ui.Warn("⚠️  Warning: Could not rename %s: %v\n", path, err)
+		}
+	}
+	return imported, nil
+}
+
+// Export writes every document to dir as a JSON file in its envelope. With
+// disable the files are written to their own paths and the database is
+// moved aside, switching back to JSON files.
+func Export(dir string, disable bool) ([]string, error) {
+	if !sqliteEnabled() {
+		return nil, fmt.Errorf("the state is stored in JSON files already, there is no %s", DatabasePath)
+	}
+	conn, err := database()
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := lockAll()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var written []string
+	for _, f := range registered() {
+		var schema int
+		var data string
+		err := conn.QueryRow(`SELECT schema, data FROM documents WHERE name = ?`, f.key()).Scan(&schema, &data)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return written, err
+		}
+		target := File{Path: filepath.Join(dir, f.key()), Perm: f.Perm}
+		if disable {
+			target.Path = f.Path
+		}
+		if err := target.writeJSON(schema, json.RawMessage(data)); err != nil {
+			return written, err
+		}
+		written = append(written, target.Path)
+	}
+
+	if disable && !dryrun.Enabled() {
+		conn.Close()
+		aside := fmt.Sprintf("%s.%s", DatabasePath, time.Now().Format("20060102-150405"))
+		for _, suffix := range []string{"-wal", "-shm"} {
+			os.Remove(DatabasePath + suffix)
+		}
+		if err := os.Rename(DatabasePath, aside); err != nil {
+			return written, fmt.Errorf("could not move %s aside: %v", DatabasePath, err)
+		}
+		fmt.Printf("ℹ️  Moved the database to %s\n", aside)
+	}
+	return written, nil
+}
+
+// Dump returns a registered document, e.g. "domains.json", as it would be
+// written to its JSON file, whichever backend holds it
+func Dump(name string) ([]byte, bool, error) {
+	f, ok := lookup(name)
+	if !ok {
+		return nil, false, fmt.Errorf("unknown state document %s", name)
+	}
+	unlock, err := f.lock(syscall.LOCK_SH)
+	if err != nil {
+		return nil, false, err
+	}
+	defer unlock()
+
+	var schema int
+	var document json.RawMessage
+	var found bool
+	if sqliteEnabled() {
+		schema, document, found, err = f.readSQLite()
+	} else {
+		schema, document, found, err = f.readJSON()
+	}
+	if err != nil || !found {
+		return nil, found, err
+	}
+	data, err := json.MarshalIndent(envelope{Schema: schema, Data: document}, "", "  ")
+	return append(data, '\n'), true, err
+}
+
+// Restore replaces a registered document with the contents of a JSON file
+// written by Dump or by an older version without the envelope
+func Restore(name string, data []byte) error {
+	f, ok := lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown state document %s", name)
+	}
+	unlock, err := f.lock(syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	schema, document := decodeEnvelope(data)
+	if !json.Valid(document) {
+		return fmt.Errorf("%s is not valid JSON", name)
+	}
+	if sqliteEnabled() {
+		if dryrun.Enabled() {
+			fmt.Printf("🔎 [dry-run] would store %s in %s (%d bytes)\n", name, DatabasePath, len(document))
+			return nil
+		}
+		conn, err := database()
+		if err != nil {
+			return err
+		}
+		return upsert(conn, name, schema, document)
+	}
+	return f.writeJSON(schema, document)
+}
+
+// Delete removes a registered document, so it reads as missing again
+func Delete(name string) error {
+	f, ok := lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown state document %s", name)
+	}
+	unlock, err := f.lock(syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if !sqliteEnabled() {
+		if err := dryrun.Remove(f.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if dryrun.Enabled() {
+		fmt.Printf("🔎 [dry-run] would delete %s from %s\n", name, DatabasePath)
+		return nil
+	}
+	conn, err := database()
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Exec(`DELETE FROM documents WHERE name = ?`, name); err != nil {
+		return fmt.Errorf("could not delete %s from %s: %v", name, DatabasePath, err)
+	}
+	return nil
+}
+
+// Names returns the names of the registered documents
+func Names() []string {
+	var names []string
+	for _, f := range registered() {
+		names = append(names, f.key())
+	}
+	return names
+}
+
+// Managed reports whether name, e.g. "domains.json", is a registered document
+func Managed(name string) bool {
+	_, ok := lookup(name)
+	return ok
+}
+
+func lookup(name string) (File, bool) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	f, ok := registry[name]
+	return f, ok
+}
+
+// lockAll takes the exclusive lock of every registered file, in name order
+// so two invocations cannot deadlock
+func lockAll() (func(), error) {
+	var unlocks []func()
+	release := func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+	for _, f := range registered() {
+		unlock, err := f.lock(syscall.LOCK_EX)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		unlocks = append(unlocks, unlock)
+	}
+	return release, nil
+}
+
+func registered() []File {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	var files []File
+	for _, f := range registry {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].key() < files[j].key() })
+	return files
+}
@@ -0,0 +1,280 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"webstack-cli/internal/dryrun"
+)
+
+// File is a JSON state file under /etc/webstack, such as domains.json.
+//
+// Documents are stored in an envelope carrying their schema version:
+//
+//	{"schema": 1, "data": ...}
+//
+// Files written before the envelope existed hold the bare document and are
+// read as schema 0. Every access takes an flock on <path>.lock, shared for
+// reads and exclusive for writes, and writes go to a temporary file that is
+// renamed over the old one, so concurrent invocations neither corrupt the
+// file nor see half of it.
+type File struct {
+	Path   string
+	Schema int         // Schema version written to the file
+	Perm   os.FileMode // Defaults to 0644
+	// Migrate upgrades a document written with an older schema to the
+	// current one. Without it older documents are read unchanged.
+	Migrate func(schema int, data json.RawMessage) (json.RawMessage, error)
+}
+
+type envelope struct {
+	Schema int             `json:"schema"`
+	Data   json.RawMessage `json:"data"`
+}
+
+var (
+	heldMu sync.Mutex
+	// held counts the exclusive locks this process holds per file, so
+	// Load and Save called from an Update callback do not lock again
+	held = map[string]int{}
+	// lockFiles keeps the lock files of the exclusive locks in held
+	lockFiles = map[string]*os.File{}
+)
+
+// Load reads the document into v. A missing file leaves v untouched.
+func (f File) Load(v interface{}) error {
+	unlock, err := f.lock(syscall.LOCK_SH)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return f.read(v)
+}
+
+// Exists reports whether the document has been written, to the JSON file or
+// to the SQLite database
+func (f File) Exists() bool {
+	if sqliteEnabled() && f.storedInDatabase() {
+		return true
+	}
+	_, err := os.Stat(f.Path)
+	return err == nil
+}
+
+// Save replaces the document with v
+func (f File) Save(v interface{}) error {
+	unlock, err := f.lock(syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return f.write(v)
+}
+
+// Update reads the document into v, calls fn to change it and writes it
+// back, holding the exclusive lock throughout so no other invocation's
+// change is lost. Nothing is written when fn returns an error.
+func (f File) Update(v interface{}, fn func() error) error {
+	unlock, err := f.lock(syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := f.read(v); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	return f.write(v)
+}
+
+func (f File) read(v interface{}) error {
+	var schema int
+	var document json.RawMessage
+	var found bool
+	var err error
+	if sqliteEnabled() {
+		schema, document, found, err = f.readSQLite()
+	} else {
+		schema, document, found, err = f.readJSON()
+	}
+	if err != nil || !found {
+		return err
+	}
+
+	if schema > f.Schema {
+		return fmt.Errorf("%s uses schema %d, this webstack version supports up to %d; upgrade webstack", f.Path, schema, f.Schema)
+	}
+	if schema < f.Schema && f.Migrate != nil {
+		if document, err = f.Migrate(schema, document); err != nil {
+			return fmt.Errorf("could not migrate %s from schema %d: %v", f.Path, schema, err)
+		}
+	}
+	if err := json.Unmarshal(document, v); err != nil {
+		return fmt.Errorf("could not parse %s: %v", f.Path, err)
+	}
+	return nil
+}
+
+func (f File) readJSON() (int, json.RawMessage, bool, error) {
+	data, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return 0, nil, false, nil
+	}
+	if err != nil {
+		return 0, nil, false, fmt.Errorf("could not read %s: %v", f.Path, err)
+	}
+	schema, document := decodeEnvelope(data)
+	return schema, document, true, nil
+}
+
+// decodeEnvelope returns the schema version and document of a file, treating
+// anything that is not an envelope as a bare schema 0 document
+func decodeEnvelope(data []byte) (int, json.RawMessage) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(trimmed, &fields) == nil && len(fields) == 2 && fields["schema"] != nil && fields["data"] != nil {
+			var env envelope
+			if json.Unmarshal(trimmed, &env) == nil {
+				return env.Schema, env.Data
+			}
+		}
+	}
+	return 0, trimmed
+}
+
+func (f File) write(v interface{}) error {
+	document, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if sqliteEnabled() {
+		return f.writeSQLite(document)
+	}
+	return f.writeJSON(f.Schema, document)
+}
+
+// writeJSON writes a document in its envelope to the file through a
+// temporary file
+func (f File) writeJSON(schema int, document json.RawMessage) error {
+	data, err := json.MarshalIndent(envelope{Schema: schema, Data: document}, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	perm := f.Perm
+	if perm == 0 {
+		perm = 0644
+	}
+	if dryrun.Enabled() {
+		return dryrun.WriteFile(f.Path, data, perm)
+	}
+
+	dir := filepath.Dir(f.Path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(f.Path)+".tmp-")
+	if err != nil {
+		return fmt.Errorf("could not write %s: %v", f.Path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write %s: %v", f.Path, err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write %s: %v", f.Path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), f.Path); err != nil {
+		return fmt.Errorf("could not replace %s: %v", f.Path, err)
+	}
+
+	// Persist the rename itself
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// lock takes an flock on the lock file next to the document and returns
+// the function releasing it. Reads go unlocked when the lock file cannot be
+// created, e.g. for a user without write access to /etc/webstack; they still
+// never see a partial file thanks to the atomic rename.
+func (f File) lock(how int) (func(), error) {
+	heldMu.Lock()
+	if held[f.Path] > 0 {
+		held[f.Path]++
+		heldMu.Unlock()
+		return f.release, nil
+	}
+	heldMu.Unlock()
+
+	if dryrun.Enabled() {
+		return func() {}, nil
+	}
+
+	path := f.Path + ".lock"
+	if how == syscall.LOCK_EX {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil && how == syscall.LOCK_SH {
+		if file, err = os.Open(path); err != nil {
+			return func() {}, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not lock %s: %v", f.Path, err)
+	}
+	if err := syscall.Flock(int(file.Fd()), how); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("could not lock %s: %v", f.Path, err)
+	}
+
+	if how == syscall.LOCK_SH {
+		return func() { file.Close() }, nil
+	}
+	heldMu.Lock()
+	held[f.Path] = 1
+	lockFiles[f.Path] = file
+	heldMu.Unlock()
+	return f.release, nil
+}
+
+// release drops one level of an exclusive lock, closing the lock file (and
+// so releasing the flock) at the outermost level
+func (f File) release() {
+	heldMu.Lock()
+	defer heldMu.Unlock()
+	held[f.Path]--
+	if held[f.Path] > 0 {
+		return
+	}
+	delete(held, f.Path)
+	if file := lockFiles[f.Path]; file != nil {
+		file.Close()
+		delete(lockFiles, f.Path)
+	}
+}
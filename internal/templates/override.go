@@ -0,0 +1,263 @@
+package templates
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// OverrideDir holds customized templates. A file there takes precedence over
+// the embedded template with the same path, e.g. nginx/domain.conf.
+const OverrideDir = "/etc/webstack/templates"
+
+// versionsFile records the embedded version each override was exported from
+const versionsFile = OverrideDir + "/.versions.json"
+
+// Template statuses reported by List
+const (
+	StatusBuiltin    = "built-in"   // No override
+	StatusUnchanged  = "unchanged"  // Override identical to the embedded template
+	StatusCustomized = "customized" // Override of the current embedded template
+	StatusOutdated   = "outdated"   // The embedded template changed since the override was exported
+	StatusCustom     = "custom"     // Override without an embedded template, e.g. a new preset
+)
+
+// Info describes a template and its override
+type Info struct {
+	Path        string
+	Version     string // Version of the embedded template
+	BaseVersion string // Embedded version the override was exported from
+	Override    string // Path of the override, if any
+	Status      string
+}
+
+// readOverride returns the override of a template
+func readOverride(name string) ([]byte, bool) {
+	name = path.Clean(name)
+	if strings.HasPrefix(name, "../") || name == ".." {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(filepath.Join(OverrideDir, name))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Version returns the version of an embedded template, a short hash of its
+// content
+func Version(name string) string {
+	data, err := FS.ReadFile(name)
+	if err != nil {
+		return ""
+	}
+	return version(data)
+}
+
+func version(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))[:12]
+}
+
+func loadVersions() map[string]string {
+	versions := map[string]string{}
+	if data, err := ioutil.ReadFile(versionsFile); err == nil {
+		json.Unmarshal(data, &versions)
+	}
+	return versions
+}
+
+func saveVersions(versions map[string]string) error {
+	data, err := json.MarshalIndent(versions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(versionsFile, data, 0644)
+}
+
+// embeddedNames returns the paths of the embedded templates under dir ("."
+// for all)
+func embeddedNames(dir string) []string {
+	var names []string
+	fs.WalkDir(FS, dir, func(name string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			names = append(names, name)
+		}
+		return nil
+	})
+	return names
+}
+
+// overrideNames returns the paths of the overrides, relative to OverrideDir
+func overrideNames() []string {
+	var names []string
+	filepath.Walk(OverrideDir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(OverrideDir, file)
+		if !strings.HasPrefix(filepath.Base(rel), ".") {
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return names
+}
+
+// List returns the embedded templates and the overrides, sorted by path
+func List() []Info {
+	versions := loadVersions()
+	byPath := map[string]*Info{}
+	for _, name := range embeddedNames(".") {
+		byPath[name] = &Info{Path: name, Version: Version(name), Status: StatusBuiltin}
+	}
+	for _, name := range overrideNames() {
+		info, embedded := byPath[name]
+		if !embedded {
+			info = &Info{Path: name, Status: StatusCustom}
+			byPath[name] = info
+		}
+		info.Override = filepath.Join(OverrideDir, name)
+		info.BaseVersion = versions[name]
+		if !embedded {
+			continue
+		}
+		data, _ := readOverride(name)
+		switch {
+		case version(data) == info.Version:
+			info.Status = StatusUnchanged
+		case info.BaseVersion != "" && info.BaseVersion != info.Version:
+			info.Status = StatusOutdated
+		default:
+			info.Status = StatusCustomized
+		}
+	}
+
+	var list []Info
+	for _, info := range byPath {
+		list = append(list, *info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
+
+// Overrides returns the templates that have an override
+func Overrides() []Info {
+	var overrides []Info
+	for _, info := range List() {
+		if info.Override != "" {
+			overrides = append(overrides, info)
+		}
+	}
+	return overrides
+}
+
+// Export copies embedded templates into OverrideDir for editing: one
+// template ("nginx/domain.conf"), a directory ("nginx", "presets/wordpress")
+// or all of them when name is empty. Existing overrides are kept unless
+// force is set. It returns the paths written.
+func Export(name string, force bool) ([]string, error) {
+	dir := "."
+	if name != "" {
+		dir = path.Clean(strings.Trim(name, "/"))
+	}
+	names := embeddedNames(dir)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no embedded template %s (see 'webstack template list')", name)
+	}
+
+	versions := loadVersions()
+	var written []string
+	for _, n := range names {
+		target := filepath.Join(OverrideDir, n)
+		if _, err := os.Stat(target); err == nil && !force {
+			fmt.Printf("⏭️  %s is already customized (use --force to overwrite)\n", target)
+			continue
+		}
+		data, err := FS.ReadFile(n)
+		if err != nil {
+			return written, err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return written, err
+		}
+		if err := ioutil.WriteFile(target, data, 0644); err != nil {
+			return written, fmt.Errorf("could not write %s: %v", target, err)
+		}
+		versions[n] = version(data)
+		written = append(written, target)
+	}
+	if len(written) > 0 {
+		if err := saveVersions(versions); err != nil {
+			return written, fmt.Errorf("could not write %s: %v", versionsFile, err)
+		}
+	}
+	return written, nil
+}
+
+// Reset removes overrides so the embedded templates are used again: one
+// template, a directory or all of them when name is empty. It returns the
+// paths removed.
+func Reset(name string) ([]string, error) {
+	prefix := path.Clean(strings.Trim(name, "/"))
+	versions := loadVersions()
+	var removed []string
+	for _, n := range overrideNames() {
+		if name != "" && n != prefix && !strings.HasPrefix(n, prefix+"/") {
+			continue
+		}
+		file := filepath.Join(OverrideDir, n)
+		if err := os.Remove(file); err != nil {
+			return removed, err
+		}
+		delete(versions, n)
+		removed = append(removed, file)
+	}
+	if len(removed) == 0 {
+		return nil, fmt.Errorf("no customized template %s", name)
+	}
+	if err := saveVersions(versions); err != nil {
+		return removed, err
+	}
+	// Leave no empty directories behind
+	for _, file := range removed {
+		for dir := filepath.Dir(file); dir != OverrideDir && strings.HasPrefix(dir, OverrideDir); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	return removed, nil
+}
+
+// Parse checks that a template is valid Go template syntax
+func Parse(name string) error {
+	data, err := GetTemplate(name)
+	if err != nil {
+		return err
+	}
+	_, err = template.New(path.Base(name)).Parse(string(data))
+	return err
+}
+
+// IsVhost reports whether a template renders a domain's vhost, which
+// 'webstack template test' can syntax-check
+func IsVhost(name string) bool {
+	dir, file := path.Split(name)
+	if dir != "nginx/" && dir != "apache/" {
+		return false
+	}
+	for _, prefix := range []string{"domain", "static", "proxy", "upstream"} {
+		if strings.HasPrefix(file, prefix) {
+			return true
+		}
+	}
+	return false
+}
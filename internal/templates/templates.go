@@ -2,13 +2,19 @@ package templates
 
 import (
 	"embed"
+	"io/ioutil"
+	"sort"
 )
 
-//go:embed nginx/* apache/* mysql/* php-fpm/* error/* dns/*
+//go:embed nginx/* apache/* mysql/* php-fpm/* error/* dns/* presets/* ftp/* caddy/*
 var FS embed.FS
 
-// GetTemplate reads a template file from the embedded filesystem
+// GetTemplate reads a template file, preferring a customized copy in
+// OverrideDir over the embedded filesystem
 func GetTemplate(path string) ([]byte, error) {
+	if data, ok := readOverride(path); ok {
+		return data, nil
+	}
 	return FS.ReadFile(path)
 }
 
@@ -41,3 +47,39 @@ func GetErrorTemplate(filename string) ([]byte, error) {
 func GetDNSTemplate(filename string) ([]byte, error) {
 	return GetTemplate("dns/" + filename)
 }
+
+// GetFTPTemplate reads an FTP/SFTP server template
+func GetFTPTemplate(filename string) ([]byte, error) {
+	return GetTemplate("ftp/" + filename)
+}
+
+// GetPresetTemplate reads the vhost rules of a framework preset for a web
+// server ("nginx" or "apache")
+func GetPresetTemplate(preset, server string) ([]byte, error) {
+	return GetTemplate("presets/" + preset + "/" + server + ".conf")
+}
+
+// ListPresets returns the names of the available framework presets,
+// including custom presets in OverrideDir
+func ListPresets() []string {
+	seen := map[string]bool{}
+	var presets []string
+	if entries, err := FS.ReadDir("presets"); err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() && !seen[entry.Name()] {
+				seen[entry.Name()] = true
+				presets = append(presets, entry.Name())
+			}
+		}
+	}
+	if entries, err := ioutil.ReadDir(OverrideDir + "/presets"); err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() && !seen[entry.Name()] {
+				seen[entry.Name()] = true
+				presets = append(presets, entry.Name())
+			}
+		}
+	}
+	sort.Strings(presets)
+	return presets
+}
@@ -0,0 +1,474 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/ui"
+)
+
+const (
+	binDir  = "/usr/local/bin"
+	nodeDir = "/opt/webstack/node"
+)
+
+// cliTool describes a command-line tool installed into /usr/local/bin at a
+// pinned release, so it does not change behind the user's back
+type cliTool struct {
+	title    string
+	binary   string
+	needsPHP bool
+	// resolve turns a requested version ("", "latest" or a prefix such as
+	// "22" for Node.js) into a release
+	resolve func(requested string) (string, error)
+	install func(version string) error
+	remove  func() error
+	// versionArgs print the release of the installed binary
+	versionArgs []string
+}
+
+var binaries = map[string]cliTool{
+	"composer": {
+		title:       "Composer",
+		binary:      filepath.Join(binDir, "composer"),
+		needsPHP:    true,
+		resolve:     resolveComposerVersion,
+		install:     installComposer,
+		remove:      func() error { return dryrun.Remove(filepath.Join(binDir, "composer")) },
+		versionArgs: []string{"--version", "--no-interaction"},
+	},
+	"wp-cli": {
+		title:       "WP-CLI",
+		binary:      filepath.Join(binDir, "wp"),
+		needsPHP:    true,
+		resolve:     resolveWPCLIVersion,
+		install:     installWPCLI,
+		remove:      func() error { return dryrun.Remove(filepath.Join(binDir, "wp")) },
+		versionArgs: []string{"--version", "--allow-root"},
+	},
+	"node": {
+		title:       "Node.js",
+		binary:      filepath.Join(binDir, "node"),
+		resolve:     resolveNodeVersion,
+		install:     installNode,
+		remove:      removeNode,
+		versionArgs: []string{"--version"},
+	},
+}
+
+var releasePattern = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+
+// installCLI installs a command-line tool, or switches an installed one to
+// the requested release
+func installCLI(name string, opts InstallOptions) {
+	t := binaries[name]
+	if opts.Path != "" || opts.Host != "" || opts.PHPVersion != "" || len(opts.AllowIPs) > 0 || opts.AuthUser != "" {
+		ui.Invalid("Invalid options: %s is a command-line tool; only --version applies\n", t.title)
+		return
+	}
+	if t.needsPHP {
+		if _, err := exec.LookPath("php"); err != nil {
+			ui.Fail("❌ %s needs the PHP CLI. Install PHP first with: sudo webstack install php <version>\n", t.title)
+			return
+		}
+	}
+
+	existing, _ := getTool(name)
+	if existing != nil && opts.Version == "" {
+		fmt.Printf("✅ %s %s is already installed. Upgrade it with: sudo webstack tools upgrade %s\n", t.title, existing.Version, name)
+		return
+	}
+
+	version, err := t.resolve(opts.Version)
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	if existing != nil && existing.Version == version {
+		fmt.Printf("✅ %s %s is already installed\n", t.title, version)
+		return
+	}
+
+	fmt.Printf("🚀 Installing %s %s...\n", t.title, version)
+	if err := t.install(version); err != nil {
+		ui.Fail("❌ %s installation failed: %v\n", t.title, err)
+		return
+	}
+
+	record := Tool{Name: name, Kind: kindCLI, Version: version, Root: t.binary, InstalledAt: time.Now()}
+	if existing != nil {
+		record.InstalledAt = existing.InstalledAt
+		record.UpgradedAt = time.Now()
+	}
+	if err := saveTool(record); err != nil {
+		ui.Warn("⚠️  Warning: Could not save %s: %v\n", toolsFile, err)
+	}
+	fmt.Printf("✅ %s %s installed as %s\n", t.title, version, t.binary)
+	if !dryrun.Enabled() {
+		if err := verifyCLI(t, version); err != nil {
+			ui.Warn("⚠️  Warning: %v\n", err)
+		}
+	}
+}
+
+// upgradeCLI moves a command-line tool to the latest or the given release
+func upgradeCLI(name, version string) {
+	t := binaries[name]
+	record, err := getTool(name)
+	if err != nil {
+		ui.Fail("%s is not installed. Install it with: sudo webstack tools install %s\n", t.title, name)
+		return
+	}
+	target, err := t.resolve(version)
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	if target == record.Version {
+		fmt.Printf("✅ %s %s is up to date\n", t.title, record.Version)
+		return
+	}
+
+	fmt.Printf("⬆️  Upgrading %s %s to %s...\n", t.title, record.Version, target)
+	if err := t.install(target); err != nil {
+		ui.Fail("❌ Upgrade failed, %s %s is still installed: %v\n", t.title, record.Version, err)
+		return
+	}
+	record.Version = target
+	record.UpgradedAt = time.Now()
+	if err := saveTool(*record); err != nil {
+		ui.Warn("⚠️  Warning: Could not save %s: %v\n", toolsFile, err)
+	}
+	fmt.Printf("✅ %s upgraded to %s\n", t.title, target)
+}
+
+// uninstallCLI removes a command-line tool installed by installCLI
+func uninstallCLI(name string) {
+	t := binaries[name]
+	if _, err := getTool(name); err != nil {
+		fmt.Printf("ℹ️  %s is not installed by webstack\n", t.title)
+		return
+	}
+	if err := t.remove(); err != nil && !os.IsNotExist(err) {
+		ui.Fail("❌ Failed to remove %s: %v\n", t.binary, err)
+		return
+	}
+	if err := deleteTool(name); err != nil {
+		ui.Warn("⚠️  Warning: Could not update %s: %v\n", toolsFile, err)
+	}
+	fmt.Printf("✅ %s uninstalled\n", t.title)
+}
+
+// verifyCLI checks that the installed binary runs and reports the pinned
+// release
+func verifyCLI(t cliTool, version string) error {
+	output, err := exec.Command(t.binary, t.versionArgs...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s does not run: %v", t.binary, err)
+	}
+	if !strings.Contains(string(output), version) {
+		return fmt.Errorf("%s reports %q, expected %s", t.binary, strings.TrimSpace(string(output)), version)
+	}
+	return nil
+}
+
+// List prints every available tool with the installed release; command-line
+// tools are run to check that they still report the pinned release
+func List() {
+	records, err := loadTools()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	installed := map[string]Tool{}
+	for _, record := range records {
+		installed[record.Name] = record
+	}
+
+	fmt.Printf("%-12s %-8s %-10s %s\n", "TOOL", "KIND", "VERSION", "STATE")
+	for _, name := range Names() {
+		kind := "web"
+		if _, ok := binaries[name]; ok {
+			kind = kindCLI
+		}
+		record, ok := installed[name]
+		if !ok {
+			fmt.Printf("%-12s %-8s %-10s %s\n", name, kind, "-", "not installed")
+			continue
+		}
+		state := "✅ installed"
+		if t, ok := binaries[name]; ok {
+			if err := verifyCLI(t, record.Version); err != nil {
+				state = "⚠️  " + err.Error()
+			} else {
+				state = "✅ verified, " + t.binary
+			}
+		} else if _, err := os.Stat(record.Root); err != nil {
+			state = "⚠️  " + record.Root + " is missing"
+		}
+		fmt.Printf("%-12s %-8s %-10s %s\n", name, kind, record.Version, state)
+	}
+}
+
+// downloadVerified downloads url to path and checks it against the hex
+// digest published at sumURL, whose first field is the digest
+func downloadVerified(url, sumURL, path string, newHash func() hash.Hash) error {
+	if err := dryrun.Run(exec.Command("curl", "-fsSL", "-o", path, url)); err != nil {
+		return fmt.Errorf("could not download %s: %v", url, err)
+	}
+	if dryrun.Enabled() {
+		return nil
+	}
+	sum, err := exec.Command("curl", "-fsSL", sumURL).Output()
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("could not download the checksum of %s: %v", url, err)
+	}
+	fields := strings.Fields(string(sum))
+	if len(fields) == 0 {
+		os.Remove(path)
+		return fmt.Errorf("empty checksum file for %s", url)
+	}
+	actual, err := fileDigest(path, newHash)
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	if !strings.EqualFold(fields[0], actual) {
+		os.Remove(path)
+		return fmt.Errorf("checksum mismatch for %s", url)
+	}
+	return nil
+}
+
+func fileDigest(path string, newHash func() hash.Hash) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := newHash()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// installPhar downloads a verified PHAR next to the binary and moves it in
+// place, so a failed download leaves the installed release working
+func installPhar(url, sumURL, binary string, newHash func() hash.Hash) error {
+	if err := dryrun.MkdirAll(filepath.Dir(binary), 0755); err != nil {
+		return err
+	}
+	staging := binary + ".new"
+	if err := downloadVerified(url, sumURL, staging, newHash); err != nil {
+		return err
+	}
+	if !dryrun.Enabled() {
+		if err := os.Chmod(staging, 0755); err != nil {
+			os.Remove(staging)
+			return err
+		}
+	}
+	return dryrun.Rename(staging, binary)
+}
+
+func resolveComposerVersion(requested string) (string, error) {
+	if requested != "" && requested != "latest" {
+		if !releasePattern.MatchString(requested) {
+			return "", fmt.Errorf("invalid Composer version: %s (e.g. 2.8.3)", requested)
+		}
+		return requested, nil
+	}
+	output, err := exec.Command("curl", "-fsSL", "https://getcomposer.org/versions").Output()
+	if err != nil {
+		return "", fmt.Errorf("could not look up the latest Composer release: %v", err)
+	}
+	var versions map[string][]struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(output, &versions); err != nil || len(versions["stable"]) == 0 {
+		return "", fmt.Errorf("unexpected response from getcomposer.org/versions")
+	}
+	return versions["stable"][0].Version, nil
+}
+
+func installComposer(version string) error {
+	url := fmt.Sprintf("https://getcomposer.org/download/%s/composer.phar", version)
+	return installPhar(url, url+".sha256sum", filepath.Join(binDir, "composer"), sha256.New)
+}
+
+func resolveWPCLIVersion(requested string) (string, error) {
+	if requested != "" && requested != "latest" {
+		if !releasePattern.MatchString(requested) {
+			return "", fmt.Errorf("invalid WP-CLI version: %s (e.g. 2.11.0)", requested)
+		}
+		return requested, nil
+	}
+	output, err := exec.Command("curl", "-fsSL", "https://api.github.com/repos/wp-cli/wp-cli/releases/latest").Output()
+	if err != nil {
+		return "", fmt.Errorf("could not look up the latest WP-CLI release: %v", err)
+	}
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.Unmarshal(output, &release); err != nil {
+		return "", fmt.Errorf("unexpected response from the GitHub API: %v", err)
+	}
+	version := strings.TrimPrefix(release.TagName, "v")
+	if !releasePattern.MatchString(version) {
+		return "", fmt.Errorf("unexpected WP-CLI release %q", release.TagName)
+	}
+	return version, nil
+}
+
+func installWPCLI(version string) error {
+	url := fmt.Sprintf("https://github.com/wp-cli/wp-cli/releases/download/v%s/wp-cli-%s.phar", version, version)
+	return installPhar(url, url+".sha512", filepath.Join(binDir, "wp"), sha512.New)
+}
+
+// resolveNodeVersion picks the newest LTS release, or the newest release of
+// a requested major version such as "22"
+func resolveNodeVersion(requested string) (string, error) {
+	requested = strings.TrimPrefix(requested, "v")
+	if releasePattern.MatchString(requested) {
+		return requested, nil
+	}
+	if requested != "" && requested != "latest" && requested != "lts" && !regexp.MustCompile(`^\d+$`).MatchString(requested) {
+		return "", fmt.Errorf("invalid Node.js version: %s (e.g. 22 or 22.11.0)", requested)
+	}
+
+	output, err := exec.Command("curl", "-fsSL", "https://nodejs.org/dist/index.json").Output()
+	if err != nil {
+		return "", fmt.Errorf("could not look up Node.js releases: %v", err)
+	}
+	var releases []struct {
+		Version string      `json:"version"`
+		LTS     interface{} `json:"lts"` // false or the LTS code name
+	}
+	if err := json.Unmarshal(output, &releases); err != nil {
+		return "", fmt.Errorf("unexpected response from nodejs.org: %v", err)
+	}
+	var candidates []string
+	for _, r := range releases {
+		version := strings.TrimPrefix(r.Version, "v")
+		switch {
+		case requested == "" || requested == "lts" || requested == "latest":
+			if lts, ok := r.LTS.(string); ok && lts != "" {
+				candidates = append(candidates, version)
+			}
+		case strings.HasPrefix(version, requested+"."):
+			candidates = append(candidates, version)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no Node.js release matches %s", requested)
+	}
+	sort.Slice(candidates, func(i, j int) bool { return versionLess(candidates[i], candidates[j]) })
+	return candidates[len(candidates)-1], nil
+}
+
+// nodeArch maps the Go architecture to the one in Node.js archive names
+func nodeArch() (string, error) {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x64", nil
+	case "arm64":
+		return "arm64", nil
+	}
+	return "", fmt.Errorf("no Node.js builds for %s", runtime.GOARCH)
+}
+
+// installNode extracts a verified Node.js release into
+// /opt/webstack/node/<version> and links node, npm, npx and corepack into
+// /usr/local/bin, then removes the release it replaced
+func installNode(version string) error {
+	arch, err := nodeArch()
+	if err != nil {
+		return err
+	}
+	archiveName := fmt.Sprintf("node-v%s-linux-%s.tar.xz", version, arch)
+	base := fmt.Sprintf("https://nodejs.org/dist/v%s/", version)
+
+	archive := filepath.Join(os.TempDir(), archiveName)
+	fmt.Printf("📥 Downloading %s...\n", archiveName)
+	if err := dryrun.Run(exec.Command("curl", "-fsSL", "-o", archive, base+archiveName)); err != nil {
+		return fmt.Errorf("could not download %s: %v", archiveName, err)
+	}
+	defer dryrun.Remove(archive)
+
+	if !dryrun.Enabled() {
+		sums, err := exec.Command("curl", "-fsSL", base+"SHASUMS256.txt").Output()
+		if err != nil {
+			return fmt.Errorf("could not download SHASUMS256.txt: %v", err)
+		}
+		expected := ""
+		for _, line := range strings.Split(string(sums), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[1] == archiveName {
+				expected = fields[0]
+			}
+		}
+		actual, err := fileDigest(archive, sha256.New)
+		if err != nil {
+			return err
+		}
+		if expected == "" || !strings.EqualFold(expected, actual) {
+			return fmt.Errorf("checksum mismatch for %s", archiveName)
+		}
+	}
+
+	dir := filepath.Join(nodeDir, version)
+	dryrun.RemoveAll(dir)
+	if err := dryrun.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("could not create %s: %v", dir, err)
+	}
+	if err := dryrun.Run(exec.Command("tar", "-xJf", archive, "-C", dir, "--strip-components=1", "--no-same-owner")); err != nil {
+		dryrun.RemoveAll(dir)
+		return fmt.Errorf("could not extract %s (is xz-utils installed?): %v", archiveName, err)
+	}
+
+	for _, command := range []string{"node", "npm", "npx", "corepack"} {
+		target := filepath.Join(dir, "bin", command)
+		if _, err := os.Stat(target); err != nil && !dryrun.Enabled() {
+			continue
+		}
+		link := filepath.Join(binDir, command)
+		dryrun.Remove(link)
+		if err := dryrun.Symlink(target, link); err != nil {
+			return fmt.Errorf("could not link %s: %v", link, err)
+		}
+	}
+
+	// Releases other than the linked one are no longer used
+	entries, _ := os.ReadDir(nodeDir)
+	for _, entry := range entries {
+		if entry.Name() != version {
+			dryrun.RemoveAll(filepath.Join(nodeDir, entry.Name()))
+		}
+	}
+	return nil
+}
+
+func removeNode() error {
+	for _, command := range []string{"node", "npm", "npx", "corepack"} {
+		link := filepath.Join(binDir, command)
+		if target, err := os.Readlink(link); err == nil && strings.HasPrefix(target, nodeDir+"/") {
+			dryrun.Remove(link)
+		}
+	}
+	return dryrun.RemoveAll(nodeDir)
+}
@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/random"
+)
+
+const (
+	phpMyAdminVersionURL = "https://www.phpmyadmin.net/home_page/version.txt"
+	phpMyAdminTempDir    = "/var/lib/phpmyadmin/tmp"
+)
+
+var phpMyAdminVersionPattern = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+
+// latestPhpMyAdminVersion reads the current release from phpmyadmin.net,
+// whose version.txt starts with the version number
+func latestPhpMyAdminVersion() (string, error) {
+	output, err := exec.Command("curl", "-fsSL", phpMyAdminVersionURL).Output()
+	if err != nil {
+		return "", err
+	}
+	version := strings.TrimSpace(strings.SplitN(string(output), "\n", 2)[0])
+	if !phpMyAdminVersionPattern.MatchString(version) {
+		return "", fmt.Errorf("unexpected version %q in %s", version, phpMyAdminVersionURL)
+	}
+	return version, nil
+}
+
+// downloadPhpMyAdmin downloads a release, checks it against the published
+// SHA-256 sum and extracts it into dir
+func downloadPhpMyAdmin(version, dir string) error {
+	if !phpMyAdminVersionPattern.MatchString(version) {
+		return fmt.Errorf("invalid phpMyAdmin version: %s (e.g. 5.2.1)", version)
+	}
+	url := fmt.Sprintf("https://files.phpmyadmin.net/phpMyAdmin/%s/phpMyAdmin-%s-all-languages.tar.gz", version, version)
+
+	archive := filepath.Join(os.TempDir(), "phpmyadmin-"+version+".tar.gz")
+	fmt.Printf("📥 Downloading phpMyAdmin %s...\n", version)
+	if err := dryrun.Run(exec.Command("curl", "-fsSL", "-o", archive, url)); err != nil {
+		return fmt.Errorf("could not download %s: %v", url, err)
+	}
+	defer dryrun.Remove(archive)
+
+	if !dryrun.Enabled() {
+		sum, err := exec.Command("curl", "-fsSL", url+".sha256").Output()
+		if err != nil {
+			return fmt.Errorf("could not download the checksum of %s: %v", url, err)
+		}
+		fields := strings.Fields(string(sum))
+		if len(fields) == 0 {
+			return fmt.Errorf("empty checksum file for %s", url)
+		}
+		actual, err := fileDigest(archive, sha256.New)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(fields[0], actual) {
+			return fmt.Errorf("checksum mismatch for %s", url)
+		}
+	}
+
+	if err := dryrun.Run(exec.Command("tar", "-xzf", archive, "-C", dir, "--strip-components=1")); err != nil {
+		return fmt.Errorf("could not extract phpMyAdmin: %v", err)
+	}
+	// The setup wizard writes configuration from the browser
+	dryrun.RemoveAll(filepath.Join(dir, "setup"))
+	return nil
+}
+
+// configurePhpMyAdmin carries config.inc.php over from the previous
+// installation, or writes a new one with cookie authentication against the
+// local MySQL/MariaDB server
+func configurePhpMyAdmin(dir, previous string) error {
+	if err := dryrun.MkdirAll(phpMyAdminTempDir, 0750); err != nil {
+		return fmt.Errorf("could not create %s: %v", phpMyAdminTempDir, err)
+	}
+	dryrun.Run(exec.Command("chown", "www-data:www-data", phpMyAdminTempDir))
+
+	target := filepath.Join(dir, "config.inc.php")
+	if previous != "" {
+		if data, err := ioutil.ReadFile(filepath.Join(previous, "config.inc.php")); err == nil {
+			if err := dryrun.WriteFile(target, data, 0640); err != nil {
+				return fmt.Errorf("could not copy config.inc.php: %v", err)
+			}
+			fmt.Println("✓ Kept config.inc.php of the previous installation")
+			return nil
+		}
+	}
+
+	// The blowfish secret encrypts the login cookie and must be 32 bytes
+	secret, err := random.String(32)
+	if err != nil {
+		return fmt.Errorf("could not generate the blowfish secret: %v", err)
+	}
+	content := fmt.Sprintf(`<?php
+// phpMyAdmin Configuration File - Generated by WebStack CLI
+
+$cfg['blowfish_secret'] = '%s';
+
+$i = 1;
+$cfg['Servers'][$i]['auth_type'] = 'cookie';
+$cfg['Servers'][$i]['host'] = 'localhost';
+$cfg['Servers'][$i]['compress'] = false;
+$cfg['Servers'][$i]['AllowNoPassword'] = false;
+
+$cfg['TempDir'] = '%s';
+$cfg['ShowErrors'] = false;
+$cfg['VersionCheck'] = false;
+`, secret, phpMyAdminTempDir)
+	if err := dryrun.WriteFile(target, []byte(content), 0640); err != nil {
+		return fmt.Errorf("could not write config.inc.php: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,553 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/osinfo"
+	"webstack-cli/internal/phpfpm"
+	"webstack-cli/internal/random"
+	"webstack-cli/internal/ui"
+)
+
+const toolsFile = "/etc/webstack/tools.json"
+
+// kindCLI marks the records of command-line tools
+const kindCLI = "cli"
+
+// Tool is a web admin application or a command-line tool installed by
+// 'webstack tools install'
+type Tool struct {
+	Name        string    `json:"name"`
+	Kind        string    `json:"kind,omitempty"` // "cli" for command-line tools, empty for web tools
+	Version     string    `json:"version"`
+	Root        string    `json:"root"`           // Installation directory, or the binary of a command-line tool
+	Path        string    `json:"path,omitempty"` // URL path on every domain, e.g. /phpmyadmin
+	Host        string    `json:"host,omitempty"` // Dedicated host name, e.g. db.example.com
+	Server      string    `json:"server"`         // Web server serving it: "nginx" or "apache"
+	PHPVersion  string    `json:"php_version"`
+	AllowIPs    []string  `json:"allow_ips,omitempty"`
+	AuthUser    string    `json:"auth_user,omitempty"`
+	InstalledAt time.Time `json:"installed_at"`
+	UpgradedAt  time.Time `json:"upgraded_at"`
+}
+
+// InstallOptions controls where a tool is served and who may reach it
+type InstallOptions struct {
+	Version      string   // Release to install; empty for the latest
+	Path         string   // URL path on every domain (default /<tool>)
+	Host         string   // Dedicated host name instead of a path
+	PHPVersion   string   // PHP-FPM version; empty for the newest installed
+	AllowIPs     []string // Addresses or CIDR ranges allowed to reach the tool
+	AuthUser     string   // Basic auth user in front of the tool's own login
+	AuthPassword string   // Generated when empty
+}
+
+// tool describes how an admin application is downloaded and configured
+type tool struct {
+	title         string
+	root          string
+	latestVersion func() (string, error)
+	download      func(version, dir string) error
+	// configure writes the tool's own configuration into a fresh
+	// installation; previous is the directory of the installation it
+	// replaces ("" for a new one)
+	configure func(dir, previous string) error
+}
+
+var available = map[string]tool{
+	"phpmyadmin": {
+		title:         "phpMyAdmin",
+		root:          "/var/www/phpmyadmin",
+		latestVersion: latestPhpMyAdminVersion,
+		download:      downloadPhpMyAdmin,
+		configure:     configurePhpMyAdmin,
+	},
+}
+
+var pathPattern = regexp.MustCompile(`^(/[a-zA-Z0-9._-]+)+$`)
+
+// Names returns the tools that can be installed
+func Names() []string {
+	var names []string
+	for name := range available {
+		names = append(names, name)
+	}
+	for name := range binaries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Install downloads a tool, serves it under a path on every domain or on a
+// dedicated host and restricts access to it. Installing an installed tool
+// again only changes where it is served and who may reach it; use Upgrade
+// for a new release.
+func Install(name string, opts InstallOptions) {
+	if _, ok := binaries[name]; ok {
+		installCLI(name, opts)
+		return
+	}
+	t, ok := available[name]
+	if !ok {
+		ui.Invalid("Unknown tool: %s. Available: %s\n", name, strings.Join(Names(), ", "))
+		return
+	}
+
+	record, err := buildRecord(name, t, opts)
+	if err != nil {
+		ui.Invalid("Invalid options: %v\n", err)
+		return
+	}
+	password := opts.AuthPassword
+	if record.AuthUser != "" && password == "" {
+		if password, err = random.String(16); err != nil {
+			ui.Fail("❌ Could not generate a password: %v\n", err)
+			return
+		}
+	}
+
+	existing, _ := getTool(name)
+	if existing != nil {
+		fmt.Printf("ℹ️  %s %s is already installed, updating how it is served\n", t.title, existing.Version)
+		record.Version = existing.Version
+		record.InstalledAt = existing.InstalledAt
+		record.UpgradedAt = existing.UpgradedAt
+		// Keep the basic auth password unless a new one is given
+		if record.AuthUser != "" && record.AuthUser == existing.AuthUser && opts.AuthPassword == "" {
+			password = ""
+		}
+	} else {
+		fmt.Printf("🚀 Installing %s...\n", t.title)
+		version, err := resolveVersion(t, opts.Version)
+		if err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+		if err := deployRelease(t, version); err != nil {
+			ui.Fail("❌ %s installation failed: %v\n", t.title, err)
+			return
+		}
+		record.Version = version
+		record.InstalledAt = time.Now()
+	}
+
+	if err := writeAccessFile(record, password); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	if existing != nil {
+		if err := removeWebConfig(*existing); err != nil {
+			ui.Warn("⚠️  Warning: %v\n", err)
+		}
+	}
+	if err := deployWebConfig(record); err != nil {
+		ui.Fail("❌ Could not configure %s: %v\n", record.Server, err)
+		if existing != nil {
+			// Put the previous setup back
+			deployWebConfig(*existing)
+		}
+		return
+	}
+	if err := saveTool(record); err != nil {
+		ui.Warn("⚠️  Warning: Could not save %s: %v\n", toolsFile, err)
+	}
+	domain.ReloadWebServers()
+
+	fmt.Printf("✅ %s %s is available at %s\n", t.title, record.Version, toolURL(record))
+	if record.AuthUser != "" && password != "" && opts.AuthPassword == "" {
+		fmt.Printf("   Basic auth user: %s\n", record.AuthUser)
+		fmt.Printf("   Basic auth password: %s\n", password)
+	}
+	if len(record.AllowIPs) == 0 && record.AuthUser == "" {
+		ui.Warn("⚠️  %s is reachable from any address. Restrict it with --allow-ip or --auth-user\n", t.title)
+	}
+}
+
+// buildRecord validates the install options and returns the tool record
+// they describe
+func buildRecord(name string, t tool, opts InstallOptions) (Tool, error) {
+	record := Tool{
+		Name:     name,
+		Root:     t.root,
+		AuthUser: opts.AuthUser,
+	}
+
+	if opts.Host != "" && opts.Path != "" {
+		return record, fmt.Errorf("use either --domain or --path")
+	}
+	if opts.Host != "" {
+		record.Host = domain.Normalize(opts.Host)
+		if record.Host == "" || strings.ContainsAny(record.Host, "/ \t;{}") {
+			return record, fmt.Errorf("invalid host name: %s", opts.Host)
+		}
+		if domain.DomainExists(record.Host) {
+			return record, fmt.Errorf("%s is a domain managed with 'webstack domain'; use --path to serve %s on it", record.Host, t.title)
+		}
+	} else {
+		record.Path = "/" + name
+		if opts.Path != "" {
+			record.Path = "/" + strings.Trim(opts.Path, "/")
+		}
+		if !pathPattern.MatchString(record.Path) {
+			return record, fmt.Errorf("invalid path: %s", opts.Path)
+		}
+	}
+
+	for _, value := range opts.AllowIPs {
+		if err := validateAddress(value); err != nil {
+			return record, err
+		}
+		record.AllowIPs = append(record.AllowIPs, value)
+	}
+	if record.AuthUser != "" && strings.ContainsAny(record.AuthUser, ": \t\n") {
+		return record, fmt.Errorf("invalid basic auth user: %s", record.AuthUser)
+	}
+
+	server, err := frontServer()
+	if err != nil {
+		return record, err
+	}
+	record.Server = server
+
+	php, err := phpVersion(opts.PHPVersion)
+	if err != nil {
+		return record, err
+	}
+	record.PHPVersion = php
+	return record, nil
+}
+
+// validateAddress accepts an IP address or a CIDR range
+func validateAddress(value string) error {
+	if net.ParseIP(value) != nil {
+		return nil
+	}
+	if _, _, err := net.ParseCIDR(value); err == nil {
+		return nil
+	}
+	return fmt.Errorf("not an IP address or CIDR range: %s", value)
+}
+
+// frontServer returns the web server answering on port 80: Nginx when it is
+// installed (alone or in front of Apache), otherwise Apache
+func frontServer() (string, error) {
+	if _, err := os.Stat("/etc/nginx/nginx.conf"); err == nil {
+		return "nginx", nil
+	}
+	if _, err := os.Stat(osinfo.Current().ApacheConf); err == nil {
+		return "apache", nil
+	}
+	return "", fmt.Errorf("no web server installed (install Nginx or Apache first)")
+}
+
+// phpVersion returns the requested PHP-FPM version if it is installed, or
+// the newest installed one
+func phpVersion(requested string) (string, error) {
+	installed := phpfpm.Versions()
+	if len(installed) == 0 {
+		return "", fmt.Errorf("no PHP-FPM version installed (install one with 'webstack install php')")
+	}
+	if requested == "" {
+		sort.Slice(installed, func(i, j int) bool { return versionLess(installed[i], installed[j]) })
+		return installed[len(installed)-1], nil
+	}
+	for _, version := range installed {
+		if version == requested {
+			return version, nil
+		}
+	}
+	return "", fmt.Errorf("PHP %s is not installed (installed: %s)", requested, strings.Join(installed, ", "))
+}
+
+// versionLess compares dotted version numbers numerically
+func versionLess(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		var x, y int
+		fmt.Sscanf(as[i], "%d", &x)
+		fmt.Sscanf(bs[i], "%d", &y)
+		if x != y {
+			return x < y
+		}
+	}
+	return len(as) < len(bs)
+}
+
+func resolveVersion(t tool, requested string) (string, error) {
+	if requested != "" && requested != "latest" {
+		return requested, nil
+	}
+	version, err := t.latestVersion()
+	if err != nil {
+		return "", fmt.Errorf("could not look up the latest %s release: %v", t.title, err)
+	}
+	return version, nil
+}
+
+// deployRelease downloads a release next to the installation, configures
+// it and swaps it in, keeping the previous installation until the swap
+// succeeded
+func deployRelease(t tool, version string) error {
+	staging := t.root + ".new"
+	dryrun.RemoveAll(staging)
+	if err := dryrun.MkdirAll(staging, 0755); err != nil {
+		return fmt.Errorf("could not create %s: %v", staging, err)
+	}
+	if err := t.download(version, staging); err != nil {
+		dryrun.RemoveAll(staging)
+		return err
+	}
+
+	previous := ""
+	if _, err := os.Stat(t.root); err == nil {
+		previous = t.root
+	}
+	if err := t.configure(staging, previous); err != nil {
+		dryrun.RemoveAll(staging)
+		return err
+	}
+	if err := chownWebUser(staging); err != nil {
+		ui.Warn("⚠️  Warning: Could not set ownership: %v\n", err)
+	}
+
+	if dryrun.Enabled() {
+		fmt.Printf("🔎 [dry-run] would replace %s with %s\n", t.root, staging)
+		return nil
+	}
+	old := t.root + ".old"
+	os.RemoveAll(old)
+	if previous != "" {
+		if err := os.Rename(t.root, old); err != nil {
+			os.RemoveAll(staging)
+			return fmt.Errorf("could not move the previous installation aside: %v", err)
+		}
+	}
+	if err := os.Rename(staging, t.root); err != nil {
+		if previous != "" {
+			os.Rename(old, t.root)
+		}
+		return fmt.Errorf("could not install the new release: %v", err)
+	}
+	os.RemoveAll(old)
+	return nil
+}
+
+// Upgrade replaces an installed tool with a newer release, keeping its
+// configuration
+func Upgrade(name, version string) {
+	if _, ok := binaries[name]; ok {
+		upgradeCLI(name, version)
+		return
+	}
+	t, ok := available[name]
+	if !ok {
+		ui.Invalid("Unknown tool: %s. Available: %s\n", name, strings.Join(Names(), ", "))
+		return
+	}
+	record, err := getTool(name)
+	if err != nil {
+		ui.Fail("%s is not installed. Install it with: sudo webstack tools install %s\n", t.title, name)
+		return
+	}
+
+	target, err := resolveVersion(t, version)
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	if target == record.Version {
+		fmt.Printf("✅ %s %s is up to date\n", t.title, record.Version)
+		return
+	}
+
+	fmt.Printf("⬆️  Upgrading %s %s to %s...\n", t.title, record.Version, target)
+	if err := deployRelease(t, target); err != nil {
+		ui.Fail("❌ Upgrade failed, %s %s is still installed: %v\n", t.title, record.Version, err)
+		return
+	}
+
+	record.Version = target
+	record.UpgradedAt = time.Now()
+	if err := saveTool(*record); err != nil {
+		ui.Warn("⚠️  Warning: Could not save %s: %v\n", toolsFile, err)
+	}
+	fmt.Printf("✅ %s upgraded to %s\n", t.title, target)
+}
+
+// Uninstall removes a tool, its web server configuration and access files
+func Uninstall(name string) {
+	if _, ok := binaries[name]; ok {
+		uninstallCLI(name)
+		return
+	}
+	t, ok := available[name]
+	if !ok {
+		ui.Invalid("Unknown tool: %s. Available: %s\n", name, strings.Join(Names(), ", "))
+		return
+	}
+
+	record, err := getTool(name)
+	if err != nil {
+		// Installations made before tools.json still have their files
+		if _, statErr := os.Stat(t.root); statErr != nil {
+			fmt.Printf("ℹ️  %s is not installed\n", t.title)
+			return
+		}
+		record = &Tool{Name: name, Root: t.root, Path: "/" + name}
+	}
+
+	fmt.Printf("🗑️  Removing %s...\n", t.title)
+	if err := removeWebConfig(*record); err != nil {
+		ui.Warn("⚠️  Warning: %v\n", err)
+	}
+	dryrun.Remove(accessFile(name))
+	if err := dryrun.RemoveAll(t.root); err != nil {
+		ui.Fail("❌ Failed to remove %s: %v\n", t.root, err)
+		return
+	}
+	if err := deleteTool(name); err != nil {
+		ui.Warn("⚠️  Warning: Could not update %s: %v\n", toolsFile, err)
+	}
+	domain.ReloadWebServers()
+	fmt.Printf("✅ %s uninstalled\n", t.title)
+}
+
+// Status lists the installed tools, how web tools are served and where
+// command-line tools are installed
+func Status() {
+	tools, err := loadTools()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	if len(tools) == 0 {
+		fmt.Println("ℹ️  No tools installed. Available: " + strings.Join(Names(), ", "))
+		return
+	}
+
+	fmt.Println("🧰 Installed tools")
+	for _, record := range tools {
+		if record.Kind == kindCLI {
+			fmt.Printf("\n✅ %s %s\n", binaries[record.Name].title, record.Version)
+			fmt.Printf("   Binary: %s\n", record.Root)
+			if !record.UpgradedAt.IsZero() {
+				fmt.Printf("   Upgraded: %s\n", record.UpgradedAt.Format("2006-01-02 15:04"))
+			}
+			continue
+		}
+		t := available[record.Name]
+		fmt.Printf("\n✅ %s %s\n", t.title, record.Version)
+		fmt.Printf("   URL: %s\n", toolURL(record))
+		fmt.Printf("   Location: %s\n", record.Root)
+		fmt.Printf("   Served by: %s, PHP %s\n", record.Server, record.PHPVersion)
+		access := "anyone"
+		var rules []string
+		if len(record.AllowIPs) > 0 {
+			rules = append(rules, "from "+strings.Join(record.AllowIPs, ", "))
+		}
+		if record.AuthUser != "" {
+			rules = append(rules, "basic auth user "+record.AuthUser)
+		}
+		if len(rules) > 0 {
+			access = strings.Join(rules, ", ")
+		}
+		fmt.Printf("   Access: %s\n", access)
+		if !record.UpgradedAt.IsZero() {
+			fmt.Printf("   Upgraded: %s\n", record.UpgradedAt.Format("2006-01-02 15:04"))
+		}
+	}
+}
+
+// toolURL describes where a tool is reached
+func toolURL(record Tool) string {
+	if record.Host != "" {
+		scheme := "http"
+		if cert, _ := findCertificate(record.Host); cert != "" {
+			scheme = "https"
+		}
+		return scheme + "://" + record.Host + "/"
+	}
+	return fmt.Sprintf("http://YOUR_SERVER_IP%s/ or https://<domain>%s/", record.Path, record.Path)
+}
+
+func loadTools() ([]Tool, error) {
+	data, err := ioutil.ReadFile(toolsFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", toolsFile, err)
+	}
+	var tools []Tool
+	if err := json.Unmarshal(data, &tools); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %v", toolsFile, err)
+	}
+	return tools, nil
+}
+
+func getTool(name string) (*Tool, error) {
+	tools, err := loadTools()
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range tools {
+		if record.Name == name {
+			return &record, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not installed", name)
+}
+
+func saveTool(record Tool) error {
+	tools, err := loadTools()
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range tools {
+		if tools[i].Name == record.Name {
+			tools[i] = record
+			replaced = true
+		}
+	}
+	if !replaced {
+		tools = append(tools, record)
+	}
+	return writeTools(tools)
+}
+
+func deleteTool(name string) error {
+	tools, err := loadTools()
+	if err != nil {
+		return err
+	}
+	var kept []Tool
+	for _, record := range tools {
+		if record.Name != name {
+			kept = append(kept, record)
+		}
+	}
+	return writeTools(kept)
+}
+
+func writeTools(tools []Tool) error {
+	data, err := json.MarshalIndent(tools, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := dryrun.MkdirAll(filepath.Dir(toolsFile), 0755); err != nil {
+		return err
+	}
+	return dryrun.WriteFile(toolsFile, data, 0644)
+}
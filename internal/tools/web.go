@@ -0,0 +1,292 @@
+package tools
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"webstack-cli/internal/config"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/osinfo"
+	"webstack-cli/internal/templates"
+	"webstack-cli/internal/ui"
+)
+
+// Directories holding the web server configuration of tools. Nginx includes
+// /etc/nginx/tools in every server block, Apache loads its includes
+// directory (/etc/apache2/includes) at the server level so an Alias there
+// applies to every virtual host.
+const (
+	nginxToolsDir = "/etc/nginx/tools"
+	accessDir     = "/etc/webstack/tools"
+)
+
+// webConfigPath returns the file serving a tool: an include for a path, a
+// site for a dedicated host
+func webConfigPath(record Tool) string {
+	switch {
+	case record.Server == "nginx" && record.Host != "":
+		return "/etc/nginx/sites-available/webstack-" + record.Name + ".conf"
+	case record.Server == "nginx":
+		return filepath.Join(nginxToolsDir, record.Name+".conf")
+	case record.Host != "":
+		return filepath.Join(osinfo.Current().ApacheDir, "sites-available", "webstack-"+record.Name+".conf")
+	}
+	return filepath.Join(osinfo.Current().ApacheDir, "includes", record.Name+".conf")
+}
+
+// enabledLink returns the sites-enabled link of a dedicated host ("" for a
+// path)
+func enabledLink(record Tool) string {
+	if record.Host == "" {
+		return ""
+	}
+	if record.Server == "nginx" {
+		return "/etc/nginx/sites-enabled/webstack-" + record.Name + ".conf"
+	}
+	return filepath.Join(osinfo.Current().ApacheDir, "sites-enabled", "webstack-"+record.Name+".conf")
+}
+
+// accessFile is the htpasswd file of a tool's basic auth
+func accessFile(name string) string {
+	return filepath.Join(accessDir, name+".htpasswd")
+}
+
+// writeAccessFile writes the basic auth user of a tool, keeping the
+// existing file when no new password is given, or removes it when the tool
+// has no basic auth
+func writeAccessFile(record Tool, password string) error {
+	path := accessFile(record.Name)
+	if record.AuthUser == "" {
+		dryrun.Remove(path)
+		return nil
+	}
+	if password == "" {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+		return fmt.Errorf("no basic auth password for %s (use --auth-password)", record.AuthUser)
+	}
+
+	// APR1 hashes are understood by both Nginx and Apache
+	cmd := exec.Command("openssl", "passwd", "-apr1", "-stdin")
+	cmd.Stdin = strings.NewReader(password + "\n")
+	hash, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("could not hash the basic auth password: %v", err)
+	}
+	if err := dryrun.MkdirAll(accessDir, 0755); err != nil {
+		return fmt.Errorf("could not create %s: %v", accessDir, err)
+	}
+	content := record.AuthUser + ":" + strings.TrimSpace(string(hash)) + "\n"
+	if err := dryrun.WriteFile(path, []byte(content), 0640); err != nil {
+		return fmt.Errorf("could not write %s: %v", path, err)
+	}
+	// The web server workers read the file as www-data
+	dryrun.Run(exec.Command("chgrp", "www-data", path))
+	return nil
+}
+
+// renderWebConfig renders the web server configuration of a tool
+func renderWebConfig(record Tool) (string, error) {
+	content, err := templates.GetTemplate(record.Server + "/" + record.Name + ".conf")
+	if err != nil {
+		return "", fmt.Errorf("could not read %s template: %v", record.Server, err)
+	}
+	tmpl, err := template.New(record.Name).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return "", fmt.Errorf("could not parse %s template: %v", record.Server, err)
+	}
+
+	vars := map[string]interface{}{
+		"Path":       record.Path,
+		"Root":       record.Root,
+		"PHPSocket":  fmt.Sprintf("unix:/run/php/php%s-fpm.sock", record.PHPVersion),
+		"Host":       record.Host,
+		"AllowIPs":   record.AllowIPs,
+		"AuthFile":   "",
+		"SSLCert":    "",
+		"SSLKey":     "",
+		"ApachePort": 80,
+	}
+	if record.AuthUser != "" {
+		vars["AuthFile"] = accessFile(record.Name)
+	}
+	if record.Host != "" {
+		if cert, key := findCertificate(record.Host); cert != "" {
+			vars["SSLCert"] = cert
+			vars["SSLKey"] = key
+		}
+	}
+	if cfg, err := config.Load(); err == nil && cfg != nil {
+		if port := cfg.GetPort("apache"); port != 0 {
+			vars["ApachePort"] = port
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("could not execute %s template: %v", record.Server, err)
+	}
+	return buf.String(), nil
+}
+
+// deployWebConfig writes and enables the web server configuration of a
+// tool, removing it again when the web server rejects it
+func deployWebConfig(record Tool) error {
+	rendered, err := renderWebConfig(record)
+	if err != nil {
+		return err
+	}
+
+	// Earlier releases served phpMyAdmin from a catch-all server block in
+	// /etc/nginx/includes, which conflicts with the default server
+	if record.Server == "nginx" {
+		dryrun.Remove(filepath.Join("/etc/nginx/includes", record.Name+".conf"))
+	}
+
+	path := webConfigPath(record)
+	if err := dryrun.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("could not create %s: %v", filepath.Dir(path), err)
+	}
+	if err := dryrun.WriteFile(path, []byte(rendered), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", path, err)
+	}
+	if link := enabledLink(record); link != "" {
+		dryrun.MkdirAll(filepath.Dir(link), 0755)
+		dryrun.Remove(link)
+		if err := dryrun.Symlink(path, link); err != nil {
+			return fmt.Errorf("could not enable %s: %v", path, err)
+		}
+	}
+	if record.Server == "apache" {
+		for _, mod := range []string{"proxy", "proxy_fcgi", "alias", "auth_basic", "authn_file"} {
+			domain.EnableApacheModules(mod)
+		}
+	}
+
+	if err := testWebServer(record.Server); err != nil {
+		removeWebConfig(record)
+		return err
+	}
+	fmt.Printf("✅ %s configuration written: %s\n", record.Server, path)
+
+	// Vhosts generated before /etc/nginx/tools existed do not include it
+	if record.Server == "nginx" && record.Host == "" {
+		updateDefaultServer()
+		if vhostsMissingInclude() {
+			fmt.Println("🔄 Regenerating domain vhosts to include the tools directory...")
+			domain.RebuildConfigs()
+		}
+	}
+	return nil
+}
+
+// updateDefaultServer rewrites the default Nginx server from its template
+// when it does not include /etc/nginx/tools yet
+func updateDefaultServer() {
+	const path = "/etc/nginx/sites-available/default"
+	data, err := ioutil.ReadFile(path)
+	if err != nil || strings.Contains(string(data), nginxToolsDir) || !strings.Contains(string(data), "WebStack CLI") {
+		return
+	}
+	content, err := templates.GetNginxTemplate("default.conf")
+	if err != nil {
+		return
+	}
+	if err := dryrun.WriteFile(path, content, 0644); err != nil {
+		ui.Warn("⚠️  Warning: Could not update %s: %v\n", path, err)
+	}
+}
+
+// removeWebConfig removes the web server configuration of a tool
+func removeWebConfig(record Tool) error {
+	if link := enabledLink(record); link != "" {
+		dryrun.Remove(link)
+	}
+	path := webConfigPath(record)
+	if err := dryrun.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not remove %s: %v", path, err)
+	}
+	return nil
+}
+
+// testWebServer validates the configuration of a web server if it is
+// installed
+func testWebServer(server string) error {
+	var cmd *exec.Cmd
+	if server == "nginx" {
+		if _, err := exec.LookPath("nginx"); err != nil {
+			return nil
+		}
+		cmd = exec.Command("nginx", "-t")
+	} else {
+		ctl := osinfo.Current().ApacheCtl
+		if _, err := exec.LookPath(ctl); err != nil {
+			return nil
+		}
+		cmd = exec.Command(ctl, "configtest")
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s configuration test failed: %s", server, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// vhostsMissingInclude reports whether an enabled Nginx site does not
+// include /etc/nginx/tools yet
+func vhostsMissingInclude() bool {
+	files, _ := filepath.Glob("/etc/nginx/sites-enabled/*")
+	for _, file := range files {
+		if name := filepath.Base(file); name == "default" || strings.HasPrefix(name, "webstack-") {
+			continue
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if !strings.Contains(string(data), nginxToolsDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// findCertificate returns the certificate and key of an SSL domain whose
+// certificate also covers host, e.g. a wildcard certificate
+func findCertificate(host string) (string, string) {
+	domains, err := domain.All()
+	if err != nil {
+		return "", ""
+	}
+	for _, d := range domains {
+		if !d.SSLEnabled || d.SSLCertPath == "" || d.SSLKeyPath == "" {
+			continue
+		}
+		data, err := ioutil.ReadFile(d.SSLCertPath)
+		if err != nil {
+			continue
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err == nil && cert.VerifyHostname(host) == nil {
+			return d.SSLCertPath, d.SSLKeyPath
+		}
+	}
+	return "", ""
+}
+
+// chownWebUser gives an installation to www-data, which PHP-FPM runs as
+func chownWebUser(dir string) error {
+	return dryrun.Run(exec.Command("chown", "-R", "www-data:www-data", dir))
+}
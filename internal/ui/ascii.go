@@ -0,0 +1,91 @@
+package ui
+
+import (
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// asciiReplacements maps the emoji and symbols used throughout the CLI
+// output to plain ASCII equivalents
+var asciiReplacements = map[rune]string{
+	'✅': "[OK]",
+	'✓': "[OK]",
+	'❌': "[ERROR]",
+	'⚠': "[WARN]",
+	'🚨': "[ALERT]",
+	'ℹ': "[INFO]",
+	'💡': "[TIP]",
+	'⏭': "[SKIP]",
+	'✋': "[CANCELLED]",
+	'🚫': "[DENIED]",
+	'⊘': "[-]",
+	'🔄': "*",
+	'📦': "*",
+	'⚙': "*",
+	'🔧': "*",
+	'📋': "*",
+	'🗑': "*",
+	'🧹': "*",
+	'🔒': "*",
+	'🔓': "*",
+	'🔐': "*",
+	'🔑': "*",
+	'🔍': "*",
+	'🔥': "*",
+	'🔪': "*",
+	'🚀': "*",
+	'🎉': "*",
+	'─': "-",
+	'━': "-",
+	'═': "=",
+	'•': "*",
+	'→': "->",
+	'⬇': "v",
+	'�': "?",
+}
+
+// ToASCII converts a string to its plain-ASCII representation.
+// Known emoji become tags like [OK] or [WARN], other symbols become "*"
+// and letters are kept as-is so user data is not mangled.
+func ToASCII(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			b.WriteRune(r)
+			continue
+		}
+		if repl, ok := asciiReplacements[r]; ok {
+			b.WriteString(repl)
+			continue
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		} else if unicode.Is(unicode.So, r) {
+			b.WriteString("*")
+		}
+		// Variation selectors, joiners and other marks are dropped
+	}
+	return b.String()
+}
+
+// DetectPlain reports whether the current terminal is unlikely to render
+// emoji correctly (non-UTF-8 locale or a dumb/serial terminal)
+func DetectPlain() bool {
+	term := os.Getenv("TERM")
+	if term == "dumb" || strings.HasPrefix(term, "vt") {
+		return true
+	}
+
+	locale := ""
+	for _, env := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := os.Getenv(env); v != "" {
+			locale = v
+			break
+		}
+	}
+
+	normalized := strings.ToLower(strings.ReplaceAll(locale, "-", ""))
+	return !strings.Contains(normalized, "utf8")
+}
@@ -0,0 +1,123 @@
+package ui
+
+import (
+	"os"
+	"strings"
+)
+
+// Level classifies a line of output
+type Level int
+
+const (
+	LevelNone Level = iota
+	LevelSuccess
+	LevelWarning
+	LevelError
+	LevelValidation
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+)
+
+var levelColors = map[Level]string{
+	LevelSuccess:    colorGreen,
+	LevelWarning:    colorYellow,
+	LevelError:      colorRed,
+	LevelValidation: colorRed,
+}
+
+var (
+	validationPrefixes = []string{"Invalid ", "Unknown "}
+	errorPrefixes      = []string{"❌", "🚨", "[ERROR]", "Error:", "Error ", "ERROR"}
+	warningPrefixes    = []string{"⚠", "[WARN]", "Warning:", "WARNING"}
+	successPrefixes    = []string{"✅", "✓", "[OK]"}
+)
+
+// Classify returns the level of a line of output based on its prefix, to
+// color and log it.
+// Messages about invalid or unknown input (optionally after an error
+// marker) are validation errors.
+func Classify(line string) Level {
+	line = strings.TrimLeft(line, " \t")
+
+	message := line
+	for _, p := range []string{"❌", "[ERROR]"} {
+		message = strings.TrimLeft(strings.TrimPrefix(message, p), " ")
+	}
+	for _, p := range validationPrefixes {
+		if strings.HasPrefix(message, p) {
+			return LevelValidation
+		}
+	}
+
+	for _, p := range errorPrefixes {
+		if strings.HasPrefix(line, p) {
+			return LevelError
+		}
+	}
+	for _, p := range warningPrefixes {
+		if strings.HasPrefix(line, p) {
+			return LevelWarning
+		}
+	}
+	for _, p := range successPrefixes {
+		if strings.HasPrefix(line, p) {
+			return LevelSuccess
+		}
+	}
+	return LevelNone
+}
+
+// DetectColor reports whether colored output should be used for f.
+// Colors are disabled when NO_COLOR is set, TERM is dumb or f is not a terminal.
+func DetectColor(f *os.File) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(f)
+}
+
+// IsColor reports whether colored output is active
+func IsColor() bool {
+	return colorOut
+}
+
+// Red returns s in red when colors are enabled
+func Red(s string) string {
+	return colorize(colorRed, s)
+}
+
+// Green returns s in green when colors are enabled
+func Green(s string) string {
+	return colorize(colorGreen, s)
+}
+
+// Yellow returns s in yellow when colors are enabled
+func Yellow(s string) string {
+	return colorize(colorYellow, s)
+}
+
+func colorize(code, s string) string {
+	if !colorOut {
+		return s
+	}
+	return code + s + colorReset
+}
+
+func isTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
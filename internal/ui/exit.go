@@ -0,0 +1,133 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Exit codes of all commands. They follow what the command reported with
+// Fail, Warn and Invalid, not what it or its child processes printed.
+const (
+	ExitOK         = 0 // Everything succeeded
+	ExitPartial    = 1 // Completed with warnings
+	ExitFailure    = 2 // An operation failed
+	ExitValidation = 3 // Invalid arguments, flags or values
+)
+
+var (
+	mu         sync.Mutex
+	strict     bool
+	warnings   int
+	errors     int
+	validation bool
+	problems   []string
+)
+
+// maxProblems bounds the warning and error lines kept for Problems
+const maxProblems = 500
+
+// SetStrict turns warnings into failures when computing the exit code
+func SetStrict(enabled bool) {
+	strict = enabled
+}
+
+// MarkValidationError records that the command was given invalid input
+func MarkValidationError() {
+	mu.Lock()
+	defer mu.Unlock()
+	validation = true
+}
+
+// Warnings returns the number of warnings reported so far
+func Warnings() int {
+	mu.Lock()
+	defer mu.Unlock()
+	return warnings
+}
+
+// Errors returns the number of errors reported so far
+func Errors() int {
+	mu.Lock()
+	defer mu.Unlock()
+	return errors
+}
+
+// Problems returns the warnings and errors reported so far, for summaries
+// of long operations whose warnings scroll away
+func Problems() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]string(nil), problems...)
+}
+
+// ExitCode returns the exit code for everything reported so far
+func ExitCode() int {
+	mu.Lock()
+	defer mu.Unlock()
+
+	switch {
+	case validation:
+		return ExitValidation
+	case errors > 0:
+		return ExitFailure
+	case warnings > 0 && strict:
+		return ExitFailure
+	case warnings > 0:
+		return ExitPartial
+	}
+	return ExitOK
+}
+
+// Finish flushes pending output and exits with the computed exit code
+func Finish() {
+	Flush()
+	Exit(ExitCode())
+}
+
+// Fail prints a message like fmt.Printf and records that an operation
+// failed, so the command exits with ExitFailure
+func Fail(format string, args ...interface{}) {
+	report(LevelError, format, args...)
+}
+
+// Warn prints a message like fmt.Printf and records a warning, so the
+// command exits with ExitPartial (ExitFailure with --strict)
+func Warn(format string, args ...interface{}) {
+	report(LevelWarning, format, args...)
+}
+
+// Invalid prints a message like fmt.Printf and records that the command
+// was given invalid input, so it exits with ExitValidation
+func Invalid(format string, args ...interface{}) {
+	report(LevelValidation, format, args...)
+}
+
+// report records a message by its level, before printing it so summaries
+// don't depend on the output filter
+func report(level Level, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+
+	mu.Lock()
+	switch level {
+	case LevelError:
+		errors++
+		keepProblem(message)
+	case LevelWarning:
+		warnings++
+		keepProblem(message)
+	case LevelValidation:
+		validation = true
+	}
+	mu.Unlock()
+
+	fmt.Print(message)
+}
+
+// keepProblem remembers a warning or error message without its blank
+// lines; the caller holds mu
+func keepProblem(message string) {
+	if len(problems) < maxProblems {
+		problems = append(problems, strings.Trim(message, "\n"))
+	}
+}
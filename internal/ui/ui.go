@@ -0,0 +1,239 @@
+package ui
+
+import (
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Options controls how command output is rendered
+type Options struct {
+	Plain bool // Convert emoji and symbols to plain ASCII
+	Color bool // Color errors, warnings and successes (only on terminals)
+	Quiet bool // Only print warnings, errors and prompts to stdout
+
+	// Log receives every complete line printed to stdout with its level,
+	// including the lines hidden by Quiet
+	Log func(level Level, line string)
+}
+
+var (
+	active    bool
+	plain     bool
+	colorOut  bool
+	realOut   *os.File
+	realErr   *os.File
+	outWriter *os.File
+	errWriter *os.File
+	done      chan struct{}
+)
+
+// IsPlain reports whether plain-ASCII output mode is active
+func IsPlain() bool {
+	return plain
+}
+
+// Start applies the output options. Stdout and stderr (including output
+// of child processes) are redirected through a filter until Flush is
+// called, so errors and warnings can be colored.
+func Start(opts Options) {
+	if active {
+		return
+	}
+
+	colorOut = opts.Color && DetectColor(os.Stdout)
+	colorErr := opts.Color && DetectColor(os.Stderr)
+
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		colorOut = false
+		return
+	}
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		outR.Close()
+		outW.Close()
+		colorOut = false
+		return
+	}
+
+	active = true
+	plain = opts.Plain
+	realOut, realErr = os.Stdout, os.Stderr
+	outWriter, errWriter = outW, errW
+	os.Stdout, os.Stderr = outW, errW
+
+	done = make(chan struct{}, 2)
+	go filter(outR, &lineWriter{dst: realOut, plain: plain, color: colorOut, quiet: opts.Quiet, log: opts.Log, atLineStart: true})
+	go filter(errR, &lineWriter{dst: realErr, plain: plain, color: colorErr, atLineStart: true})
+}
+
+// Flush waits for all filtered output to be written and restores the
+// original stdout and stderr
+func Flush() {
+	if !active {
+		return
+	}
+
+	outWriter.Close()
+	errWriter.Close()
+	<-done
+	<-done
+
+	os.Stdout, os.Stderr = realOut, realErr
+	active = false
+}
+
+// Terminal returns the original stdout and stderr, for interactive child
+// processes (shells, editors, tail -f) that need a terminal instead of the
+// filter. What they print is neither converted nor logged.
+func Terminal() (out, err *os.File) {
+	if !active {
+		return os.Stdout, os.Stderr
+	}
+	return realOut, realErr
+}
+
+// Exit flushes pending output and terminates the program with the given code.
+// Use Finish to exit with the code computed from the reported output.
+func Exit(code int) {
+	Flush()
+	os.Exit(code)
+}
+
+// lineWriter renders output line by line: each line is classified by its
+// prefix, colored accordingly and converted to ASCII in plain mode. In quiet
+// mode lines below warnings are held back and dropped at their newline.
+type lineWriter struct {
+	dst         io.Writer
+	plain       bool
+	color       bool
+	quiet       bool
+	log         func(Level, string)
+	atLineStart bool
+	colored     bool
+	level       Level           // Level of the current line
+	hidden      bool            // The current line is dropped in quiet mode
+	line        strings.Builder // Current line, for log and release
+}
+
+func (w *lineWriter) write(text string) {
+	var b strings.Builder
+
+	for len(text) > 0 {
+		segment := text
+		newline := strings.IndexByte(text, '\n')
+		if newline >= 0 {
+			segment = text[:newline]
+		}
+
+		if w.atLineStart && segment != "" {
+			w.level = Classify(segment)
+			w.hidden = w.quiet && w.level < LevelWarning
+			if code, ok := levelColors[w.level]; ok && w.color && !w.hidden {
+				b.WriteString(code)
+				w.colored = true
+			}
+			w.atLineStart = false
+		}
+		if w.log != nil || w.quiet {
+			w.line.WriteString(segment)
+		}
+
+		if w.hidden {
+			// Shown by release if the line turns out to be a prompt
+		} else if w.plain {
+			b.WriteString(ToASCII(segment))
+		} else {
+			b.WriteString(segment)
+		}
+
+		if newline < 0 {
+			break
+		}
+
+		if w.colored {
+			b.WriteString(colorReset)
+			w.colored = false
+		}
+		if !w.hidden && !(w.quiet && w.atLineStart) {
+			b.WriteByte('\n')
+		}
+		if w.log != nil && w.line.Len() > 0 {
+			w.log(w.level, w.line.String())
+		}
+		w.line.Reset()
+		w.atLineStart = true
+		w.hidden = false
+		text = text[newline+1:]
+	}
+
+	io.WriteString(w.dst, b.String())
+}
+
+// release shows the hidden part of an unterminated line. A line the
+// program stopped writing in the middle of is a prompt waiting for input.
+func (w *lineWriter) release() {
+	if !w.hidden {
+		return
+	}
+	w.hidden = false
+	text := w.line.String()
+	if w.plain {
+		text = ToASCII(text)
+	}
+	io.WriteString(w.dst, text)
+}
+
+// close resets the terminal color and logs the last line if it was left
+// unterminated
+func (w *lineWriter) close() {
+	if w.colored {
+		io.WriteString(w.dst, colorReset)
+	}
+	if w.log != nil && w.line.Len() > 0 {
+		w.log(w.level, w.line.String())
+	}
+}
+
+// filter copies src through w. Chunks are forwarded as soon as they are
+// read so prompts without a trailing newline still show up before the
+// program waits for input.
+func filter(src *os.File, w *lineWriter) {
+	defer func() { done <- struct{}{} }()
+	defer src.Close()
+	defer w.close()
+
+	buf := make([]byte, 4096)
+	var pending []byte
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			data := append(pending, buf[:n]...)
+
+			// Keep an incomplete trailing UTF-8 sequence for the next read
+			cut := len(data)
+			for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+				if utf8.RuneStart(data[i]) {
+					if !utf8.FullRune(data[i:]) {
+						cut = i
+					}
+					break
+				}
+			}
+
+			w.write(string(data[:cut]))
+			pending = append([]byte(nil), data[cut:]...)
+			if n < len(buf) && !w.atLineStart {
+				w.release()
+			}
+		}
+		if err != nil {
+			if len(pending) > 0 {
+				w.write(string(pending))
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,499 @@
+package worker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"webstack-cli/internal/domain"
+	"webstack-cli/internal/dryrun"
+	"webstack-cli/internal/ui"
+)
+
+const (
+	workersFile = "/etc/webstack/workers.json"
+	unitDir     = "/etc/systemd/system"
+
+	// MaxReplicas caps the instances of one worker
+	MaxReplicas = 32
+)
+
+// Restart policies accepted for a worker
+var restartPolicies = []string{"always", "on-failure"}
+
+// Worker is a long-running process of a domain, such as a queue consumer,
+// run by systemd as one or more instances of a template unit
+type Worker struct {
+	Domain    string    `json:"domain"`
+	Name      string    `json:"name"`
+	Command   string    `json:"command"`
+	Replicas  int       `json:"replicas"`
+	Restart   string    `json:"restart"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Options describes a worker to add
+type Options struct {
+	Name     string
+	Command  string
+	Replicas int
+	Restart  string // "always" (default) or "on-failure"
+}
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// unitName returns the systemd template unit of a worker, e.g.
+// webstack-worker-example.com-queue@.service
+func unitName(w Worker) string {
+	return fmt.Sprintf("webstack-worker-%s-%s@.service", w.Domain, w.Name)
+}
+
+// instanceName returns the unit of one replica, numbered from 1
+func instanceName(w Worker, n int) string {
+	return strings.Replace(unitName(w), "@.", "@"+strconv.Itoa(n)+".", 1)
+}
+
+// logFile returns the file the instances of a worker write to; the domain's
+// logrotate configuration rotates it with the other logs
+func logFile(w Worker) string {
+	return filepath.Join(domain.HomeDir(w.Domain), "logs", "worker-"+w.Name+".log")
+}
+
+// Add creates a worker for a domain and starts its instances. The command
+// runs as www-data in the application folder (htdocs) through /bin/sh; a leading "php" runs the PHP
+// CLI of the domain's PHP-FPM version.
+func Add(domainName string, opts Options) {
+	d, err := domain.GetDomain(domainName)
+	if err != nil {
+		ui.Fail("Domain %s not found\n", domainName)
+		return
+	}
+	if !namePattern.MatchString(opts.Name) {
+		ui.Invalid("Invalid worker name: %s (lowercase letters, digits and -)\n", opts.Name)
+		return
+	}
+	if strings.TrimSpace(opts.Command) == "" {
+		ui.Invalid("Invalid command: use --cmd, e.g. --cmd \"php artisan queue:work\"\n")
+		return
+	}
+	if opts.Replicas == 0 {
+		opts.Replicas = 1
+	}
+	if opts.Replicas < 1 || opts.Replicas > MaxReplicas {
+		ui.Invalid("Invalid replica count: %d (1-%d)\n", opts.Replicas, MaxReplicas)
+		return
+	}
+	if opts.Restart == "" {
+		opts.Restart = "always"
+	}
+	if !validRestart(opts.Restart) {
+		ui.Invalid("Invalid restart policy: %s (%s)\n", opts.Restart, strings.Join(restartPolicies, ", "))
+		return
+	}
+
+	workers, err := load()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	for _, w := range workers {
+		if w.Domain == d.Name && w.Name == opts.Name {
+			ui.Fail("❌ %s already has a worker named %s. Remove it first or change its replicas with 'webstack worker scale'\n", d.Name, opts.Name)
+			return
+		}
+	}
+
+	w := Worker{
+		Domain:    d.Name,
+		Name:      opts.Name,
+		Command:   strings.TrimSpace(opts.Command),
+		Replicas:  opts.Replicas,
+		Restart:   opts.Restart,
+		CreatedAt: time.Now(),
+	}
+	if err := writeUnit(w, d); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	if err := startInstances(w, 1, w.Replicas); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+
+	workers = append(workers, w)
+	if err := save(workers); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+
+	fmt.Printf("✅ Worker %s added to %s with %d replica(s)\n", w.Name, w.Domain, w.Replicas)
+	fmt.Printf("   Unit: %s\n", unitName(w))
+	fmt.Printf("   Logs: %s\n", logFile(w))
+	fmt.Printf("💡 Check it with: sudo webstack worker status %s\n", w.Domain)
+}
+
+// Remove stops a worker and deletes its unit
+func Remove(domainName, name string) {
+	domainName = domain.Normalize(domainName)
+	workers, err := load()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	index := find(workers, domainName, name)
+	if index < 0 {
+		ui.Fail("❌ %s has no worker named %s\n", domainName, name)
+		return
+	}
+
+	removeUnit(workers[index])
+	workers = append(workers[:index], workers[index+1:]...)
+	if err := save(workers); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	fmt.Printf("✅ Worker %s of %s removed\n", name, domainName)
+}
+
+// RemoveDomain removes the workers of a deleted domain
+func RemoveDomain(domainName string) {
+	domainName = domain.Normalize(domainName)
+	workers, err := load()
+	if err != nil {
+		ui.Warn("⚠️  Warning: %v\n", err)
+		return
+	}
+	var kept []Worker
+	for _, w := range workers {
+		if w.Domain != domainName {
+			kept = append(kept, w)
+			continue
+		}
+		removeUnit(w)
+		fmt.Printf("✅ Worker %s removed\n", w.Name)
+	}
+	if len(kept) == len(workers) {
+		return
+	}
+	if err := save(kept); err != nil {
+		ui.Warn("⚠️  Warning: %v\n", err)
+	}
+}
+
+// Scale changes the number of running instances of a worker
+func Scale(domainName, name string, replicas int) {
+	if replicas < 1 || replicas > MaxReplicas {
+		ui.Invalid("Invalid replica count: %d (1-%d)\n", replicas, MaxReplicas)
+		return
+	}
+	domainName = domain.Normalize(domainName)
+	workers, err := load()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	index := find(workers, domainName, name)
+	if index < 0 {
+		ui.Fail("❌ %s has no worker named %s\n", domainName, name)
+		return
+	}
+
+	w := &workers[index]
+	if replicas > w.Replicas {
+		if err := startInstances(*w, w.Replicas+1, replicas); err != nil {
+			ui.Fail("❌ %v\n", err)
+			return
+		}
+	}
+	for n := replicas + 1; n <= w.Replicas; n++ {
+		dryrun.Run(exec.Command("systemctl", "disable", "--now", instanceName(*w, n)))
+	}
+	previous := w.Replicas
+	w.Replicas = replicas
+	if err := save(workers); err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	fmt.Printf("✅ Worker %s of %s scaled from %d to %d replica(s)\n", name, domainName, previous, replicas)
+}
+
+// Restart restarts every instance of one worker, or of all workers of a
+// domain when name is empty, e.g. after deploying new code
+func Restart(domainName, name string) {
+	domainName = domain.Normalize(domainName)
+	workers, err := load()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	restarted := 0
+	for _, w := range workers {
+		if w.Domain != domainName || (name != "" && w.Name != name) {
+			continue
+		}
+		for n := 1; n <= w.Replicas; n++ {
+			if err := dryrun.Run(exec.Command("systemctl", "restart", instanceName(w, n))); err != nil {
+				ui.Fail("❌ Could not restart %s: %v\n", instanceName(w, n), err)
+			}
+		}
+		fmt.Printf("🔄 Restarted worker %s (%d replica(s))\n", w.Name, w.Replicas)
+		restarted++
+	}
+	if restarted == 0 {
+		ui.Warn("⚠️  No matching workers for %s\n", domainName)
+	}
+}
+
+// List prints the workers, optionally of one domain
+func List(domainName string) {
+	workers, err := load()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	workers = filter(workers, domain.Normalize(domainName))
+	if len(workers) == 0 {
+		fmt.Println("ℹ️  No workers configured")
+		return
+	}
+
+	fmt.Printf("%-30s %-16s %-9s %-11s %s\n", "DOMAIN", "NAME", "REPLICAS", "RESTART", "COMMAND")
+	for _, w := range workers {
+		fmt.Printf("%-30s %-16s %-9d %-11s %s\n", w.Domain, w.Name, w.Replicas, w.Restart, w.Command)
+	}
+}
+
+// Status prints the state of every instance, its PID, uptime and restart
+// count as reported by systemd
+func Status(domainName string) {
+	workers, err := load()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	workers = filter(workers, domain.Normalize(domainName))
+	if len(workers) == 0 {
+		fmt.Println("ℹ️  No workers configured")
+		return
+	}
+
+	for _, w := range workers {
+		fmt.Printf("⚙️  %s / %s: %s\n", w.Domain, w.Name, w.Command)
+		if !domain.DomainExists(w.Domain) {
+			fmt.Println("   ⚠️  The domain no longer exists; remove the worker with 'webstack worker remove'")
+		}
+		for n := 1; n <= w.Replicas; n++ {
+			props := unitProperties(instanceName(w, n))
+			icon := "✅"
+			if props["ActiveState"] != "active" {
+				icon = "❌"
+			}
+			line := fmt.Sprintf("   %s #%d %s/%s", icon, n, props["ActiveState"], props["SubState"])
+			if pid := props["MainPID"]; pid != "" && pid != "0" {
+				line += " pid " + pid
+			}
+			if since := props["ActiveEnterTimestamp"]; since != "" && props["ActiveState"] == "active" {
+				line += " since " + since
+			}
+			if restarts := props["NRestarts"]; restarts != "" && restarts != "0" {
+				line += ", restarted " + restarts + "x"
+			}
+			fmt.Println(line)
+		}
+		fmt.Printf("   Logs: %s\n", logFile(w))
+	}
+}
+
+// TailLogs prints the last lines of a worker's log and keeps following it
+func TailLogs(domainName, name string, lines int, follow bool) {
+	workers, err := load()
+	if err != nil {
+		ui.Fail("❌ %v\n", err)
+		return
+	}
+	index := find(workers, domain.Normalize(domainName), name)
+	if index < 0 {
+		ui.Fail("❌ %s has no worker named %s\n", domainName, name)
+		return
+	}
+	path := logFile(workers[index])
+	if _, err := os.Stat(path); err != nil {
+		ui.Warn("⚠️  %s has not written any output yet\n", path)
+		return
+	}
+
+	args := []string{"-n", strconv.Itoa(lines)}
+	if follow {
+		args = append(args, "-F")
+	}
+	// Log lines go to the terminal as they are, also with tail -F
+	cmd := exec.Command("tail", append(args, path)...)
+	cmd.Stdout, cmd.Stderr = ui.Terminal()
+	cmd.Run()
+}
+
+// writeUnit writes the template unit of a worker. Output is appended to the
+// worker log in the domain's logs directory, which systemd opens as root.
+func writeUnit(w Worker, d *domain.Domain) error {
+	content := fmt.Sprintf(`# Managed by webstack - worker %s of %s
+[Unit]
+Description=WebStack worker %s of %s (replica %%i)
+After=network.target
+StartLimitIntervalSec=300
+StartLimitBurst=10
+
+[Service]
+Type=simple
+User=www-data
+Group=www-data
+WorkingDirectory=%s
+ExecStart=/bin/sh -c %s
+Restart=%s
+RestartSec=5
+KillSignal=SIGTERM
+TimeoutStopSec=60
+StandardOutput=append:%s
+StandardError=append:%s
+SyslogIdentifier=webstack-worker-%s-%s
+
+[Install]
+WantedBy=multi-user.target
+`, w.Name, w.Domain, w.Name, w.Domain,
+		d.AppRoot(),
+		systemdQuote(command(w, d)),
+		w.Restart, logFile(w), logFile(w), w.Domain, w.Name)
+
+	path := filepath.Join(unitDir, unitName(w))
+	if err := dryrun.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", path, err)
+	}
+	if err := dryrun.Run(exec.Command("systemctl", "daemon-reload")); err != nil {
+		return fmt.Errorf("systemctl daemon-reload failed: %v", err)
+	}
+	return nil
+}
+
+// removeUnit stops and disables the instances of a worker and deletes its
+// unit; the log file is kept
+func removeUnit(w Worker) {
+	for n := 1; n <= w.Replicas; n++ {
+		dryrun.Run(exec.Command("systemctl", "disable", "--now", instanceName(w, n)))
+	}
+	if err := dryrun.Remove(filepath.Join(unitDir, unitName(w))); err != nil && !os.IsNotExist(err) {
+		ui.Warn("⚠️  Warning: Could not remove %s: %v\n", unitName(w), err)
+	}
+	dryrun.Run(exec.Command("systemctl", "daemon-reload"))
+}
+
+// startInstances enables and starts the replicas from..to
+func startInstances(w Worker, from, to int) error {
+	for n := from; n <= to; n++ {
+		if err := dryrun.Run(exec.Command("systemctl", "enable", "--now", instanceName(w, n))); err != nil {
+			return fmt.Errorf("could not start %s: %v (see journalctl -u %s)", instanceName(w, n), err, instanceName(w, n))
+		}
+	}
+	return nil
+}
+
+// command returns the command of a worker with a leading "php" replaced by
+// the CLI of the domain's PHP version
+func command(w Worker, d *domain.Domain) string {
+	if d.PHPVersion != "" && (w.Command == "php" || strings.HasPrefix(w.Command, "php ")) {
+		return "/usr/bin/php" + d.PHPVersion + strings.TrimPrefix(w.Command, "php")
+	}
+	return w.Command
+}
+
+// systemdQuote quotes a value as a single ExecStart argument, escaping the
+// characters systemd would otherwise interpret
+func systemdQuote(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `%`, `%%`, `$`, `$$`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+// unitProperties reads the state of a unit from systemctl show
+func unitProperties(unit string) map[string]string {
+	props := map[string]string{}
+	output, err := exec.Command("systemctl", "show", unit,
+		"--property=ActiveState,SubState,MainPID,NRestarts,ActiveEnterTimestamp").Output()
+	if err != nil {
+		props["ActiveState"] = "unknown"
+		return props
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			props[key] = value
+		}
+	}
+	return props
+}
+
+func validRestart(policy string) bool {
+	for _, p := range restartPolicies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
+
+func find(workers []Worker, domainName, name string) int {
+	for i, w := range workers {
+		if w.Domain == domainName && w.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func filter(workers []Worker, domainName string) []Worker {
+	var shown []Worker
+	for _, w := range workers {
+		if domainName == "" || w.Domain == domainName {
+			shown = append(shown, w)
+		}
+	}
+	sort.Slice(shown, func(i, j int) bool {
+		if shown[i].Domain != shown[j].Domain {
+			return shown[i].Domain < shown[j].Domain
+		}
+		return shown[i].Name < shown[j].Name
+	})
+	return shown
+}
+
+func load() ([]Worker, error) {
+	data, err := ioutil.ReadFile(workersFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", workersFile, err)
+	}
+	var workers []Worker
+	if err := json.Unmarshal(data, &workers); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %v", workersFile, err)
+	}
+	return workers, nil
+}
+
+func save(workers []Worker) error {
+	data, err := json.MarshalIndent(workers, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := dryrun.MkdirAll(filepath.Dir(workersFile), 0755); err != nil {
+		return err
+	}
+	if err := dryrun.WriteFile(workersFile, data, 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", workersFile, err)
+	}
+	return nil
+}
@@ -5,13 +5,14 @@ import (
 	"os"
 
 	"webstack-cli/cmd"
+	"webstack-cli/internal/ui"
 )
 
 func main() {
 	// Check if running as root on Linux
 	if os.Geteuid() != 0 {
 		fmt.Println("This tool requires root privileges. Please run with sudo.")
-		os.Exit(1)
+		os.Exit(ui.ExitFailure)
 	}
 
 	cmd.Execute()